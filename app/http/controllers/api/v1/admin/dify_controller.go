@@ -0,0 +1,25 @@
+package admin
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"tarot/pkg/dify"
+	"tarot/pkg/response"
+)
+
+type DifyController struct{}
+
+func NewDifyController() *DifyController {
+	return &DifyController{}
+}
+
+// Instances 各 Dify 实例的健康状态与负载
+// GET /v1/admin/dify/instances
+func (dc *DifyController) Instances(c *gin.Context) {
+	if dify.Service == nil {
+		response.Abort500(c, "Dify 服务未初始化")
+		return
+	}
+
+	response.Data(c, dify.Service.HealthReport())
+}
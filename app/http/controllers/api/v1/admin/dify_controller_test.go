@@ -0,0 +1,108 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"tarot/app/http/middlewares"
+	"tarot/pkg/dify"
+	"tarot/pkg/testutil"
+)
+
+// setDifyService 在测试期间替换全局 Dify 服务：第一个实例健康，第二个实例不健康
+func setDifyService(t *testing.T) {
+	t.Helper()
+	s := dify.NewDifyService(&dify.Config{
+		URLs:    []string{"http://dify-1.internal/v1", "http://dify-2.internal.example.com/v1/workflows"},
+		APIKeys: []string{"app-1", "app-2"},
+		Weights: []string{"3"},
+		Timeout: time.Second,
+	})
+	if s == nil {
+		t.Fatal("NewDifyService returned nil")
+	}
+	s.MarkInstanceUnhealthy(s.GetInstances()[1], errors.New("connection refused"))
+
+	prev := dify.Service
+	dify.Service = s
+	t.Cleanup(func() { dify.Service = prev })
+}
+
+func getInstances(router *gin.Engine, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/admin/dify/instances", nil)
+	if token != "" {
+		req.Header.Set("X-Admin-Token", token)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func newDifyRouter(t *testing.T) *gin.Engine {
+	t.Helper()
+	testutil.SetConfig(t, "app.admin_token", "admin-secret")
+
+	router := gin.New()
+	router.GET("/admin/dify/instances", middlewares.AdminAuth(), NewDifyController().Instances)
+	return router
+}
+
+func TestDifyInstancesShape(t *testing.T) {
+	setDifyService(t)
+	router := newDifyRouter(t)
+
+	w := getInstances(router, "admin-secret")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", w.Code, w.Body.String())
+	}
+	var body struct {
+		Data struct {
+			Total     int                      `json:"total"`
+			Healthy   int                      `json:"healthy"`
+			Instances []map[string]interface{} `json:"instances"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	report := body.Data
+	if report.Total != 2 || report.Healthy != 1 || len(report.Instances) != 2 {
+		t.Fatalf("report = %+v, want 1 of 2 healthy", report)
+	}
+
+	for _, instance := range report.Instances {
+		for _, key := range []string{"url", "healthy", "error_count", "recent_requests", "weight"} {
+			if _, ok := instance[key]; !ok {
+				t.Fatalf("instance %v is missing %s", instance, key)
+			}
+		}
+	}
+	up, down := report.Instances[0], report.Instances[1]
+	if up["healthy"] != true || up["weight"] != float64(3) || up["last_error"] != nil {
+		t.Fatalf("healthy instance = %v", up)
+	}
+	if down["healthy"] != false || down["last_error"] != "connection refused" {
+		t.Fatalf("unhealthy instance = %v", down)
+	}
+	// 过长的地址只返回缩短后的形式
+	if url := down["url"].(string); url == "http://dify-2.internal.example.com/v1/workflows" || len(url) > 30 {
+		t.Fatalf("url = %q, want shortened", url)
+	}
+}
+
+func TestDifyInstancesRequiresAdmin(t *testing.T) {
+	setDifyService(t)
+	router := newDifyRouter(t)
+
+	for _, token := range []string{"", "wrong"} {
+		if w := getInstances(router, token); w.Code != http.StatusForbidden {
+			t.Fatalf("token %q: status = %d, want 403", token, w.Code)
+		}
+	}
+}
@@ -0,0 +1,25 @@
+package admin
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"tarot/app/repositories"
+	"tarot/pkg/response"
+)
+
+type FeedbackController struct{}
+
+func NewFeedbackController() *FeedbackController {
+	return &FeedbackController{}
+}
+
+// Summary 解读评价汇总：数量、平均分与评分分布
+// GET /v1/admin/feedback/summary
+func (fc *FeedbackController) Summary(c *gin.Context) {
+	summary, err := repositories.NewFeedbackRepository().Summary(c.Request.Context())
+	if err != nil {
+		response.Abort500(c, "获取评价汇总失败")
+		return
+	}
+	response.Data(c, summary)
+}
@@ -0,0 +1,46 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"tarot/app/models/feedback"
+	"tarot/app/repositories"
+	"tarot/pkg/database"
+	"tarot/pkg/testutil"
+)
+
+func TestFeedbackSummary(t *testing.T) {
+	testutil.SetupDB(t, &feedback.Feedback{})
+	for i, rating := range []int{5, 4, 4, 1} {
+		if err := database.DB.Create(&feedback.Feedback{ReadingID: uint64(i + 1), UserID: "user-1", Rating: rating}).Error; err != nil {
+			t.Fatalf("create feedback: %v", err)
+		}
+	}
+
+	router := gin.New()
+	router.GET("/feedback/summary", NewFeedbackController().Summary)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/feedback/summary", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", w.Code, w.Body.String())
+	}
+
+	var body struct {
+		Data repositories.FeedbackSummary `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	summary := body.Data
+	if summary.Count != 4 || summary.AverageRating != 3.5 {
+		t.Fatalf("summary = %+v, want 4 ratings averaging 3.5", summary)
+	}
+	if summary.Distribution[4] != 2 || summary.Distribution[2] != 0 || len(summary.Distribution) != 5 {
+		t.Fatalf("distribution = %v", summary.Distribution)
+	}
+}
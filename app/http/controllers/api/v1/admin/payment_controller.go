@@ -0,0 +1,75 @@
+package admin
+
+import (
+	"errors"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"tarot/app/repositories"
+	"tarot/pkg/response"
+)
+
+type PaymentController struct{}
+
+func NewPaymentController() *PaymentController {
+	return &PaymentController{}
+}
+
+// Events 查看订单的状态变化记录，用于处理支付纠纷
+// GET /v1/admin/payments/:order_no/events
+func (pc *PaymentController) Events(c *gin.Context) {
+	repo := repositories.NewPaymentRepository()
+	record, err := repo.GetByOrderNo(c.Request.Context(), c.Param("order_no"))
+	if err != nil {
+		response.Abort404(c, "订单不存在")
+		return
+	}
+
+	events, err := repo.ListEvents(c.Request.Context(), record.ID)
+	if err != nil {
+		response.Abort500(c, "获取订单记录失败")
+		return
+	}
+	response.Data(c, gin.H{
+		"order_no": record.OrderNo,
+		"status":   record.Status,
+		"events":   events,
+	})
+}
+
+// Destroy 软删除已退款、已取消或支付失败的订单，用于隐藏测试订单
+// DELETE /v1/admin/payments/:order_no
+func (pc *PaymentController) Destroy(c *gin.Context) {
+	repo := repositories.NewPaymentRepository()
+	record, err := repo.GetByOrderNo(c.Request.Context(), c.Param("order_no"))
+	if err != nil {
+		response.Abort404(c, "订单不存在")
+		return
+	}
+	if err := record.CheckDelete(); err != nil {
+		response.Abort400(c, "只能删除已退款、已取消或支付失败的订单")
+		return
+	}
+
+	if err := repo.SoftDelete(c.Request.Context(), record.OrderNo); err != nil {
+		response.Abort500(c, "删除订单失败")
+		return
+	}
+	response.Data(c, gin.H{"order_no": record.OrderNo})
+}
+
+// Restore 恢复已软删除的订单
+// POST /v1/admin/payments/:order_no/restore
+func (pc *PaymentController) Restore(c *gin.Context) {
+	record, err := repositories.NewPaymentRepository().Restore(c.Request.Context(), c.Param("order_no"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			response.Abort404(c, "订单不存在或未删除")
+			return
+		}
+		response.Abort500(c, "恢复订单失败")
+		return
+	}
+	response.Data(c, record)
+}
@@ -0,0 +1,110 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"tarot/app/models/payment"
+	"tarot/app/repositories"
+	"tarot/pkg/database"
+	"tarot/pkg/testutil"
+)
+
+// newPaymentRouter 创建订单管理接口路由，并创建一笔待支付订单 order-1
+func newPaymentRouter(t *testing.T) (*gin.Engine, *payment.Payment) {
+	t.Helper()
+	testutil.SetupDB(t, &payment.Payment{}, &payment.PaymentEvent{})
+
+	p := &payment.Payment{OrderNo: "order-1", UserID: "user-1", Provider: "wechat", Amount: 2000, Status: string(payment.StatusPending)}
+	if err := database.DB.Create(p).Error; err != nil {
+		t.Fatalf("create payment: %v", err)
+	}
+
+	pc := NewPaymentController()
+	router := gin.New()
+	router.GET("/payments/:order_no/events", pc.Events)
+	router.DELETE("/payments/:order_no", pc.Destroy)
+	router.POST("/payments/:order_no/restore", pc.Restore)
+	return router, p
+}
+
+// paymentRequest 请求 path 并解析 data 字段
+func paymentRequest(router *gin.Engine, method, path string) (int, map[string]interface{}) {
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+	var body struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &body)
+	return w.Code, body.Data
+}
+
+func TestPaymentEventsHistory(t *testing.T) {
+	router, p := newPaymentRouter(t)
+	repo := repositories.NewPaymentRepository()
+	ctx := payment.WithEventSource(context.Background(), payment.EventSourceQuery, nil)
+	for _, status := range []payment.Status{payment.StatusPaid, payment.StatusRefunded} {
+		p.Status = string(status)
+		if err := repo.Update(ctx, p); err != nil {
+			t.Fatalf("Update: %v", err)
+		}
+	}
+
+	code, data := paymentRequest(router, http.MethodGet, "/payments/order-1/events")
+	events, _ := data["events"].([]interface{})
+	if code != http.StatusOK || data["status"] != string(payment.StatusRefunded) || len(events) != 2 {
+		t.Fatalf("status=%d data=%v, want two events", code, data)
+	}
+	for i, want := range [][2]payment.Status{{payment.StatusPending, payment.StatusPaid}, {payment.StatusPaid, payment.StatusRefunded}} {
+		e := events[i].(map[string]interface{})
+		if e["from_status"] != string(want[0]) || e["to_status"] != string(want[1]) || e["source"] != string(payment.EventSourceQuery) {
+			t.Fatalf("event %d = %v, want %s → %s", i, e, want[0], want[1])
+		}
+	}
+
+	if code, _ := paymentRequest(router, http.MethodGet, "/payments/missing/events"); code != http.StatusNotFound {
+		t.Fatalf("missing order status = %d, want 404", code)
+	}
+}
+
+func TestPaymentDestroyAndRestore(t *testing.T) {
+	router, p := newPaymentRouter(t)
+
+	// 待支付订单仍可能到账，不允许删除
+	if code, _ := paymentRequest(router, http.MethodDelete, "/payments/order-1"); code != http.StatusBadRequest {
+		t.Fatalf("delete pending payment status = %d, want 400", code)
+	}
+
+	p.Status = string(payment.StatusCanceled)
+	if err := repositories.NewPaymentRepository().Update(context.Background(), p); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if code, data := paymentRequest(router, http.MethodDelete, "/payments/order-1"); code != http.StatusOK || data["order_no"] != "order-1" {
+		t.Fatalf("delete status=%d data=%v", code, data)
+	}
+	if code, _ := paymentRequest(router, http.MethodGet, "/payments/order-1/events"); code != http.StatusNotFound {
+		t.Fatalf("events of a deleted payment status = %d, want 404", code)
+	}
+	if code, _ := paymentRequest(router, http.MethodDelete, "/payments/order-1"); code != http.StatusNotFound {
+		t.Fatalf("second delete status = %d, want 404", code)
+	}
+
+	code, data := paymentRequest(router, http.MethodPost, "/payments/order-1/restore")
+	if code != http.StatusOK || data["order_no"] != "order-1" || data["status"] != string(payment.StatusCanceled) {
+		t.Fatalf("restore status=%d data=%v", code, data)
+	}
+	if code, _ := paymentRequest(router, http.MethodGet, "/payments/order-1/events"); code != http.StatusOK {
+		t.Fatalf("events after restore status = %d, want 200", code)
+	}
+	if code, _ := paymentRequest(router, http.MethodPost, "/payments/order-1/restore"); code != http.StatusNotFound {
+		t.Fatalf("restore of a payment that is not deleted status = %d, want 404", code)
+	}
+	if code, _ := paymentRequest(router, http.MethodPost, "/payments/missing/restore"); code != http.StatusNotFound {
+		t.Fatalf("restore of a missing payment status = %d, want 404", code)
+	}
+}
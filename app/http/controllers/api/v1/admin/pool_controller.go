@@ -0,0 +1,32 @@
+package admin
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"tarot/pkg/database"
+	"tarot/pkg/redis"
+	"tarot/pkg/response"
+)
+
+type PoolController struct{}
+
+func NewPoolController() *PoolController {
+	return &PoolController{}
+}
+
+// Show 数据库与各 Redis 实例的连接池统计，每次请求时实时读取
+// GET /v1/admin/pools
+func (pc *PoolController) Show(c *gin.Context) {
+	var redisStats map[redis.RedisInstance]redis.PoolStats
+	if redis.Manager != nil {
+		redisStats = redis.Manager.PoolStats()
+	}
+
+	response.Data(c, gin.H{
+		"database": database.Stats(),
+		"redis":    redisStats,
+		"time":     time.Now().Unix(),
+	})
+}
@@ -0,0 +1,80 @@
+package admin
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"tarot/pkg/database"
+	"tarot/pkg/redis"
+	"tarot/pkg/testutil"
+)
+
+func newPoolRouter() *gin.Engine {
+	router := gin.New()
+	router.GET("/pools", NewPoolController().Show)
+	return router
+}
+
+// assertNumeric 检查 m 中的字段都存在且为数值
+func assertNumeric(t *testing.T, name string, m map[string]interface{}, keys ...string) {
+	t.Helper()
+	for _, key := range keys {
+		if _, ok := m[key].(float64); !ok {
+			t.Errorf("%s.%s = %v, want a number", name, key, m[key])
+		}
+	}
+}
+
+func TestPoolStatsArePresentAndNumeric(t *testing.T) {
+	testutil.SetupDB(t)
+	testutil.SetupRedis(t)
+	// 产生一次数据库与 Redis 连接，统计中才有非零的连接数
+	if err := database.DB.Exec("SELECT 1").Error; err != nil {
+		t.Fatalf("query: %v", err)
+	}
+	if err := redis.GetRedis(redis.MainDB).Client.Ping(context.Background()).Err(); err != nil {
+		t.Fatalf("ping: %v", err)
+	}
+
+	code, data := paymentRequest(newPoolRouter(), http.MethodGet, "/pools")
+	if code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", code)
+	}
+
+	db := section(t, data, "database")
+	assertNumeric(t, "database", db, "max_open", "open", "in_use", "idle", "wait_count", "wait_duration_ms")
+	if db["open"] != float64(1) {
+		t.Errorf("database.open = %v, want 1", db["open"])
+	}
+
+	pools := section(t, data, "redis")
+	for _, instance := range []redis.RedisInstance{redis.MainDB, redis.QueueDB, redis.CacheDB} {
+		stats := section(t, pools, string(instance))
+		assertNumeric(t, "redis."+string(instance), stats, "hits", "misses", "timeouts", "total_conns", "idle_conns", "stale_conns")
+	}
+	if main := section(t, pools, string(redis.MainDB)); main["total_conns"].(float64) < 1 {
+		t.Errorf("redis.main.total_conns = %v, want at least 1", main["total_conns"])
+	}
+	if _, ok := data["time"].(float64); !ok {
+		t.Errorf("time = %v, want a timestamp", data["time"])
+	}
+}
+
+func TestPoolStatsWithoutConnections(t *testing.T) {
+	testutil.SetupDB(t)
+	prevSQL, prevManager := database.SQLDB, redis.Manager
+	database.SQLDB, redis.Manager = nil, nil
+	t.Cleanup(func() { database.SQLDB, redis.Manager = prevSQL, prevManager })
+
+	code, data := paymentRequest(newPoolRouter(), http.MethodGet, "/pools")
+	if code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", code)
+	}
+	assertCounts(t, "database", section(t, data, "database"), map[string]float64{"open": 0, "in_use": 0, "idle": 0})
+	if data["redis"] != nil {
+		t.Errorf("redis = %v, want null without a manager", data["redis"])
+	}
+}
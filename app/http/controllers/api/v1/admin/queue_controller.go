@@ -0,0 +1,115 @@
+package admin
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+
+	"tarot/pkg/queue"
+	"tarot/pkg/response"
+)
+
+// QueueController 队列管理，通过 ?queue=name 指定命名队列，默认为 default
+type QueueController struct{}
+
+func NewQueueController() *QueueController {
+	return &QueueController{}
+}
+
+// Show 队列状态：是否暂停、待处理任务数与工作器运行情况
+// GET /v1/admin/queue
+func (qc *QueueController) Show(c *gin.Context) {
+	worker, ok := qc.workers(c)
+	if !ok {
+		return
+	}
+	qc.respondStatus(c, worker)
+}
+
+// Pause 暂停获取新任务，进行中的任务继续完成，新任务保留在队列中
+// POST /v1/admin/queue/pause
+func (qc *QueueController) Pause(c *gin.Context) {
+	worker, ok := qc.workers(c)
+	if !ok {
+		return
+	}
+	worker.Pause()
+	qc.respondStatus(c, worker)
+}
+
+// Resume 恢复获取任务
+// POST /v1/admin/queue/resume
+func (qc *QueueController) Resume(c *gin.Context) {
+	worker, ok := qc.workers(c)
+	if !ok {
+		return
+	}
+	worker.Resume()
+	qc.respondStatus(c, worker)
+}
+
+// resizeRequest 调整工作器数量的请求
+type resizeRequest struct {
+	Count int `json:"count"`
+}
+
+// Resize 调整运行中的工作器数量，减少时被停止的工作器会先完成当前任务
+// PUT /v1/admin/queue/workers
+func (qc *QueueController) Resize(c *gin.Context) {
+	worker, ok := qc.workers(c)
+	if !ok {
+		return
+	}
+
+	var req resizeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, err, "请求参数错误")
+		return
+	}
+	if err := worker.Resize(req.Count); err != nil {
+		if errors.Is(err, queue.ErrInvalidWorkerCount) {
+			response.Abort400(c, fmt.Sprintf("工作器数量必须在 1 到 %d 之间", queue.MaxWorkerCount))
+			return
+		}
+		response.Abort500(c, "调整工作器数量失败")
+		return
+	}
+	qc.respondStatus(c, worker)
+}
+
+// workers 查找请求指定队列的工作器组，不存在时写入响应并返回 false
+func (qc *QueueController) workers(c *gin.Context) (*queue.Worker, bool) {
+	name := c.DefaultQuery("queue", queue.DefaultQueue)
+	worker, ok := queue.WorkerGroups[name]
+	if !ok {
+		if name == queue.DefaultQueue {
+			response.Abort500(c, "队列工作器未启动")
+		} else {
+			response.Abort404(c, "队列不存在")
+		}
+		return nil, false
+	}
+	return worker, true
+}
+
+func (qc *QueueController) respondStatus(c *gin.Context, worker *queue.Worker) {
+	length, err := worker.Queue().Length(c.Request.Context())
+	if err != nil {
+		response.Abort500(c, "获取队列长度失败")
+		return
+	}
+	alive, err := worker.Queue().ActiveWorkers(c.Request.Context())
+	if err != nil {
+		response.Abort500(c, "获取存活工作器失败")
+		return
+	}
+	response.Data(c, gin.H{
+		"queue":   worker.Queue().Name(),
+		"paused":  worker.Paused(),
+		"pending": length,
+		"workers": worker.Stats(),
+		// 所有实例中心跳未过期的工作器
+		"alive_workers": alive,
+	})
+}
@@ -0,0 +1,109 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"tarot/pkg/queue"
+	"tarot/pkg/testutil"
+)
+
+// newQueueRouter 注册一个未启动的默认队列工作器组，测试结束后移除
+func newQueueRouter(t *testing.T) (*gin.Engine, *queue.Worker) {
+	t.Helper()
+	testutil.SetupRedis(t)
+
+	worker := queue.NewWorker(queue.NewQueueService(), nil, queue.WorkerConfig{WorkerCount: 1})
+	prev, had := queue.WorkerGroups[queue.DefaultQueue]
+	queue.WorkerGroups[queue.DefaultQueue] = worker
+	t.Cleanup(func() {
+		if had {
+			queue.WorkerGroups[queue.DefaultQueue] = prev
+		} else {
+			delete(queue.WorkerGroups, queue.DefaultQueue)
+		}
+	})
+
+	qc := NewQueueController()
+	router := gin.New()
+	router.GET("/queue", qc.Show)
+	router.POST("/queue/pause", qc.Pause)
+	router.POST("/queue/resume", qc.Resume)
+	router.PUT("/queue/workers", qc.Resize)
+	return router, worker
+}
+
+// queueStatus 请求 path 并解析 data 字段
+func queueStatus(t *testing.T, router *gin.Engine, method, path string) (int, map[string]interface{}) {
+	t.Helper()
+	return queueRequest(t, router, method, path, "")
+}
+
+// queueRequest 以 JSON body 请求 path 并解析 data 字段
+func queueRequest(t *testing.T, router *gin.Engine, method, path, payload string) (int, map[string]interface{}) {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	var body struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &body)
+	return w.Code, body.Data
+}
+
+func TestQueuePauseAndResume(t *testing.T) {
+	router, worker := newQueueRouter(t)
+	if err := worker.Queue().PushTask(context.Background(), &queue.TarotTask{ID: "task-1", UserID: "user-1", Question: "我最近的事业运势如何？", Type: "free", Cards: []int{1}}); err != nil {
+		t.Fatalf("PushTask: %v", err)
+	}
+
+	code, data := queueStatus(t, router, http.MethodPost, "/queue/pause")
+	if code != http.StatusOK || data["paused"] != true || data["pending"] != float64(1) || !worker.Paused() {
+		t.Fatalf("pause = %d %v", code, data)
+	}
+	if _, data := queueStatus(t, router, http.MethodGet, "/queue"); data["paused"] != true {
+		t.Fatalf("status after pause = %v", data)
+	}
+
+	code, data = queueStatus(t, router, http.MethodPost, "/queue/resume")
+	if code != http.StatusOK || data["paused"] != false || worker.Paused() {
+		t.Fatalf("resume = %d %v", code, data)
+	}
+}
+
+func TestQueueControlUnknownQueue(t *testing.T) {
+	router, _ := newQueueRouter(t)
+
+	if code, _ := queueStatus(t, router, http.MethodPost, "/queue/pause?queue=missing"); code != http.StatusNotFound {
+		t.Fatalf("status = %d, want 404", code)
+	}
+}
+
+func TestQueueResizeWorkers(t *testing.T) {
+	router, worker := newQueueRouter(t)
+	worker.Start()
+	t.Cleanup(worker.Stop)
+
+	code, data := queueRequest(t, router, http.MethodPut, "/queue/workers", `{"count":3}`)
+	workers, _ := data["workers"].(map[string]interface{})
+	if code != http.StatusOK || workers["worker_count"] != float64(3) || worker.WorkerCount() != 3 {
+		t.Fatalf("resize = %d %v", code, data)
+	}
+
+	for _, body := range []string{`{"count":0}`, `{"count":1000}`, `{"count":"many"}`} {
+		if code, _ := queueRequest(t, router, http.MethodPut, "/queue/workers", body); code != http.StatusBadRequest {
+			t.Fatalf("resize %s = %d, want 400", body, code)
+		}
+	}
+	if got := worker.WorkerCount(); got != 3 {
+		t.Fatalf("worker count after rejected resizes = %d, want 3", got)
+	}
+}
@@ -0,0 +1,166 @@
+package admin
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"tarot/app/models/spread"
+	"tarot/app/repositories"
+	"tarot/pkg/logger"
+	"tarot/pkg/response"
+)
+
+type SpreadController struct {
+	repo *repositories.SpreadRepository
+}
+
+func NewSpreadController() *SpreadController {
+	return &SpreadController{
+		repo: repositories.NewSpreadRepository(),
+	}
+}
+
+// spreadRequest 创建/更新牌阵参数
+type spreadRequest struct {
+	Slug        string   `json:"slug" binding:"required,max=64"`
+	Name        string   `json:"name" binding:"required,max=100"`
+	Description string   `json:"description"`
+	CardCount   int      `json:"card_count" binding:"required,min=1"`
+	Positions   []string `json:"positions" binding:"required"`
+}
+
+// Index 牌阵列表
+func (sc *SpreadController) Index(c *gin.Context) {
+	spreads, err := sc.repo.List(c.Request.Context())
+	if err != nil {
+		response.Abort500(c, "获取牌阵失败")
+		return
+	}
+	response.Data(c, spreads)
+}
+
+// Show 牌阵详情
+func (sc *SpreadController) Show(c *gin.Context) {
+	s, ok := sc.find(c)
+	if !ok {
+		return
+	}
+	response.Data(c, s)
+}
+
+// Store 创建牌阵
+func (sc *SpreadController) Store(c *gin.Context) {
+	var req spreadRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, err, "请求参数错误")
+		return
+	}
+
+	s := &spread.Spread{}
+	req.fill(s)
+	if err := s.Validate(); err != nil {
+		response.BadRequest(c, err, "牌阵定义无效")
+		return
+	}
+
+	if err := sc.repo.Create(c.Request.Context(), s); err != nil {
+		response.Abort500(c, "创建牌阵失败")
+		return
+	}
+
+	sc.reload()
+	response.Created(c, s, "牌阵创建成功")
+}
+
+// Update 更新牌阵
+func (sc *SpreadController) Update(c *gin.Context) {
+	s, ok := sc.find(c)
+	if !ok {
+		return
+	}
+
+	var req spreadRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, err, "请求参数错误")
+		return
+	}
+
+	req.fill(s)
+	if err := s.Validate(); err != nil {
+		response.BadRequest(c, err, "牌阵定义无效")
+		return
+	}
+
+	if err := sc.repo.Update(c.Request.Context(), s); err != nil {
+		response.Abort500(c, "更新牌阵失败")
+		return
+	}
+
+	sc.reload()
+	response.Data(c, s)
+}
+
+// Destroy 删除牌阵
+func (sc *SpreadController) Destroy(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		response.Abort400(c, "无效的牌阵 ID")
+		return
+	}
+
+	if err := sc.repo.Delete(c.Request.Context(), id); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			response.Abort404(c, "牌阵不存在")
+			return
+		}
+		response.Abort500(c, "删除牌阵失败")
+		return
+	}
+
+	sc.reload()
+	response.Data(c, gin.H{"id": id})
+}
+
+// Reload 重新加载当前实例的牌阵缓存（例如直接修改数据库后），其他实例在 tarot.spread_cache_ttl 内自动刷新
+func (sc *SpreadController) Reload(c *gin.Context) {
+	if err := spread.Reload(); err != nil {
+		response.Abort500(c, "重新加载牌阵失败")
+		return
+	}
+	response.Data(c, gin.H{"reloaded": true})
+}
+
+// find 根据路由参数获取牌阵，失败时已写入响应
+func (sc *SpreadController) find(c *gin.Context) (*spread.Spread, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		response.Abort400(c, "无效的牌阵 ID")
+		return nil, false
+	}
+
+	s, err := sc.repo.GetByID(c.Request.Context(), id)
+	if err != nil {
+		response.Abort404(c, "牌阵不存在")
+		return nil, false
+	}
+	return s, true
+}
+
+// reload 数据变更后刷新缓存，失败时仅记录日志，下次变更或手动刷新时恢复
+func (sc *SpreadController) reload() {
+	if err := spread.Reload(); err != nil {
+		logger.ErrorString("Spread", "Reload", err.Error())
+	}
+}
+
+// fill 将请求参数写入模型
+func (req spreadRequest) fill(s *spread.Spread) {
+	s.Slug = req.Slug
+	s.Name = req.Name
+	s.Description = req.Description
+	s.CardCount = req.CardCount
+	s.Positions = spread.Positions(req.Positions)
+}
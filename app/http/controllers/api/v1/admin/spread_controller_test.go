@@ -0,0 +1,71 @@
+package admin
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"tarot/app/models/spread"
+	"tarot/pkg/testutil"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+func newSpreadRouter(t *testing.T) *gin.Engine {
+	t.Helper()
+	testutil.SetupDB(t, &spread.Spread{})
+
+	sc := NewSpreadController()
+	router := gin.New()
+	router.POST("/spreads", sc.Store)
+	return router
+}
+
+func sendSpread(router *gin.Engine, method, path string, params map[string]interface{}) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(params)
+	req := httptest.NewRequest(method, path, bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestStoreSpreadRefreshesCache(t *testing.T) {
+	router := newSpreadRouter(t)
+
+	w := sendSpread(router, http.MethodPost, "/spreads", map[string]interface{}{
+		"slug": "three-card", "name": "三牌阵", "card_count": 3, "positions": []string{"过去", "现在", "未来"},
+	})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("status = %d, want 201: %s", w.Code, w.Body.String())
+	}
+	var body struct {
+		Data spread.Spread `json:"data"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &body)
+
+	s, ok := spread.Find(body.Data.ID)
+	if !ok || s.CardCount != 3 || s.PromptText() != "三牌阵：1.过去 2.现在 3.未来" {
+		t.Fatalf("cached spread = %+v, %v", s, ok)
+	}
+}
+
+func TestStoreSpreadRejectsMismatchedPositions(t *testing.T) {
+	router := newSpreadRouter(t)
+
+	for _, params := range []map[string]interface{}{
+		{"slug": "two", "name": "二牌阵", "card_count": 3, "positions": []string{"过去", "现在"}},
+		{"slug": "many", "name": "大牌阵", "card_count": spread.MaxCardCount + 1, "positions": make([]string, spread.MaxCardCount+1)},
+		{"name": "无标识", "card_count": 1, "positions": []string{"现在"}},
+	} {
+		if w := sendSpread(router, http.MethodPost, "/spreads", params); w.Code != http.StatusBadRequest {
+			t.Fatalf("params %v: status = %d, want 400", params, w.Code)
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package admin
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"tarot/app/models/reading"
+	"tarot/app/repositories"
+	"tarot/pkg/dify"
+	"tarot/pkg/logger"
+	"tarot/pkg/queue"
+	"tarot/pkg/response"
+)
+
+const (
+	// statsWindow 解读与支付统计的时间范围
+	statsWindow = 24 * time.Hour
+	// statsCacheTTL 统计结果的缓存时间，避免频繁刷新时反复查询数据库
+	statsCacheTTL = 30 * time.Second
+	// statusBatchSize 批量查询任务状态时每次的数量
+	statusBatchSize = 500
+)
+
+// ReadingStats 最近 statsWindow 内创建的解读数量
+type ReadingStats struct {
+	Total      int64 `json:"total"`
+	Completed  int64 `json:"completed"` // 含 Dify 不可用时的兜底结果
+	Failed     int64 `json:"failed"`
+	Pending    int64 `json:"pending"`
+	Processing int64 `json:"processing"`
+}
+
+// QueueStats 所有队列的积压与处理情况
+type QueueStats struct {
+	Pending      int64 `json:"pending"`
+	InFlight     int64 `json:"in_flight"`
+	AvgProcessMs int64 `json:"avg_process_ms"` // 按各队列已处理任务数加权
+}
+
+// DifyStats Dify 实例健康情况
+type DifyStats struct {
+	Total   int `json:"total"`
+	Healthy int `json:"healthy"`
+}
+
+// Stats 管理端系统概览
+type Stats struct {
+	Readings    ReadingStats                `json:"readings"`
+	Queue       QueueStats                  `json:"queue"`
+	Dify        DifyStats                   `json:"dify"`
+	Payments    *repositories.PaymentTotals `json:"payments"`
+	WindowHours int                         `json:"window_hours"`
+	GeneratedAt time.Time                   `json:"generated_at"`
+}
+
+type StatsController struct {
+	mu       sync.Mutex
+	cached   *Stats
+	cachedAt time.Time
+}
+
+func NewStatsController() *StatsController {
+	return &StatsController{}
+}
+
+// Show 系统概览：最近 24 小时的解读与支付、队列积压、平均处理耗时与 Dify 健康实例数
+// GET /v1/admin/stats
+// 结果缓存 statsCacheTTL，generated_at 为统计时间
+func (sc *StatsController) Show(c *gin.Context) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	if sc.cached != nil && time.Since(sc.cachedAt) < statsCacheTTL {
+		response.Data(c, sc.cached)
+		return
+	}
+
+	stats, err := collectStats(c.Request.Context(), time.Now())
+	if err != nil {
+		logger.ErrorStringContext(c.Request.Context(), "Admin", "Stats", err.Error())
+		response.Abort500(c, "获取统计数据失败")
+		return
+	}
+
+	sc.cached = stats
+	sc.cachedAt = time.Now()
+	response.Data(c, stats)
+}
+
+// collectStats 汇总各项统计
+func collectStats(ctx context.Context, now time.Time) (*Stats, error) {
+	since := now.Add(-statsWindow)
+
+	readings, err := readingStats(ctx, since)
+	if err != nil {
+		return nil, err
+	}
+
+	payments, err := repositories.NewPaymentRepository().TotalsSince(ctx, since)
+	if err != nil {
+		return nil, err
+	}
+
+	queueStats, err := queueStats(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var difyStats DifyStats
+	if dify.Service != nil {
+		difyStats.Total = len(dify.Service.GetInstances())
+		difyStats.Healthy = dify.Service.GetHealthyInstanceCount()
+	}
+
+	return &Stats{
+		Readings:    *readings,
+		Queue:       *queueStats,
+		Dify:        difyStats,
+		Payments:    payments,
+		WindowHours: int(statsWindow / time.Hour),
+		GeneratedAt: now,
+	}, nil
+}
+
+// readingStats 统计解读数量
+// 记录中仍未结束的解读（处理中，或在工作器写入结果之前创建）按 Redis 中的状态计入
+func readingStats(ctx context.Context, since time.Time) (*ReadingStats, error) {
+	repo := repositories.NewReadingRepository()
+	counts, err := repo.CountByStatusSince(ctx, since)
+	if err != nil {
+		return nil, err
+	}
+	unfinished, err := repo.UnfinishedTaskIDsSince(ctx, since)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &ReadingStats{
+		Completed: counts[string(reading.StatusCompleted)],
+		Failed:    counts[string(reading.StatusFailed)],
+	}
+	for _, count := range counts {
+		stats.Total += count
+	}
+
+	qs := queue.NewQueueService()
+	for start := 0; start < len(unfinished); start += statusBatchSize {
+		batch := unfinished[start:min(start+statusBatchSize, len(unfinished))]
+		statuses, err := qs.GetTaskStatuses(ctx, batch)
+		if err != nil {
+			return nil, err
+		}
+		for _, taskID := range batch {
+			switch statuses[taskID] {
+			case queue.TaskCompleted, queue.TaskFallback:
+				stats.Completed++
+			case queue.TaskFailed:
+				stats.Failed++
+			case queue.TaskRunning:
+				stats.Processing++
+			default:
+				stats.Pending++
+			}
+		}
+	}
+	return stats, nil
+}
+
+// queueStats 汇总所有队列的积压与平均处理耗时
+func queueStats(ctx context.Context) (*QueueStats, error) {
+	stats := &QueueStats{}
+	if len(queue.WorkerGroups) == 0 {
+		length, err := queue.NewQueueService().Length(ctx)
+		if err != nil {
+			return nil, err
+		}
+		stats.Pending = length
+		return stats, nil
+	}
+
+	var weighted, processed int64
+	for _, worker := range queue.WorkerGroups {
+		length, err := worker.Queue().Length(ctx)
+		if err != nil {
+			return nil, err
+		}
+		ws := worker.Stats()
+		stats.Pending += length
+		stats.InFlight += ws.InFlight
+		weighted += ws.AvgProcessMs * ws.Processed
+		processed += ws.Processed
+	}
+	if processed > 0 {
+		stats.AvgProcessMs = weighted / processed
+	}
+	return stats, nil
+}
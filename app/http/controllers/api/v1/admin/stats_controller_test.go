@@ -0,0 +1,167 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"tarot/app/models/payment"
+	"tarot/app/models/reading"
+	"tarot/pkg/database"
+	"tarot/pkg/dify"
+	"tarot/pkg/queue"
+	"tarot/pkg/testutil"
+)
+
+// newStatsRouter 创建统计接口路由，Dify 有两个实例，其中一个不健康
+func newStatsRouter(t *testing.T) *gin.Engine {
+	t.Helper()
+	testutil.SetupDB(t, &reading.Reading{}, &payment.Payment{})
+	testutil.SetupRedis(t)
+
+	prevGroups := queue.WorkerGroups
+	queue.WorkerGroups = map[string]*queue.Worker{}
+	t.Cleanup(func() { queue.WorkerGroups = prevGroups })
+
+	s := dify.NewDifyService(&dify.Config{URLs: []string{"http://dify-1", "http://dify-2"}, APIKeys: []string{"app-1", "app-2"}, Timeout: time.Second})
+	if s == nil {
+		t.Fatal("NewDifyService returned nil")
+	}
+	s.MarkInstanceUnhealthy(s.GetInstances()[1], errors.New("connection refused"))
+	prevDify := dify.Service
+	dify.Service = s
+	t.Cleanup(func() { dify.Service = prevDify })
+
+	router := gin.New()
+	router.GET("/stats", NewStatsController().Show)
+	return router
+}
+
+// seedReading 创建解读记录，taskStatus 非空时同时写入 Redis 中的任务状态
+func seedReading(t *testing.T, taskID string, status reading.Status, taskStatus queue.TaskStatus, createdAt time.Time) {
+	t.Helper()
+	r := &reading.Reading{TaskID: taskID, UserID: "user-1", Question: "我最近的事业运势如何？", Type: reading.TypeFree, Status: string(status), Cards: reading.Cards{1}}
+	r.CreatedAt = createdAt
+	if err := database.DB.Create(r).Error; err != nil {
+		t.Fatalf("create reading: %v", err)
+	}
+	if taskStatus != "" {
+		if err := queue.NewQueueService().UpdateTaskStatus(context.Background(), taskID, taskStatus, ""); err != nil {
+			t.Fatalf("UpdateTaskStatus: %v", err)
+		}
+	}
+}
+
+func seedPayment(t *testing.T, orderNo string, status payment.Status, amount, refunded int64, payAt *time.Time) {
+	t.Helper()
+	p := &payment.Payment{OrderNo: orderNo, UserID: "user-1", Provider: "wechat", Amount: amount, RefundedAmount: refunded, Status: string(status), PayAt: payAt}
+	if err := database.DB.Create(p).Error; err != nil {
+		t.Fatalf("create payment: %v", err)
+	}
+}
+
+// section 取出 data 中的某个对象
+func section(t *testing.T, data map[string]interface{}, name string) map[string]interface{} {
+	t.Helper()
+	m, ok := data[name].(map[string]interface{})
+	if !ok {
+		t.Fatalf("data.%s = %v, want an object", name, data[name])
+	}
+	return m
+}
+
+// assertCounts 检查 m 中各字段的数值
+func assertCounts(t *testing.T, name string, m map[string]interface{}, want map[string]float64) {
+	t.Helper()
+	for key, value := range want {
+		if got, ok := m[key]; !ok || got != value {
+			t.Errorf("%s.%s = %v, want %v", name, key, got, value)
+		}
+	}
+}
+
+func TestStatsReflectSeededData(t *testing.T) {
+	router := newStatsRouter(t)
+	now := time.Now()
+	old := now.Add(-48 * time.Hour)
+
+	// 数据库中已结束的解读
+	seedReading(t, "task-completed-1", reading.StatusCompleted, "", now)
+	seedReading(t, "task-completed-2", reading.StatusCompleted, "", now)
+	seedReading(t, "task-failed", reading.StatusFailed, "", now)
+	// 记录仍为 pending，实际状态在 Redis 中
+	seedReading(t, "task-done-in-redis", reading.StatusPending, queue.TaskCompleted, now)
+	seedReading(t, "task-fallback-in-redis", reading.StatusPending, queue.TaskFallback, now)
+	seedReading(t, "task-failed-in-redis", reading.StatusPending, queue.TaskFailed, now)
+	seedReading(t, "task-running", reading.StatusPending, queue.TaskRunning, now)
+	seedReading(t, "task-pending", reading.StatusPending, "", now)
+	// 超出统计范围
+	seedReading(t, "task-old", reading.StatusCompleted, "", old)
+
+	if err := queue.NewQueueService().PushTask(context.Background(), &queue.TarotTask{ID: "task-pending", UserID: "user-1", Question: "我最近的事业运势如何？", Type: "free", Cards: []int{1}}); err != nil {
+		t.Fatalf("PushTask: %v", err)
+	}
+
+	seedPayment(t, "order-paid", payment.StatusPaid, 990, 0, &now)
+	seedPayment(t, "order-refunded", payment.StatusRefunded, 1990, 1990, &now)
+	seedPayment(t, "order-pending", payment.StatusPending, 2990, 0, nil)
+	seedPayment(t, "order-old", payment.StatusPaid, 3990, 0, &old)
+
+	code, data := paymentRequest(router, http.MethodGet, "/stats")
+	if code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", code)
+	}
+
+	assertCounts(t, "readings", section(t, data, "readings"), map[string]float64{
+		"total": 8, "completed": 4, "failed": 2, "processing": 1, "pending": 1,
+	})
+	assertCounts(t, "queue", section(t, data, "queue"), map[string]float64{
+		"pending": 1, "in_flight": 0, "avg_process_ms": 0,
+	})
+	assertCounts(t, "dify", section(t, data, "dify"), map[string]float64{
+		"total": 2, "healthy": 1,
+	})
+	assertCounts(t, "payments", section(t, data, "payments"), map[string]float64{
+		"paid_count": 2, "paid_amount": 2980, "refunded_amount": 1990,
+	})
+	if data["window_hours"] != float64(24) {
+		t.Errorf("window_hours = %v, want 24", data["window_hours"])
+	}
+	if generated, _ := data["generated_at"].(string); generated == "" {
+		t.Errorf("generated_at = %v, want a timestamp", data["generated_at"])
+	}
+}
+
+func TestStatsAreCachedBriefly(t *testing.T) {
+	router := newStatsRouter(t)
+	seedReading(t, "task-1", reading.StatusCompleted, "", time.Now())
+
+	_, first := paymentRequest(router, http.MethodGet, "/stats")
+	for i := 0; i < 3; i++ {
+		seedReading(t, fmt.Sprintf("task-new-%d", i), reading.StatusCompleted, "", time.Now())
+	}
+	_, second := paymentRequest(router, http.MethodGet, "/stats")
+
+	if total := section(t, second, "readings")["total"]; total != float64(1) {
+		t.Fatalf("cached total = %v, want 1 until the cache expires", total)
+	}
+	if first["generated_at"] != second["generated_at"] {
+		t.Fatalf("generated_at changed from %v to %v within the cache ttl", first["generated_at"], second["generated_at"])
+	}
+}
+
+func TestStatsWithoutDify(t *testing.T) {
+	router := newStatsRouter(t)
+	dify.Service = nil
+
+	code, data := paymentRequest(router, http.MethodGet, "/stats")
+	if code != http.StatusOK {
+		t.Fatalf("status = %d, want 200 without Dify", code)
+	}
+	assertCounts(t, "dify", section(t, data, "dify"), map[string]float64{"total": 0, "healthy": 0})
+}
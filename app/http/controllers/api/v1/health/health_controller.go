@@ -0,0 +1,205 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"tarot/pkg/database"
+	"tarot/pkg/dify"
+	"tarot/pkg/queue"
+	"tarot/pkg/redis"
+	"tarot/pkg/response"
+)
+
+// CheckTimeout 单次就绪检查的超时时间
+const CheckTimeout = 2 * time.Second
+
+// difyDegradedRatio 健康的 Dify 实例占比低于该值时就绪检查记为 degraded
+const difyDegradedRatio = 0.5
+
+// 检查结果状态，degraded 表示依赖部分可用，仍视为就绪
+const (
+	StatusUp       = "up"
+	StatusDegraded = "degraded"
+	StatusDown     = "down"
+)
+
+// degradedError 依赖部分可用，检查返回该错误时结果记为 degraded
+type degradedError struct {
+	err error
+}
+
+func (e *degradedError) Error() string { return e.err.Error() }
+
+func (e *degradedError) Unwrap() error { return e.err }
+
+// Degraded 将错误标记为部分可用
+func Degraded(err error) error {
+	return &degradedError{err: err}
+}
+
+// Check 依赖检查函数
+type Check func(ctx context.Context) error
+
+// CheckResult 单个依赖的检查结果
+type CheckResult struct {
+	Status  string  `json:"status"` // up / degraded / down
+	Error   string  `json:"error,omitempty"`
+	Latency float64 `json:"latency_ms"`
+}
+
+type HealthController struct {
+	checks map[string]Check
+}
+
+// NewHealthController 创建健康检查控制器，注册数据库、Redis、队列与 Dify 的就绪检查
+func NewHealthController() *HealthController {
+	return &HealthController{
+		checks: Checks(),
+	}
+}
+
+// Checks 就绪检查使用的依赖检查，启动前的就绪等待与 /readyz 共用
+func Checks() map[string]Check {
+	queueService := queue.NewQueueService()
+	difyService := dify.Service
+
+	return map[string]Check{
+		"database":    checkDatabase,
+		"redis_main":  checkRedis(redis.MainDB),
+		"redis_queue": checkRedis(redis.QueueDB),
+		"redis_cache": checkRedis(redis.CacheDB),
+		"queue":       queueService.Ping,
+		"dify":        checkDify(difyService),
+	}
+}
+
+// Liveness 存活探针，只说明进程能够处理请求，不检查依赖
+// GET /healthz
+func (hc *HealthController) Liveness(c *gin.Context) {
+	response.Data(c, gin.H{
+		"status": "ok",
+		"time":   time.Now().Unix(),
+	})
+}
+
+// Readiness 就绪探针，并发检查所有依赖，任一不可用时返回 503
+// 部分可用（如半数以上 Dify 实例故障）时仍返回 200，status 为 degraded
+// GET /readyz
+func (hc *HealthController) Readiness(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), CheckTimeout)
+	defer cancel()
+
+	results := RunChecks(ctx, hc.checks)
+
+	status := StatusUp
+	for _, result := range results {
+		if result.Status == StatusDown {
+			status = StatusDown
+			break
+		}
+		if result.Status == StatusDegraded {
+			status = StatusDegraded
+		}
+	}
+
+	data := gin.H{
+		"status": status,
+		"checks": results,
+		"time":   time.Now().Unix(),
+	}
+	if status == StatusDown {
+		response.FailWithData(c, http.StatusServiceUnavailable, response.CodeServiceUnavailable, "依赖服务不可用", data)
+		return
+	}
+
+	response.Data(c, data)
+}
+
+// RunChecks 并发执行检查，超时未返回的检查记为 down
+func RunChecks(ctx context.Context, checks map[string]Check) map[string]CheckResult {
+	var (
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		results = make(map[string]CheckResult, len(checks))
+	)
+
+	for name, check := range checks {
+		wg.Add(1)
+		go func(name string, check Check) {
+			defer wg.Done()
+
+			start := time.Now()
+			done := make(chan error, 1)
+			go func() { done <- check(ctx) }()
+
+			var err error
+			select {
+			case err = <-done:
+			case <-ctx.Done():
+				err = fmt.Errorf("check timed out after %v", CheckTimeout)
+			}
+
+			result := CheckResult{
+				Status:  StatusUp,
+				Latency: float64(time.Since(start).Microseconds()) / 1000,
+			}
+			if err != nil {
+				var degraded *degradedError
+				result.Status = StatusDown
+				if errors.As(err, &degraded) {
+					result.Status = StatusDegraded
+				}
+				result.Error = err.Error()
+			}
+
+			mu.Lock()
+			results[name] = result
+			mu.Unlock()
+		}(name, check)
+	}
+
+	wg.Wait()
+	return results
+}
+
+// checkDatabase 检查数据库连接
+func checkDatabase(ctx context.Context) error {
+	if database.SQLDB == nil {
+		return errors.New("database not initialized")
+	}
+	return database.SQLDB.PingContext(ctx)
+}
+
+// checkRedis 检查指定的 Redis 实例
+func checkRedis(instance redis.RedisInstance) Check {
+	return func(ctx context.Context) error {
+		if redis.Manager == nil {
+			return errors.New("redis not initialized")
+		}
+		return redis.GetRedis(instance).Client.Ping(ctx).Err()
+	}
+}
+
+// checkDify 检查 Dify 实例池，没有健康实例时为 down，健康实例不足 difyDegradedRatio 时为 degraded
+func checkDify(s *dify.DifyService) Check {
+	return func(ctx context.Context) error {
+		if s == nil {
+			return errors.New("dify service not initialized")
+		}
+		if err := s.HealthCheck(ctx); err != nil {
+			return err
+		}
+		report := s.HealthReport()
+		if report.HealthyRatio() < difyDegradedRatio {
+			return Degraded(fmt.Errorf("only %d of %d dify instances healthy", report.Healthy, report.Total))
+		}
+		return nil
+	}
+}
@@ -0,0 +1,173 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"tarot/pkg/dify"
+	"tarot/pkg/testutil"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// newHealthRouter 使用真实的数据库、Redis 与队列检查，Dify 检查由 dify 替代
+func newHealthRouter(t *testing.T, dify Check) (*gin.Engine, *HealthController) {
+	t.Helper()
+	testutil.SetupDB(t)
+	testutil.SetupRedis(t)
+
+	hc := NewHealthController()
+	hc.checks["dify"] = dify
+	router := gin.New()
+	router.GET("/healthz", hc.Liveness)
+	router.GET("/readyz", hc.Readiness)
+	return router, hc
+}
+
+func difyUp(ctx context.Context) error { return nil }
+
+// getReadiness 请求 /readyz，返回状态码、整体状态与各依赖的检查结果
+func getReadiness(t *testing.T, router *gin.Engine) (int, string, map[string]CheckResult) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/readyz", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	var body struct {
+		Code string `json:"code"`
+		Data struct {
+			Status string                 `json:"status"`
+			Checks map[string]CheckResult `json:"checks"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode response: %v: %s", err, w.Body.String())
+	}
+	if w.Code == http.StatusServiceUnavailable && body.Code != "service_unavailable" {
+		t.Fatalf("code = %q, want service_unavailable", body.Code)
+	}
+	return w.Code, body.Data.Status, body.Data.Checks
+}
+
+func TestLivenessAlwaysOK(t *testing.T) {
+	router, hc := newHealthRouter(t, difyUp)
+	hc.checks["database"] = func(ctx context.Context) error { return errors.New("down") }
+
+	req := httptest.NewRequest(http.MethodGet, "/healthz", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestReadinessAllHealthy(t *testing.T) {
+	router, _ := newHealthRouter(t, difyUp)
+
+	code, status, checks := getReadiness(t, router)
+	if code != http.StatusOK || status != StatusUp {
+		t.Fatalf("readyz = %d %s, want 200 up: %v", code, status, checks)
+	}
+	for _, name := range []string{"database", "redis_main", "redis_queue", "redis_cache", "queue", "dify"} {
+		if checks[name].Status != StatusUp {
+			t.Fatalf("check %s = %+v, want up", name, checks[name])
+		}
+	}
+}
+
+func TestReadinessDependencyDown(t *testing.T) {
+	router, hc := newHealthRouter(t, difyUp)
+	hc.checks["redis_queue"] = func(ctx context.Context) error { return errors.New("connection refused") }
+
+	code, status, checks := getReadiness(t, router)
+	if code != http.StatusServiceUnavailable || status != StatusDown {
+		t.Fatalf("readyz = %d %s, want 503 down", code, status)
+	}
+	if got := checks["redis_queue"]; got.Status != StatusDown || got.Error != "connection refused" {
+		t.Fatalf("redis_queue = %+v, want down with error", got)
+	}
+	if checks["database"].Status != StatusUp {
+		t.Fatalf("database = %+v, want up", checks["database"])
+	}
+}
+
+func TestReadinessDegradedDify(t *testing.T) {
+	router, _ := newHealthRouter(t, func(ctx context.Context) error {
+		return Degraded(errors.New("only 1 of 3 dify instances healthy"))
+	})
+
+	code, status, checks := getReadiness(t, router)
+	if code != http.StatusOK || status != StatusDegraded || checks["dify"].Status != StatusDegraded {
+		t.Fatalf("readyz = %d %s, dify = %+v, want 200 degraded", code, status, checks["dify"])
+	}
+}
+
+func TestRunChecksTimesOut(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	block := make(chan struct{})
+	defer close(block)
+
+	results := RunChecks(ctx, map[string]Check{
+		"slow": func(ctx context.Context) error { <-block; return nil },
+	})
+	if results["slow"].Status != StatusDown || results["slow"].Error == "" {
+		t.Fatalf("slow check = %+v, want down after timeout", results["slow"])
+	}
+}
+
+// difyPool 创建 total 个实例的 Dify 服务，前 down 个实例不健康
+func difyPool(t *testing.T, total, down int) *dify.DifyService {
+	t.Helper()
+	urls := make([]string, total)
+	keys := make([]string, total)
+	for i := range urls {
+		urls[i] = fmt.Sprintf("http://dify-%d.example.com", i+1)
+		keys[i] = fmt.Sprintf("app-%d", i+1)
+	}
+	s := dify.NewDifyService(&dify.Config{URLs: urls, APIKeys: keys, Timeout: time.Second})
+	if s == nil {
+		t.Fatal("NewDifyService returned nil")
+	}
+	for _, instance := range s.GetInstances()[:down] {
+		s.MarkInstanceUnhealthy(instance, errors.New("connection refused"))
+	}
+	return s
+}
+
+func TestReadinessFollowsDifyPool(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		total, down int
+		code        int
+		status      string
+	}{
+		{"all healthy", 3, 0, http.StatusOK, StatusUp},
+		{"half healthy", 4, 2, http.StatusOK, StatusUp},
+		{"partial", 3, 2, http.StatusOK, StatusDegraded},
+		{"none healthy", 3, 3, http.StatusServiceUnavailable, StatusDown},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			router, _ := newHealthRouter(t, checkDify(difyPool(t, tc.total, tc.down)))
+
+			code, status, checks := getReadiness(t, router)
+			if code != tc.code || status != tc.status || checks["dify"].Status != tc.status {
+				t.Fatalf("readyz = %d %s, dify = %+v, want %d %s", code, status, checks["dify"], tc.code, tc.status)
+			}
+			if tc.status == StatusDegraded && !strings.Contains(checks["dify"].Error, fmt.Sprintf("only %d of %d", tc.total-tc.down, tc.total)) {
+				t.Fatalf("dify error = %q, want the healthy count", checks["dify"].Error)
+			}
+		})
+	}
+}
@@ -0,0 +1,20 @@
+package health
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"tarot/pkg/response"
+	"tarot/pkg/version"
+)
+
+type VersionController struct{}
+
+func NewVersionController() *VersionController {
+	return &VersionController{}
+}
+
+// Show 返回当前部署的构建版本、Go 版本与运行时长
+// GET /v1/version
+func (vc *VersionController) Show(c *gin.Context) {
+	response.Data(c, version.Get())
+}
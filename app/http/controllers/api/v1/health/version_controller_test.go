@@ -0,0 +1,62 @@
+package health
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"tarot/pkg/version"
+)
+
+// getVersion 请求 /version 并解析 data 字段
+func getVersion(t *testing.T) version.Info {
+	t.Helper()
+	router := gin.New()
+	router.GET("/version", NewVersionController().Show)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/version", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", w.Code, w.Body.String())
+	}
+	var body struct {
+		Data version.Info `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	return body.Data
+}
+
+// setVersion 模拟 -ldflags 注入的构建信息，测试结束后恢复
+func setVersion(t *testing.T, v, commit, buildTime string) {
+	t.Helper()
+	prevVersion, prevCommit, prevBuildTime := version.Version, version.Commit, version.BuildTime
+	version.Version, version.Commit, version.BuildTime = v, commit, buildTime
+	t.Cleanup(func() {
+		version.Version, version.Commit, version.BuildTime = prevVersion, prevCommit, prevBuildTime
+	})
+}
+
+func TestVersionDefaultsToDev(t *testing.T) {
+	info := getVersion(t)
+	if info.Version != "dev" || info.Commit != "dev" || info.BuildTime != "dev" {
+		t.Fatalf("version = %+v, want dev defaults", info)
+	}
+	if info.GoVersion != runtime.Version() || info.Uptime < 0 {
+		t.Fatalf("go version = %s, uptime = %v", info.GoVersion, info.Uptime)
+	}
+}
+
+func TestVersionReturnsInjectedValues(t *testing.T) {
+	setVersion(t, "v1.2.0", "abc1234", "2024-01-02T15:04:05Z")
+
+	info := getVersion(t)
+	if info.Version != "v1.2.0" || info.Commit != "abc1234" || info.BuildTime != "2024-01-02T15:04:05Z" {
+		t.Fatalf("version = %+v, want injected values", info)
+	}
+}
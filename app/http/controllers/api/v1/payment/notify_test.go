@@ -0,0 +1,240 @@
+package payment
+
+import (
+	"context"
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	paymentModel "tarot/app/models/payment"
+	"tarot/app/models/reading"
+	"tarot/app/repositories"
+	"tarot/config"
+	"tarot/pkg/database"
+	"tarot/pkg/payment"
+	"tarot/pkg/payment/alipay"
+	"tarot/pkg/payment/stripe"
+	"tarot/pkg/payment/types"
+	"tarot/pkg/testutil"
+)
+
+// notifyService 按预设结果处理回调的支付服务
+type notifyService struct {
+	stubService
+	err    error
+	bodies []string
+}
+
+func (s *notifyService) HandleNotify(ctx context.Context, header http.Header, body []byte) error {
+	s.bodies = append(s.bodies, string(body))
+	return s.err
+}
+
+// setupNotifyTest 挂载三个回调路由，回调不经过登录中间件
+func setupNotifyTest(t *testing.T) *gin.Engine {
+	t.Helper()
+	testutil.SetupDB(t, &reading.Reading{}, &paymentModel.Payment{}, &paymentModel.PaymentEvent{}, &paymentModel.Refund{})
+	testutil.SetupRedis(t)
+
+	pc := NewPaymentController()
+	router := gin.New()
+	router.POST("/payments/notify/wechat", pc.NotifyWechat)
+	router.POST("/payments/notify/alipay", pc.NotifyAlipay)
+	router.POST("/payments/notify/stripe", pc.NotifyStripe)
+	return router
+}
+
+// createPending 为解读创建一笔待支付订单
+func createPending(t *testing.T, orderNo string, provider paymentModel.Provider, readingID uint64, amount int64) {
+	t.Helper()
+	expireAt := time.Now().Add(30 * time.Minute)
+	if err := database.DB.Create(&paymentModel.Payment{
+		OrderNo: orderNo, UserID: "user-1", ReadingID: readingID, Provider: string(provider),
+		Amount: amount, Status: string(paymentModel.StatusPending), ExpireAt: &expireAt,
+	}).Error; err != nil {
+		t.Fatalf("create payment: %v", err)
+	}
+}
+
+// assertPaid 断言订单已支付且对应解读已解锁
+func assertPaid(t *testing.T, orderNo string, readingID uint64, want bool) {
+	t.Helper()
+	var p paymentModel.Payment
+	if err := database.DB.Where("order_no = ?", orderNo).First(&p).Error; err != nil {
+		t.Fatalf("reload payment: %v", err)
+	}
+	var r reading.Reading
+	if err := database.DB.First(&r, readingID).Error; err != nil {
+		t.Fatalf("reload reading: %v", err)
+	}
+	if paid := p.Status == string(paymentModel.StatusPaid); paid != want || r.Unlocked != want {
+		t.Fatalf("payment status = %s, reading unlocked = %v, want paid=%v", p.Status, r.Unlocked, want)
+	}
+}
+
+func postNotify(router *gin.Engine, provider, contentType string, header http.Header, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/payments/notify/"+provider, strings.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestNotifyWechatResponses(t *testing.T) {
+	router := setupNotifyTest(t)
+	service := &notifyService{}
+	payment.Register(types.ProviderWechat, service)
+
+	w := postNotify(router, "wechat", "application/json", nil, `{"id":"evt-1","event_type":"TRANSACTION.SUCCESS"}`)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"code":"SUCCESS"`) {
+		t.Fatalf("success response = %d %s", w.Code, w.Body.String())
+	}
+	if len(service.bodies) != 1 || !strings.Contains(service.bodies[0], "evt-1") {
+		t.Fatalf("service received %v, want the raw callback body", service.bodies)
+	}
+
+	service.err = errors.New("verify failed")
+	w = postNotify(router, "wechat", "application/json", nil, `{"id":"evt-2"}`)
+	if w.Code != http.StatusInternalServerError || !strings.Contains(w.Body.String(), `"code":"FAIL"`) {
+		t.Fatalf("failure response = %d %s", w.Code, w.Body.String())
+	}
+}
+
+// signStripe 按 Stripe-Signature 格式签名
+func signStripe(payload, secret string) http.Header {
+	ts := time.Now().Unix()
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.%s", ts, payload)
+	header := http.Header{}
+	header.Set("Stripe-Signature", fmt.Sprintf("t=%d,v1=%s", ts, hex.EncodeToString(mac.Sum(nil))))
+	return header
+}
+
+func TestNotifyStripeMarksOrderPaid(t *testing.T) {
+	router := setupNotifyTest(t)
+	s, err := stripe.NewStripeService(config.StripeConfig{SecretKey: "sk_test", WebhookSecret: "whsec_test", Currency: "usd"}, repositories.NewPaymentRepository())
+	if err != nil {
+		t.Fatalf("NewStripeService: %v", err)
+	}
+	payment.Register(types.ProviderStripe, s)
+	r := createReading(t, "user-1", reading.TypePremium)
+	createPending(t, "S-1", paymentModel.ProviderStripe, r.ID, 299)
+
+	event, _ := json.Marshal(map[string]interface{}{
+		"id":   "evt_1",
+		"type": stripe.EventPaymentIntentSucceeded,
+		"data": map[string]interface{}{"object": map[string]interface{}{
+			"id": "pi_1", "status": "succeeded", "amount": 299, "currency": "usd",
+			"metadata": map[string]string{"order_no": "S-1"},
+		}},
+	})
+
+	w := postNotify(router, "stripe", "application/json", signStripe(string(event), "whsec_wrong"), string(event))
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), `"received":false`) {
+		t.Fatalf("bad signature response = %d %s", w.Code, w.Body.String())
+	}
+	assertPaid(t, "S-1", r.ID, false)
+
+	w = postNotify(router, "stripe", "application/json", signStripe(string(event), "whsec_test"), string(event))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"received":true`) {
+		t.Fatalf("response = %d %s", w.Code, w.Body.String())
+	}
+	assertPaid(t, "S-1", r.ID, true)
+}
+
+// newAlipayService 使用临时生成的密钥对创建支付宝服务，平台公钥与应用私钥为同一对
+func newAlipayService(t *testing.T) (*alipay.AlipayService, *rsa.PrivateKey) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	pub, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatalf("marshal public key: %v", err)
+	}
+	s, err := alipay.NewAlipayService(config.AlipayConfig{
+		AppID:      "2021000000000000",
+		PrivateKey: string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})),
+		PublicKey:  string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pub})),
+	}, repositories.NewPaymentRepository())
+	if err != nil {
+		t.Fatalf("NewAlipayService: %v", err)
+	}
+	return s, key
+}
+
+// signAlipay 按支付宝规则签名：除 sign/sign_type 外的字段按 key 排序后以 & 连接，RSA2 签名
+func signAlipay(t *testing.T, key *rsa.PrivateKey, values url.Values) string {
+	t.Helper()
+	pairs := make([]string, 0, len(values))
+	for k := range values {
+		if k == "sign" || k == "sign_type" {
+			continue
+		}
+		pairs = append(pairs, k+"="+values.Get(k))
+	}
+	sort.Strings(pairs)
+	digest := sha256.Sum256([]byte(strings.Join(pairs, "&")))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	values.Set("sign_type", "RSA2")
+	values.Set("sign", base64.StdEncoding.EncodeToString(sig))
+	return values.Encode()
+}
+
+func TestNotifyAlipayMarksOrderPaid(t *testing.T) {
+	router := setupNotifyTest(t)
+	s, key := newAlipayService(t)
+	payment.Register(types.ProviderAlipay, s)
+	r := createReading(t, "user-1", reading.TypePremium)
+	createPending(t, "A-1", paymentModel.ProviderAlipay, r.ID, 2000)
+
+	values := url.Values{
+		"app_id":       {"2021000000000000"},
+		"notify_id":    {"notify-1"},
+		"notify_type":  {"trade_status_sync"},
+		"notify_time":  {"2024-01-02 15:04:05"},
+		"out_trade_no": {"A-1"},
+		"trade_no":     {"2024010222001"},
+		"trade_status": {"TRADE_SUCCESS"},
+		"total_amount": {"20.00"},
+		"gmt_payment":  {"2024-01-02 15:04:00"},
+	}
+	body := signAlipay(t, key, values)
+
+	tampered := strings.Replace(body, "total_amount=20.00", "total_amount=0.01", 1)
+	if w := postNotify(router, "alipay", "application/x-www-form-urlencoded", nil, tampered); w.Body.String() != "fail" {
+		t.Fatalf("tampered response = %q, want fail", w.Body.String())
+	}
+	assertPaid(t, "A-1", r.ID, false)
+
+	w := postNotify(router, "alipay", "application/x-www-form-urlencoded", nil, body)
+	if w.Code != http.StatusOK || w.Body.String() != "success" {
+		t.Fatalf("response = %d %q, want success", w.Code, w.Body.String())
+	}
+	assertPaid(t, "A-1", r.ID, true)
+}
@@ -1,29 +1,42 @@
 package payment
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
 	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
 
+	paymentModel "tarot/app/models/payment"
+	"tarot/app/models/reading"
+	"tarot/app/repositories"
+	"tarot/pkg/config"
+	"tarot/pkg/logger"
 	"tarot/pkg/payment"
+	"tarot/pkg/payment/types"
 	"tarot/pkg/response"
 )
 
-type PaymentController struct {
-	paymentService payment.Service
-}
+var errProviderUnavailable = errors.New("payment provider not available")
+
+type PaymentController struct{}
 
 // NewPaymentController 创建支付控制器
-func NewPaymentController(service payment.Service) *PaymentController {
-	return &PaymentController{
-		paymentService: service,
-	}
+func NewPaymentController() *PaymentController {
+	return &PaymentController{}
 }
 
 // CreatePayment 创建支付
 func (pc *PaymentController) CreatePayment(c *gin.Context) {
 	var req struct {
-		ReadingID uint64           `json:"reading_id" binding:"required"`
-		Provider  payment.Provider `json:"provider" binding:"required,oneof=wechat alipay"`
-		ReturnURL string           `json:"return_url"`
+		ReadingID uint64         `json:"reading_id" binding:"required"`
+		Provider  types.Provider `json:"provider" binding:"required,oneof=wechat alipay stripe"`
+		ReturnURL string         `json:"return_url"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -31,25 +44,275 @@ func (pc *PaymentController) CreatePayment(c *gin.Context) {
 		return
 	}
 
+	service, ok := payment.GetService(req.Provider)
+	if !ok {
+		response.Abort400(c, "payment provider not available")
+		return
+	}
+
 	// 获取用户ID
 	userID := c.GetString("user_id")
+	if userID == "" {
+		response.Abort401(c)
+		return
+	}
+
+	// 只有已注册的用户才能发起支付
+	if _, err := repositories.NewUserRepository().GetByID(c.Request.Context(), userID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			response.Abort401(c, "user not found")
+			return
+		}
+		response.Abort500(c, "get user failed")
+		return
+	}
+
+	// 校验解读归属与支付状态
+	readingRecord, err := repositories.NewReadingRepository().GetByID(c.Request.Context(), req.ReadingID)
+	if err != nil {
+		response.Abort404(c, "reading not found")
+		return
+	}
+	if readingRecord.UserID != userID {
+		response.Abort403(c, "reading does not belong to current user")
+		return
+	}
+
+	amount, currency, ok := priceFor(readingRecord, req.Provider)
+	if !ok {
+		response.Abort400(c, "reading does not require payment")
+		return
+	}
+
+	paid, err := repositories.NewPaymentRepository().HasPaidReading(c.Request.Context(), readingRecord.ID)
+	if err != nil {
+		response.Abort500(c, "check payment status failed")
+		return
+	}
+	if paid {
+		response.Abort400(c, "reading already paid")
+		return
+	}
+
+	// 同一解读已有未过期的待支付订单时直接复用，避免重复下单
+	if result, ok := reusablePayment(c.Request.Context(), userID, readingRecord.ID, req.Provider); ok {
+		response.Data(c, result)
+		return
+	}
 
 	// 创建支付请求
-	payReq := &payment.Request{
+	payReq := &types.Request{
 		UserID:      userID,
 		ReadingID:   req.ReadingID,
-		Amount:      2000, // 20元
+		Amount:      amount,
+		Currency:    currency,
 		Provider:    req.Provider,
 		ReturnURL:   req.ReturnURL,
 		Description: "塔罗牌解读服务",
 	}
 
 	// 创建支付
-	result, err := pc.paymentService.CreatePayment(c.Request.Context(), payReq)
+	result, err := service.CreatePayment(c.Request.Context(), payReq)
 	if err != nil {
+		logger.Error("create payment failed", zap.Error(err))
 		response.Abort500(c, "create payment failed")
 		return
 	}
+	if err := repositories.NewPaymentRepository().SaveResult(c.Request.Context(), result.OrderNo, result); err != nil {
+		// 保存失败只影响后续复用，不影响本次支付
+		logger.Warn("save payment result failed", zap.String("order_no", result.OrderNo), zap.Error(err))
+	}
 
 	response.Data(c, result)
 }
+
+// reusableMinRemaining 复用订单时距过期至少剩余的时间，留给用户完成支付
+const reusableMinRemaining = 2 * time.Minute
+
+// reusablePayment 查找可复用的待支付订单，返回当时保存的下单结果
+func reusablePayment(ctx context.Context, userID string, readingID uint64, provider types.Provider) (*types.Result, bool) {
+	p, err := repositories.NewPaymentRepository().FindReusablePending(ctx, userID, readingID, string(provider), time.Now().Add(reusableMinRemaining))
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			logger.Warn("find reusable payment failed", zap.Error(err))
+		}
+		return nil, false
+	}
+
+	stored, ok := p.ExtraData[paymentModel.ResultKey]
+	if !ok {
+		return nil, false
+	}
+	raw, err := json.Marshal(stored)
+	if err != nil {
+		return nil, false
+	}
+	var result types.Result
+	if err := json.Unmarshal(raw, &result); err != nil || result.OrderNo != p.OrderNo {
+		return nil, false
+	}
+	return &result, true
+}
+
+// Show 查询支付订单状态
+// 订单仍待支付且未过期时向支付平台查询并同步，避免客户端只能等待回调
+func (pc *PaymentController) Show(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		response.Abort401(c)
+		return
+	}
+
+	record, err := repositories.NewPaymentRepository().GetByOrderNo(c.Request.Context(), c.Param("order_no"))
+	if err != nil {
+		response.Abort404(c, "payment not found")
+		return
+	}
+	if record.UserID != userID {
+		response.Abort403(c, "payment does not belong to current user")
+		return
+	}
+
+	if record.IsPending() && !record.IsExpired() {
+		if service, ok := payment.GetService(types.Provider(record.Provider)); ok {
+			ctx := paymentModel.WithEventSource(c.Request.Context(), paymentModel.EventSourceQuery, nil)
+			synced, err := service.QueryPayment(ctx, record.OrderNo)
+			if err != nil {
+				// 查询失败时返回本地状态，由客户端继续轮询
+				logger.Warn("query payment from provider failed",
+					zap.String("order_no", record.OrderNo), zap.Error(err))
+			} else {
+				record = synced
+			}
+		}
+	}
+
+	response.Data(c, paymentView(record))
+}
+
+// Index 分页查询用户的支付记录，可按状态过滤
+// GET /v1/users/:user_id/payments?status=paid&page=1&page_size=10
+func (pc *PaymentController) Index(c *gin.Context) {
+	userID := c.Param("user_id")
+	if userID == "" || userID != c.GetString("user_id") {
+		response.Abort403(c)
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	size, _ := strconv.Atoi(c.DefaultQuery("page_size", "10"))
+	if page < 1 {
+		page = 1
+	}
+	if size < 1 || size > 100 {
+		size = 10
+	}
+
+	status := c.Query("status")
+	switch types.Status(status) {
+	case "", types.StatusPending, types.StatusPaid, types.StatusFailed, types.StatusCanceled, types.StatusRefunded:
+	default:
+		response.Abort400(c, "invalid payment status")
+		return
+	}
+
+	records, total, err := repositories.NewPaymentRepository().ListByUserID(c.Request.Context(), userID, status, page, size)
+	if err != nil {
+		logger.Error("list payments failed", zap.Error(err))
+		response.Abort500(c, "list payments failed")
+		return
+	}
+
+	items := make([]gin.H, len(records))
+	for i := range records {
+		items[i] = paymentView(&records[i])
+	}
+	response.Data(c, gin.H{
+		"data": items,
+		"meta": gin.H{
+			"total":     total,
+			"page":      page,
+			"page_size": size,
+		},
+	})
+}
+
+// paymentView 返回给用户的订单信息，不包含交易号与支付平台原始数据
+func paymentView(p *paymentModel.Payment) gin.H {
+	return gin.H{
+		"order_no":        p.OrderNo,
+		"reading_id":      p.ReadingID,
+		"provider":        p.Provider,
+		"amount":          p.Amount,
+		"refunded_amount": p.RefundedAmount,
+		"status":          p.Status,
+		"expired":         p.IsPending() && p.IsExpired(),
+		"pay_at":          p.PayAt,
+		"expire_at":       p.ExpireAt,
+		"created_at":      p.CreatedAt,
+	}
+}
+
+// priceFor 根据解读类型与支付渠道计算应付金额与币种，免费解读无需支付
+// 微信与支付宝按 payment.premium_price（分）以人民币收款，Stripe 使用单独配置的价格与币种
+func priceFor(r *reading.Reading, provider types.Provider) (int64, string, bool) {
+	if !r.IsPremium() {
+		return 0, "", false
+	}
+	if provider == types.ProviderStripe {
+		return config.GetInt64("payment.stripe.premium_price", 299), config.GetString("payment.stripe.currency", "usd"), true
+	}
+	return config.GetInt64("payment.premium_price", 2000), config.GetString("payment.currency", "CNY"), true
+}
+
+// NotifyWechat 微信支付结果通知
+// 应答格式遵循微信支付 V3 规范，非 2xx 时微信会按策略重发
+func (pc *PaymentController) NotifyWechat(c *gin.Context) {
+	if err := pc.handleNotify(c, types.ProviderWechat); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": "FAIL", "message": "失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": "SUCCESS", "message": "成功"})
+}
+
+// NotifyAlipay 支付宝支付结果通知
+// 支付宝要求返回纯文本 success，否则会重复推送
+func (pc *PaymentController) NotifyAlipay(c *gin.Context) {
+	if err := pc.handleNotify(c, types.ProviderAlipay); err != nil {
+		c.String(http.StatusOK, "fail")
+		return
+	}
+	c.String(http.StatusOK, "success")
+}
+
+// NotifyStripe Stripe Webhook
+// 返回非 2xx 时 Stripe 会按退避策略重发
+func (pc *PaymentController) NotifyStripe(c *gin.Context) {
+	if err := pc.handleNotify(c, types.ProviderStripe); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"received": false})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"received": true})
+}
+
+// handleNotify 读取原始请求体并交给对应支付服务处理
+func (pc *PaymentController) handleNotify(c *gin.Context, provider types.Provider) error {
+	service, ok := payment.GetService(provider)
+	if !ok {
+		logger.Error("payment notify for unavailable provider", zap.String("provider", string(provider)))
+		return errProviderUnavailable
+	}
+
+	body, err := c.GetRawData()
+	if err != nil {
+		logger.Error("read payment notify body failed", zap.String("provider", string(provider)), zap.Error(err))
+		return err
+	}
+
+	ctx := paymentModel.WithEventSource(c.Request.Context(), paymentModel.EventSourceNotify, body)
+	if err := service.HandleNotify(ctx, c.Request.Header, body); err != nil {
+		logger.Error("handle payment notify failed", zap.String("provider", string(provider)), zap.Error(err))
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,433 @@
+package payment
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	paymentModel "tarot/app/models/payment"
+	"tarot/app/models/reading"
+	"tarot/app/models/user"
+	"tarot/pkg/database"
+	"tarot/pkg/payment"
+	"tarot/pkg/payment/types"
+	"tarot/pkg/testutil"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// stubService 记录下单请求的支付服务
+type stubService struct {
+	requests []*types.Request
+}
+
+func (s *stubService) CreatePayment(ctx context.Context, req *types.Request) (*types.Result, error) {
+	s.requests = append(s.requests, req)
+	return &types.Result{OrderNo: "order-1"}, nil
+}
+
+func (s *stubService) QueryPayment(ctx context.Context, orderNo string) (*paymentModel.Payment, error) {
+	return nil, errors.New("not used")
+}
+
+func (s *stubService) HandleNotify(ctx context.Context, header http.Header, body []byte) error {
+	return errors.New("not used")
+}
+
+func (s *stubService) CancelPayment(ctx context.Context, orderNo string) error {
+	return errors.New("not used")
+}
+
+func (s *stubService) RefundPayment(ctx context.Context, orderNo string, amount int64, reason string) error {
+	return errors.New("not used")
+}
+
+func (s *stubService) SyncRefund(ctx context.Context, refund *paymentModel.Refund) error {
+	return errors.New("not used")
+}
+
+func setupPaymentTest(t *testing.T) (*gin.Engine, *stubService) {
+	t.Helper()
+	testutil.SetupDB(t, &user.User{}, &reading.Reading{}, &paymentModel.Payment{}, &paymentModel.PaymentEvent{})
+
+	service := &stubService{}
+	payment.Register(types.ProviderWechat, service)
+
+	for _, id := range []string{"user-1", "user-2"} {
+		email := id + "@example.com"
+		u := &user.User{ID: id, Email: &email, ClerkID: "clerk_" + id}
+		if err := database.DB.Create(u).Error; err != nil {
+			t.Fatalf("create user: %v", err)
+		}
+	}
+
+	pc := NewPaymentController()
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("user_id", c.GetHeader("X-Test-User"))
+		c.Next()
+	})
+	router.POST("/payments", pc.CreatePayment)
+	router.GET("/payments/:order_no", pc.Show)
+	router.GET("/users/:user_id/payments", pc.Index)
+	return router, service
+}
+
+func createReading(t *testing.T, userID string, readingType reading.ReadingType) *reading.Reading {
+	t.Helper()
+	r := &reading.Reading{TaskID: "task-" + userID + "-" + string(readingType), UserID: userID, Type: readingType, Cards: reading.Cards{1}}
+	if err := database.DB.Create(r).Error; err != nil {
+		t.Fatalf("create reading: %v", err)
+	}
+	return r
+}
+
+func postPayment(router *gin.Engine, userID string, readingID uint64) *httptest.ResponseRecorder {
+	return postPaymentWith(router, userID, readingID, types.ProviderWechat)
+}
+
+func postPaymentWith(router *gin.Engine, userID string, readingID uint64, provider types.Provider) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(map[string]interface{}{"reading_id": readingID, "provider": provider})
+	req := httptest.NewRequest(http.MethodPost, "/payments", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Test-User", userID)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestCreatePaymentUsesConfiguredPrice(t *testing.T) {
+	router, service := setupPaymentTest(t)
+	testutil.SetConfig(t, "payment.premium_price", 1288)
+	testutil.SetConfig(t, "payment.currency", "CNY")
+	r := createReading(t, "user-1", reading.TypePremium)
+
+	if w := postPayment(router, "user-1", r.ID); w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", w.Code, w.Body.String())
+	}
+	if len(service.requests) != 1 {
+		t.Fatalf("CreatePayment called %d times, want 1", len(service.requests))
+	}
+	if req := service.requests[0]; req.Amount != 1288 || req.Currency != "CNY" || req.ReadingID != r.ID {
+		t.Fatalf("payment request = %+v, want 1288 CNY for reading %d", req, r.ID)
+	}
+}
+
+func TestCreatePaymentUsesStripePriceAndCurrency(t *testing.T) {
+	router, _ := setupPaymentTest(t)
+	stripeService := &stubService{}
+	payment.Register(types.ProviderStripe, stripeService)
+	testutil.SetConfig(t, "payment.premium_price", 1288)
+	testutil.SetConfig(t, "payment.stripe.premium_price", 199)
+	testutil.SetConfig(t, "payment.stripe.currency", "usd")
+	r := createReading(t, "user-1", reading.TypePremium)
+
+	if w := postPaymentWith(router, "user-1", r.ID, types.ProviderStripe); w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", w.Code, w.Body.String())
+	}
+	if req := stripeService.requests[0]; req.Amount != 199 || req.Currency != "usd" {
+		t.Fatalf("stripe request = %+v, want 199 usd", req)
+	}
+}
+
+func TestCreatePaymentRejectsOtherUsersReading(t *testing.T) {
+	router, service := setupPaymentTest(t)
+	r := createReading(t, "user-2", reading.TypePremium)
+
+	if w := postPayment(router, "user-1", r.ID); w.Code != http.StatusForbidden {
+		t.Fatalf("status = %d, want 403: %s", w.Code, w.Body.String())
+	}
+	if len(service.requests) != 0 {
+		t.Fatalf("CreatePayment called for someone else's reading")
+	}
+}
+
+func TestCreatePaymentRejectsFreeAndPaidReadings(t *testing.T) {
+	router, service := setupPaymentTest(t)
+	free := createReading(t, "user-1", reading.TypeFree)
+	paid := createReading(t, "user-1", reading.TypePremium)
+	if err := database.DB.Create(&paymentModel.Payment{
+		OrderNo: "paid-1", UserID: "user-1", ReadingID: paid.ID, Provider: "wechat", Amount: 2000, Status: string(paymentModel.StatusPaid),
+	}).Error; err != nil {
+		t.Fatalf("create payment: %v", err)
+	}
+
+	if w := postPayment(router, "user-1", free.ID); w.Code != http.StatusBadRequest {
+		t.Fatalf("free reading status = %d, want 400", w.Code)
+	}
+	if w := postPayment(router, "user-1", paid.ID); w.Code != http.StatusBadRequest {
+		t.Fatalf("paid reading status = %d, want 400", w.Code)
+	}
+	if len(service.requests) != 0 {
+		t.Fatalf("CreatePayment called %d times, want 0", len(service.requests))
+	}
+}
+
+// orderService 像真实支付平台一样为每次下单保存一笔待支付订单
+type orderService struct {
+	stubService
+	ttl time.Duration
+}
+
+func (s *orderService) CreatePayment(ctx context.Context, req *types.Request) (*types.Result, error) {
+	s.requests = append(s.requests, req)
+	orderNo := fmt.Sprintf("order-%d", len(s.requests))
+	expireAt := time.Now().Add(s.ttl)
+	if err := database.DB.Create(&paymentModel.Payment{
+		OrderNo: orderNo, UserID: req.UserID, ReadingID: req.ReadingID, Provider: string(req.Provider),
+		Amount: req.Amount, Status: string(paymentModel.StatusPending), ExpireAt: &expireAt,
+	}).Error; err != nil {
+		return nil, err
+	}
+	return &types.Result{OrderNo: orderNo, PaymentURL: "weixin://wxpay/" + orderNo, ExpireAt: expireAt}, nil
+}
+
+// createdOrderNo 解析下单响应中的订单号
+func createdOrderNo(t *testing.T, w *httptest.ResponseRecorder) string {
+	t.Helper()
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", w.Code, w.Body.String())
+	}
+	var body struct {
+		Data types.Result `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	return body.Data.OrderNo
+}
+
+func TestCreatePaymentReusesPendingOrder(t *testing.T) {
+	router, _ := setupPaymentTest(t)
+	service := &orderService{ttl: 30 * time.Minute}
+	payment.Register(types.ProviderWechat, service)
+	r := createReading(t, "user-1", reading.TypePremium)
+
+	first := postPayment(router, "user-1", r.ID)
+	second := postPayment(router, "user-1", r.ID)
+	if a, b := createdOrderNo(t, first), createdOrderNo(t, second); a != "order-1" || b != a {
+		t.Fatalf("order numbers = %q, %q, want the same order reused", a, b)
+	}
+	if !strings.Contains(second.Body.String(), "weixin://wxpay/order-1") {
+		t.Fatalf("reused result = %s, want the stored payment url", second.Body.String())
+	}
+	if len(service.requests) != 1 {
+		t.Fatalf("CreatePayment called %d times, want 1", len(service.requests))
+	}
+
+	var n int64
+	database.DB.Model(&paymentModel.Payment{}).Where("reading_id = ?", r.ID).Count(&n)
+	if n != 1 {
+		t.Fatalf("pending orders = %d, want 1", n)
+	}
+}
+
+func TestCreatePaymentDoesNotReuseExpiringOrder(t *testing.T) {
+	router, _ := setupPaymentTest(t)
+	// 订单剩余时间不足以完成支付时重新下单
+	service := &orderService{ttl: time.Minute}
+	payment.Register(types.ProviderWechat, service)
+	r := createReading(t, "user-1", reading.TypePremium)
+
+	first := createdOrderNo(t, postPayment(router, "user-1", r.ID))
+	second := createdOrderNo(t, postPayment(router, "user-1", r.ID))
+	if first == second || len(service.requests) != 2 {
+		t.Fatalf("order numbers = %q, %q with %d requests, want a new order", first, second, len(service.requests))
+	}
+}
+
+func TestCreatePaymentRejectsMissingReading(t *testing.T) {
+	router, service := setupPaymentTest(t)
+
+	if w := postPayment(router, "user-1", 999); w.Code != http.StatusNotFound {
+		t.Fatalf("status = %d, want 404: %s", w.Code, w.Body.String())
+	}
+	if len(service.requests) != 0 {
+		t.Fatalf("CreatePayment called for a missing reading")
+	}
+}
+
+// queryService 查询时返回已支付结果的支付服务
+type queryService struct {
+	stubService
+	queried []string
+}
+
+func (s *queryService) QueryPayment(ctx context.Context, orderNo string) (*paymentModel.Payment, error) {
+	s.queried = append(s.queried, orderNo)
+	p := &paymentModel.Payment{}
+	if err := database.DB.Where("order_no = ?", orderNo).First(p).Error; err != nil {
+		return nil, err
+	}
+	p.MarkPaid("wx-trade", time.Now())
+	return p, database.DB.Save(p).Error
+}
+
+// showPayment 以 userID 的身份查询订单，返回状态码与 data 字段
+func showPayment(t *testing.T, router *gin.Engine, userID, orderNo string) (int, map[string]interface{}) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/payments/"+orderNo, nil)
+	req.Header.Set("X-Test-User", userID)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	var body struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &body)
+	return w.Code, body.Data
+}
+
+func createOrder(t *testing.T, orderNo string, status paymentModel.Status, expireAt time.Time) {
+	t.Helper()
+	if err := database.DB.Create(&paymentModel.Payment{
+		OrderNo: orderNo, UserID: "user-1", Provider: "wechat", Amount: 2000, Status: string(status), ExpireAt: &expireAt,
+	}).Error; err != nil {
+		t.Fatalf("create payment: %v", err)
+	}
+}
+
+func TestShowPaymentStatus(t *testing.T) {
+	router, _ := setupPaymentTest(t)
+	service := &queryService{}
+	payment.Register(types.ProviderWechat, service)
+
+	future := time.Now().Add(10 * time.Minute)
+	createOrder(t, "paid-1", paymentModel.StatusPaid, future)
+	createOrder(t, "pending-1", paymentModel.StatusPending, future)
+	createOrder(t, "expired-1", paymentModel.StatusPending, time.Now().Add(-time.Minute))
+
+	if code, data := showPayment(t, router, "user-1", "paid-1"); code != http.StatusOK || data["status"] != "paid" {
+		t.Fatalf("paid order: status=%d data=%v", code, data)
+	}
+	if code, data := showPayment(t, router, "user-1", "pending-1"); code != http.StatusOK || data["status"] != "paid" {
+		t.Fatalf("pending order: status=%d data=%v, want synced to paid", code, data)
+	}
+	if code, data := showPayment(t, router, "user-1", "expired-1"); code != http.StatusOK || data["status"] != "pending" || data["expired"] != true {
+		t.Fatalf("expired order: status=%d data=%v", code, data)
+	}
+	if len(service.queried) != 1 || service.queried[0] != "pending-1" {
+		t.Fatalf("provider queried for %v, want only pending-1", service.queried)
+	}
+}
+
+func TestShowPaymentOwnership(t *testing.T) {
+	router, _ := setupPaymentTest(t)
+	createOrder(t, "paid-1", paymentModel.StatusPaid, time.Now())
+
+	if code, _ := showPayment(t, router, "user-2", "paid-1"); code != http.StatusForbidden {
+		t.Fatalf("other user status = %d, want 403", code)
+	}
+	if code, _ := showPayment(t, router, "user-1", "missing"); code != http.StatusNotFound {
+		t.Fatalf("missing order status = %d, want 404", code)
+	}
+	if code, _ := showPayment(t, router, "", "paid-1"); code != http.StatusUnauthorized {
+		t.Fatalf("anonymous status = %d, want 401", code)
+	}
+}
+
+// createHistory 为 user-1 创建按时间先后排列的订单，statuses 依次对应 order-h0、order-h1……
+func createHistory(t *testing.T, statuses ...paymentModel.Status) {
+	t.Helper()
+	base := time.Now().Add(-time.Hour)
+	for i, status := range statuses {
+		if err := database.DB.Create(&paymentModel.Payment{
+			OrderNo: fmt.Sprintf("order-h%d", i), UserID: "user-1", ReadingID: uint64(i + 1), Provider: "wechat",
+			Amount: 2000, Status: string(status), TransactionID: fmt.Sprintf("wx-secret-%d", i),
+			ExtraData: paymentModel.JSON{"raw": "provider payload"}, CreatedAt: base.Add(time.Duration(i) * time.Minute),
+		}).Error; err != nil {
+			t.Fatalf("create payment: %v", err)
+		}
+	}
+	if err := database.DB.Create(&paymentModel.Payment{
+		OrderNo: "order-other", UserID: "user-2", ReadingID: 99, Provider: "wechat", Amount: 2000, Status: string(paymentModel.StatusPaid),
+	}).Error; err != nil {
+		t.Fatalf("create payment: %v", err)
+	}
+}
+
+// listPayments 以 currentUser 的身份查询 user-1 的支付记录，返回订单号与总数
+func listPayments(t *testing.T, router *gin.Engine, currentUser, query string) (int, []string, float64) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/users/user-1/payments"+query, nil)
+	req.Header.Set("X-Test-User", currentUser)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	var body struct {
+		Data struct {
+			Data []map[string]interface{} `json:"data"`
+			Meta map[string]interface{}   `json:"meta"`
+		} `json:"data"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &body)
+	orderNos := make([]string, 0, len(body.Data.Data))
+	for _, item := range body.Data.Data {
+		if _, ok := item["transaction_id"]; ok {
+			t.Fatalf("payment item exposes transaction_id: %v", item)
+		}
+		if _, ok := item["extra_data"]; ok {
+			t.Fatalf("payment item exposes extra_data: %v", item)
+		}
+		orderNos = append(orderNos, item["order_no"].(string))
+	}
+	total, _ := body.Data.Meta["total"].(float64)
+	return w.Code, orderNos, total
+}
+
+func TestPaymentHistoryPagination(t *testing.T) {
+	router, _ := setupPaymentTest(t)
+	createHistory(t, paymentModel.StatusPaid, paymentModel.StatusPending, paymentModel.StatusPaid, paymentModel.StatusRefunded, paymentModel.StatusPaid)
+
+	for _, tc := range []struct {
+		query string
+		want  []string
+	}{
+		{"?page=1&page_size=2", []string{"order-h4", "order-h3"}},
+		{"?page=2&page_size=2", []string{"order-h2", "order-h1"}},
+		{"?page=3&page_size=2", []string{"order-h0"}},
+		{"?page=4&page_size=2", []string{}},
+	} {
+		code, orderNos, total := listPayments(t, router, "user-1", tc.query)
+		if code != http.StatusOK || total != 5 || !slices.Equal(orderNos, tc.want) {
+			t.Fatalf("%s: status=%d orders=%v total=%v, want %v of 5", tc.query, code, orderNos, total, tc.want)
+		}
+	}
+}
+
+func TestPaymentHistoryStatusFilter(t *testing.T) {
+	router, _ := setupPaymentTest(t)
+	createHistory(t, paymentModel.StatusPaid, paymentModel.StatusPending, paymentModel.StatusPaid, paymentModel.StatusRefunded, paymentModel.StatusPaid)
+
+	code, orderNos, total := listPayments(t, router, "user-1", "?status=paid&page_size=2")
+	if code != http.StatusOK || total != 3 || !slices.Equal(orderNos, []string{"order-h4", "order-h2"}) {
+		t.Fatalf("paid: status=%d orders=%v total=%v", code, orderNos, total)
+	}
+	if code, orderNos, total := listPayments(t, router, "user-1", "?status=pending"); code != http.StatusOK || total != 1 || !slices.Equal(orderNos, []string{"order-h1"}) {
+		t.Fatalf("pending: status=%d orders=%v total=%v", code, orderNos, total)
+	}
+	if code, _, _ := listPayments(t, router, "user-1", "?status=unknown"); code != http.StatusBadRequest {
+		t.Fatalf("unknown status = %d, want 400", code)
+	}
+}
+
+func TestPaymentHistoryOwnership(t *testing.T) {
+	router, _ := setupPaymentTest(t)
+	createHistory(t, paymentModel.StatusPaid)
+
+	if code, _, _ := listPayments(t, router, "user-2", ""); code != http.StatusForbidden {
+		t.Fatalf("other user status = %d, want 403", code)
+	}
+}
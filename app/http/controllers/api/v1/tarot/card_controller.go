@@ -0,0 +1,38 @@
+package tarot
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"tarot/pkg/response"
+	"tarot/pkg/tarot"
+)
+
+type CardController struct{}
+
+func NewCardController() *CardController {
+	return &CardController{}
+}
+
+// Index 获取完整卡牌目录
+func (cc *CardController) Index(c *gin.Context) {
+	response.Data(c, tarot.All())
+}
+
+// Show 获取单张卡牌
+func (cc *CardController) Show(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		response.Abort400(c, "无效的卡牌编号")
+		return
+	}
+
+	card, ok := tarot.Get(id)
+	if !ok {
+		response.Abort404(c, "卡牌不存在")
+		return
+	}
+
+	response.Data(c, card)
+}
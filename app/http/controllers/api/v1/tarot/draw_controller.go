@@ -0,0 +1,51 @@
+package tarot
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"tarot/app/requests"
+	"tarot/pkg/config"
+	"tarot/pkg/helpers"
+	"tarot/pkg/logger"
+	"tarot/pkg/response"
+	"tarot/pkg/tarot"
+)
+
+type DrawController struct {
+	random helpers.RandomSource // 洗牌使用的随机数来源
+}
+
+func NewDrawController() *DrawController {
+	return &DrawController{random: helpers.CryptoRandom}
+}
+
+// Store 由服务端抽牌，返回的 draw_id 在创建解读时引用
+// POST /v1/tarot/draws
+// 开启 tarot.require_draw 时，解读的卡牌必须与该次抽牌一致，抽牌结果在 tarot.draw_ttl 秒后过期
+func (dc *DrawController) Store(c *gin.Context) {
+	request, err := requests.ValidateDraw(c)
+	if err != nil {
+		response.BadRequest(c, err, "请求验证失败")
+		return
+	}
+	if !bindRequestUser(c, &request.UserID) {
+		return
+	}
+
+	ttl := time.Duration(config.GetInt("tarot.draw_ttl", 600)) * time.Second
+	draw := &tarot.Draw{
+		ID:        helpers.UUID(),
+		UserID:    request.UserID,
+		Cards:     tarot.DrawCards(dc.random, request.Count),
+		ExpiresAt: time.Now().Add(ttl),
+	}
+	if err := tarot.SaveDraw(c.Request.Context(), draw, ttl); err != nil {
+		logger.ErrorStringContext(c.Request.Context(), "Draw", "Save", err.Error())
+		response.Abort500(c, "抽牌失败")
+		return
+	}
+
+	response.Created(c, draw)
+}
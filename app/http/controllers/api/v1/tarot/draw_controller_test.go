@@ -0,0 +1,106 @@
+package tarot
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"tarot/pkg/testutil"
+)
+
+// newDrawRouter 创建开启 tarot.require_draw 的解读路由，并注册抽牌接口
+func newDrawRouter(t *testing.T) *gin.Engine {
+	t.Helper()
+	router := newReadingRouter(t)
+	testutil.SetConfig(t, "tarot.require_draw", true)
+	router.POST("/draws", NewDrawController().Store)
+	return router
+}
+
+// postDraw 为 userID 抽取 count 张牌，返回 draw_id 与卡牌
+func postDraw(t *testing.T, router *gin.Engine, userID string, count int) (string, []int) {
+	t.Helper()
+	body, _ := json.Marshal(map[string]interface{}{"user_id": userID, "count": count})
+	req := httptest.NewRequest(http.MethodPost, "/draws", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("draw status = %d, want 201: %s", w.Code, w.Body.String())
+	}
+
+	var resp struct {
+		Data struct {
+			DrawID string `json:"draw_id"`
+			Cards  []int  `json:"cards"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || resp.Data.DrawID == "" || len(resp.Data.Cards) != count {
+		t.Fatalf("draw response = %s, %v", w.Body.String(), err)
+	}
+	return resp.Data.DrawID, resp.Data.Cards
+}
+
+func TestStoreAcceptsMatchingDraw(t *testing.T) {
+	router := newDrawRouter(t)
+	drawID, cards := postDraw(t, router, "user-1", 3)
+
+	w := postReadingWith(router, "user-1", readingBody("user-1", map[string]interface{}{"cards": cards, "draw_id": drawID}))
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("status = %d, want 202: %s", w.Code, w.Body.String())
+	}
+	if n := countReadings(t, "user-1"); n != 1 {
+		t.Fatalf("readings = %d, want 1", n)
+	}
+
+	// 抽牌记录只能使用一次
+	w = postReadingWith(router, "user-1", readingBody("user-1", map[string]interface{}{"cards": cards, "draw_id": drawID}))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("reused draw status = %d, want 400: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestStoreRejectsMismatchedDraw(t *testing.T) {
+	router := newDrawRouter(t)
+	drawID, cards := postDraw(t, router, "user-1", 3)
+	tampered := []int{cards[2], cards[1], cards[0]}
+
+	w := postReadingWith(router, "user-1", readingBody("user-1", map[string]interface{}{"cards": tampered, "draw_id": drawID}))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400: %s", w.Code, w.Body.String())
+	}
+	if n := countReadings(t, "user-1"); n != 0 {
+		t.Fatalf("created %d readings from tampered cards", n)
+	}
+}
+
+func TestStoreRejectsMissingOrForeignDraw(t *testing.T) {
+	router := newDrawRouter(t)
+	drawID, cards := postDraw(t, router, "user-2", 3)
+
+	for name, extra := range map[string]map[string]interface{}{
+		"missing draw": {"cards": cards},
+		"foreign draw": {"cards": cards, "draw_id": drawID},
+	} {
+		w := postReadingWith(router, "user-1", readingBody("user-1", extra))
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("%s: status = %d, want 400: %s", name, w.Code, w.Body.String())
+		}
+	}
+	if n := countReadings(t, "user-1"); n != 0 {
+		t.Fatalf("created %d readings without a valid draw", n)
+	}
+}
+
+func TestStoreWithoutRequiredDraw(t *testing.T) {
+	router := newReadingRouter(t)
+	testutil.SetConfig(t, "tarot.require_draw", false)
+
+	if w := postReading(router, "user-1", "user-1"); w.Code != http.StatusAccepted {
+		t.Fatalf("status = %d, want 202 with tarot.require_draw disabled: %s", w.Code, w.Body.String())
+	}
+}
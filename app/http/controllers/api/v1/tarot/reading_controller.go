@@ -1,43 +1,47 @@
 package tarot
 
 import (
-	"log"
+	"context"
+	"errors"
+	"io"
 	"strconv"
+	"strings"
+	"unicode/utf8"
 	"time"
-	"math/rand"
+	"net/http"
 	"fmt"
-	"strings"
 	
 	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
 	
 	"tarot/app/requests"
 	"tarot/pkg/dify"
+	"tarot/pkg/helpers"
 	"tarot/pkg/queue"
 	"tarot/pkg/response"
 	"tarot/app/repositories"
 	"tarot/app/models/reading"
+	"tarot/app/models/spread"
 	"tarot/pkg/redis"
 	"tarot/pkg/logger"
+	"tarot/pkg/tracing"
 	"tarot/pkg/config"
+	"tarot/pkg/moderation"
+	"tarot/pkg/tarot"
 )
 
 type ReadingController struct {
 	queueService *queue.QueueService
 	difyService  *dify.DifyService
+	random       helpers.RandomSource // 生成任务 ID 的随机数来源
 }
 
 func NewReadingController() *ReadingController {
-	// 创建 Dify 配置
-	difyConfig := &dify.Config{
-		URLs:       strings.Split(config.GetString("dify.urls"), ","),
-		APIKeys:    strings.Split(config.GetString("dify.api_keys"), ","),
-		Timeout:    time.Duration(config.GetInt("dify.timeout")) * time.Second,
-		MaxRetries: config.GetInt("dify.max_retries"),
-	}
-
 	return &ReadingController{
 		queueService: queue.NewQueueService(),
-		difyService:  dify.NewDifyService(difyConfig),
+		difyService:  dify.Service,
+		random:       helpers.CryptoRandom,
 	}
 }
 
@@ -50,8 +54,29 @@ func (rc *ReadingController) Store(c *gin.Context) {
 		return
 	}
 	
+	// 已登录用户只能以自己的身份创建解读
+	if !bindRequestUser(c, &request.UserID) {
+		return
+	}
+	
+	// 问题内容审核，未通过时不提交给 AI
+	if !rc.moderateQuestion(c, request.Question) {
+		return
+	}
+	
+	// 卡牌必须是服务端为该用户抽出的牌
+	if !rc.verifyDraw(c, request) {
+		return
+	}
+	
+	// 未登录的请求视为游客，占用一次免费测算次数
+	guestID, ok := rc.consumeGuestReading(c, request.UserID)
+	if !ok {
+		return
+	}
+	
 	// 2. 生成唯一的 task_id
-	taskID := generateTaskID()
+	taskID := generateTaskID(rc.random)
 	
 	// 3. 创建塔罗牌阅读记录
 	readingRecord := &reading.Reading{
@@ -60,59 +85,222 @@ func (rc *ReadingController) Store(c *gin.Context) {
 		Question: request.Question,
 		Cards:    reading.Cards(request.Cards),
 		Type:     request.Type,
+		SpreadID: request.SpreadID,
 		Status:   string(reading.StatusPending),
 	}
 	
-	// 4. 保存到数据库
-	if err := readingRecord.Create(); err != nil {
-		log.Printf("创建塔罗牌阅读失败: %v", err)
-		response.Abort500(c, "创建塔罗牌阅读失败")
+	// 4. 创建队列任务
+	task := &queue.TarotTask{
+		ID:           taskID,
+		UserID:       request.UserID,
+		Question:     request.Question,
+		Type:         string(request.Type),
+		SpreadID:     request.SpreadID,
+		Cards:        request.Cards,
+		Status:       queue.TaskPending,
+		CreatedAt:    time.Now(),
+		ResponseMode: request.ResponseMode,
+		RequestID:    c.GetString("request_id"),
+		CallbackURL:  request.CallbackURL,
+	}
+	
+	if request.Spread != nil {
+		task.Spread = request.Spread.PromptText()
+	}
+	
+	// 5. 保存记录并推送到队列
+	if !rc.enqueueReading(c, readingRecord, task, guestID) {
 		return
 	}
 	
-	// 5. 创建队列任务
-	task := &queue.TarotTask{
-		ID:        taskID,
-		UserID:    request.UserID,
-		Question:  request.Question,
-		Cards:     request.Cards,
-		Status:    queue.TaskPending,
-		CreatedAt: time.Now(),
+	rc.accepted(c, newReadingCreated(readingRecord, request.ResponseMode), "塔罗牌阅读创建成功")
+}
+
+// enqueueReading 保存解读记录并推送队列任务
+// 失败时归还游客次数并写入响应，返回 false
+func (rc *ReadingController) enqueueReading(c *gin.Context, readingRecord *reading.Reading, task *queue.TarotTask, guestID string) bool {
+	ctx, span := tracing.Start(c.Request.Context(), "db.reading.create", tracing.KindInternal)
+	err := readingRecord.CreateCtx(ctx)
+	span.RecordError(err)
+	span.End()
+	if err != nil {
+		logger.ErrorContext(c.Request.Context(), "Reading", "Create", zap.Error(err))
+		rc.releaseGuestReading(c, guestID)
+		response.Abort500(c, "创建塔罗牌阅读失败")
+		return false
 	}
 	
-	// 6. 推送到队列
 	if err := rc.queueService.PushTask(c.Request.Context(), task); err != nil {
-		logger.ErrorString("Reading", "Queue", fmt.Sprintf("推送任务失败: %v", err))
-		// 更新记录状态为错误
+		logger.ErrorContext(c.Request.Context(), "Reading", "Queue", zap.String("task_id", task.ID), zap.Error(err))
+		// 更新记录状态为错误，推送可能因请求取消而失败，更新不随请求取消
 		readingRecord.Status = string(reading.StatusFailed)
-		if updateErr := readingRecord.Save(); updateErr != nil {
-			log.Printf("更新状态失败: %v", updateErr)
+		if updateErr := readingRecord.SaveCtx(context.WithoutCancel(c.Request.Context())); updateErr != nil {
+			logger.ErrorContext(c.Request.Context(), "Reading", "MarkFailed", zap.String("task_id", task.ID), zap.Error(updateErr))
+		}
+		rc.releaseGuestReading(c, guestID)
+		if errors.Is(err, queue.ErrQueueFull) {
+			response.Fail(c, http.StatusServiceUnavailable, response.CodeQueueFull, "当前请求较多，请稍后再试")
+			return false
 		}
 		response.Abort500(c, "推送任务失败")
+		return false
+	}
+	return true
+}
+
+// moderateQuestion 审核问题内容，拦截时写入 422 响应并返回 false
+// 审核服务不可用时放行，避免影响正常测算
+func (rc *ReadingController) moderateQuestion(c *gin.Context, question string) bool {
+	verdict, err := moderation.Default.Check(c.Request.Context(), question)
+	if err != nil {
+		logger.WarnString("Reading", "Moderation", err.Error())
+		return true
+	}
+	if verdict.Blocked {
+		logger.WarnString("Reading", "Moderation", fmt.Sprintf("问题未通过审核: %s", verdict.Reason))
+		response.FailWithData(c, http.StatusUnprocessableEntity, response.CodeContentBlocked, "问题包含不允许的内容，请修改后重试", map[string][]string{
+			"question": {"问题包含不允许的内容，请修改后重试"},
+		})
+		return false
+	}
+	return true
+}
+
+// consumeGuestReading 未登录时校验游客并占用一次免费测算次数
+// 已登录时直接通过；返回的 guestID 用于创建失败时归还次数，ok 为 false 时已写入响应
+func (rc *ReadingController) consumeGuestReading(c *gin.Context, guestID string) (string, bool) {
+	if c.GetString("user_id") != "" {
+		return "", true
+	}
+
+	err := repositories.NewGuestRepository().ConsumeReading(c.Request.Context(), guestID)
+	switch {
+	case err == nil:
+		return guestID, true
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		response.Abort403(c, "游客不存在，请先创建游客会话")
+	case errors.Is(err, repositories.ErrGuestReadingLimit):
+		response.Fail(c, http.StatusForbidden, response.CodeGuestLimitReached, "免费测算次数已用完，请登录后继续")
+	default:
+		logger.ErrorStringContext(c.Request.Context(), "Reading", "Guest", err.Error())
+		response.Abort500(c, "校验游客失败")
+	}
+	return "", false
+}
+
+// verifyDraw 开启 tarot.require_draw 时校验请求引用的抽牌记录，记录校验后即失效，不能重复使用
+// 未开启时直接通过，兼容由客户端抽牌的旧版本
+func (rc *ReadingController) verifyDraw(c *gin.Context, request *requests.TarotReadingRequest) bool {
+	if !config.GetBool("tarot.require_draw") {
+		return true
+	}
+	if request.DrawID == "" {
+		response.Abort400(c, "请先抽牌")
+		return false
+	}
+
+	draw, err := tarot.TakeDraw(c.Request.Context(), request.UserID, request.DrawID)
+	switch {
+	case errors.Is(err, tarot.ErrDrawNotFound):
+		response.Abort400(c, "抽牌记录不存在或已过期，请重新抽牌")
+		return false
+	case err != nil:
+		logger.ErrorStringContext(c.Request.Context(), "Reading", "Draw", err.Error())
+		response.Abort500(c, "校验抽牌记录失败")
+		return false
+	case !draw.Matches(request.Cards):
+		response.Abort400(c, "卡牌与抽牌结果不一致")
+		return false
+	}
+	return true
+}
+
+// bindRequestUser 校验请求体中的用户 ID
+// 已登录时请求体的 user_id 只能为空或与当前用户一致，为空时填入当前用户，不一致时写入 403 响应并返回 false；
+// 游客没有登录身份，请求体中的 user_id 即游客 ID
+func bindRequestUser(c *gin.Context, userID *string) bool {
+	current := c.GetString("user_id")
+	if current == "" {
+		return true
+	}
+	if *userID != "" && *userID != current {
+		response.Abort403(c, "不能以其他用户的身份发起请求")
+		return false
+	}
+	*userID = current
+	return true
+}
+
+// releaseGuestReading 解读创建失败时归还游客的测算次数
+func (rc *ReadingController) releaseGuestReading(c *gin.Context, guestID string) {
+	if guestID == "" {
 		return
 	}
-	
-	response.Created(c, readingRecord, "塔罗牌阅读创建成功")
+	if err := repositories.NewGuestRepository().ReleaseReading(c.Request.Context(), guestID); err != nil {
+		logger.ErrorStringContext(c.Request.Context(), "Reading", "Guest", err.Error())
+	}
+}
+
+// readingCreated 创建解读的响应，附带结果的获取方式
+type readingCreated struct {
+	*reading.Reading
+	ResponseMode string `json:"response_mode"`
+	Delivery     string `json:"delivery"`   // polling：轮询 result_url；sse：订阅 stream_url
+	ResultURL    string `json:"result_url"`
+	StreamURL    string `json:"stream_url,omitempty"`
+}
+
+// newReadingCreated 根据响应模式确定结果的获取方式
+func newReadingCreated(r *reading.Reading, mode string) readingCreated {
+	resp := readingCreated{
+		Reading:      r,
+		ResponseMode: mode,
+		Delivery:     "polling",
+		ResultURL:    "/v1/tarot/readings/" + r.TaskID,
+	}
+	if mode == dify.ResponseModeStreaming {
+		resp.Delivery = "sse"
+		resp.StreamURL = resp.ResultURL + "/stream"
+	}
+	return resp
+}
+
+// accepted 解读已入队，返回 202 并在 Location 中给出结果的轮询地址
+// 解读结果尚未生成，不使用表示已完成的 201
+func (rc *ReadingController) accepted(c *gin.Context, resp readingCreated, msg string) {
+	response.Accepted(c, resp.ResultURL, resp, msg)
 }
 
 // generateTaskID 生成唯一的任务ID
-func generateTaskID() string {
+func generateTaskID(random helpers.RandomSource) string {
 	// 格式: task_时间戳_随机数
 	timestamp := time.Now().UnixNano() / 1e6 // 毫秒时间戳
-	random := rand.Intn(10000)               // 随机数
-	return fmt.Sprintf("task_%d_%04d", timestamp, random)
+	return fmt.Sprintf("task_%d_%04d", timestamp, random.Intn(10000))
 }
 
-// GetResult 获取解读结果
+// GetResult 获取解读结果，游客需携带 ?user_id=<guest_id>
+// 携带 ?wait=N 时最多等待 N 秒（不超过 tarot.result_max_wait）直到任务结束，供无法使用 SSE 的客户端长轮询
 func (rc *ReadingController) GetResult(c *gin.Context) {
 	taskID := c.Param("id")
 	if taskID == "" {
 		response.Abort400(c, "缺少任务 ID")
 		return
 	}
+	record, ok := rc.ownedReading(c, taskID)
+	if !ok {
+		return
+	}
 
-	// 获取任务进度
-	progress, err := rc.queueService.GetTaskProgress(c.Request.Context(), taskID)
+	// 获取任务进度；携带 wait 参数时长轮询，直到任务结束或等待超时
+	var progress *queue.TaskProgress
+	var err error
+	if wait, ok := c.GetQuery("wait"); ok {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), resultWaitDuration(wait))
+		progress, err = rc.queueService.WaitTaskProgress(ctx, taskID, resultPollInterval)
+		cancel()
+	} else {
+		progress, err = rc.queueService.GetTaskProgress(c.Request.Context(), taskID)
+	}
 	if err != nil {
 		response.Abort500(c, "获取任务进度失败")
 		return
@@ -124,7 +312,7 @@ func (rc *ReadingController) GetResult(c *gin.Context) {
 	}
 
 	// 如果任务未完成，返回进度信息
-	if progress.Status != queue.TaskCompleted {
+	if progress.Status != queue.TaskCompleted && progress.Status != queue.TaskFallback {
 		response.Data(c, gin.H{
 			"task_id": taskID,
 			"status":  progress.Status,
@@ -133,20 +321,190 @@ func (rc *ReadingController) GetResult(c *gin.Context) {
 		return
 	}
 
-	response.Data(c, gin.H{
-		"task_id": taskID,
-		"status":  progress.Status,
-		"result":  progress.Result,
+	response.Data(c, resultPayload(record, progress))
+}
+
+// requestOwner 当前请求者的用户 ID，无法确定时返回空字符串
+// 已登录时以当前用户为准；未登录时以 ?user_id 为游客 ID，且必须是有效游客，不能借此读取注册用户的记录
+func (rc *ReadingController) requestOwner(c *gin.Context) string {
+	if userID := c.GetString("user_id"); userID != "" {
+		return userID
+	}
+	guestID := c.Query("user_id")
+	if guestID == "" {
+		return ""
+	}
+	if _, err := repositories.NewGuestRepository().GetByID(c.Request.Context(), guestID); err != nil {
+		return ""
+	}
+	return guestID
+}
+
+// ownedReading 加载当前请求者名下的解读记录，ok 为 false 时已写入响应
+// 无权访问与记录不存在同样返回 404，不暴露任务是否存在
+func (rc *ReadingController) ownedReading(c *gin.Context, taskID string) (*reading.Reading, bool) {
+	ctx := c.Request.Context()
+	ownerID := rc.requestOwner(c)
+	if ownerID == "" {
+		response.Abort404(c, "任务不存在")
+		return nil, false
+	}
+
+	record, err := repositories.NewReadingRepository().GetByTaskID(ctx, ownerID, taskID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		response.Abort404(c, "任务不存在")
+		return nil, false
+	}
+	if err != nil {
+		logger.ErrorStringContext(ctx, "Reading", "Result", err.Error())
+		response.Abort500(c, "获取解读记录失败")
+		return nil, false
+	}
+	return record, true
+}
+
+// resultPayload 构建解读结果，GetResult 与 Stream 的 done 事件共用
+// 未解锁的付费解读只返回预览，fallback 表示 Dify 不可用时的兜底结果，客户端可据此区别展示
+// cards 为抽到的卡牌目录信息（含图片地址），客户端无需自行拼接
+func resultPayload(record *reading.Reading, progress *queue.TaskProgress) gin.H {
+	r := *record
+	r.Interpretation = progress.Result
+	r.HideLockedInterpretation()
+	return gin.H{
+		"task_id":  record.TaskID,
+		"status":   progress.Status,
+		"result":   r.Interpretation,
+		"fallback": progress.Status == queue.TaskFallback,
+		"locked":   r.IsLocked(),
+		"cards":    record.CardDetails,
+	}
+}
+
+// resultPollInterval 长轮询时查询任务状态的间隔
+const resultPollInterval = 500 * time.Millisecond
+
+// resultWaitDuration 解析 wait 参数（秒），不超过 tarot.result_max_wait
+// 参数为空或无法解析时按最长等待时间处理
+func resultWaitDuration(wait string) time.Duration {
+	maxWait := config.GetInt("tarot.result_max_wait", 25)
+	seconds, err := strconv.Atoi(wait)
+	if err != nil || seconds <= 0 || seconds > maxWait {
+		seconds = maxWait
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// Stream 以 SSE 推送解读结果
+// status 事件为任务状态变化（连接建立时先推送当前状态），
+// chunk 事件为 Dify 流式返回的文本片段，done 事件携带最终结果；
+// 任务失败重试时片段可能重复，客户端应以 done 事件中的结果为准。
+// 连接超过 tarot.stream_max_duration 秒仍未结束时推送 timeout 事件并关闭，客户端可重新连接。
+// 游客需携带 ?user_id=<guest_id>；未解锁的付费解读不推送 chunk 事件，done 事件只包含预览
+func (rc *ReadingController) Stream(c *gin.Context) {
+	taskID := c.Param("id")
+	if taskID == "" {
+		response.Abort400(c, "缺少任务 ID")
+		return
+	}
+	record, ok := rc.ownedReading(c, taskID)
+	if !ok {
+		return
+	}
+
+	maxDuration := time.Duration(config.GetInt("tarot.stream_max_duration", 300)) * time.Second
+	ctx, cancel := context.WithTimeout(c.Request.Context(), maxDuration)
+	defer cancel()
+
+	// 先订阅再查询状态，避免两者之间任务结束导致错过 done 事件
+	sub, err := rc.queueService.SubscribeStream(ctx, taskID)
+	if err != nil {
+		logger.ErrorString("Reading", "Stream", err.Error())
+		response.Abort500(c, "订阅解读结果失败")
+		return
+	}
+	defer sub.Close()
+
+	progress, err := rc.queueService.GetTaskProgress(ctx, taskID)
+	if err != nil {
+		response.Abort500(c, "获取任务进度失败")
+		return
+	}
+	if progress == nil || progress.Status == "" {
+		response.Abort404(c, "任务不存在")
+		return
+	}
+
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+
+	if progress.Status.IsTerminal() {
+		c.SSEvent(queue.StreamEventStatus, gin.H{"status": progress.Status})
+		c.SSEvent(queue.StreamEventDone, resultPayload(record, progress))
+		return
+	}
+	c.SSEvent(queue.StreamEventStatus, gin.H{"status": progress.Status})
+	// c.Stream 只在每轮事件之后刷新，立即发出当前状态，避免客户端等到第一个心跳才收到响应
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(15 * time.Second)
+	defer heartbeat.Stop()
+
+	messages := sub.Channel()
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case <-ctx.Done():
+			// 客户端断开时无需写出；超过最长连接时间时通知客户端
+			if c.Request.Context().Err() == nil {
+				c.SSEvent("timeout", gin.H{"status": progress.Status})
+			}
+			return false
+		case <-heartbeat.C:
+			c.SSEvent("ping", "")
+			return true
+		case msg, ok := <-messages:
+			if !ok {
+				return false
+			}
+			m, err := queue.ParseStreamMessage(msg)
+			if err != nil {
+				logger.WarnString("Reading", "Stream", err.Error())
+				return true
+			}
+			switch m.Event {
+			case queue.StreamEventStatus:
+				progress.Status = m.Status
+				c.SSEvent(queue.StreamEventStatus, gin.H{"status": m.Status})
+				return true
+			case queue.StreamEventChunk:
+				if !record.IsLocked() {
+					c.SSEvent(queue.StreamEventChunk, gin.H{"text": m.Text})
+				}
+				return true
+			}
+
+			// 任务结束，从 Redis 读取完整结果
+			final, err := rc.queueService.GetTaskProgress(ctx, taskID)
+			if err != nil {
+				c.SSEvent("error", gin.H{"message": "获取任务结果失败"})
+				return false
+			}
+			c.SSEvent(queue.StreamEventStatus, gin.H{"status": final.Status})
+			c.SSEvent(queue.StreamEventDone, resultPayload(record, final))
+			return false
+		}
 	})
 }
 
-// GetStatus 获取任务状态
+// GetStatus 获取任务状态，游客需携带 ?user_id=<guest_id>
 func (rc *ReadingController) GetStatus(c *gin.Context) {
 	taskID := c.Param("id")
 	if taskID == "" {
 		response.Abort400(c, "缺少任务 ID")
 		return
 	}
+	if _, ok := rc.ownedReading(c, taskID); !ok {
+		return
+	}
 
 	status, err := rc.queueService.GetTaskStatus(c.Request.Context(), taskID)
 	if err != nil {
@@ -165,6 +523,47 @@ func (rc *ReadingController) GetStatus(c *gin.Context) {
 	})
 }
 
+// BatchStatus 批量获取任务状态，游客需携带 ?user_id=<guest_id>
+// 返回任务 ID 到状态的映射，不存在或不属于当前请求者的任务状态为 not_found
+func (rc *ReadingController) BatchStatus(c *gin.Context) {
+	request, err := requests.ValidateTaskStatusBatch(c)
+	if err != nil {
+		response.BadRequest(c, err, "请求验证失败")
+		return
+	}
+
+	ctx := c.Request.Context()
+	var owned []string
+	if ownerID := rc.requestOwner(c); ownerID != "" {
+		owned, err = repositories.NewReadingRepository().OwnedTaskIDs(ctx, ownerID, request.TaskIDs)
+		if err != nil {
+			logger.ErrorStringContext(ctx, "Reading", "BatchStatus", err.Error())
+			response.Abort500(c, "获取任务状态失败")
+			return
+		}
+	}
+
+	statuses, err := rc.queueService.GetTaskStatuses(ctx, owned)
+	if err != nil {
+		logger.ErrorStringContext(ctx, "Reading", "BatchStatus", err.Error())
+		response.Abort500(c, "获取任务状态失败")
+		return
+	}
+
+	result := make(map[string]string, len(request.TaskIDs))
+	for _, id := range request.TaskIDs {
+		if status, ok := statuses[id]; ok {
+			result[id] = string(status)
+		} else {
+			result[id] = "not_found"
+		}
+	}
+
+	response.Data(c, gin.H{
+		"statuses": result,
+	})
+}
+
 // HealthCheck 健康检查端点
 func (rc *ReadingController) HealthCheck(c *gin.Context) {
 	// 检查 Redis 连接
@@ -185,7 +584,7 @@ func (rc *ReadingController) HealthCheck(c *gin.Context) {
 	})
 }
 
-// GetHistory 获取用户历史记录
+// GetHistory 获取用户历史记录，只允许本人访问
 func (rc *ReadingController) GetHistory(c *gin.Context) {
 	// 获取分页参数
 	page := c.DefaultQuery("page", "1")
@@ -207,14 +606,29 @@ func (rc *ReadingController) GetHistory(c *gin.Context) {
 		response.Abort400(c, "用户ID不能为空")
 		return
 	}
+	// 只能查看（搜索）自己的历史记录
+	if userID != c.GetString("user_id") {
+		response.Abort403(c)
+		return
+	}
+	
+	// 按问题内容搜索
+	keyword := strings.TrimSpace(c.Query("q"))
+	if utf8.RuneCountInString(keyword) > maxHistoryKeywordLength {
+		response.Abort400(c, fmt.Sprintf("搜索内容不能超过 %d 个字符", maxHistoryKeywordLength))
+		return
+	}
 	
 	// 获取历史记录
 	repo := repositories.NewReadingRepository()
-	readings, total, err := repo.GetByUserID(c.Request.Context(), userID, pageNum, size)
+	readings, total, err := repo.GetByUserID(c.Request.Context(), userID, keyword, pageNum, size)
 	if err != nil {
 		response.Abort500(c, "获取历史记录失败")
 		return
 	}
+	for i := range readings {
+		readings[i].HideLockedInterpretation()
+	}
 	
 	response.Data(c, gin.H{
 		"data": readings,
@@ -226,7 +640,10 @@ func (rc *ReadingController) GetHistory(c *gin.Context) {
 	})
 }
 
-// GetReadingDetail 获取单次测算结果
+// maxHistoryKeywordLength 历史记录搜索内容的最大字符数
+const maxHistoryKeywordLength = 100
+
+// GetReadingDetail 获取单次测算结果，只允许本人访问
 func (rc *ReadingController) GetReadingDetail(c *gin.Context) {
 	userID := c.Param("user_id")
 	taskID := c.Param("task_id")
@@ -235,6 +652,10 @@ func (rc *ReadingController) GetReadingDetail(c *gin.Context) {
 		response.Abort400(c, "参数不完整")
 		return
 	}
+	if userID != c.GetString("user_id") {
+		response.Abort403(c)
+		return
+	}
 	
 	// 获取测算结果
 	repo := repositories.NewReadingRepository()
@@ -244,29 +665,278 @@ func (rc *ReadingController) GetReadingDetail(c *gin.Context) {
 		return
 	}
 	
+	// 付费解读未支付时仅返回预览
+	reading.HideLockedInterpretation()
 	response.Data(c, reading)
 }
 
+// Export 导出解读记录，用于保存或打印
+// GET /v1/users/:user_id/readings/:task_id/export?format=md
+// 未解锁的付费解读不允许导出；PDF 导出暂不支持
+func (rc *ReadingController) Export(c *gin.Context) {
+	userID := c.Param("user_id")
+	if userID == "" || userID != c.GetString("user_id") {
+		response.Abort403(c)
+		return
+	}
+
+	format := c.DefaultQuery("format", "md")
+	if format != "md" {
+		response.Abort400(c, "暂不支持的导出格式，目前仅支持 md")
+		return
+	}
+
+	record, err := repositories.NewReadingRepository().GetByTaskID(c.Request.Context(), userID, c.Param("task_id"))
+	if err != nil {
+		response.Abort404(c, "记录不存在")
+		return
+	}
+	if !record.IsCompleted() {
+		response.Abort400(c, "解读尚未完成")
+		return
+	}
+	if record.IsLocked() {
+		response.Fail(c, http.StatusPaymentRequired, response.CodePaymentRequired, "付费解读解锁后才能导出")
+		return
+	}
+
+	var positions []string
+	if s, ok := spread.Find(record.SpreadID); ok {
+		positions = s.Positions
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="reading-%s.md"`, record.TaskID))
+	c.Data(http.StatusOK, "text/markdown; charset=utf-8", []byte(record.Markdown(positions)))
+}
+
+// FollowUp 对已完成的解读追问
+// POST /v1/users/:user_id/readings/:task_id/follow-up
+// 新解读沿用原解读的卡牌、类型与牌阵，并延续原解读的 Dify 对话；需 Dify 使用对话应用
+func (rc *ReadingController) FollowUp(c *gin.Context) {
+	userID := c.Param("user_id")
+	if userID == "" || userID != c.GetString("user_id") {
+		response.Abort403(c)
+		return
+	}
+	if rc.difyService == nil || !rc.difyService.SupportsConversation() {
+		response.Abort400(c, "当前未启用追问")
+		return
+	}
+
+	request, err := requests.ValidateFollowUp(c)
+	if err != nil {
+		response.BadRequest(c, err, "请求验证失败")
+		return
+	}
+
+	repo := repositories.NewReadingRepository()
+	parent, err := repo.GetByTaskID(c.Request.Context(), userID, c.Param("task_id"))
+	if err != nil {
+		response.Abort404(c, "记录不存在")
+		return
+	}
+	if parent.IsLocked() {
+		response.Fail(c, http.StatusPaymentRequired, response.CodePaymentRequired, "付费解读解锁后才能追问")
+		return
+	}
+
+	conversationID, err := rc.conversationOf(c.Request.Context(), repo, parent)
+	if err != nil {
+		logger.ErrorStringContext(c.Request.Context(), "Reading", "FollowUp", err.Error())
+		response.Abort500(c, "获取对话失败")
+		return
+	}
+	if conversationID == "" {
+		response.Abort400(c, "原解读尚未完成，暂不能追问")
+		return
+	}
+
+	if !rc.moderateQuestion(c, request.Question) {
+		return
+	}
+
+	taskID := generateTaskID(rc.random)
+	readingRecord := &reading.Reading{
+		TaskID:         taskID,
+		UserID:         userID,
+		Question:       request.Question,
+		Cards:          parent.Cards,
+		Type:           parent.Type,
+		SpreadID:       parent.SpreadID,
+		Status:         string(reading.StatusPending),
+		ConversationID: conversationID,
+		ParentTaskID:   parent.TaskID,
+	}
+
+	task := &queue.TarotTask{
+		ID:             taskID,
+		UserID:         userID,
+		Question:       request.Question,
+		Type:           string(parent.Type),
+		SpreadID:       parent.SpreadID,
+		Cards:          []int(parent.Cards),
+		Status:         queue.TaskPending,
+		CreatedAt:      time.Now(),
+		ResponseMode:   request.ResponseMode,
+		RequestID:      c.GetString("request_id"),
+		CallbackURL:    request.CallbackURL,
+		ConversationID: conversationID,
+	}
+	if s, ok := spread.Find(parent.SpreadID); ok {
+		task.Spread = s.PromptText()
+	}
+
+	if !rc.enqueueReading(c, readingRecord, task, "") {
+		return
+	}
+
+	rc.accepted(c, newReadingCreated(readingRecord, request.ResponseMode), "追问创建成功")
+}
+
+// Retry 重新解读失败的解读
+// POST /v1/users/:user_id/readings/:task_id/retry
+// 新解读沿用原解读的问题、卡牌、类型与牌阵，并通过 retry_of_task_id 关联原解读；每次失败的解读只能重试一次
+func (rc *ReadingController) Retry(c *gin.Context) {
+	userID := c.Param("user_id")
+	if userID == "" || userID != c.GetString("user_id") {
+		response.Abort403(c)
+		return
+	}
+
+	ctx := c.Request.Context()
+	repo := repositories.NewReadingRepository()
+	original, err := repo.GetByTaskID(ctx, userID, c.Param("task_id"))
+	if err != nil {
+		response.Abort404(c, "记录不存在")
+		return
+	}
+
+	failed, err := rc.isFailed(ctx, repo, original)
+	if err != nil {
+		logger.ErrorStringContext(ctx, "Reading", "Retry", err.Error())
+		response.Abort500(c, "获取解读状态失败")
+		return
+	}
+	if !failed {
+		response.Abort400(c, "只有失败的解读可以重试")
+		return
+	}
+
+	retried, err := repo.HasRetry(ctx, original.TaskID)
+	if err != nil {
+		logger.ErrorStringContext(ctx, "Reading", "Retry", err.Error())
+		response.Abort500(c, "获取解读状态失败")
+		return
+	}
+	if retried {
+		response.Fail(c, http.StatusConflict, response.CodeConflict, "该解读已重试过")
+		return
+	}
+
+	taskID := generateTaskID(rc.random)
+	readingRecord := &reading.Reading{
+		TaskID:         taskID,
+		UserID:         userID,
+		Question:       original.Question,
+		Cards:          original.Cards,
+		Type:           original.Type,
+		SpreadID:       original.SpreadID,
+		Status:         string(reading.StatusPending),
+		Unlocked:       original.Unlocked,
+		UnlockedAt:     original.UnlockedAt,
+		ConversationID: original.ConversationID,
+		ParentTaskID:   original.ParentTaskID,
+		RetryOfTaskID:  original.TaskID,
+	}
+
+	task := &queue.TarotTask{
+		ID:             taskID,
+		UserID:         userID,
+		Question:       original.Question,
+		Type:           string(original.Type),
+		SpreadID:       original.SpreadID,
+		Cards:          []int(original.Cards),
+		Status:         queue.TaskPending,
+		CreatedAt:      time.Now(),
+		RequestID:      c.GetString("request_id"),
+		ConversationID: original.ConversationID,
+	}
+	if s, ok := spread.Find(original.SpreadID); ok {
+		task.Spread = s.PromptText()
+	}
+
+	if !rc.enqueueReading(c, readingRecord, task, "") {
+		return
+	}
+
+	// 重新解读的任务沿用服务默认的响应模式；Dify 未初始化时任务仍已入队，按轮询返回
+	mode := ""
+	if rc.difyService != nil {
+		mode = rc.difyService.ResponseMode()
+	}
+	rc.accepted(c, newReadingCreated(readingRecord, mode), "重新解读创建成功")
+}
+
+// isFailed 解读是否失败
+// 工作器写入结果之前的任务以 Redis 中的状态为准，状态为失败时同步到解读记录
+func (rc *ReadingController) isFailed(ctx context.Context, repo *repositories.ReadingRepository, r *reading.Reading) (bool, error) {
+	if r.IsFailed() {
+		return true, nil
+	}
+	if r.IsCompleted() {
+		return false, nil
+	}
+
+	status, err := rc.queueService.GetTaskStatus(ctx, r.TaskID)
+	if err != nil || status != queue.TaskFailed {
+		return false, err
+	}
+	if err := repo.SetStatus(ctx, r.TaskID, reading.StatusFailed); err != nil {
+		return false, err
+	}
+	r.Status = string(reading.StatusFailed)
+	return true, nil
+}
+
+// conversationOf 获取解读所属的 Dify 对话 ID
+// 工作器只把对话 ID 写入 Redis，首次读取时落库，Redis 过期后仍可追问
+func (rc *ReadingController) conversationOf(ctx context.Context, repo *repositories.ReadingRepository, r *reading.Reading) (string, error) {
+	if r.ConversationID != "" {
+		return r.ConversationID, nil
+	}
+
+	conversationID, err := rc.queueService.GetTaskConversation(ctx, r.TaskID)
+	if err != nil || conversationID == "" {
+		return "", err
+	}
+	if err := repo.SetConversationID(ctx, r.TaskID, conversationID); err != nil {
+		return "", err
+	}
+	r.ConversationID = conversationID
+	return conversationID, nil
+}
+
 // CheckRedisHealth Redis 健康检查
+// 读取后台监控记录的状态，不在请求中直接 Ping
 func (rc *ReadingController) CheckRedisHealth(c *gin.Context) {
 	// 检查主 Redis 实例
-	mainRedis := redis.GetRedis(redis.MainDB)
-	if err := mainRedis.Ping(); err != nil {
-		response.JSON(c, gin.H{
-			"status": "error",
+	mainStatus := redis.GetRedis(redis.MainDB).Status()
+	if !mainStatus.Healthy {
+		response.FailWithData(c, http.StatusServiceUnavailable, response.CodeServiceUnavailable, "Redis 不可用", gin.H{
 			"main_db": "unavailable",
-			"error": err.Error(),
+			"error": mainStatus.LastError,
+			"since": mainStatus.Since.Unix(),
 		})
 		return
 	}
 	
 	// 检查队列 Redis 实例
-	queueRedis := redis.GetRedis(redis.QueueDB)
-	if err := queueRedis.Ping(); err != nil {
-		response.JSON(c, gin.H{
-			"status": "error",
+	queueStatus := redis.GetRedis(redis.QueueDB).Status()
+	if !queueStatus.Healthy {
+		response.FailWithData(c, http.StatusServiceUnavailable, response.CodeServiceUnavailable, "Redis 不可用", gin.H{
 			"queue_db": "unavailable",
-			"error": err.Error(),
+			"error": queueStatus.LastError,
+			"since": queueStatus.Since.Unix(),
 		})
 		return
 	}
@@ -275,6 +945,7 @@ func (rc *ReadingController) CheckRedisHealth(c *gin.Context) {
 		"status": "ok",
 		"main_db": "available",
 		"queue_db": "available",
+		"last_check": mainStatus.LastCheck.Unix(),
 		"time": time.Now().Unix(),
 	})
 } 
\ No newline at end of file
@@ -0,0 +1,1150 @@
+package tarot
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"tarot/app/http/middlewares"
+	"tarot/app/models/guest"
+	"tarot/app/models/reading"
+	"tarot/app/models/spread"
+	"tarot/app/requests"
+	"tarot/pkg/database"
+	"tarot/pkg/dify"
+	"tarot/pkg/moderation"
+	"tarot/pkg/queue"
+	"tarot/pkg/testutil"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// newReadingRouter 创建解读接口路由，X-Test-User 头模拟 CurrentUser 解析出的登录用户
+func newReadingRouter(t *testing.T) *gin.Engine {
+	t.Helper()
+	testutil.SetupDB(t, &reading.Reading{}, &guest.Guest{})
+	testutil.SetupRedis(t)
+
+	rc := NewReadingController()
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		if userID := c.GetHeader("X-Test-User"); userID != "" {
+			c.Set("user_id", userID)
+		}
+		c.Next()
+	})
+	router.POST("/readings", rc.Store)
+	router.POST("/readings/status", rc.BatchStatus)
+	router.GET("/readings/:id", rc.GetResult)
+	router.GET("/readings/:id/status", rc.GetStatus)
+	router.GET("/readings/:id/stream", rc.Stream)
+	router.GET("/users/:user_id/readings", rc.GetHistory)
+	router.GET("/users/:user_id/readings/:task_id", rc.GetReadingDetail)
+	router.GET("/users/:user_id/readings/:task_id/export", rc.Export)
+	router.POST("/users/:user_id/readings/:task_id/follow-up", rc.FollowUp)
+	router.POST("/users/:user_id/readings/:task_id/retry", rc.Retry)
+	return router
+}
+
+// postReading 以 currentUser 的身份提交 bodyUser 的解读请求
+func postReading(router *gin.Engine, currentUser, bodyUser string) *httptest.ResponseRecorder {
+	return postReadingWith(router, currentUser, readingBody(bodyUser, nil))
+}
+
+// readingBody 默认的解读请求参数，extra 中的字段覆盖默认值
+func readingBody(userID string, extra map[string]interface{}) map[string]interface{} {
+	body := map[string]interface{}{
+		"user_id":  userID,
+		"question": "我最近的事业运势如何？",
+		"cards":    []int{1, 2, 3},
+		"type":     "free",
+	}
+	for k, v := range extra {
+		body[k] = v
+	}
+	return body
+}
+
+// postReadingWith 以 currentUser 的身份提交任意解读请求参数
+func postReadingWith(router *gin.Engine, currentUser string, params map[string]interface{}) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(params)
+	req := httptest.NewRequest(http.MethodPost, "/readings", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if currentUser != "" {
+		req.Header.Set("X-Test-User", currentUser)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func countReadings(t *testing.T, userID string) int64 {
+	t.Helper()
+	var n int64
+	database.DB.Model(&reading.Reading{}).Where("user_id = ?", userID).Count(&n)
+	return n
+}
+
+func TestStoreRejectsOtherUsersID(t *testing.T) {
+	router := newReadingRouter(t)
+
+	w := postReading(router, "user-1", "user-2")
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("status = %d, want 403: %s", w.Code, w.Body.String())
+	}
+	if n := countReadings(t, "user-2"); n != 0 {
+		t.Fatalf("created %d readings for user-2", n)
+	}
+}
+
+func TestStoreUsesCurrentUser(t *testing.T) {
+	router := newReadingRouter(t)
+
+	w := postReading(router, "user-1", "user-1")
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("status = %d, want 202: %s", w.Code, w.Body.String())
+	}
+	if n := countReadings(t, "user-1"); n != 1 {
+		t.Fatalf("readings for user-1 = %d, want 1", n)
+	}
+}
+
+func TestStoreGuestConsumesOwnReading(t *testing.T) {
+	router := newReadingRouter(t)
+	if err := database.DB.Create(&guest.Guest{ID: "guest-1", FreeReadings: 1}).Error; err != nil {
+		t.Fatalf("create guest: %v", err)
+	}
+
+	if w := postReading(router, "", "guest-1"); w.Code != http.StatusAccepted {
+		t.Fatalf("first guest reading = %d, want 202: %s", w.Code, w.Body.String())
+	}
+	if w := postReading(router, "", "guest-1"); w.Code != http.StatusForbidden {
+		t.Fatalf("second guest reading = %d, want 403", w.Code)
+	}
+	if w := postReading(router, "", "guest-unknown"); w.Code != http.StatusForbidden {
+		t.Fatalf("unknown guest = %d, want 403", w.Code)
+	}
+}
+
+// completedReading 创建已完成的解读记录并写入任务结果
+func completedReading(t *testing.T, userID string, readingType reading.ReadingType, unlocked bool, result string) *reading.Reading {
+	t.Helper()
+	r := &reading.Reading{TaskID: "task-" + userID, UserID: userID, Type: readingType, Unlocked: unlocked, Cards: reading.Cards{1}}
+	if err := database.DB.Create(r).Error; err != nil {
+		t.Fatalf("create reading: %v", err)
+	}
+	if err := queue.NewQueueService().UpdateTaskStatus(context.Background(), r.TaskID, queue.TaskCompleted, result); err != nil {
+		t.Fatalf("UpdateTaskStatus: %v", err)
+	}
+	return r
+}
+
+// getResult 以 currentUser 的身份请求 path，返回状态码与 data 字段
+func getResult(t *testing.T, router *gin.Engine, currentUser, path string) (int, map[string]interface{}) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	if currentUser != "" {
+		req.Header.Set("X-Test-User", currentUser)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	var body struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &body)
+	return w.Code, body.Data
+}
+
+var fullInterpretation = strings.Repeat("星", reading.TeaserLength*2)
+
+func TestGetResultReturnsFullTextToOwner(t *testing.T) {
+	router := newReadingRouter(t)
+	r := completedReading(t, "user-1", reading.TypePremium, true, fullInterpretation)
+
+	code, data := getResult(t, router, "user-1", "/readings/"+r.TaskID)
+	if code != http.StatusOK || data["result"] != fullInterpretation || data["locked"] != false {
+		t.Fatalf("status=%d data=%v, want full unlocked result", code, data)
+	}
+}
+
+func TestGetResultIncludesCardImages(t *testing.T) {
+	router := newReadingRouter(t)
+	testutil.SetConfig(t, "tarot.card_image_base_url", "https://cdn.example.com/cards")
+	r := completedReading(t, "user-1", reading.TypeFree, false, "解读")
+
+	code, data := getResult(t, router, "user-1", "/readings/"+r.TaskID)
+	cards, _ := data["cards"].([]interface{})
+	if code != http.StatusOK || len(cards) != 1 {
+		t.Fatalf("status=%d data=%v, want one card", code, data)
+	}
+	card := cards[0].(map[string]interface{})
+	if card["image_key"] != "major/00.jpg" || card["image_url"] != "https://cdn.example.com/cards/major/00.jpg" {
+		t.Fatalf("card = %v, want prefixed image of The Fool", card)
+	}
+}
+
+func TestGetResultHidesLockedInterpretation(t *testing.T) {
+	router := newReadingRouter(t)
+	r := completedReading(t, "user-1", reading.TypePremium, false, fullInterpretation)
+
+	code, data := getResult(t, router, "user-1", "/readings/"+r.TaskID)
+	if code != http.StatusOK || data["locked"] != true {
+		t.Fatalf("status=%d data=%v, want locked result", code, data)
+	}
+	want := strings.Repeat("星", reading.TeaserLength) + "……"
+	if data["result"] != want {
+		t.Fatalf("result = %q, want teaser", data["result"])
+	}
+}
+
+func TestGetResultRejectsOtherUsers(t *testing.T) {
+	router := newReadingRouter(t)
+	r := completedReading(t, "user-1", reading.TypeFree, false, fullInterpretation)
+
+	if code, _ := getResult(t, router, "user-2", "/readings/"+r.TaskID); code != http.StatusNotFound {
+		t.Fatalf("other user status = %d, want 404", code)
+	}
+	if code, _ := getResult(t, router, "", "/readings/"+r.TaskID); code != http.StatusNotFound {
+		t.Fatalf("anonymous status = %d, want 404", code)
+	}
+	// 未登录时不能以注册用户的 ID 冒充游客
+	if code, _ := getResult(t, router, "", "/readings/"+r.TaskID+"?user_id=user-1"); code != http.StatusNotFound {
+		t.Fatalf("anonymous with user id status = %d, want 404", code)
+	}
+}
+
+func TestGetResultForGuest(t *testing.T) {
+	router := newReadingRouter(t)
+	if err := database.DB.Create(&guest.Guest{ID: "guest-1", FreeReadings: 1}).Error; err != nil {
+		t.Fatalf("create guest: %v", err)
+	}
+	r := completedReading(t, "guest-1", reading.TypeFree, false, fullInterpretation)
+
+	code, data := getResult(t, router, "", "/readings/"+r.TaskID+"?user_id=guest-1")
+	if code != http.StatusOK || data["result"] != fullInterpretation {
+		t.Fatalf("status=%d data=%v, want guest's result", code, data)
+	}
+}
+
+func TestStreamHidesLockedInterpretation(t *testing.T) {
+	router := newReadingRouter(t)
+	r := completedReading(t, "user-1", reading.TypePremium, false, fullInterpretation)
+
+	req := httptest.NewRequest(http.MethodGet, "/readings/"+r.TaskID+"/stream", nil)
+	req.Header.Set("X-Test-User", "user-1")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", w.Code, w.Body.String())
+	}
+	if strings.Contains(w.Body.String(), fullInterpretation) {
+		t.Fatalf("stream leaked the locked interpretation")
+	}
+	if !strings.Contains(w.Body.String(), `"locked":true`) {
+		t.Fatalf("done event not marked locked: %s", w.Body.String())
+	}
+}
+
+func TestStreamRejectsOtherUsers(t *testing.T) {
+	router := newReadingRouter(t)
+	r := completedReading(t, "user-1", reading.TypeFree, false, fullInterpretation)
+
+	req := httptest.NewRequest(http.MethodGet, "/readings/"+r.TaskID+"/stream", nil)
+	req.Header.Set("X-Test-User", "user-2")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("status = %d, want 404", w.Code)
+	}
+}
+
+func TestGetResultWaitReturnsWhenTaskCompletes(t *testing.T) {
+	router := newReadingRouter(t)
+	r := pendingReading(t, "user-1")
+
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		queue.NewQueueService().UpdateTaskStatus(context.Background(), r.TaskID, queue.TaskCompleted, "完整解读")
+	}()
+
+	start := time.Now()
+	code, data := getResult(t, router, "user-1", "/readings/"+r.TaskID+"?wait=5")
+	if code != http.StatusOK || data["status"] != string(queue.TaskCompleted) || data["result"] != "完整解读" {
+		t.Fatalf("status=%d data=%v, want the completed result", code, data)
+	}
+	if elapsed := time.Since(start); elapsed >= 2*time.Second {
+		t.Fatalf("long poll returned after %v, want as soon as the task completed", elapsed)
+	}
+}
+
+func TestGetResultWaitTimesOutPending(t *testing.T) {
+	router := newReadingRouter(t)
+	r := pendingReading(t, "user-1")
+
+	start := time.Now()
+	code, data := getResult(t, router, "user-1", "/readings/"+r.TaskID+"?wait=1")
+	if code != http.StatusOK || data["status"] != string(queue.TaskPending) || data["result"] != nil {
+		t.Fatalf("status=%d data=%v, want still pending", code, data)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second || elapsed > 3*time.Second {
+		t.Fatalf("long poll returned after %v, want about 1s", elapsed)
+	}
+}
+
+// sseEvent SSE 连接收到的一条事件
+type sseEvent struct {
+	Name string
+	Data map[string]interface{}
+}
+
+// openStream 通过真实的 HTTP 连接订阅 path，返回逐条读取事件的函数
+func openStream(t *testing.T, router *gin.Engine, currentUser, path string) func() sseEvent {
+	t.Helper()
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL+path, nil)
+	req.Header.Set("Accept", "text/event-stream")
+	req.Header.Set("X-Test-User", currentUser)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("open stream: %v", err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	if resp.StatusCode != http.StatusOK || !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		t.Fatalf("stream status=%d content-type=%q", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+
+	events := make(chan sseEvent)
+	go func() {
+		defer close(events)
+		scanner := bufio.NewScanner(resp.Body)
+		var ev sseEvent
+		for scanner.Scan() {
+			line := scanner.Text()
+			switch {
+			case strings.HasPrefix(line, "event:"):
+				ev.Name = strings.TrimPrefix(line, "event:")
+			case strings.HasPrefix(line, "data:"):
+				json.Unmarshal([]byte(strings.TrimPrefix(line, "data:")), &ev.Data)
+			case line == "" && ev.Name != "":
+				events <- ev
+				ev = sseEvent{}
+			}
+		}
+	}()
+
+	return func() sseEvent {
+		t.Helper()
+		select {
+		case ev, ok := <-events:
+			if !ok {
+				t.Fatal("stream closed before the next event")
+			}
+			return ev
+		case <-time.After(3 * time.Second):
+			t.Fatal("timed out waiting for stream event")
+		}
+		return sseEvent{}
+	}
+}
+
+// pendingReading 创建排队中的解读记录
+func pendingReading(t *testing.T, userID string) *reading.Reading {
+	t.Helper()
+	r := &reading.Reading{TaskID: "task-" + userID, UserID: userID, Type: reading.TypeFree, Cards: reading.Cards{1}}
+	if err := database.DB.Create(r).Error; err != nil {
+		t.Fatalf("create reading: %v", err)
+	}
+	if err := queue.NewQueueService().UpdateTaskStatus(context.Background(), r.TaskID, queue.TaskPending, ""); err != nil {
+		t.Fatalf("UpdateTaskStatus: %v", err)
+	}
+	return r
+}
+
+func TestStreamDeliversTerminalEvent(t *testing.T) {
+	router := newReadingRouter(t)
+	r := pendingReading(t, "user-1")
+	next := openStream(t, router, "user-1", "/readings/"+r.TaskID+"/stream")
+
+	if ev := next(); ev.Name != queue.StreamEventStatus || ev.Data["status"] != string(queue.TaskPending) {
+		t.Fatalf("first event = %+v, want pending status", ev)
+	}
+
+	qs := queue.NewQueueService()
+	ctx := context.Background()
+	if err := qs.UpdateTaskStatus(ctx, r.TaskID, queue.TaskRunning, ""); err != nil {
+		t.Fatalf("UpdateTaskStatus: %v", err)
+	}
+	if ev := next(); ev.Name != queue.StreamEventStatus || ev.Data["status"] != string(queue.TaskRunning) {
+		t.Fatalf("second event = %+v, want running status", ev)
+	}
+
+	if err := qs.UpdateTaskStatus(ctx, r.TaskID, queue.TaskCompleted, "完整解读"); err != nil {
+		t.Fatalf("UpdateTaskStatus: %v", err)
+	}
+	if ev := next(); ev.Name != queue.StreamEventStatus || ev.Data["status"] != string(queue.TaskCompleted) {
+		t.Fatalf("third event = %+v, want completed status", ev)
+	}
+	ev := next()
+	if ev.Name != queue.StreamEventDone || ev.Data["result"] != "完整解读" || ev.Data["task_id"] != r.TaskID {
+		t.Fatalf("terminal event = %+v, want done with the interpretation", ev)
+	}
+}
+
+func TestStreamClosesAfterMaxDuration(t *testing.T) {
+	router := newReadingRouter(t)
+	testutil.SetConfig(t, "tarot.stream_max_duration", 1)
+	r := pendingReading(t, "user-1")
+	next := openStream(t, router, "user-1", "/readings/"+r.TaskID+"/stream")
+
+	if ev := next(); ev.Name != queue.StreamEventStatus {
+		t.Fatalf("first event = %+v, want status", ev)
+	}
+	if ev := next(); ev.Name != "timeout" || ev.Data["status"] != string(queue.TaskPending) {
+		t.Fatalf("event = %+v, want timeout", ev)
+	}
+}
+
+// createSpread 创建牌阵并刷新缓存
+func createSpread(t *testing.T) *spread.Spread {
+	t.Helper()
+	if err := database.DB.AutoMigrate(&spread.Spread{}); err != nil {
+		t.Fatalf("migrate spreads: %v", err)
+	}
+	s := &spread.Spread{Slug: "three-card", Name: "三牌阵", CardCount: 3, Positions: spread.Positions{"过去", "现在", "未来"}}
+	if err := database.DB.Create(s).Error; err != nil {
+		t.Fatalf("create spread: %v", err)
+	}
+	if err := spread.Reload(); err != nil {
+		t.Fatalf("reload spreads: %v", err)
+	}
+	return s
+}
+
+func TestStoreWithSpread(t *testing.T) {
+	router := newReadingRouter(t)
+	s := createSpread(t)
+
+	w := postReadingWith(router, "user-1", readingBody("user-1", map[string]interface{}{"spread_id": s.ID}))
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("status = %d, want 202: %s", w.Code, w.Body.String())
+	}
+
+	var r reading.Reading
+	if err := database.DB.Where("user_id = ?", "user-1").First(&r).Error; err != nil {
+		t.Fatalf("load reading: %v", err)
+	}
+	if r.SpreadID != s.ID || len(r.Cards) != s.CardCount {
+		t.Fatalf("reading spread=%d cards=%v, want spread %d with %d cards", r.SpreadID, r.Cards, s.ID, s.CardCount)
+	}
+
+	task, err := queue.NewQueueService().DequeueTask(context.Background(), "worker-test")
+	if err != nil || task == nil {
+		t.Fatalf("DequeueTask: %v, %v", task, err)
+	}
+	if task.SpreadID != s.ID || task.Spread != s.PromptText() {
+		t.Fatalf("task spread=%d prompt=%q, want %q", task.SpreadID, task.Spread, s.PromptText())
+	}
+}
+
+func TestStoreRejectsWrongCardCountForSpread(t *testing.T) {
+	router := newReadingRouter(t)
+	s := createSpread(t)
+
+	w := postReadingWith(router, "user-1", readingBody("user-1", map[string]interface{}{"spread_id": s.ID, "cards": []int{1, 2}}))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400: %s", w.Code, w.Body.String())
+	}
+	w = postReadingWith(router, "user-1", readingBody("user-1", map[string]interface{}{"spread_id": s.ID + 100}))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("unknown spread status = %d, want 400: %s", w.Code, w.Body.String())
+	}
+	if n := countReadings(t, "user-1"); n != 0 {
+		t.Fatalf("readings created = %d, want 0", n)
+	}
+}
+
+// createdData 解析创建解读响应中的 data 字段
+func createdData(t *testing.T, w *httptest.ResponseRecorder) map[string]interface{} {
+	t.Helper()
+	var body struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	return body.Data
+}
+
+func TestStoreResponseModeSelectsDelivery(t *testing.T) {
+	router := newReadingRouter(t)
+	qs := queue.NewQueueService()
+
+	for _, tc := range []struct {
+		mode, wantMode, wantDelivery string
+		wantStream                   bool
+	}{
+		{"", "blocking", "polling", false},
+		{"blocking", "blocking", "polling", false},
+		{"streaming", "streaming", "sse", true},
+	} {
+		extra := map[string]interface{}{}
+		if tc.mode != "" {
+			extra["response_mode"] = tc.mode
+		}
+		w := postReadingWith(router, "user-1", readingBody("user-1", extra))
+		if w.Code != http.StatusAccepted {
+			t.Fatalf("mode %q: status = %d, want 202: %s", tc.mode, w.Code, w.Body.String())
+		}
+		data := createdData(t, w)
+		taskID, _ := data["task_id"].(string)
+		if data["response_mode"] != tc.wantMode || data["delivery"] != tc.wantDelivery {
+			t.Fatalf("mode %q: data = %v, want %s via %s", tc.mode, data, tc.wantMode, tc.wantDelivery)
+		}
+		if streamURL, _ := data["stream_url"].(string); (streamURL == "/v1/tarot/readings/"+taskID+"/stream") != tc.wantStream {
+			t.Fatalf("mode %q: stream_url = %q", tc.mode, streamURL)
+		}
+
+		task, err := qs.DequeueTask(context.Background(), "worker-test")
+		if err != nil || task == nil || task.ResponseMode != tc.wantMode {
+			t.Fatalf("mode %q: queued task = %+v, %v", tc.mode, task, err)
+		}
+	}
+}
+
+func TestStoreRejectsUnknownResponseMode(t *testing.T) {
+	router := newReadingRouter(t)
+
+	w := postReadingWith(router, "user-1", readingBody("user-1", map[string]interface{}{"response_mode": "push"}))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestStoreAttachesRequestIDToTask(t *testing.T) {
+	router := newReadingRouter(t)
+	router.Use(middlewares.RequestID())
+	router.POST("/traced/readings", NewReadingController().Store)
+
+	body, _ := json.Marshal(readingBody("user-1", nil))
+	req := httptest.NewRequest(http.MethodPost, "/traced/readings", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Test-User", "user-1")
+	req.Header.Set(middlewares.RequestIDHeader, "req-reading-1")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("status = %d, want 202: %s", w.Code, w.Body.String())
+	}
+
+	task, err := queue.NewQueueService().DequeueTask(context.Background(), "worker-test")
+	if err != nil || task == nil || task.RequestID != "req-reading-1" {
+		t.Fatalf("queued task = %+v, %v, want request id req-reading-1", task, err)
+	}
+}
+
+// postBatchStatus 以 currentUser 的身份批量查询任务状态，query 为附加的查询参数（如游客的 ?user_id=）
+func postBatchStatus(router *gin.Engine, currentUser, query string, taskIDs []string) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(map[string]interface{}{"task_ids": taskIDs})
+	req := httptest.NewRequest(http.MethodPost, "/readings/status"+query, bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if currentUser != "" {
+		req.Header.Set("X-Test-User", currentUser)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestBatchStatusMixedTasks(t *testing.T) {
+	router := newReadingRouter(t)
+	qs := queue.NewQueueService()
+	ctx := context.Background()
+	if err := qs.PushTask(ctx, &queue.TarotTask{ID: "task-pending", UserID: "user-1", Question: "我最近的事业运势如何？", Type: "free", Cards: []int{1}}); err != nil {
+		t.Fatalf("PushTask: %v", err)
+	}
+	if err := qs.UpdateTaskStatus(ctx, "task-done", queue.TaskCompleted, "结果"); err != nil {
+		t.Fatalf("UpdateTaskStatus: %v", err)
+	}
+	for _, taskID := range []string{"task-pending", "task-done"} {
+		if err := database.DB.Create(&reading.Reading{TaskID: taskID, UserID: "user-1", Type: reading.TypeFree, Cards: reading.Cards{1}}).Error; err != nil {
+			t.Fatalf("create reading: %v", err)
+		}
+	}
+
+	w := postBatchStatus(router, "user-1", "", []string{"task-pending", "task-done", "task-missing", "task-done"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", w.Code, w.Body.String())
+	}
+	statuses, _ := createdData(t, w)["statuses"].(map[string]interface{})
+	want := map[string]string{"task-pending": "pending", "task-done": "completed", "task-missing": "not_found"}
+	if len(statuses) != len(want) {
+		t.Fatalf("statuses = %v, want %v", statuses, want)
+	}
+	for id, status := range want {
+		if statuses[id] != status {
+			t.Fatalf("statuses[%s] = %v, want %s", id, statuses[id], status)
+		}
+	}
+}
+
+// TestStatusHidesOtherUsersTasks 任务状态只对所属用户（或携带 ?user_id 的游客本人）可见
+func TestStatusHidesOtherUsersTasks(t *testing.T) {
+	router := newReadingRouter(t)
+	owned := completedReading(t, "user-1", reading.TypeFree, false, "结果")
+	guestReading := completedReading(t, "guest-1", reading.TypeFree, false, "结果")
+	if err := database.DB.Create(&guest.Guest{ID: "guest-1"}).Error; err != nil {
+		t.Fatalf("create guest: %v", err)
+	}
+
+	for _, tc := range []struct {
+		currentUser, query string
+		visible            *reading.Reading
+	}{
+		{"user-1", "", owned},
+		{"", "?user_id=guest-1", guestReading},
+		{"user-2", "", nil},
+		{"", "", nil},
+		// 游客参数不能用来查询注册用户的任务
+		{"", "?user_id=user-1", nil},
+	} {
+		for _, r := range []*reading.Reading{owned, guestReading} {
+			want, wantCode := "not_found", http.StatusNotFound
+			if r == tc.visible {
+				want, wantCode = "completed", http.StatusOK
+			}
+			if code, data := getResult(t, router, tc.currentUser, "/readings/"+r.TaskID+"/status"+tc.query); code != wantCode ||
+				(code == http.StatusOK && data["status"] != want) {
+				t.Fatalf("%s as %q%s: status = %d %v, want %d", r.TaskID, tc.currentUser, tc.query, code, data, wantCode)
+			}
+
+			w := postBatchStatus(router, tc.currentUser, tc.query, []string{r.TaskID})
+			statuses, _ := createdData(t, w)["statuses"].(map[string]interface{})
+			if w.Code != http.StatusOK || statuses[r.TaskID] != want {
+				t.Fatalf("%s batch as %q%s = %d %v, want %s", r.TaskID, tc.currentUser, tc.query, w.Code, statuses, want)
+			}
+		}
+	}
+}
+
+func TestBatchStatusValidatesIDs(t *testing.T) {
+	router := newReadingRouter(t)
+
+	tooMany := make([]string, requests.MaxBatchTaskIDs+1)
+	for i := range tooMany {
+		tooMany[i] = fmt.Sprintf("task-%d", i)
+	}
+	for name, ids := range map[string][]string{
+		"empty":    {},
+		"invalid":  {"task-1", "../etc/passwd"},
+		"too many": tooMany,
+	} {
+		if w := postBatchStatus(router, "user-1", "", ids); w.Code != http.StatusBadRequest {
+			t.Fatalf("%s: status = %d, want 400: %s", name, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestStoreQuestionLengthBoundaries(t *testing.T) {
+	router := newReadingRouter(t)
+
+	for _, tc := range []struct {
+		length int
+		want   int
+	}{
+		{9, http.StatusBadRequest},
+		{10, http.StatusAccepted},
+		{500, http.StatusAccepted},
+		{501, http.StatusBadRequest},
+	} {
+		question := strings.Repeat("星", tc.length)
+		w := postReadingWith(router, "user-1", readingBody("user-1", map[string]interface{}{"question": question}))
+		if w.Code != tc.want {
+			t.Fatalf("%d chars: status = %d, want %d: %s", tc.length, w.Code, tc.want, w.Body.String())
+		}
+	}
+	if n := countReadings(t, "user-1"); n != 2 {
+		t.Fatalf("readings = %d, want 2 for the accepted lengths", n)
+	}
+}
+
+// setModerator 替换全局审核器，测试结束后恢复
+func setModerator(t *testing.T, words ...string) {
+	t.Helper()
+	m, err := moderation.NewKeywordModerator(words, "")
+	if err != nil {
+		t.Fatalf("NewKeywordModerator: %v", err)
+	}
+	previous := moderation.Default
+	moderation.Default = m
+	t.Cleanup(func() { moderation.Default = previous })
+}
+
+func TestStoreRejectsBlockedQuestion(t *testing.T) {
+	router := newReadingRouter(t)
+	setModerator(t, "赌博")
+
+	w := postReadingWith(router, "user-1", readingBody("user-1", map[string]interface{}{"question": "我这次去赌博能赢多少钱？"}))
+	if w.Code != http.StatusUnprocessableEntity || !strings.Contains(w.Body.String(), `"code":"content_blocked"`) {
+		t.Fatalf("status = %d, want 422 content_blocked: %s", w.Code, w.Body.String())
+	}
+	if n := countReadings(t, "user-1"); n != 0 {
+		t.Fatalf("blocked question created %d readings", n)
+	}
+	if n, _ := queue.NewQueueService().Length(context.Background()); n != 0 {
+		t.Fatalf("blocked question enqueued %d tasks", n)
+	}
+}
+
+func TestStoreAcceptsCleanQuestion(t *testing.T) {
+	router := newReadingRouter(t)
+	setModerator(t, "赌博")
+
+	if w := postReading(router, "user-1", "user-1"); w.Code != http.StatusAccepted {
+		t.Fatalf("status = %d, want 202: %s", w.Code, w.Body.String())
+	}
+	if n := countReadings(t, "user-1"); n != 1 {
+		t.Fatalf("readings = %d, want 1", n)
+	}
+}
+
+// exportedReading 创建已完成的解读记录，解读结果保存在数据库中
+func exportedReading(t *testing.T, readingType reading.ReadingType, unlocked bool) *reading.Reading {
+	t.Helper()
+	r := &reading.Reading{
+		TaskID:         "task-export",
+		UserID:         "user-1",
+		Question:       "我最近的事业运势如何？",
+		Interpretation: "愚者预示着新的开始。",
+		Type:           readingType,
+		Unlocked:       unlocked,
+		Status:         string(reading.StatusCompleted),
+		Cards:          reading.Cards{1},
+	}
+	if err := database.DB.Create(r).Error; err != nil {
+		t.Fatalf("create reading: %v", err)
+	}
+	return r
+}
+
+// getExport 以 currentUser 的身份导出 userID 名下的解读
+func getExport(router *gin.Engine, currentUser, userID, query string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/users/"+userID+"/readings/task-export/export"+query, nil)
+	req.Header.Set("X-Test-User", currentUser)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestExportMarkdownContainsQuestionAndInterpretation(t *testing.T) {
+	router := newReadingRouter(t)
+	exportedReading(t, reading.TypeFree, false)
+
+	w := getExport(router, "user-1", "user-1", "?format=md")
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/markdown") {
+		t.Fatalf("status=%d content-type=%q: %s", w.Code, w.Header().Get("Content-Type"), w.Body.String())
+	}
+	if !strings.Contains(w.Header().Get("Content-Disposition"), `filename="reading-task-export.md"`) {
+		t.Fatalf("Content-Disposition = %q", w.Header().Get("Content-Disposition"))
+	}
+	for _, want := range []string{"我最近的事业运势如何？", "愚者预示着新的开始。"} {
+		if !strings.Contains(w.Body.String(), want) {
+			t.Fatalf("export missing %q:\n%s", want, w.Body.String())
+		}
+	}
+}
+
+func TestExportEnforcesOwnershipAndPayment(t *testing.T) {
+	router := newReadingRouter(t)
+	exportedReading(t, reading.TypePremium, false)
+
+	if w := getExport(router, "user-2", "user-1", ""); w.Code != http.StatusForbidden {
+		t.Fatalf("other user status = %d, want 403", w.Code)
+	}
+	if w := getExport(router, "user-2", "user-2", ""); w.Code != http.StatusNotFound {
+		t.Fatalf("other user's own path status = %d, want 404", w.Code)
+	}
+	w := getExport(router, "user-1", "user-1", "")
+	if w.Code != http.StatusPaymentRequired || strings.Contains(w.Body.String(), "愚者预示着新的开始。") {
+		t.Fatalf("locked premium status = %d, want 402 without interpretation: %s", w.Code, w.Body.String())
+	}
+	if w := getExport(router, "user-1", "user-1", "?format=pdf"); w.Code != http.StatusBadRequest {
+		t.Fatalf("pdf status = %d, want 400", w.Code)
+	}
+}
+
+func TestHistorySearchByQuestion(t *testing.T) {
+	router := newReadingRouter(t)
+	exportedReading(t, reading.TypeFree, false)
+
+	for keyword, want := range map[string]int{"事业": 1, "感情": 0, "%25": 0} {
+		code, data := getResult(t, router, "user-1", "/users/user-1/readings?q="+keyword)
+		meta, _ := data["meta"].(map[string]interface{})
+		if code != http.StatusOK || meta["total"] != float64(want) {
+			t.Fatalf("q=%s: status=%d data=%v, want %d results", keyword, code, data, want)
+		}
+	}
+
+	code, _ := getResult(t, router, "user-1", "/users/user-1/readings?q="+strings.Repeat("星", 101))
+	if code != http.StatusBadRequest {
+		t.Fatalf("overlong keyword status = %d, want 400", code)
+	}
+}
+
+func TestHistoryAndDetailRequireOwner(t *testing.T) {
+	router := newReadingRouter(t)
+	exportedReading(t, reading.TypeFree, false)
+
+	for _, path := range []string{"/users/user-1/readings", "/users/user-1/readings?q=事业", "/users/user-1/readings/task-export"} {
+		for _, currentUser := range []string{"", "user-2"} {
+			if code, data := getResult(t, router, currentUser, path); code != http.StatusForbidden || data != nil {
+				t.Fatalf("%s as %q: status=%d data=%v, want 403 without data", path, currentUser, code, data)
+			}
+		}
+		if code, _ := getResult(t, router, "user-1", path); code != http.StatusOK {
+			t.Fatalf("%s as owner: status=%d, want 200", path, code)
+		}
+	}
+}
+
+// TestStoreEnqueuesWhenDifyUnavailable 提交解读不同步调用 Dify：
+// 所有实例都不可用时仍然入队并返回 202 与 task_id，由工作器稍后处理
+func TestStoreEnqueuesWhenDifyUnavailable(t *testing.T) {
+	var calls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(server.Close)
+
+	s := dify.NewDifyService(&dify.Config{URLs: []string{server.URL}, APIKeys: []string{"app-test"}, Timeout: time.Second})
+	if s == nil {
+		t.Fatal("NewDifyService returned nil")
+	}
+	s.MarkInstanceUnhealthy(s.GetInstances()[0], errors.New("connection refused"))
+	prev := dify.Service
+	dify.Service = s
+	t.Cleanup(func() { dify.Service = prev })
+
+	router := newReadingRouter(t)
+	w := postReading(router, "user-1", "user-1")
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("status = %d, want 202: %s", w.Code, w.Body.String())
+	}
+	if data := createdData(t, w); data["task_id"] == "" || data["task_id"] == nil {
+		t.Fatalf("data = %v, want a task_id to poll", data)
+	}
+	if n, _ := queue.NewQueueService().Length(context.Background()); n != 1 {
+		t.Fatalf("queue length = %d, want the task enqueued", n)
+	}
+	if calls != 0 {
+		t.Fatalf("Store called Dify %d times, want none", calls)
+	}
+}
+
+// useChatDify 让之后创建的控制器使用对话模式的 Dify 服务，追问依赖对话能力
+func useChatDify(t *testing.T) {
+	t.Helper()
+	s := dify.NewDifyService(&dify.Config{
+		URLs:    []string{"http://dify.invalid"},
+		APIKeys: []string{"app-test"},
+		Timeout: time.Second,
+		AppMode: dify.AppModeChat,
+	})
+	if s == nil {
+		t.Fatal("NewDifyService returned nil")
+	}
+	prev := dify.Service
+	dify.Service = s
+	t.Cleanup(func() { dify.Service = prev })
+}
+
+func postFollowUp(router *gin.Engine, userID, taskID string) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(map[string]interface{}{"question": "那么我最近的感情运势又会如何呢？"})
+	req := httptest.NewRequest(http.MethodPost, "/users/"+userID+"/readings/"+taskID+"/follow-up", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Test-User", userID)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// TestFollowUpThreadsConversationID 追问沿用原解读的对话 ID：
+// 工作器只写入了 Redis 的对话 ID 会持久化到原解读，并随新解读与任务一起传给 Dify
+func TestFollowUpThreadsConversationID(t *testing.T) {
+	useChatDify(t)
+	router := newReadingRouter(t)
+	ctx := context.Background()
+	qs := queue.NewQueueService()
+
+	parent := exportedReading(t, reading.TypeFree, false)
+	if err := qs.SetTaskConversation(ctx, parent.TaskID, "conv-1"); err != nil {
+		t.Fatalf("SetTaskConversation: %v", err)
+	}
+
+	w := postFollowUp(router, "user-1", parent.TaskID)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("status = %d, want 202: %s", w.Code, w.Body.String())
+	}
+	taskID, _ := createdData(t, w)["task_id"].(string)
+
+	var stored reading.Reading
+	if err := database.DB.Where("task_id = ?", parent.TaskID).First(&stored).Error; err != nil {
+		t.Fatalf("load parent: %v", err)
+	}
+	if stored.ConversationID != "conv-1" {
+		t.Fatalf("parent conversation_id = %q, want conv-1 persisted", stored.ConversationID)
+	}
+
+	var child reading.Reading
+	if err := database.DB.Where("task_id = ?", taskID).First(&child).Error; err != nil {
+		t.Fatalf("load follow-up: %v", err)
+	}
+	if child.ConversationID != "conv-1" || child.ParentTaskID != parent.TaskID {
+		t.Fatalf("follow-up conversation_id=%q parent_task_id=%q, want conv-1 and %s",
+			child.ConversationID, child.ParentTaskID, parent.TaskID)
+	}
+
+	task, err := qs.DequeueTask(ctx, "worker-test")
+	if err != nil || task == nil {
+		t.Fatalf("DequeueTask: %v, %v", task, err)
+	}
+	if task.ID != taskID || task.ConversationID != "conv-1" {
+		t.Fatalf("queued task id=%s conversation_id=%q, want %s with conv-1", task.ID, task.ConversationID, taskID)
+	}
+}
+
+func TestFollowUpRequiresConversation(t *testing.T) {
+	useChatDify(t)
+	router := newReadingRouter(t)
+
+	parent := exportedReading(t, reading.TypeFree, false)
+	if w := postFollowUp(router, "user-1", parent.TaskID); w.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400 without a conversation: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestFollowUpRejectsLockedReading(t *testing.T) {
+	useChatDify(t)
+	router := newReadingRouter(t)
+
+	parent := exportedReading(t, reading.TypePremium, false)
+	if w := postFollowUp(router, "user-1", parent.TaskID); w.Code != http.StatusPaymentRequired {
+		t.Fatalf("status = %d, want 402 for a locked reading: %s", w.Code, w.Body.String())
+	}
+}
+
+// failedReading 创建状态为 status 的解读，数据库中的状态保持 pending，任务状态只写入 Redis，与工作器的行为一致
+func failedReading(t *testing.T, status queue.TaskStatus) *reading.Reading {
+	t.Helper()
+	r := &reading.Reading{
+		TaskID:   "task-retry",
+		UserID:   "user-1",
+		Question: "我最近的事业运势如何？",
+		Type:     reading.TypeFree,
+		Status:   string(reading.StatusPending),
+		Cards:    reading.Cards{1, 5, 9},
+	}
+	if err := database.DB.Create(r).Error; err != nil {
+		t.Fatalf("create reading: %v", err)
+	}
+	if err := queue.NewQueueService().UpdateTaskStatus(context.Background(), r.TaskID, status, ""); err != nil {
+		t.Fatalf("UpdateTaskStatus: %v", err)
+	}
+	return r
+}
+
+func postRetry(router *gin.Engine, currentUser, userID, taskID string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/users/"+userID+"/readings/"+taskID+"/retry", nil)
+	req.Header.Set("X-Test-User", currentUser)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestRetryFailedReading(t *testing.T) {
+	router := newReadingRouter(t)
+	ctx := context.Background()
+	original := failedReading(t, queue.TaskFailed)
+
+	w := postRetry(router, "user-1", "user-1", original.TaskID)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("status = %d, want 202: %s", w.Code, w.Body.String())
+	}
+	taskID, _ := createdData(t, w)["task_id"].(string)
+	if taskID == "" || taskID == original.TaskID {
+		t.Fatalf("task_id = %q, want a new task", taskID)
+	}
+
+	var retry reading.Reading
+	if err := database.DB.Where("task_id = ?", taskID).First(&retry).Error; err != nil {
+		t.Fatalf("load retry: %v", err)
+	}
+	if retry.RetryOfTaskID != original.TaskID || retry.Question != original.Question ||
+		retry.Type != original.Type || !slices.Equal([]int(retry.Cards), []int(original.Cards)) {
+		t.Fatalf("retry = %+v, want the original question, cards and type linked to %s", retry, original.TaskID)
+	}
+
+	// 只记录在 Redis 中的失败状态同步到原解读
+	var stored reading.Reading
+	database.DB.Where("task_id = ?", original.TaskID).First(&stored)
+	if stored.Status != string(reading.StatusFailed) {
+		t.Fatalf("original status = %q, want failed", stored.Status)
+	}
+
+	task, err := queue.NewQueueService().DequeueTask(ctx, "worker-test")
+	if err != nil || task == nil {
+		t.Fatalf("DequeueTask: %v, %v", task, err)
+	}
+	if task.ID != taskID || task.Question != original.Question || !slices.Equal(task.Cards, []int(original.Cards)) {
+		t.Fatalf("queued task = %+v, want the original reading", task)
+	}
+
+	// 每次失败的解读只能重试一次
+	if w := postRetry(router, "user-1", "user-1", original.TaskID); w.Code != http.StatusConflict {
+		t.Fatalf("second retry status = %d, want 409: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestRetryRejectsReadingsThatDidNotFail(t *testing.T) {
+	for _, status := range []queue.TaskStatus{queue.TaskCompleted, queue.TaskPending, queue.TaskRunning} {
+		t.Run(string(status), func(t *testing.T) {
+			router := newReadingRouter(t)
+			original := failedReading(t, status)
+
+			if w := postRetry(router, "user-1", "user-1", original.TaskID); w.Code != http.StatusBadRequest {
+				t.Fatalf("status = %d, want 400: %s", w.Code, w.Body.String())
+			}
+			if n := countReadings(t, "user-1"); n != 1 {
+				t.Fatalf("readings = %d, want no retry created", n)
+			}
+		})
+	}
+}
+
+func TestRetryChecksOwnership(t *testing.T) {
+	router := newReadingRouter(t)
+	original := failedReading(t, queue.TaskFailed)
+
+	if w := postRetry(router, "user-2", "user-1", original.TaskID); w.Code != http.StatusForbidden {
+		t.Fatalf("other user's retry status = %d, want 403", w.Code)
+	}
+	if w := postRetry(router, "user-2", "user-2", original.TaskID); w.Code != http.StatusNotFound {
+		t.Fatalf("retry of another user's reading = %d, want 404", w.Code)
+	}
+}
+
+// taskSuffix 取出 task_时间戳_随机数 中的随机数部分
+func taskSuffix(t *testing.T, taskID string) string {
+	t.Helper()
+	parts := strings.Split(taskID, "_")
+	if len(parts) != 3 || parts[0] != "task" {
+		t.Fatalf("task id = %q, want task_<timestamp>_<random>", taskID)
+	}
+	return parts[2]
+}
+
+func TestGenerateTaskIDWithPinnedSource(t *testing.T) {
+	first, second := rand.New(rand.NewSource(42)), rand.New(rand.NewSource(42))
+	want := rand.New(rand.NewSource(42))
+
+	for i := 0; i < 5; i++ {
+		a, b := taskSuffix(t, generateTaskID(first)), taskSuffix(t, generateTaskID(second))
+		if a != b {
+			t.Fatalf("call %d: suffixes %s and %s differ for the same seed", i, a, b)
+		}
+		if expected := fmt.Sprintf("%04d", want.Intn(10000)); a != expected {
+			t.Fatalf("call %d: suffix = %s, want %s from the pinned source", i, a, expected)
+		}
+	}
+}
+
+func TestStoreUsesInjectedRandomSource(t *testing.T) {
+	newRouter := func() *gin.Engine {
+		rc := NewReadingController()
+		rc.random = rand.New(rand.NewSource(7))
+		router := gin.New()
+		router.Use(func(c *gin.Context) {
+			c.Set("user_id", c.GetHeader("X-Test-User"))
+			c.Next()
+		})
+		router.POST("/readings", rc.Store)
+		return router
+	}
+	var suffixes [2][]string
+	for run := range suffixes {
+		// 每轮使用新的数据库，相同种子生成的任务 ID 不会冲突
+		newReadingRouter(t)
+		router := newRouter()
+		for i := 0; i < 3; i++ {
+			w := postReading(router, "user-1", "user-1")
+			if w.Code != http.StatusAccepted {
+				t.Fatalf("status = %d, want 202: %s", w.Code, w.Body.String())
+			}
+			taskID, _ := createdData(t, w)["task_id"].(string)
+			suffixes[run] = append(suffixes[run], taskSuffix(t, taskID))
+		}
+	}
+	if !slices.Equal(suffixes[0], suffixes[1]) {
+		t.Fatalf("task id suffixes %v and %v differ for the same seed", suffixes[0], suffixes[1])
+	}
+}
+
+func TestCryptoRandomStaysInRange(t *testing.T) {
+	rc := NewReadingController()
+	for i := 0; i < 100; i++ {
+		suffix := taskSuffix(t, generateTaskID(rc.random))
+		if len(suffix) != 4 {
+			t.Fatalf("suffix = %q, want 4 digits", suffix)
+		}
+	}
+}
+
+// assertAccepted 检查异步创建的响应：202、Location 指向结果地址，响应体仍包含 task_id
+func assertAccepted(t *testing.T, w *httptest.ResponseRecorder) string {
+	t.Helper()
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("status = %d, want 202: %s", w.Code, w.Body.String())
+	}
+	data := createdData(t, w)
+	taskID, _ := data["task_id"].(string)
+	if taskID == "" {
+		t.Fatalf("data = %v, want a task_id", data)
+	}
+	want := "/v1/tarot/readings/" + taskID
+	if location := w.Header().Get("Location"); location != want {
+		t.Fatalf("Location = %q, want %q", location, want)
+	}
+	if data["result_url"] != want || data["status"] != string(reading.StatusPending) {
+		t.Fatalf("data = %v, want a pending reading with result_url %s", data, want)
+	}
+	return taskID
+}
+
+func TestAsyncReadingsReturnAcceptedWithLocation(t *testing.T) {
+	router := newReadingRouter(t)
+
+	assertAccepted(t, postReading(router, "user-1", "user-1"))
+
+	original := failedReading(t, queue.TaskFailed)
+	if taskID := assertAccepted(t, postRetry(router, "user-1", "user-1", original.TaskID)); taskID == original.TaskID {
+		t.Fatalf("retry reused task_id %s", taskID)
+	}
+}
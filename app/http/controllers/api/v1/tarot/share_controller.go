@@ -0,0 +1,90 @@
+package tarot
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"tarot/app/repositories"
+	"tarot/pkg/config"
+	"tarot/pkg/response"
+	"tarot/pkg/share"
+)
+
+type ShareController struct{}
+
+func NewShareController() *ShareController {
+	return &ShareController{}
+}
+
+// Store 为已完成的解读生成分享令牌
+// POST /v1/users/:user_id/readings/:task_id/share
+func (sc *ShareController) Store(c *gin.Context) {
+	userID := c.Param("user_id")
+	if userID == "" || userID != c.GetString("user_id") {
+		response.Abort403(c)
+		return
+	}
+
+	secret := config.GetString("share.secret")
+	if secret == "" {
+		response.Abort404(c, "分享功能未开启")
+		return
+	}
+
+	record, err := repositories.NewReadingRepository().GetByTaskID(c.Request.Context(), userID, c.Param("task_id"))
+	if err != nil {
+		response.Abort404(c, "记录不存在")
+		return
+	}
+	if !record.IsCompleted() {
+		response.Abort400(c, "解读尚未完成")
+		return
+	}
+
+	expiresAt := time.Now().Add(time.Duration(config.GetInt("share.ttl", 168)) * time.Hour)
+	response.Created(c, gin.H{
+		"token":      share.Sign(secret, record.ID, expiresAt),
+		"expires_at": expiresAt.Unix(),
+	})
+}
+
+// Show 通过分享令牌只读查看解读，不返回用户信息
+// GET /v1/shared/:token
+func (sc *ShareController) Show(c *gin.Context) {
+	secret := config.GetString("share.secret")
+	if secret == "" {
+		response.Abort404(c, "分享功能未开启")
+		return
+	}
+
+	readingID, err := share.Parse(secret, c.Param("token"), time.Now())
+	if err != nil {
+		if errors.Is(err, share.ErrTokenExpired) {
+			response.Abort404(c, "分享链接已过期")
+			return
+		}
+		response.Abort404(c, "分享链接无效")
+		return
+	}
+
+	record, err := repositories.NewReadingRepository().GetByID(c.Request.Context(), readingID)
+	if err != nil {
+		response.Abort404(c, "记录不存在")
+		return
+	}
+
+	// 付费解读未支付时仅返回预览
+	record.HideLockedInterpretation()
+	response.Data(c, gin.H{
+		"task_id":        record.TaskID,
+		"type":           record.Type,
+		"question":       record.Question,
+		"cards":          record.Cards,
+		"card_details":   record.CardDetails,
+		"interpretation": record.Interpretation,
+		"locked":         record.IsLocked(),
+		"created_at":     record.CreatedAt,
+	})
+}
@@ -0,0 +1,123 @@
+package tarot
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"tarot/app/models/reading"
+	"tarot/pkg/database"
+	"tarot/pkg/share"
+	"tarot/pkg/testutil"
+)
+
+const testShareSecret = "share-secret"
+
+// newShareRouter 创建分享接口路由，X-Test-User 头模拟 CurrentUser 解析出的登录用户
+func newShareRouter(t *testing.T) *gin.Engine {
+	t.Helper()
+	testutil.SetupDB(t, &reading.Reading{})
+	testutil.SetConfig(t, "share.secret", testShareSecret)
+
+	sc := NewShareController()
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("user_id", c.GetHeader("X-Test-User"))
+		c.Next()
+	})
+	router.POST("/users/:user_id/readings/:task_id/share", sc.Store)
+	router.GET("/shared/:token", sc.Show)
+	return router
+}
+
+// createShare 以 user-1 的身份为解读生成分享令牌
+func createShare(t *testing.T, router *gin.Engine) string {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/users/user-1/readings/task-export/share", nil)
+	req.Header.Set("X-Test-User", "user-1")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("share status = %d, want 201: %s", w.Code, w.Body.String())
+	}
+
+	var body struct {
+		Data struct {
+			Token string `json:"token"`
+		} `json:"data"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &body)
+	return body.Data.Token
+}
+
+// getShared 匿名访问分享链接
+func getShared(router *gin.Engine, token string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/shared/"+token, nil))
+	return w
+}
+
+func TestSharedReadingWithValidToken(t *testing.T) {
+	router := newShareRouter(t)
+	exportedReading(t, reading.TypeFree, false)
+
+	w := getShared(router, createShare(t, router))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", w.Code, w.Body.String())
+	}
+	if !strings.Contains(w.Body.String(), "愚者预示着新的开始。") || strings.Contains(w.Body.String(), "user-1") {
+		t.Fatalf("shared reading should include the interpretation without the user id: %s", w.Body.String())
+	}
+}
+
+func TestSharedReadingHidesLockedInterpretation(t *testing.T) {
+	router := newShareRouter(t)
+	r := exportedReading(t, reading.TypePremium, false)
+	database.DB.Model(r).Update("interpretation", fullInterpretation)
+
+	w := getShared(router, createShare(t, router))
+	if w.Code != http.StatusOK || strings.Contains(w.Body.String(), fullInterpretation) || !strings.Contains(w.Body.String(), `"locked":true`) {
+		t.Fatalf("status = %d, want locked preview: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestSharedReadingWithExpiredToken(t *testing.T) {
+	router := newShareRouter(t)
+	r := exportedReading(t, reading.TypeFree, false)
+
+	w := getShared(router, share.Sign(testShareSecret, r.ID, time.Now().Add(-time.Minute)))
+	if w.Code != http.StatusNotFound || !strings.Contains(w.Body.String(), "分享链接已过期") {
+		t.Fatalf("status = %d, want 404 expired: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestSharedReadingWithTamperedToken(t *testing.T) {
+	router := newShareRouter(t)
+	exportedReading(t, reading.TypeFree, false)
+	token := createShare(t, router)
+
+	// 改动记录 ID 后签名不再匹配
+	tampered := "999" + token[strings.Index(token, "."):]
+	w := getShared(router, tampered)
+	if w.Code != http.StatusNotFound || !strings.Contains(w.Body.String(), "分享链接无效") {
+		t.Fatalf("status = %d, want 404 invalid: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestShareRequiresOwner(t *testing.T) {
+	router := newShareRouter(t)
+	exportedReading(t, reading.TypeFree, false)
+
+	req := httptest.NewRequest(http.MethodPost, "/users/user-1/readings/task-export/share", nil)
+	req.Header.Set("X-Test-User", "user-2")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("status = %d, want 403", w.Code)
+	}
+}
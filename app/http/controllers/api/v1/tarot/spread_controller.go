@@ -0,0 +1,24 @@
+package tarot
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"tarot/app/repositories"
+	"tarot/pkg/response"
+)
+
+type SpreadController struct{}
+
+func NewSpreadController() *SpreadController {
+	return &SpreadController{}
+}
+
+// Index 获取可用牌阵列表，创建解读时通过 spread_id 引用
+func (sc *SpreadController) Index(c *gin.Context) {
+	spreads, err := repositories.NewSpreadRepository().List(c.Request.Context())
+	if err != nil {
+		response.Abort500(c, "获取牌阵失败")
+		return
+	}
+	response.Data(c, spreads)
+}
@@ -0,0 +1,57 @@
+package user
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"tarot/app/models/feedback"
+	"tarot/app/repositories"
+	"tarot/pkg/logger"
+	"tarot/pkg/response"
+)
+
+type FeedbackController struct{}
+
+func NewFeedbackController() *FeedbackController {
+	return &FeedbackController{}
+}
+
+// Store 评价解读，重复提交时更新原有评价
+// POST /v1/users/:user_id/readings/:task_id/feedback
+func (fc *FeedbackController) Store(c *gin.Context) {
+	userID := c.Param("user_id")
+	if userID == "" || userID != c.GetString("user_id") {
+		response.Abort403(c)
+		return
+	}
+
+	var req struct {
+		Rating  int    `json:"rating" binding:"required,min=1,max=5"`
+		Comment string `json:"comment" binding:"max=1000"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, err, "请求参数错误")
+		return
+	}
+
+	// 只能评价自己的解读
+	ctx := c.Request.Context()
+	readingRecord, err := repositories.NewReadingRepository().GetByTaskID(ctx, userID, c.Param("task_id"))
+	if err != nil {
+		response.Abort404(c, "记录不存在")
+		return
+	}
+
+	saved, err := repositories.NewFeedbackRepository().Upsert(ctx, &feedback.Feedback{
+		ReadingID: readingRecord.ID,
+		UserID:    userID,
+		Rating:    req.Rating,
+		Comment:   req.Comment,
+	})
+	if err != nil {
+		logger.ErrorStringContext(ctx, "Feedback", "Upsert", err.Error())
+		response.Abort500(c, "保存评价失败")
+		return
+	}
+
+	response.Data(c, saved)
+}
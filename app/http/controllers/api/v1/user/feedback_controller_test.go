@@ -0,0 +1,104 @@
+package user
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"tarot/app/models/feedback"
+	"tarot/app/models/reading"
+	"tarot/pkg/database"
+	"tarot/pkg/testutil"
+)
+
+// newFeedbackRouter 创建评价接口路由，user-1 拥有解读 task-1
+func newFeedbackRouter(t *testing.T) *gin.Engine {
+	t.Helper()
+	testutil.SetupDB(t, &reading.Reading{}, &feedback.Feedback{})
+	for _, r := range []*reading.Reading{
+		{TaskID: "task-1", UserID: "user-1", Type: reading.TypeFree, Cards: reading.Cards{1}},
+		{TaskID: "task-2", UserID: "user-2", Type: reading.TypeFree, Cards: reading.Cards{2}},
+	} {
+		if err := database.DB.Create(r).Error; err != nil {
+			t.Fatalf("create reading: %v", err)
+		}
+	}
+
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("user_id", c.GetHeader("X-Test-User"))
+		c.Next()
+	})
+	router.POST("/users/:user_id/readings/:task_id/feedback", NewFeedbackController().Store)
+	return router
+}
+
+func postFeedback(router *gin.Engine, currentUser, path string, body map[string]interface{}) (*httptest.ResponseRecorder, feedback.Feedback) {
+	data, _ := json.Marshal(body)
+	req := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(data))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Test-User", currentUser)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	var resp struct {
+		Data feedback.Feedback `json:"data"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	return w, resp.Data
+}
+
+func countFeedback(t *testing.T) int64 {
+	t.Helper()
+	var n int64
+	database.DB.Model(&feedback.Feedback{}).Count(&n)
+	return n
+}
+
+func TestFeedbackCreateAndUpdate(t *testing.T) {
+	router := newFeedbackRouter(t)
+	path := "/users/user-1/readings/task-1/feedback"
+
+	w, created := postFeedback(router, "user-1", path, map[string]interface{}{"rating": 4})
+	if w.Code != http.StatusOK || created.Rating != 4 || created.UserID != "user-1" || created.ID == 0 {
+		t.Fatalf("create = %d %+v", w.Code, created)
+	}
+
+	w, updated := postFeedback(router, "user-1", path, map[string]interface{}{"rating": 2, "comment": "不太准"})
+	if w.Code != http.StatusOK || updated.ID != created.ID || updated.Rating != 2 || updated.Comment != "不太准" {
+		t.Fatalf("update = %d %+v, want the same feedback updated", w.Code, updated)
+	}
+	if n := countFeedback(t); n != 1 {
+		t.Fatalf("feedback rows = %d, want 1", n)
+	}
+}
+
+func TestFeedbackOwnership(t *testing.T) {
+	router := newFeedbackRouter(t)
+
+	// 路径中的用户与登录用户不一致
+	if w, _ := postFeedback(router, "user-2", "/users/user-1/readings/task-1/feedback", map[string]interface{}{"rating": 5}); w.Code != http.StatusForbidden {
+		t.Fatalf("other user's path = %d, want 403", w.Code)
+	}
+	// 评价他人的解读
+	if w, _ := postFeedback(router, "user-1", "/users/user-1/readings/task-2/feedback", map[string]interface{}{"rating": 5}); w.Code != http.StatusNotFound {
+		t.Fatalf("other user's reading = %d, want 404", w.Code)
+	}
+	if n := countFeedback(t); n != 0 {
+		t.Fatalf("feedback rows = %d, want 0", n)
+	}
+}
+
+func TestFeedbackRejectsInvalidRating(t *testing.T) {
+	router := newFeedbackRouter(t)
+
+	for _, rating := range []int{0, 6} {
+		if w, _ := postFeedback(router, "user-1", "/users/user-1/readings/task-1/feedback", map[string]interface{}{"rating": rating}); w.Code != http.StatusBadRequest {
+			t.Fatalf("rating %d: status = %d, want 400", rating, w.Code)
+		}
+	}
+}
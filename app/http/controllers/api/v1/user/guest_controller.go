@@ -0,0 +1,32 @@
+package user
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"tarot/app/repositories"
+	"tarot/pkg/config"
+	"tarot/pkg/response"
+)
+
+type GuestController struct{}
+
+func NewGuestController() *GuestController {
+	return &GuestController{}
+}
+
+// Store 创建游客会话
+// 未登录时以返回的 guest_id 作为解读请求的 user_id，注册后用于迁移游客的解读记录
+// POST /v1/guests
+func (gc *GuestController) Store(c *gin.Context) {
+	// 不传默认值，配置为 0 时游客不能免费测算，而不是回落为 1 次
+	g, err := repositories.NewGuestRepository().Create(c.Request.Context(), config.GetInt("tarot.guest_free_readings"))
+	if err != nil {
+		response.Abort500(c, "创建游客失败")
+		return
+	}
+
+	response.Created(c, gin.H{
+		"guest_id":      g.ID,
+		"free_readings": g.FreeReadings,
+	})
+}
@@ -0,0 +1,90 @@
+package user
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"tarot/app/models/guest"
+	"tarot/app/models/reading"
+	userModel "tarot/app/models/user"
+	"tarot/app/repositories"
+	"tarot/pkg/database"
+	"tarot/pkg/testutil"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// createGuest 请求 POST /guests，返回 data 字段
+func createGuest(t *testing.T) map[string]interface{} {
+	t.Helper()
+	router := gin.New()
+	router.POST("/guests", NewGuestController().Store)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/guests", nil))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("status = %d, want 201: %s", w.Code, w.Body.String())
+	}
+	var body struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	return body.Data
+}
+
+func TestStoreGuest(t *testing.T) {
+	testutil.SetupDB(t, &guest.Guest{})
+	testutil.SetConfig(t, "tarot.guest_free_readings", 2)
+
+	first, second := createGuest(t), createGuest(t)
+	id, _ := first["guest_id"].(string)
+	if len(id) != 36 || first["free_readings"] != float64(2) {
+		t.Fatalf("guest = %v, want a UUID with 2 free readings", first)
+	}
+	if second["guest_id"] == id {
+		t.Fatal("two guests share the same id")
+	}
+
+	testutil.SetConfig(t, "tarot.guest_free_readings", 0)
+	if none := createGuest(t); none["free_readings"] != float64(0) {
+		t.Fatalf("guest = %v, want 0 free readings when configured", none)
+	}
+
+	g, err := repositories.NewGuestRepository().GetByID(context.Background(), id)
+	if err != nil || g.FreeReadings != 2 {
+		t.Fatalf("stored guest = %+v, %v", g, err)
+	}
+}
+
+func TestStoredGuestIsMigratable(t *testing.T) {
+	testutil.SetupDB(t, &guest.Guest{}, &userModel.User{}, &reading.Reading{})
+	id, _ := createGuest(t)["guest_id"].(string)
+	if err := database.DB.Create(&userModel.User{ID: "user-1", ClerkID: "clerk_1"}).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+
+	err := guest.MigrateToUser(id, "user-1", []guest.ReadingData{{
+		Type: reading.TypeFree, Question: "我最近的事业运势如何？", Cards: reading.Cards{1}, Interpretation: "解读",
+	}})
+	if err != nil {
+		t.Fatalf("MigrateToUser: %v", err)
+	}
+
+	var u userModel.User
+	database.DB.First(&u, "id = ?", "user-1")
+	if u.GuestID != id {
+		t.Fatalf("user guest id = %q, want %q", u.GuestID, id)
+	}
+	if _, err := repositories.NewGuestRepository().GetByID(context.Background(), id); err == nil {
+		t.Fatal("migrated guest is still active")
+	}
+}
@@ -0,0 +1,104 @@
+package user
+
+import (
+	"errors"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"tarot/app/models/guest"
+	"tarot/app/repositories"
+	"tarot/pkg/logger"
+	"tarot/pkg/response"
+)
+
+type UserController struct{}
+
+func NewUserController() *UserController {
+	return &UserController{}
+}
+
+// UpdateRetention 设置解读记录保留天数
+// retention_days 为 0 表示使用全局保留策略
+func (uc *UserController) UpdateRetention(c *gin.Context) {
+	userID := c.Param("user_id")
+	if userID == "" || userID != c.GetString("user_id") {
+		response.Abort403(c)
+		return
+	}
+
+	var req struct {
+		RetentionDays *int `json:"retention_days" binding:"required,min=0,max=3650"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, err, "请求参数错误")
+		return
+	}
+
+	err := repositories.NewUserRepository().UpdateRetentionDays(c.Request.Context(), userID, *req.RetentionDays)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		response.Abort404(c, "用户不存在")
+		return
+	}
+	if err != nil {
+		response.Abort500(c, "更新保留设置失败")
+		return
+	}
+
+	response.Data(c, gin.H{
+		"user_id":        userID,
+		"retention_days": *req.RetentionDays,
+	})
+}
+
+// Migrate 将游客数据迁移到当前用户
+// 游客记录会被关联到用户并软删除，测算记录在同一事务中写入用户名下
+// POST /v1/users/:user_id/migrate
+func (uc *UserController) Migrate(c *gin.Context) {
+	userID := c.Param("user_id")
+	if userID == "" || userID != c.GetString("user_id") {
+		response.Abort403(c)
+		return
+	}
+
+	var req struct {
+		GuestID  string              `json:"guest_id"`
+		Readings []guest.ReadingData `json:"readings" binding:"max=100,dive"` // 单次最多迁移 100 条
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, err, "请求参数错误")
+		return
+	}
+
+	ctx := c.Request.Context()
+	if _, err := repositories.NewUserRepository().GetByID(ctx, userID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			response.Abort404(c, "用户不存在")
+			return
+		}
+		response.Abort500(c, "获取用户失败")
+		return
+	}
+	if req.GuestID != "" {
+		if _, err := repositories.NewGuestRepository().GetByID(ctx, req.GuestID); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				response.Abort404(c, "游客不存在或已迁移")
+				return
+			}
+			response.Abort500(c, "获取游客失败")
+			return
+		}
+	}
+
+	if err := guest.MigrateToUser(req.GuestID, userID, req.Readings); err != nil {
+		logger.ErrorStringContext(ctx, "User", "Migrate", err.Error())
+		response.Abort500(c, "迁移游客数据失败")
+		return
+	}
+
+	response.Data(c, gin.H{
+		"user_id":  userID,
+		"guest_id": req.GuestID,
+		"migrated": len(req.Readings),
+	})
+}
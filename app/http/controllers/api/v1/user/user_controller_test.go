@@ -0,0 +1,136 @@
+package user
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"tarot/app/models/guest"
+	"tarot/app/models/reading"
+	userModel "tarot/app/models/user"
+	"tarot/pkg/database"
+	"tarot/pkg/testutil"
+)
+
+// newUserRouter 创建用户接口路由，X-Test-User 头模拟登录用户
+func newUserRouter(t *testing.T) *gin.Engine {
+	t.Helper()
+	testutil.SetupDB(t, &guest.Guest{}, &userModel.User{}, &reading.Reading{})
+	if err := database.DB.Create(&userModel.User{ID: "user-1", ClerkID: "clerk_1"}).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	if err := database.DB.Create(&guest.Guest{ID: "guest-1", FreeReadings: 1}).Error; err != nil {
+		t.Fatalf("create guest: %v", err)
+	}
+
+	uc := NewUserController()
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		if userID := c.GetHeader("X-Test-User"); userID != "" {
+			c.Set("user_id", userID)
+		}
+		c.Next()
+	})
+	router.POST("/users/:user_id/migrate", uc.Migrate)
+	return router
+}
+
+func postMigrate(router *gin.Engine, currentUser, userID string, body map[string]interface{}) *httptest.ResponseRecorder {
+	data, _ := json.Marshal(body)
+	req := httptest.NewRequest(http.MethodPost, "/users/"+userID+"/migrate", bytes.NewReader(data))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Test-User", currentUser)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func migrationReading(question string) map[string]interface{} {
+	return map[string]interface{}{
+		"type":           "free",
+		"question":       question,
+		"cards":          []int{1, 2},
+		"interpretation": "解读内容",
+	}
+}
+
+func countUserReadings(t *testing.T, userID string) int64 {
+	t.Helper()
+	var n int64
+	database.DB.Model(&reading.Reading{}).Where("user_id = ?", userID).Count(&n)
+	return n
+}
+
+func TestMigrateGuestReadings(t *testing.T) {
+	router := newUserRouter(t)
+
+	w := postMigrate(router, "user-1", "user-1", map[string]interface{}{
+		"guest_id": "guest-1",
+		"readings": []interface{}{migrationReading("我最近的事业运势如何？"), migrationReading("这段感情会有结果吗？请指引")},
+	})
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"migrated":2`) {
+		t.Fatalf("response = %d %s, want 2 migrated", w.Code, w.Body.String())
+	}
+	if n := countUserReadings(t, "user-1"); n != 2 {
+		t.Fatalf("user readings = %d, want 2", n)
+	}
+
+	var u userModel.User
+	database.DB.First(&u, "id = ?", "user-1")
+	if u.GuestID != "guest-1" || u.ReadingsCount != 2 {
+		t.Fatalf("user = %+v, want guest linked and readings count 2", u)
+	}
+
+	// 已迁移的游客不能再次迁移
+	w = postMigrate(router, "user-1", "user-1", map[string]interface{}{
+		"guest_id": "guest-1",
+		"readings": []interface{}{migrationReading("我最近的事业运势如何？")},
+	})
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("second migration = %d, want 404", w.Code)
+	}
+}
+
+func TestMigrateEmptyPayloadIsNoop(t *testing.T) {
+	router := newUserRouter(t)
+
+	w := postMigrate(router, "user-1", "user-1", map[string]interface{}{"guest_id": "guest-1"})
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"migrated":0`) {
+		t.Fatalf("response = %d %s, want 0 migrated", w.Code, w.Body.String())
+	}
+	if n := countUserReadings(t, "user-1"); n != 0 {
+		t.Fatalf("user readings = %d, want 0", n)
+	}
+	var g guest.Guest
+	if err := database.DB.Where("id = ? AND deleted_at IS NULL", "guest-1").First(&g).Error; err != nil {
+		t.Fatalf("guest was consumed by an empty migration: %v", err)
+	}
+}
+
+func TestMigrateRejectsInvalidRequests(t *testing.T) {
+	router := newUserRouter(t)
+	valid := []interface{}{migrationReading("我最近的事业运势如何？")}
+
+	for _, tc := range []struct {
+		name        string
+		currentUser string
+		body        map[string]interface{}
+		want        int
+	}{
+		{"unknown guest", "user-1", map[string]interface{}{"guest_id": "guest-404", "readings": valid}, http.StatusNotFound},
+		{"other user", "user-2", map[string]interface{}{"guest_id": "guest-1", "readings": valid}, http.StatusForbidden},
+		{"short question", "user-1", map[string]interface{}{"guest_id": "guest-1", "readings": []interface{}{migrationReading("太短")}}, http.StatusBadRequest},
+	} {
+		if w := postMigrate(router, tc.currentUser, "user-1", tc.body); w.Code != tc.want {
+			t.Fatalf("%s: status = %d, want %d: %s", tc.name, w.Code, tc.want, w.Body.String())
+		}
+	}
+	if n := countUserReadings(t, "user-1"); n != 0 {
+		t.Fatalf("user readings = %d, want 0 after rejected migrations", n)
+	}
+}
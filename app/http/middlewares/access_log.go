@@ -0,0 +1,116 @@
+package middlewares
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"tarot/pkg/config"
+	"tarot/pkg/logger"
+)
+
+// accessLogField 访问日志字段，根据请求上下文与耗时生成 zap 字段
+type accessLogField func(c *gin.Context, latency time.Duration) zap.Field
+
+// accessLogFields 支持的访问日志字段
+var accessLogFields = map[string]accessLogField{
+	"method": func(c *gin.Context, _ time.Duration) zap.Field {
+		return zap.String("method", c.Request.Method)
+	},
+	"path": func(c *gin.Context, _ time.Duration) zap.Field {
+		return zap.String("path", c.Request.URL.Path)
+	},
+	"route": func(c *gin.Context, _ time.Duration) zap.Field {
+		return zap.String("route", c.FullPath())
+	},
+	"status": func(c *gin.Context, _ time.Duration) zap.Field {
+		return zap.Int("status", c.Writer.Status())
+	},
+	"duration": func(_ *gin.Context, latency time.Duration) zap.Field {
+		return zap.Float64("duration_ms", float64(latency.Microseconds())/1000)
+	},
+	"bytes": func(c *gin.Context, _ time.Duration) zap.Field {
+		size := c.Writer.Size()
+		if size < 0 {
+			size = 0
+		}
+		return zap.Int("bytes", size)
+	},
+	"ip": func(c *gin.Context, _ time.Duration) zap.Field {
+		return zap.String("ip", c.ClientIP())
+	},
+	"request_id": func(c *gin.Context, _ time.Duration) zap.Field {
+		return zap.String("request_id", c.GetString("request_id"))
+	},
+	"user_id": func(c *gin.Context, _ time.Duration) zap.Field {
+		return zap.String("user_id", c.GetString("user_id"))
+	},
+	"user_agent": func(c *gin.Context, _ time.Duration) zap.Field {
+		return zap.String("user_agent", c.Request.UserAgent())
+	},
+}
+
+// AccessLog 结构化访问日志，每个请求输出一行
+//
+// 配置项（log.access_*）：
+// - access_enabled：是否启用
+// - access_fields：输出的字段，逗号分隔，可选 method, path, route, status, duration, bytes, ip, request_id, user_id, user_agent
+// - access_sample_rate：采样率（0~1），5xx 响应始终记录
+//
+// 默认值由 config/log.go 提供；这里不传默认值，否则 false 与 0 会被当作未配置
+func AccessLog() gin.HandlerFunc {
+	if !config.GetBool("log.access_enabled") {
+		return func(c *gin.Context) {
+			c.Next()
+		}
+	}
+
+	var fields []accessLogField
+	for _, name := range splitConfigList(config.GetString("log.access_fields")) {
+		field, ok := accessLogFields[name]
+		if !ok {
+			logger.WarnString("AccessLog", "Config", "未知的访问日志字段: "+name)
+			continue
+		}
+		fields = append(fields, field)
+	}
+	sampleRate := config.GetFloat64("log.access_sample_rate")
+
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+		latency := time.Since(start)
+
+		status := c.Writer.Status()
+		if status < 500 && !sampled(sampleRate) {
+			return
+		}
+
+		logFields := make([]zap.Field, 0, len(fields))
+		for _, field := range fields {
+			logFields = append(logFields, field(c, latency))
+		}
+
+		switch {
+		case status >= 500:
+			logger.Error("HTTP Access", logFields...)
+		case status >= 400:
+			logger.Warn("HTTP Access", logFields...)
+		default:
+			logger.Info("HTTP Access", logFields...)
+		}
+	}
+}
+
+// sampled 按采样率决定是否记录本次请求
+func sampled(rate float64) bool {
+	if rate >= 1 {
+		return true
+	}
+	if rate <= 0 {
+		return false
+	}
+	return rand.Float64() < rate
+}
@@ -0,0 +1,118 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	"tarot/pkg/testutil"
+)
+
+// newAccessLogRouter 按指定字段与采样率创建挂载 AccessLog 的路由，返回记录的日志
+func newAccessLogRouter(t *testing.T, fields string, sampleRate float64) (*gin.Engine, *observer.ObservedLogs) {
+	t.Helper()
+	logs := testutil.ObserveLogs(t)
+	testutil.SetConfig(t, "log.access_enabled", true)
+	testutil.SetConfig(t, "log.access_fields", fields)
+	testutil.SetConfig(t, "log.access_sample_rate", sampleRate)
+
+	router := gin.New()
+	router.Use(RequestID(), func(c *gin.Context) {
+		c.Set("user_id", c.GetHeader("X-Test-User"))
+		c.Next()
+	}, AccessLog())
+	router.GET("/readings/:id", func(c *gin.Context) {
+		c.String(http.StatusOK, "hello")
+	})
+	router.GET("/boom", func(c *gin.Context) {
+		c.Status(http.StatusInternalServerError)
+	})
+	return router, logs
+}
+
+func accessRequest(router *gin.Engine, path string) {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	req.RemoteAddr = "10.1.0.1:12345"
+	req.Header.Set(RequestIDHeader, "req-access-1")
+	req.Header.Set("X-Test-User", "u1")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+}
+
+func TestAccessLogWritesOneStructuredLine(t *testing.T) {
+	router, logs := newAccessLogRouter(t, "method,path,route,status,duration,bytes,ip,request_id,user_id", 1)
+
+	accessRequest(router, "/readings/task-1")
+
+	entries := logs.FilterMessage("HTTP Access").All()
+	if len(entries) != 1 {
+		t.Fatalf("access log lines = %d, want 1", len(entries))
+	}
+	entry := entries[0]
+	if entry.Level != zapcore.InfoLevel {
+		t.Fatalf("level = %s, want info", entry.Level)
+	}
+	fields := entry.ContextMap()
+	for name, want := range map[string]interface{}{
+		"method":     "GET",
+		"path":       "/readings/task-1",
+		"route":      "/readings/:id",
+		"status":     int64(200),
+		"bytes":      int64(5),
+		"ip":         "10.1.0.1",
+		"request_id": "req-access-1",
+		"user_id":    "u1",
+	} {
+		if fields[name] != want {
+			t.Fatalf("field %s = %#v, want %#v (all fields %v)", name, fields[name], want, fields)
+		}
+	}
+	if _, ok := fields["duration_ms"].(float64); !ok {
+		t.Fatalf("duration_ms = %#v, want a float", fields["duration_ms"])
+	}
+}
+
+func TestAccessLogOnlyConfiguredFields(t *testing.T) {
+	router, logs := newAccessLogRouter(t, "status, unknown", 1)
+
+	accessRequest(router, "/readings/task-1")
+
+	if warnings := logs.FilterMessage("AccessLog").Len(); warnings != 1 {
+		t.Fatalf("unknown field warnings = %d, want 1", warnings)
+	}
+	entries := logs.FilterMessage("HTTP Access").All()
+	if len(entries) != 1 || len(entries[0].Context) != 1 || entries[0].ContextMap()["status"] != int64(200) {
+		t.Fatalf("entries = %+v, want only the status field", entries)
+	}
+}
+
+func TestAccessLogSamplingKeepsServerErrors(t *testing.T) {
+	router, logs := newAccessLogRouter(t, "status", 0)
+
+	accessRequest(router, "/readings/task-1")
+	accessRequest(router, "/boom")
+
+	entries := logs.FilterMessage("HTTP Access").All()
+	if len(entries) != 1 || entries[0].Level != zapcore.ErrorLevel || entries[0].ContextMap()["status"] != int64(500) {
+		t.Fatalf("entries = %+v, want only the 500 logged at error level", entries)
+	}
+}
+
+func TestAccessLogDisabled(t *testing.T) {
+	logs := testutil.ObserveLogs(t)
+	testutil.SetConfig(t, "log.access_enabled", false)
+
+	router := gin.New()
+	router.Use(AccessLog())
+	router.GET("/boom", func(c *gin.Context) {
+		c.Status(http.StatusInternalServerError)
+	})
+	accessRequest(router, "/boom")
+
+	if n := logs.FilterMessage("HTTP Access").Len(); n != 0 {
+		t.Fatalf("access log lines = %d, want none when disabled", n)
+	}
+}
@@ -0,0 +1,88 @@
+package middlewares
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"tarot/app/models/user"
+	"tarot/app/repositories"
+	"tarot/pkg/config"
+	"tarot/pkg/limiter"
+	"tarot/pkg/logger"
+	"tarot/pkg/response"
+)
+
+// identityHeaders 网关鉴权后透传的用户身份头
+var identityHeaders = []string{"X-User-ID", "X-Clerk-User-ID"}
+
+// CurrentUser 解析当前请求的用户身份
+// 用户身份由前端网关完成鉴权后透传：
+// - X-User-ID：本系统的用户 ID，直接使用
+// - X-Clerk-User-ID：Clerk 用户 ID，查找对应用户，首次访问时自动创建
+// 只有直连地址在 app.trusted_proxies 中的请求才信任这两个头，其余请求的身份头会被移除并视为游客，
+// 避免客户端直接伪造身份；解析结果写入上下文的 user_id，均未携带时视为游客
+func CurrentUser() gin.HandlerFunc {
+	trustedProxies, err := limiter.ParseIPList(config.GetString("app.trusted_proxies"))
+	if err != nil {
+		logger.ErrorString("Auth", "TrustedProxies", err.Error())
+	}
+
+	return func(c *gin.Context) {
+		if !trustedProxies.Contains(c.RemoteIP()) {
+			for _, header := range identityHeaders {
+				c.Request.Header.Del(header)
+			}
+			c.Next()
+			return
+		}
+
+		if userID := strings.TrimSpace(c.GetHeader("X-User-ID")); userID != "" {
+			c.Set("user_id", userID)
+			c.Next()
+			return
+		}
+
+		if clerkID := strings.TrimSpace(c.GetHeader("X-Clerk-User-ID")); clerkID != "" {
+			u, err := resolveClerkUser(c.Request.Context(), clerkID)
+			if err != nil {
+				logger.ErrorStringContext(c.Request.Context(), "Auth", "ClerkUser", err.Error())
+				response.Abort500(c, "获取用户信息失败")
+				return
+			}
+			c.Set("user_id", u.ID)
+		}
+		c.Next()
+	}
+}
+
+// resolveClerkUser 根据 Clerk 用户 ID 获取用户，不存在时创建
+func resolveClerkUser(ctx context.Context, clerkID string) (*user.User, error) {
+	repo := repositories.NewUserRepository()
+	u, err := repo.GetByClerkID(ctx, clerkID)
+	if err == nil {
+		return u, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	return repo.Upsert(ctx, &user.User{ClerkID: clerkID})
+}
+
+// AdminAuth 管理端接口鉴权
+// 请求需携带与 app.admin_token 一致的 X-Admin-Token 头；未配置令牌时拒绝所有请求
+func AdminAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := config.GetString("app.admin_token")
+		provided := c.GetHeader("X-Admin-Token")
+		if token == "" || subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+			response.Abort403(c, "无管理权限")
+			return
+		}
+		c.Next()
+	}
+}
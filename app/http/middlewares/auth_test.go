@@ -0,0 +1,53 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"tarot/pkg/testutil"
+)
+
+// currentUserOf 以指定直连地址请求，返回 CurrentUser 解析出的用户与处理器看到的身份头
+func currentUserOf(t *testing.T, remoteIP, userHeader string) (userID, header string) {
+	t.Helper()
+	router := gin.New()
+	router.GET("/me", CurrentUser(), func(c *gin.Context) {
+		userID = c.GetString("user_id")
+		header = c.GetHeader("X-User-ID")
+		c.Status(http.StatusOK)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/me", nil)
+	req.RemoteAddr = remoteIP + ":12345"
+	req.Header.Set("X-User-ID", userHeader)
+	router.ServeHTTP(httptest.NewRecorder(), req)
+	return userID, header
+}
+
+func TestCurrentUserIgnoresIdentityHeaderFromUntrustedPeer(t *testing.T) {
+	testutil.SetConfig(t, "app.trusted_proxies", "10.0.0.0/8")
+
+	userID, header := currentUserOf(t, "203.0.113.7", "victim")
+	if userID != "" || header != "" {
+		t.Fatalf("user_id=%q header=%q, want guest with header stripped", userID, header)
+	}
+}
+
+func TestCurrentUserTrustsIdentityHeaderFromProxy(t *testing.T) {
+	testutil.SetConfig(t, "app.trusted_proxies", "10.0.0.0/8")
+
+	if userID, _ := currentUserOf(t, "10.1.2.3", "user-1"); userID != "user-1" {
+		t.Fatalf("user_id = %q, want user-1", userID)
+	}
+}
+
+func TestCurrentUserWithoutTrustedProxiesIsGuest(t *testing.T) {
+	testutil.SetConfig(t, "app.trusted_proxies", "")
+
+	if userID, _ := currentUserOf(t, "127.0.0.1", "user-1"); userID != "" {
+		t.Fatalf("user_id = %q, want guest", userID)
+	}
+}
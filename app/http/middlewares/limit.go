@@ -0,0 +1,547 @@
+package middlewares
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"tarot/pkg/app"
+	"tarot/pkg/config"
+	"tarot/pkg/limiter"
+	"tarot/pkg/logger"
+	"tarot/pkg/response"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/cast"
+	limiterlib "github.com/ulule/limiter/v3"
+	"golang.org/x/time/rate"
+)
+
+const (
+	// DefaultBurst 未指定突发数量时的上限，也是限流格式无法解析时的突发数量
+	DefaultBurst = 100
+	// burstDivisor 未指定突发数量时，突发数量取时间窗口额度的 1/burstDivisor
+	burstDivisor = 10
+	// DefaultTimeout 默认等待超时时间
+	DefaultTimeout = 50 * time.Millisecond
+)
+
+var (
+	// 用于存储限流器的并发安全缓存
+	limiters sync.Map
+	// 用于存储上次清理时间的并发安全Map
+	lastCleanup sync.Map
+	// 清理任务只启动一次，所有限流中间件共用
+	cleanupOnce sync.Once
+
+	// Redis 计数的查询与计入，测试中可替换以模拟 Redis 故障或并发抢占
+	peekLimit = limiter.PeekWith
+	takeLimit = limiter.TakeWith
+
+	// 限流白名单与黑名单，首次创建限流中间件时解析
+	ipListsOnce sync.Once
+	allowlist   limiter.IPList
+	denylist    limiter.IPList
+)
+
+// RateLimitConfig 限流配置
+type RateLimitConfig struct {
+	Limit   string
+	Burst   int
+	Timeout time.Duration
+}
+
+// LimitRule 单条限流规则
+type LimitRule struct {
+	Name    string                     // 规则名称，超限时返回给客户端
+	Limit   string                     // 限流格式，如 "100-H"
+	KeyFunc func(*gin.Context) string // 限流键，返回空字符串时跳过该规则
+	// Burst 突发请求数量，<= 0 时按速率推算，见 ruleBurst；
+	// 显式指定时 Redis 计数同样生效：burst / 速率 的时间内最多 burst 次请求，推算值只用于进程内令牌桶
+	Burst int
+	// Algorithm Redis 计数使用的算法，默认固定窗口；需要严格按时间窗口计数时使用 limiter.SlidingWindow
+	// Redis 不可用时两者都降级为进程内令牌桶
+	Algorithm limiter.Algorithm
+}
+
+// LimitIP 全局限流中间件，针对 IP 进行限流
+//
+// 支持的限流格式:
+// - 5 reqs/second:   "5-S"
+// - 10 reqs/minute:  "10-M"
+// - 1000 reqs/hour:  "1000-H"
+// - 2000 reqs/day:   "2000-D"
+//
+// 特性:
+// - 支持突发流量处理
+// - 自动清理过期限流器
+// - 高并发安全
+// - 优雅降级
+//
+// burst 可选，指定突发请求数量，Redis 计数与进程内令牌桶均生效；未指定时按速率推算，仅用于进程内令牌桶
+func LimitIP(limit string, burst ...int) gin.HandlerFunc {
+	// 测试环境用较大限制
+	if app.IsTesting() {
+		limit = "1000000-H"
+	}
+
+	return createLimiterHandler(LimitRule{
+		Name:    "ip",
+		Limit:   limit,
+		KeyFunc: limiter.GetKeyIP,
+		Burst:   optionalBurst(burst),
+	})
+}
+
+// LimitPerRoute 针对单个路由的限流中间件
+//
+// 特性:
+// - 基于 IP + 路由路径进行限流
+// - 支持动态调整限流策略
+// - 自动清理过期数据
+//
+// burst 可选，指定突发请求数量，Redis 计数与进程内令牌桶均生效；未指定时按速率推算，仅用于进程内令牌桶
+func LimitPerRoute(limit string, burst ...int) gin.HandlerFunc {
+	if app.IsTesting() {
+		limit = "1000000-H"
+	}
+
+	return createLimiterHandler(LimitRule{
+		Name:    "route",
+		Limit:   limit,
+		KeyFunc: limiter.GetKeyRouteWithIP,
+		Burst:   optionalBurst(burst),
+	})
+}
+
+// LimitSliding 按滑动窗口计数的限流中间件，任意一个时间窗口内的请求数都不超过额度
+// 默认的固定窗口在窗口交界处短时间内最多可通过两倍额度，对额度要求严格的接口使用本中间件
+func LimitSliding(rules ...LimitRule) gin.HandlerFunc {
+	for i := range rules {
+		rules[i].Algorithm = limiter.SlidingWindow
+		if app.IsTesting() {
+			rules[i].Limit = "1000000-H"
+		}
+	}
+	return createLimiterHandler(rules...)
+}
+
+// optionalBurst 取可选参数中的突发数量，未指定时为 0
+func optionalBurst(burst []int) int {
+	if len(burst) > 0 {
+		return burst[0]
+	}
+	return 0
+}
+
+// ruleBurst 规则的令牌桶突发数量
+// 指定了 burst 时使用指定值（不超过时间窗口额度）；未指定时取时间窗口额度的 1/burstDivisor，
+// 至少为 1、至多为 DefaultBurst，例如 "100-H" 为 10，避免短时间内用完整个时间窗口的额度
+func ruleBurst(rule LimitRule, r *limiter.Rate) int {
+	if r == nil {
+		if rule.Burst > 0 {
+			return rule.Burst
+		}
+		return DefaultBurst
+	}
+	if rule.Burst > 0 {
+		return int(min(int64(rule.Burst), r.Limit))
+	}
+	return int(max(1, min(r.Limit/burstDivisor, DefaultBurst)))
+}
+
+// burstWindow 显式指定突发数量时 Redis 计数使用的突发窗口：burst / 速率 的时间内最多 burst 次请求，
+// 与令牌桶从空到补满 burst 个令牌所需的时间一致；未指定或不小于时间窗口额度时返回 nil
+func burstWindow(rule LimitRule, r *limiter.Rate) *limiterlib.Rate {
+	if rule.Burst <= 0 || r == nil || r.Rate <= 0 || int64(rule.Burst) >= r.Limit {
+		return nil
+	}
+	return &limiterlib.Rate{
+		Limit:  int64(rule.Burst),
+		Period: time.Duration(float64(rule.Burst) / r.Rate * float64(time.Second)),
+	}
+}
+
+// LimitPerUser 按登录用户限流的中间件，游客按 IP 计算
+//
+// 特性:
+// - 共享 NAT 下的不同用户互不影响
+// - 同一用户切换 IP 仍计入同一份额度
+// - 计数保存在 Redis 中，多实例共享
+func LimitPerUser(limit string) gin.HandlerFunc {
+	if app.IsTesting() {
+		limit = "1000000-H"
+	}
+
+	return createLimiterHandler(userRule(limit))
+}
+
+// LimitIPAndUser 同时按 IP 与用户限流，任一额度耗尽即拒绝请求
+//
+// 适用于开销较大的接口：
+// - 防止单个用户切换 IP 绕过限制
+// - 防止同一 IP 下批量注册的用户绕过限制
+// 游客（未登录）的用户额度按 IP 计算
+func LimitIPAndUser(ipLimit, userLimit string) gin.HandlerFunc {
+	if app.IsTesting() {
+		ipLimit = "1000000-H"
+		userLimit = "1000000-H"
+	}
+
+	return LimitCombined(
+		LimitRule{Name: "ip", Limit: ipLimit, KeyFunc: limiter.GetKeyIP},
+		userRule(userLimit),
+	)
+}
+
+// LimitScopedIPAndUser 与 LimitIPAndUser 相同，但使用独立的额度
+// 规则名称为 scope-ip 与 scope-user，计数不与其他接口共享
+func LimitScopedIPAndUser(scope, ipLimit, userLimit string) gin.HandlerFunc {
+	if app.IsTesting() {
+		ipLimit = "1000000-H"
+		userLimit = "1000000-H"
+	}
+
+	user := userRule(userLimit)
+	user.Name = scope + "-user"
+	return LimitCombined(
+		LimitRule{Name: scope + "-ip", Limit: ipLimit, KeyFunc: limiter.GetKeyIP},
+		user,
+	)
+}
+
+// userRule 按用户限流的规则，游客回退为 IP
+func userRule(limit string) LimitRule {
+	return LimitRule{Name: "user", Limit: limit, KeyFunc: limiter.GetKeyUserOrIP}
+}
+
+// LimitCombined 组合多条限流规则，所有规则都放行时请求才会通过
+func LimitCombined(rules ...LimitRule) gin.HandlerFunc {
+	return createLimiterHandler(rules...)
+}
+
+// createLimiterHandler 创建限流处理器
+// rules: 限流规则，按顺序检查，任一规则超限即拒绝
+//
+// 计数保存在 Redis 中，多实例部署时共享同一份额度；
+// Redis 不可用时该规则降级为进程内令牌桶，保证限流不失效
+func createLimiterHandler(rules ...LimitRule) gin.HandlerFunc {
+	// 定期清理过期的限流器
+	cleanupOnce.Do(func() { go cleanupLimiters() })
+
+	ipListsOnce.Do(loadIPLists)
+
+	configs := make([]RateLimitConfig, len(rules))
+	rates := make([]*limiter.Rate, len(rules))
+	bursts := make([]*limiterlib.Rate, len(rules))
+	for i, rule := range rules {
+		r, err := limiter.ParseLimit(rule.Limit)
+		if err != nil {
+			logger.ErrorString("限流器", "配置错误", err.Error())
+		}
+		rates[i] = r
+		configs[i] = RateLimitConfig{
+			Limit:   rule.Limit,
+			Burst:   ruleBurst(rule, r),
+			Timeout: DefaultTimeout,
+		}
+		bursts[i] = burstWindow(rule, r)
+	}
+
+	return func(c *gin.Context) {
+		// 0. 黑名单直接拒绝，白名单跳过限流，均不计入额度
+		clientIP := c.ClientIP()
+		if denylist.Contains(clientIP) {
+			response.Abort403(c, "访问被拒绝")
+			return
+		}
+		if allowlist.Contains(clientIP) {
+			c.Next()
+			return
+		}
+
+		keys := make([]string, len(rules))
+		distributed := make([]bool, len(rules))
+		// 响应头只报告剩余额度最少的规则
+		var tightest *rateLimitQuota
+
+		// 1. 先查询所有规则的剩余额度，任一耗尽即拒绝，避免未通过的请求消耗其他规则的额度
+		for i, rule := range rules {
+			key := rule.KeyFunc(c)
+			if key == "" {
+				continue
+			}
+			keys[i] = rule.Name + ":" + key
+
+			state, err := peekLimit(c, rule.Algorithm, keys[i], rule.Limit)
+			if err != nil {
+				logger.WarnString("限流器", "Redis 不可用", err.Error())
+				continue
+			}
+			distributed[i] = true
+			if state.Remaining <= 0 {
+				abortLimited(c, rule.Name, resetDelay(state))
+				return
+			}
+
+			if bursts[i] == nil {
+				continue
+			}
+			state, err = limiter.SlidingRate(c, keys[i]+":burst", *bursts[i], false)
+			if err != nil {
+				logger.WarnString("限流器", "Redis 不可用", err.Error())
+				continue
+			}
+			if state.Remaining <= 0 {
+				abortLimited(c, rule.Name, resetDelay(state))
+				return
+			}
+		}
+
+		// 2. 降级规则使用进程内令牌桶，超限时归还已预留的令牌
+		// 预留与归还使用同一时间点，已生效的预留用 Cancel 无法归还令牌
+		now := time.Now()
+		reservations := make([]*rate.Reservation, 0, len(rules))
+		for i, rule := range rules {
+			if keys[i] == "" || distributed[i] {
+				continue
+			}
+
+			lim, err := getLimiter(keys[i], configs[i])
+			if err != nil {
+				logger.ErrorString("限流器", "创建失败", err.Error())
+				// 降级处理：跳过该规则
+				continue
+			}
+
+			r := lim.ReserveN(now, 1)
+			if !r.OK() || r.DelayFrom(now) > 0 {
+				// 取消预留前记录需要等待的时间，作为 Retry-After
+				delay := r.DelayFrom(now)
+				if !r.OK() {
+					delay = time.Second
+				}
+				r.CancelAt(now)
+				cancelReservations(reservations, now)
+				abortLimited(c, rule.Name, delay)
+				return
+			}
+			reservations = append(reservations, r)
+			tightest = tighterQuota(tightest, bucketQuota(lim, rates[i], configs[i].Burst))
+		}
+
+		// 3. 计入 Redis 计数；并发请求可能在查询后抢先耗尽额度，以计数结果为准，超限时同样归还第 2 步预留的令牌
+		for i, rule := range rules {
+			if !distributed[i] {
+				continue
+			}
+
+			// 突发额度先计入：滑动窗口不计入被拒绝的请求，超限时不会消耗时间窗口额度
+			if bursts[i] != nil {
+				state, err := limiter.SlidingRate(c, keys[i]+":burst", *bursts[i], true)
+				if err != nil {
+					logger.WarnString("限流器", "Redis 不可用", err.Error())
+				} else if state.Reached {
+					cancelReservations(reservations, now)
+					abortLimited(c, rule.Name, resetDelay(state))
+					return
+				} else {
+					tightest = tighterQuota(tightest, &rateLimitQuota{
+						Limit:     state.Limit,
+						Remaining: state.Remaining,
+						Reset:     resetDelay(state),
+						Window:    bursts[i].Period,
+					})
+				}
+			}
+
+			state, err := takeLimit(c, rule.Algorithm, keys[i], rule.Limit)
+			if err != nil {
+				logger.WarnString("限流器", "Redis 不可用", err.Error())
+				continue
+			}
+			if state.Reached {
+				cancelReservations(reservations, now)
+				abortLimited(c, rule.Name, resetDelay(state))
+				return
+			}
+			tightest = tighterQuota(tightest, distributedQuota(state, rates[i]))
+		}
+
+		if tightest != nil {
+			setRateLimitHeaders(c, *tightest)
+		}
+		c.Next()
+	}
+}
+
+// cancelReservations 请求被拒绝时归还已预留的令牌，at 为预留时使用的时间点
+func cancelReservations(reservations []*rate.Reservation, at time.Time) {
+	for _, r := range reservations {
+		r.CancelAt(at)
+	}
+}
+
+// loadIPLists 解析配置中的限流白名单与黑名单
+func loadIPLists() {
+	var err error
+	if allowlist, err = limiter.ParseIPList(config.GetString("limiter.allowlist")); err != nil {
+		logger.ErrorString("限流器", "白名单配置错误", err.Error())
+	}
+	if denylist, err = limiter.ParseIPList(config.GetString("limiter.denylist")); err != nil {
+		logger.ErrorString("限流器", "黑名单配置错误", err.Error())
+	}
+}
+
+// abortLimited 以 429 状态码拒绝超限请求，retryAfter 为额度恢复前需要等待的时间
+func abortLimited(c *gin.Context, ruleName string, retryAfter time.Duration) {
+	c.Header("Retry-After", strconv.FormatInt(retryAfterSeconds(retryAfter), 10))
+	response.FailWithData(c, http.StatusTooManyRequests, response.CodeRateLimited, "请求太频繁，请稍后再试", gin.H{
+		"limit": ruleName,
+	})
+}
+
+// resetDelay 计算 Redis 限流窗口重置前的剩余时间
+func resetDelay(state limiterlib.Context) time.Duration {
+	return time.Until(time.Unix(state.Reset, 0))
+}
+
+// retryAfterSeconds 将等待时间向上取整为秒，至少为 1 秒
+func retryAfterSeconds(d time.Duration) int64 {
+	if seconds := ceilSeconds(d); seconds > 1 {
+		return seconds
+	}
+	return 1
+}
+
+// ceilSeconds 将时长向上取整为秒，负数按 0 处理
+func ceilSeconds(d time.Duration) int64 {
+	if d <= 0 {
+		return 0
+	}
+	return int64((d + time.Second - 1) / time.Second)
+}
+
+// getLimiter 获取或创建限流器
+func getLimiter(key string, config RateLimitConfig) (*rate.Limiter, error) {
+	// 尝试从缓存获取限流器
+	if lim, exists := limiters.Load(key); exists {
+		return lim.(*rate.Limiter), nil
+	}
+
+	// 解析限流配置
+	r, err := limiter.ParseLimit(config.Limit)
+	if err != nil {
+		return nil, err
+	}
+
+	// 创建新的限流器
+	lim := rate.NewLimiter(rate.Limit(r.Rate), config.Burst)
+
+	// 并发安全地存储限流器
+	actual, _ := limiters.LoadOrStore(key, lim)
+	return actual.(*rate.Limiter), nil
+}
+
+// rateLimitQuota 单条规则的额度状态，用于生成响应头
+type rateLimitQuota struct {
+	Limit     int64         // 每个时间窗口允许的请求数
+	Remaining int64         // 当前剩余请求数
+	Reset     time.Duration // 额度完全恢复前的剩余时间
+	Window    time.Duration // 时间窗口长度
+}
+
+// bucketQuota 根据进程内令牌桶计算额度状态
+// 令牌按配置速率匀速恢复，Reset 为令牌桶补满所需的时间
+func bucketQuota(lim *rate.Limiter, r *limiter.Rate, burst int) *rateLimitQuota {
+	if r == nil || r.Rate <= 0 {
+		return nil
+	}
+
+	tokens := lim.Tokens()
+	if tokens < 0 {
+		tokens = 0
+	}
+	remaining := int64(tokens)
+	if remaining > r.Limit {
+		remaining = r.Limit
+	}
+
+	missing := float64(burst) - tokens
+	if missing < 0 {
+		missing = 0
+	}
+
+	return &rateLimitQuota{
+		Limit:     r.Limit,
+		Remaining: remaining,
+		Reset:     time.Duration(missing / r.Rate * float64(time.Second)),
+		Window:    r.Period,
+	}
+}
+
+// distributedQuota 根据 Redis 限流状态计算额度状态
+func distributedQuota(state limiterlib.Context, r *limiter.Rate) *rateLimitQuota {
+	q := &rateLimitQuota{
+		Limit:     state.Limit,
+		Remaining: state.Remaining,
+		Reset:     resetDelay(state),
+	}
+	if r != nil {
+		q.Window = r.Period
+	}
+	return q
+}
+
+// tighterQuota 返回剩余额度更少的一方，相同时取恢复更慢的一方
+func tighterQuota(current, next *rateLimitQuota) *rateLimitQuota {
+	if next == nil {
+		return current
+	}
+	if current == nil ||
+		next.Remaining < current.Remaining ||
+		(next.Remaining == current.Remaining && next.Reset > current.Reset) {
+		return next
+	}
+	return current
+}
+
+// setRateLimitHeaders 设置限流相关的响应头
+// 同时输出 RateLimit 草案标准头（Reset 为相对秒数）与兼容的 X-RateLimit 头（Reset 为 Unix 时间戳）
+func setRateLimitHeaders(c *gin.Context, q rateLimitQuota) {
+	reset := ceilSeconds(q.Reset)
+
+	c.Header("RateLimit-Limit", cast.ToString(q.Limit))
+	c.Header("RateLimit-Remaining", cast.ToString(q.Remaining))
+	c.Header("RateLimit-Reset", cast.ToString(reset))
+	if q.Window > 0 {
+		c.Header("RateLimit-Policy", fmt.Sprintf("%d;w=%d", q.Limit, ceilSeconds(q.Window)))
+	}
+
+	c.Header("X-RateLimit-Limit", cast.ToString(q.Limit))
+	c.Header("X-RateLimit-Remaining", cast.ToString(q.Remaining))
+	c.Header("X-RateLimit-Reset", cast.ToString(time.Now().Unix()+reset))
+}
+
+// cleanupLimiters 定期���理过期的限流器
+func cleanupLimiters() {
+	ticker := time.NewTicker(1 * time.Hour)
+	for range ticker.C {
+		now := time.Now()
+		limiters.Range(func(key, value interface{}) bool {
+			lastAccess, _ := lastCleanup.Load(key)
+			if lastAccess == nil {
+				lastCleanup.Store(key, now)
+				return true
+			}
+
+			// 清理超过24小时未使用的限流器
+			if now.Sub(lastAccess.(time.Time)) > 24*time.Hour {
+				limiters.Delete(key)
+				lastCleanup.Delete(key)
+			}
+			return true
+		})
+	}
+}
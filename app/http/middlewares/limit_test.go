@@ -0,0 +1,591 @@
+package middlewares
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	limiterlib "github.com/ulule/limiter/v3"
+
+	"tarot/pkg/limiter"
+	"tarot/pkg/testutil"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// newLimitedRouter 创建挂载限流中间件的路由，X-Test-User 头模拟已登录用户
+func newLimitedRouter(limit gin.HandlerFunc) *gin.Engine {
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		if userID := c.GetHeader("X-Test-User"); userID != "" {
+			c.Set("user_id", userID)
+		}
+		c.Next()
+	})
+	router.GET("/limited", limit, func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	return router
+}
+
+// serveLimited 以指定 IP 与用户发起请求
+func serveLimited(router *gin.Engine, ip, userID string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/limited", nil)
+	req.RemoteAddr = net.JoinHostPort(ip, "12345")
+	if userID != "" {
+		req.Header.Set("X-Test-User", userID)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// limitedRequest 以指定 IP 与用户发起请求，返回状态码与超限的规则名称
+func limitedRequest(t *testing.T, router *gin.Engine, ip, userID string) (int, string) {
+	t.Helper()
+	w := serveLimited(router, ip, userID)
+
+	if w.Code != http.StatusTooManyRequests {
+		return w.Code, ""
+	}
+	var body struct {
+		Data struct {
+			Limit string `json:"limit"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode 429 body: %v", err)
+	}
+	return w.Code, body.Data.Limit
+}
+
+func TestLimitIPAndUserIPLimitBlocksUserUnderBudget(t *testing.T) {
+	testutil.SetupRedis(t)
+	router := newLimitedRouter(LimitIPAndUser("2-M", "10-M"))
+
+	// 同一 IP 下的不同用户共享 IP 额度
+	for _, userID := range []string{"u1", "u2"} {
+		if code, _ := limitedRequest(t, router, "10.0.0.1", userID); code != http.StatusOK {
+			t.Fatalf("request for %s = %d, want 200", userID, code)
+		}
+	}
+	code, limit := limitedRequest(t, router, "10.0.0.1", "u3")
+	if code != http.StatusTooManyRequests || limit != "ip" {
+		t.Fatalf("third request = %d (%q), want 429 by ip", code, limit)
+	}
+
+	// 其他 IP 不受影响
+	if code, _ := limitedRequest(t, router, "10.0.0.2", "u3"); code != http.StatusOK {
+		t.Fatalf("request from another ip = %d, want 200", code)
+	}
+}
+
+func TestLimitIPAndUserUserLimitBlocksAcrossIPs(t *testing.T) {
+	testutil.SetupRedis(t)
+	router := newLimitedRouter(LimitIPAndUser("10-M", "2-M"))
+
+	// 同一用户切换 IP 仍计入同一份用户额度
+	for _, ip := range []string{"10.0.1.1", "10.0.1.2"} {
+		if code, _ := limitedRequest(t, router, ip, "u1"); code != http.StatusOK {
+			t.Fatalf("request from %s = %d, want 200", ip, code)
+		}
+	}
+	code, limit := limitedRequest(t, router, "10.0.1.3", "u1")
+	if code != http.StatusTooManyRequests || limit != "user" {
+		t.Fatalf("third request = %d (%q), want 429 by user", code, limit)
+	}
+
+	// 同一 IP 下的其他用户不受影响
+	if code, _ := limitedRequest(t, router, "10.0.1.3", "u2"); code != http.StatusOK {
+		t.Fatalf("request for another user = %d, want 200", code)
+	}
+}
+
+func TestLimitIPAndUserRejectedRequestDoesNotConsumeOtherBudget(t *testing.T) {
+	testutil.SetupRedis(t)
+	router := newLimitedRouter(LimitIPAndUser("1-M", "2-M"))
+
+	if code, _ := limitedRequest(t, router, "10.0.2.1", "u1"); code != http.StatusOK {
+		t.Fatalf("first request = %d, want 200", code)
+	}
+	// 被 IP 额度拒绝的请求不消耗用户额度
+	for i := 0; i < 3; i++ {
+		if code, limit := limitedRequest(t, router, "10.0.2.1", "u1"); limit != "ip" {
+			t.Fatalf("request %d = %d (%q), want 429 by ip", i, code, limit)
+		}
+	}
+	if code, _ := limitedRequest(t, router, "10.0.2.2", "u1"); code != http.StatusOK {
+		t.Fatalf("request from another ip = %d, want 200 (user budget left)", code)
+	}
+}
+
+func TestLimitScopedIPAndUserKeepsSeparateBudget(t *testing.T) {
+	testutil.SetupRedis(t)
+	readings := newLimitedRouter(LimitIPAndUser("1-M", "1-M"))
+	draws := newLimitedRouter(LimitScopedIPAndUser("draw", "1-M", "1-M"))
+
+	if code, _ := limitedRequest(t, draws, "10.0.2.5", "u1"); code != http.StatusOK {
+		t.Fatalf("draw = %d, want 200", code)
+	}
+	// 抽牌用完自己的额度后，同一 IP 与用户仍可创建解读
+	if code, limit := limitedRequest(t, draws, "10.0.2.5", "u1"); code != http.StatusTooManyRequests || limit != "draw-ip" {
+		t.Fatalf("second draw = %d (%q), want 429 by draw-ip", code, limit)
+	}
+	if code, _ := limitedRequest(t, readings, "10.0.2.5", "u1"); code != http.StatusOK {
+		t.Fatalf("reading after draws = %d, want 200", code)
+	}
+	// 用户额度同样独立
+	if code, limit := limitedRequest(t, draws, "10.0.2.6", "u1"); code != http.StatusTooManyRequests || limit != "draw-user" {
+		t.Fatalf("draw from another ip = %d (%q), want 429 by draw-user", code, limit)
+	}
+}
+
+func TestLimitSharedAcrossInstances(t *testing.T) {
+	testutil.SetupRedis(t)
+	// 两个实例各自创建中间件，计数保存在同一个 Redis 中
+	instances := []*gin.Engine{
+		newLimitedRouter(LimitIPAndUser("10-M", "2-M")),
+		newLimitedRouter(LimitIPAndUser("10-M", "2-M")),
+	}
+
+	for i, router := range instances {
+		if code, _ := limitedRequest(t, router, "10.0.3.1", "u1"); code != http.StatusOK {
+			t.Fatalf("request to instance %d = %d, want 200", i, code)
+		}
+	}
+	for i, router := range instances {
+		if code, limit := limitedRequest(t, router, "10.0.3.1", "u1"); code != http.StatusTooManyRequests || limit != "user" {
+			t.Fatalf("instance %d after shared budget used = %d (%q), want 429 by user", i, code, limit)
+		}
+	}
+}
+
+func TestLimitRedisRejectionReleasesLocalReservations(t *testing.T) {
+	testutil.SetupRedis(t)
+	// ip 规则的 Redis 查询失败，降级为进程内令牌桶；user 规则查询时仍有额度，计入时被并发请求抢先用完
+	prevPeek, prevTake := peekLimit, takeLimit
+	t.Cleanup(func() { peekLimit, takeLimit = prevPeek, prevTake })
+	peekLimit = func(ctx context.Context, algorithm limiter.Algorithm, key, formatted string) (limiterlib.Context, error) {
+		if key == "ip:10.0.4.1" {
+			return limiterlib.Context{}, errors.New("redis down")
+		}
+		return limiterlib.Context{Limit: 10, Remaining: 1}, nil
+	}
+	takeLimit = func(ctx context.Context, algorithm limiter.Algorithm, key, formatted string) (limiterlib.Context, error) {
+		return limiterlib.Context{Limit: 10, Reached: true, Reset: time.Now().Add(time.Minute).Unix()}, nil
+	}
+
+	router := newLimitedRouter(LimitCombined(
+		LimitRule{Name: "ip", Limit: "60-M", KeyFunc: limiter.GetKeyIP, Burst: 3},
+		LimitRule{Name: "user", Limit: "10-M", KeyFunc: limiter.GetKeyUserOrIP},
+	))
+	for i := 0; i < 5; i++ {
+		if code, limit := limitedRequest(t, router, "10.0.4.1", "u1"); limit != "user" {
+			t.Fatalf("request %d = %d (%q), want 429 by user", i, code, limit)
+		}
+	}
+
+	lim, err := getLimiter("ip:10.0.4.1", RateLimitConfig{Limit: "60-M", Burst: 3})
+	if err != nil {
+		t.Fatalf("getLimiter: %v", err)
+	}
+	if tokens := lim.Tokens(); tokens < 2.9 {
+		t.Fatalf("local tokens = %.2f, want reservations returned", tokens)
+	}
+}
+
+func TestCreateLimiterHandlerStartsOneCleanup(t *testing.T) {
+	testutil.SetupRedis(t)
+	LimitIP("10-M")
+	before := runtime.NumGoroutine()
+	for i := 0; i < 50; i++ {
+		LimitIP("10-M")
+	}
+	if after := runtime.NumGoroutine(); after-before >= 50 {
+		t.Fatalf("goroutines grew from %d to %d, want a single cleanup goroutine", before, after)
+	}
+}
+
+func TestLimitPerRouteBurstAppliesToRedis(t *testing.T) {
+	testutil.SetupRedis(t)
+	// 每小时 100 次，突发 3 次：短时间内不能用完整个小时的额度
+	router := newLimitedRouter(LimitPerRoute("100-H", 3))
+
+	for i := 0; i < 3; i++ {
+		if code, _ := limitedRequest(t, router, "10.0.5.1", ""); code != http.StatusOK {
+			t.Fatalf("request %d = %d, want 200", i, code)
+		}
+	}
+	code, limit := limitedRequest(t, router, "10.0.5.1", "")
+	if code != http.StatusTooManyRequests || limit != "route" {
+		t.Fatalf("request beyond burst = %d (%q), want 429 by route", code, limit)
+	}
+
+	// 其他 IP 有独立的突发额度
+	if code, _ := limitedRequest(t, router, "10.0.5.2", ""); code != http.StatusOK {
+		t.Fatalf("request from another ip = %d, want 200", code)
+	}
+}
+
+func TestBurstWindow(t *testing.T) {
+	r, err := limiter.ParseLimit("100-H")
+	if err != nil {
+		t.Fatalf("ParseLimit: %v", err)
+	}
+
+	w := burstWindow(LimitRule{Burst: 5}, r)
+	if w == nil || w.Limit != 5 || w.Period != 3*time.Minute {
+		t.Fatalf("burst window = %+v, want 5 per 3m", w)
+	}
+	// 未指定或不小于时间窗口额度时不需要突发窗口
+	for _, burst := range []int{0, 100, 200} {
+		if w := burstWindow(LimitRule{Burst: burst}, r); w != nil {
+			t.Fatalf("burst %d window = %+v, want nil", burst, w)
+		}
+	}
+}
+
+// redisDown 模拟 Redis 不可用，所有规则降级为清空后的进程内令牌桶
+func redisDown(t *testing.T) {
+	t.Helper()
+	prevPeek, prevTake := peekLimit, takeLimit
+	t.Cleanup(func() { peekLimit, takeLimit = prevPeek, prevTake })
+	limiters.Range(func(key, _ interface{}) bool {
+		limiters.Delete(key)
+		return true
+	})
+	down := func(ctx context.Context, algorithm limiter.Algorithm, key, formatted string) (limiterlib.Context, error) {
+		return limiterlib.Context{}, errors.New("redis down")
+	}
+	peekLimit, takeLimit = down, down
+}
+
+// assertLimited 断言请求以 429 拒绝，Retry-After 在 [minRetry, maxRetry] 秒之间
+func assertLimited(t *testing.T, w *httptest.ResponseRecorder, minRetry, maxRetry int64) {
+	t.Helper()
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("status = %d, want 429", w.Code)
+	}
+	var body struct {
+		Status string `json:"status"`
+		Code   string `json:"code"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil || body.Status != "error" || body.Code != "rate_limited" {
+		t.Fatalf("body = %s, want rate_limited error", w.Body.String())
+	}
+	retry, err := strconv.ParseInt(w.Header().Get("Retry-After"), 10, 64)
+	if err != nil || retry < minRetry || retry > maxRetry {
+		t.Fatalf("Retry-After = %q, want %d-%d seconds", w.Header().Get("Retry-After"), minRetry, maxRetry)
+	}
+}
+
+func TestLimitedRequestReturns429(t *testing.T) {
+	testutil.SetupRedis(t)
+	router := newLimitedRouter(LimitCombined(LimitRule{Name: "ip", Limit: "1-M", KeyFunc: limiter.GetKeyIP}))
+
+	if w := serveLimited(router, "10.0.6.1", ""); w.Code != http.StatusOK {
+		t.Fatalf("first request = %d, want 200", w.Code)
+	}
+	// Redis 固定窗口：等待到窗口重置
+	assertLimited(t, serveLimited(router, "10.0.6.1", ""), 1, 60)
+}
+
+func TestLimitedRequestReturns429WhenRedisDown(t *testing.T) {
+	redisDown(t)
+	router := newLimitedRouter(LimitCombined(LimitRule{Name: "ip", Limit: "1-M", KeyFunc: limiter.GetKeyIP, Burst: 1}))
+
+	if w := serveLimited(router, "10.0.6.2", ""); w.Code != http.StatusOK {
+		t.Fatalf("first request = %d, want 200", w.Code)
+	}
+	// 进程内令牌桶每分钟恢复一个令牌
+	assertLimited(t, serveLimited(router, "10.0.6.2", ""), 59, 60)
+}
+
+// headerInt 读取整数响应头
+func headerInt(t *testing.T, w *httptest.ResponseRecorder, name string) int64 {
+	t.Helper()
+	v, err := strconv.ParseInt(w.Header().Get(name), 10, 64)
+	if err != nil {
+		t.Fatalf("header %s = %q: %v", name, w.Header().Get(name), err)
+	}
+	return v
+}
+
+func TestRateLimitHeadersFromRedisWindow(t *testing.T) {
+	testutil.SetupRedis(t)
+	router := newLimitedRouter(LimitCombined(LimitRule{Name: "ip", Limit: "5-M", KeyFunc: limiter.GetKeyIP}))
+
+	serveLimited(router, "10.0.7.1", "")
+	w := serveLimited(router, "10.0.7.1", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", w.Code)
+	}
+
+	if limit, remaining := headerInt(t, w, "RateLimit-Limit"), headerInt(t, w, "RateLimit-Remaining"); limit != 5 || remaining != 3 {
+		t.Fatalf("RateLimit-Limit=%d Remaining=%d, want 5 and 3", limit, remaining)
+	}
+	if policy := w.Header().Get("RateLimit-Policy"); policy != "5;w=60" {
+		t.Fatalf("RateLimit-Policy = %q, want 5;w=60", policy)
+	}
+	reset := headerInt(t, w, "RateLimit-Reset")
+	if reset < 0 || reset > 60 {
+		t.Fatalf("RateLimit-Reset = %d, want within the 60s window", reset)
+	}
+
+	if headerInt(t, w, "X-RateLimit-Limit") != 5 || headerInt(t, w, "X-RateLimit-Remaining") != 3 {
+		t.Fatalf("X-RateLimit headers = %v", w.Header())
+	}
+	if at := headerInt(t, w, "X-RateLimit-Reset"); at < time.Now().Unix()+reset-1 || at > time.Now().Unix()+reset+1 {
+		t.Fatalf("X-RateLimit-Reset = %d, want unix time %ds from now", at, reset)
+	}
+}
+
+func TestRateLimitHeadersFromLocalBucket(t *testing.T) {
+	redisDown(t)
+	router := newLimitedRouter(LimitCombined(LimitRule{Name: "ip", Limit: "60-M", KeyFunc: limiter.GetKeyIP, Burst: 10}))
+
+	w := serveLimited(router, "10.0.7.2", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", w.Code)
+	}
+	// 令牌桶容量 10，消耗一个后剩余 9，每秒恢复一个令牌
+	if limit, remaining := headerInt(t, w, "RateLimit-Limit"), headerInt(t, w, "RateLimit-Remaining"); limit != 60 || remaining != 9 {
+		t.Fatalf("RateLimit-Limit=%d Remaining=%d, want 60 and 9", limit, remaining)
+	}
+	if reset := headerInt(t, w, "RateLimit-Reset"); reset != 1 {
+		t.Fatalf("RateLimit-Reset = %d, want 1", reset)
+	}
+	if policy := w.Header().Get("RateLimit-Policy"); policy != "60;w=60" {
+		t.Fatalf("RateLimit-Policy = %q, want 60;w=60", policy)
+	}
+}
+
+func TestRateLimitHeadersReportTightestRule(t *testing.T) {
+	testutil.SetupRedis(t)
+	router := newLimitedRouter(LimitCombined(
+		LimitRule{Name: "ip", Limit: "100-H", KeyFunc: limiter.GetKeyIP},
+		LimitRule{Name: "user", Limit: "3-M", KeyFunc: limiter.GetKeyUserOrIP},
+	))
+
+	w := serveLimited(router, "10.0.7.3", "u1")
+	if headerInt(t, w, "RateLimit-Limit") != 3 || headerInt(t, w, "RateLimit-Remaining") != 2 {
+		t.Fatalf("headers = %v, want the user rule with 2 remaining", w.Header())
+	}
+}
+
+func TestLimitPerUserIndependentOfIP(t *testing.T) {
+	testutil.SetupRedis(t)
+	router := newLimitedRouter(LimitPerUser("2-M"))
+
+	// 同一用户切换 IP 仍计入同一份额度
+	for _, ip := range []string{"10.0.8.1", "10.0.8.2"} {
+		if code, _ := limitedRequest(t, router, ip, "u1"); code != http.StatusOK {
+			t.Fatalf("request from %s = %d, want 200", ip, code)
+		}
+	}
+	if code, limit := limitedRequest(t, router, "10.0.8.3", "u1"); code != http.StatusTooManyRequests || limit != "user" {
+		t.Fatalf("third request = %d (%q), want 429 by user", code, limit)
+	}
+
+	// 共享 IP 的其他用户与游客各自计数
+	if code, _ := limitedRequest(t, router, "10.0.8.1", "u2"); code != http.StatusOK {
+		t.Fatalf("another user on the same ip = %d, want 200", code)
+	}
+	for i := 0; i < 2; i++ {
+		if code, _ := limitedRequest(t, router, "10.0.8.1", ""); code != http.StatusOK {
+			t.Fatalf("guest request %d = %d, want 200", i, code)
+		}
+	}
+	if code, _ := limitedRequest(t, router, "10.0.8.1", ""); code != http.StatusTooManyRequests {
+		t.Fatalf("third guest request = %d, want 429 by ip", code)
+	}
+}
+
+func TestGetKeyUserOrIP(t *testing.T) {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+	c.Request.RemoteAddr = "10.0.8.9:12345"
+
+	if key := limiter.GetKeyUserOrIP(c); key != "ip:10.0.8.9" {
+		t.Fatalf("guest key = %q, want ip:10.0.8.9", key)
+	}
+	c.Set("user_id", "u1")
+	if key := limiter.GetKeyUserOrIP(c); key != "u1" {
+		t.Fatalf("user key = %q, want u1", key)
+	}
+}
+
+// setIPLists 替换限流白名单与黑名单，测试结束后恢复
+func setIPLists(t *testing.T, allow, deny string) {
+	t.Helper()
+	ipListsOnce.Do(loadIPLists)
+	prevAllow, prevDeny := allowlist, denylist
+	t.Cleanup(func() { allowlist, denylist = prevAllow, prevDeny })
+
+	var err error
+	if allowlist, err = limiter.ParseIPList(allow); err != nil {
+		t.Fatalf("parse allowlist: %v", err)
+	}
+	if denylist, err = limiter.ParseIPList(deny); err != nil {
+		t.Fatalf("parse denylist: %v", err)
+	}
+}
+
+// remaining 查询 Redis 中 key 的剩余额度
+func remaining(t *testing.T, key, limit string) int64 {
+	t.Helper()
+	state, err := limiter.Peek(context.Background(), key, limit)
+	if err != nil {
+		t.Fatalf("Peek: %v", err)
+	}
+	return state.Remaining
+}
+
+func TestLimitAllowlistBypassesCounting(t *testing.T) {
+	testutil.SetupRedis(t)
+	router := newLimitedRouter(LimitCombined(LimitRule{Name: "ip", Limit: "1-M", KeyFunc: limiter.GetKeyIP}))
+	setIPLists(t, "10.0.9.0/24", "")
+
+	for i := 0; i < 5; i++ {
+		w := serveLimited(router, "10.0.9.7", "")
+		if w.Code != http.StatusOK || w.Header().Get("RateLimit-Limit") != "" {
+			t.Fatalf("allowlisted request %d = %d headers=%v, want 200 without limiting", i, w.Code, w.Header())
+		}
+	}
+	if n := remaining(t, "ip:10.0.9.7", "1-M"); n != 1 {
+		t.Fatalf("remaining = %d, want allowlisted requests not counted", n)
+	}
+
+	// 网段外的 IP 正常限流
+	serveLimited(router, "10.0.10.1", "")
+	if code, _ := limitedRequest(t, router, "10.0.10.1", ""); code != http.StatusTooManyRequests {
+		t.Fatalf("request outside allowlist = %d, want 429", code)
+	}
+}
+
+func TestLimitDenylistRejectsBeforeCounting(t *testing.T) {
+	testutil.SetupRedis(t)
+	router := newLimitedRouter(LimitCombined(LimitRule{Name: "ip", Limit: "5-M", KeyFunc: limiter.GetKeyIP}))
+	setIPLists(t, "10.0.11.0/24", "10.0.11.66, 2001:db8::/32")
+
+	// 黑名单优先于白名单
+	if w := serveLimited(router, "10.0.11.66", ""); w.Code != http.StatusForbidden {
+		t.Fatalf("denylisted request = %d, want 403", w.Code)
+	}
+	if n := remaining(t, "ip:10.0.11.66", "5-M"); n != 5 {
+		t.Fatalf("remaining = %d, want denylisted request not counted", n)
+	}
+	if w := serveLimited(router, "2001:db8::1", ""); w.Code != http.StatusForbidden {
+		t.Fatalf("denylisted ipv6 request = %d, want 403", w.Code)
+	}
+}
+
+func TestParseIPList(t *testing.T) {
+	list, err := limiter.ParseIPList(" 192.168.1.10 , 10.0.0.0/8,,2001:db8::/32, bogus, 10.0.0.0/33")
+	if err == nil || !strings.Contains(err.Error(), "bogus") || !strings.Contains(err.Error(), "10.0.0.0/33") {
+		t.Fatalf("err = %v, want invalid entries reported", err)
+	}
+	if len(list) != 3 {
+		t.Fatalf("parsed %d entries, want 3 valid ones", len(list))
+	}
+
+	for ip, want := range map[string]bool{
+		"192.168.1.10": true,
+		"192.168.1.11": false,
+		"10.255.0.1":   true,
+		"2001:db8::42": true,
+		"2001:db9::1":  false,
+		"not-an-ip":    false,
+	} {
+		if got := list.Contains(ip); got != want {
+			t.Fatalf("Contains(%s) = %v, want %v", ip, got, want)
+		}
+	}
+}
+
+// countAllowed 连续发起 n 次请求，返回放行的次数
+func countAllowed(router *gin.Engine, ip string, n int) int {
+	allowed := 0
+	for i := 0; i < n; i++ {
+		if w := serveLimited(router, ip, ""); w.Code == http.StatusOK {
+			allowed++
+		}
+	}
+	return allowed
+}
+
+// TestSlidingWindowPreventsBoundaryBurst 窗口交界处固定窗口短时间内可通过近两倍额度，滑动窗口始终不超过额度
+func TestSlidingWindowPreventsBoundaryBurst(t *testing.T) {
+	server := testutil.SetupRedis(t)
+	sliding := newLimitedRouter(LimitSliding(LimitRule{Name: "ip", Limit: "5-S", KeyFunc: limiter.GetKeyIP}))
+	fixed := newLimitedRouter(LimitCombined(LimitRule{Name: "ip", Limit: "5-S", KeyFunc: limiter.GetKeyIP}))
+
+	// 滑动窗口：窗口开始时 1 次，临近窗口结束时用完剩余 4 次
+	start := time.Now()
+	if n := countAllowed(sliding, "10.0.9.1", 1); n != 1 {
+		t.Fatalf("first sliding request allowed = %d, want 1", n)
+	}
+	time.Sleep(800 * time.Millisecond)
+	burstStart := time.Now()
+	if n := countAllowed(sliding, "10.0.9.1", 6); n != 4 {
+		t.Fatalf("sliding requests near the window end allowed = %d, want 4", n)
+	}
+	// 第一个请求移出窗口后只恢复 1 次额度，被拒绝的请求不占用额度
+	time.Sleep(time.Until(start.Add(1100 * time.Millisecond)))
+	if n := countAllowed(sliding, "10.0.9.1", 5); n != 1 {
+		t.Fatalf("sliding requests after the first slid out allowed = %d, want 1", n)
+	}
+	if elapsed := time.Since(burstStart); elapsed >= time.Second {
+		t.Fatalf("sliding burst spanned %s, want it within one window", elapsed)
+	}
+
+	// 固定窗口：用完额度后进入下一个窗口，短时间内又放行全部额度
+	if n := countAllowed(fixed, "10.0.9.2", 6); n != 5 {
+		t.Fatalf("fixed requests in the first window allowed = %d, want 5", n)
+	}
+	server.FastForward(time.Second)
+	if n := countAllowed(fixed, "10.0.9.2", 6); n != 5 {
+		t.Fatalf("fixed requests after the window reset allowed = %d, want 5", n)
+	}
+}
+
+func TestLimitSlidingSetsAlgorithm(t *testing.T) {
+	testutil.SetupRedis(t)
+	var algorithms []limiter.Algorithm
+	prevPeek := peekLimit
+	t.Cleanup(func() { peekLimit = prevPeek })
+	peekLimit = func(ctx context.Context, algorithm limiter.Algorithm, key, formatted string) (limiterlib.Context, error) {
+		algorithms = append(algorithms, algorithm)
+		return prevPeek(ctx, algorithm, key, formatted)
+	}
+
+	router := newLimitedRouter(LimitSliding(
+		LimitRule{Name: "ip", Limit: "10-M", KeyFunc: limiter.GetKeyIP},
+		LimitRule{Name: "user", Limit: "10-M", KeyFunc: limiter.GetKeyUserOrIP},
+	))
+	if code, _ := limitedRequest(t, router, "10.0.9.3", "u1"); code != http.StatusOK {
+		t.Fatalf("request = %d, want 200", code)
+	}
+	if len(algorithms) != 2 || algorithms[0] != limiter.SlidingWindow || algorithms[1] != limiter.SlidingWindow {
+		t.Fatalf("algorithms = %v, want sliding for every rule", algorithms)
+	}
+
+	// 默认仍为固定窗口
+	algorithms = nil
+	router = newLimitedRouter(LimitIP("10-M"))
+	limitedRequest(t, router, "10.0.9.4", "")
+	if len(algorithms) != 1 || algorithms[0] != limiter.FixedWindow {
+		t.Fatalf("default algorithm = %v, want the fixed window", algorithms)
+	}
+}
@@ -49,6 +49,7 @@ func Logger() gin.HandlerFunc {
 		responStatus := c.Writer.Status()
 
 		logFields := []zap.Field{
+			zap.String("request_id", c.GetString("request_id")),
 			zap.Int("status", responStatus),
 			zap.String("request", c.Request.Method+" "+c.Request.URL.String()),
 			zap.String("query", c.Request.URL.RawQuery),
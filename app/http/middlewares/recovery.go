@@ -4,8 +4,10 @@ import (
 	"tarot/pkg/logger"
 	"tarot/pkg/response"
 	"net"
+	"net/http"
 	"net/http/httputil"
 	"os"
+	"runtime/debug"
 	"strings"
 	"time"
 
@@ -20,6 +22,11 @@ func Recovery() gin.HandlerFunc {
 		defer func() {
 			if err := recover(); err != nil {
 
+				// http.ErrAbortHandler 用于主动中断响应，交由 net/http 处理，不视为异常
+				if err == http.ErrAbortHandler {
+					panic(err)
+				}
+
 				// 获取用户的请求信息
 				httpRequest, _ := httputil.DumpRequest(c.Request, true)
 
@@ -36,6 +43,7 @@ func Recovery() gin.HandlerFunc {
 				// 链接中断的情况
 				if brokenPipe {
 					logger.Error(c.Request.URL.Path,
+						zap.String("request_id", c.GetString("request_id")),
 						zap.Time("time", time.Now()),
 						zap.Any("error", err),
 						zap.String("request", string(httpRequest)),
@@ -48,12 +56,19 @@ func Recovery() gin.HandlerFunc {
 
 				// 如果不是链接中断，就开始记录堆栈信息
 				logger.Error("recovery from panic",
-					zap.Time("time", time.Now()),               // 记录时间
-					zap.Any("error", err),                      // 记录错误信息
-					zap.String("request", string(httpRequest)), // 请求信息
-					zap.Stack("stacktrace"),                    // 调用堆栈信息
+					zap.String("request_id", c.GetString("request_id")), // 请求 ID
+					zap.Time("time", time.Now()),                       // 记录时间
+					zap.Any("error", err),                              // 记录错误信息
+					zap.String("request", string(httpRequest)),         // 请求信息
+					zap.ByteString("stacktrace", debug.Stack()),        // panic 发生处的调用堆栈
 				)
 
+				// 响应已开始写出时无法再修改状态码
+				if c.Writer.Written() {
+					c.Abort()
+					return
+				}
+
 				// 返回 500 状态码
 				response.Abort500(c)
 			}
@@ -0,0 +1,75 @@
+package middlewares
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap/zapcore"
+
+	"tarot/pkg/testutil"
+)
+
+func panickingHandler(c *gin.Context) {
+	panic("tarot deck missing")
+}
+
+func TestRecoveryLogsStackAndReturns500(t *testing.T) {
+	logs := testutil.ObserveLogs(t)
+	router := gin.New()
+	router.Use(RequestID(), Recovery())
+	router.GET("/panic", panickingHandler)
+
+	req := httptest.NewRequest(http.MethodGet, "/panic", nil)
+	req.Header.Set(RequestIDHeader, "req-panic-1")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("status = %d, want 500", w.Code)
+	}
+	var body struct {
+		Status string `json:"status"`
+		Code   string `json:"code"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil || body.Status != "error" || body.Code != "internal_error" {
+		t.Fatalf("body = %s, want the standard 500 envelope", w.Body.String())
+	}
+
+	entries := logs.FilterMessage("recovery from panic").All()
+	if len(entries) != 1 || entries[0].Level != zapcore.ErrorLevel {
+		t.Fatalf("panic log entries = %+v, want one error", entries)
+	}
+	fields := entries[0].ContextMap()
+	if fields["request_id"] != "req-panic-1" || fields["error"] != "tarot deck missing" {
+		t.Fatalf("fields = %v, want request id and panic value", fields)
+	}
+	if stack, _ := fields["stacktrace"].(string); !strings.Contains(stack, "panickingHandler") {
+		t.Fatalf("stacktrace = %q, want the panicking frame", stack)
+	}
+}
+
+func TestRecoveryRepanicsAbortHandler(t *testing.T) {
+	logs := testutil.ObserveLogs(t)
+	var recovered interface{}
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		defer func() { recovered = recover() }()
+		c.Next()
+	}, Recovery())
+	router.GET("/abort", func(c *gin.Context) {
+		panic(http.ErrAbortHandler)
+	})
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/abort", nil))
+
+	if recovered != http.ErrAbortHandler {
+		t.Fatalf("recovered = %v, want http.ErrAbortHandler passed through", recovered)
+	}
+	if logs.Len() != 0 {
+		t.Fatalf("logged %d entries for an aborted handler, want none", logs.Len())
+	}
+}
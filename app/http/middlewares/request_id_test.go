@@ -0,0 +1,75 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"tarot/pkg/logger"
+	"tarot/pkg/testutil"
+)
+
+// newRequestIDRouter 返回记录处理函数中看到的请求 ID 的路由
+func newRequestIDRouter(seen *[2]string) *gin.Engine {
+	router := gin.New()
+	router.Use(RequestID())
+	router.GET("/", func(c *gin.Context) {
+		seen[0] = c.GetString("request_id")
+		seen[1] = logger.RequestID(c.Request.Context())
+		logger.InfoStringContext(c.Request.Context(), "Test", "handler", "ok")
+		c.Status(http.StatusOK)
+	})
+	return router
+}
+
+func TestRequestIDEchoesProvidedID(t *testing.T) {
+	logs := testutil.ObserveLogs(t)
+	var seen [2]string
+	router := newRequestIDRouter(&seen)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(RequestIDHeader, "req-123.abc")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if got := w.Header().Get(RequestIDHeader); got != "req-123.abc" {
+		t.Fatalf("response header = %q, want the provided id", got)
+	}
+	if seen[0] != "req-123.abc" || seen[1] != "req-123.abc" {
+		t.Fatalf("context ids = %v, want the provided id", seen)
+	}
+	entries := logs.FilterField(zap.String("request_id", "req-123.abc")).All()
+	if len(entries) != 1 {
+		t.Fatalf("logs with request_id = %d, want 1", len(entries))
+	}
+}
+
+func TestRequestIDGeneratesMissingOrInvalidID(t *testing.T) {
+	var seen [2]string
+	router := newRequestIDRouter(&seen)
+	generated := regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+	ids := map[string]bool{}
+	for _, incoming := range []string{"", "bad id\nwith newline", strings.Repeat("a", maxRequestIDLength+1)} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if incoming != "" {
+			req.Header.Set(RequestIDHeader, incoming)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		got := w.Header().Get(RequestIDHeader)
+		if !generated.MatchString(got) || seen[0] != got || seen[1] != got {
+			t.Fatalf("incoming %q: response id %q, context ids %v", incoming, got, seen)
+		}
+		ids[got] = true
+	}
+	if len(ids) != 3 {
+		t.Fatalf("generated ids = %v, want unique ids", ids)
+	}
+}
@@ -1,6 +1,14 @@
 package middlewares
 
-import "github.com/gin-gonic/gin"
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"tarot/pkg/config"
+	"tarot/pkg/logger"
+)
 
 // SecurityHeaders 添加安全相关的 HTTP 头
 func SecurityHeaders() gin.HandlerFunc {
@@ -16,18 +24,83 @@ func SecurityHeaders() gin.HandlerFunc {
 }
 
 // Cors 处理跨域请求
+// 允许的来源、方法与请求头读取自 cors 配置：
+// - 来源在白名单内时回显请求的 Origin，并设置 Vary: Origin
+// - 白名单为 * 且未开启凭证时返回 *；开启凭证时不允许使用 *
+// - 来源不在白名单内时不返回任何 CORS 头，预检请求直接拒绝
 func Cors() gin.HandlerFunc {
+	origins := splitConfigList(config.GetString("cors.allowed_origins"))
+	methods := strings.Join(splitConfigList(config.GetString("cors.allowed_methods")), ", ")
+	headers := strings.Join(splitConfigList(config.GetString("cors.allowed_headers")), ", ")
+	credentials := config.GetBool("cors.allow_credentials")
+	maxAge := config.GetString("cors.max_age")
+
+	allowAll := false
+	allowed := make(map[string]bool, len(origins))
+	for _, origin := range origins {
+		if origin == "*" {
+			allowAll = true
+			continue
+		}
+		allowed[strings.ToLower(strings.TrimRight(origin, "/"))] = true
+	}
+	if allowAll && credentials {
+		logger.WarnString("CORS", "Config", "allowed_origins 为 * 时不能携带凭证，已关闭 allow_credentials")
+		credentials = false
+	}
+
 	return func(c *gin.Context) {
-		c.Header("Access-Control-Allow-Origin", "*")
-		c.Header("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization")
-		
+		origin := c.GetHeader("Origin")
+		preflight := c.Request.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != ""
+
+		// 非跨域请求不需要 CORS 头
+		if origin == "" {
+			c.Next()
+			return
+		}
+
+		if !allowAll {
+			c.Header("Vary", "Origin")
+		}
+		if !allowAll && !allowed[strings.ToLower(origin)] {
+			if preflight {
+				c.AbortWithStatus(http.StatusForbidden)
+				return
+			}
+			c.Next()
+			return
+		}
+
+		if allowAll {
+			c.Header("Access-Control-Allow-Origin", "*")
+		} else {
+			c.Header("Access-Control-Allow-Origin", origin)
+		}
+		if credentials {
+			c.Header("Access-Control-Allow-Credentials", "true")
+		}
+		c.Header("Access-Control-Expose-Headers", "X-Request-ID, Retry-After, RateLimit-Limit, RateLimit-Remaining, RateLimit-Reset, RateLimit-Policy")
+
 		// 处理预检请求
-		if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(204)
+		if preflight {
+			c.Header("Access-Control-Allow-Methods", methods)
+			c.Header("Access-Control-Allow-Headers", headers)
+			c.Header("Access-Control-Max-Age", maxAge)
+			c.AbortWithStatus(http.StatusNoContent)
 			return
 		}
-		
+
 		c.Next()
 	}
-} 
\ No newline at end of file
+}
+
+// splitConfigList 拆分逗号分隔的配置项，忽略空白项
+func splitConfigList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
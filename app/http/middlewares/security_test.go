@@ -0,0 +1,103 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"tarot/pkg/testutil"
+)
+
+// newCorsRouter 按指定的来源白名单与凭证配置创建挂载 Cors 的路由
+func newCorsRouter(t *testing.T, origins string, credentials bool) *gin.Engine {
+	t.Helper()
+	testutil.SetConfig(t, "cors.allowed_origins", origins)
+	testutil.SetConfig(t, "cors.allowed_methods", "GET,POST,PUT,DELETE,OPTIONS")
+	testutil.SetConfig(t, "cors.allowed_headers", "Content-Type,Authorization")
+	testutil.SetConfig(t, "cors.allow_credentials", credentials)
+	testutil.SetConfig(t, "cors.max_age", 600)
+
+	router := gin.New()
+	router.Use(Cors())
+	router.Any("/cors", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	return router
+}
+
+func corsRequest(router *gin.Engine, method, origin string, preflight bool) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, "/cors", nil)
+	if origin != "" {
+		req.Header.Set("Origin", origin)
+	}
+	if preflight {
+		req.Header.Set("Access-Control-Request-Method", http.MethodDelete)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestCorsAllowedOrigin(t *testing.T) {
+	router := newCorsRouter(t, "https://app.example.com, https://admin.example.com/", true)
+
+	for _, origin := range []string{"https://app.example.com", "https://admin.example.com"} {
+		w := corsRequest(router, http.MethodGet, origin, false)
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: status = %d, want 200", origin, w.Code)
+		}
+		if got := w.Header().Get("Access-Control-Allow-Origin"); got != origin {
+			t.Fatalf("%s: Allow-Origin = %q, want the request origin echoed", origin, got)
+		}
+		if w.Header().Get("Access-Control-Allow-Credentials") != "true" || w.Header().Get("Vary") != "Origin" {
+			t.Fatalf("%s: headers = %v, want credentials and Vary: Origin", origin, w.Header())
+		}
+	}
+}
+
+func TestCorsDisallowedOrigin(t *testing.T) {
+	router := newCorsRouter(t, "https://app.example.com", true)
+
+	w := corsRequest(router, http.MethodGet, "https://evil.example.com", false)
+	if w.Code != http.StatusOK || w.Header().Get("Access-Control-Allow-Origin") != "" || w.Header().Get("Access-Control-Allow-Credentials") != "" {
+		t.Fatalf("status=%d headers=%v, want no CORS headers for a disallowed origin", w.Code, w.Header())
+	}
+	if w.Header().Get("Vary") != "Origin" {
+		t.Fatalf("Vary = %q, want Origin so caches key on it", w.Header().Get("Vary"))
+	}
+
+	if w := corsRequest(router, http.MethodOptions, "https://evil.example.com", true); w.Code != http.StatusForbidden {
+		t.Fatalf("disallowed preflight = %d, want 403", w.Code)
+	}
+}
+
+func TestCorsPreflight(t *testing.T) {
+	router := newCorsRouter(t, "https://app.example.com", false)
+
+	w := corsRequest(router, http.MethodOptions, "https://app.example.com", true)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("status = %d, want 204", w.Code)
+	}
+	methods := w.Header().Get("Access-Control-Allow-Methods")
+	if !strings.Contains(methods, "PUT") || !strings.Contains(methods, "DELETE") {
+		t.Fatalf("Allow-Methods = %q, want PUT and DELETE", methods)
+	}
+	if w.Header().Get("Access-Control-Allow-Headers") != "Content-Type, Authorization" || w.Header().Get("Access-Control-Max-Age") != "600" {
+		t.Fatalf("preflight headers = %v", w.Header())
+	}
+	if w.Header().Get("Access-Control-Allow-Credentials") != "" {
+		t.Fatalf("credentials header set while disabled")
+	}
+}
+
+func TestCorsWildcardNeverAllowsCredentials(t *testing.T) {
+	router := newCorsRouter(t, "*", true)
+
+	w := corsRequest(router, http.MethodGet, "https://any.example.com", false)
+	if w.Header().Get("Access-Control-Allow-Origin") != "*" || w.Header().Get("Access-Control-Allow-Credentials") != "" {
+		t.Fatalf("headers = %v, want * without credentials", w.Header())
+	}
+}
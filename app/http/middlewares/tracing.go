@@ -0,0 +1,42 @@
+package middlewares
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"tarot/pkg/tracing"
+)
+
+// Tracing 为每个请求创建服务端 span
+// 沿用上游 traceparent 所在的链路，span 写入 Request.Context()，
+// 后续的数据库、队列与 Dify 调用以此为父 span
+func Tracing() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !tracing.Enabled() {
+			c.Next()
+			return
+		}
+
+		ctx := tracing.ContextFromTraceparent(c.Request.Context(), c.GetHeader(tracing.TraceparentHeader))
+		ctx, span := tracing.Start(ctx, "HTTP "+c.Request.Method, tracing.KindServer)
+		defer span.End()
+
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+
+		route := c.FullPath()
+		if route != "" {
+			span.SetName("HTTP " + c.Request.Method + " " + route)
+		}
+		status := c.Writer.Status()
+		span.SetAttribute("http.method", c.Request.Method)
+		span.SetAttribute("http.route", route)
+		span.SetAttribute("http.status_code", status)
+		span.SetAttribute("request_id", c.GetString("request_id"))
+		if status >= http.StatusInternalServerError {
+			span.RecordError(fmt.Errorf("HTTP %d", status))
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package middlewares
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"tarot/pkg/tracing"
+)
+
+// enableTracing 启用追踪并将 span 导出到内存，测试结束时关闭
+// 返回的 flush 关闭追踪并导出剩余 span
+func enableTracing(t *testing.T) (*tracing.MemoryExporter, func()) {
+	t.Helper()
+	exporter := &tracing.MemoryExporter{}
+	tracing.Setup(tracing.Config{Enabled: true, SampleRatio: 1}, exporter)
+	flush := func() { tracing.Shutdown(context.Background()) }
+	t.Cleanup(flush)
+	return exporter, flush
+}
+
+func newTracingRouter() *gin.Engine {
+	router := gin.New()
+	router.Use(Tracing())
+	router.GET("/readings/:id", func(c *gin.Context) {
+		// 处理函数中创建的 span 以请求 span 为父 span
+		_, span := tracing.Start(c.Request.Context(), "db.query", tracing.KindClient)
+		span.End()
+		c.Status(http.StatusOK)
+	})
+	router.GET("/fail", func(c *gin.Context) {
+		c.Status(http.StatusInternalServerError)
+	})
+	return router
+}
+
+func serveTraced(router *gin.Engine, path, traceparent string) {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	if traceparent != "" {
+		req.Header.Set(tracing.TraceparentHeader, traceparent)
+	}
+	router.ServeHTTP(httptest.NewRecorder(), req)
+}
+
+// serverSpans 过滤出中间件创建的请求 span
+func serverSpans(spans []*tracing.Span) []*tracing.Span {
+	var out []*tracing.Span
+	for _, s := range spans {
+		if s.Attribute("http.method") != nil {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+func TestTracingSpanPerRequest(t *testing.T) {
+	exporter, flush := enableTracing(t)
+	router := newTracingRouter()
+
+	serveTraced(router, "/readings/1", "")
+	serveTraced(router, "/readings/2", "")
+	serveTraced(router, "/fail", "")
+	flush()
+
+	spans := exporter.Spans()
+	requests := serverSpans(spans)
+	if len(requests) != 3 {
+		t.Fatalf("exported %d request spans (%d total), want 3", len(requests), len(spans))
+	}
+	first := requests[0]
+	if first.Name() != "HTTP GET /readings/:id" || first.Attribute("http.route") != "/readings/:id" || first.Attribute("http.status_code") != http.StatusOK {
+		t.Fatalf("request span = %s route=%v status=%v", first.Name(), first.Attribute("http.route"), first.Attribute("http.status_code"))
+	}
+	if first.Parent().IsValid() {
+		t.Fatal("request span without traceparent has a parent")
+	}
+	if requests[0].SpanContext().TraceID == requests[1].SpanContext().TraceID {
+		t.Fatal("separate requests share a trace id")
+	}
+
+	var child *tracing.Span
+	for _, s := range spans {
+		if s.Name() == "db.query" {
+			child = s
+			break
+		}
+	}
+	if child == nil || child.Parent() != first.SpanContext().SpanID || child.SpanContext().TraceID != first.SpanContext().TraceID {
+		t.Fatal("handler span is not a child of the first request span")
+	}
+}
+
+func TestTracingContinuesTraceparent(t *testing.T) {
+	exporter, flush := enableTracing(t)
+
+	const traceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	serveTraced(newTracingRouter(), "/fail", traceparent)
+	flush()
+
+	requests := serverSpans(exporter.Spans())
+	if len(requests) != 1 {
+		t.Fatalf("exported %d request spans, want 1", len(requests))
+	}
+	span := requests[0]
+	if span.SpanContext().TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" || span.Parent().String() != "00f067aa0ba902b7" {
+		t.Fatalf("span trace=%s parent=%s, want the upstream trace", span.SpanContext().TraceID, span.Parent())
+	}
+}
+
+func TestTracingDisabledExportsNothing(t *testing.T) {
+	tracing.Shutdown(context.Background())
+	exporter := &tracing.MemoryExporter{}
+	tracing.Setup(tracing.Config{Enabled: false, SampleRatio: 1}, exporter)
+
+	serveTraced(newTracingRouter(), "/readings/1", "")
+	if tracing.Enabled() || len(exporter.Spans()) != 0 {
+		t.Fatal("spans exported while tracing is disabled")
+	}
+}
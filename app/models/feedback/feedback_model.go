@@ -0,0 +1,29 @@
+// Package feedback 存放解读评价 Model 相关逻辑
+package feedback
+
+import (
+	"tarot/app/models"
+)
+
+// 评分范围
+const (
+	MinRating = 1
+	MaxRating = 5
+)
+
+// Feedback 用户对解读的评价，每个用户对每条解读只保留一条
+type Feedback struct {
+	models.BaseModel
+
+	ReadingID uint64 `gorm:"uniqueIndex:idx_feedback_reading_user;not null" json:"reading_id"`
+	UserID    string `gorm:"type:varchar(36);uniqueIndex:idx_feedback_reading_user;not null" json:"user_id"`
+	Rating    int    `gorm:"not null;index" json:"rating"` // 1~5 分
+	Comment   string `gorm:"type:text" json:"comment,omitempty"`
+
+	models.CommonTimestampsField
+}
+
+// TableName 表名
+func (Feedback) TableName() string {
+	return "reading_feedbacks"
+}
@@ -7,6 +7,7 @@ import (
 	"tarot/app/models/reading"
 	"tarot/app/models/user"
 	"tarot/pkg/database"
+	"tarot/pkg/helpers"
 	"time"
 
 	"gorm.io/gorm"
@@ -20,7 +21,7 @@ var (
 // ReadingData 定义从前端接收的测算数据结构
 type ReadingData struct {
 	Type           reading.ReadingType `json:"type" binding:"required,oneof=free premium"`
-	Question       string              `json:"question" binding:"required,min:10,max:500"`
+	Question       string              `json:"question" binding:"required,min=10,max=500"` // 与 reading.MinQuestionLength、MaxQuestionLength 一致
 	Cards          reading.Cards       `json:"cards" binding:"required,min=1,max=3"`
 	Interpretation string              `json:"interpretation" binding:"required"`
 }
@@ -85,6 +86,7 @@ func MigrateToUser(guestID string, userID string, readingData []ReadingData) err
 		readings := make([]reading.Reading, len(readingData))
 		for i, data := range readingData {
 			readings[i] = reading.Reading{
+				TaskID:         helpers.UUID(), // task_id 唯一，迁移的记录没有队列任务，单独生成
 				UserID:         userID,
 				Type:           data.Type,
 				Question:       data.Question,
@@ -0,0 +1,60 @@
+package payment
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+)
+
+// EventSource 订单状态变化的来源
+type EventSource string
+
+const (
+	EventSourceNotify EventSource = "notify" // 支付平台回调
+	EventSourceQuery  EventSource = "query"  // 主动查询支付平台
+	EventSourceSweep  EventSource = "sweep"  // 过期订单清理
+	EventSourceManual EventSource = "manual" // 人工操作，例如退款
+)
+
+// PaymentEvent 订单状态变化记录，与状态更新在同一事务中写入
+type PaymentEvent struct {
+	ID          uint64    `gorm:"primaryKey;autoIncrement" json:"id"`
+	PaymentID   uint64    `gorm:"index" json:"payment_id"`                // 关联支付记录
+	OrderNo     string    `gorm:"type:varchar(64);index" json:"order_no"` // 订单号
+	FromStatus  string    `gorm:"type:varchar(20)" json:"from_status"`    // 变更前状态
+	ToStatus    string    `gorm:"type:varchar(20)" json:"to_status"`      // 变更后状态
+	Source      string    `gorm:"type:varchar(20)" json:"source"`         // 变更来源
+	PayloadHash string    `gorm:"type:varchar(64)" json:"payload_hash"`   // 支付平台原始报文的 SHA-256，无报文时为空
+	CreatedAt   time.Time `gorm:"" json:"created_at"`
+}
+
+// TableName 指定表名
+func (PaymentEvent) TableName() string {
+	return "payment_events"
+}
+
+type eventSourceKey struct{}
+
+type eventSource struct {
+	source      EventSource
+	payloadHash string
+}
+
+// WithEventSource 在 ctx 中记录本次状态变化的来源与原始报文，由仓库写入事件时读取
+func WithEventSource(ctx context.Context, source EventSource, payload []byte) context.Context {
+	es := eventSource{source: source}
+	if len(payload) > 0 {
+		sum := sha256.Sum256(payload)
+		es.payloadHash = hex.EncodeToString(sum[:])
+	}
+	return context.WithValue(ctx, eventSourceKey{}, es)
+}
+
+// EventSourceFrom 读取 ctx 中的变更来源，未设置时视为人工操作
+func EventSourceFrom(ctx context.Context) (EventSource, string) {
+	if es, ok := ctx.Value(eventSourceKey{}).(eventSource); ok {
+		return es.source, es.payloadHash
+	}
+	return EventSourceManual, ""
+}
@@ -2,6 +2,8 @@ package payment
 
 import (
 	"time"
+
+	"gorm.io/gorm"
 )
 
 // Payment 支付记录模型
@@ -12,13 +14,15 @@ type Payment struct {
 	ReadingID     uint64         `gorm:"index" json:"reading_id"`                         
 	Provider      string         `gorm:"type:varchar(20)" json:"provider"`                
 	Amount        int64          `gorm:"" json:"amount"`                                  
+	RefundedAmount int64         `gorm:"default:0" json:"refunded_amount"`               // 累计退款金额
 	Status        string         `gorm:"type:varchar(20);index" json:"status"`           
 	TransactionID string         `gorm:"type:varchar(64)" json:"transaction_id"`          
-	PayAt         *time.Time     `gorm:"" json:"pay_at"`                                 
+	PayAt         *time.Time     `gorm:"index" json:"pay_at"`                                 
 	ExpireAt      *time.Time     `gorm:"" json:"expire_at"`                             
 	ExtraData     JSON           `gorm:"type:json" json:"extra_data"`                    
 	CreatedAt     time.Time      `gorm:"" json:"created_at"`
 	UpdatedAt     time.Time      `gorm:"" json:"updated_at"`
+	DeletedAt     gorm.DeletedAt `gorm:"index" json:"deleted_at,omitempty"`              // 软删除时间，已删除的订单不参与查询、对账与统计
 }
 
 // TableName 指定表名
@@ -4,6 +4,8 @@ import (
 	"database/sql/driver"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"time"
 )
 
 // Provider 支付提供商类型
@@ -12,6 +14,7 @@ type Provider string
 const (
 	ProviderWechat Provider = "wechat" // 微信支付
 	ProviderAlipay Provider = "alipay" // 支付宝
+	ProviderStripe Provider = "stripe" // Stripe
 )
 
 // Status 支付状态
@@ -25,6 +28,33 @@ const (
 	StatusRefunded Status = "refunded" // 已退款
 )
 
+// RefundStatus 退款状态
+type RefundStatus string
+
+const (
+	RefundStatusProcessing RefundStatus = "processing" // 退款处理中
+	RefundStatusSuccess    RefundStatus = "success"    // 退款成功
+	RefundStatusFailed     RefundStatus = "failed"     // 退款失败
+)
+
+var (
+	// ErrNotRefundable 当前支付状态不允许退款
+	ErrNotRefundable = errors.New("payment is not refundable")
+	// ErrInvalidRefundAmount 退款金额非法（小于等于 0 或超过可退金额）
+	ErrInvalidRefundAmount = errors.New("invalid refund amount")
+	// ErrNotCancelable 订单已支付或已退款，不允许取消
+	ErrNotCancelable = errors.New("payment is not cancelable")
+	// ErrNotDeletable 订单仍可能发生资金变动，不允许删除
+	ErrNotDeletable = errors.New("payment is not deletable")
+	// ErrInvalidPayment 订单内容不合法（如金额小于等于 0），不会发往支付渠道
+	ErrInvalidPayment = errors.New("invalid payment")
+	// ErrRefundInProgress 同一订单的另一笔退款正在提交中
+	ErrRefundInProgress = errors.New("refund in progress")
+)
+
+// ResultKey ExtraData 中保存下单结果的键，重复下单复用订单时返回
+const ResultKey = "result"
+
 // JSON 自定义JSON类型
 type JSON map[string]interface{}
 
@@ -52,20 +82,22 @@ func (j *JSON) Scan(value interface{}) error {
 // Validate 验证支付记录
 func (p *Payment) Validate() error {
 	if p.UserID == "" {
-		return errors.New("user_id is required")
+		return fmt.Errorf("%w: user_id is required", ErrInvalidPayment)
 	}
 	if p.Amount <= 0 {
-		return errors.New("amount must be greater than 0")
+		return fmt.Errorf("%w: amount must be greater than 0, got %d", ErrInvalidPayment, p.Amount)
 	}
 	if !p.ValidateProvider() {
-		return errors.New("invalid payment provider")
+		return fmt.Errorf("%w: invalid payment provider %q", ErrInvalidPayment, p.Provider)
 	}
 	return nil
 }
 
 // ValidateProvider 验证支付提供商
 func (p *Payment) ValidateProvider() bool {
-	return p.Provider == string(ProviderWechat) || p.Provider == string(ProviderAlipay)
+	return p.Provider == string(ProviderWechat) ||
+		p.Provider == string(ProviderAlipay) ||
+		p.Provider == string(ProviderStripe)
 }
 
 // IsSuccess 检查支付是否成功
@@ -87,3 +119,58 @@ func (p *Payment) IsRefunded() bool {
 func (p *Payment) IsCanceled() bool {
 	return p.Status == string(StatusCanceled)
 }
+
+// RefundableAmount 剩余可退款金额
+func (p *Payment) RefundableAmount() int64 {
+	return p.Amount - p.RefundedAmount
+}
+
+// CheckRefund 校验退款金额是否合法
+// 仅已支付的订单可退款，累计退款金额加上处理中的退款金额 processing 不能超过支付金额
+func (p *Payment) CheckRefund(amount, processing int64) error {
+	if !p.IsSuccess() {
+		return ErrNotRefundable
+	}
+	if amount <= 0 || amount > p.RefundableAmount()-processing {
+		return ErrInvalidRefundAmount
+	}
+	return nil
+}
+
+// ApplyRefund 累加退款金额，全额退款后将状态置为已退款
+func (p *Payment) ApplyRefund(amount int64) {
+	p.RefundedAmount += amount
+	if p.RefundedAmount >= p.Amount {
+		p.Status = string(StatusRefunded)
+	}
+}
+
+// IsExpired 检查订单是否已过期
+func (p *Payment) IsExpired() bool {
+	return p.ExpireAt != nil && p.ExpireAt.Before(time.Now())
+}
+
+// CheckCancel 校验订单是否可以取消
+// 已支付、已退款的订单不能取消
+func (p *Payment) CheckCancel() error {
+	if p.IsSuccess() || p.IsRefunded() {
+		return ErrNotCancelable
+	}
+	return nil
+}
+
+// CheckDelete 校验订单是否可以软删除
+// 只有已退款、已取消或支付失败的订单可以删除，待支付与已支付的订单仍可能发生资金变动
+func (p *Payment) CheckDelete() error {
+	if p.IsRefunded() || p.IsCanceled() || p.Status == string(StatusFailed) {
+		return nil
+	}
+	return ErrNotDeletable
+}
+
+// MarkPaid 标记为已支付
+func (p *Payment) MarkPaid(transactionID string, payAt time.Time) {
+	p.Status = string(StatusPaid)
+	p.TransactionID = transactionID
+	p.PayAt = &payAt
+}
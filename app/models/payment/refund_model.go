@@ -0,0 +1,30 @@
+package payment
+
+import (
+	"time"
+)
+
+// Refund 退款记录模型
+type Refund struct {
+	ID               uint64    `gorm:"primaryKey;autoIncrement" json:"id"`
+	RefundNo         string    `gorm:"type:varchar(64);uniqueIndex" json:"refund_no"` // 商户退款单号
+	PaymentID        uint64    `gorm:"index" json:"payment_id"`                       // 关联支付记录
+	OrderNo          string    `gorm:"type:varchar(64);index" json:"order_no"`        // 原支付订单号
+	Provider         string    `gorm:"type:varchar(20)" json:"provider"`              // 支付提供商
+	Amount           int64     `gorm:"" json:"amount"`                                // 退款金额（分）
+	Reason           string    `gorm:"type:varchar(255)" json:"reason"`               // 退款原因
+	Status           string    `gorm:"type:varchar(20);index" json:"status"`          // 退款状态
+	ProviderRefundID string    `gorm:"type:varchar(64)" json:"provider_refund_id"`    // 第三方退款单号
+	CreatedAt        time.Time `gorm:"" json:"created_at"`
+	UpdatedAt        time.Time `gorm:"" json:"updated_at"`
+}
+
+// TableName 指定表名
+func (Refund) TableName() string {
+	return "payment_refunds"
+}
+
+// IsProcessing 检查退款是否仍在处理中
+func (r *Refund) IsProcessing() bool {
+	return r.Status == string(RefundStatusProcessing)
+}
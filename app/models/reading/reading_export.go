@@ -0,0 +1,37 @@
+package reading
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Markdown 将解读记录渲染为 Markdown，用于导出保存或打印
+// positions 为牌阵各位置含义，可为空；调用方需先处理付费解读的解锁状态
+func (r *Reading) Markdown(positions []string) string {
+	var b strings.Builder
+
+	b.WriteString("# 塔罗牌解读\n\n")
+	fmt.Fprintf(&b, "- 时间：%s\n", r.CreatedAt.Format("2006-01-02 15:04"))
+	fmt.Fprintf(&b, "- 编号：%s\n\n", r.TaskID)
+
+	b.WriteString("## 问题\n\n")
+	b.WriteString(strings.TrimSpace(r.Question))
+	b.WriteString("\n\n")
+
+	b.WriteString("## 卡牌\n\n")
+	for i, card := range r.CardDetails {
+		name := fmt.Sprintf("%s（%s）", card.NameCN, card.Name)
+		if i < len(positions) && positions[i] != "" {
+			fmt.Fprintf(&b, "%d. **%s**：%s\n", i+1, positions[i], name)
+		} else {
+			fmt.Fprintf(&b, "%d. %s\n", i+1, name)
+		}
+	}
+	b.WriteString("\n")
+
+	b.WriteString("## 解读\n\n")
+	b.WriteString(strings.TrimSpace(r.Interpretation))
+	b.WriteString("\n")
+
+	return b.String()
+}
@@ -0,0 +1,34 @@
+package reading
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"tarot/pkg/tarot"
+)
+
+func TestMarkdownIncludesQuestionCardsAndInterpretation(t *testing.T) {
+	r := &Reading{
+		TaskID:         "task-1",
+		Question:       "  我最近的事业运势如何？ ",
+		Interpretation: "愚者预示着新的开始。\n",
+		Cards:          Cards{1, 2},
+		CardDetails:    tarot.Lookup(Cards{1, 2}),
+	}
+	r.CreatedAt = time.Date(2026, 1, 2, 15, 4, 0, 0, time.UTC)
+
+	md := r.Markdown([]string{"现状"})
+	for _, want := range []string{
+		"- 时间：2026-01-02 15:04\n",
+		"- 编号：task-1\n",
+		"## 问题\n\n我最近的事业运势如何？\n\n",
+		"1. **现状**：" + r.CardDetails[0].NameCN,
+		"2. " + r.CardDetails[1].NameCN,
+		"## 解读\n\n愚者预示着新的开始。\n",
+	} {
+		if !strings.Contains(md, want) {
+			t.Fatalf("markdown missing %q:\n%s", want, md)
+		}
+	}
+}
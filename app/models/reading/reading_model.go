@@ -2,9 +2,13 @@
 package reading
 
 import (
+	"context"
+	"time"
+
 	"tarot/app/models"
 	"gorm.io/gorm"
 	"tarot/pkg/database"
+	"tarot/pkg/tarot"
 )
 
 // Reading 塔罗牌阅读记录模型
@@ -13,10 +17,17 @@ type Reading struct {
 	TaskID         string      `gorm:"type:varchar(36);uniqueIndex" json:"task_id"`      // 任务ID，唯一索引
 	UserID         string      `gorm:"type:varchar(36);index" json:"user_id"`            // 用户ID，普通索引
 	Type           ReadingType `gorm:"type:varchar(20);index" json:"type"`               // 解读类型（免费/付费）
+	SpreadID       uint64      `gorm:"index" json:"spread_id,omitempty"`                 // 牌阵模板ID，0 表示默认牌阵
 	Question       string      `gorm:"type:text" json:"question"`                        // 问题
 	Cards          Cards       `gorm:"type:json" json:"cards"`                          // 卡牌数组
 	Interpretation string      `gorm:"type:text" json:"interpretation"`                  // 解读结果
 	Status         string      `gorm:"type:varchar(20);index" json:"status"`            // 状态
+	Unlocked       bool        `gorm:"default:false" json:"unlocked"`                   // 付费解读是否已解锁
+	UnlockedAt     *time.Time  `gorm:"" json:"unlocked_at"`                             // 解锁时间
+	ConversationID string      `gorm:"type:varchar(64);index" json:"conversation_id,omitempty"` // Dify 对话ID，追问时延续上下文
+	ParentTaskID   string      `gorm:"type:varchar(36);index" json:"parent_task_id,omitempty"`  // 追问所针对的原解读任务ID
+	RetryOfTaskID  string      `gorm:"type:varchar(36);index" json:"retry_of_task_id,omitempty"` // 重新解读所针对的失败任务ID
+	CardDetails    []tarot.Card `gorm:"-" json:"card_details"`                          // 卡牌目录信息（含图片地址），不落库
 	
 	models.CommonTimestampsField // 包含 created_at 和 updated_at
 }
@@ -40,12 +51,34 @@ func (r *Reading) BeforeSave(tx *gorm.DB) error {
 	return nil
 }
 
+// AfterFind GORM 钩子 - 查询后补充卡牌图片信息
+func (r *Reading) AfterFind(tx *gorm.DB) error {
+	r.CardDetails = tarot.Lookup(r.Cards)
+	return nil
+}
+
+// AfterSave GORM 钩子 - 保存后补充卡牌图片信息
+func (r *Reading) AfterSave(tx *gorm.DB) error {
+	r.CardDetails = tarot.Lookup(r.Cards)
+	return nil
+}
+
 // Create 创建阅读记录
 func (r *Reading) Create() error {
-	return database.DB.Create(&r).Error
+	return r.CreateCtx(context.Background())
+}
+
+// CreateCtx 创建阅读记录，ctx 取消或超时时中止查询
+func (r *Reading) CreateCtx(ctx context.Context) error {
+	return database.DB.WithContext(ctx).Create(r).Error
 }
 
 // Save 保存记录
 func (r *Reading) Save() error {
-	return database.DB.Save(&r).Error
+	return r.SaveCtx(context.Background())
+}
+
+// SaveCtx 保存记录，ctx 取消或超时时中止查询
+func (r *Reading) SaveCtx(ctx context.Context) error {
+	return database.DB.WithContext(ctx).Save(r).Error
 }
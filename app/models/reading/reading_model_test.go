@@ -0,0 +1,79 @@
+package reading
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"tarot/pkg/database"
+	"tarot/pkg/testutil"
+)
+
+func newReading(taskID string) *Reading {
+	return &Reading{TaskID: taskID, UserID: "user-1", Question: "我最近的事业运势如何？", Type: TypeFree, Status: string(StatusPending), Cards: Cards{1, 2, 3}}
+}
+
+func countReadings(t *testing.T) int64 {
+	t.Helper()
+	var n int64
+	if err := database.DB.Model(&Reading{}).Count(&n).Error; err != nil {
+		t.Fatalf("count readings: %v", err)
+	}
+	return n
+}
+
+func TestCreateCtxAbortsOnCancelledContext(t *testing.T) {
+	testutil.SetupDB(t, &Reading{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := newReading("task-cancelled").CreateCtx(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("CreateCtx with a cancelled context = %v, want context.Canceled", err)
+	}
+
+	expired, cancelExpired := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancelExpired()
+	if err := newReading("task-expired").CreateCtx(expired); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("CreateCtx past its deadline = %v, want context.DeadlineExceeded", err)
+	}
+	if n := countReadings(t); n != 0 {
+		t.Fatalf("%d readings created by aborted queries, want 0", n)
+	}
+
+	// 不带 context 的方法不受影响
+	if err := newReading("task-background").Create(); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if n := countReadings(t); n != 1 {
+		t.Fatalf("%d readings after Create, want 1", n)
+	}
+}
+
+func TestSaveCtxAbortsOnCancelledContext(t *testing.T) {
+	testutil.SetupDB(t, &Reading{})
+	r := newReading("task-1")
+	if err := r.CreateCtx(context.Background()); err != nil {
+		t.Fatalf("CreateCtx: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	r.Status = string(StatusFailed)
+	if err := r.SaveCtx(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("SaveCtx with a cancelled context = %v, want context.Canceled", err)
+	}
+	var stored Reading
+	database.DB.First(&stored, r.ID)
+	if stored.Status != string(StatusPending) {
+		t.Fatalf("status after an aborted save = %s, want pending", stored.Status)
+	}
+
+	if err := r.Save(); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	database.DB.First(&stored, r.ID)
+	if stored.Status != string(StatusFailed) {
+		t.Fatalf("status after Save = %s, want failed", stored.Status)
+	}
+}
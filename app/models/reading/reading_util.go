@@ -4,6 +4,23 @@ import (
 	"database/sql/driver"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"tarot/pkg/tarot"
+)
+
+// TeaserLength 未解锁付费解读可预览的字符数
+const TeaserLength = 80
+
+// MaxCards 单次解读允许的最大卡牌数量（与牌阵上限一致）
+const MaxCards = 10
+
+// 问题长度范围（按字符计）
+const (
+	MinQuestionLength = 10
+	MaxQuestionLength = 500
 )
 
 // ReadingType 塔罗牌解读类型
@@ -64,9 +81,38 @@ func (r *Reading) Validate() error {
 	if len(r.Cards) == 0 {
 		return errors.New("cards cannot be empty")
 	}
-	if len(r.Cards) > 3 {
+	// 牌阵的卡牌数量在请求校验时已按牌阵定义检查
+	if r.SpreadID == 0 && len(r.Cards) > 3 {
 		return errors.New("maximum 3 cards allowed")
 	}
+	if len(r.Cards) > MaxCards {
+		return errors.New("too many cards")
+	}
+	return nil
+}
+
+// ValidateQuestion 校验问题长度（按字符计），过长的问题不应传给 Dify
+func ValidateQuestion(question string) error {
+	n := utf8.RuneCountInString(strings.TrimSpace(question))
+	if n < MinQuestionLength {
+		return fmt.Errorf("问题长度不能小于 %d 个字符", MinQuestionLength)
+	}
+	if n > MaxQuestionLength {
+		return fmt.Errorf("问题长度不能超过 %d 个字符", MaxQuestionLength)
+	}
+	return nil
+}
+
+// ValidateCardIDs 校验卡牌不为空且编号都在 1 到 tarot.TotalCards 之间
+func ValidateCardIDs(cards []int) error {
+	if len(cards) == 0 {
+		return fmt.Errorf("至少需要选择一张卡牌")
+	}
+	for _, cardID := range cards {
+		if cardID < 1 || cardID > tarot.TotalCards {
+			return fmt.Errorf("无效的卡牌编号: %d", cardID)
+		}
+	}
 	return nil
 }
 
@@ -80,6 +126,22 @@ func (r *Reading) IsPremium() bool {
 	return r.Type == TypePremium
 }
 
+// IsLocked 检查付费解读是否尚未解锁
+func (r *Reading) IsLocked() bool {
+	return r.IsPremium() && !r.Unlocked
+}
+
+// HideLockedInterpretation 未解锁的付费解读只保留开头部分作为预览
+func (r *Reading) HideLockedInterpretation() {
+	if !r.IsLocked() {
+		return
+	}
+	runes := []rune(r.Interpretation)
+	if len(runes) > TeaserLength {
+		r.Interpretation = string(runes[:TeaserLength]) + "……"
+	}
+}
+
 // IsCompleted 检查是否已完成
 func (r *Reading) IsCompleted() bool {
 	return r.Status == string(StatusCompleted)
@@ -0,0 +1,33 @@
+package reading
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateQuestionBoundaries(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		length  int
+		char    string
+		wantErr bool
+	}{
+		{"9 chars", 9, "星", true},
+		{"10 chars", 10, "星", false},
+		{"500 chars", 500, "星", false},
+		{"501 chars", 501, "星", true},
+		{"9 ascii", 9, "a", true},
+		{"501 ascii", 501, "a", true},
+	} {
+		err := ValidateQuestion(strings.Repeat(tc.char, tc.length))
+		if (err != nil) != tc.wantErr {
+			t.Fatalf("%s: err = %v, wantErr %v", tc.name, err, tc.wantErr)
+		}
+	}
+}
+
+func TestValidateQuestionIgnoresSurroundingSpace(t *testing.T) {
+	if err := ValidateQuestion("  " + strings.Repeat("星", 9) + "  "); err == nil {
+		t.Fatal("padded 9-character question accepted")
+	}
+}
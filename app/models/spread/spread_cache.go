@@ -0,0 +1,87 @@
+package spread
+
+import (
+	"sync"
+	"time"
+
+	"tarot/pkg/database"
+)
+
+// 牌阵数量少且读多写少，全部缓存在内存中。
+// 管理端修改后调用 Reload 立即刷新当前实例，其他实例在缓存超过 CacheTTL 后自动重新加载；
+// 重新加载失败时继续使用上次成功加载的牌阵
+var (
+	cacheMu  sync.RWMutex
+	cache    map[uint64]Spread
+	loadedAt time.Time
+
+	// refreshMu 保证同一时间只有一个请求重新加载，其余请求继续读取旧缓存
+	refreshMu sync.Mutex
+
+	// now 当前时间，测试中可替换
+	now = time.Now
+)
+
+// CacheTTL 缓存有效期，超过后下次读取时重新加载，0 表示只在 Reload 时刷新
+var CacheTTL = 30 * time.Second
+
+// Reload 从数据库重新加载全部牌阵，失败时保留原缓存
+func Reload() error {
+	var spreads []Spread
+	if err := database.DB.Find(&spreads).Error; err != nil {
+		return err
+	}
+
+	loaded := make(map[uint64]Spread, len(spreads))
+	for _, s := range spreads {
+		loaded[s.ID] = s
+	}
+
+	cacheMu.Lock()
+	cache = loaded
+	loadedAt = now()
+	cacheMu.Unlock()
+	return nil
+}
+
+// Find 从缓存中获取牌阵，首次调用或缓存过期时加载
+func Find(id uint64) (*Spread, bool) {
+	if stale() {
+		refresh()
+	}
+
+	cacheMu.RLock()
+	s, ok := cache[id]
+	cacheMu.RUnlock()
+
+	if !ok {
+		return nil, false
+	}
+	return &s, true
+}
+
+// stale 缓存是否未加载或已过期
+func stale() bool {
+	cacheMu.RLock()
+	defer cacheMu.RUnlock()
+	return cache == nil || (CacheTTL > 0 && now().Sub(loadedAt) >= CacheTTL)
+}
+
+// refresh 重新加载过期的缓存
+// 加载失败且已有缓存时顺延有效期，避免数据库故障期间每次读取都查询数据库
+func refresh() {
+	refreshMu.Lock()
+	defer refreshMu.Unlock()
+
+	// 等待期间其他请求可能已完成加载
+	if !stale() {
+		return
+	}
+	if err := Reload(); err != nil {
+		cacheMu.Lock()
+		if cache != nil {
+			loadedAt = now()
+		}
+		cacheMu.Unlock()
+	}
+}
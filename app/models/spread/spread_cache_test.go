@@ -0,0 +1,103 @@
+package spread
+
+import (
+	"testing"
+	"time"
+
+	"tarot/pkg/database"
+	"tarot/pkg/testutil"
+)
+
+// setupCache 使用空数据库并重置缓存，返回可推进的时钟
+func setupCache(t *testing.T) *time.Time {
+	t.Helper()
+	testutil.SetupDB(t, &Spread{})
+
+	clock := time.Now()
+	prevNow, prevTTL := now, CacheTTL
+	now = func() time.Time { return clock }
+	CacheTTL = 30 * time.Second
+	resetCache()
+	t.Cleanup(func() {
+		now, CacheTTL = prevNow, prevTTL
+		resetCache()
+	})
+	return &clock
+}
+
+func resetCache() {
+	cacheMu.Lock()
+	cache, loadedAt = nil, time.Time{}
+	cacheMu.Unlock()
+}
+
+func createSpread(t *testing.T, name string) *Spread {
+	t.Helper()
+	s := &Spread{Slug: "three-card", Name: name, CardCount: 3, Positions: Positions{"过去", "现在", "未来"}}
+	if err := database.DB.Create(s).Error; err != nil {
+		t.Fatalf("create spread: %v", err)
+	}
+	return s
+}
+
+func TestFindPicksUpChangesFromOtherInstancesAfterTTL(t *testing.T) {
+	clock := setupCache(t)
+	s := createSpread(t, "三张牌")
+
+	if got, ok := Find(s.ID); !ok || got.Name != "三张牌" {
+		t.Fatalf("Find = %v, %t", got, ok)
+	}
+
+	// 模拟其他实例修改牌阵：本实例的缓存在过期前不变
+	if err := database.DB.Model(&Spread{}).Where("id = ?", s.ID).UpdateColumn("name", "时间之流").Error; err != nil {
+		t.Fatalf("update spread: %v", err)
+	}
+	if got, _ := Find(s.ID); got.Name != "三张牌" {
+		t.Fatalf("name before ttl = %q, want cached value", got.Name)
+	}
+
+	*clock = clock.Add(CacheTTL)
+	if got, _ := Find(s.ID); got.Name != "时间之流" {
+		t.Fatalf("name after ttl = %q, want reloaded value", got.Name)
+	}
+}
+
+func TestFailedReloadKeepsLastSnapshot(t *testing.T) {
+	clock := setupCache(t)
+	s := createSpread(t, "三张牌")
+	if _, ok := Find(s.ID); !ok {
+		t.Fatal("spread not found")
+	}
+
+	if err := database.DB.Migrator().DropTable(&Spread{}); err != nil {
+		t.Fatalf("drop table: %v", err)
+	}
+	if err := Reload(); err == nil {
+		t.Fatal("Reload succeeded without table")
+	}
+
+	*clock = clock.Add(CacheTTL)
+	if got, ok := Find(s.ID); !ok || got.Name != "三张牌" {
+		t.Fatalf("Find after failed reload = %v, %t, want last snapshot", got, ok)
+	}
+}
+
+func TestFindWithoutTTLOnlyRefreshesOnReload(t *testing.T) {
+	clock := setupCache(t)
+	CacheTTL = 0
+	s := createSpread(t, "三张牌")
+	Find(s.ID)
+
+	database.DB.Model(&Spread{}).Where("id = ?", s.ID).UpdateColumn("name", "时间之流")
+	*clock = clock.Add(time.Hour)
+	if got, _ := Find(s.ID); got.Name != "三张牌" {
+		t.Fatalf("name = %q, want cached value", got.Name)
+	}
+
+	if err := Reload(); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if got, _ := Find(s.ID); got.Name != "时间之流" {
+		t.Fatalf("name after Reload = %q", got.Name)
+	}
+}
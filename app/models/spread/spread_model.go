@@ -0,0 +1,29 @@
+// Package spread 牌阵模板
+package spread
+
+import (
+	"gorm.io/gorm"
+	"tarot/app/models"
+)
+
+// Spread 牌阵模板模型，由管理员维护
+type Spread struct {
+	ID          uint64    `gorm:"primaryKey;autoIncrement" json:"id"`
+	Slug        string    `gorm:"type:varchar(64);uniqueIndex" json:"slug"` // 唯一标识，例如 three-card
+	Name        string    `gorm:"type:varchar(100)" json:"name"`            // 牌阵名称
+	Description string    `gorm:"type:text" json:"description"`             // 牌阵说明
+	CardCount   int       `gorm:"" json:"card_count"`                       // 需要抽取的卡牌数量
+	Positions   Positions `gorm:"type:json" json:"positions"`               // 各位置含义，长度与 CardCount 一致
+
+	models.CommonTimestampsField
+}
+
+// TableName 指定表名
+func (Spread) TableName() string {
+	return "tarot_spreads"
+}
+
+// BeforeSave GORM 钩子 - 保存前校验
+func (s *Spread) BeforeSave(tx *gorm.DB) error {
+	return s.Validate()
+}
@@ -0,0 +1,69 @@
+package spread
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// MaxCardCount 单个牌阵允许的最大卡牌数量
+const MaxCardCount = 10
+
+// Positions 牌阵位置含义列表
+type Positions []string
+
+// Value 实现 driver.Valuer 接口
+func (p Positions) Value() (driver.Value, error) {
+	if len(p) == 0 {
+		return "[]", nil
+	}
+	return json.Marshal(p)
+}
+
+// Scan 实现 sql.Scanner 接口
+func (p *Positions) Scan(value interface{}) error {
+	if value == nil {
+		*p = Positions{}
+		return nil
+	}
+
+	var bytes []byte
+	switch v := value.(type) {
+	case []byte:
+		bytes = v
+	case string:
+		bytes = []byte(v)
+	default:
+		return errors.New("invalid type for positions")
+	}
+
+	return json.Unmarshal(bytes, p)
+}
+
+// Validate 验证牌阵定义
+func (s *Spread) Validate() error {
+	if s.Slug == "" {
+		return errors.New("slug is required")
+	}
+	if s.Name == "" {
+		return errors.New("name is required")
+	}
+	if s.CardCount < 1 || s.CardCount > MaxCardCount {
+		return fmt.Errorf("card_count must be between 1 and %d", MaxCardCount)
+	}
+	if len(s.Positions) != s.CardCount {
+		return errors.New("positions must match card_count")
+	}
+	return nil
+}
+
+// PromptText 生成传给 Dify 的牌阵说明，例如：三牌阵：1.过去 2.现在 3.未来
+func (s *Spread) PromptText() string {
+	parts := make([]string, len(s.Positions))
+	for i, position := range s.Positions {
+		parts[i] = fmt.Sprintf("%d.%s", i+1, position)
+	}
+	return fmt.Sprintf("%s：%s", s.Name, strings.Join(parts, " "))
+}
@@ -7,13 +7,17 @@ import (
 
 // User 用户模型
 type User struct {
-	ID        string `gorm:"primaryKey;type:varchar(36)"`
-	Email     string `gorm:"unique;type:varchar(255)"`
-	ClerkID   string `gorm:"unique;type:varchar(255);index"`
-	Nickname  string `gorm:"type:varchar(50)"`
-	AvatarURL string `gorm:"type:text"`
-	Credits   int    `gorm:"default:0;index"`                     // 用户积分/次数
-	GuestID   string `gorm:"type:varchar(36);index;default:null"` // 关联之前的游客ID
+	ID        string  `gorm:"primaryKey;type:varchar(36)"`
+	Email     *string `gorm:"unique;type:varchar(255)"` // 可能为空（如 Clerk 未提供邮箱），为空时存 NULL，避免唯一索引冲突
+	ClerkID   string  `gorm:"unique;type:varchar(255);index"`
+	Nickname  string  `gorm:"type:varchar(50)"`
+	AvatarURL string  `gorm:"type:text"`
+	Credits   int     `gorm:"default:0;index"`                     // 用户积分/次数
+	GuestID   string  `gorm:"type:varchar(36);index;default:null"` // 关联之前的游客ID
+
+	ReadingsCount int `gorm:"default:0"` // 累计测算次数（含游客迁移的记录）
+
+	RetentionDays int `gorm:"default:0"` // 解读记录保留天数，0 表示使用全局策略
 
 	models.CommonTimestampsField
 }
@@ -0,0 +1,27 @@
+// Package webhook 存放回调投递记录 Model
+package webhook
+
+import (
+	"tarot/app/models"
+)
+
+// Delivery 解读完成回调的单次投递记录
+type Delivery struct {
+	models.BaseModel
+
+	TaskID     string `gorm:"type:varchar(36);index" json:"task_id"`
+	URL        string `gorm:"type:text" json:"url"`
+	Event      string `gorm:"type:varchar(32)" json:"event"`
+	Attempt    int    `json:"attempt"`     // 第几次投递，从 1 开始
+	StatusCode int    `json:"status_code"` // 接收方响应码，网络错误时为 0
+	Error      string `gorm:"type:text" json:"error,omitempty"`
+	Success    bool   `gorm:"index" json:"success"`
+	DurationMs int64  `json:"duration_ms"`
+
+	models.CommonTimestampsField
+}
+
+// TableName 表名
+func (Delivery) TableName() string {
+	return "webhook_deliveries"
+}
@@ -0,0 +1,82 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"tarot/app/models/feedback"
+	"tarot/pkg/database"
+)
+
+// FeedbackSummary 评价汇总
+type FeedbackSummary struct {
+	Count         int64         `json:"count"`
+	AverageRating float64       `json:"average_rating"`
+	Distribution  map[int]int64 `json:"distribution"` // 各评分的数量
+}
+
+// FeedbackRepository 解读评价仓库
+type FeedbackRepository struct {
+	db *gorm.DB
+}
+
+// NewFeedbackRepository 创建仓库实例
+func NewFeedbackRepository() *FeedbackRepository {
+	return &FeedbackRepository{
+		db: database.DB,
+	}
+}
+
+// Upsert 创建或更新用户对解读的评价，同一用户对同一解读只保留一条
+func (r *FeedbackRepository) Upsert(ctx context.Context, f *feedback.Feedback) (*feedback.Feedback, error) {
+	f.UpdatedAt = time.Now()
+	err := r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "reading_id"}, {Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"rating", "comment", "updated_at"}),
+	}).Create(f).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var saved feedback.Feedback
+	if err := r.db.WithContext(ctx).
+		Where("reading_id = ? AND user_id = ?", f.ReadingID, f.UserID).
+		First(&saved).Error; err != nil {
+		return nil, err
+	}
+	return &saved, nil
+}
+
+// Summary 统计评价数量、平均分与评分分布
+func (r *FeedbackRepository) Summary(ctx context.Context) (*FeedbackSummary, error) {
+	var rows []struct {
+		Rating int
+		Count  int64
+	}
+	err := r.db.WithContext(ctx).Model(&feedback.Feedback{}).
+		Select("rating, COUNT(*) AS count").
+		Group("rating").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	summary := &FeedbackSummary{Distribution: make(map[int]int64, feedback.MaxRating)}
+	for rating := feedback.MinRating; rating <= feedback.MaxRating; rating++ {
+		summary.Distribution[rating] = 0
+	}
+
+	var total int64
+	for _, row := range rows {
+		summary.Distribution[row.Rating] = row.Count
+		summary.Count += row.Count
+		total += int64(row.Rating) * row.Count
+	}
+	if summary.Count > 0 {
+		summary.AverageRating = float64(total) / float64(summary.Count)
+	}
+	return summary, nil
+}
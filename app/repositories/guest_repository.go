@@ -0,0 +1,90 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+
+	"gorm.io/gorm"
+
+	"tarot/app/models/guest"
+	"tarot/pkg/database"
+	"tarot/pkg/helpers"
+)
+
+// ErrGuestReadingLimit 游客的免费测算次数已用完
+var ErrGuestReadingLimit = errors.New("guest reading limit reached")
+
+// GuestRepository 游客仓库
+type GuestRepository struct {
+	db *gorm.DB
+}
+
+// NewGuestRepository 创建仓库实例
+func NewGuestRepository() *GuestRepository {
+	return &GuestRepository{
+		db: database.DB,
+	}
+}
+
+// Create 创建游客，ID 为新生成的 UUID，注册后由 guest.MigrateToUser 关联到用户
+// freeReadings 为游客可进行的免费测算次数
+func (r *GuestRepository) Create(ctx context.Context, freeReadings int) (*guest.Guest, error) {
+	g := &guest.Guest{
+		ID:           helpers.UUID(),
+		FreeReadings: freeReadings,
+	}
+	// 字段带有默认值，Create 会把 0 替换为默认值，0 次时创建后单独写入
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(g).Error; err != nil {
+			return err
+		}
+		if freeReadings != 0 {
+			return nil
+		}
+		g.FreeReadings = 0
+		return tx.Model(g).UpdateColumn("free_readings", 0).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// GetByID 获取未迁移（未软删除）的游客
+func (r *GuestRepository) GetByID(ctx context.Context, id string) (*guest.Guest, error) {
+	var g guest.Guest
+	err := r.db.WithContext(ctx).
+		Where("id = ? AND deleted_at IS NULL", id).
+		First(&g).Error
+	if err != nil {
+		return nil, err
+	}
+	return &g, nil
+}
+
+// ConsumeReading 占用一次免费测算次数
+// 判断与扣减在同一条 UPDATE 中完成，并发请求也不会超出上限
+func (r *GuestRepository) ConsumeReading(ctx context.Context, id string) error {
+	result := r.db.WithContext(ctx).Model(&guest.Guest{}).
+		Where("id = ? AND deleted_at IS NULL AND free_readings > 0", id).
+		Update("free_readings", gorm.Expr("free_readings - 1"))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		return nil
+	}
+
+	// 未更新时区分游客不存在与次数已用完
+	if _, err := r.GetByID(ctx, id); err != nil {
+		return err
+	}
+	return ErrGuestReadingLimit
+}
+
+// ReleaseReading 归还一次测算次数，用于解读创建失败时回滚 ConsumeReading
+func (r *GuestRepository) ReleaseReading(ctx context.Context, id string) error {
+	return r.db.WithContext(ctx).Model(&guest.Guest{}).
+		Where("id = ? AND deleted_at IS NULL", id).
+		Update("free_readings", gorm.Expr("free_readings + 1")).Error
+}
@@ -0,0 +1,104 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"gorm.io/gorm"
+
+	"tarot/app/models/guest"
+	"tarot/pkg/testutil"
+)
+
+func TestGuestCreateAndGet(t *testing.T) {
+	testutil.SetupDB(t, &guest.Guest{})
+	repo := NewGuestRepository()
+	ctx := context.Background()
+
+	g, err := repo.Create(ctx, 0)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if len(g.ID) != 36 {
+		t.Fatalf("guest id = %q, want a UUID", g.ID)
+	}
+
+	got, err := repo.GetByID(ctx, g.ID)
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	// 0 次免费测算需要原样写入，而不是回落到字段默认值 1
+	if got.FreeReadings != 0 {
+		t.Fatalf("free readings = %d, want 0", got.FreeReadings)
+	}
+
+	if _, err := repo.GetByID(ctx, "missing"); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("GetByID missing error = %v, want ErrRecordNotFound", err)
+	}
+}
+
+func TestGuestReadingCap(t *testing.T) {
+	testutil.SetupDB(t, &guest.Guest{})
+	repo := NewGuestRepository()
+	ctx := context.Background()
+
+	g, err := repo.Create(ctx, 2)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := repo.ConsumeReading(ctx, g.ID); err != nil {
+			t.Fatalf("ConsumeReading %d: %v", i+1, err)
+		}
+	}
+	if err := repo.ConsumeReading(ctx, g.ID); !errors.Is(err, ErrGuestReadingLimit) {
+		t.Fatalf("ConsumeReading over cap error = %v, want ErrGuestReadingLimit", err)
+	}
+
+	// 创建解读失败时归还的次数可以再次使用
+	if err := repo.ReleaseReading(ctx, g.ID); err != nil {
+		t.Fatalf("ReleaseReading: %v", err)
+	}
+	if err := repo.ConsumeReading(ctx, g.ID); err != nil {
+		t.Fatalf("ConsumeReading after release: %v", err)
+	}
+
+	if err := repo.ConsumeReading(ctx, "missing"); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("ConsumeReading missing guest error = %v, want ErrRecordNotFound", err)
+	}
+}
+
+func TestGuestReadingCapUnderConcurrency(t *testing.T) {
+	testutil.SetupDB(t, &guest.Guest{})
+	repo := NewGuestRepository()
+	ctx := context.Background()
+
+	g, err := repo.Create(ctx, 3)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	var consumed atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := repo.ConsumeReading(ctx, g.ID)
+			switch {
+			case err == nil:
+				consumed.Add(1)
+			case !errors.Is(err, ErrGuestReadingLimit):
+				t.Errorf("ConsumeReading: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := consumed.Load(); n != 3 {
+		t.Fatalf("consumed %d readings, want 3", n)
+	}
+}
@@ -4,7 +4,9 @@ import (
 	"context"
 	"gorm.io/gorm"
 	"tarot/app/models/payment"
+	"tarot/app/models/reading"
 	"tarot/pkg/database"
+	"time"
 )
 
 // PaymentRepository 支付记录仓库
@@ -24,9 +26,81 @@ func (r *PaymentRepository) Create(ctx context.Context, payment *payment.Payment
 	return r.db.WithContext(ctx).Create(payment).Error
 }
 
-// Update 更新支付记录
-func (r *PaymentRepository) Update(ctx context.Context, payment *payment.Payment) error {
-	return r.db.WithContext(ctx).Save(payment).Error
+// Update 更新支付记录，状态变化时在同一事务中写入变更记录
+func (r *PaymentRepository) Update(ctx context.Context, p *payment.Payment) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := recordTransition(ctx, tx, p); err != nil {
+			return err
+		}
+		return tx.Save(p).Error
+	})
+}
+
+// CompletePayment 保存已支付的订单并解锁对应的解读，两者在同一事务中完成
+func (r *PaymentRepository) CompletePayment(ctx context.Context, p *payment.Payment) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := recordTransition(ctx, tx, p); err != nil {
+			return err
+		}
+		if err := tx.Save(p).Error; err != nil {
+			return err
+		}
+		if p.ReadingID == 0 {
+			return nil
+		}
+		// 使用 UpdateColumns 跳过 Reading 的保存钩子（空模型无法通过校验）
+		return tx.Model(&reading.Reading{}).
+			Where("id = ?", p.ReadingID).
+			UpdateColumns(map[string]interface{}{
+				"unlocked":    true,
+				"unlocked_at": time.Now(),
+			}).Error
+	})
+}
+
+// recordTransition 对比数据库中的状态，变化时写入一条 PaymentEvent
+// 状态以条件更新的方式切换，只有成功切换的一方写入记录
+// 来源与报文摘要由调用方通过 payment.WithEventSource 放入 ctx
+func recordTransition(ctx context.Context, tx *gorm.DB, p *payment.Payment) error {
+	if p.ID == 0 {
+		return nil
+	}
+
+	var from string
+	if err := tx.Model(&payment.Payment{}).Select("status").Where("id = ?", p.ID).Scan(&from).Error; err != nil {
+		return err
+	}
+	if from == p.Status {
+		return nil
+	}
+
+	// 以读到的状态为条件更新，并发的通知已完成同一变化时不再重复记录
+	result := tx.Model(&payment.Payment{}).
+		Where("id = ? AND status = ?", p.ID, from).
+		UpdateColumn("status", p.Status)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return result.Error
+	}
+
+	source, payloadHash := payment.EventSourceFrom(ctx)
+	return tx.Create(&payment.PaymentEvent{
+		PaymentID:   p.ID,
+		OrderNo:     p.OrderNo,
+		FromStatus:  from,
+		ToStatus:    p.Status,
+		Source:      string(source),
+		PayloadHash: payloadHash,
+	}).Error
+}
+
+// ListEvents 获取订单的状态变化记录，按时间先后排列
+func (r *PaymentRepository) ListEvents(ctx context.Context, paymentID uint64) ([]payment.PaymentEvent, error) {
+	var events []payment.PaymentEvent
+	err := r.db.WithContext(ctx).
+		Where("payment_id = ?", paymentID).
+		Order("id ASC").
+		Find(&events).Error
+	return events, err
 }
 
 // GetByOrderNo 根据订单号获取支付记录
@@ -39,6 +113,32 @@ func (r *PaymentRepository) GetByOrderNo(ctx context.Context, orderNo string) (*
 	return &payment, nil
 }
 
+// SoftDelete 软删除订单，删除后不再出现在列表、对账与统计中，可通过 Restore 恢复
+func (r *PaymentRepository) SoftDelete(ctx context.Context, orderNo string) error {
+	result := r.db.WithContext(ctx).Where("order_no = ?", orderNo).Delete(&payment.Payment{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// Restore 恢复已软删除的订单，订单不存在或未删除时返回 gorm.ErrRecordNotFound
+func (r *PaymentRepository) Restore(ctx context.Context, orderNo string) (*payment.Payment, error) {
+	result := r.db.WithContext(ctx).Unscoped().Model(&payment.Payment{}).
+		Where("order_no = ? AND deleted_at IS NOT NULL", orderNo).
+		UpdateColumn("deleted_at", nil)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return r.GetByOrderNo(ctx, orderNo)
+}
+
 // GetByTransactionID 根据交易ID获取支付记录
 func (r *PaymentRepository) GetByTransactionID(ctx context.Context, transactionID string) (*payment.Payment, error) {
 	var payment payment.Payment
@@ -47,4 +147,169 @@ func (r *PaymentRepository) GetByTransactionID(ctx context.Context, transactionI
 		return nil, err
 	}
 	return &payment, nil
-} 
\ No newline at end of file
+}
+
+// CreateRefund 创建退款记录
+func (r *PaymentRepository) CreateRefund(ctx context.Context, refund *payment.Refund) error {
+	return r.db.WithContext(ctx).Create(refund).Error
+}
+
+// UpdateRefund 更新处理中的退款记录（渠道退款单号、失败状态），已结束的退款不再改动
+func (r *PaymentRepository) UpdateRefund(ctx context.Context, refund *payment.Refund) error {
+	return r.db.WithContext(ctx).Model(&payment.Refund{}).
+		Where("id = ? AND status = ?", refund.ID, payment.RefundStatusProcessing).
+		Updates(map[string]interface{}{
+			"status":             refund.Status,
+			"provider_refund_id": refund.ProviderRefundID,
+		}).Error
+}
+
+// FinishRefund 将处理中的退款标记为成功，并在同一事务中累加订单的退款金额
+// 退款已由其他途径（通知、补查）完成时不重复累加
+func (r *PaymentRepository) FinishRefund(ctx context.Context, refund *payment.Refund) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&payment.Refund{}).
+			Where("id = ? AND status = ?", refund.ID, payment.RefundStatusProcessing).
+			Updates(map[string]interface{}{
+				"status":             payment.RefundStatusSuccess,
+				"provider_refund_id": refund.ProviderRefundID,
+			})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		refund.Status = string(payment.RefundStatusSuccess)
+
+		var p payment.Payment
+		if err := tx.First(&p, refund.PaymentID).Error; err != nil {
+			return err
+		}
+		p.ApplyRefund(refund.Amount)
+		if err := recordTransition(ctx, tx, &p); err != nil {
+			return err
+		}
+		return tx.Save(&p).Error
+	})
+}
+
+// ProcessingRefundAmount 订单处理中的退款总金额，这部分金额尚未计入累计退款但不能再次退款
+func (r *PaymentRepository) ProcessingRefundAmount(ctx context.Context, paymentID uint64) (int64, error) {
+	var amount int64
+	err := r.db.WithContext(ctx).Model(&payment.Refund{}).
+		Select("COALESCE(SUM(amount), 0)").
+		Where("payment_id = ? AND status = ?", paymentID, payment.RefundStatusProcessing).
+		Scan(&amount).Error
+	return amount, err
+}
+
+// ListProcessingRefunds 获取创建时间早于 createdBefore 仍在处理中的退款，用于向支付平台补查
+func (r *PaymentRepository) ListProcessingRefunds(ctx context.Context, createdBefore time.Time, limit int) ([]payment.Refund, error) {
+	var refunds []payment.Refund
+	err := r.db.WithContext(ctx).
+		Where("status = ? AND created_at < ?", payment.RefundStatusProcessing, createdBefore).
+		Order("created_at ASC").
+		Limit(limit).
+		Find(&refunds).Error
+	return refunds, err
+}
+
+// ListExpiredPending 获取已过期但仍处于待支付状态的订单
+func (r *PaymentRepository) ListExpiredPending(ctx context.Context, before time.Time, limit int) ([]payment.Payment, error) {
+	var payments []payment.Payment
+	err := r.db.WithContext(ctx).
+		Where("status = ? AND expire_at IS NOT NULL AND expire_at < ?", payment.StatusPending, before).
+		Order("expire_at ASC").
+		Limit(limit).
+		Find(&payments).Error
+	return payments, err
+}
+
+// ListByUserID 分页获取用户的支付记录，status 为空时不过滤状态
+func (r *PaymentRepository) ListByUserID(ctx context.Context, userID, status string, page, pageSize int) ([]payment.Payment, int64, error) {
+	var payments []payment.Payment
+	var total int64
+
+	query := r.db.WithContext(ctx).Model(&payment.Payment{}).Where("user_id = ?", userID)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := query.Order("created_at DESC").
+		Offset((page - 1) * pageSize).
+		Limit(pageSize).
+		Find(&payments).Error
+	return payments, total, err
+}
+
+// FindReusablePending 获取用户对同一解读、同一支付方式仍可继续支付的订单
+// 只返回在 validUntil 之后才过期的待支付订单，没有时返回 gorm.ErrRecordNotFound
+func (r *PaymentRepository) FindReusablePending(ctx context.Context, userID string, readingID uint64, provider string, validUntil time.Time) (*payment.Payment, error) {
+	var p payment.Payment
+	err := r.db.WithContext(ctx).
+		Where("user_id = ? AND reading_id = ? AND provider = ? AND status = ?", userID, readingID, provider, payment.StatusPending).
+		Where("expire_at IS NOT NULL AND expire_at > ?", validUntil).
+		Order("created_at DESC").
+		First(&p).Error
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// SaveResult 保存下单结果，重复下单复用订单时原样返回给客户端
+func (r *PaymentRepository) SaveResult(ctx context.Context, orderNo string, result interface{}) error {
+	p, err := r.GetByOrderNo(ctx, orderNo)
+	if err != nil {
+		return err
+	}
+	if p.ExtraData == nil {
+		p.ExtraData = payment.JSON{}
+	}
+	p.ExtraData[payment.ResultKey] = result
+	return r.db.WithContext(ctx).Model(p).UpdateColumn("extra_data", p.ExtraData).Error
+}
+
+// ListStalePending 获取创建时间早于 createdBefore、仍待支付且尚未过期的订单，用于向支付平台补查
+func (r *PaymentRepository) ListStalePending(ctx context.Context, createdBefore, now time.Time, limit int) ([]payment.Payment, error) {
+	var payments []payment.Payment
+	err := r.db.WithContext(ctx).
+		Where("status = ? AND created_at < ?", payment.StatusPending, createdBefore).
+		Where("expire_at IS NULL OR expire_at >= ?", now).
+		Order("created_at ASC").
+		Limit(limit).
+		Find(&payments).Error
+	return payments, err
+}
+
+// PaymentTotals 一段时间内的支付汇总，金额单位为分
+type PaymentTotals struct {
+	PaidCount      int64 `json:"paid_count"`      // 支付成功的订单数（含之后退款的）
+	PaidAmount     int64 `json:"paid_amount"`     // 支付成功的总金额
+	RefundedAmount int64 `json:"refunded_amount"` // 其中已退款的金额
+}
+
+// TotalsSince 统计 since 之后支付成功的订单
+func (r *PaymentRepository) TotalsSince(ctx context.Context, since time.Time) (*PaymentTotals, error) {
+	var totals PaymentTotals
+	err := r.db.WithContext(ctx).Model(&payment.Payment{}).
+		Select("COUNT(*) AS paid_count, COALESCE(SUM(amount), 0) AS paid_amount, COALESCE(SUM(refunded_amount), 0) AS refunded_amount").
+		Where("pay_at >= ? AND status IN ?", since,
+			[]string{string(payment.StatusPaid), string(payment.StatusRefunded)}).
+		Scan(&totals).Error
+	if err != nil {
+		return nil, err
+	}
+	return &totals, nil
+}
+
+// HasPaidReading 检查解读是否已存在成功支付的订单
+func (r *PaymentRepository) HasPaidReading(ctx context.Context, readingID uint64) (bool, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&payment.Payment{}).
+		Where("reading_id = ? AND status = ?", readingID, payment.StatusPaid).
+		Count(&count).Error
+	return count > 0, err
+}
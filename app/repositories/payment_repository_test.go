@@ -0,0 +1,183 @@
+package repositories
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+
+	"tarot/app/models/payment"
+	"tarot/app/models/reading"
+	"tarot/pkg/testutil"
+)
+
+// createPendingPayment 创建一笔待支付订单及其解读记录
+func createPendingPayment(t *testing.T, orderNo string) *payment.Payment {
+	t.Helper()
+	r := &reading.Reading{TaskID: "task-" + orderNo, UserID: "user-1", Type: reading.TypePremium, Cards: reading.Cards{1}}
+	if err := NewReadingRepository().Create(context.Background(), r); err != nil {
+		t.Fatalf("create reading: %v", err)
+	}
+	expireAt := time.Now().Add(30 * time.Minute)
+	p := &payment.Payment{OrderNo: orderNo, UserID: "user-1", ReadingID: r.ID, Provider: "wechat", Amount: 2000, Status: string(payment.StatusPending), ExpireAt: &expireAt}
+	if err := NewPaymentRepository().Create(context.Background(), p); err != nil {
+		t.Fatalf("create payment: %v", err)
+	}
+	return p
+}
+
+func listEvents(t *testing.T, p *payment.Payment) []payment.PaymentEvent {
+	t.Helper()
+	events, err := NewPaymentRepository().ListEvents(context.Background(), p.ID)
+	if err != nil {
+		t.Fatalf("ListEvents: %v", err)
+	}
+	return events
+}
+
+func TestPaymentTransitionsRecordOneEventEach(t *testing.T) {
+	testutil.SetupDB(t, &reading.Reading{}, &payment.Payment{}, &payment.PaymentEvent{})
+	repo := NewPaymentRepository()
+	p := createPendingPayment(t, "order-1")
+
+	// pending → paid：支付平台回调，记录报文摘要
+	body := []byte(`{"out_trade_no":"order-1","trade_state":"SUCCESS"}`)
+	p.Status = string(payment.StatusPaid)
+	if err := repo.CompletePayment(payment.WithEventSource(context.Background(), payment.EventSourceNotify, body), p); err != nil {
+		t.Fatalf("CompletePayment: %v", err)
+	}
+	events := listEvents(t, p)
+	sum := sha256.Sum256(body)
+	if len(events) != 1 {
+		t.Fatalf("events after payment = %+v, want 1", events)
+	}
+	if e := events[0]; e.FromStatus != string(payment.StatusPending) || e.ToStatus != string(payment.StatusPaid) ||
+		e.Source != string(payment.EventSourceNotify) || e.PayloadHash != hex.EncodeToString(sum[:]) || e.OrderNo != "order-1" {
+		t.Fatalf("payment event = %+v", e)
+	}
+
+	// 状态未变化的保存（例如重复回调）不写入记录
+	if err := repo.CompletePayment(payment.WithEventSource(context.Background(), payment.EventSourceNotify, body), p); err != nil {
+		t.Fatalf("CompletePayment again: %v", err)
+	}
+	if events := listEvents(t, p); len(events) != 1 {
+		t.Fatalf("events after duplicate notify = %d, want 1", len(events))
+	}
+
+	// paid → refunded：未指定来源时视为人工操作
+	p.Status = string(payment.StatusRefunded)
+	if err := repo.Update(context.Background(), p); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	events = listEvents(t, p)
+	if len(events) != 2 {
+		t.Fatalf("events after refund = %+v, want 2", events)
+	}
+	if e := events[1]; e.FromStatus != string(payment.StatusPaid) || e.ToStatus != string(payment.StatusRefunded) ||
+		e.Source != string(payment.EventSourceManual) || e.PayloadHash != "" {
+		t.Fatalf("refund event = %+v", e)
+	}
+}
+
+// TestConcurrentCompletionRecordsOneEvent 两个通知同时完成同一订单时只记录一次 pending → paid
+func TestConcurrentCompletionRecordsOneEvent(t *testing.T) {
+	db := testutil.SetupDB(t, &reading.Reading{}, &payment.Payment{}, &payment.PaymentEvent{})
+	p := createPendingPayment(t, "order-1")
+	ctx := payment.WithEventSource(context.Background(), payment.EventSourceNotify, []byte(`{"trade_state":"SUCCESS"}`))
+
+	// 第一个通知读到 pending 后、写入前，另一个通知在同一时刻完成了订单
+	var armed atomic.Bool
+	armed.Store(true)
+	err := db.Callback().Update().Before("gorm:update").Register("test:concurrent_notify", func(tx *gorm.DB) {
+		if tx.Statement.Table != "payments" || !armed.CompareAndSwap(true, false) {
+			return
+		}
+		notified := *p
+		notified.Status = string(payment.StatusPaid)
+		// 与当前事务共用连接，模拟另一个通知在此刻提交
+		other := db.Session(&gorm.Session{NewDB: true, Initialized: true})
+		other.Statement.ConnPool = tx.Statement.ConnPool
+		repo := &PaymentRepository{db: other}
+		if err := repo.CompletePayment(ctx, &notified); err != nil {
+			t.Errorf("concurrent CompletePayment: %v", err)
+		}
+	})
+	if err != nil {
+		t.Fatalf("register callback: %v", err)
+	}
+
+	p.Status = string(payment.StatusPaid)
+	if err := NewPaymentRepository().CompletePayment(ctx, p); err != nil {
+		t.Fatalf("CompletePayment: %v", err)
+	}
+	if armed.Load() {
+		t.Fatal("concurrent notify did not run")
+	}
+	events := listEvents(t, p)
+	if len(events) != 1 || events[0].FromStatus != string(payment.StatusPending) || events[0].ToStatus != string(payment.StatusPaid) {
+		t.Fatalf("events = %+v, want a single pending → paid", events)
+	}
+}
+
+func TestPaymentUpdateWithoutStatusChangeRecordsNothing(t *testing.T) {
+	testutil.SetupDB(t, &reading.Reading{}, &payment.Payment{}, &payment.PaymentEvent{})
+	p := createPendingPayment(t, "order-1")
+
+	p.TransactionID = "wx-1"
+	if err := NewPaymentRepository().Update(context.Background(), p); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if events := listEvents(t, p); len(events) != 0 {
+		t.Fatalf("events = %+v, want none", events)
+	}
+}
+
+func TestSoftDeletedPaymentsAreExcludedUntilRestored(t *testing.T) {
+	testutil.SetupDB(t, &reading.Reading{}, &payment.Payment{}, &payment.PaymentEvent{})
+	repo := NewPaymentRepository()
+	ctx := context.Background()
+	createPendingPayment(t, "order-keep")
+	p := createPendingPayment(t, "order-test")
+	payAt := time.Now()
+	p.Status = string(payment.StatusRefunded)
+	p.PayAt = &payAt
+	p.RefundedAmount = p.Amount
+	if err := repo.Update(ctx, p); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+
+	if err := repo.SoftDelete(ctx, "order-test"); err != nil {
+		t.Fatalf("SoftDelete: %v", err)
+	}
+	payments, total, err := repo.ListByUserID(ctx, "user-1", "", 1, 10)
+	if err != nil || total != 1 || len(payments) != 1 || payments[0].OrderNo != "order-keep" {
+		t.Fatalf("ListByUserID after delete = %d of %d, %v, want only order-keep", len(payments), total, err)
+	}
+	if _, err := repo.GetByOrderNo(ctx, "order-test"); err == nil {
+		t.Fatal("GetByOrderNo found a soft-deleted payment")
+	}
+	if totals, err := repo.TotalsSince(ctx, payAt.Add(-time.Hour)); err != nil || totals.PaidCount != 0 {
+		t.Fatalf("TotalsSince after delete = %+v, %v, want the payment excluded", totals, err)
+	}
+	if err := repo.SoftDelete(ctx, "order-test"); err == nil {
+		t.Fatal("SoftDelete of an already deleted payment succeeded")
+	}
+
+	restored, err := repo.Restore(ctx, "order-test")
+	if err != nil || restored.OrderNo != "order-test" || restored.DeletedAt.Valid {
+		t.Fatalf("Restore = %+v, %v", restored, err)
+	}
+	if _, total, _ := repo.ListByUserID(ctx, "user-1", "", 1, 10); total != 2 {
+		t.Fatalf("ListByUserID after restore = %d payments, want 2", total)
+	}
+	if totals, _ := repo.TotalsSince(ctx, payAt.Add(-time.Hour)); totals.PaidCount != 1 || totals.RefundedAmount != p.Amount {
+		t.Fatalf("TotalsSince after restore = %+v", totals)
+	}
+	if _, err := repo.Restore(ctx, "order-test"); err == nil {
+		t.Fatal("Restore of a payment that is not deleted succeeded")
+	}
+}
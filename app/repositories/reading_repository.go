@@ -4,7 +4,10 @@ import (
 	"context"
 	"gorm.io/gorm"
 	"tarot/app/models/reading"
+	"tarot/app/models/user"
 	"tarot/pkg/database"
+	"strings"
+	"time"
 )
 
 // ReadingRepository 塔罗牌阅读记录仓库
@@ -25,12 +28,16 @@ func (r *ReadingRepository) Create(ctx context.Context, reading *reading.Reading
 }
 
 // GetByUserID 获取用户的历史记录
-func (r *ReadingRepository) GetByUserID(ctx context.Context, userID string, page, pageSize int) ([]reading.Reading, int64, error) {
+// keyword 不为空时按问题内容模糊匹配（不区分大小写），通配符按字面量处理
+func (r *ReadingRepository) GetByUserID(ctx context.Context, userID, keyword string, page, pageSize int) ([]reading.Reading, int64, error) {
 	var readings []reading.Reading
 	var total int64
 	
 	// 使用预加载和索引优化查询
 	query := r.db.WithContext(ctx).Model(&reading.Reading{}).Where("user_id = ?", userID)
+	if keyword != "" {
+		query = query.Where(`LOWER(question) LIKE ? ESCAPE '\'`, "%"+escapeLike(strings.ToLower(keyword))+"%")
+	}
 	
 	// 获取总数
 	if err := query.Count(&total).Error; err != nil {
@@ -46,6 +53,30 @@ func (r *ReadingRepository) GetByUserID(ctx context.Context, userID string, page
 	return readings, total, err
 }
 
+// activeReadingLimit 进行中的解读最多返回的条数
+const activeReadingLimit = 50
+
+// GetActiveByUserID 获取用户等待或解读中的记录，按创建时间从新到旧排列
+// 使用 (user_id, status, created_at) 复合索引
+func (r *ReadingRepository) GetActiveByUserID(ctx context.Context, userID string) ([]reading.Reading, error) {
+	var readings []reading.Reading
+	err := r.db.WithContext(ctx).
+		Where("user_id = ? AND status IN ?", userID, []string{string(reading.StatusPending), string(reading.StatusProcessing)}).
+		Order("created_at DESC").
+		Limit(activeReadingLimit).
+		Find(&readings).Error
+	return readings, err
+}
+
+// GetByID 根据主键获取阅读记录
+func (r *ReadingRepository) GetByID(ctx context.Context, id uint64) (*reading.Reading, error) {
+	var reading reading.Reading
+	if err := r.db.WithContext(ctx).First(&reading, id).Error; err != nil {
+		return nil, err
+	}
+	return &reading, nil
+}
+
 // GetByTaskID 获取单次测算结果
 func (r *ReadingRepository) GetByTaskID(ctx context.Context, userID, taskID string) (*reading.Reading, error) {
 	var reading reading.Reading
@@ -60,4 +91,130 @@ func (r *ReadingRepository) GetByTaskID(ctx context.Context, userID, taskID stri
 	}
 	
 	return &reading, nil
-} 
\ No newline at end of file
+}
+
+// OwnedTaskIDs 返回 taskIDs 中属于 userID 的任务 ID
+func (r *ReadingRepository) OwnedTaskIDs(ctx context.Context, userID string, taskIDs []string) ([]string, error) {
+	var owned []string
+	err := r.db.WithContext(ctx).Model(&reading.Reading{}).
+		Where("user_id = ? AND task_id IN ?", userID, taskIDs).
+		Pluck("task_id", &owned).Error
+	return owned, err
+}
+
+// SetConversationID 记录解读所属的 Dify 对话
+func (r *ReadingRepository) SetConversationID(ctx context.Context, taskID, conversationID string) error {
+	// 使用 UpdateColumn 跳过 Reading 的保存钩子（空模型无法通过校验）
+	return r.db.WithContext(ctx).Model(&reading.Reading{}).
+		Where("task_id = ?", taskID).
+		UpdateColumn("conversation_id", conversationID).Error
+}
+
+// SetStatus 更新解读记录的状态
+func (r *ReadingRepository) SetStatus(ctx context.Context, taskID string, status reading.Status) error {
+	return r.db.WithContext(ctx).Model(&reading.Reading{}).
+		Where("task_id = ?", taskID).
+		UpdateColumn("status", string(status)).Error
+}
+
+// UpdateResultByTaskID 写入解读结束后的状态与解读结果，返回是否有记录被更新
+// 按 task_id 唯一索引单条更新，不经过模型的保存钩子
+func (r *ReadingRepository) UpdateResultByTaskID(ctx context.Context, taskID string, status reading.Status, interpretation string) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&reading.Reading{}).
+		Where("task_id = ?", taskID).
+		UpdateColumns(map[string]interface{}{
+			"status":         string(status),
+			"interpretation": interpretation,
+			"updated_at":     time.Now(),
+		})
+	return result.RowsAffected > 0, result.Error
+}
+
+// HasRetry 失败的解读是否已经重新解读过
+func (r *ReadingRepository) HasRetry(ctx context.Context, taskID string) (bool, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&reading.Reading{}).
+		Where("retry_of_task_id = ?", taskID).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// CountByStatusSince 统计 since 之后创建的解读在各状态下的数量
+func (r *ReadingRepository) CountByStatusSince(ctx context.Context, since time.Time) (map[string]int64, error) {
+	var rows []struct {
+		Status string
+		Count  int64
+	}
+	err := r.db.WithContext(ctx).Model(&reading.Reading{}).
+		Select("status, COUNT(*) AS count").
+		Where("created_at >= ?", since).
+		Group("status").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[string]int64, len(rows))
+	for _, row := range rows {
+		counts[row.Status] = row.Count
+	}
+	return counts, nil
+}
+
+// UnfinishedTaskIDsSince since 之后创建、记录中仍为等待或解读中的任务 ID
+// 记录可能尚未写入工作器的结果，调用方据此查询 Redis 中的实际状态
+func (r *ReadingRepository) UnfinishedTaskIDsSince(ctx context.Context, since time.Time) ([]string, error) {
+	var taskIDs []string
+	err := r.db.WithContext(ctx).Model(&reading.Reading{}).
+		Where("created_at >= ? AND status IN ?", since,
+			[]string{string(reading.StatusPending), string(reading.StatusProcessing)}).
+		Pluck("task_id", &taskIDs).Error
+	return taskIDs, err
+}
+
+// PurgeExpired 删除超过保留期限的阅读记录，返回删除条数
+// 设置了保留天数的用户按个人设置清理，其余用户按全局天数清理；globalDays 为 0 表示全局永久保留
+func (r *ReadingRepository) PurgeExpired(ctx context.Context, globalDays int, now time.Time) (int64, error) {
+	db := r.db.WithContext(ctx)
+	var purged int64
+
+	// 1. 按用户个人保留天数清理
+	var userDays []int
+	if err := db.Model(&user.User{}).
+		Where("retention_days > 0").
+		Distinct().
+		Pluck("retention_days", &userDays).Error; err != nil {
+		return purged, err
+	}
+
+	for _, days := range userDays {
+		users := db.Model(&user.User{}).Select("id").Where("retention_days = ?", days)
+		result := db.Where("user_id IN (?) AND created_at < ?", users, now.AddDate(0, 0, -days)).
+			Delete(&reading.Reading{})
+		if result.Error != nil {
+			return purged, result.Error
+		}
+		purged += result.RowsAffected
+	}
+
+	// 2. 未设置个人偏好的用户按全局策略清理
+	if globalDays > 0 {
+		overridden := db.Model(&user.User{}).Select("id").Where("retention_days > 0")
+		result := db.Where("user_id NOT IN (?) AND created_at < ?", overridden, now.AddDate(0, 0, -globalDays)).
+			Delete(&reading.Reading{})
+		if result.Error != nil {
+			return purged, result.Error
+		}
+		purged += result.RowsAffected
+	}
+
+	return purged, nil
+}
+
+// likeEscaper 转义 LIKE 通配符，避免用户输入的 % 与 _ 造成全表匹配
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// escapeLike 转义 LIKE 查询中的特殊字符，需配合 ESCAPE '\' 使用
+func escapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
@@ -0,0 +1,251 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"testing"
+	"time"
+
+	"tarot/app/models"
+	"tarot/app/models/reading"
+	"tarot/app/models/user"
+	"tarot/pkg/database"
+	"tarot/pkg/testutil"
+)
+
+func createUser(t *testing.T, id string, retentionDays int) {
+	t.Helper()
+	email := id + "@example.com"
+	u := &user.User{ID: id, Email: &email, ClerkID: "clerk_" + id, RetentionDays: retentionDays}
+	if err := database.DB.Create(u).Error; err != nil {
+		t.Fatalf("create user %s: %v", id, err)
+	}
+}
+
+func createReadingAt(t *testing.T, taskID, userID string, createdAt time.Time) {
+	t.Helper()
+	r := &reading.Reading{
+		TaskID:                taskID,
+		UserID:                userID,
+		Type:                  reading.TypeFree,
+		Cards:                 reading.Cards{1},
+		CommonTimestampsField: models.CommonTimestampsField{CreatedAt: createdAt, UpdatedAt: createdAt},
+	}
+	if err := database.DB.Create(r).Error; err != nil {
+		t.Fatalf("create reading %s: %v", taskID, err)
+	}
+}
+
+func remainingTasks(t *testing.T) map[string]bool {
+	t.Helper()
+	var taskIDs []string
+	if err := database.DB.Model(&reading.Reading{}).Pluck("task_id", &taskIDs).Error; err != nil {
+		t.Fatalf("list readings: %v", err)
+	}
+	left := make(map[string]bool, len(taskIDs))
+	for _, id := range taskIDs {
+		left[id] = true
+	}
+	return left
+}
+
+func TestPurgeExpiredUserRetentionOverridesGlobal(t *testing.T) {
+	testutil.SetupDB(t, &user.User{}, &reading.Reading{})
+	now := time.Now()
+	days := func(n int) time.Time { return now.AddDate(0, 0, -n) }
+
+	createUser(t, "short", 7)   // 个人保留 7 天，短于全局
+	createUser(t, "long", 90)   // 个人保留 90 天，长于全局
+	createUser(t, "default", 0) // 未设置，使用全局 30 天
+	createReadingAt(t, "short-10d", "short", days(10))
+	createReadingAt(t, "short-3d", "short", days(3))
+	createReadingAt(t, "long-60d", "long", days(60))
+	createReadingAt(t, "long-100d", "long", days(100))
+	createReadingAt(t, "default-20d", "default", days(20))
+	createReadingAt(t, "default-40d", "default", days(40))
+	createReadingAt(t, "guest-40d", "guest-1", days(40)) // 游客没有用户记录，按全局清理
+
+	purged, err := NewReadingRepository().PurgeExpired(context.Background(), 30, now)
+	if err != nil {
+		t.Fatalf("PurgeExpired: %v", err)
+	}
+	if purged != 4 {
+		t.Fatalf("purged = %d, want 4", purged)
+	}
+
+	left := remainingTasks(t)
+	for _, id := range []string{"short-3d", "long-60d", "default-20d"} {
+		if !left[id] {
+			t.Errorf("%s was purged, want kept", id)
+		}
+	}
+	for _, id := range []string{"short-10d", "long-100d", "default-40d", "guest-40d"} {
+		if left[id] {
+			t.Errorf("%s was kept, want purged", id)
+		}
+	}
+}
+
+func TestPurgeExpiredGlobalDisabledStillHonorsUsers(t *testing.T) {
+	testutil.SetupDB(t, &user.User{}, &reading.Reading{})
+	now := time.Now()
+
+	createUser(t, "short", 7)
+	createUser(t, "default", 0)
+	createReadingAt(t, "short-10d", "short", now.AddDate(0, 0, -10))
+	createReadingAt(t, "default-400d", "default", now.AddDate(0, 0, -400))
+
+	if _, err := NewReadingRepository().PurgeExpired(context.Background(), 0, now); err != nil {
+		t.Fatalf("PurgeExpired: %v", err)
+	}
+
+	left := remainingTasks(t)
+	if left["short-10d"] || !left["default-400d"] {
+		t.Fatalf("remaining = %v, want only default-400d", left)
+	}
+}
+
+// createQuestions 为用户创建一组问题各不相同的解读记录
+func createQuestions(t *testing.T, userID string, questions ...string) {
+	t.Helper()
+	for i, q := range questions {
+		r := &reading.Reading{TaskID: fmt.Sprintf("%s-%d", userID, i), UserID: userID, Question: q, Type: reading.TypeFree, Cards: reading.Cards{1}}
+		if err := database.DB.Create(r).Error; err != nil {
+			t.Fatalf("create reading: %v", err)
+		}
+	}
+}
+
+// searchQuestions 按关键词搜索并返回匹配的问题
+func searchQuestions(t *testing.T, userID, keyword string, page, pageSize int) ([]string, int64) {
+	t.Helper()
+	readings, total, err := NewReadingRepository().GetByUserID(context.Background(), userID, keyword, page, pageSize)
+	if err != nil {
+		t.Fatalf("GetByUserID(%q): %v", keyword, err)
+	}
+	questions := make([]string, 0, len(readings))
+	for _, r := range readings {
+		questions = append(questions, r.Question)
+	}
+	sort.Strings(questions)
+	return questions, total
+}
+
+func TestHistorySearchMatchesQuestion(t *testing.T) {
+	testutil.SetupDB(t, &reading.Reading{})
+	createQuestions(t, "user-1", "我的Career发展如何？", "最近的感情运势如何？", "明年的career规划")
+	createQuestions(t, "user-2", "我的career会好吗？")
+
+	questions, total := searchQuestions(t, "user-1", "CAREER", 1, 10)
+	if total != 2 || !slices.Equal(questions, []string{"我的Career发展如何？", "明年的career规划"}) {
+		t.Fatalf("questions = %v (total %d), want both career questions of user-1", questions, total)
+	}
+
+	// 搜索条件与分页组合：总数按搜索结果计算
+	if questions, total := searchQuestions(t, "user-1", "career", 2, 1); total != 2 || len(questions) != 1 {
+		t.Fatalf("page 2 = %v (total %d), want one of two results", questions, total)
+	}
+
+	if questions, total := searchQuestions(t, "user-1", "", 1, 10); total != 3 || len(questions) != 3 {
+		t.Fatalf("empty keyword = %v (total %d), want all readings", questions, total)
+	}
+}
+
+func TestHistorySearchNoMatch(t *testing.T) {
+	testutil.SetupDB(t, &reading.Reading{})
+	createQuestions(t, "user-1", "最近的感情运势如何？")
+
+	if questions, total := searchQuestions(t, "user-1", "事业", 1, 10); total != 0 || len(questions) != 0 {
+		t.Fatalf("questions = %v (total %d), want none", questions, total)
+	}
+}
+
+func TestHistorySearchTreatsSpecialCharactersLiterally(t *testing.T) {
+	testutil.SetupDB(t, &reading.Reading{})
+	createQuestions(t, "user-1", "成功率能到100%吗？", "a_b 代表什么", `路径 C:\tarot 是什么`, "普通的问题", "it's fine")
+
+	for keyword, want := range map[string][]string{
+		"%":            {"成功率能到100%吗？"},
+		"_":            {"a_b 代表什么"},
+		`\`:            {`路径 C:\tarot 是什么`},
+		"'":            {"it's fine"},
+		"%' OR '1'='1": {},
+	} {
+		questions, _ := searchQuestions(t, "user-1", keyword, 1, 10)
+		if !slices.Equal(questions, want) {
+			t.Fatalf("search %q = %v, want %v", keyword, questions, want)
+		}
+	}
+}
+
+func TestUpdateResultByTaskID(t *testing.T) {
+	testutil.SetupDB(t, &reading.Reading{})
+	repo := NewReadingRepository()
+	ctx := context.Background()
+	old := time.Now().Add(-time.Hour)
+	createReadingAt(t, "task-1", "user-1", old)
+	createReadingAt(t, "task-2", "user-1", old)
+
+	updated, err := repo.UpdateResultByTaskID(ctx, "task-1", reading.StatusCompleted, "愚者预示着新的开始")
+	if err != nil || !updated {
+		t.Fatalf("UpdateResultByTaskID = %v, %v, want the reading updated", updated, err)
+	}
+	r, err := repo.GetByTaskID(ctx, "user-1", "task-1")
+	if err != nil {
+		t.Fatalf("GetByTaskID: %v", err)
+	}
+	if r.Status != string(reading.StatusCompleted) || r.Interpretation != "愚者预示着新的开始" || !r.UpdatedAt.After(old) {
+		t.Fatalf("reading = status %s, interpretation %q, updated %s", r.Status, r.Interpretation, r.UpdatedAt)
+	}
+
+	// 其他记录不受影响
+	other, _ := repo.GetByTaskID(ctx, "user-1", "task-2")
+	if other.Status == string(reading.StatusCompleted) || other.Interpretation != "" {
+		t.Fatalf("unrelated reading = status %s, interpretation %q, want unchanged", other.Status, other.Interpretation)
+	}
+
+	updated, err = repo.UpdateResultByTaskID(ctx, "task-missing", reading.StatusFailed, "")
+	if err != nil || updated {
+		t.Fatalf("UpdateResultByTaskID for a missing task = %v, %v, want nothing updated", updated, err)
+	}
+}
+
+func TestGetActiveByUserIDReturnsOnlyActiveReadingsNewestFirst(t *testing.T) {
+	testutil.SetupDB(t, &reading.Reading{})
+	now := time.Now()
+	for i, tc := range []struct {
+		taskID string
+		userID string
+		status reading.Status
+	}{
+		{"pending-old", "user-1", reading.StatusPending},
+		{"completed", "user-1", reading.StatusCompleted},
+		{"processing", "user-1", reading.StatusProcessing},
+		{"failed", "user-1", reading.StatusFailed},
+		{"other-user", "user-2", reading.StatusPending},
+		{"pending-new", "user-1", reading.StatusPending},
+	} {
+		createReadingAt(t, tc.taskID, tc.userID, now.Add(time.Duration(i)*time.Minute))
+		if err := database.DB.Model(&reading.Reading{}).Where("task_id = ?", tc.taskID).UpdateColumn("status", tc.status).Error; err != nil {
+			t.Fatalf("set status of %s: %v", tc.taskID, err)
+		}
+	}
+
+	readings, err := NewReadingRepository().GetActiveByUserID(context.Background(), "user-1")
+	if err != nil {
+		t.Fatalf("GetActiveByUserID: %v", err)
+	}
+	var got []string
+	for _, r := range readings {
+		got = append(got, r.TaskID)
+	}
+	if want := []string{"pending-new", "processing", "pending-old"}; !slices.Equal(got, want) {
+		t.Fatalf("active readings = %v, want %v", got, want)
+	}
+
+	if readings, err := NewReadingRepository().GetActiveByUserID(context.Background(), "user-3"); err != nil || len(readings) != 0 {
+		t.Fatalf("GetActiveByUserID for a user without readings = %d, %v", len(readings), err)
+	}
+}
@@ -0,0 +1,58 @@
+package repositories
+
+import (
+	"context"
+	"gorm.io/gorm"
+	"tarot/app/models/spread"
+	"tarot/pkg/database"
+)
+
+// SpreadRepository 牌阵模板仓库
+type SpreadRepository struct {
+	db *gorm.DB
+}
+
+// NewSpreadRepository 创建仓库实例
+func NewSpreadRepository() *SpreadRepository {
+	return &SpreadRepository{
+		db: database.DB,
+	}
+}
+
+// List 获取全部牌阵
+func (r *SpreadRepository) List(ctx context.Context) ([]spread.Spread, error) {
+	var spreads []spread.Spread
+	err := r.db.WithContext(ctx).Order("id ASC").Find(&spreads).Error
+	return spreads, err
+}
+
+// GetByID 根据主键获取牌阵
+func (r *SpreadRepository) GetByID(ctx context.Context, id uint64) (*spread.Spread, error) {
+	var s spread.Spread
+	if err := r.db.WithContext(ctx).First(&s, id).Error; err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// Create 创建牌阵
+func (r *SpreadRepository) Create(ctx context.Context, s *spread.Spread) error {
+	return r.db.WithContext(ctx).Create(s).Error
+}
+
+// Update 更新牌阵
+func (r *SpreadRepository) Update(ctx context.Context, s *spread.Spread) error {
+	return r.db.WithContext(ctx).Save(s).Error
+}
+
+// Delete 删除牌阵
+func (r *SpreadRepository) Delete(ctx context.Context, id uint64) error {
+	result := r.db.WithContext(ctx).Delete(&spread.Spread{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
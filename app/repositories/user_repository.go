@@ -0,0 +1,142 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"tarot/app/models/user"
+	"tarot/pkg/database"
+	"tarot/pkg/helpers"
+)
+
+// ErrInsufficientCredits 用户积分不足
+var ErrInsufficientCredits = errors.New("insufficient credits")
+
+// UserRepository 用户仓库
+type UserRepository struct {
+	db *gorm.DB
+}
+
+// NewUserRepository 创建仓库实例
+func NewUserRepository() *UserRepository {
+	return &UserRepository{
+		db: database.DB,
+	}
+}
+
+// GetByID 根据用户 ID 获取用户
+func (r *UserRepository) GetByID(ctx context.Context, id string) (*user.User, error) {
+	var u user.User
+	if err := r.db.WithContext(ctx).Where("id = ?", id).First(&u).Error; err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+// GetByClerkID 根据 Clerk 用户 ID 获取用户
+func (r *UserRepository) GetByClerkID(ctx context.Context, clerkID string) (*user.User, error) {
+	var u user.User
+	if err := r.db.WithContext(ctx).Where("clerk_id = ?", clerkID).First(&u).Error; err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+// Upsert 按 ClerkID 创建或更新用户，返回数据库中的最新记录
+// 已存在时只更新非空的 Email、Nickname、AvatarURL，不会覆盖积分等业务字段；
+// 新建时未指定 ID 则自动生成，Email 为空字符串时按 NULL 保存
+func (r *UserRepository) Upsert(ctx context.Context, u *user.User) (*user.User, error) {
+	if u.ClerkID == "" {
+		return nil, errors.New("clerk id is required")
+	}
+	if u.ID == "" {
+		u.ID = helpers.UUID()
+	}
+
+	if u.Email != nil && *u.Email == "" {
+		u.Email = nil
+	}
+
+	var columns []string
+	if u.Email != nil {
+		columns = append(columns, "email")
+	}
+	if u.Nickname != "" {
+		columns = append(columns, "nickname")
+	}
+	if u.AvatarURL != "" {
+		columns = append(columns, "avatar_url")
+	}
+
+	onConflict := clause.OnConflict{
+		Columns:   []clause.Column{{Name: "clerk_id"}},
+		DoNothing: len(columns) == 0,
+	}
+	if len(columns) > 0 {
+		onConflict.DoUpdates = clause.AssignmentColumns(append(columns, "updated_at"))
+	}
+
+	if err := r.db.WithContext(ctx).Clauses(onConflict).Create(u).Error; err != nil {
+		return nil, err
+	}
+	return r.GetByClerkID(ctx, u.ClerkID)
+}
+
+// AddCredits 增加用户积分
+func (r *UserRepository) AddCredits(ctx context.Context, userID string, amount int) error {
+	if amount <= 0 {
+		return errors.New("credits amount must be positive")
+	}
+
+	result := r.db.WithContext(ctx).Model(&user.User{}).
+		Where("id = ?", userID).
+		Update("credits", gorm.Expr("credits + ?", amount))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// DeductCredits 扣减用户积分
+// 余额判断与扣减在同一条 UPDATE 中完成，并发扣减也不会出现负数余额
+func (r *UserRepository) DeductCredits(ctx context.Context, userID string, amount int) error {
+	if amount <= 0 {
+		return errors.New("credits amount must be positive")
+	}
+
+	result := r.db.WithContext(ctx).Model(&user.User{}).
+		Where("id = ? AND credits >= ?", userID, amount).
+		Update("credits", gorm.Expr("credits - ?", amount))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		return nil
+	}
+
+	// 未更新时区分用户不存在与余额不足
+	if _, err := r.GetByID(ctx, userID); err != nil {
+		return err
+	}
+	return ErrInsufficientCredits
+}
+
+// UpdateRetentionDays 更新用户的解读记录保留天数，0 表示恢复全局策略
+func (r *UserRepository) UpdateRetentionDays(ctx context.Context, userID string, days int) error {
+	result := r.db.WithContext(ctx).Model(&user.User{}).
+		Where("id = ?", userID).
+		Update("retention_days", days)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"tarot/app/models/user"
+	"tarot/pkg/database"
+	"tarot/pkg/testutil"
+)
+
+func TestUpsertByClerkID(t *testing.T) {
+	testutil.SetupDB(t, &user.User{})
+	repo := NewUserRepository()
+	ctx := context.Background()
+
+	created, err := repo.Upsert(ctx, &user.User{ClerkID: "clerk_1", Nickname: "星星"})
+	if err != nil {
+		t.Fatalf("Upsert create: %v", err)
+	}
+	if created.ID == "" || created.Email != nil {
+		t.Fatalf("created = %+v, want generated id and NULL email", created)
+	}
+	database.DB.Model(&user.User{}).Where("id = ?", created.ID).Update("credits", 5)
+
+	// 再次登录只更新非空字段，不覆盖积分，也不改变 ID
+	email := "star@example.com"
+	updated, err := repo.Upsert(ctx, &user.User{ClerkID: "clerk_1", Email: &email})
+	if err != nil {
+		t.Fatalf("Upsert update: %v", err)
+	}
+	if updated.ID != created.ID || updated.Nickname != "星星" || updated.Credits != 5 || updated.Email == nil || *updated.Email != email {
+		t.Fatalf("updated = %+v, want same user with email set and credits kept", updated)
+	}
+
+	var n int64
+	database.DB.Model(&user.User{}).Count(&n)
+	if n != 1 {
+		t.Fatalf("users = %d, want 1", n)
+	}
+}
+
+func TestUpsertUsersWithoutEmail(t *testing.T) {
+	testutil.SetupDB(t, &user.User{})
+	repo := NewUserRepository()
+	empty := ""
+
+	// 多个没有邮箱的用户不会冲突于邮箱唯一索引
+	for _, u := range []*user.User{{ClerkID: "clerk_1"}, {ClerkID: "clerk_2", Email: &empty}, {ClerkID: "clerk_3"}} {
+		saved, err := repo.Upsert(context.Background(), u)
+		if err != nil {
+			t.Fatalf("Upsert %s: %v", u.ClerkID, err)
+		}
+		if saved.Email != nil {
+			t.Fatalf("email of %s = %q, want NULL", u.ClerkID, *saved.Email)
+		}
+	}
+}
+
+func TestDeductCreditsConcurrentlyNeverNegative(t *testing.T) {
+	testutil.SetupDB(t, &user.User{})
+	repo := NewUserRepository()
+	ctx := context.Background()
+	createUser(t, "user-1", 0)
+	if err := repo.AddCredits(ctx, "user-1", 10); err != nil {
+		t.Fatalf("AddCredits: %v", err)
+	}
+
+	var (
+		wg           sync.WaitGroup
+		mu           sync.Mutex
+		ok, rejected int
+	)
+	for i := 0; i < 30; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := repo.DeductCredits(ctx, "user-1", 1)
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				ok++
+			case errors.Is(err, ErrInsufficientCredits):
+				rejected++
+			default:
+				t.Errorf("DeductCredits: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	u, err := repo.GetByID(ctx, "user-1")
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	if ok != 10 || rejected != 20 || u.Credits != 0 {
+		t.Fatalf("ok=%d rejected=%d credits=%d, want 10/20/0", ok, rejected, u.Credits)
+	}
+}
+
+func TestDeductCreditsUnknownUser(t *testing.T) {
+	testutil.SetupDB(t, &user.User{})
+	if err := NewUserRepository().DeductCredits(context.Background(), "missing", 1); errors.Is(err, ErrInsufficientCredits) || err == nil {
+		t.Fatalf("err = %v, want record not found", err)
+	}
+}
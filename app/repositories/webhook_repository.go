@@ -0,0 +1,47 @@
+package repositories
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+
+	"tarot/app/models/webhook"
+	"tarot/pkg/database"
+	pkgwebhook "tarot/pkg/webhook"
+)
+
+// WebhookRepository 回调投递记录仓库，实现 webhook.Recorder
+type WebhookRepository struct {
+	db *gorm.DB
+}
+
+// NewWebhookRepository 创建仓库实例
+func NewWebhookRepository() *WebhookRepository {
+	return &WebhookRepository{
+		db: database.DB,
+	}
+}
+
+// RecordAttempt 保存一次投递记录
+func (r *WebhookRepository) RecordAttempt(ctx context.Context, attempt *pkgwebhook.Attempt) error {
+	return r.db.WithContext(ctx).Create(&webhook.Delivery{
+		TaskID:     attempt.TaskID,
+		URL:        attempt.URL,
+		Event:      attempt.Event,
+		Attempt:    attempt.Attempt,
+		StatusCode: attempt.StatusCode,
+		Error:      attempt.Error,
+		Success:    attempt.Success,
+		DurationMs: attempt.Duration.Milliseconds(),
+	}).Error
+}
+
+// ListByTaskID 获取任务的全部投递记录
+func (r *WebhookRepository) ListByTaskID(ctx context.Context, taskID string) ([]webhook.Delivery, error) {
+	var deliveries []webhook.Delivery
+	err := r.db.WithContext(ctx).
+		Where("task_id = ?", taskID).
+		Order("id ASC").
+		Find(&deliveries).Error
+	return deliveries, err
+}
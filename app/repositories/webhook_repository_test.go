@@ -0,0 +1,44 @@
+package repositories
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"tarot/app/models/webhook"
+	"tarot/pkg/testutil"
+	pkgwebhook "tarot/pkg/webhook"
+)
+
+func TestWebhookRecordAttempt(t *testing.T) {
+	testutil.SetupDB(t, &webhook.Delivery{})
+	repo := NewWebhookRepository()
+	ctx := context.Background()
+
+	attempts := []pkgwebhook.Attempt{
+		{TaskID: "task-1", URL: "https://example.com/hook", Event: pkgwebhook.EventCompleted, Attempt: 1, StatusCode: http.StatusBadGateway, Error: "unexpected status 502", Duration: 120 * time.Millisecond},
+		{TaskID: "task-1", URL: "https://example.com/hook", Event: pkgwebhook.EventCompleted, Attempt: 2, StatusCode: http.StatusOK, Success: true, Duration: 80 * time.Millisecond},
+		{TaskID: "task-2", URL: "https://example.com/hook", Event: pkgwebhook.EventFailed, Attempt: 1, StatusCode: http.StatusOK, Success: true},
+	}
+	for i := range attempts {
+		if err := repo.RecordAttempt(ctx, &attempts[i]); err != nil {
+			t.Fatalf("RecordAttempt: %v", err)
+		}
+	}
+
+	deliveries, err := repo.ListByTaskID(ctx, "task-1")
+	if err != nil {
+		t.Fatalf("ListByTaskID: %v", err)
+	}
+	if len(deliveries) != 2 {
+		t.Fatalf("deliveries = %d, want 2", len(deliveries))
+	}
+	first, second := deliveries[0], deliveries[1]
+	if first.Attempt != 1 || first.Success || first.StatusCode != http.StatusBadGateway || first.DurationMs != 120 {
+		t.Fatalf("first delivery = %+v", first)
+	}
+	if second.Attempt != 2 || !second.Success || second.Event != pkgwebhook.EventCompleted {
+		t.Fatalf("second delivery = %+v", second)
+	}
+}
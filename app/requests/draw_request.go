@@ -0,0 +1,42 @@
+package requests
+
+import (
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+
+	"tarot/app/models/spread"
+	"tarot/pkg/tarot"
+)
+
+// DrawRequest 服务端抽牌，指定牌阵时按牌阵的张数抽取，否则按 count 抽取
+type DrawRequest struct {
+	UserID   string `json:"user_id"`
+	SpreadID uint64 `json:"spread_id"`
+	Count    int    `json:"count"`
+}
+
+// ValidateDraw 验证抽牌请求，通过后 Count 为实际要抽取的张数
+func ValidateDraw(c *gin.Context) (*DrawRequest, error) {
+	var req DrawRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		return nil, fmt.Errorf("解析 JSON 失败: %w", err)
+	}
+
+	if req.UserID == "" {
+		return nil, fmt.Errorf("用户 ID 不能为空")
+	}
+
+	if req.SpreadID != 0 {
+		s, ok := spread.Find(req.SpreadID)
+		if !ok {
+			return nil, fmt.Errorf("牌阵不存在: %d", req.SpreadID)
+		}
+		req.Count = s.CardCount
+	}
+	if req.Count < 1 || req.Count > tarot.TotalCards {
+		return nil, fmt.Errorf("抽牌张数必须在 1 到 %d 之间", tarot.TotalCards)
+	}
+
+	return &req, nil
+}
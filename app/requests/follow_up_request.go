@@ -0,0 +1,47 @@
+package requests
+
+import (
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+
+	"tarot/app/models/reading"
+	"tarot/pkg/dify"
+	"tarot/pkg/webhook"
+)
+
+// FollowUpRequest 对已完成解读的追问，沿用原解读的卡牌与牌阵
+type FollowUpRequest struct {
+	Question string `json:"question"`
+	// ResponseMode Dify 响应模式：blocking（默认）或 streaming
+	ResponseMode string `json:"response_mode"`
+	// CallbackURL 解读结束后回调的地址（可选）
+	CallbackURL string `json:"callback_url"`
+}
+
+// ValidateFollowUp 验证追问请求
+func ValidateFollowUp(c *gin.Context) (*FollowUpRequest, error) {
+	var req FollowUpRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		return nil, fmt.Errorf("解析 JSON 失败: %w", err)
+	}
+
+	if err := reading.ValidateQuestion(req.Question); err != nil {
+		return nil, err
+	}
+
+	if req.ResponseMode == "" {
+		req.ResponseMode = dify.ResponseModeBlocking
+	}
+	if !dify.IsValidResponseMode(req.ResponseMode) {
+		return nil, fmt.Errorf("响应模式必须是 blocking 或 streaming")
+	}
+
+	if req.CallbackURL != "" {
+		if err := webhook.ValidateURL(req.CallbackURL); err != nil {
+			return nil, fmt.Errorf("回调地址必须是 http 或 https 的完整地址")
+		}
+	}
+
+	return &req, nil
+}
@@ -5,6 +5,9 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/thedevsaddam/govalidator"
 	"tarot/app/models/reading"
+	"tarot/app/models/spread"
+	"tarot/pkg/dify"
+	"tarot/pkg/webhook"
 )
 
 type TarotReadingRequest struct {
@@ -12,6 +15,16 @@ type TarotReadingRequest struct {
 	Question string `json:"question" valid:"required"`
 	Cards    []int  `json:"cards" valid:"required"`
 	Type     reading.ReadingType `json:"type" valid:"required"`
+	SpreadID uint64 `json:"spread_id"`
+	// ResponseMode Dify 响应模式：blocking（默认，轮询取结果）或 streaming（SSE 推送）
+	ResponseMode string `json:"response_mode"`
+	// CallbackURL 解读结束后回调的地址（可选），回调内容带 HMAC 签名
+	CallbackURL string `json:"callback_url"`
+	// DrawID 服务端抽牌返回的 draw_id，开启 tarot.require_draw 时必填
+	DrawID string `json:"draw_id"`
+
+	// Spread 请求引用的牌阵，校验通过后填充
+	Spread *spread.Spread `json:"-"`
 }
 
 func ValidateTarotReading(c *gin.Context) (*TarotReadingRequest, error) {
@@ -24,10 +37,11 @@ func ValidateTarotReading(c *gin.Context) (*TarotReadingRequest, error) {
 	
 	// 2. 验证规则
 	rules := govalidator.MapData{
-		"user_id":  []string{"required"},
-		"question": []string{"required", "min:1"},
-		"cards":    []string{"required"},
-		"type":     []string{"required", "in:free,premium"},
+		"user_id":       []string{"required"},
+		"question":      []string{"required"},
+		"cards":         []string{"required"},
+		"type":          []string{"required", "in:free,premium"},
+		"response_mode": []string{"in:blocking,streaming"},
 	}
 	
 	// 3. 验证消息
@@ -37,7 +51,6 @@ func ValidateTarotReading(c *gin.Context) (*TarotReadingRequest, error) {
 		},
 		"question": []string{
 			"required:问题不能为空",
-			"min:问题长度不能小于 1 个字符",
 		},
 		"cards": []string{
 			"required:卡牌不能为空",
@@ -46,6 +59,9 @@ func ValidateTarotReading(c *gin.Context) (*TarotReadingRequest, error) {
 			"required:解读类型不能为空",
 			"in:解读类型必须是 free 或 premium",
 		},
+		"response_mode": []string{
+			"in:响应模式必须是 blocking 或 streaming",
+		},
 	}
 	
 	// 4. 开始验证
@@ -61,16 +77,36 @@ func ValidateTarotReading(c *gin.Context) (*TarotReadingRequest, error) {
 		return nil, fmt.Errorf("验证失败: %v", errs)
 	}
 	
+	// govalidator 的 min/max 按字节计算，中文问题需按字符数校验
+	if err := reading.ValidateQuestion(req.Question); err != nil {
+		return nil, err
+	}
+	
+	if req.CallbackURL != "" {
+		if err := webhook.ValidateURL(req.CallbackURL); err != nil {
+			return nil, fmt.Errorf("回调地址必须是 http 或 https 的完整地址")
+		}
+	}
+	
+	if req.ResponseMode == "" {
+		req.ResponseMode = dify.ResponseModeBlocking
+	}
+	
 	// 5. 额外的卡牌验证
-	if len(req.Cards) == 0 {
-		return nil, fmt.Errorf("至少需要选择一张卡牌")
+	if err := reading.ValidateCardIDs(req.Cards); err != nil {
+		return nil, err
 	}
 	
-	// 验证卡牌号码是否有效
-	for _, cardID := range req.Cards {
-		if cardID < 1 || cardID > 78 {
-			return nil, fmt.Errorf("无效的卡牌编号: %d", cardID)
+	// 引用牌阵时卡牌数量必须与牌阵定义一致
+	if req.SpreadID != 0 {
+		s, ok := spread.Find(req.SpreadID)
+		if !ok {
+			return nil, fmt.Errorf("牌阵不存在: %d", req.SpreadID)
+		}
+		if len(req.Cards) != s.CardCount {
+			return nil, fmt.Errorf("牌阵 %s 需要 %d 张卡牌", s.Name, s.CardCount)
 		}
+		req.Spread = s
 	}
 	
 	return &req, nil
@@ -0,0 +1,49 @@
+package requests
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MaxBatchTaskIDs 单次批量查询的任务数上限
+const MaxBatchTaskIDs = 50
+
+// taskIDPattern 任务 ID 格式，与 generateTaskID 生成的 task_<毫秒>_<随机数> 兼容
+var taskIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+type TaskStatusBatchRequest struct {
+	TaskIDs []string `json:"task_ids"`
+}
+
+// ValidateTaskStatusBatch 验证批量查询任务状态请求，重复的任务 ID 会被去除
+func ValidateTaskStatusBatch(c *gin.Context) (*TaskStatusBatchRequest, error) {
+	var req TaskStatusBatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		return nil, fmt.Errorf("解析 JSON 失败: %w", err)
+	}
+
+	if len(req.TaskIDs) == 0 {
+		return nil, fmt.Errorf("任务 ID 不能为空")
+	}
+	if len(req.TaskIDs) > MaxBatchTaskIDs {
+		return nil, fmt.Errorf("单次最多查询 %d 个任务", MaxBatchTaskIDs)
+	}
+
+	seen := make(map[string]bool, len(req.TaskIDs))
+	ids := make([]string, 0, len(req.TaskIDs))
+	for _, id := range req.TaskIDs {
+		if !taskIDPattern.MatchString(id) {
+			return nil, fmt.Errorf("无效的任务 ID: %q", id)
+		}
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		ids = append(ids, id)
+	}
+	req.TaskIDs = ids
+
+	return &req, nil
+}
@@ -0,0 +1,28 @@
+package bootstrap
+
+import (
+	"context"
+	"sync"
+)
+
+var (
+	// backgroundCtx 后台定时任务共用的上下文，关闭服务时取消
+	backgroundCtx, stopBackground = context.WithCancel(context.Background())
+	// backgroundWG 等待后台定时任务退出
+	backgroundWG sync.WaitGroup
+)
+
+// runBackground 在后台启动定时任务，task 需在 ctx 取消后返回
+func runBackground(task func(ctx context.Context)) {
+	backgroundWG.Add(1)
+	go func() {
+		defer backgroundWG.Done()
+		task(backgroundCtx)
+	}()
+}
+
+// ShutdownBackground 通知后台定时任务退出，并等待正在执行的一轮完成
+func ShutdownBackground() {
+	stopBackground()
+	backgroundWG.Wait()
+}
@@ -2,6 +2,8 @@ package bootstrap
 
 import (
 	"fmt"
+	"strings"
+	"tarot/app/models/user"
 	"tarot/pkg/config"
 	"tarot/pkg/database"
 	"tarot/pkg/database/migrations"
@@ -37,10 +39,23 @@ func SetupDB() {
 	setupDBPool()
 
 	// 自动迁移数据库结构
+	if !config.GetBool("database.auto_migrate") {
+		logger.WarnString("数据库", "自动迁移", "database.auto_migrate 未开启，跳过数据表结构迁移，请确认表结构已是最新")
+		return
+	}
 	if err := database.AutoMigrate(migrations.RegisterTables()); err != nil {
 		logger.ErrorString("数据库", "自动迁移", "数据表结构迁移失败："+err.Error())
 		return
 	}
+	if err := database.CreateIndexes(migrations.RegisterIndexes()); err != nil {
+		logger.ErrorString("数据库", "自动迁移", "创建索引失败："+err.Error())
+		return
+	}
+	// 早期版本以空字符串保存缺失的邮箱，多个用户会冲突于唯一索引，统一改为 NULL
+	if err := database.DB.Model(&user.User{}).Where("email = ?", "").Update("email", nil).Error; err != nil {
+		logger.ErrorString("数据库", "自动迁移", "清理空邮箱失败："+err.Error())
+		return
+	}
 	logger.InfoString("数据库", "自动迁移", "数据表结构迁移成功")
 }
 
@@ -55,13 +70,36 @@ func setupPostgreSQL() gorm.Dialector {
 	// 打印连接信息（不包含密码）
 	logger.InfoString("数据库", "PostgreSQL", fmt.Sprintf("正在连接到 %s:%s/%s", host, port, dbname))
 
-	dsn := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable TimeZone=Asia/Shanghai",
-		host, port, username, password, dbname)
+	dsn := postgresDSN([][2]string{
+		{"host", host},
+		{"port", port},
+		{"user", username},
+		{"password", password},
+		{"dbname", dbname},
+		{"sslmode", config.GetString("database.postgresql.sslmode", "disable")},
+		{"sslrootcert", config.GetString("database.postgresql.sslrootcert")},
+		{"application_name", config.GetString("database.postgresql.application_name")},
+		{"TimeZone", "Asia/Shanghai"},
+	})
 	return postgres.New(postgres.Config{
 		DSN: dsn,
 	})
 }
 
+// postgresDSN 按 libpq 的 key=value 格式拼接连接串，忽略空值
+// 值用单引号包裹并转义，密码等包含空格或引号时也能正确解析
+func postgresDSN(params [][2]string) string {
+	var parts []string
+	for _, param := range params {
+		if param[1] == "" {
+			continue
+		}
+		value := strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(param[1])
+		parts = append(parts, fmt.Sprintf("%s='%s'", param[0], value))
+	}
+	return strings.Join(parts, " ")
+}
+
 // setupSQLite 配置 SQLite 连接
 func setupSQLite() gorm.Dialector {
 	database := config.Get("database.sqlite.database")
@@ -0,0 +1,66 @@
+package bootstrap
+
+import (
+	"strings"
+	"testing"
+
+	"gorm.io/driver/postgres"
+
+	"tarot/pkg/testutil"
+)
+
+// postgresConfig 设置 PostgreSQL 连接配置并返回生成的 DSN
+func postgresConfig(t *testing.T, overrides map[string]interface{}) string {
+	t.Helper()
+	testutil.ObserveLogs(t)
+	for path, value := range map[string]interface{}{
+		"database.postgresql.host":             "db.example.com",
+		"database.postgresql.port":             "5432",
+		"database.postgresql.database":         "tarot",
+		"database.postgresql.username":         "tarot",
+		"database.postgresql.password":         "secret",
+		"database.postgresql.sslmode":          "disable",
+		"database.postgresql.sslrootcert":      "",
+		"database.postgresql.application_name": "",
+	} {
+		if v, ok := overrides[path]; ok {
+			value = v
+		}
+		testutil.SetConfig(t, path, value)
+	}
+	return setupPostgreSQL().(*postgres.Dialector).Config.DSN
+}
+
+func TestPostgresDSNDefaultsToDisable(t *testing.T) {
+	dsn := postgresConfig(t, nil)
+
+	want := "host='db.example.com' port='5432' user='tarot' password='secret' dbname='tarot' sslmode='disable' TimeZone='Asia/Shanghai'"
+	if dsn != want {
+		t.Fatalf("dsn = %q, want %q", dsn, want)
+	}
+}
+
+func TestPostgresDSNReflectsSSLOptions(t *testing.T) {
+	dsn := postgresConfig(t, map[string]interface{}{
+		"database.postgresql.sslmode":          "verify-full",
+		"database.postgresql.sslrootcert":      "/etc/ssl/rds-ca.pem",
+		"database.postgresql.application_name": "tarot-api",
+	})
+
+	for _, param := range []string{"sslmode='verify-full'", "sslrootcert='/etc/ssl/rds-ca.pem'", "application_name='tarot-api'"} {
+		if !strings.Contains(dsn, param) {
+			t.Errorf("dsn = %q, want it to contain %s", dsn, param)
+		}
+	}
+	if strings.Contains(dsn, "disable") {
+		t.Errorf("dsn = %q still disables TLS", dsn)
+	}
+}
+
+func TestPostgresDSNQuotesValues(t *testing.T) {
+	dsn := postgresDSN([][2]string{{"password", `p@ss 'wo\rd`}, {"sslrootcert", ""}})
+
+	if want := `password='p@ss \'wo\\rd'`; dsn != want {
+		t.Fatalf("dsn = %q, want %q", dsn, want)
+	}
+}
@@ -3,7 +3,6 @@ package bootstrap
 import (
 	"fmt"
 	"strings"
-	"time"
 
 	"tarot/pkg/dify"
 	"tarot/pkg/config"
@@ -41,18 +40,15 @@ func SetupDify() *dify.DifyService {
 	}
 
 	// 创建服务实例
-	service := dify.NewDifyService(&dify.Config{
-		URLs:       strings.Split(urls, ","),
-			APIKeys:    strings.Split(apiKeys, ","),
-			Timeout:    time.Duration(timeout) * time.Second,
-			MaxRetries: maxRetries,
-	})
+	service := dify.NewDifyService(dify.LoadConfig())
 
 	if service == nil {
 		logger.ErrorString("Dify", "Setup", "Dify 服务初始化失败")
 		return nil
 	}
 
+	dify.Service = service
+
 	logger.InfoString("Dify", "Setup", fmt.Sprintf(
 		"Dify 服务初始化成功 [URLs: %d, APIKeys: %d]",
 		len(strings.Split(urls, ",")),
@@ -0,0 +1,65 @@
+package bootstrap
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"tarot/app/models/user"
+	_ "tarot/config"
+	"tarot/pkg/config"
+	"tarot/pkg/database"
+	"tarot/pkg/testutil"
+)
+
+// setupSQLiteDB 以临时 SQLite 文件执行 SetupDB，结束后恢复原连接
+func setupSQLiteDB(t *testing.T, autoMigrate bool) {
+	t.Helper()
+	testutil.SetupDB(t)
+	testutil.SetConfig(t, "database.connection", "sqlite")
+	testutil.SetConfig(t, "database.sqlite.database", filepath.Join(t.TempDir(), "tarot.db"))
+	testutil.SetConfig(t, "database.auto_migrate", autoMigrate)
+
+	SetupDB()
+	sqlDB := database.SQLDB
+	t.Cleanup(func() { sqlDB.Close() })
+}
+
+func TestSetupDBSkipsMigrationWhenDisabled(t *testing.T) {
+	logs := testutil.ObserveLogs(t)
+	setupSQLiteDB(t, false)
+
+	if database.DB.Migrator().HasTable(&user.User{}) {
+		t.Fatal("users table created with database.auto_migrate disabled")
+	}
+	skipped := false
+	for _, entry := range logs.FilterMessage("数据库").All() {
+		if msg, _ := entry.ContextMap()["自动迁移"].(string); strings.Contains(msg, "跳过") {
+			skipped = true
+		}
+	}
+	if !skipped {
+		t.Fatal("no log entry explains that the migration was skipped")
+	}
+}
+
+func TestSetupDBMigratesWhenEnabled(t *testing.T) {
+	testutil.ObserveLogs(t)
+	setupSQLiteDB(t, true)
+
+	if !database.DB.Migrator().HasTable(&user.User{}) {
+		t.Fatal("users table missing with database.auto_migrate enabled")
+	}
+}
+
+func TestAutoMigrateDefaultsOffInProduction(t *testing.T) {
+	for env, want := range map[string]bool{"production": false, "local": true, "test": true} {
+		testutil.SetConfig(t, "APP_ENV", env)
+		testutil.SetConfig(t, "DB_AUTO_MIGRATE", nil)
+
+		db := config.ConfigFuncs["database"]()
+		if got := db["auto_migrate"]; got != want {
+			t.Errorf("APP_ENV=%s: auto_migrate = %v, want %v", env, got, want)
+		}
+	}
+}
@@ -0,0 +1,28 @@
+package bootstrap
+
+import (
+	"fmt"
+	"strings"
+
+	"tarot/pkg/config"
+	"tarot/pkg/logger"
+	"tarot/pkg/moderation"
+)
+
+// SetupModeration 初始化问题内容审核，未配置黑名单与正则时不审核
+func SetupModeration() {
+	blocklist := config.GetString("moderation.blocklist")
+	pattern := config.GetString("moderation.pattern")
+	if strings.TrimSpace(blocklist) == "" && pattern == "" {
+		return
+	}
+
+	moderator, err := moderation.NewKeywordModerator(strings.Split(blocklist, ","), pattern)
+	if err != nil {
+		logger.ErrorString("Moderation", "Setup", err.Error())
+		return
+	}
+	moderation.Default = moderator
+
+	logger.InfoString("Moderation", "Setup", fmt.Sprintf("内容审核启动成功 [关键词: %d, 正则: %t]", len(moderator.Words()), pattern != ""))
+}
@@ -0,0 +1,117 @@
+package bootstrap
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"tarot/app/repositories"
+	btsConfig "tarot/config"
+	"tarot/pkg/config"
+	"tarot/pkg/logger"
+	"tarot/pkg/payment"
+	"tarot/pkg/payment/factory"
+	"tarot/pkg/payment/types"
+)
+
+// SetupPayment 初始化支付服务
+// 仅初始化配置完整的支付渠道，并启动过期订单清理任务
+func SetupPayment() {
+	repo := repositories.NewPaymentRepository()
+
+	if config.GetString("payment.wechat.mch_id") != "" {
+		wcfg, err := loadWechatConfig()
+		if err == nil {
+			err = registerPaymentService(types.ProviderWechat, repo, wcfg)
+		}
+		if err != nil {
+			logger.ErrorString("Payment", "Wechat", fmt.Sprintf("微信支付初始化失败: %v", err))
+		}
+	}
+
+	if config.GetString("payment.alipay.app_id") != "" {
+		acfg, err := loadAlipayConfig()
+		if err == nil {
+			err = registerPaymentService(types.ProviderAlipay, repo, acfg)
+		}
+		if err != nil {
+			logger.ErrorString("Payment", "Alipay", fmt.Sprintf("支付宝初始化失败: %v", err))
+		}
+	}
+
+	if config.GetString("payment.stripe.secret_key") != "" {
+		if err := registerPaymentService(types.ProviderStripe, repo, loadStripeConfig()); err != nil {
+			logger.ErrorString("Payment", "Stripe", fmt.Sprintf("Stripe 初始化失败: %v", err))
+		}
+	}
+
+	// 启动待支付订单对账与过期清理任务
+	sweeper := payment.NewSweeper(
+		repo,
+		time.Duration(config.GetInt("payment.sweep_interval", 60))*time.Second,
+		config.GetInt("payment.sweep_batch_size", 100),
+		time.Duration(config.GetInt("payment.reconcile_after", 300))*time.Second,
+	)
+	runBackground(sweeper.Start)
+
+	logger.InfoString("Payment", "Setup", "支付服务初始化完成")
+}
+
+// registerPaymentService 创建并注册支付服务
+func registerPaymentService(provider types.Provider, repo types.Repository, cfg interface{}) error {
+	service, err := factory.NewPaymentService(provider, repo, cfg)
+	if err != nil {
+		return err
+	}
+	payment.Register(provider, service)
+	return nil
+}
+
+// loadWechatConfig 读取微信支付配置
+func loadWechatConfig() (btsConfig.WechatConfig, error) {
+	privateKey, err := os.ReadFile(config.GetString("payment.wechat.private_key_path"))
+	if err != nil {
+		return btsConfig.WechatConfig{}, fmt.Errorf("read private key error: %w", err)
+	}
+
+	return btsConfig.WechatConfig{
+		AppID:      config.GetString("payment.wechat.app_id"),
+		MchID:      config.GetString("payment.wechat.mch_id"),
+		SerialNo:   config.GetString("payment.wechat.serial_no"),
+		PrivateKey: string(privateKey),
+		APIv3Key:   config.GetString("payment.wechat.api_v3_key"),
+		NotifyURL:  config.GetString("payment.wechat.notify_url"),
+		ReturnURL:  config.GetString("payment.wechat.return_url"),
+	}, nil
+}
+
+// loadStripeConfig 读取 Stripe 配置
+func loadStripeConfig() btsConfig.StripeConfig {
+	return btsConfig.StripeConfig{
+		SecretKey:     config.GetString("payment.stripe.secret_key"),
+		WebhookSecret: config.GetString("payment.stripe.webhook_secret"),
+		Currency:      config.GetString("payment.stripe.currency"),
+		APIBase:       config.GetString("payment.stripe.api_base"),
+	}
+}
+
+// loadAlipayConfig 读取支付宝配置
+func loadAlipayConfig() (btsConfig.AlipayConfig, error) {
+	privateKey, err := os.ReadFile(config.GetString("payment.alipay.private_key_path"))
+	if err != nil {
+		return btsConfig.AlipayConfig{}, fmt.Errorf("read private key error: %w", err)
+	}
+	publicKey, err := os.ReadFile(config.GetString("payment.alipay.public_key_path"))
+	if err != nil {
+		return btsConfig.AlipayConfig{}, fmt.Errorf("read public key error: %w", err)
+	}
+
+	return btsConfig.AlipayConfig{
+		AppID:        config.GetString("payment.alipay.app_id"),
+		PrivateKey:   string(privateKey),
+		PublicKey:    string(publicKey),
+		NotifyURL:    config.GetString("payment.alipay.notify_url"),
+		ReturnURL:    config.GetString("payment.alipay.return_url"),
+		IsProduction: config.GetBool("payment.alipay.is_production"),
+	}, nil
+}
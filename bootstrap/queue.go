@@ -1,14 +1,19 @@
 package bootstrap
 
 import (
+	"context"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"tarot/app/repositories"
 	"tarot/pkg/config"
 	"tarot/pkg/dify"
 	"tarot/pkg/queue"
 	"tarot/pkg/logger"
 	"tarot/pkg/redis"
+	"tarot/pkg/webhook"
 )
 
 func SetupQueue() {
@@ -19,30 +24,110 @@ func SetupQueue() {
 
 	queueService := queue.NewQueueService()
 	
-	// 创建 Dify 配置
-	difyConfig := &dify.Config{
-		URLs:       strings.Split(config.GetString("dify.urls"), ","),
-		APIKeys:    strings.Split(config.GetString("dify.api_keys"), ","),
-		Timeout:    time.Duration(config.GetInt("dify.timeout")) * time.Second,
-		MaxRetries: config.GetInt("dify.max_retries"),
-	}
-	
-	difyService := dify.NewDifyService(difyConfig)
+	// 使用共享的 Dify 服务，需先调用 SetupDify
+	difyConfig := dify.LoadConfig()
+	difyService := dify.Service
 	if difyService == nil {
 		logger.ErrorString("Queue", "Setup", "Dify service initialization failed")
 		return
 	}
 	
-	worker := queue.NewWorker(queueService, difyService, queue.WorkerConfig{
-		WorkerCount:     config.GetInt("queue.worker_count", 10),
-		MaxRetries:      config.GetInt("queue.retry_times", 3),
-		RetryInterval:   time.Duration(config.GetInt("queue.retry_delay", 1)) * time.Second,
-		ShutdownTimeout: 30 * time.Second,
-		BatchSize:       10,
-		MaxQueueSize:    10000,
-	})
+	notifier := setupWebhook()
+	newWorkerConfig := func(workerCount int) queue.WorkerConfig {
+		return queue.WorkerConfig{
+			WorkerCount:     workerCount,
+			MaxRetries:      config.GetInt("queue.retry_times", 3),
+			RetryInterval:   time.Duration(config.GetInt("queue.retry_delay", 1)) * time.Second,
+			TaskTimeout:     difyConfig.Timeout,
+			StreamTimeout:   difyConfig.StreamTimeout,
+			ShutdownTimeout: 30 * time.Second,
+			BatchSize:       config.GetInt("queue.batch_size", 10),
+			MaxQueueSize:    10000,
+			MetricsInterval: time.Duration(config.GetInt("queue.metrics_interval", 60)) * time.Second,
+			Notifier:        notifier,
+			Results:         repositories.NewReadingRepository(),
+			Fallback: queue.FallbackConfig{
+				Enabled: config.GetBool("queue.fallback_enabled"),
+				Types:   strings.Split(config.GetString("queue.fallback_types"), ","),
+				Message: config.GetString("queue.fallback_message"),
+			},
+		}
+	}
 	
+	worker := queue.NewWorker(queueService, difyService, newWorkerConfig(config.GetInt("queue.worker_count", 10)))
+	queue.Workers = worker
+	queue.WorkerGroups[queue.DefaultQueue] = worker
 	go worker.Start()
 	
+	// 其他命名队列使用独立的工作器组，互不占用
+	for name, count := range parseExtraQueues(config.GetString("queue.extra_queues")) {
+		group := queue.NewWorker(queue.NewNamedQueueService(name), difyService, newWorkerConfig(count))
+		queue.WorkerGroups[name] = group
+		go group.Start()
+	}
+	
+	setupQueueJanitor(queueService)
+	
 	logger.InfoString("Queue", "Setup", "队列服务启动成功")
-} 
\ No newline at end of file
+}
+
+// ShutdownQueue 关闭所有工作器组：停止获取新任务，等待进行中的任务完成，超时未完成的任务放回队列
+func ShutdownQueue() {
+	var wg sync.WaitGroup
+	for _, worker := range queue.WorkerGroups {
+		wg.Add(1)
+		go func(w *queue.Worker) {
+			defer wg.Done()
+			w.Stop()
+		}(worker)
+	}
+	wg.Wait()
+}
+
+// parseExtraQueues 解析 name:workers 形式、逗号分隔的命名队列配置
+// 配置已在启动时校验，这里忽略无法解析的项
+func parseExtraQueues(value string) map[string]int {
+	queues := make(map[string]int)
+	for _, item := range strings.Split(value, ",") {
+		name, count, ok := strings.Cut(strings.TrimSpace(item), ":")
+		if !ok || name == queue.DefaultQueue || !queue.ValidQueueName(name) {
+			continue
+		}
+		n, err := strconv.Atoi(count)
+		if err != nil || n <= 0 {
+			continue
+		}
+		queues[name] = n
+	}
+	return queues
+}
+
+// setupQueueJanitor 启动队列残留键的定期清理，间隔为 0 时不清理
+func setupQueueJanitor(queueService *queue.QueueService) {
+	interval := time.Duration(config.GetInt("queue.cleanup_interval", 600)) * time.Second
+	if interval <= 0 {
+		logger.InfoString("Queue", "Janitor", "残留键清理已禁用")
+		return
+	}
+
+	janitor := queue.NewJanitor(queueService, interval,
+		config.GetInt("queue.cleanup_scan_count", 100),
+		config.GetInt("queue.cleanup_rate", 1000))
+	go janitor.Start(context.Background())
+}
+
+// setupWebhook 创建解读完成回调投递器，未配置签名密钥时不回调
+func setupWebhook() *webhook.Notifier {
+	secret := config.GetString("webhook.secret")
+	if secret == "" {
+		return nil
+	}
+	return webhook.NewNotifier(webhook.Config{
+		Secret:         secret,
+		MaxAttempts:    config.GetInt("webhook.max_attempts", 5),
+		InitialBackoff: time.Duration(config.GetInt("webhook.backoff", 2)) * time.Second,
+		MaxBackoff:     time.Duration(config.GetInt("webhook.max_backoff", 60)) * time.Second,
+		Timeout:        time.Duration(config.GetInt("webhook.timeout", 10)) * time.Second,
+		AllowPrivate:   config.GetBool("webhook.allow_private"),
+	}, repositories.NewWebhookRepository())
+}
@@ -0,0 +1,76 @@
+package bootstrap
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"tarot/app/http/controllers/api/v1/health"
+	"tarot/pkg/config"
+	"tarot/pkg/logger"
+)
+
+// WaitForReady 开始监听端口前等待依赖就绪
+// 与 /readyz 使用相同的检查（数据库、Redis、队列与 Dify），每 app.startup_check_interval 秒重试一次，
+// 超过 app.startup_check_timeout 秒仍有依赖不可用时返回错误，由调用方终止启动。
+// 关闭 app.startup_check 时直接返回，便于本地快速启动
+func WaitForReady() error {
+	if !config.GetBool("app.startup_check") {
+		return nil
+	}
+
+	timeout := seconds("app.startup_check_timeout")
+	interval := seconds("app.startup_check_interval")
+	if timeout <= 0 {
+		timeout = 60 * time.Second
+	}
+	if interval <= 0 {
+		interval = 2 * time.Second
+	}
+
+	return waitForReady(context.Background(), health.Checks(), timeout, interval)
+}
+
+// waitForReady 重复执行检查直到全部通过，超过 timeout 时返回最后一次的失败原因
+func waitForReady(ctx context.Context, checks map[string]health.Check, timeout, interval time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var down []string
+	for attempt := 1; ; attempt++ {
+		checkCtx, checkCancel := context.WithTimeout(ctx, health.CheckTimeout)
+		failed := failedChecks(health.RunChecks(checkCtx, checks))
+		checkCancel()
+
+		if len(failed) == 0 {
+			logger.InfoString("Startup", "Ready", fmt.Sprintf("依赖检查通过 [第 %d 次]", attempt))
+			return nil
+		}
+		// 总等待时间在检查中途耗尽时，正常的依赖也会被记为超时，沿用上一次完整检查的失败原因
+		if ctx.Err() == nil || down == nil {
+			down = failed
+		}
+		logger.WarnString("Startup", "NotReady", fmt.Sprintf(
+			"依赖未就绪 [第 %d 次]: %s", attempt, strings.Join(down, "; ")))
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("依赖未在 %v 内就绪: %s", timeout, strings.Join(down, "; "))
+		case <-time.After(interval):
+		}
+	}
+}
+
+// failedChecks 未通过的检查，按名称排序，部分可用的依赖不阻止启动
+func failedChecks(results map[string]health.CheckResult) []string {
+	var down []string
+	for name, result := range results {
+		if result.Status == health.StatusDown {
+			down = append(down, fmt.Sprintf("%s: %s", name, result.Error))
+		}
+	}
+	sort.Strings(down)
+	return down
+}
@@ -0,0 +1,78 @@
+package bootstrap
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"tarot/app/http/controllers/api/v1/health"
+	"tarot/pkg/testutil"
+)
+
+func TestWaitForReadyPassesOnceChecksRecover(t *testing.T) {
+	var attempts atomic.Int32
+	checks := map[string]health.Check{
+		"database": func(ctx context.Context) error { return nil },
+		"dify": func(ctx context.Context) error {
+			if attempts.Add(1) < 3 {
+				return errors.New("no healthy instance")
+			}
+			return nil
+		},
+	}
+
+	if err := waitForReady(context.Background(), checks, 5*time.Second, 10*time.Millisecond); err != nil {
+		t.Fatalf("waitForReady: %v", err)
+	}
+	if n := attempts.Load(); n != 3 {
+		t.Fatalf("dify checked %d times, want 3", n)
+	}
+}
+
+func TestWaitForReadyTimesOut(t *testing.T) {
+	checks := map[string]health.Check{
+		"database":    func(ctx context.Context) error { return nil },
+		"redis_queue": func(ctx context.Context) error { return errors.New("connection refused") },
+	}
+
+	start := time.Now()
+	err := waitForReady(context.Background(), checks, 200*time.Millisecond, 50*time.Millisecond)
+	if err == nil {
+		t.Fatal("waitForReady returned nil, want a timeout error")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("waitForReady took %s with a 200ms timeout", elapsed)
+	}
+	if msg := err.Error(); !strings.Contains(msg, "redis_queue: connection refused") || strings.Contains(msg, "database") {
+		t.Fatalf("error = %q, want only the failing check", msg)
+	}
+}
+
+// TestWaitForReadyBoundsHangingCheck 不返回的检查由单次检查超时截断，不会阻塞启动
+func TestWaitForReadyBoundsHangingCheck(t *testing.T) {
+	checks := map[string]health.Check{
+		"dify": func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		},
+	}
+
+	start := time.Now()
+	if err := waitForReady(context.Background(), checks, 100*time.Millisecond, 10*time.Millisecond); err == nil {
+		t.Fatal("waitForReady returned nil, want a timeout error")
+	}
+	if elapsed := time.Since(start); elapsed > health.CheckTimeout+time.Second {
+		t.Fatalf("waitForReady took %s", elapsed)
+	}
+}
+
+func TestWaitForReadyDisabled(t *testing.T) {
+	testutil.SetConfig(t, "app.startup_check", false)
+
+	if err := WaitForReady(); err != nil {
+		t.Fatalf("WaitForReady with the check disabled: %v", err)
+	}
+}
@@ -2,6 +2,9 @@ package bootstrap
 
 import (
 	"fmt"
+	"strings"
+	"time"
+
 	"tarot/pkg/config"
 	"tarot/pkg/redis"
 	"tarot/pkg/logger"
@@ -9,23 +12,54 @@ import (
 
 // SetupRedis 初始化 Redis
 func SetupRedis() {
+	base := redis.RedisConfig{
+		Mode:     config.GetString("redis.mode", redis.ModeStandalone),
+		Address:  fmt.Sprintf("%v:%v", config.GetString("redis.host"), config.GetString("redis.port")),
+		Username: config.GetString("redis.username"),
+		Password: config.GetString("redis.password"),
+
+		PoolSize:        config.GetInt("redis.pool_size"),
+		MinIdleConns:    config.GetInt("redis.min_idle"),
+		Timeout:         seconds("redis.pool_timeout"),
+		DialTimeout:     seconds("redis.dial_timeout"),
+		ReadTimeout:     seconds("redis.read_timeout"),
+		WriteTimeout:    seconds("redis.write_timeout"),
+		ConnMaxIdleTime: seconds("redis.conn_max_idle_time"),
+		ConnMaxLifetime: seconds("redis.conn_max_lifetime"),
+	}
+
 	// 添加日志
-	logger.InfoString("Redis", "Setup", fmt.Sprintf(
-		"正在连接 Redis: %v:%v, DB: %v, QueueDB: %v",
-		config.GetString("redis.host"),
-		config.GetString("redis.port"),
-		config.GetInt("redis.database"),
-		config.GetInt("redis.queue_database"),
-	))
+	if base.Mode == redis.ModeCluster {
+		for _, addr := range strings.Split(config.GetString("redis.cluster_addrs"), ",") {
+			if addr = strings.TrimSpace(addr); addr != "" {
+				base.Addresses = append(base.Addresses, addr)
+			}
+		}
+		logger.InfoString("Redis", "Setup", fmt.Sprintf(
+			"正在连接 Redis Cluster: %v", base.Addresses))
+	} else {
+		logger.InfoString("Redis", "Setup", fmt.Sprintf(
+			"正在连接 Redis: %v, DB: %v, QueueDB: %v, CacheDB: %v",
+			base.Address,
+			config.GetInt("redis.database"),
+			config.GetInt("redis.queue_database"),
+			config.GetInt("redis.cache_database"),
+		))
+	}
 	
-	// 初始化 Redis 连接
-	redis.InitRedis(
-		fmt.Sprintf("%v:%v", config.GetString("redis.host"), config.GetString("redis.port")),
-		config.GetString("redis.username"),
-		config.GetString("redis.password"),
-		config.GetInt("redis.database"),
-		config.GetInt("redis.queue_database"),
-	)
+	// 初始化 Redis 连接，队列实例使用独立的连接池配置
+	mainConfig := base
+	mainConfig.DB = config.GetInt("redis.database")
+
+	queueConfig := base
+	queueConfig.DB = config.GetInt("redis.queue_database")
+	queueConfig.PoolSize = config.GetInt("queue.pool_size")
+	queueConfig.MinIdleConns = config.GetInt("queue.min_idle")
+
+	cacheConfig := base
+	cacheConfig.DB = config.GetInt("redis.cache_database")
+
+	redis.InitRedis(mainConfig, queueConfig, cacheConfig)
 	
 	// 测试连接
 	mainRedis := redis.GetRedis(redis.MainDB)
@@ -39,6 +73,19 @@ func SetupRedis() {
 		logger.ErrorString("Redis", "QueueDB", fmt.Sprintf("连接失败: %v", err))
 		panic(err)
 	}
+
+	if err := redis.Cache().Ping(); err != nil {
+		logger.ErrorString("Redis", "CacheDB", fmt.Sprintf("连接失败: %v", err))
+		panic(err)
+	}
 	
+	// 启动后台健康检查
+	redis.Manager.StartMonitors(seconds("redis.health_interval"))
+
 	logger.InfoString("Redis", "Setup", "Redis 连接成功")
 }
+
+// seconds 读取以秒为单位的配置项，未配置时为 0（使用默认值）
+func seconds(path string) time.Duration {
+	return time.Duration(config.GetInt(path)) * time.Second
+}
@@ -0,0 +1,52 @@
+package bootstrap
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"tarot/app/repositories"
+	"tarot/pkg/config"
+	"tarot/pkg/logger"
+)
+
+// SetupRetention 启动解读记录过期清理任务
+func SetupRetention() {
+	interval := time.Duration(config.GetInt("tarot.purge_interval", 3600)) * time.Second
+	if interval <= 0 {
+		logger.InfoString("Retention", "Setup", "清理任务已禁用")
+		return
+	}
+
+	runBackground(func(ctx context.Context) {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				purgeExpiredReadings(ctx)
+			}
+		}
+	})
+
+	logger.InfoString("Retention", "Setup", "解读记录清理任务启动成功")
+}
+
+// purgeExpiredReadings 执行一次清理，每次读取最新的全局保留天数
+func purgeExpiredReadings(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+
+	globalDays := config.GetInt("tarot.retention_days", 0)
+	purged, err := repositories.NewReadingRepository().PurgeExpired(ctx, globalDays, time.Now())
+	if err != nil {
+		logger.ErrorString("Retention", "Purge", fmt.Sprintf("清理过期解读记录失败: %v", err))
+		return
+	}
+	if purged > 0 {
+		logger.InfoString("Retention", "Purge", fmt.Sprintf("已清理 %d 条过期解读记录", purged))
+	}
+}
@@ -2,6 +2,10 @@ package bootstrap
 
 import (
 	"tarot/app/http/middlewares"
+	"tarot/pkg/config"
+	"tarot/pkg/limiter"
+	"tarot/pkg/logger"
+	"tarot/pkg/response"
 	"tarot/routes"
 	"net/http"
 	"strings"
@@ -11,10 +15,14 @@ import (
 
 // SetupRoute 路由初始化
 // 该方法用于设置 Web 应用的路由配置，包括：
-// 1. 注册全局中间件
-// 2. 注册 API 路由
-// 3. 配置 404 处理器
+// 1. 设置可信代理
+// 2. 注册全局中间件
+// 3. 注册 API 路由
+// 4. 配置 404 处理器
 func SetupRoute(router *gin.Engine) {
+	// 只信任网关转发的客户端 IP
+	setupTrustedProxies(router)
+
 	// 注册全局中间件
 	registerGlobalMiddleWare(router)
 
@@ -26,14 +34,40 @@ func SetupRoute(router *gin.Engine) {
 	setup404Handler(router)
 }
 
+// setupTrustedProxies 只有直连地址在 app.trusted_proxies 中时，ClientIP 才采用 X-Forwarded-For 与 X-Real-IP
+// gin 默认信任任意来源的这两个头，客户端可借此冒充白名单 IP、绕过黑名单与按 IP 的限流；
+// 未配置时不信任任何代理，ClientIP 即直连地址
+func setupTrustedProxies(router *gin.Engine) {
+	proxies, err := limiter.ParseIPList(config.GetString("app.trusted_proxies"))
+	if err != nil {
+		logger.ErrorString("路由", "TrustedProxies", err.Error())
+	}
+
+	var cidrs []string
+	for _, n := range proxies {
+		cidrs = append(cidrs, n.String())
+	}
+	if err := router.SetTrustedProxies(cidrs); err != nil {
+		logger.ErrorString("路由", "TrustedProxies", err.Error())
+	}
+}
+
 // registerGlobalMiddleWare 注册全局中间件
 // 设置应用级别的中间件，作用于所有请求
+// - RequestID 中间件：分配请求 ID，贯穿请求、队列与 Dify 日志
+// - Tracing 中间件：创建请求 span，延续上游链路
+// - AccessLog 中间件：结构化访问日志，便于日志聚合
 // - Logger 中间件：记录请求日志
 // - Recovery 中间件：从 panic 中恢复
+// - Cors 中间件：跨域处理，需在全局注册，否则未匹配路由的预检请求无法处理
 func registerGlobalMiddleWare(router *gin.Engine) {
 	router.Use(
+		middlewares.RequestID(), // 分配请求 ID
+		middlewares.Tracing(),   // 链路追踪
+		middlewares.AccessLog(), // 结构化访问日志
 		middlewares.Logger(),    // 记录请求日志
 		middlewares.Recovery(),  // 在发生 panic 时恢复
+		middlewares.Cors(),      // 跨域处理
 	)
 }
 
@@ -52,10 +86,7 @@ func setup404Handler(router *gin.Engine) {
 			c.String(http.StatusNotFound, "页面返回 404")
 		} else {
 			// 默认返回 JSON 格式的错误信息
-			c.JSON(http.StatusNotFound, gin.H{
-				"error_code":    404,
-				"error_message": "路由未定义，请确认 url 和请求方法是否正确。",
-			})
+			response.Abort404(c, "路由未定义，请确认 url 和请求方法是否正确。")
 		}
 	})
 }
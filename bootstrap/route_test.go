@@ -0,0 +1,78 @@
+package bootstrap
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"tarot/app/http/middlewares"
+	"tarot/pkg/limiter"
+	"tarot/pkg/testutil"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// newProxyRouter 按 trustedProxies 设置可信代理，并挂载带黑白名单的限流
+func newProxyRouter(t *testing.T, trustedProxies string) *gin.Engine {
+	t.Helper()
+	testutil.SetupRedis(t)
+	testutil.SetConfig(t, "app.trusted_proxies", trustedProxies)
+	// 白名单与黑名单在第一次创建限流器时加载，同一测试进程内保持一致
+	testutil.SetConfig(t, "limiter.allowlist", "10.0.0.5")
+	testutil.SetConfig(t, "limiter.denylist", "203.0.113.9")
+
+	router := gin.New()
+	setupTrustedProxies(router)
+	router.GET("/limited", middlewares.LimitCombined(middlewares.LimitRule{Name: "ip", Limit: "1-M", KeyFunc: limiter.GetKeyIP}), func(c *gin.Context) {
+		c.String(http.StatusOK, c.ClientIP())
+	})
+	return router
+}
+
+// serveFrom 以 remoteIP 直连发起请求，forwardedFor 非空时携带 X-Forwarded-For
+func serveFrom(router *gin.Engine, remoteIP, forwardedFor string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/limited", nil)
+	req.RemoteAddr = remoteIP + ":12345"
+	if forwardedFor != "" {
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestSpoofedForwardedForIgnoredFromUntrustedPeer(t *testing.T) {
+	router := newProxyRouter(t, "")
+
+	// 伪造白名单 IP 不能跳过限流
+	if w := serveFrom(router, "198.51.100.7", "10.0.0.5"); w.Code != http.StatusOK || w.Body.String() != "198.51.100.7" {
+		t.Fatalf("first request = %d %q, want 200 from the peer address", w.Code, w.Body.String())
+	}
+	if w := serveFrom(router, "198.51.100.7", "10.0.0.5"); w.Code != http.StatusTooManyRequests {
+		t.Fatalf("spoofed allowlisted ip = %d, want 429", w.Code)
+	}
+
+	// 黑名单中的地址轮换 X-Forwarded-For 仍被拒绝
+	for _, spoofed := range []string{"192.0.2.1", "192.0.2.2"} {
+		if w := serveFrom(router, "203.0.113.9", spoofed); w.Code != http.StatusForbidden {
+			t.Fatalf("denylisted peer with X-Forwarded-For %s = %d, want 403", spoofed, w.Code)
+		}
+	}
+}
+
+func TestForwardedForTrustedFromConfiguredProxy(t *testing.T) {
+	router := newProxyRouter(t, "127.0.0.0/8")
+
+	for i := 0; i < 3; i++ {
+		if w := serveFrom(router, "127.0.0.1", "10.0.0.5"); w.Code != http.StatusOK || w.Body.String() != "10.0.0.5" {
+			t.Fatalf("allowlisted client via proxy = %d %q, want 200 for 10.0.0.5", w.Code, w.Body.String())
+		}
+	}
+	if w := serveFrom(router, "127.0.0.1", "203.0.113.9"); w.Code != http.StatusForbidden {
+		t.Fatalf("denylisted client via proxy = %d, want 403", w.Code)
+	}
+}
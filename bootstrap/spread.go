@@ -0,0 +1,13 @@
+package bootstrap
+
+import (
+	"time"
+
+	"tarot/app/models/spread"
+	"tarot/pkg/config"
+)
+
+// SetupSpread 设置牌阵缓存有效期，多实例部署时据此同步其他实例对牌阵的修改
+func SetupSpread() {
+	spread.CacheTTL = time.Duration(config.GetInt("tarot.spread_cache_ttl", 30)) * time.Second
+}
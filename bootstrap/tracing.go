@@ -0,0 +1,23 @@
+package bootstrap
+
+import (
+	"tarot/pkg/config"
+	"tarot/pkg/logger"
+	"tarot/pkg/tracing"
+)
+
+// SetupTracing 初始化链路追踪
+func SetupTracing() {
+	if !config.GetBool("tracing.enabled") {
+		return
+	}
+
+	tracing.Setup(tracing.Config{
+		Enabled:     true,
+		Endpoint:    config.GetString("tracing.endpoint"),
+		ServiceName: config.GetString("tracing.service_name"),
+		SampleRatio: config.GetFloat64("tracing.sample_ratio"),
+	}, nil)
+
+	logger.InfoString("Tracing", "Setup", "链路追踪启动成功")
+}
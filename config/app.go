@@ -19,11 +19,25 @@ func init() {
 			// 应用服务端口
 			"port": config.Env("APP_PORT", "3000"),
 
+			// 启动时等待数据库、Redis 与 Dify 就绪后再监听端口，超时则终止启动；本地开发可关闭以加快启动
+			"startup_check": config.Env("APP_STARTUP_CHECK", true),
+			// 启动检查的总等待时间与重试间隔（秒）
+			"startup_check_timeout":  config.Env("APP_STARTUP_CHECK_TIMEOUT", 60),
+			"startup_check_interval": config.Env("APP_STARTUP_CHECK_INTERVAL", 2),
+
+			// 可信网关地址（逗号分隔的 IP 或 CIDR），只有来自这些地址的请求才信任 X-User-ID 与 X-Clerk-User-ID 身份头，
+			// 以及用于识别客户端 IP 的 X-Forwarded-For 与 X-Real-IP
+			// 为空时不信任任何身份头，所有请求按游客处理，客户端 IP 取直连地址
+			"trusted_proxies": config.Env("TRUSTED_PROXIES", ""),
+
+			// 管理端接口令牌，为空时禁用所有管理端接口
+			"admin_token": config.Env("ADMIN_TOKEN", ""),
+
 			// 设置时区，日志记录里会使用到
 			"timezone": config.Env("TIMEZONE", "Asia/Shanghai"),
 
-			// 修改限流格式为每小时请求数
-			"api_rate_limit": config.Env("API_RATE_LIMIT", "100"),  // 每小时100次
+			// 限流格式为 "次数-时间单位"，单位可选 S、M、H、D
+			"api_rate_limit": config.Env("API_RATE_LIMIT", "100-H"),  // 每小时100次
 			"queue_rate_limit": config.Env("QUEUE_RATE_LIMIT", "30000"), // 每小时30000次
 		}
 	})
@@ -0,0 +1,22 @@
+package config
+
+import (
+	"tarot/pkg/config"
+)
+
+func init() {
+	config.Add("cors", func() map[string]interface{} {
+		return map[string]interface{}{
+			// 允许跨域访问的来源，逗号分隔，例如 https://tarot.example.com；* 表示允许所有来源
+			"allowed_origins": config.Env("CORS_ALLOWED_ORIGINS", "*"),
+			// 允许的请求方法
+			"allowed_methods": config.Env("CORS_ALLOWED_METHODS", "GET,POST,PUT,DELETE,OPTIONS"),
+			// 允许的请求头
+			"allowed_headers": config.Env("CORS_ALLOWED_HEADERS", "Origin,Content-Type,Content-Length,Accept-Encoding,X-CSRF-Token,Authorization,X-Admin-Token,X-Request-ID,X-User-ID,X-Clerk-User-ID,traceparent"),
+			// 是否允许携带 Cookie 等凭证，开启时来源不能为 *
+			"allow_credentials": config.Env("CORS_ALLOW_CREDENTIALS", false),
+			// 预检结果缓存时间（秒）
+			"max_age": config.Env("CORS_MAX_AGE", 600),
+		}
+	})
+}
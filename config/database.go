@@ -9,6 +9,8 @@ func init() {
 		return map[string]interface{}{
 			// 默认连接
 			"connection": config.Env("DB_CONNECTION", "postgresql"),
+			// 启动时是否自动迁移表结构，未设置时生产环境默认关闭，表结构变更应单独执行
+			"auto_migrate": config.Env("DB_AUTO_MIGRATE", config.Env("APP_ENV", "production") != "production"),
 
 			// PostgreSQL 数据库配置
 			"postgresql": map[string]interface{}{
@@ -18,6 +20,12 @@ func init() {
 				"database": config.Env("DB_DATABASE", "tarot"),
 				"username": config.Env("DB_USERNAME", ""),
 				"password": config.Env("DB_PASSWORD", ""),
+				// TLS 模式：disable / require / verify-ca / verify-full 等，云数据库通常需要 require 以上
+				"sslmode": config.Env("DB_SSLMODE", "disable"),
+				// verify-ca、verify-full 校验服务端证书使用的 CA 证书路径
+				"sslrootcert": config.Env("DB_SSLROOTCERT", ""),
+				// 连接名称，显示在 pg_stat_activity 中，便于区分来源
+				"application_name": config.Env("DB_APPLICATION_NAME", ""),
 
 				// 数据库连接池配置
 				"max_idle_connections": config.Env("DB_MAX_IDLE_CONNECTIONS", 100),
@@ -19,8 +19,38 @@ func init() {
 		return map[string]interface{}{
 			"urls":        urls,
 			"api_keys":    apiKeys,
+			// 实例权重，逗号分隔并与地址一一对应，缺失或无效时为 1
+			"weights":     config.Env("DIFY_WEIGHTS", ""),
 			"timeout":     config.Env("DIFY_TIMEOUT", 90),
 			"max_retries": config.Env("DIFY_MAX_RETRIES", 3),
+			// 实例请求失败后的冷却时间（秒），期间优先选择其他实例，0 表示不冷却
+			"cooldown": config.Env("DIFY_COOLDOWN", 5),
+			// 实例连续失败达到该次数时标记为不健康，不再参与负载均衡，直到请求成功
+			"error_threshold": config.Env("DIFY_ERROR_THRESHOLD", 3),
+
+			// 响应模式：blocking（阻塞）或 streaming（流式）
+			"response_mode": config.Env("DIFY_RESPONSE_MODE", "blocking"),
+			// 应用类型：workflow（工作流）或 chat（对话应用，支持多轮追问）
+			"app_mode": config.Env("DIFY_APP_MODE", "workflow"),
+			// 所有工作器发往 Dify 的请求每秒上限与突发容量，0 表示不限速，与接口限流相互独立
+			"rate_limit": config.Env("DIFY_RATE_LIMIT", 0),
+			"rate_burst": config.Env("DIFY_RATE_BURST", 5),
+			// 流式响应总时长上限（秒），超过即中断
+			"stream_timeout": config.Env("DIFY_STREAM_TIMEOUT", 300),
+			// 流式响应无新数据块的最长等待时间（秒），用于识别上游挂起
+			"stream_idle_timeout": config.Env("DIFY_STREAM_IDLE_TIMEOUT", 30),
+
+			// 访问 Dify 的出口代理，例如 http://proxy.internal:3128，为空时不使用
+			"proxy_url": config.Env("DIFY_PROXY_URL", ""),
+			// 额外信任的 CA 证书文件（PEM），用于内网自签证书，系统根证书仍然有效
+			"ca_file": config.Env("DIFY_CA_FILE", ""),
+
+			// 每个实例保留的空闲连接数，应不小于并发调用 Dify 的工作器数量，否则连接无法复用
+			"max_idle_conns_per_host": config.Env("DIFY_MAX_IDLE_CONNS_PER_HOST", 32),
+			// 空闲连接的保留时长（秒），应小于 Dify 前端负载均衡的空闲超时
+			"idle_conn_timeout": config.Env("DIFY_IDLE_CONN_TIMEOUT", 90),
+			// TCP keep-alive 探测间隔（秒），-1 表示关闭
+			"keep_alive": config.Env("DIFY_KEEP_ALIVE", 30),
 		}
 	})
 } 
\ No newline at end of file
@@ -0,0 +1,16 @@
+package config
+
+import (
+	"tarot/pkg/config"
+)
+
+func init() {
+	config.Add("limiter", func() map[string]interface{} {
+		return map[string]interface{}{
+			// 白名单，逗号分隔的 IP 或 CIDR，命中时跳过限流（如内部健康检查、监控）
+			"allowlist": config.Env("RATE_LIMIT_ALLOWLIST", ""),
+			// 黑名单，逗号分隔的 IP 或 CIDR，命中时直接返回 403
+			"denylist": config.Env("RATE_LIMIT_DENYLIST", ""),
+		}
+	})
+}
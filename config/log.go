@@ -31,6 +31,15 @@ func init() {
 			"max_age": config.Env("LOG_MAX_AGE", 30),
 			// 是否压缩，压缩日志不方便查看，我们设置为 false（压缩可节省空间）
 			"compress": config.Env("LOG_COMPRESS", false),
+
+			/* ------------------ 访问日志配置 ------------------ */
+			// 是否输出结构化访问日志，每个请求一行
+			"access_enabled": config.Env("LOG_ACCESS_ENABLED", true),
+			// 访问日志字段，逗号分隔
+			// 可选：method, path, route, status, duration, bytes, ip, request_id, user_id, user_agent
+			"access_fields": config.Env("LOG_ACCESS_FIELDS", "method,path,status,duration,bytes,ip,request_id,user_id"),
+			// 采样率（0~1），1 表示全部记录；5xx 响应始终记录
+			"access_sample_rate": config.Env("LOG_ACCESS_SAMPLE_RATE", 1),
 		}
 	})
 }
@@ -0,0 +1,14 @@
+package config
+
+import "tarot/pkg/config"
+
+func init() {
+	config.Add("moderation", func() map[string]interface{} {
+		return map[string]interface{}{
+			// 问题关键词黑名单（逗号分隔，不区分大小写）
+			"blocklist": config.Env("MODERATION_BLOCKLIST", ""),
+			// 问题拦截正则表达式，多个规则请使用 | 组合
+			"pattern": config.Env("MODERATION_PATTERN", ""),
+		}
+	})
+}
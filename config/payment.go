@@ -1,9 +1,64 @@
 package config
 
+import "tarot/pkg/config"
+
+func init() {
+	config.Add("payment", func() map[string]interface{} {
+		return map[string]interface{}{
+			// 订单有效期（分钟）
+			"expire_minutes": config.Env("PAYMENT_EXPIRE_MINUTES", 30),
+			"retry_times":    config.Env("PAYMENT_RETRY_TIMES", 3),
+			"retry_delay":    config.Env("PAYMENT_RETRY_DELAY", 5),
+
+			// 付费解读价格（单位：分）与币种
+			"premium_price": config.Env("PAYMENT_PREMIUM_PRICE", 2000),
+			"currency":      config.Env("PAYMENT_CURRENCY", "CNY"),
+
+			// 过期订单清理任务：执行间隔（秒）与单次处理数量
+			"sweep_interval":   config.Env("PAYMENT_SWEEP_INTERVAL", 60),
+			"sweep_batch_size": config.Env("PAYMENT_SWEEP_BATCH_SIZE", 100),
+			// 创建超过该时间（秒）仍待支付的订单会向支付平台补查，0 表示不补查
+			"reconcile_after": config.Env("PAYMENT_RECONCILE_AFTER", 300),
+
+			// 微信支付
+			"wechat": map[string]interface{}{
+				"app_id":           config.Env("WECHAT_PAY_APP_ID", ""),
+				"mch_id":           config.Env("WECHAT_PAY_MCH_ID", ""),
+				"serial_no":        config.Env("WECHAT_PAY_SERIAL_NO", ""),
+				"api_v3_key":       config.Env("WECHAT_PAY_API_V3_KEY", ""),
+				"private_key_path": config.Env("WECHAT_PAY_PRIVATE_KEY_PATH", ""),
+				"notify_url":       config.Env("WECHAT_PAY_NOTIFY_URL", ""),
+				"return_url":       config.Env("WECHAT_PAY_RETURN_URL", ""),
+			},
+
+			// 支付宝
+			"alipay": map[string]interface{}{
+				"app_id":           config.Env("ALIPAY_APP_ID", ""),
+				"private_key_path": config.Env("ALIPAY_PRIVATE_KEY_PATH", ""),
+				"public_key_path":  config.Env("ALIPAY_PUBLIC_KEY_PATH", ""),
+				"notify_url":       config.Env("ALIPAY_NOTIFY_URL", ""),
+				"return_url":       config.Env("ALIPAY_RETURN_URL", ""),
+				"is_production":    config.Env("ALIPAY_IS_PRODUCTION", false),
+			},
+
+			// Stripe（境外银行卡支付）
+			"stripe": map[string]interface{}{
+				"secret_key":     config.Env("STRIPE_SECRET_KEY", ""),
+				"webhook_secret": config.Env("STRIPE_WEBHOOK_SECRET", ""),
+				"currency":       config.Env("STRIPE_CURRENCY", "usd"),
+				// 付费解读价格，以 STRIPE_CURRENCY 的最小单位计价（如美分），与 premium_price 相互独立
+				"premium_price":  config.Env("STRIPE_PREMIUM_PRICE", 299),
+				"api_base":       config.Env("STRIPE_API_BASE", "https://api.stripe.com"),
+			},
+		}
+	})
+}
+
 // PaymentConfig 支付配置
 type PaymentConfig struct {
 	Wechat  WechatConfig
 	Alipay  AlipayConfig
+	Stripe  StripeConfig
 }
 
 // WechatConfig 微信支付配置
@@ -17,6 +72,14 @@ type WechatConfig struct {
 	ReturnURL  string
 }
 
+// StripeConfig Stripe 配置
+type StripeConfig struct {
+	SecretKey     string
+	WebhookSecret string
+	Currency      string
+	APIBase       string
+}
+
 // AlipayConfig 支付宝配置
 type AlipayConfig struct {
 	AppID        string
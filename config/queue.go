@@ -8,11 +8,37 @@ func init() {
 			"rate_limit":    config.Env("QUEUE_RATE_LIMIT", 12),
 			"rate_burst":    config.Env("QUEUE_RATE_BURST", 50),
 			"worker_count":  config.Env("QUEUE_WORKER_COUNT", 10),
+			// 每个工作器单次最多取出的任务数，队列积压时减少与 Redis 的往返；取出后依次处理，不宜过大
+			"batch_size": config.Env("QUEUE_BATCH_SIZE", 10),
+			// 默认队列之外的命名队列及其工作器数量，格式 name:workers，逗号分隔，例如 horoscope:2
+			"extra_queues": config.Env("QUEUE_EXTRA_QUEUES", ""),
 			"metrics_size":  config.Env("QUEUE_METRICS_SIZE", 1000),
+			// 队列指标摘要写入日志的间隔（秒），0 表示不输出
+			"metrics_interval": config.Env("QUEUE_METRICS_INTERVAL", 60),
 			"retry_times":   config.Env("QUEUE_RETRY_TIMES", 3),
 			"retry_delay":   config.Env("QUEUE_RETRY_DELAY", 1),
 			"pool_size":     config.Env("QUEUE_POOL_SIZE", 100),
 			"min_idle":      config.Env("QUEUE_MIN_IDLE", 10),
+
+			// Dify 不可用时的兜底解读，默认关闭
+			"fallback_enabled": config.Env("QUEUE_FALLBACK_ENABLED", false),
+			// 启用兜底的解读类型，逗号分隔，例如 free,premium
+			"fallback_types":   config.Env("QUEUE_FALLBACK_TYPES", "free"),
+			"fallback_message": config.Env("QUEUE_FALLBACK_MESSAGE", "我们的占卜师正在休息，请稍后再试。"),
+
+			// 各终态的状态与结果保留时间（秒），0 表示使用 redis.queue_timeout
+			"ttl_completed": config.Env("QUEUE_TTL_COMPLETED", 86400),
+			"ttl_failed":    config.Env("QUEUE_TTL_FAILED", 600),
+			"ttl_fallback":  config.Env("QUEUE_TTL_FALLBACK", 3600),
+
+			// 任务与结果超过该字节数时使用 gzip 压缩存储，0 表示不压缩
+			"compress_threshold": config.Env("QUEUE_COMPRESS_THRESHOLD", 0),
+
+			// 残留键清理间隔（秒），0 表示不清理
+			"cleanup_interval": config.Env("QUEUE_CLEANUP_INTERVAL", 600),
+			// 每次 SCAN 的 COUNT 提示与每秒最多检查的键数量
+			"cleanup_scan_count": config.Env("QUEUE_CLEANUP_SCAN_COUNT", 100),
+			"cleanup_rate":       config.Env("QUEUE_CLEANUP_RATE", 1000),
 		}
 	})
 } 
\ No newline at end of file
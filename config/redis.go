@@ -11,9 +11,31 @@ func init() {
 			"port":     config.Env("REDIS_PORT", "6379"),
 			"password": config.Env("REDIS_PASSWORD", ""),
 
+			// 部署模式：standalone（单机）或 cluster（集群）
+			"mode": config.Env("REDIS_MODE", "standalone"),
+			// 集群节点地址，逗号分隔，cluster 模式下使用；集群模式不支持选择数据库
+			"cluster_addrs": config.Env("REDIS_CLUSTER_ADDRS", ""),
+
+			// 连接池配置（队列实例使用 queue.pool_size / queue.min_idle）
+			"pool_size": config.Env("REDIS_POOL_SIZE", 100),
+			"min_idle":  config.Env("REDIS_MIN_IDLE", 10),
+			// 超时配置（秒）
+			"pool_timeout":  config.Env("REDIS_POOL_TIMEOUT", 5),
+			"dial_timeout":  config.Env("REDIS_DIAL_TIMEOUT", 5),
+			"read_timeout":  config.Env("REDIS_READ_TIMEOUT", 3),
+			"write_timeout": config.Env("REDIS_WRITE_TIMEOUT", 3),
+			// 连接空闲回收时间与最长存活时间（秒）
+			"conn_max_idle_time": config.Env("REDIS_CONN_MAX_IDLE_TIME", 300),
+			"conn_max_lifetime":  config.Env("REDIS_CONN_MAX_LIFETIME", 86400),
+			// 后台健康检查间隔（秒）
+			"health_interval": config.Env("REDIS_HEALTH_INTERVAL", 10),
+
 			// 业务类存储使用 1 号库（包括限流）
 			"database": config.Env("REDIS_MAIN_DB", 1),
 
+			// 缓存、幂等与分布式锁使用 3 号库
+			"cache_database": config.Env("REDIS_CACHE_DB", 3),
+
 			// 队列专用 2 号库
 			"queue_database": config.Env("REDIS_QUEUE_DB", 2),
 			"queue_prefix":   config.Env("REDIS_QUEUE_PREFIX", "tarot:queue"),
@@ -0,0 +1,14 @@
+package config
+
+import "tarot/pkg/config"
+
+func init() {
+	config.Add("share", func() map[string]interface{} {
+		return map[string]interface{}{
+			// 分享链接签名密钥，为空时禁用分享
+			"secret": config.Env("SHARE_SECRET", ""),
+			// 分享链接有效期（小时）
+			"ttl": config.Env("SHARE_TTL", 168),
+		}
+	})
+}
@@ -0,0 +1,35 @@
+package config
+
+import (
+	"tarot/pkg/config"
+)
+
+func init() {
+	config.Add("tarot", func() map[string]interface{} {
+		return map[string]interface{}{
+			// 卡牌图片访问前缀，一般为 CDN 地址，例如 https://cdn.example.com/cards
+			"card_image_base_url": config.Env("CARD_IMAGE_BASE_URL", ""),
+
+			// 查询解读结果时 wait 参数允许的最长等待时间（秒）
+			"result_max_wait": config.Env("READING_RESULT_MAX_WAIT", 25),
+
+			// SSE 推送解读结果的最长连接时间（秒）
+			"stream_max_duration": config.Env("READING_STREAM_MAX_DURATION", 300),
+
+			// 每个游客可进行的免费测算次数
+			"guest_free_readings": config.Env("GUEST_FREE_READINGS", 1),
+
+			// 创建解读时是否要求卡牌来自服务端抽牌（POST /v1/tarot/draws），默认关闭以兼容客户端抽牌
+			"require_draw": config.Env("TAROT_REQUIRE_DRAW", false),
+			// 抽牌结果的有效期（秒），过期后需重新抽牌
+			"draw_ttl": config.Env("TAROT_DRAW_TTL", 600),
+			// 牌阵缓存有效期（秒），多实例部署时其他实例的修改最迟在该时间后生效，0 表示只在本实例修改时刷新
+			"spread_cache_ttl": config.Env("SPREAD_CACHE_TTL", 30),
+
+			// 解读记录全局保留天数，0 表示永久保留；用户可单独设置覆盖该值
+			"retention_days": config.Env("READING_RETENTION_DAYS", 0),
+			// 过期记录清理任务执行间隔（秒）
+			"purge_interval": config.Env("READING_PURGE_INTERVAL", 3600),
+		}
+	})
+}
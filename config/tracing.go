@@ -0,0 +1,18 @@
+package config
+
+import "tarot/pkg/config"
+
+func init() {
+	config.Add("tracing", func() map[string]interface{} {
+		return map[string]interface{}{
+			// 是否启用链路追踪
+			"enabled": config.Env("OTEL_ENABLED", false),
+			// OTLP/HTTP Collector 地址，span 上报到 <endpoint>/v1/traces
+			"endpoint": config.Env("OTEL_EXPORTER_OTLP_ENDPOINT", "http://localhost:4318"),
+			// 上报的服务名
+			"service_name": config.Env("OTEL_SERVICE_NAME", "tarot"),
+			// 新链路采样率（0~1），上游已采样的链路始终延续
+			"sample_ratio": config.Env("OTEL_SAMPLE_RATIO", 1),
+		}
+	})
+}
@@ -0,0 +1,167 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"tarot/pkg/app"
+	"tarot/pkg/config"
+	"tarot/pkg/limiter"
+	"tarot/pkg/queue"
+)
+
+// Validate 校验启动所需的配置，汇总所有问题后一并返回
+// 需在 config.InitConfig 之后、连接数据库等外部依赖之前调用，配置有误时直接终止启动
+func Validate() error {
+	var errs []error
+	add := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	// 基础配置
+	if config.GetString("app.port") == "" {
+		add("app.port 不能为空")
+	}
+
+	if _, err := limiter.ParseIPList(config.GetString("app.trusted_proxies")); err != nil {
+		add("app.trusted_proxies 格式错误: %v", err)
+	}
+
+	// 数据库
+	switch connection := config.GetString("database.connection"); connection {
+	case "postgresql":
+		for _, key := range []string{"host", "port", "database", "username"} {
+			if config.GetString("database.postgresql."+key) == "" {
+				add("database.postgresql.%s 不能为空", key)
+			}
+		}
+		if app.IsProduction() && config.GetString("database.postgresql.password") == "" {
+			add("生产环境 database.postgresql.password 不能为空")
+		}
+		switch mode := config.GetString("database.postgresql.sslmode"); mode {
+		case "disable", "allow", "prefer", "require", "verify-ca", "verify-full":
+		default:
+			add("database.postgresql.sslmode 不支持: %q", mode)
+		}
+	case "sqlite":
+		if config.GetString("database.sqlite.database") == "" {
+			add("database.sqlite.database 不能为空")
+		}
+	default:
+		add("database.connection 不支持: %q", connection)
+	}
+
+	// Dify：地址与密钥一一对应
+	urls := splitList(config.GetString("dify.urls"))
+	keys := splitList(config.GetString("dify.api_keys"))
+	if len(urls) != len(keys) {
+		add("dify.urls 与 dify.api_keys 数量不一致: %d 个地址, %d 个密钥", len(urls), len(keys))
+	}
+	if app.IsProduction() && len(urls) == 0 {
+		add("生产环境 dify.urls 不能为空")
+	}
+	if mode := config.GetString("dify.response_mode"); mode != "blocking" && mode != "streaming" {
+		add("dify.response_mode 只能为 blocking 或 streaming: %q", mode)
+	}
+	if mode := config.GetString("dify.app_mode"); mode != "workflow" && mode != "chat" {
+		add("dify.app_mode 只能为 workflow 或 chat: %q", mode)
+	}
+	if config.GetInt("dify.cooldown") < 0 {
+		add("dify.cooldown 不能为负数: %d", config.GetInt("dify.cooldown"))
+	}
+	if config.GetInt("dify.error_threshold") < 1 {
+		add("dify.error_threshold 不能小于 1: %d", config.GetInt("dify.error_threshold"))
+	}
+	if proxy := config.GetString("dify.proxy_url"); proxy != "" {
+		if u, err := url.Parse(proxy); err != nil || u.Host == "" ||
+			(u.Scheme != "http" && u.Scheme != "https" && u.Scheme != "socks5") {
+			add("dify.proxy_url 必须是 http、https 或 socks5 的完整地址: %q", proxy)
+		}
+	}
+	if n := config.GetInt("dify.max_idle_conns_per_host"); n < 1 {
+		add("dify.max_idle_conns_per_host 不能小于 1: %d", n)
+	}
+	if caFile := config.GetString("dify.ca_file"); caFile != "" {
+		if _, err := os.Stat(caFile); err != nil {
+			add("dify.ca_file 无法读取: %v", err)
+		}
+	}
+
+	// 支付：付费解读价格以分为单位
+	if price := config.GetInt64("payment.premium_price"); price <= 0 {
+		add("payment.premium_price 必须大于 0: %d", price)
+	}
+	if config.GetString("payment.stripe.secret_key") != "" {
+		if price := config.GetInt64("payment.stripe.premium_price"); price <= 0 {
+			add("启用 Stripe 时 payment.stripe.premium_price 必须大于 0: %d", price)
+		}
+	}
+
+	// 服务端抽牌：结果保存在缓存 Redis 中，有效期过短时用户来不及提交解读
+	if config.GetBool("tarot.require_draw") && config.GetInt("tarot.draw_ttl") < 60 {
+		add("开启 tarot.require_draw 时 tarot.draw_ttl 不能小于 60 秒: %d", config.GetInt("tarot.draw_ttl"))
+	}
+	if ttl := config.GetInt("tarot.spread_cache_ttl"); ttl < 0 {
+		add("tarot.spread_cache_ttl 不能为负数: %d", ttl)
+	}
+
+	// Redis：单机模式下限流、队列与缓存使用不同的库，避免数据相互覆盖
+	if config.GetString("redis.mode") != "cluster" {
+		dbs := map[int]string{}
+		for _, key := range []string{"redis.database", "redis.queue_database", "redis.cache_database"} {
+			db := config.GetInt(key)
+			if other, ok := dbs[db]; ok {
+				add("%s 与 %s 不能使用同一个库: %d", key, other, db)
+			}
+			dbs[db] = key
+		}
+	}
+
+	// 命名队列，例如 horoscope:2
+	for _, item := range splitList(config.GetString("queue.extra_queues")) {
+		name, count, ok := strings.Cut(item, ":")
+		n, err := strconv.Atoi(count)
+		if !ok || err != nil || n <= 0 {
+			add("queue.extra_queues 格式错误，应为 name:workers: %q", item)
+			continue
+		}
+		if name == queue.DefaultQueue || !queue.ValidQueueName(name) {
+			add("queue.extra_queues 队列名称无效: %q", name)
+		}
+	}
+
+	// 限流格式，例如 100-H
+	if limit := config.GetString("app.api_rate_limit"); limit != "" {
+		if _, err := limiter.ParseLimit(limit); err != nil {
+			add("app.api_rate_limit 格式错误: %v", err)
+		}
+	}
+
+	// 内容审核正则
+	if pattern := config.GetString("moderation.pattern"); pattern != "" {
+		if _, err := regexp.Compile(pattern); err != nil {
+			add("moderation.pattern 格式错误: %v", err)
+		}
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+	return fmt.Errorf("配置校验失败:\n%w", errors.Join(errs...))
+}
+
+// splitList 拆分逗号分隔的配置项，忽略空白项
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,98 @@
+package config
+
+import (
+	"strings"
+	"testing"
+
+	"tarot/pkg/config"
+	"tarot/pkg/testutil"
+)
+
+// loadDefaults 以默认值加载全部配置项，不读取 .env 文件
+func loadDefaults(t *testing.T) {
+	t.Helper()
+	for name, fn := range config.ConfigFuncs {
+		testutil.SetConfig(t, name, fn())
+	}
+}
+
+// validConfig 在默认配置的基础上补齐一份可通过校验的配置
+func validConfig(t *testing.T) {
+	t.Helper()
+	loadDefaults(t)
+	testutil.SetConfig(t, "database.connection", "sqlite")
+	testutil.SetConfig(t, "database.sqlite.database", "tarot.db")
+	testutil.SetConfig(t, "dify.urls", "http://dify-1,http://dify-2")
+	testutil.SetConfig(t, "dify.api_keys", "app-1,app-2")
+}
+
+func TestValidateAcceptsValidConfig(t *testing.T) {
+	validConfig(t)
+
+	if err := Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+}
+
+func TestValidateRejectsInvalidConfig(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		overrides map[string]interface{}
+		want      string
+	}{
+		{"dify counts", map[string]interface{}{"dify.api_keys": "app-1"}, "dify.urls 与 dify.api_keys 数量不一致"},
+		{"rate limit", map[string]interface{}{"app.api_rate_limit": "100-X"}, "app.api_rate_limit 格式错误"},
+		{"production password", map[string]interface{}{
+			"app.env": "production", "database.connection": "postgresql",
+			"database.postgresql.host": "db", "database.postgresql.port": "5432",
+			"database.postgresql.database": "tarot", "database.postgresql.username": "tarot",
+			"database.postgresql.password": "", "database.postgresql.sslmode": "require",
+		}, "生产环境 database.postgresql.password 不能为空"},
+		{"postgresql sslmode", map[string]interface{}{
+			"database.connection": "postgresql", "database.postgresql.sslmode": "required",
+		}, `database.postgresql.sslmode 不支持: "required"`},
+		{"database driver", map[string]interface{}{"database.connection": "mysql"}, "database.connection 不支持"},
+		{"shared redis db", map[string]interface{}{"redis.database": 1, "redis.queue_database": 1}, "不能使用同一个库"},
+		{"shared cache db", map[string]interface{}{"redis.queue_database": 2, "redis.cache_database": 2}, "redis.cache_database 与 redis.queue_database 不能使用同一个库"},
+		{"response mode", map[string]interface{}{"dify.response_mode": "push"}, "dify.response_mode"},
+		{"extra queues", map[string]interface{}{"queue.extra_queues": "horoscope"}, "queue.extra_queues 格式错误"},
+		{"extra queue name", map[string]interface{}{"queue.extra_queues": "horoscope:2,Daily Jobs:1"}, "queue.extra_queues 队列名称无效"},
+		{"extra default queue", map[string]interface{}{"queue.extra_queues": "default:2"}, "queue.extra_queues 队列名称无效"},
+		{"dify cooldown", map[string]interface{}{"dify.cooldown": -1}, "dify.cooldown 不能为负数"},
+		{"dify error threshold", map[string]interface{}{"dify.error_threshold": 0}, "dify.error_threshold 不能小于 1"},
+		{"draw ttl", map[string]interface{}{"tarot.require_draw": true, "tarot.draw_ttl": 30}, "tarot.draw_ttl 不能小于 60 秒"},
+		{"dify proxy", map[string]interface{}{"dify.proxy_url": "proxy.internal:3128"}, "dify.proxy_url 必须是 http、https 或 socks5 的完整地址"},
+		{"dify ca file", map[string]interface{}{"dify.ca_file": "/nonexistent/dify-ca.pem"}, "dify.ca_file 无法读取"},
+		{"dify idle conns", map[string]interface{}{"dify.max_idle_conns_per_host": -1}, "dify.max_idle_conns_per_host 不能小于 1"},
+		{"moderation pattern", map[string]interface{}{"moderation.pattern": "(unclosed"}, "moderation.pattern 格式错误"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			validConfig(t)
+			for path, value := range tc.overrides {
+				testutil.SetConfig(t, path, value)
+			}
+
+			err := Validate()
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Fatalf("Validate error = %v, want %q", err, tc.want)
+			}
+		})
+	}
+}
+
+func TestValidateReportsAllErrors(t *testing.T) {
+	validConfig(t)
+	testutil.SetConfig(t, "dify.api_keys", "app-1")
+	testutil.SetConfig(t, "app.api_rate_limit", "fast")
+	testutil.SetConfig(t, "payment.premium_price", -1)
+
+	err := Validate()
+	if err == nil {
+		t.Fatal("Validate accepted an invalid config")
+	}
+	for _, want := range []string{"dify.urls", "app.api_rate_limit", "payment.premium_price"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Fatalf("Validate error %q does not mention %s", err, want)
+		}
+	}
+}
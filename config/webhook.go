@@ -0,0 +1,21 @@
+package config
+
+import "tarot/pkg/config"
+
+func init() {
+	config.Add("webhook", func() map[string]interface{} {
+		return map[string]interface{}{
+			// 回调签名密钥，为空时不投递回调
+			"secret": config.Env("WEBHOOK_SECRET", ""),
+			// 最大投递次数（含首次）
+			"max_attempts": config.Env("WEBHOOK_MAX_ATTEMPTS", 5),
+			// 首次重试间隔（秒），之后每次翻倍，最长 max_backoff 秒
+			"backoff":     config.Env("WEBHOOK_BACKOFF", 2),
+			"max_backoff": config.Env("WEBHOOK_MAX_BACKOFF", 60),
+			// 单次请求超时（秒）
+			"timeout": config.Env("WEBHOOK_TIMEOUT", 10),
+			// 是否允许回调内网地址，仅用于本地开发
+			"allow_private": config.Env("WEBHOOK_ALLOW_PRIVATE", false),
+		}
+	})
+}
@@ -10,7 +10,9 @@ import (
 	"syscall"
 	"tarot/bootstrap"
 	btsConfig "tarot/config"
+	"tarot/pkg/app"
 	"tarot/pkg/config"
+	"tarot/pkg/tracing"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -36,6 +38,11 @@ func main() {
 		log.Fatalf("初始化应用程序失败: %v", err)
 	}
 
+	// 等待数据库、Redis 与 Dify 就绪后再接收请求
+	if err := bootstrap.WaitForReady(); err != nil {
+		log.Fatalf("依赖服务未就绪: %v", err)
+	}
+
 	// 创建并配置 Gin 服务器
 	router := setupServer()
 
@@ -65,17 +72,34 @@ func setupApplication(env string) error {
 	// 先初始化配置
 	config.InitConfig(env)
 
+	// 校验配置，有误时终止启动
+	if err := btsConfig.Validate(); err != nil {
+		return err
+	}
+
 	// 然后初始化日志
 	bootstrap.SetupLogger()
 
+	// 初始化链路追踪
+	bootstrap.SetupTracing()
+
 	// 初始化数据库
 	bootstrap.SetupDB()
 
+	// 初始化牌阵缓存
+	bootstrap.SetupSpread()
+
 	// 初始化 Redis
 	bootstrap.SetupRedis()
 
-	// 初始化队列服务
-	bootstrap.SetupQueue()
+	// 初始化支付服务
+	bootstrap.SetupPayment()
+
+	// 启动解读记录过期清理
+	bootstrap.SetupRetention()
+
+	// 初始化问题内容审核
+	bootstrap.SetupModeration()
 
 	// 初始化 Dify 服务
 	difyService := bootstrap.SetupDify()
@@ -84,14 +108,16 @@ func setupApplication(env string) error {
 		return nil
 	}
 
+	// 初始化队列服务，Worker 使用上面创建的 Dify 服务
+	bootstrap.SetupQueue()
+
 	return nil
 }
 
 // setupServer 配置并返回 Gin 服务器实例
 func setupServer() *gin.Engine {
-	// 设置 gin 为生产模式
-	// 这样可以减少不必要的日志输出，提高性能
-	gin.SetMode(gin.ReleaseMode)
+	// 根据运行环境设置 gin 模式，需在配置加载之后调用
+	gin.SetMode(ginMode())
 
 	// 创建一个新的 Gin 引擎实例
 	router := gin.New()
@@ -102,6 +128,23 @@ func setupServer() *gin.Engine {
 	return router
 }
 
+// ginMode 根据 app.env 与 app.debug 选择 gin 模式
+// - testing 环境使用 test 模式
+// - production 环境始终使用 release 模式，减少日志输出并隐藏调试信息
+// - 其他环境（如 local）在开启 app.debug 时使用 debug 模式
+func ginMode() string {
+	switch {
+	case app.IsTesting():
+		return gin.TestMode
+	case app.IsProduction():
+		return gin.ReleaseMode
+	case config.GetBool("app.debug"):
+		return gin.DebugMode
+	default:
+		return gin.ReleaseMode
+	}
+}
+
 // start 启动服务器并处理优雅关闭
 func (a *App) start() {
 	// 创建系统信号监听器
@@ -128,5 +171,16 @@ func (a *App) start() {
 		log.Fatalf("服务器关闭异常: %v", err)
 	}
 
+	// 等待队列中进行中的任务完成
+	bootstrap.ShutdownQueue()
+
+	// 停止支付对账、记录清理等后台定时任务
+	bootstrap.ShutdownBackground()
+
+	// 导出剩余的追踪数据
+	traceCtx, traceCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer traceCancel()
+	tracing.Shutdown(traceCtx)
+
 	log.Println("服务器已成功关闭")
 }
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"tarot/pkg/testutil"
+)
+
+func TestGinModeFollowsEnvironment(t *testing.T) {
+	for _, tc := range []struct {
+		env   string
+		debug bool
+		want  string
+	}{
+		{"testing", true, gin.TestMode},
+		{"production", true, gin.ReleaseMode},
+		{"production", false, gin.ReleaseMode},
+		{"local", true, gin.DebugMode},
+		{"local", false, gin.ReleaseMode},
+		{"stage", true, gin.DebugMode},
+		{"stage", false, gin.ReleaseMode},
+	} {
+		testutil.SetConfig(t, "app.env", tc.env)
+		testutil.SetConfig(t, "app.debug", tc.debug)
+
+		if got := ginMode(); got != tc.want {
+			t.Fatalf("env=%s debug=%v: ginMode = %s, want %s", tc.env, tc.debug, got, tc.want)
+		}
+	}
+}
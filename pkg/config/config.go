@@ -84,6 +84,11 @@ func Add(name string, configFn ConfigFunc) {
 	ConfigFuncs[name] = configFn
 }
 
+// Set 设置配置项，覆盖已加载的值，主要用于测试
+func Set(path string, value interface{}) {
+	viper.Set(path, value)
+}
+
 // Get 获取配置项
 // 第一个参数 path 允许使用点式获取，如：app.name
 // 第二个参数允许传参默认值
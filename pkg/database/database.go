@@ -3,6 +3,8 @@ package database
 
 import (
 	"database/sql"
+	"fmt"
+	"strings"
 	"tarot/pkg/logger"
 
 	"gorm.io/gorm"
@@ -38,3 +40,51 @@ func Connect(dbConfig gorm.Dialector, _logger gormlogger.Interface) {
 func AutoMigrate(tables []interface{}) error {
 	return DB.AutoMigrate(tables...)
 }
+
+// Index 模型标签无法声明的复合索引，例如包含公共时间戳字段的索引
+type Index struct {
+	Table   string
+	Name    string
+	Columns []string
+}
+
+// CreateIndexes 创建不存在的复合索引，需在 AutoMigrate 之后执行
+func CreateIndexes(indexes []Index) error {
+	for _, index := range indexes {
+		if DB.Migrator().HasIndex(index.Table, index.Name) {
+			continue
+		}
+		sql := fmt.Sprintf("CREATE INDEX %s ON %s (%s)",
+			index.Name, index.Table, strings.Join(index.Columns, ", "))
+		if err := DB.Exec(sql).Error; err != nil {
+			return fmt.Errorf("create index %s: %w", index.Name, err)
+		}
+	}
+	return nil
+}
+
+// PoolStats 数据库连接池统计，等待次数与时长为启动以来的累计值
+type PoolStats struct {
+	MaxOpen        int     `json:"max_open"`         // 最大连接数，0 表示不限
+	Open           int     `json:"open"`             // 当前连接总数
+	InUse          int     `json:"in_use"`           // 使用中的连接数
+	Idle           int     `json:"idle"`             // 空闲连接数
+	WaitCount      int64   `json:"wait_count"`       // 等待空闲连接的次数，持续增长说明连接池不足
+	WaitDurationMs float64 `json:"wait_duration_ms"` // 等待空闲连接的总时长
+}
+
+// Stats 返回连接池统计，未连接数据库时返回零值
+func Stats() PoolStats {
+	if SQLDB == nil {
+		return PoolStats{}
+	}
+	stats := SQLDB.Stats()
+	return PoolStats{
+		MaxOpen:        stats.MaxOpenConnections,
+		Open:           stats.OpenConnections,
+		InUse:          stats.InUse,
+		Idle:           stats.Idle,
+		WaitCount:      stats.WaitCount,
+		WaitDurationMs: float64(stats.WaitDuration.Microseconds()) / 1000,
+	}
+}
@@ -1,14 +1,39 @@
 package migrations
 
 import (
-	"tarot/app/models/user"
+	"tarot/app/models/feedback"
+	"tarot/app/models/guest"
+	"tarot/app/models/payment"
 	"tarot/app/models/reading"
+	"tarot/app/models/spread"
+	"tarot/app/models/user"
+	"tarot/app/models/webhook"
+	"tarot/pkg/database"
 )
 
 // RegisterTables 返回需要迁移的表的模型列表
 func RegisterTables() []interface{} {
 	return []interface{}{
 		&user.User{},
+		&guest.Guest{},
 		&reading.Reading{},
+		&spread.Spread{},
+		&payment.Payment{},
+		&payment.Refund{},
+		&payment.PaymentEvent{},
+		&feedback.Feedback{},
+		&webhook.Delivery{},
+	}
+}
+
+// RegisterIndexes 返回 AutoMigrate 之后需要补充的复合索引
+func RegisterIndexes() []database.Index {
+	return []database.Index{
+		// 按用户查询进行中的解读（GetActiveByUserID）
+		{
+			Table:   reading.Reading{}.TableName(),
+			Name:    "idx_tarot_readings_user_status_created",
+			Columns: []string{"user_id", "status", "created_at"},
+		},
 	}
-} 
\ No newline at end of file
+}
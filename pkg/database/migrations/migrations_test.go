@@ -0,0 +1,48 @@
+package migrations
+
+import (
+	"strings"
+	"testing"
+
+	"tarot/pkg/database"
+	"tarot/pkg/testutil"
+)
+
+func TestRegisterIndexesCreatesCompositeIndexOnce(t *testing.T) {
+	testutil.SetupDB(t, RegisterTables()...)
+
+	for i := 0; i < 2; i++ {
+		if err := database.CreateIndexes(RegisterIndexes()); err != nil {
+			t.Fatalf("CreateIndexes run %d: %v", i+1, err)
+		}
+	}
+	for _, index := range RegisterIndexes() {
+		if !database.DB.Migrator().HasIndex(index.Table, index.Name) {
+			t.Fatalf("index %s missing on %s", index.Name, index.Table)
+		}
+	}
+}
+
+// TestActiveReadingsQueryUsesIndex 进行中解读的查询走 (user_id, status, created_at) 索引
+func TestActiveReadingsQueryUsesIndex(t *testing.T) {
+	testutil.SetupDB(t, RegisterTables()...)
+	if err := database.CreateIndexes(RegisterIndexes()); err != nil {
+		t.Fatalf("CreateIndexes: %v", err)
+	}
+
+	var plan []struct {
+		Detail string
+	}
+	err := database.DB.Raw("EXPLAIN QUERY PLAN SELECT * FROM tarot_readings WHERE user_id = ? AND status IN (?, ?) ORDER BY created_at DESC LIMIT 50",
+		"user-1", "pending", "processing").Scan(&plan).Error
+	if err != nil {
+		t.Fatalf("explain: %v", err)
+	}
+	var details []string
+	for _, row := range plan {
+		details = append(details, row.Detail)
+	}
+	if !strings.Contains(strings.Join(details, "\n"), "idx_tarot_readings_user_status_created") {
+		t.Fatalf("query plan = %q, want the composite index", details)
+	}
+}
@@ -0,0 +1,102 @@
+package dify
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// 连接池的默认值，标准库默认每个主机只保留 2 个空闲连接，并发的工作器会频繁新建连接
+const (
+	defaultMaxIdleConnsPerHost = 32
+	defaultIdleConnTimeout     = 90 * time.Second
+	defaultKeepAlive           = 30 * time.Second
+)
+
+// ClientOptions 各实例 HTTP 客户端共用的网络设置，流式请求复用同一个 Transport
+type ClientOptions struct {
+	ProxyURL string         // HTTP 代理地址，为空时沿用默认行为（读取 HTTP_PROXY 等环境变量）
+	RootCAs  *x509.CertPool // 校验服务端证书使用的根证书，为空时使用系统根证书
+
+	MaxIdleConnsPerHost int           // 每个实例保留的空闲连接数，<= 0 时为 defaultMaxIdleConnsPerHost
+	IdleConnTimeout     time.Duration // 空闲连接的保留时长，<= 0 时为 defaultIdleConnTimeout
+	KeepAlive           time.Duration // TCP keep-alive 探测间隔，0 时为 defaultKeepAlive，< 0 时关闭
+}
+
+// clientOptions 根据配置生成客户端设置，CA 证书文件在此读取，无法读取或解析时返回错误
+func (c *Config) clientOptions() (ClientOptions, error) {
+	opts := ClientOptions{
+		ProxyURL:            c.ProxyURL,
+		MaxIdleConnsPerHost: c.MaxIdleConnsPerHost,
+		IdleConnTimeout:     c.IdleConnTimeout,
+		KeepAlive:           c.KeepAlive,
+	}
+	if c.CAFile == "" {
+		return opts, nil
+	}
+
+	pool, err := loadCertPool(c.CAFile)
+	if err != nil {
+		return opts, err
+	}
+	opts.RootCAs = pool
+	return opts, nil
+}
+
+// apply 将设置应用到 resty 客户端，先替换 Transport，代理与证书再设置在新的 Transport 上
+func (o ClientOptions) apply(client *resty.Client) {
+	client.SetTransport(o.transport())
+	if o.ProxyURL != "" {
+		client.SetProxy(o.ProxyURL)
+	}
+	if o.RootCAs != nil {
+		client.SetTLSClientConfig(&tls.Config{RootCAs: o.RootCAs, MinVersion: tls.VersionTLS12})
+	}
+}
+
+// transport 基于标准库默认 Transport 调整连接池与 keep-alive
+func (o ClientOptions) transport() *http.Transport {
+	idlePerHost := o.MaxIdleConnsPerHost
+	if idlePerHost <= 0 {
+		idlePerHost = defaultMaxIdleConnsPerHost
+	}
+	idleTimeout := o.IdleConnTimeout
+	if idleTimeout <= 0 {
+		idleTimeout = defaultIdleConnTimeout
+	}
+	keepAlive := o.KeepAlive
+	if keepAlive == 0 {
+		keepAlive = defaultKeepAlive
+	}
+
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.DialContext = (&net.Dialer{Timeout: 30 * time.Second, KeepAlive: keepAlive}).DialContext
+	t.MaxIdleConnsPerHost = idlePerHost
+	// 总空闲连接数不少于单个主机的上限，否则单主机设置不生效
+	t.MaxIdleConns = max(t.MaxIdleConns, idlePerHost)
+	t.IdleConnTimeout = idleTimeout
+	return t
+}
+
+// loadCertPool 在系统根证书的基础上追加 PEM 文件中的证书，内网自签 CA 与公网证书可同时使用
+func loadCertPool(path string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取 dify.ca_file 失败: %w", err)
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("dify.ca_file 中没有有效的 PEM 证书: %s", path)
+	}
+	return pool, nil
+}
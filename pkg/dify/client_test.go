@@ -0,0 +1,211 @@
+package dify
+
+import (
+	"encoding/pem"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"tarot/pkg/testutil"
+)
+
+// writeCAFile 将 TLS 测试服务器的证书写入 PEM 文件
+func writeCAFile(t *testing.T, server *httptest.Server) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "ca.pem")
+	data := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("write ca file: %v", err)
+	}
+	return path
+}
+
+func TestNewInstanceSetsConfiguredProxy(t *testing.T) {
+	var mu sync.Mutex
+	var proxied []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		proxied = append(proxied, r.URL.String())
+		mu.Unlock()
+		w.Write([]byte(`{}`))
+	}))
+	t.Cleanup(proxy.Close)
+
+	instance := NewInstance("http://dify.internal", "app-1", time.Second, ClientOptions{ProxyURL: proxy.URL})
+	if !instance.Client.IsProxySet() {
+		t.Fatal("proxy not set on the client")
+	}
+	if _, err := instance.Client.R().Get("http://dify.internal/v1/parameters"); err != nil {
+		t.Fatalf("request through proxy: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(proxied) != 1 || proxied[0] != "http://dify.internal/v1/parameters" {
+		t.Fatalf("proxy received %v, want the Dify request", proxied)
+	}
+}
+
+func TestNewInstanceDefaultsToNoProxyAndSystemRoots(t *testing.T) {
+	opts, err := (&Config{}).clientOptions()
+	if err != nil {
+		t.Fatalf("clientOptions: %v", err)
+	}
+	instance := NewInstance("http://dify-1", "app-1", time.Second, opts)
+	if instance.Client.IsProxySet() {
+		t.Fatal("proxy set without dify.proxy_url")
+	}
+	if transport, err := instance.Client.Transport(); err != nil || (transport.TLSClientConfig != nil && transport.TLSClientConfig.RootCAs != nil) {
+		t.Fatalf("custom root CAs set without dify.ca_file: %v", err)
+	}
+}
+
+func TestCAFileTrustsCustomCertificate(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{}`))
+	}))
+	t.Cleanup(server.Close)
+
+	// 未配置 CA 证书时不信任自签证书，直接使用底层客户端以免触发重试
+	plain := NewInstance(server.URL, "app-1", time.Second, ClientOptions{})
+	if _, err := plain.Client.GetClient().Get(server.URL); err == nil {
+		t.Fatal("self-signed certificate trusted without dify.ca_file")
+	}
+
+	opts, err := (&Config{CAFile: writeCAFile(t, server)}).clientOptions()
+	if err != nil {
+		t.Fatalf("clientOptions: %v", err)
+	}
+	instance := NewInstance(server.URL, "app-1", time.Second, opts)
+	if resp, err := instance.Client.R().Get(server.URL); err != nil || resp.StatusCode() != http.StatusOK {
+		t.Fatalf("request with dify.ca_file: %v", err)
+	}
+}
+
+func TestInvalidCAFileRejected(t *testing.T) {
+	notPEM := filepath.Join(t.TempDir(), "ca.pem")
+	os.WriteFile(notPEM, []byte("not a certificate"), 0o600)
+
+	for name, path := range map[string]string{
+		"missing": filepath.Join(t.TempDir(), "missing.pem"),
+		"not pem": notPEM,
+	} {
+		cfg := &Config{URLs: []string{"http://dify-1"}, APIKeys: []string{"app-1"}, Timeout: time.Second, CAFile: path}
+		if _, err := cfg.clientOptions(); err == nil {
+			t.Fatalf("%s: clientOptions accepted %s", name, path)
+		}
+		if s := NewDifyService(cfg); s != nil {
+			t.Fatalf("%s: NewDifyService returned a service, want nil", name)
+		}
+	}
+}
+
+func TestLoadConfigReadsProxyAndCAFile(t *testing.T) {
+	testutil.SetConfig(t, "dify.proxy_url", "http://proxy.internal:3128")
+	testutil.SetConfig(t, "dify.ca_file", "/etc/ssl/dify-ca.pem")
+
+	cfg := LoadConfig()
+	if cfg.ProxyURL != "http://proxy.internal:3128" || cfg.CAFile != "/etc/ssl/dify-ca.pem" {
+		t.Fatalf("ProxyURL = %q, CAFile = %q", cfg.ProxyURL, cfg.CAFile)
+	}
+}
+
+func TestTransportAppliesPoolSettings(t *testing.T) {
+	transport := ClientOptions{MaxIdleConnsPerHost: 64, IdleConnTimeout: time.Minute}.transport()
+	if transport.MaxIdleConnsPerHost != 64 || transport.MaxIdleConns < 64 || transport.IdleConnTimeout != time.Minute {
+		t.Fatalf("transport = per host %d, total %d, idle %s", transport.MaxIdleConnsPerHost, transport.MaxIdleConns, transport.IdleConnTimeout)
+	}
+
+	defaults := ClientOptions{}.transport()
+	if defaults.MaxIdleConnsPerHost != defaultMaxIdleConnsPerHost || defaults.IdleConnTimeout != defaultIdleConnTimeout {
+		t.Fatalf("default transport = per host %d, idle %s", defaults.MaxIdleConnsPerHost, defaults.IdleConnTimeout)
+	}
+}
+
+func TestLoadConfigReadsPoolSettings(t *testing.T) {
+	testutil.SetConfig(t, "dify.max_idle_conns_per_host", 8)
+	testutil.SetConfig(t, "dify.idle_conn_timeout", 45)
+	testutil.SetConfig(t, "dify.keep_alive", -1)
+
+	cfg := LoadConfig()
+	if cfg.MaxIdleConnsPerHost != 8 || cfg.IdleConnTimeout != 45*time.Second || cfg.KeepAlive != -time.Second {
+		t.Fatalf("pool settings = %d, %s, %s", cfg.MaxIdleConnsPerHost, cfg.IdleConnTimeout, cfg.KeepAlive)
+	}
+}
+
+// countingServer 记录新建连接数的 Dify 服务，每个请求稍作停顿，使并发请求同时占用连接
+func countingServer(t *testing.T) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var conns atomic.Int32
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+		w.Write([]byte(`{}`))
+	}))
+	server.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			conns.Add(1)
+		}
+	}
+	server.Start()
+	t.Cleanup(server.Close)
+	return server, &conns
+}
+
+// concurrentRounds 分 rounds 轮并发发起 callers 个请求
+func concurrentRounds(t *testing.T, instance *Instance, rounds, callers int) {
+	t.Helper()
+	for round := 0; round < rounds; round++ {
+		var wg sync.WaitGroup
+		for i := 0; i < callers; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if _, err := instance.Client.R().Get(instance.URL); err != nil {
+					t.Errorf("request: %v", err)
+				}
+			}()
+		}
+		wg.Wait()
+	}
+}
+
+func TestConcurrentCallsReuseConnections(t *testing.T) {
+	const rounds, callers = 5, 10
+
+	server, conns := countingServer(t)
+	tuned := NewInstance(server.URL, "app-1", 5*time.Second, ClientOptions{MaxIdleConnsPerHost: callers})
+	concurrentRounds(t, tuned, rounds, callers)
+	if n := conns.Load(); n > callers {
+		t.Fatalf("opened %d connections for %d rounds of %d callers, want at most %d", n, rounds, callers, callers)
+	}
+
+	// 空闲连接数小于并发数时，每一轮都要重新建立大部分连接
+	server, conns = countingServer(t)
+	starved := NewInstance(server.URL, "app-1", 5*time.Second, ClientOptions{MaxIdleConnsPerHost: 1})
+	concurrentRounds(t, starved, rounds, callers)
+	if n := conns.Load(); n <= 2*callers {
+		t.Fatalf("opened only %d connections with a single idle slot, want the pool to be exhausted", n)
+	}
+}
+
+func BenchmarkConcurrentCalls(b *testing.B) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{}`))
+	}))
+	b.Cleanup(server.Close)
+	instance := NewInstance(server.URL, "app-1", 5*time.Second, ClientOptions{})
+
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := instance.Client.R().Get(server.URL); err != nil {
+				b.Errorf("request: %v", err)
+			}
+		}
+	})
+}
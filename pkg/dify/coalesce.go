@@ -0,0 +1,34 @@
+package dify
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+)
+
+// ReadingKey 解读输入的摘要，问题、卡牌（含顺序）与牌阵都相同的解读得到相同的 key
+// 延续对话的解读依赖各自的上下文，不应与其他解读合并，调用方需自行排除
+func ReadingKey(in ReadingInput) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%q|%v|%q", in.Question, in.Cards, in.Spread)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Coalesce 合并同一 key 正在进行中的调用，并发的相同解读只调用一次 Dify 并共享结果
+// shared 表示同一结果返回给了多个调用方；调用方的 ctx 结束时不再等待，但不会中断其他调用方仍在等待的请求
+func (s *DifyService) Coalesce(ctx context.Context, key string, fn func() (string, error)) (result string, shared bool, err error) {
+	ch := s.inflight.DoChan(key, func() (interface{}, error) {
+		return fn()
+	})
+
+	select {
+	case <-ctx.Done():
+		return "", false, ctx.Err()
+	case res := <-ch:
+		if res.Err != nil {
+			return "", res.Shared, res.Err
+		}
+		return res.Val.(string), res.Shared, nil
+	}
+}
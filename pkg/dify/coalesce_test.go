@@ -0,0 +1,135 @@
+package dify
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestReadingKey(t *testing.T) {
+	base := ReadingInput{Question: "我最近的事业运势如何？", Cards: []int{1, 2, 3}, Spread: "过去-现在-未来"}
+	same := base
+	same.Cards = []int{1, 2, 3}
+	same.OnChunk = func(string) {}
+	if ReadingKey(base) != ReadingKey(same) {
+		t.Fatal("identical readings got different keys")
+	}
+
+	for name, in := range map[string]ReadingInput{
+		"question":   {Question: "我最近的感情运势如何？", Cards: base.Cards, Spread: base.Spread},
+		"card order": {Question: base.Question, Cards: []int{3, 2, 1}, Spread: base.Spread},
+		"spread":     {Question: base.Question, Cards: base.Cards},
+	} {
+		if ReadingKey(in) == ReadingKey(base) {
+			t.Fatalf("different %s got the same key", name)
+		}
+	}
+}
+
+func TestCoalesceSharesOneCall(t *testing.T) {
+	s := &DifyService{}
+	const callers = 10
+
+	var calls atomic.Int32
+	joined := make(chan struct{})
+	var wg sync.WaitGroup
+	results := make([]string, callers)
+	shared := make([]bool, callers)
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var err error
+			results[i], shared[i], err = s.Coalesce(context.Background(), "key", func() (string, error) {
+				calls.Add(1)
+				<-joined
+				return "解读结果", nil
+			})
+			if err != nil {
+				t.Errorf("Coalesce: %v", err)
+			}
+		}()
+	}
+	// 等待所有调用方都进入等待后再返回结果
+	time.Sleep(100 * time.Millisecond)
+	close(joined)
+	wg.Wait()
+
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("backend called %d times, want 1", n)
+	}
+	for i := range results {
+		if results[i] != "解读结果" || !shared[i] {
+			t.Fatalf("caller %d got %q shared=%v", i, results[i], shared[i])
+		}
+	}
+
+	// 之前的调用结束后，新的调用重新请求
+	if _, shared, _ := s.Coalesce(context.Background(), "key", func() (string, error) { return "新结果", nil }); shared {
+		t.Fatal("a later call was shared with a finished one")
+	}
+}
+
+func TestCoalesceSharesErrors(t *testing.T) {
+	s := &DifyService{}
+	want := errors.New("dify error")
+	release := make(chan struct{})
+
+	var wg sync.WaitGroup
+	errs := make([]error, 3)
+	for i := range errs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _, errs[i] = s.Coalesce(context.Background(), "key", func() (string, error) {
+				<-release
+				return "", want
+			})
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	for i, err := range errs {
+		if !errors.Is(err, want) {
+			t.Fatalf("caller %d error = %v, want the shared error", i, err)
+		}
+	}
+}
+
+// TestCoalesceCallerCancelDoesNotAbortOthers 某个调用方放弃等待后，其他调用方仍拿到结果
+func TestCoalesceCallerCancelDoesNotAbortOthers(t *testing.T) {
+	s := &DifyService{}
+	release := make(chan struct{})
+	fn := func() (string, error) {
+		<-release
+		return "解读结果", nil
+	}
+
+	done := make(chan string)
+	go func() {
+		result, _, _ := s.Coalesce(context.Background(), "key", fn)
+		done <- result
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, _, err := s.Coalesce(ctx, "key", fn); !errors.Is(err, context.Canceled) {
+		t.Fatalf("cancelled caller error = %v, want context.Canceled", err)
+	}
+
+	close(release)
+	select {
+	case result := <-done:
+		if result != "解读结果" {
+			t.Fatalf("waiting caller got %q", result)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("waiting caller never got the result")
+	}
+}
@@ -0,0 +1,101 @@
+package dify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// Dify 调用错误分类，调用方通过 errors.Is 判断
+var (
+	// ErrDifyTimeout 请求超时
+	ErrDifyTimeout = errors.New("dify request timed out")
+	// ErrDifyClient 请求被 Dify 拒绝（4xx），重试不会成功，也不说明实例不健康
+	ErrDifyClient = errors.New("dify rejected the request")
+	// ErrDifyServer 网络错误或 Dify 服务端错误（5xx、429），可换实例重试
+	ErrDifyServer = errors.New("dify server error")
+	// ErrDifyMalformed 响应无法解析或不符合预期
+	ErrDifyMalformed = errors.New("dify response malformed")
+	// ErrInvalidCards 卡牌数量不合法，请求不会发送给 Dify
+	ErrInvalidCards = errors.New("invalid card count")
+	// ErrNoInstance 没有可用的 Dify 实例
+	ErrNoInstance = errors.New("no healthy dify instance available")
+)
+
+// APIError Dify 返回的非 200 响应
+type APIError struct {
+	Kind       error // ErrDifyClient 或 ErrDifyServer
+	StatusCode int
+	Body       string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%v: status %d, body: %s", e.Kind, e.StatusCode, e.Body)
+}
+
+func (e *APIError) Unwrap() error {
+	return e.Kind
+}
+
+// newStatusError 根据状态码构造 APIError，429 与 408 视为服务端繁忙，可重试
+func newStatusError(statusCode int, body string) error {
+	kind := ErrDifyServer
+	if statusCode >= 400 && statusCode < 500 &&
+		statusCode != http.StatusTooManyRequests && statusCode != http.StatusRequestTimeout {
+		kind = ErrDifyClient
+	}
+	return &APIError{Kind: kind, StatusCode: statusCode, Body: body}
+}
+
+// classifyRequestError 将请求发送阶段的错误归类为超时或服务端错误
+// 调用方主动取消时原样返回，便于上层识别
+func classifyRequestError(ctx context.Context, err error) error {
+	if errors.Is(ctx.Err(), context.Canceled) {
+		return ctx.Err()
+	}
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(ctx.Err(), context.DeadlineExceeded) ||
+		(errors.As(err, &netErr) && netErr.Timeout()) {
+		return fmt.Errorf("%w: %w", ErrDifyTimeout, err)
+	}
+	return fmt.Errorf("%w: %w", ErrDifyServer, err)
+}
+
+// ClassifyResponse 归类直接调用 Dify 接口得到的请求错误与非 200 响应，成功时返回 nil
+func ClassifyResponse(ctx context.Context, resp *resty.Response, err error) error {
+	if err != nil {
+		return classifyRequestError(ctx, err)
+	}
+	if resp.StatusCode() != http.StatusOK {
+		return newStatusError(resp.StatusCode(), resp.String())
+	}
+	return nil
+}
+
+// malformed 包装响应解析错误
+func malformed(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", ErrDifyMalformed, fmt.Sprintf(format, args...))
+}
+
+// IsUnavailable 判断错误是否说明 Dify 当前不可用：网络错误、5xx、超时或没有可用实例
+// 4xx、响应无法解析与输入不合法属于请求本身的问题，不算不可用
+func IsUnavailable(err error) bool {
+	return errors.Is(err, ErrDifyServer) ||
+		errors.Is(err, ErrDifyTimeout) ||
+		errors.Is(err, ErrNoInstance) ||
+		errors.Is(err, context.DeadlineExceeded) ||
+		IsStreamTimeout(err)
+}
+
+// IsRetryable 判断错误是否值得换实例重试
+// 4xx、输入不合法、流式超时与调用方取消都不重试
+func IsRetryable(err error) bool {
+	return !errors.Is(err, ErrDifyClient) &&
+		!errors.Is(err, ErrInvalidCards) &&
+		!IsStreamTimeout(err) &&
+		!errors.Is(err, context.Canceled)
+}
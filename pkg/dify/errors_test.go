@@ -0,0 +1,171 @@
+package dify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestIsUnavailable(t *testing.T) {
+	cases := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"server error", newStatusError(503, "busy"), true},
+		{"too many requests", newStatusError(429, ""), true},
+		{"transport error", fmt.Errorf("%w: connection refused", ErrDifyServer), true},
+		{"request timeout", fmt.Errorf("%w: %w", ErrDifyTimeout, context.DeadlineExceeded), true},
+		{"task deadline", fmt.Errorf("task failed: %w", context.DeadlineExceeded), true},
+		{"no healthy instance", fmt.Errorf("%w: %w", ErrNoInstance, errors.New("last error")), true},
+		{"stream idle timeout", ErrStreamIdleTimeout, true},
+		{"client error", newStatusError(400, "bad inputs"), false},
+		{"malformed response", malformed("missing outputs"), false},
+		{"invalid cards", ErrInvalidCards, false},
+		{"canceled", context.Canceled, false},
+		{"unrelated", errors.New("update task status error"), false},
+	}
+	for _, tc := range cases {
+		if got := IsUnavailable(tc.err); got != tc.want {
+			t.Errorf("%s: IsUnavailable(%v) = %v, want %v", tc.name, tc.err, got, tc.want)
+		}
+	}
+}
+
+// stubDify 启动按 handler 应答的假 Dify 服务，返回服务地址与请求计数
+func stubDify(t *testing.T, handler http.HandlerFunc) (string, *atomic.Int32) {
+	t.Helper()
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		handler(w, r)
+	}))
+	t.Cleanup(server.Close)
+	return server.URL, &calls
+}
+
+// newInstancesService 创建指向 urls 的对话应用服务（阻塞模式），连续 1 次错误即标记实例不健康
+func newInstancesService(t *testing.T, maxRetries int, urls ...string) *DifyService {
+	t.Helper()
+	keys := make([]string, len(urls))
+	for i := range keys {
+		keys[i] = fmt.Sprintf("app-%d", i+1)
+	}
+	s := NewDifyService(&Config{
+		URLs:           urls,
+		APIKeys:        keys,
+		Timeout:        200 * time.Millisecond,
+		MaxRetries:     maxRetries,
+		ResponseMode:   ResponseModeBlocking,
+		AppMode:        AppModeChat,
+		ErrorThreshold: 1,
+	})
+	if s == nil {
+		t.Fatal("NewDifyService returned nil")
+	}
+	return s
+}
+
+func TestDifyResponsesAreClassified(t *testing.T) {
+	cases := []struct {
+		name        string
+		handler     http.HandlerFunc
+		want        error
+		retryable   bool
+		unavailable bool
+		healthy     bool
+	}{
+		{"bad request", func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, `{"code":"invalid_param"}`, http.StatusBadRequest)
+		}, ErrDifyClient, false, false, true},
+		{"unauthorized", func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, `{"code":"unauthorized"}`, http.StatusUnauthorized)
+		}, ErrDifyClient, false, false, true},
+		{"too many requests", func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "slow down", http.StatusTooManyRequests)
+		}, ErrDifyServer, true, true, false},
+		{"server error", func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "boom", http.StatusBadGateway)
+		}, ErrDifyServer, true, true, false},
+		{"timeout", func(w http.ResponseWriter, r *http.Request) {
+			io.Copy(io.Discard, r.Body)
+			select {
+			case <-r.Context().Done():
+			case <-time.After(time.Second):
+			}
+		}, ErrDifyTimeout, true, true, false},
+		{"malformed", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte("not json"))
+		}, ErrDifyMalformed, true, false, false},
+	}
+	for _, tc := range cases {
+		url, _ := stubDify(t, tc.handler)
+		s := newInstancesService(t, 1, url)
+
+		_, err := s.ProcessTarotReading(context.Background(), testReading)
+		if !errors.Is(err, tc.want) {
+			t.Fatalf("%s: error = %v, want %v", tc.name, err, tc.want)
+		}
+		if got := IsRetryable(err); got != tc.retryable {
+			t.Errorf("%s: IsRetryable = %v, want %v", tc.name, got, tc.retryable)
+		}
+		if got := IsUnavailable(err); got != tc.unavailable {
+			t.Errorf("%s: IsUnavailable = %v, want %v", tc.name, got, tc.unavailable)
+		}
+		// 4xx 不计入实例错误，实例保持健康
+		if got := s.GetInstances()[0].Health; got != tc.healthy {
+			t.Errorf("%s: instance healthy = %v, want %v", tc.name, got, tc.healthy)
+		}
+	}
+}
+
+func TestDifyStatusErrorExposesStatusCode(t *testing.T) {
+	url, _ := stubDify(t, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "bad inputs", http.StatusUnprocessableEntity)
+	})
+
+	_, err := newInstancesService(t, 1, url).ProcessTarotReading(context.Background(), testReading)
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnprocessableEntity || apiErr.Kind != ErrDifyClient {
+		t.Fatalf("error = %v, want APIError 422", err)
+	}
+}
+
+func TestDifyClientErrorIsNotRetried(t *testing.T) {
+	reject := func(w http.ResponseWriter, r *http.Request) { http.Error(w, "bad inputs", http.StatusBadRequest) }
+	url1, calls1 := stubDify(t, reject)
+	url2, calls2 := stubDify(t, reject)
+
+	if _, err := newInstancesService(t, 3, url1, url2).ProcessTarotReading(context.Background(), testReading); !errors.Is(err, ErrDifyClient) {
+		t.Fatalf("error = %v, want ErrDifyClient", err)
+	}
+	if total := calls1.Load() + calls2.Load(); total != 1 {
+		t.Fatalf("dify called %d times, want 1", total)
+	}
+}
+
+func TestDifyServerErrorIsRetriedOnAnotherInstance(t *testing.T) {
+	url1, calls1 := stubDify(t, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "boom", http.StatusInternalServerError)
+	})
+	url2, calls2 := stubDify(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"event":"message","answer":"解读结果"}`))
+	})
+
+	// 两个实例负载相同时先选择第一个
+	result, err := newInstancesService(t, 3, url1, url2).ProcessTarotReading(context.Background(), testReading)
+	if err != nil || result != "解读结果" {
+		t.Fatalf("ProcessTarotReading = %q, %v want the second instance's result", result, err)
+	}
+	if calls1.Load() != 1 || calls2.Load() != 1 {
+		t.Fatalf("calls = %d/%d, want one attempt on each instance", calls1.Load(), calls2.Load())
+	}
+}
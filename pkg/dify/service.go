@@ -5,25 +5,44 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-resty/resty/v2"
+	"go.uber.org/zap"
+	"golang.org/x/sync/singleflight"
+	"golang.org/x/time/rate"
 
 	"tarot/pkg/config"
 	"tarot/pkg/logger"
+	"tarot/pkg/tracing"
 )
 
 // DifyService 实现了与 Dify API 的交互
 // 支持多实例负载均衡、故障转移和自动恢复
 type DifyService struct {
-	instances  []*Instance   // Dify API 实例列表
-	numRetries int           // 重试次数
-	timeout    time.Duration // 请求超时时间
-	mu         sync.RWMutex  // 保护实例状态的互斥锁
+	instances         []*Instance        // Dify API 实例列表
+	numRetries        int                // 重试次数
+	timeout           time.Duration      // 请求超时时间
+	responseMode      string             // 响应模式
+	appMode           string             // 应用类型
+	limiter           *rate.Limiter      // 出站请求限速，为 nil 时不限速
+	cooldown          time.Duration      // 实例失败后暂不选择的时长
+	errorThreshold    int                // 连续错误达到该次数时标记为不健康
+	degraded          atomic.Bool        // 是否处于没有健康实例的降级状态
+	inflight          singleflight.Group // 合并进行中的相同解读
+	streamTimeout     time.Duration      // 流式响应总时长上限
+	streamIdleTimeout time.Duration      // 流式响应无数据超时
+	mu                sync.RWMutex       // 保护实例状态的互斥锁
 }
 
+// Service 全局共享的 Dify 服务，由 bootstrap.SetupDify 初始化；
+// Worker、健康检查与管理端共用同一份实例状态
+var Service *DifyService
+
 // Instance Dify 实例
 type Instance struct {
 	URL          string
@@ -34,6 +53,9 @@ type Instance struct {
 	LastUsed     time.Time       // 记录最后一次成功使用时间
 	ErrorCount   int             // 连续错误计数
 	RequestCount *RequestCounter // 新增：请求计数器
+	Weight       int             // 权重，按权重分摊请求，默认 1
+	CooldownTill time.Time       // 最近一次失败后的冷却截止时间，冷却期间优先选择其他实例
+	LastFailedAt time.Time       // 最近一次失败的时间
 }
 
 // RequestCounter 请求计数器
@@ -82,13 +104,40 @@ func (rc *RequestCounter) GetRecentCount(duration time.Duration) int {
 	return count
 }
 
-// GetConfig 获取配置切片
+// GetConfig 获取逗号分隔的配置切片，去除各项首尾空白并忽略空项
 func GetConfig(key string) []string {
-	value := config.GetString(key)
-	if value == "" {
-		return []string{}
+	items := []string{}
+	for _, item := range strings.Split(config.GetString(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// LoadConfig 从配置文件读取 Dify 服务配置
+func LoadConfig() *Config {
+	return &Config{
+		URLs:              GetConfig("dify.urls"),
+		APIKeys:           GetConfig("dify.api_keys"),
+		Weights:           GetConfig("dify.weights"),
+		Timeout:           time.Duration(config.GetInt("dify.timeout")) * time.Second,
+		MaxRetries:        config.GetInt("dify.max_retries"),
+		ResponseMode:      config.GetString("dify.response_mode", ResponseModeBlocking),
+		AppMode:           config.GetString("dify.app_mode", AppModeWorkflow),
+		RateLimit:         config.GetFloat64("dify.rate_limit"),
+		RateBurst:         config.GetInt("dify.rate_burst"),
+		Cooldown:          time.Duration(config.GetInt("dify.cooldown", 5)) * time.Second,
+		ErrorThreshold:    config.GetInt("dify.error_threshold", defaultErrorThreshold),
+		StreamTimeout:     time.Duration(config.GetInt("dify.stream_timeout", 300)) * time.Second,
+		StreamIdleTimeout: time.Duration(config.GetInt("dify.stream_idle_timeout", 30)) * time.Second,
+		ProxyURL:          config.GetString("dify.proxy_url"),
+		CAFile:            config.GetString("dify.ca_file"),
+
+		MaxIdleConnsPerHost: config.GetInt("dify.max_idle_conns_per_host", defaultMaxIdleConnsPerHost),
+		IdleConnTimeout:     time.Duration(config.GetInt("dify.idle_conn_timeout", 90)) * time.Second,
+		KeepAlive:           time.Duration(config.GetInt("dify.keep_alive", 30)) * time.Second,
 	}
-	return strings.Split(value, ",")
 }
 
 // NewDifyService 创建新的 Dify 服务实例
@@ -101,10 +150,50 @@ func NewDifyService(config *Config) *DifyService {
 		return nil
 	}
 
+	// 地址与密钥按位置一一对应，数量不一致时无法确定对应关系
+	if len(config.URLs) != len(config.APIKeys) {
+		logger.ErrorString("Dify", "Config", fmt.Sprintf(
+			"dify.urls 与 dify.api_keys 数量不一致: %d 个地址, %d 个密钥",
+			len(config.URLs), len(config.APIKeys)))
+		return nil
+	}
+
 	// 创建服务实例
 	service := &DifyService{
-		instances: make([]*Instance, 0, len(config.URLs)),
-		timeout:   config.Timeout,
+		instances:         make([]*Instance, 0, len(config.URLs)),
+		numRetries:        config.MaxRetries,
+		timeout:           config.Timeout,
+		responseMode:      config.ResponseMode,
+		appMode:           config.AppMode,
+		cooldown:          config.Cooldown,
+		errorThreshold:    config.ErrorThreshold,
+		streamTimeout:     config.StreamTimeout,
+		streamIdleTimeout: config.StreamIdleTimeout,
+	}
+	if service.numRetries <= 0 {
+		service.numRetries = 1
+	}
+	if service.errorThreshold <= 0 {
+		service.errorThreshold = defaultErrorThreshold
+	}
+	if service.responseMode == "" {
+		service.responseMode = ResponseModeBlocking
+	}
+	if service.appMode != AppModeChat {
+		service.appMode = AppModeWorkflow
+	}
+	if config.RateLimit > 0 {
+		burst := config.RateBurst
+		if burst <= 0 {
+			burst = 1
+		}
+		service.limiter = rate.NewLimiter(rate.Limit(config.RateLimit), burst)
+	}
+
+	clientOpts, err := config.clientOptions()
+	if err != nil {
+		logger.ErrorString("Dify", "Config", err.Error())
+		return nil
 	}
 
 	// 初始化所有实例
@@ -112,8 +201,9 @@ func NewDifyService(config *Config) *DifyService {
 		url := config.URLs[i]
 		apiKey := config.APIKeys[i]
 		
-		instance := NewInstance(url, apiKey, config.Timeout)
+		instance := NewInstance(url, apiKey, config.Timeout, clientOpts)
 		if instance != nil {
+			instance.Weight = parseWeight(config.Weights, i)
 			service.instances = append(service.instances, instance)
 		}
 	}
@@ -126,6 +216,19 @@ func NewDifyService(config *Config) *DifyService {
 	return service
 }
 
+// parseWeight 解析第 i 个实例的权重，缺失或无效时为 1
+func parseWeight(weights []string, i int) int {
+	if i >= len(weights) {
+		return 1
+	}
+	weight, err := strconv.Atoi(weights[i])
+	if err != nil || weight <= 0 {
+		logger.WarnString("Dify", "Config", fmt.Sprintf("dify.weights 第 %d 项无效: %q，按 1 处理", i+1, weights[i]))
+		return 1
+	}
+	return weight
+}
+
 // GetInstances 获取所有实例列表
 func (s *DifyService) GetInstances() []*Instance {
 	s.mu.RLock()
@@ -163,7 +266,7 @@ func (s *DifyService) GetHealthyInstance() (*Instance, error) {
 		}
 	}
 
-	return nil, errors.New("no healthy dify instance available")
+	return nil, ErrNoInstance
 }
 
 // MarkInstanceUnhealthy 标记实例为不健康
@@ -173,11 +276,90 @@ func (s *DifyService) MarkInstanceUnhealthy(instance *Instance, err error) {
 
 	instance.Health = false
 	instance.LastErr = err
+	instance.LastFailedAt = time.Now()
 	logger.ErrorString("Dify", "Instance Unhealthy", fmt.Sprintf("URL: %s, Error: %v", instance.URL, err))
 }
 
+// IsValidResponseMode 检查响应模式是否合法
+func IsValidResponseMode(mode string) bool {
+	return mode == ResponseModeBlocking || mode == ResponseModeStreaming
+}
+
+// ResponseMode 获取配置的响应模式
+func (s *DifyService) ResponseMode() string {
+	return s.responseMode
+}
+
+// Wait 等待出站限速放行，所有工作器共享同一个限速器，避免积压任务集中消化时压垮 Dify
+// 直接向 Dify 发起请求的调用方也需要先调用
+func (s *DifyService) Wait(ctx context.Context) error {
+	if s.limiter == nil {
+		return nil
+	}
+	if err := s.limiter.Wait(ctx); err != nil {
+		return fmt.Errorf("dify rate limit wait: %w", err)
+	}
+	return nil
+}
+
+// AppMode 获取配置的应用类型
+func (s *DifyService) AppMode() string {
+	return s.appMode
+}
+
+// SupportsConversation 是否支持延续对话（多轮追问），仅对话应用支持
+func (s *DifyService) SupportsConversation() bool {
+	return s.appMode == AppModeChat
+}
+
+// endpoint 应用类型对应的接口地址
+func (s *DifyService) endpoint(instance *Instance) string {
+	if s.appMode == AppModeChat {
+		return instance.URL + "/v1/chat-messages"
+	}
+	return instance.URL + "/v1/workflows/run"
+}
+
+// newRequest 构建请求体
+// 对话应用以问题作为 query，并携带要延续的对话 ID
+func (s *DifyService) newRequest(in ReadingInput, responseMode string) (DifyRequest, error) {
+	inputs, err := in.inputs()
+	if err != nil {
+		return DifyRequest{}, err
+	}
+	req := DifyRequest{
+		Inputs:       inputs,
+		ResponseMode: responseMode,
+		User:         "tarot-user",
+	}
+	if s.appMode == AppModeChat {
+		req.Query = in.Question
+		req.ConversationID = in.ConversationID
+	}
+	return req, nil
+}
+
 // ProcessTarotReading 处理塔罗牌解请求
-func (s *DifyService) ProcessTarotReading(ctx context.Context, question string, cards []int) (string, error) {
+func (s *DifyService) ProcessTarotReading(ctx context.Context, in ReadingInput) (string, error) {
+	return s.process(ctx, in, s.callDifyAPI)
+}
+
+// ProcessTarotReadingStream 以流式模式处理塔罗牌解读请求
+// 受 streamTimeout 与 streamIdleTimeout 约束，上游挂起时会及时中断
+func (s *DifyService) ProcessTarotReadingStream(ctx context.Context, in ReadingInput) (string, error) {
+	return s.process(ctx, in, s.callDifyStreamAPI)
+}
+
+// apiCall Dify 接口调用函数
+type apiCall func(ctx context.Context, instance *Instance, in ReadingInput) (string, error)
+
+// process 带实例选择与重试的通用处理流程
+func (s *DifyService) process(ctx context.Context, in ReadingInput, call apiCall) (string, error) {
+	// 输入不合法时无需选择实例，直接返回
+	if _, err := in.inputs(); err != nil {
+		return "", err
+	}
+
 	start := time.Now()
 	var lastErr error
 
@@ -188,26 +370,44 @@ func (s *DifyService) ProcessTarotReading(ctx context.Context, question string,
 		}
 
 		// 记录请求开始
-		logger.InfoString("Dify", "Request", fmt.Sprintf(
-			"开始请求 实例:%s 问题:%s 卡牌:%v",
-			shortenURL(instance.URL), question, cards))
+		logger.InfoContext(ctx, "Dify", "Request",
+			logger.Instance(shortenURL(instance.URL)), logger.Attempt(i+1),
+			zap.String("question", in.Question), zap.Ints("cards", in.Cards))
+
+		if err := s.Wait(ctx); err != nil {
+			return "", err
+		}
 
-		result, err := s.callDifyAPI(ctx, instance, question, cards)
+		callCtx, span := tracing.Start(ctx, "dify.workflows.run", tracing.KindClient)
+		span.SetAttribute("dify.instance", shortenURL(instance.URL))
+		span.SetAttribute("dify.attempt", i+1)
+		result, err := call(callCtx, instance, in)
+		span.RecordError(err)
+		span.End()
 		if err != nil {
-			s.handleAPIError(instance, err)
 			lastErr = err
-			logger.ErrorString("Dify", "Error", fmt.Sprintf(
-				"请求失败 实例:%s 错误:%v",
-				shortenURL(instance.URL), err))
+			logger.ErrorContext(ctx, "Dify", "Error",
+				logger.Instance(shortenURL(instance.URL)), logger.Attempt(i+1), zap.Error(err))
+
+			// 4xx 是请求本身的问题，不计入实例错误，也不重试
+			if errors.Is(err, ErrDifyClient) {
+				return "", err
+			}
+			s.handleAPIError(instance, err)
+
+			// 流式超时说明上游已挂起，调用方取消或超时时也无需继续，不再重试以免继续占用资源
+			if !IsRetryable(err) || ctx.Err() != nil {
+				return "", err
+			}
 			continue
 		}
 
 		// 记录请求成功
 		instance.RequestCount.AddRequest()
 		duration := time.Since(start)
-		logger.InfoString("Dify", "Success", fmt.Sprintf(
-			"请求成功 实例:%s 耗时:%v 结果长度:%d",
-			shortenURL(instance.URL), duration, len(result)))
+		logger.InfoContext(ctx, "Dify", "Success",
+			logger.Instance(shortenURL(instance.URL)), logger.Latency(duration),
+			zap.Int("result_length", len(result)))
 
 		s.handleAPISuccess(instance)
 		return result, nil
@@ -216,76 +416,102 @@ func (s *DifyService) ProcessTarotReading(ctx context.Context, question string,
 	return "", fmt.Errorf("all retry attempts failed: %w", lastErr)
 }
 
+// defaultRequestTimeout 未配置 dify.timeout 时单次请求的超时时间
+const defaultRequestTimeout = 90 * time.Second
+
 // callDifyAPI 调用 Dify API
-func (s *DifyService) callDifyAPI(ctx context.Context, instance *Instance, question string, cards []int) (string, error) {
-	// 设置较长的超时时间
-	ctx, cancel := context.WithTimeout(ctx, 90*time.Second)
+// 单次请求最长 dify.timeout；调用方的截止时间更早时以调用方为准，取消也会随 ctx 传递给请求
+func (s *DifyService) callDifyAPI(ctx context.Context, instance *Instance, in ReadingInput) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.requestTimeout())
 	defer cancel()
 
 	// 构建请求体
-	reqBody := DifyRequest{
-		Inputs: map[string]interface{}{
-			"question": question,
-			"cards":    formatCards(cards),
-		},
-		ResponseMode: "blocking",
-		User:        "tarot-user",
+	reqBody, err := s.newRequest(in, ResponseModeBlocking)
+	if err != nil {
+		return "", err
 	}
+	url := s.endpoint(instance)
 
 	// 发送请求前记录
-	logger.InfoString("Dify", "Request", fmt.Sprintf(
-		"开始请求 实例:%s URL:%s/v1/workflows/run",
-		shortenURL(instance.URL), instance.URL))
+	logger.InfoContext(ctx, "Dify", "Call",
+		logger.Instance(shortenURL(instance.URL)), zap.String("url", url))
 
 	// 发送请求
-	resp, err := instance.Client.R().
+	req := instance.Client.R().
 		SetContext(ctx).
 		SetHeader("Authorization", fmt.Sprintf("Bearer %s", instance.APIKey)).
 		SetHeader("Content-Type", "application/json").
-		SetBody(reqBody).
-		Post(fmt.Sprintf("%s/v1/workflows/run", instance.URL))
+		SetBody(reqBody)
+	if traceparent := tracing.Inject(ctx); traceparent != "" {
+		req.SetHeader(tracing.TraceparentHeader, traceparent)
+	}
+	resp, err := req.Post(url)
 
 	if err != nil {
-		logger.ErrorString("Dify", "Error", fmt.Sprintf(
-			"请求失败 实例:%s 错误:%v", 
-			shortenURL(instance.URL), err))
-		return "", fmt.Errorf("failed to call dify api: %w", err)
+		logger.ErrorContext(ctx, "Dify", "CallError",
+			logger.Instance(shortenURL(instance.URL)), zap.Error(err))
+		return "", classifyRequestError(ctx, err)
 	}
 
 	// 记录响应结果
-	logger.InfoString("Dify", "Response", fmt.Sprintf(
-		"请求完成 实例:%s 状态:%d 响应长度:%d",
-		shortenURL(instance.URL), resp.StatusCode(), len(resp.Body())))
+	logger.InfoContext(ctx, "Dify", "Response",
+		logger.Instance(shortenURL(instance.URL)), logger.Latency(resp.Time()),
+		zap.Int("status", resp.StatusCode()), zap.Int("body_length", len(resp.Body())))
 
 	if resp.StatusCode() != 200 {
-		return "", fmt.Errorf("dify api returned non-200 status: %d, body: %s",
-			resp.StatusCode(), resp.String())
+		return "", newStatusError(resp.StatusCode(), resp.String())
 	}
 
 	// 解析响应
 	var difyResp DifyResponse
 	if err := json.Unmarshal(resp.Body(), &difyResp); err != nil {
-		return "", fmt.Errorf("failed to unmarshal dify response: %w", err)
+		return "", malformed("failed to unmarshal dify response: %v", err)
 	}
 
 	// 根据响应类型处理
 	if difyResp.EventType == "message" {
+		in.conversation(difyResp.ConversationID)
 		return difyResp.Answer, nil
 	}
+	// 工作流返回执行结果，解读文本在 outputs.text 中
+	if difyResp.Data.Status != "" {
+		return workflowText(difyResp)
+	}
+
+	return "", malformed("unexpected response type: %s", difyResp.EventType)
+}
+
+// workflowText 取出工作流阻塞响应中的解读文本，工作流执行失败时返回 ErrDifyServer
+func workflowText(resp DifyResponse) (string, error) {
+	if resp.Data.Status != "succeeded" {
+		return "", fmt.Errorf("%w: dify workflow %s: %s", ErrDifyServer, resp.Data.Status, resp.Data.Error)
+	}
+	text, ok := resp.Data.Outputs["text"].(string)
+	if !ok {
+		return "", malformed("dify workflow outputs missing text")
+	}
+	return text, nil
+}
 
-	return "", fmt.Errorf("unexpected response type: %s", difyResp.EventType)
+// requestTimeout 单次阻塞请求的超时上限
+func (s *DifyService) requestTimeout() time.Duration {
+	if s.timeout > 0 {
+		return s.timeout
+	}
+	return defaultRequestTimeout
 }
 
-// formatCards 格式化卡牌数组为字符串
-func formatCards(cards []int) string {
-	if len(cards) != 3 {
-		return "" // 或者返回错误
+// formatCards 格式化卡牌数组为逗号分隔的字符串
+// 卡牌数量须在 1 到 MaxCards 之间，否则返回 ErrInvalidCards
+func formatCards(cards []int) (string, error) {
+	if len(cards) < 1 || len(cards) > MaxCards {
+		return "", fmt.Errorf("%w: got %d cards, want 1-%d", ErrInvalidCards, len(cards), MaxCards)
 	}
 	cardStrs := make([]string, len(cards))
 	for i, card := range cards {
 		cardStrs[i] = fmt.Sprintf("%d", card)
 	}
-	return strings.Join(cardStrs, ",")
+	return strings.Join(cardStrs, ","), nil
 }
 
 // HealthCheck 检查 Dify 服务健康状态
@@ -311,23 +537,31 @@ func (s *DifyService) HealthCheck(ctx context.Context) error {
 
 	if !hasHealthy {
 		if lastErr != nil {
-			return fmt.Errorf("no healthy dify instance available: %w", lastErr)
+			return fmt.Errorf("%w: %w", ErrNoInstance, lastErr)
 		}
-		return errors.New("no healthy dify instance available")
+		return ErrNoInstance
 	}
 
 	return nil
 }
 
+// defaultErrorThreshold 未配置 dify.error_threshold 时标记实例不健康的连续错误次数
+const defaultErrorThreshold = 3
+
 // handleAPISuccess 处理 API 调用成功
 func (s *DifyService) handleAPISuccess(instance *Instance) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	if s.degraded.CompareAndSwap(true, false) {
+		logger.InfoString("Dify", "Recovered", fmt.Sprintf(
+			"实例 %s 请求成功，退出降级状态", shortenURL(instance.URL)))
+	}
 	instance.Health = true
 	instance.ErrorCount = 0
 	instance.LastUsed = time.Now()
 	instance.LastErr = nil
+	instance.CooldownTill = time.Time{}
 }
 
 // handleAPIError 处理 API 调用错误
@@ -337,9 +571,13 @@ func (s *DifyService) handleAPIError(instance *Instance, err error) {
 
 	instance.ErrorCount++
 	instance.LastErr = err
+	instance.LastFailedAt = time.Now()
+	if s.cooldown > 0 {
+		instance.CooldownTill = instance.LastFailedAt.Add(s.cooldown)
+	}
 
-	// 连续错误超过阈值才标记为不健康
-	if instance.ErrorCount >= 3 {
+	// 连续错误达到阈值才标记为不健康
+	if instance.Health && instance.ErrorCount >= s.errorThreshold {
 		instance.Health = false
 		logger.WarnString("Dify", "Instance", fmt.Sprintf(
 			"实例 %s 被标记为不健康: 连续 %d 次错误, 最后错误: %v",
@@ -348,15 +586,22 @@ func (s *DifyService) handleAPIError(instance *Instance, err error) {
 }
 
 // getAvailableInstance 获取可用的实例
+// 在健康实例中选择按权重折算后负载最低的实例，权重为 2 的实例承担约两倍的请求；
+// 刚失败仍在冷却期的实例不参与选择，只有所有健康实例都在冷却时才选择最早结束冷却的实例。
+// 没有健康实例时进入降级状态，只试用最久未失败的一个实例，成功后该实例恢复健康，其余实例状态不变
 func (s *DifyService) getAvailableInstance() (*Instance, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
 	var (
 		selected *Instance
+		cooling  *Instance
+		probe    *Instance
 		minLoad  int
+		minScore float64
 		statuses []string
 	)
+	now := time.Now()
 
 	// 记录当前有实例状态
 	var healthyCount, totalCount int
@@ -366,20 +611,35 @@ func (s *DifyService) getAvailableInstance() (*Instance, error) {
 		if instance.Health {
 			healthyCount++
 			load := instance.RequestCount.GetRecentCount(5 * time.Minute)
+			if now.Before(instance.CooldownTill) {
+				statuses = append(statuses, fmt.Sprintf(
+					"实例#%d[%s] - 健康状态:⏸ 冷却剩余:%v 最后错误:%v",
+					i+1, shortenURL(instance.URL),
+					instance.CooldownTill.Sub(now).Round(time.Second), instance.LastErr))
+				if cooling == nil || instance.CooldownTill.Before(cooling.CooldownTill) {
+					cooling = instance
+				}
+				continue
+			}
 			statuses = append(statuses, fmt.Sprintf(
-				"实例#%d[%s] - 健康状态:✅ 最近负载:%d 上次使用:%s",
-				i+1, shortenURL(instance.URL), load,
+				"实例#%d[%s] - 健康状态:✅ 最近负载:%d 权重:%d 上次使用:%s",
+				i+1, shortenURL(instance.URL), load, instance.weight(),
 				formatDuration(instance.LastUsed)))
 
-			if selected == nil || load < minLoad {
+			score := float64(load) / float64(instance.weight())
+			if selected == nil || score < minScore {
 				selected = instance
 				minLoad = load
+				minScore = score
 			}
 		} else {
 			statuses = append(statuses, fmt.Sprintf(
 				"实例#%d[%s] - 健康状态:❌ 错误计数:%d 最后错误:%v",
 				i+1, shortenURL(instance.URL), instance.ErrorCount,
 				instance.LastErr))
+			if probe == nil || instance.LastFailedAt.Before(probe.LastFailedAt) {
+				probe = instance
+			}
 		}
 	}
 
@@ -394,25 +654,25 @@ func (s *DifyService) getAvailableInstance() (*Instance, error) {
 		return selected, nil
 	}
 
-	// 如果没有健康实例，重置所有实例状态
-	if len(s.instances) > 0 {
-		s.resetAllInstances()
-		return s.instances[0], nil
+	// 健康实例都在冷却中，没有其他选择时仍使用最早结束冷却的实例
+	if cooling != nil {
+		logger.InfoString("Dify", "Selected", fmt.Sprintf(
+			"选择冷却中的实例 %s [无其他可用实例]", shortenURL(cooling.URL)))
+		return cooling, nil
 	}
 
-	return nil, errors.New("no dify instances available")
-}
-
-// resetAllInstances 重置所有实例状态
-func (s *DifyService) resetAllInstances() {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	for _, instance := range s.instances {
-		instance.Health = true
-		instance.ErrorCount = 0
+	// 没有健康实例，试用最久未失败的实例，不重置整个实例池，避免所有故障实例同时涌入请求
+	if probe != nil {
+		if s.degraded.CompareAndSwap(false, true) {
+			logger.WarnString("Dify", "Degraded", fmt.Sprintf(
+				"没有健康的 Dify 实例 (总数:%d)，进入降级状态", totalCount))
+		}
+		logger.WarnString("Dify", "Selected", fmt.Sprintf(
+			"降级试用实例 %s [最后失败:%s]", shortenURL(probe.URL), formatDuration(probe.LastFailedAt)))
+		return probe, nil
 	}
-	logger.InfoString("Dify", "Reset", "已重置所有实例状态")
+
+	return nil, fmt.Errorf("%w: no instances configured", ErrNoInstance)
 }
 
 // shortenURL 缩短 URL 用日志显示
@@ -436,8 +696,16 @@ func formatDuration(t time.Time) string {
 	return t.Format("01-02 15:04")
 }
 
-// NewInstance 创建新的 Dify 实例
-func NewInstance(url string, apiKey string, timeout time.Duration) *Instance {
+// weight 实例的有效权重，未设置时为 1
+func (i *Instance) weight() int {
+	if i.Weight <= 0 {
+		return 1
+	}
+	return i.Weight
+}
+
+// NewInstance 创建新的 Dify 实例，opts 为代理、证书等网络设置
+func NewInstance(url string, apiKey string, timeout time.Duration, opts ClientOptions) *Instance {
 	if url == "" || apiKey == "" {
 		return nil
 	}
@@ -447,6 +715,7 @@ func NewInstance(url string, apiKey string, timeout time.Duration) *Instance {
 		SetRetryCount(3).
 		SetRetryWaitTime(1 * time.Second).
 		SetRetryMaxWaitTime(5 * time.Second)
+	opts.apply(client)
 
 	return &Instance{
 		URL:          url,
@@ -456,5 +725,6 @@ func NewInstance(url string, apiKey string, timeout time.Duration) *Instance {
 		LastUsed:     time.Now(),
 		ErrorCount:   0,
 		RequestCount: NewRequestCounter(),
+		Weight:       1,
 	}
 }
@@ -0,0 +1,533 @@
+package dify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"tarot/pkg/testutil"
+)
+
+func TestNewDifyServiceRejectsMismatchedCounts(t *testing.T) {
+	for name, cfg := range map[string]Config{
+		"fewer keys": {URLs: []string{"http://dify-1", "http://dify-2"}, APIKeys: []string{"app-1"}},
+		"more keys":  {URLs: []string{"http://dify-1"}, APIKeys: []string{"app-1", "app-2"}},
+	} {
+		cfg.Timeout = time.Second
+		if s := NewDifyService(&cfg); s != nil {
+			t.Fatalf("%s: NewDifyService returned a service, want nil", name)
+		}
+	}
+}
+
+func TestGetConfigTrimsValues(t *testing.T) {
+	testutil.SetConfig(t, "dify.urls", " http://dify-1 ,http://dify-2,, ")
+	testutil.SetConfig(t, "dify.api_keys", "app-1, app-2")
+
+	urls, keys := GetConfig("dify.urls"), GetConfig("dify.api_keys")
+	if !slices.Equal(urls, []string{"http://dify-1", "http://dify-2"}) {
+		t.Fatalf("urls = %q", urls)
+	}
+	if !slices.Equal(keys, []string{"app-1", "app-2"}) {
+		t.Fatalf("api keys = %q", keys)
+	}
+
+	testutil.SetConfig(t, "dify.weights", "")
+	if weights := GetConfig("dify.weights"); len(weights) != 0 {
+		t.Fatalf("empty config = %q, want no items", weights)
+	}
+}
+
+func TestLoadConfigPairsTrimmedKeys(t *testing.T) {
+	testutil.SetConfig(t, "dify.urls", "http://dify-1, http://dify-2")
+	testutil.SetConfig(t, "dify.api_keys", "app-1 , app-2")
+	testutil.SetConfig(t, "dify.timeout", 5)
+
+	s := NewDifyService(LoadConfig())
+	if s == nil {
+		t.Fatal("NewDifyService returned nil")
+	}
+	instances := s.GetInstances()
+	if len(instances) != 2 || instances[1].URL != "http://dify-2" || instances[1].APIKey != "app-2" {
+		t.Fatalf("instances = %+v, want trimmed url/key pairs", instances)
+	}
+}
+
+// hangingDify 创建一直不响应的 Dify 服务，请求被取消时向 canceled 发送通知
+func hangingDify(t *testing.T, timeout time.Duration) (*DifyService, <-chan struct{}) {
+	t.Helper()
+	canceled := make(chan struct{}, 1)
+	url, _ := stubDify(t, func(w http.ResponseWriter, r *http.Request) {
+		// 读完请求体后服务端才能感知连接断开
+		io.Copy(io.Discard, r.Body)
+		select {
+		case <-r.Context().Done():
+			canceled <- struct{}{}
+		case <-time.After(5 * time.Second):
+		}
+	})
+	s := newInstancesService(t, 1, url)
+	s.timeout = timeout
+	return s, canceled
+}
+
+func TestCallerDeadlineBoundsDifyRequest(t *testing.T) {
+	s, canceled := hangingDify(t, 90*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	start := time.Now()
+	_, err := s.ProcessTarotReading(ctx, testReading)
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("call returned after %v, want about the 1s caller deadline", elapsed)
+	}
+	if !errors.Is(err, ErrDifyTimeout) {
+		t.Fatalf("error = %v, want ErrDifyTimeout", err)
+	}
+	select {
+	case <-canceled:
+	case <-time.After(time.Second):
+		t.Fatal("dify request was not canceled")
+	}
+}
+
+func TestConfiguredTimeoutBoundsDifyRequest(t *testing.T) {
+	s, _ := hangingDify(t, 200*time.Millisecond)
+
+	start := time.Now()
+	_, err := s.ProcessTarotReading(context.Background(), testReading)
+	if elapsed := time.Since(start); elapsed > time.Second || !errors.Is(err, ErrDifyTimeout) {
+		t.Fatalf("call returned %v after %v, want ErrDifyTimeout after the 200ms timeout", err, elapsed)
+	}
+}
+
+func TestCallerCancelPropagatesToDifyRequest(t *testing.T) {
+	s, canceled := hangingDify(t, 90*time.Second)
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+
+	if _, err := s.ProcessTarotReading(ctx, testReading); !errors.Is(err, context.Canceled) {
+		t.Fatalf("error = %v, want context.Canceled", err)
+	}
+	select {
+	case <-canceled:
+	case <-time.After(time.Second):
+		t.Fatal("dify request was not canceled")
+	}
+}
+
+func TestRequestTimeoutDefault(t *testing.T) {
+	s := &DifyService{}
+	if got := s.requestTimeout(); got != defaultRequestTimeout {
+		t.Fatalf("requestTimeout = %v, want %v", got, defaultRequestTimeout)
+	}
+	s.timeout = 30 * time.Second
+	if got := s.requestTimeout(); got != 30*time.Second {
+		t.Fatalf("requestTimeout = %v, want the configured 30s", got)
+	}
+}
+
+func TestFormatCards(t *testing.T) {
+	for _, tc := range []struct {
+		cards []int
+		want  string
+	}{
+		{[]int{7}, "7"},
+		{[]int{7, 21}, "7,21"},
+		{[]int{1, 2, 3}, "1,2,3"},
+	} {
+		got, err := formatCards(tc.cards)
+		if err != nil || got != tc.want {
+			t.Fatalf("formatCards(%v) = %q, %v, want %q", tc.cards, got, err, tc.want)
+		}
+	}
+
+	for _, cards := range [][]int{nil, {}, make([]int, MaxCards+1)} {
+		if got, err := formatCards(cards); !errors.Is(err, ErrInvalidCards) || got != "" {
+			t.Fatalf("formatCards(%d cards) = %q, %v, want ErrInvalidCards", len(cards), got, err)
+		}
+	}
+}
+
+func TestProcessSendsSingleCardReading(t *testing.T) {
+	var inputs map[string]interface{}
+	url, calls := stubDify(t, func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Inputs map[string]interface{} `json:"inputs"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		inputs = body.Inputs
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"event":"message","answer":"单张解读"}`))
+	})
+	s := newInstancesService(t, 1, url)
+
+	in := testReading
+	in.Cards = []int{7}
+	if result, err := s.ProcessTarotReading(context.Background(), in); err != nil || result != "单张解读" {
+		t.Fatalf("ProcessTarotReading = %q, %v", result, err)
+	}
+	if calls.Load() != 1 || inputs["cards"] != "7" {
+		t.Fatalf("dify inputs = %v, want cards \"7\"", inputs)
+	}
+
+	// 没有卡牌时不发送请求，直接返回错误
+	in.Cards = nil
+	if _, err := s.ProcessTarotReading(context.Background(), in); !errors.Is(err, ErrInvalidCards) {
+		t.Fatalf("error = %v, want ErrInvalidCards", err)
+	}
+	if calls.Load() != 1 {
+		t.Fatalf("dify called %d times, want no request for zero cards", calls.Load())
+	}
+}
+
+func TestWorkflowBlockingResponseReturnsOutputText(t *testing.T) {
+	cases := []struct {
+		name string
+		body string
+		want string
+		err  error
+	}{
+		{"succeeded", `{"data":{"status":"succeeded","outputs":{"text":"工作流解读"}}}`, "工作流解读", nil},
+		{"failed", `{"data":{"status":"failed","error":"node error"}}`, "", ErrDifyServer},
+		{"missing text", `{"data":{"status":"succeeded","outputs":{}}}`, "", ErrDifyMalformed},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var path string
+			url, _ := stubDify(t, func(w http.ResponseWriter, r *http.Request) {
+				path = r.URL.Path
+				w.Header().Set("Content-Type", "application/json")
+				w.Write([]byte(tc.body))
+			})
+			s := newInstancesService(t, 1, url)
+			s.appMode = AppModeWorkflow
+
+			result, err := s.ProcessTarotReading(context.Background(), testReading)
+			if path != "/v1/workflows/run" {
+				t.Fatalf("request path = %q, want /v1/workflows/run", path)
+			}
+			if tc.err == nil {
+				if err != nil || result != tc.want {
+					t.Fatalf("ProcessTarotReading = %q, %v, want %q", result, err, tc.want)
+				}
+				return
+			}
+			if !errors.Is(err, tc.err) {
+				t.Fatalf("error = %v, want %v", err, tc.err)
+			}
+		})
+	}
+}
+
+func TestWaitCapsOutboundRateAcrossCallers(t *testing.T) {
+	s := NewDifyService(&Config{URLs: []string{"http://dify-1"}, APIKeys: []string{"app-1"}, Timeout: time.Second, RateLimit: 20, RateBurst: 1})
+	if s == nil {
+		t.Fatal("NewDifyService returned nil")
+	}
+
+	// 5 个调用方各发起 4 次请求，共 20 次，按每秒 20 次至少需要 19 个间隔
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 4; j++ {
+				if err := s.Wait(context.Background()); err != nil {
+					t.Errorf("Wait: %v", err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if elapsed := time.Since(start); elapsed < 900*time.Millisecond {
+		t.Fatalf("20 requests passed in %s, want the 20/s limit to hold", elapsed)
+	}
+}
+
+func TestWaitWithoutRateLimit(t *testing.T) {
+	s := NewDifyService(&Config{URLs: []string{"http://dify-1"}, APIKeys: []string{"app-1"}, Timeout: time.Second})
+	if s == nil {
+		t.Fatal("NewDifyService returned nil")
+	}
+
+	start := time.Now()
+	for i := 0; i < 1000; i++ {
+		if err := s.Wait(context.Background()); err != nil {
+			t.Fatalf("Wait: %v", err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Fatalf("unlimited Wait took %s", elapsed)
+	}
+}
+
+func TestWaitStopsWhenContextEnds(t *testing.T) {
+	s := NewDifyService(&Config{URLs: []string{"http://dify-1"}, APIKeys: []string{"app-1"}, Timeout: time.Second, RateLimit: 0.1, RateBurst: 1})
+	if s == nil {
+		t.Fatal("NewDifyService returned nil")
+	}
+	if err := s.Wait(context.Background()); err != nil {
+		t.Fatalf("first Wait: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := s.Wait(ctx); err == nil {
+		t.Fatal("Wait returned nil, want an error once the caller's deadline cannot be met")
+	}
+}
+
+func TestInstanceSelectionFollowsWeights(t *testing.T) {
+	s := NewDifyService(&Config{
+		URLs:    []string{"http://dify-1", "http://dify-2", "http://dify-3"},
+		APIKeys: []string{"app-1", "app-2", "app-3"},
+		Weights: []string{"1", "2", "3"},
+		Timeout: time.Second,
+	})
+	if s == nil {
+		t.Fatal("NewDifyService returned nil")
+	}
+
+	const picks = 600
+	counts := make(map[string]int)
+	for i := 0; i < picks; i++ {
+		instance, err := s.getAvailableInstance()
+		if err != nil {
+			t.Fatalf("getAvailableInstance: %v", err)
+		}
+		instance.RequestCount.AddRequest()
+		counts[instance.URL]++
+	}
+
+	for i, instance := range s.GetInstances() {
+		want := picks * (i + 1) / 6
+		if got := counts[instance.URL]; got < want*9/10 || got > want*11/10 {
+			t.Fatalf("%s (weight %d) picked %d times, want about %d", instance.URL, instance.Weight, got, want)
+		}
+	}
+}
+
+func TestParseWeightDefaultsToOne(t *testing.T) {
+	weights := []string{"3", "0", "-2", "heavy", ""}
+	for i, want := range []int{3, 1, 1, 1, 1, 1} {
+		if got := parseWeight(weights, i); got != want {
+			t.Fatalf("weight %d = %d, want %d", i, got, want)
+		}
+	}
+}
+
+func TestLoadConfigReadsWeights(t *testing.T) {
+	testutil.SetConfig(t, "dify.urls", "http://dify-1,http://dify-2,http://dify-3")
+	testutil.SetConfig(t, "dify.api_keys", "app-1,app-2,app-3")
+	testutil.SetConfig(t, "dify.weights", "4, x")
+	testutil.SetConfig(t, "dify.timeout", 5)
+
+	s := NewDifyService(LoadConfig())
+	if s == nil {
+		t.Fatal("NewDifyService returned nil")
+	}
+	var got []int
+	for _, instance := range s.GetInstances() {
+		got = append(got, instance.Weight)
+	}
+	if !slices.Equal(got, []int{4, 1, 1}) {
+		t.Fatalf("weights = %v, want [4 1 1]", got)
+	}
+}
+
+// newCooldownService 创建两个实例的服务，连续错误阈值足够大，失败的实例保持健康只进入冷却
+func newCooldownService(t *testing.T, cooldown time.Duration, urls ...string) *DifyService {
+	t.Helper()
+	keys := make([]string, len(urls))
+	for i := range keys {
+		keys[i] = fmt.Sprintf("app-%d", i+1)
+	}
+	s := NewDifyService(&Config{URLs: urls, APIKeys: keys, Timeout: time.Second, ErrorThreshold: 100, Cooldown: cooldown})
+	if s == nil {
+		t.Fatal("NewDifyService returned nil")
+	}
+	return s
+}
+
+func pick(t *testing.T, s *DifyService) *Instance {
+	t.Helper()
+	instance, err := s.getAvailableInstance()
+	if err != nil {
+		t.Fatalf("getAvailableInstance: %v", err)
+	}
+	return instance
+}
+
+func TestFailedInstanceSkippedDuringCooldown(t *testing.T) {
+	s := newCooldownService(t, 200*time.Millisecond, "http://dify-1", "http://dify-2")
+	first, second := s.GetInstances()[0], s.GetInstances()[1]
+	// 第二个实例负载更高，不冷却时总是选择第一个实例
+	for i := 0; i < 5; i++ {
+		second.RequestCount.AddRequest()
+	}
+	if got := pick(t, s); got != first {
+		t.Fatalf("picked %s before any failure, want %s", got.URL, first.URL)
+	}
+
+	s.handleAPIError(first, errors.New("dify error"))
+	for i := 0; i < 3; i++ {
+		if got := pick(t, s); got != second {
+			t.Fatalf("picked %s during its cool-down, want %s", got.URL, second.URL)
+		}
+	}
+
+	time.Sleep(250 * time.Millisecond)
+	if got := pick(t, s); got != first {
+		t.Fatalf("picked %s after the cool-down, want %s again", got.URL, first.URL)
+	}
+}
+
+func TestCoolingInstanceUsedWhenNoOtherAvailable(t *testing.T) {
+	s := newCooldownService(t, time.Minute, "http://dify-1", "http://dify-2")
+	first, second := s.GetInstances()[0], s.GetInstances()[1]
+
+	s.handleAPIError(second, errors.New("dify error"))
+	time.Sleep(10 * time.Millisecond)
+	s.handleAPIError(first, errors.New("dify error"))
+	if got := pick(t, s); got != second {
+		t.Fatalf("picked %s, want the instance whose cool-down ends first", got.URL)
+	}
+
+	single := newCooldownService(t, time.Minute, "http://dify-1")
+	only := single.GetInstances()[0]
+	single.handleAPIError(only, errors.New("dify error"))
+	if got := pick(t, single); got != only {
+		t.Fatalf("picked %v, want the only instance despite its cool-down", got)
+	}
+}
+
+func TestCooldownClearedBySuccessOrDisabled(t *testing.T) {
+	s := newCooldownService(t, time.Minute, "http://dify-1", "http://dify-2")
+	first := s.GetInstances()[0]
+	s.handleAPIError(first, errors.New("dify error"))
+	s.handleAPISuccess(first)
+	if got := pick(t, s); got != first {
+		t.Fatalf("picked %s, want a successful instance out of cool-down", got.URL)
+	}
+
+	disabled := newCooldownService(t, 0, "http://dify-1", "http://dify-2")
+	first = disabled.GetInstances()[0]
+	disabled.handleAPIError(first, errors.New("dify error"))
+	if got := pick(t, disabled); got != first {
+		t.Fatalf("picked %s, want no cool-down when it is disabled", got.URL)
+	}
+}
+
+// newThresholdService 创建不冷却的服务，连续 threshold 次错误后实例标记为不健康
+func newThresholdService(t *testing.T, threshold int, urls ...string) *DifyService {
+	t.Helper()
+	keys := make([]string, len(urls))
+	for i := range keys {
+		keys[i] = fmt.Sprintf("app-%d", i+1)
+	}
+	s := NewDifyService(&Config{URLs: urls, APIKeys: keys, Timeout: time.Second, ErrorThreshold: threshold})
+	if s == nil {
+		t.Fatal("NewDifyService returned nil")
+	}
+	return s
+}
+
+func TestErrorThresholdIsConfigurable(t *testing.T) {
+	for _, tc := range []struct {
+		threshold int
+		want      int // 标记为不健康所需的连续错误次数
+	}{
+		{1, 1},
+		{5, 5},
+		{0, defaultErrorThreshold},
+	} {
+		s := newThresholdService(t, tc.threshold, "http://dify-1")
+		instance := s.GetInstances()[0]
+		for i := 1; i < tc.want; i++ {
+			s.handleAPIError(instance, errors.New("dify error"))
+		}
+		if !instance.Health {
+			t.Fatalf("threshold %d: unhealthy after %d errors, want healthy until %d", tc.threshold, tc.want-1, tc.want)
+		}
+		s.handleAPIError(instance, errors.New("dify error"))
+		if instance.Health {
+			t.Fatalf("threshold %d: still healthy after %d errors", tc.threshold, tc.want)
+		}
+	}
+}
+
+func TestLoadConfigReadsErrorThreshold(t *testing.T) {
+	testutil.SetConfig(t, "dify.urls", "http://dify-1")
+	testutil.SetConfig(t, "dify.api_keys", "app-1")
+	testutil.SetConfig(t, "dify.error_threshold", 7)
+
+	if got := LoadConfig().ErrorThreshold; got != 7 {
+		t.Fatalf("ErrorThreshold = %d, want 7", got)
+	}
+}
+
+// TestTransientErrorDoesNotResetPool 单次错误不会让已不健康的实例恢复
+func TestTransientErrorDoesNotResetPool(t *testing.T) {
+	s := newThresholdService(t, 2, "http://dify-1", "http://dify-2")
+	broken, flaky := s.GetInstances()[0], s.GetInstances()[1]
+	s.handleAPIError(broken, errors.New("connection refused"))
+	s.handleAPIError(broken, errors.New("connection refused"))
+
+	s.handleAPIError(flaky, errors.New("timeout"))
+	if got := pick(t, s); got != flaky {
+		t.Fatalf("picked %s, want the instance below the threshold", got.URL)
+	}
+	if broken.Health || broken.ErrorCount != 2 || !flaky.Health {
+		t.Fatalf("broken health=%v errors=%d, flaky health=%v; want the pool unchanged",
+			broken.Health, broken.ErrorCount, flaky.Health)
+	}
+}
+
+// TestNoHealthyInstanceProbesLeastRecentlyFailed 没有健康实例时只试用最久未失败的实例，其余实例保持不健康
+func TestNoHealthyInstanceProbesLeastRecentlyFailed(t *testing.T) {
+	s := newThresholdService(t, 1, "http://dify-1", "http://dify-2", "http://dify-3")
+	instances := s.GetInstances()
+	for _, i := range []int{1, 2, 0} {
+		s.handleAPIError(instances[i], errors.New("connection refused"))
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	for n := 0; n < 3; n++ {
+		if got := pick(t, s); got != instances[1] {
+			t.Fatalf("picked %s, want the least recently failed instance", got.URL)
+		}
+	}
+	if !s.degraded.Load() {
+		t.Fatal("service not marked degraded without healthy instances")
+	}
+	for i, instance := range instances {
+		if instance.Health || instance.ErrorCount != 1 {
+			t.Fatalf("instance %d health=%v errors=%d, want it left unhealthy", i, instance.Health, instance.ErrorCount)
+		}
+	}
+
+	// 试用失败后换下一个最久未失败的实例
+	s.handleAPIError(instances[1], errors.New("connection refused"))
+	if got := pick(t, s); got != instances[2] {
+		t.Fatalf("picked %s after the probe failed, want the next least recently failed", got.URL)
+	}
+
+	// 试用成功只恢复该实例，并退出降级状态
+	s.handleAPISuccess(instances[2])
+	if s.degraded.Load() || !instances[2].Health || instances[0].Health || instances[1].Health {
+		t.Fatalf("after recovery degraded=%v health=[%v %v %v], want only the probed instance healthy",
+			s.degraded.Load(), instances[0].Health, instances[1].Health, instances[2].Health)
+	}
+	if got := pick(t, s); got != instances[2] {
+		t.Fatalf("picked %s, want the recovered instance", got.URL)
+	}
+}
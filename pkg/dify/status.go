@@ -0,0 +1,76 @@
+package dify
+
+import "time"
+
+// recentWindow 统计实例近期请求数的时间窗口，与负载均衡使用的窗口一致
+const recentWindow = 5 * time.Minute
+
+// InstanceStatus 实例状态快照，用于管理端展示
+type InstanceStatus struct {
+	URL            string     `json:"url"` // 缩短后的地址，不包含密钥
+	Healthy        bool       `json:"healthy"`
+	ErrorCount     int        `json:"error_count"`
+	LastError      string     `json:"last_error,omitempty"`
+	LastUsed       *time.Time `json:"last_used,omitempty"`
+	RecentRequests int        `json:"recent_requests"` // 最近 5 分钟的请求数
+	Weight         int        `json:"weight"`
+	CooldownUntil  *time.Time `json:"cooldown_until,omitempty"` // 冷却截止时间，仅冷却中返回
+}
+
+// InstanceStatuses 返回所有实例的状态快照
+func (s *DifyService) InstanceStatuses() []InstanceStatus {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	statuses := make([]InstanceStatus, 0, len(s.instances))
+	for _, instance := range s.instances {
+		status := InstanceStatus{
+			URL:            shortenURL(instance.URL),
+			Healthy:        instance.Health,
+			ErrorCount:     instance.ErrorCount,
+			RecentRequests: instance.RequestCount.GetRecentCount(recentWindow),
+			Weight:         instance.weight(),
+		}
+		if instance.LastErr != nil {
+			status.LastError = instance.LastErr.Error()
+		}
+		if time.Now().Before(instance.CooldownTill) {
+			cooldownUntil := instance.CooldownTill
+			status.CooldownUntil = &cooldownUntil
+		}
+		if !instance.LastUsed.IsZero() {
+			lastUsed := instance.LastUsed
+			status.LastUsed = &lastUsed
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// HealthReport 实例池的健康概况
+type HealthReport struct {
+	Total     int              `json:"total"`
+	Healthy   int              `json:"healthy"`
+	Instances []InstanceStatus `json:"instances"`
+}
+
+// HealthyRatio 健康实例的占比，没有实例时为 0
+func (r HealthReport) HealthyRatio() float64 {
+	if r.Total == 0 {
+		return 0
+	}
+	return float64(r.Healthy) / float64(r.Total)
+}
+
+// HealthReport 返回健康实例数、实例总数与各实例状态
+// 与 HealthCheck 只判断是否存在健康实例不同，可以看出部分实例故障
+func (s *DifyService) HealthReport() HealthReport {
+	instances := s.InstanceStatuses()
+	report := HealthReport{Total: len(instances), Instances: instances}
+	for _, instance := range instances {
+		if instance.Healthy {
+			report.Healthy++
+		}
+	}
+	return report
+}
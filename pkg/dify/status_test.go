@@ -0,0 +1,65 @@
+package dify
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// newPool 创建 total 个实例的服务，并将前 down 个标记为不健康
+func newPool(t *testing.T, total, down int) *DifyService {
+	t.Helper()
+	urls := make([]string, total)
+	for i := range urls {
+		urls[i] = "http://dify-" + string(rune('a'+i)) + ".example.com"
+	}
+	s := newCooldownService(t, time.Minute, urls...)
+	for _, instance := range s.GetInstances()[:down] {
+		s.MarkInstanceUnhealthy(instance, errors.New("connection refused"))
+	}
+	return s
+}
+
+func TestHealthReport(t *testing.T) {
+	for _, tc := range []struct {
+		name         string
+		total, down  int
+		wantHealthy  int
+		wantRatio    float64
+		checkHealthy bool
+	}{
+		{"all healthy", 3, 0, 3, 1, true},
+		{"partial", 3, 2, 1, 1.0 / 3, true},
+		{"none healthy", 3, 3, 0, 0, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			s := newPool(t, tc.total, tc.down)
+
+			report := s.HealthReport()
+			if report.Total != tc.total || report.Healthy != tc.wantHealthy || len(report.Instances) != tc.total {
+				t.Fatalf("report = %d of %d healthy with %d instances, want %d of %d",
+					report.Healthy, report.Total, len(report.Instances), tc.wantHealthy, tc.total)
+			}
+			if ratio := report.HealthyRatio(); ratio != tc.wantRatio {
+				t.Fatalf("HealthyRatio = %v, want %v", ratio, tc.wantRatio)
+			}
+			for i, instance := range report.Instances {
+				if unhealthy := i < tc.down; instance.Healthy == unhealthy || (unhealthy && instance.LastError != "connection refused") {
+					t.Fatalf("instance %d = %+v, want healthy=%v", i, instance, !unhealthy)
+				}
+			}
+
+			// 布尔检查只要有一个健康实例即通过
+			if err := s.HealthCheck(context.Background()); (err == nil) != tc.checkHealthy {
+				t.Fatalf("HealthCheck = %v, want healthy=%v", err, tc.checkHealthy)
+			}
+		})
+	}
+}
+
+func TestHealthyRatioWithoutInstances(t *testing.T) {
+	if ratio := (HealthReport{}).HealthyRatio(); ratio != 0 {
+		t.Fatalf("HealthyRatio of an empty pool = %v, want 0", ratio)
+	}
+}
@@ -0,0 +1,175 @@
+package dify
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"tarot/pkg/logger"
+	"tarot/pkg/tracing"
+)
+
+var (
+	// ErrStreamTimeout 流式响应超过总时长上限
+	ErrStreamTimeout = errors.New("dify stream deadline exceeded")
+	// ErrStreamIdleTimeout 流式响应长时间没有收到新的数据块
+	ErrStreamIdleTimeout = errors.New("dify stream idle timeout")
+)
+
+// IsStreamTimeout 判断是否为流式响应超时错误
+func IsStreamTimeout(err error) bool {
+	return errors.Is(err, ErrStreamTimeout) || errors.Is(err, ErrStreamIdleTimeout)
+}
+
+// streamLine 读取协程传递的单行数据
+type streamLine struct {
+	text string
+	err  error
+}
+
+// callDifyStreamAPI 以流式模式调用 Dify API
+//
+// 同时受两个超时约束：
+// - streamTimeout: 整个流的总时长上限
+// - streamIdleTimeout: 连续未收到数据块的最长时间
+// 任一超时触发都会取消请求并关闭连接，释放工作器
+func (s *DifyService) callDifyStreamAPI(ctx context.Context, instance *Instance, in ReadingInput) (string, error) {
+	streamCtx, cancel := context.WithTimeout(ctx, s.streamTimeout)
+	defer cancel()
+
+	reqBody, err := s.newRequest(in, ResponseModeStreaming)
+	if err != nil {
+		return "", err
+	}
+	body, err := json.Marshal(reqBody)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal dify request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(streamCtx, http.MethodPost,
+		s.endpoint(instance), bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("failed to build dify request: %w", err)
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", instance.APIKey))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "text/event-stream")
+	if traceparent := tracing.Inject(ctx); traceparent != "" {
+		req.Header.Set(tracing.TraceparentHeader, traceparent)
+	}
+
+	// 复用实例的连接池，但不使用 client 级别的超时，超时由本方法自行控制
+	client := &http.Client{Transport: instance.Client.GetClient().Transport}
+	resp, err := client.Do(req)
+	if err != nil {
+		if errors.Is(streamCtx.Err(), context.DeadlineExceeded) && ctx.Err() == nil {
+			return "", ErrStreamTimeout
+		}
+		return "", classifyRequestError(ctx, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return "", newStatusError(resp.StatusCode, string(body))
+	}
+
+	// 在独立协程中逐行读取，主循环负责超时判断
+	lines := make(chan streamLine)
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(resp.Body)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			select {
+			case lines <- streamLine{text: scanner.Text()}:
+			case <-streamCtx.Done():
+				return
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			select {
+			case lines <- streamLine{err: err}:
+			case <-streamCtx.Done():
+			}
+		}
+	}()
+
+	idle := time.NewTimer(s.streamIdleTimeout)
+	defer idle.Stop()
+
+	var answer strings.Builder
+	for {
+		select {
+		case <-streamCtx.Done():
+			if ctx.Err() != nil {
+				return "", ctx.Err()
+			}
+			logger.WarnContext(ctx, "Dify", "StreamTimeout",
+				logger.Instance(shortenURL(instance.URL)), zap.Duration("limit", s.streamTimeout))
+			return "", ErrStreamTimeout
+
+		case <-idle.C:
+			logger.WarnContext(ctx, "Dify", "StreamIdle",
+				logger.Instance(shortenURL(instance.URL)), zap.Duration("idle", s.streamIdleTimeout))
+			return "", ErrStreamIdleTimeout
+
+		case line, ok := <-lines:
+			if !ok {
+				return "", malformed("dify stream closed before finished")
+			}
+			if line.err != nil {
+				return "", fmt.Errorf("%w: failed to read dify stream: %v", ErrDifyServer, line.err)
+			}
+
+			data, found := strings.CutPrefix(line.text, "data:")
+			if !found {
+				continue
+			}
+
+			var event StreamEvent
+			if err := json.Unmarshal([]byte(strings.TrimSpace(data)), &event); err != nil {
+				return "", malformed("failed to unmarshal dify stream event: %v", err)
+			}
+
+			// ping 为保活事件，不算作有效数据块
+			if event.Event == "ping" {
+				continue
+			}
+			idle.Reset(s.streamIdleTimeout)
+
+			switch event.Event {
+			case "message", "agent_message":
+				answer.WriteString(event.Answer)
+				in.emit(event.Answer)
+			case "text_chunk":
+				answer.WriteString(event.Data.Text)
+				in.emit(event.Data.Text)
+			case "message_end":
+				in.conversation(event.ConversationID)
+				return answer.String(), nil
+			case "workflow_finished":
+				if event.Data.Status != "" && event.Data.Status != "succeeded" {
+					return "", fmt.Errorf("%w: dify workflow %s: %s", ErrDifyServer, event.Data.Status, event.Data.Error)
+				}
+				if answer.Len() == 0 {
+					if text, ok := event.Data.Outputs["text"].(string); ok {
+						answer.WriteString(text)
+					}
+				}
+				return answer.String(), nil
+			case "error":
+				return "", fmt.Errorf("%w: dify stream error: %s", ErrDifyServer, event.Message)
+			}
+		}
+	}
+}
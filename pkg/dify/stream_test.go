@@ -0,0 +1,128 @@
+package dify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	_ "tarot/pkg/testutil" // 初始化空日志
+)
+
+// newStubService 创建连接到假 Dify 服务的 DifyService，cfg 中未设置的地址与密钥由本函数填充
+func newStubService(t *testing.T, handler http.HandlerFunc, cfg Config) *DifyService {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	cfg.URLs = []string{server.URL}
+	cfg.APIKeys = []string{"app-test"}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = 5 * time.Second
+	}
+	s := NewDifyService(&cfg)
+	if s == nil {
+		t.Fatal("NewDifyService returned nil")
+	}
+	return s
+}
+
+// writeEvent 写出一个 SSE 数据块并立即发送
+func writeEvent(w http.ResponseWriter, data string) {
+	fmt.Fprintf(w, "data: %s\n\n", data)
+	w.(http.Flusher).Flush()
+}
+
+var testReading = ReadingInput{Question: "我最近的事业运势如何？", Cards: []int{1, 2, 3}}
+
+func TestStreamIdleTimeout(t *testing.T) {
+	s := newStubService(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		writeEvent(w, `{"event":"message","answer":"星"}`)
+		// 发送一个数据块后不再响应
+		<-r.Context().Done()
+	}, Config{StreamTimeout: 5 * time.Second, StreamIdleTimeout: 100 * time.Millisecond})
+
+	var (
+		mu     sync.Mutex
+		chunks []string
+	)
+	in := testReading
+	in.OnChunk = func(text string) {
+		mu.Lock()
+		chunks = append(chunks, text)
+		mu.Unlock()
+	}
+
+	start := time.Now()
+	_, err := s.ProcessTarotReadingStream(context.Background(), in)
+	if !errors.Is(err, ErrStreamIdleTimeout) {
+		t.Fatalf("err = %v, want ErrStreamIdleTimeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("idle timeout took %s", elapsed)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(chunks) != 1 || chunks[0] != "星" {
+		t.Fatalf("chunks = %v, want the chunk sent before stalling", chunks)
+	}
+}
+
+func TestStreamPingDoesNotResetIdleTimeout(t *testing.T) {
+	s := newStubService(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		ticker := time.NewTicker(20 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-ticker.C:
+				writeEvent(w, `{"event":"ping"}`)
+			}
+		}
+	}, Config{StreamTimeout: 5 * time.Second, StreamIdleTimeout: 150 * time.Millisecond})
+
+	if _, err := s.ProcessTarotReadingStream(context.Background(), testReading); !errors.Is(err, ErrStreamIdleTimeout) {
+		t.Fatalf("err = %v, want ErrStreamIdleTimeout", err)
+	}
+}
+
+func TestStreamDeadline(t *testing.T) {
+	s := newStubService(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		ticker := time.NewTicker(20 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-ticker.C:
+				writeEvent(w, `{"event":"message","answer":"星"}`)
+			}
+		}
+	}, Config{StreamTimeout: 200 * time.Millisecond, StreamIdleTimeout: time.Second})
+
+	if _, err := s.ProcessTarotReadingStream(context.Background(), testReading); !errors.Is(err, ErrStreamTimeout) {
+		t.Fatalf("err = %v, want ErrStreamTimeout", err)
+	}
+}
+
+func TestStreamCompletes(t *testing.T) {
+	s := newStubService(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		writeEvent(w, `{"event":"text_chunk","data":{"text":"过去"}}`)
+		writeEvent(w, `{"event":"text_chunk","data":{"text":"未来"}}`)
+		writeEvent(w, `{"event":"workflow_finished","data":{"status":"succeeded"}}`)
+	}, Config{StreamTimeout: time.Second, StreamIdleTimeout: time.Second})
+
+	result, err := s.ProcessTarotReadingStream(context.Background(), testReading)
+	if err != nil || result != "过去未来" {
+		t.Fatalf("result=%q err=%v, want joined chunks", result, err)
+	}
+}
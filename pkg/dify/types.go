@@ -2,11 +2,75 @@ package dify
 
 import "time"
 
+// MaxCards 单次解读允许的最大卡牌数量，与解读请求及牌阵的校验一致
+const MaxCards = 10
+
+// 响应模式
+const (
+	ResponseModeBlocking  = "blocking"  // 阻塞模式，等待完整结果返回
+	ResponseModeStreaming = "streaming" // 流式模式，基于 SSE 逐块返回
+)
+
+// 应用类型
+const (
+	AppModeWorkflow = "workflow" // 工作流应用，每次解读相互独立
+	AppModeChat     = "chat"     // 对话应用，可通过 conversation_id 延续上下文
+)
+
 // DifyRequest 请求结构体
 type DifyRequest struct {
 	Inputs        map[string]interface{} `json:"inputs"`        // 改为 interface{} 类型以支持更灵活的输入
 	ResponseMode  string                 `json:"response_mode"` 
 	User          string                 `json:"user"`
+
+	// 以下字段仅对话应用使用
+	Query          string `json:"query,omitempty"`
+	ConversationID string `json:"conversation_id,omitempty"`
+}
+
+// ReadingInput 一次塔罗解读的输入
+type ReadingInput struct {
+	Question string
+	Cards    []int
+	Spread   string // 牌阵说明（名称与各位置含义），为空表示默认牌阵
+
+	// ConversationID 延续的 Dify 对话，为空时开始新对话，仅对话应用有效
+	ConversationID string
+
+	// OnChunk 流式模式下每收到一段文本时回调，可为空
+	OnChunk func(text string)
+	// OnConversation 收到 Dify 返回的对话 ID 时回调，可为空
+	OnConversation func(conversationID string)
+}
+
+// emit 回调流式文本片段
+func (in ReadingInput) emit(text string) {
+	if in.OnChunk != nil && text != "" {
+		in.OnChunk(text)
+	}
+}
+
+// conversation 回调 Dify 返回的对话 ID
+func (in ReadingInput) conversation(id string) {
+	if in.OnConversation != nil && id != "" {
+		in.OnConversation(id)
+	}
+}
+
+// inputs 构建工作流输入参数
+func (in ReadingInput) inputs() (map[string]interface{}, error) {
+	cards, err := formatCards(in.Cards)
+	if err != nil {
+		return nil, err
+	}
+	inputs := map[string]interface{}{
+		"question": in.Question,
+		"cards":    cards,
+	}
+	if in.Spread != "" {
+		inputs["spread"] = in.Spread
+	}
+	return inputs, nil
 }
 
 // DifyResponse 响应结构体
@@ -17,12 +81,51 @@ type DifyResponse struct {
 		Status string `json:"status"`
 	} `json:"task"`
 	Answer string `json:"answer"` // 对于非流式响应
+
+	ConversationID string `json:"conversation_id"` // 对话应用返回的对话 ID
+
+	// Data 工作流阻塞响应的执行结果
+	Data struct {
+		Status  string                 `json:"status"`  // 执行状态，成功为 succeeded
+		Error   string                 `json:"error"`   // 执行失败的错误信息
+		Outputs map[string]interface{} `json:"outputs"` // 工作流输出，解读文本在 text 中
+	} `json:"data"`
+}
+
+// StreamEvent 流式响应中的单个事件
+type StreamEvent struct {
+	Event   string `json:"event"`   // 事件类型
+	Answer  string `json:"answer"`  // message 事件的文本块
+	Message string `json:"message"` // error 事件的错误信息
+
+	ConversationID string `json:"conversation_id"` // 对话应用的对话 ID
+	Data    struct {
+		Text    string                 `json:"text"`    // text_chunk 事件的文本块
+		Status  string                 `json:"status"`  // workflow_finished 事件的执行状态
+		Error   string                 `json:"error"`   // workflow_finished 事件的错误信息
+		Outputs map[string]interface{} `json:"outputs"` // workflow_finished 事件的输出
+	} `json:"data"`
 }
 
 // Config Dify 服务配置
 type Config struct {
-	URLs       []string      // Dify 服务地址列表
-	APIKeys    []string      // API 密钥列表
-	Timeout    time.Duration // 请求超时时间
-	MaxRetries int          // 最大重试次数
+	URLs              []string      // Dify 服务地址列表
+	APIKeys           []string      // API 密钥列表
+	Weights           []string      // 实例权重，与地址一一对应，缺失或无效时为 1
+	Timeout           time.Duration // 请求超时时间
+	MaxRetries        int           // 最大重试次数
+	ResponseMode      string        // 响应模式：blocking / streaming
+	AppMode           string        // 应用类型：workflow / chat
+	RateLimit         float64       // 每秒最多发往 Dify 的请求数，<= 0 时不限速
+	RateBurst         int           // 限速的突发容量
+	Cooldown          time.Duration // 实例失败后暂不选择的时长，0 表示不冷却
+	ErrorThreshold    int           // 连续错误达到该次数时标记实例不健康，<= 0 时为 3
+	StreamTimeout     time.Duration // 流式响应的总时长上限
+	StreamIdleTimeout time.Duration // 流式响应无数据的最长等待时间
+	ProxyURL          string        // 访问 Dify 使用的 HTTP 代理，为空时不额外设置
+	CAFile            string        // 额外信任的 CA 证书文件（PEM），为空时只使用系统根证书
+
+	MaxIdleConnsPerHost int           // 每个实例保留的空闲连接数
+	IdleConnTimeout     time.Duration // 空闲连接的保留时长
+	KeepAlive           time.Duration // TCP keep-alive 探测间隔，< 0 时关闭
 } 
\ No newline at end of file
@@ -2,6 +2,7 @@
 package helpers
 
 import (
+	"crypto/rand"
 	"fmt"
 	"reflect"
 	"time"
@@ -37,3 +38,12 @@ func Empty(val interface{}) bool {
 func MicrosecondsStr(elapsed time.Duration) string {
 	return fmt.Sprintf("%.3fms", float64(elapsed.Nanoseconds())/1e6)
 }
+
+// UUID 生成随机的 UUID（v4），格式如 7c9e6679-7425-40de-944b-e07fc1f90ae7
+func UUID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	b[6] = (b[6] & 0x0f) | 0x40 // 版本 4
+	b[8] = (b[8] & 0x3f) | 0x80 // RFC 4122 变体
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
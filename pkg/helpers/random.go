@@ -0,0 +1,26 @@
+package helpers
+
+import (
+	"crypto/rand"
+	"math/big"
+)
+
+// RandomSource 随机数来源，*math/rand.Rand 也满足该接口，测试时可注入固定种子的实例
+type RandomSource interface {
+	// Intn 返回 [0, n) 范围内的随机整数
+	Intn(n int) int
+}
+
+// CryptoRandom 基于 crypto/rand 的随机数来源，可并发使用，生产环境默认使用
+var CryptoRandom RandomSource = cryptoRandom{}
+
+type cryptoRandom struct{}
+
+func (cryptoRandom) Intn(n int) int {
+	v, err := rand.Int(rand.Reader, big.NewInt(int64(n)))
+	if err != nil {
+		// crypto/rand 读取失败说明系统随机源不可用，无法安全地继续
+		panic(err)
+	}
+	return int(v.Int64())
+}
@@ -0,0 +1,66 @@
+package limiter
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// IPList IP 网段列表，用于限流白名单与黑名单匹配
+type IPList []*net.IPNet
+
+// ParseIPList 解析逗号分隔的 IP 或 CIDR 列表，单个 IP 视为 /32（IPv6 为 /128）
+// 无效的条目会被跳过并在 error 中汇总，其余条目照常返回
+func ParseIPList(list string) (IPList, error) {
+	var (
+		nets    IPList
+		invalid []string
+	)
+	for _, item := range strings.Split(list, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		if !strings.Contains(item, "/") {
+			ip := net.ParseIP(item)
+			if ip == nil {
+				invalid = append(invalid, item)
+				continue
+			}
+			bits := 128
+			if ip.To4() != nil {
+				ip = ip.To4()
+				bits = 32
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, ipNet, err := net.ParseCIDR(item)
+		if err != nil {
+			invalid = append(invalid, item)
+			continue
+		}
+		nets = append(nets, ipNet)
+	}
+
+	if len(invalid) > 0 {
+		return nets, fmt.Errorf("invalid ip or cidr: %s", strings.Join(invalid, ", "))
+	}
+	return nets, nil
+}
+
+// Contains 判断 IP 是否在列表中
+func (l IPList) Contains(ip string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, n := range l {
+		if n.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
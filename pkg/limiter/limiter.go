@@ -2,12 +2,16 @@
 package limiter
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"strconv"
 	"strings"
+	"sync"
 	"tarot/pkg/config"
 	"tarot/pkg/logger"
 	"tarot/pkg/redis"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	limiterlib "github.com/ulule/limiter/v3"
@@ -16,17 +20,16 @@ import (
 
 // Rate 定义限流速率
 type Rate struct {
-	Rate float64
+	Rate   float64       // 每秒速率
+	Limit  int64         // 每个时间窗口允许的请求数
+	Period time.Duration // 时间窗口长度
 }
 
 // ParseLimit 解析限流配置字符串
 // 支持的格式: "5-S"、"10-M"、"1000-H"、"2000-D"
 func ParseLimit(limit string) (*Rate, error) {
-	// 将 "5-S" 格式转换为 "5/S" 格式
-	formatted := strings.ReplaceAll(limit, "-", "/")
-
-	// 使用 limiterlib 解析
-	_, err := limiterlib.NewRateFromFormatted(formatted)
+	// 使用 limiterlib 校验格式（limiterlib 同样使用 "5-S" 格式）
+	formatted, err := limiterlib.NewRateFromFormatted(limit)
 	if err != nil {
 		return nil, fmt.Errorf("invalid limit format: %w", err)
 	}
@@ -57,7 +60,11 @@ func ParseLimit(limit string) (*Rate, error) {
 		return nil, fmt.Errorf("invalid time unit: %s", parts[1])
 	}
 
-	return &Rate{Rate: ratePerSecond}, nil
+	return &Rate{
+		Rate:   ratePerSecond,
+		Limit:  formatted.Limit,
+		Period: formatted.Period,
+	}, nil
 }
 
 // GetKeyIP 获取 Limitor 的 Key，IP
@@ -65,47 +72,122 @@ func GetKeyIP(c *gin.Context) string {
 	return c.ClientIP()
 }
 
+// GetKeyUser 获取 Limitor 的 Key，当前登录用户 ID，游客返回空字符串
+func GetKeyUser(c *gin.Context) string {
+	return c.GetString("user_id")
+}
+
+// GetKeyUserOrIP 获取 Limitor 的 Key，登录用户使用用户 ID，游客使用 IP
+// 游客的 Key 带有 ip: 前缀，避免与用户 ID 冲突
+func GetKeyUserOrIP(c *gin.Context) string {
+	if userID := GetKeyUser(c); userID != "" {
+		return userID
+	}
+	return "ip:" + GetKeyIP(c)
+}
+
 // GetKeyRouteWithIP Limitor 的 Key，路由+IP，针对单个路由做限流
 func GetKeyRouteWithIP(c *gin.Context) string {
 	return routeToKeyString(c.FullPath()) + c.ClientIP()
 }
 
-// CheckRate 检测请求是否超额
-func CheckRate(c *gin.Context, key string, formatted string) (limiterlib.Context, error) {
+// ErrStoreUnavailable Redis 未初始化，无法使用分布式限流
+var ErrStoreUnavailable = errors.New("limiter store unavailable")
 
-	// 实例化依赖的 limiter 包的 limiter.Rate 对象
-	var context limiterlib.Context
-	rate, err := limiterlib.NewRateFromFormatted(formatted)
-	if err != nil {
-		logger.LogIf(err)
-		return context, err
+var (
+	storeMu sync.Mutex
+	store   limiterlib.Store
+)
+
+// getStore 获取共享的 Redis 存储，所有实例共用同一份计数
+func getStore() (limiterlib.Store, error) {
+	storeMu.Lock()
+	defer storeMu.Unlock()
+
+	if store != nil {
+		return store, nil
+	}
+	if redis.Redis == nil {
+		return nil, ErrStoreUnavailable
 	}
 
 	// 初始化存储，使用我们程序里共用的 redis.Redis 对象
-	store, err := sredis.NewStoreWithOptions(redis.Redis.Client, limiterlib.StoreOptions{
+	s, err := sredis.NewStoreWithOptions(redis.Redis.Client, limiterlib.StoreOptions{
 		// 为 limiter 设置前缀，保持 redis 里数据的整洁
 		Prefix: config.GetString("app.name") + ":limiter",
 	})
 	if err != nil {
-		logger.LogIf(err)
-		return context, err
+		return nil, err
+	}
+	store = s
+	return store, nil
+}
+
+// newLimiter 根据限流格式创建基于 Redis 的限流器
+func newLimiter(formatted string) (*limiterlib.Limiter, error) {
+	rate, err := limiterlib.NewRateFromFormatted(formatted)
+	if err != nil {
+		return nil, err
 	}
+	s, err := getStore()
+	if err != nil {
+		return nil, err
+	}
+	return limiterlib.New(s, rate), nil
+}
 
-	// 使用上面的初始化的 limiter.Rate 对象和存储对象
-	limiterObj := limiterlib.New(store, rate)
+// Peek 查询 key 当前的限流状态，不增加访问次数
+func Peek(ctx context.Context, key string, formatted string) (limiterlib.Context, error) {
+	return PeekWith(ctx, FixedWindow, key, formatted)
+}
+
+// Take 增加一次访问次数并返回限流状态
+func Take(ctx context.Context, key string, formatted string) (limiterlib.Context, error) {
+	return TakeWith(ctx, FixedWindow, key, formatted)
+}
+
+// PeekWith 按指定算法查询 key 当前的限流状态，不增加访问次数
+func PeekWith(ctx context.Context, algorithm Algorithm, key string, formatted string) (limiterlib.Context, error) {
+	if algorithm == SlidingWindow {
+		return slidingWindow(ctx, key, formatted, false)
+	}
+	lim, err := newLimiter(formatted)
+	if err != nil {
+		return limiterlib.Context{}, err
+	}
+	return lim.Peek(ctx, key)
+}
 
+// TakeWith 按指定算法增加一次访问次数并返回限流状态
+// 滑动窗口下超限的请求不计入次数
+func TakeWith(ctx context.Context, algorithm Algorithm, key string, formatted string) (limiterlib.Context, error) {
+	if algorithm == SlidingWindow {
+		return slidingWindow(ctx, key, formatted, true)
+	}
+	lim, err := newLimiter(formatted)
+	if err != nil {
+		return limiterlib.Context{}, err
+	}
+	return lim.Get(ctx, key)
+}
+
+// CheckRate 检测请求是否超额
+func CheckRate(c *gin.Context, key string, formatted string) (limiterlib.Context, error) {
 	// 获取限流的结果
 	if c.GetBool("limiter-once") {
 		// Peek() 取结果，不增加访问次数
-		return limiterObj.Peek(c, key)
-	} else {
+		result, err := Peek(c, key, formatted)
+		logger.LogIf(err)
+		return result, err
+	}
 
-		// 确保多个路由组里调用 LimitIP 进行限流时，只增加一次访问次数。
-		c.Set("limiter-once", true)
+	// 确保多个路由组里调用 LimitIP 进行限流时，只增加一次访问次数。
+	c.Set("limiter-once", true)
 
-		// Get() 取结果且增加访问次数
-		return limiterObj.Get(c, key)
-	}
+	// Get() 取结果且增加访问次数
+	result, err := Take(c, key, formatted)
+	logger.LogIf(err)
+	return result, err
 }
 
 // routeToKeyString 辅助方法，将 URL 中的 / 格式为 -
@@ -0,0 +1,95 @@
+package limiter
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+	limiterlib "github.com/ulule/limiter/v3"
+
+	"tarot/pkg/config"
+	"tarot/pkg/redis"
+)
+
+// Algorithm 分布式限流算法
+type Algorithm string
+
+const (
+	// FixedWindow 固定窗口计数（默认），窗口交界处短时间内最多可通过两倍额度
+	FixedWindow Algorithm = ""
+	// SlidingWindow 滑动窗口计数，任意长度为一个时间窗口的区间内都不超过额度
+	SlidingWindow Algorithm = "sliding"
+)
+
+// slidingWindowScript 滑动窗口限流：有序集合保存窗口内每次请求的时间（毫秒），先移除窗口外的记录再计数
+// 被拒绝的请求不计入，额度耗尽时只能等最早的请求移出窗口
+// KEYS: 计数 key
+// ARGV: 当前时间, 窗口长度, 额度, 请求标识, 是否计入（1 计入，0 仅查询）
+// 返回: 窗口内请求数, 是否超限, 最早的请求移出窗口的时间
+var slidingWindowScript = goredis.NewScript(`
+local now = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local limit = tonumber(ARGV[3])
+redis.call("zremrangebyscore", KEYS[1], "-inf", now - window)
+local count = redis.call("zcard", KEYS[1])
+local reached = 0
+if ARGV[5] == "1" then
+	if count < limit then
+		redis.call("zadd", KEYS[1], now, ARGV[4])
+		redis.call("pexpire", KEYS[1], window)
+		count = count + 1
+	else
+		reached = 1
+	end
+end
+local reset = now + window
+local oldest = redis.call("zrange", KEYS[1], 0, 0, "WITHSCORES")
+if oldest[2] then
+	reset = tonumber(oldest[2]) + window
+end
+return {count, reached, reset}
+`)
+
+// slidingWindow 执行滑动窗口限流，take 为 false 时只查询不计入
+func slidingWindow(ctx context.Context, key, formatted string, take bool) (limiterlib.Context, error) {
+	rate, err := limiterlib.NewRateFromFormatted(formatted)
+	if err != nil {
+		return limiterlib.Context{}, err
+	}
+	return SlidingRate(ctx, key, rate, take)
+}
+
+// SlidingRate 按任意窗口长度执行滑动窗口限流，take 为 false 时只查询不计入
+// 用于 "N-S" 等格式无法表示的窗口，例如令牌桶突发数量对应的窗口
+func SlidingRate(ctx context.Context, key string, rate limiterlib.Rate, take bool) (limiterlib.Context, error) {
+	if rate.Limit <= 0 || rate.Period <= 0 {
+		return limiterlib.Context{}, fmt.Errorf("invalid sliding rate: %d per %s", rate.Limit, rate.Period)
+	}
+	if redis.Redis == nil {
+		return limiterlib.Context{}, ErrStoreUnavailable
+	}
+
+	now := time.Now()
+	flag := "0"
+	if take {
+		flag = "1"
+	}
+	key = config.GetString("app.name") + ":limiter:sliding:" + key
+	member := fmt.Sprintf("%d-%d", now.UnixNano(), rand.Int63())
+
+	values, err := slidingWindowScript.Run(ctx, redis.Redis.Client, []string{key},
+		now.UnixMilli(), rate.Period.Milliseconds(), rate.Limit, member, flag).Int64Slice()
+	if err != nil {
+		return limiterlib.Context{}, err
+	}
+
+	count, reached, resetMs := values[0], values[1] == 1, values[2]
+	return limiterlib.Context{
+		Limit:     rate.Limit,
+		Remaining: max(rate.Limit-count, 0),
+		Reset:     (resetMs + 999) / 1000,
+		Reached:   reached,
+	}, nil
+}
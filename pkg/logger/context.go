@@ -0,0 +1,51 @@
+package logger
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+)
+
+type requestIDKey struct{}
+
+// WithRequestID 将请求 ID 写入 context，之后通过 *Context 系列方法记录的日志都会带上该 ID
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	if requestID == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// RequestID 从 context 中读取请求 ID，不存在时返回空字符串
+func RequestID(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// FromContext 返回携带请求 ID 字段的 Logger，调用示例：
+//
+//	logger.FromContext(ctx).Info("Worker", zap.String("task_id", task.ID))
+func FromContext(ctx context.Context) *zap.Logger {
+	if id := RequestID(ctx); id != "" {
+		return Logger.With(zap.String("request_id", id))
+	}
+	return Logger
+}
+
+// InfoStringContext 同 InfoString，附带 context 中的请求 ID
+func InfoStringContext(ctx context.Context, moduleName, name, msg string) {
+	FromContext(ctx).Info(moduleName, zap.String(name, msg))
+}
+
+// WarnStringContext 同 WarnString，附带 context 中的请求 ID
+func WarnStringContext(ctx context.Context, moduleName, name, msg string) {
+	FromContext(ctx).Warn(moduleName, zap.String(name, msg))
+}
+
+// ErrorStringContext 同 ErrorString，附带 context 中的请求 ID
+func ErrorStringContext(ctx context.Context, moduleName, name, msg string) {
+	FromContext(ctx).Error(moduleName, zap.String(name, msg))
+}
@@ -0,0 +1,67 @@
+package logger
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// 结构化日志的常用字段，按字段名即可检索同一任务、实例的全部日志
+const (
+	FieldEvent     = "event"
+	FieldTaskID    = "task_id"
+	FieldWorkerID  = "worker_id"
+	FieldInstance  = "instance"
+	FieldLatencyMs = "latency_ms"
+	FieldAttempt   = "attempt"
+)
+
+// TaskID 任务 ID 字段
+func TaskID(id string) zap.Field {
+	return zap.String(FieldTaskID, id)
+}
+
+// WorkerID 工作器 ID 字段
+func WorkerID(id int) zap.Field {
+	return zap.Int(FieldWorkerID, id)
+}
+
+// Instance 上游实例地址字段
+func Instance(url string) zap.Field {
+	return zap.String(FieldInstance, url)
+}
+
+// Latency 耗时字段，单位毫秒
+func Latency(d time.Duration) zap.Field {
+	return zap.Float64(FieldLatencyMs, float64(d.Microseconds())/1000)
+}
+
+// Attempt 第几次尝试，从 1 开始
+func Attempt(n int) zap.Field {
+	return zap.Int(FieldAttempt, n)
+}
+
+// InfoContext 记录结构化的 info 日志，附带 context 中的请求 ID，调用示例：
+//
+//	logger.InfoContext(ctx, "Worker", "Success", logger.TaskID(task.ID), logger.Latency(d))
+//
+// 与 InfoString 的区别是各项信息作为独立字段输出，而不是拼接在一个字符串中
+func InfoContext(ctx context.Context, moduleName, event string, fields ...zap.Field) {
+	FromContext(ctx).Info(moduleName, withEvent(event, fields)...)
+}
+
+// WarnContext 记录结构化的 warn 日志，附带 context 中的请求 ID
+func WarnContext(ctx context.Context, moduleName, event string, fields ...zap.Field) {
+	FromContext(ctx).Warn(moduleName, withEvent(event, fields)...)
+}
+
+// ErrorContext 记录结构化的 error 日志，附带 context 中的请求 ID
+func ErrorContext(ctx context.Context, moduleName, event string, fields ...zap.Field) {
+	FromContext(ctx).Error(moduleName, withEvent(event, fields)...)
+}
+
+// withEvent 在字段前加上事件名
+func withEvent(event string, fields []zap.Field) []zap.Field {
+	return append([]zap.Field{zap.String(FieldEvent, event)}, fields...)
+}
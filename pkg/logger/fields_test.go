@@ -0,0 +1,87 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// captureJSON 将 Logger 替换为输出到缓冲区的 JSON 日志，测试结束后恢复
+func captureJSON(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	encoder := zapcore.NewJSONEncoder(zapcore.EncoderConfig{MessageKey: "message", LevelKey: "level", EncodeLevel: zapcore.CapitalLevelEncoder})
+	prev := Logger
+	Logger = zap.New(zapcore.NewCore(encoder, zapcore.AddSync(&buf), zapcore.DebugLevel))
+	t.Cleanup(func() { Logger = prev })
+	return &buf
+}
+
+// decodeLine 解析一行 JSON 日志
+func decodeLine(t *testing.T, line string) map[string]interface{} {
+	t.Helper()
+	var entry map[string]interface{}
+	if err := json.Unmarshal([]byte(line), &entry); err != nil {
+		t.Fatalf("decode log line %q: %v", line, err)
+	}
+	return entry
+}
+
+func TestContextLoggingWritesStructuredFields(t *testing.T) {
+	buf := captureJSON(t)
+	ctx := WithRequestID(context.Background(), "req-1")
+
+	InfoContext(ctx, "Worker", "Success",
+		TaskID("task-1"), WorkerID(3), Instance("dify-1.example.com"), Latency(1500*time.Microsecond), Attempt(2))
+	ErrorContext(context.Background(), "Dify", "Error", zap.Error(errors.New("timeout")))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d log lines, want 2: %s", len(lines), buf.String())
+	}
+
+	entry := decodeLine(t, lines[0])
+	want := map[string]interface{}{
+		"level":        "INFO",
+		"message":      "Worker",
+		FieldEvent:     "Success",
+		"request_id":   "req-1",
+		FieldTaskID:    "task-1",
+		FieldWorkerID:  float64(3),
+		FieldInstance:  "dify-1.example.com",
+		FieldLatencyMs: 1.5,
+		FieldAttempt:   float64(2),
+	}
+	for key, value := range want {
+		if entry[key] != value {
+			t.Errorf("%s = %v, want %v", key, entry[key], value)
+		}
+	}
+
+	// 没有请求 ID 时不输出空字段
+	entry = decodeLine(t, lines[1])
+	if _, ok := entry["request_id"]; ok {
+		t.Errorf("request_id = %v, want it omitted without a request", entry["request_id"])
+	}
+	if entry["level"] != "ERROR" || entry[FieldEvent] != "Error" || entry["error"] != "timeout" {
+		t.Errorf("error entry = %v", entry)
+	}
+}
+
+func TestStringHelpersStillWork(t *testing.T) {
+	buf := captureJSON(t)
+
+	WarnStringContext(WithRequestID(context.Background(), "req-2"), "Queue", "Full", "队列已满")
+
+	entry := decodeLine(t, strings.TrimSpace(buf.String()))
+	if entry["message"] != "Queue" || entry["Full"] != "队列已满" || entry["request_id"] != "req-2" {
+		t.Fatalf("entry = %v", entry)
+	}
+}
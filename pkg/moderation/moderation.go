@@ -0,0 +1,76 @@
+// Package moderation 在问题提交给 AI 之前进行内容审核
+package moderation
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Verdict 审核结果
+type Verdict struct {
+	Blocked bool
+	Reason  string // 命中的规则，仅用于日志，不返回给客户端
+}
+
+// Moderator 内容审核器
+// 返回 error 表示审核服务本身不可用，调用方自行决定是否放行
+type Moderator interface {
+	Check(ctx context.Context, text string) (Verdict, error)
+}
+
+// Default 全局审核器，默认不审核，在 bootstrap 中按配置替换
+var Default Moderator = Noop{}
+
+// Noop 不做任何审核
+type Noop struct{}
+
+// Check 始终放行
+func (Noop) Check(context.Context, string) (Verdict, error) {
+	return Verdict{}, nil
+}
+
+// KeywordModerator 基于关键词黑名单与正则表达式的审核器
+type KeywordModerator struct {
+	words   []string
+	pattern *regexp.Regexp
+}
+
+// NewKeywordModerator 创建关键词审核器，关键词匹配不区分大小写
+// pattern 为空时只按关键词匹配
+func NewKeywordModerator(words []string, pattern string) (*KeywordModerator, error) {
+	m := &KeywordModerator{}
+	for _, word := range words {
+		if word = strings.TrimSpace(word); word != "" {
+			m.words = append(m.words, strings.ToLower(word))
+		}
+	}
+	if pattern != "" {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid moderation pattern: %w", err)
+		}
+		m.pattern = re
+	}
+	return m, nil
+}
+
+// Words 生效的关键词
+func (m *KeywordModerator) Words() []string {
+	return m.words
+}
+
+// Check 命中任一关键词或正则时拦截
+func (m *KeywordModerator) Check(_ context.Context, text string) (Verdict, error) {
+	lower := strings.ToLower(text)
+	for _, word := range m.words {
+		if strings.Contains(lower, word) {
+			return Verdict{Blocked: true, Reason: "keyword: " + word}, nil
+		}
+	}
+	if m.pattern != nil && m.pattern.MatchString(text) {
+		return Verdict{Blocked: true, Reason: "pattern: " + m.pattern.String()}, nil
+	}
+	return Verdict{}, nil
+}
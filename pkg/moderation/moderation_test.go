@@ -0,0 +1,51 @@
+package moderation
+
+import (
+	"context"
+	"testing"
+)
+
+func TestKeywordModeratorBlocksPhrase(t *testing.T) {
+	m, err := NewKeywordModerator([]string{" 赌博 ", "Forbidden", ""}, `\d{11}`)
+	if err != nil {
+		t.Fatalf("NewKeywordModerator: %v", err)
+	}
+	if words := m.Words(); len(words) != 2 {
+		t.Fatalf("words = %v, want trimmed non-empty keywords", words)
+	}
+
+	for _, question := range []string{
+		"我想知道去赌博会不会赢钱？",
+		"Is this FORBIDDEN topic ok?",
+		"请帮我看看 13800138000 这个号码",
+	} {
+		verdict, err := m.Check(context.Background(), question)
+		if err != nil || !verdict.Blocked || verdict.Reason == "" {
+			t.Fatalf("Check(%q) = %+v, %v, want blocked", question, verdict, err)
+		}
+	}
+}
+
+func TestKeywordModeratorAllowsCleanQuestion(t *testing.T) {
+	m, err := NewKeywordModerator([]string{"赌博"}, `\d{11}`)
+	if err != nil {
+		t.Fatalf("NewKeywordModerator: %v", err)
+	}
+
+	verdict, err := m.Check(context.Background(), "我最近的事业运势如何？")
+	if err != nil || verdict.Blocked {
+		t.Fatalf("Check = %+v, %v, want allowed", verdict, err)
+	}
+}
+
+func TestNewKeywordModeratorRejectsInvalidPattern(t *testing.T) {
+	if _, err := NewKeywordModerator(nil, "("); err == nil {
+		t.Fatal("invalid pattern accepted")
+	}
+}
+
+func TestNoopAllowsEverything(t *testing.T) {
+	if verdict, err := (Noop{}).Check(context.Background(), "赌博"); err != nil || verdict.Blocked {
+		t.Fatalf("Noop Check = %+v, %v", verdict, err)
+	}
+}
@@ -3,6 +3,8 @@ package alipay
 import (
 	"context"
 	"fmt"
+	"net/http"
+	"net/url"
 	"time"
 	
 	"github.com/smartwalle/alipay/v3"
@@ -10,11 +12,22 @@ import (
 	"tarot/app/models/payment"
 	"tarot/config"
 	"tarot/pkg/payment/types"
+	"tarot/pkg/payment/utils"
 )
 
+// alipayAPI 服务用到的支付宝接口，*alipay.Client 满足该接口，测试时以桩实现替换
+type alipayAPI interface {
+	TradePagePay(param alipay.TradePagePay) (*url.URL, error)
+	TradeClose(ctx context.Context, param alipay.TradeClose) (*alipay.TradeCloseRsp, error)
+	TradeQuery(ctx context.Context, param alipay.TradeQuery) (*alipay.TradeQueryRsp, error)
+	TradeRefund(ctx context.Context, param alipay.TradeRefund) (*alipay.TradeRefundRsp, error)
+	TradeFastPayRefundQuery(ctx context.Context, param alipay.TradeFastPayRefundQuery) (*alipay.TradeFastPayRefundQueryRsp, error)
+	DecodeNotification(values url.Values) (*alipay.Notification, error)
+}
+
 // AlipayService 支付宝支付服务
 type AlipayService struct {
-	client     *alipay.Client
+	client     alipayAPI
 	appID      string
 	notifyURL  string
 	returnURL  string
@@ -43,7 +56,7 @@ func NewAlipayService(config config.AlipayConfig, repo types.Repository) (*Alipa
 
 // CreatePayment 创建支付
 func (s *AlipayService) CreatePayment(ctx context.Context, req *types.Request) (*types.Result, error) {
-	orderNo := GenerateOrderNo()
+	orderNo := utils.GenerateOrderNo()
 	expireAt := time.Now().Add(30 * time.Minute)
 	
 	p := &payment.Payment{
@@ -56,6 +69,11 @@ func (s *AlipayService) CreatePayment(ctx context.Context, req *types.Request) (
 			ExpireAt:  &expireAt,
 	}
 	
+	// 金额等不合法时直接返回，不落库也不请求支付宝
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+
 	if err := s.repository.Create(ctx, p); err != nil {
 		return nil, fmt.Errorf("create payment record error: %w", err)
 	}
@@ -80,27 +98,199 @@ func (s *AlipayService) CreatePayment(ctx context.Context, req *types.Request) (
 	}, nil
 }
 
-// GenerateOrderNo 生成订单号
-func GenerateOrderNo() string {
-	return fmt.Sprintf("%d%06d", time.Now().Unix(), time.Now().Nanosecond()/1000)
-}
-
 // 实现 Service 接口的所有方法
+// CancelPayment 关闭未支付订单
 func (s *AlipayService) CancelPayment(ctx context.Context, orderNo string) error {
-	// 实现取消支付逻辑
+	p, err := s.repository.GetByOrderNo(ctx, orderNo)
+	if err != nil {
+		return fmt.Errorf("get payment record error: %w", err)
+	}
+	if p.IsCanceled() {
+		return nil
+	}
+	if err := p.CheckCancel(); err != nil {
+		return err
+	}
+
+	// 调用支付宝关单 API
+	// 用户未扫码时支付宝侧尚未创建交易，返回 ACQ.TRADE_NOT_EXIST，可视为已关闭
+	rsp, err := s.client.TradeClose(ctx, alipay.TradeClose{OutTradeNo: orderNo})
+	if err != nil {
+		return fmt.Errorf("close alipay order error: %w", err)
+	}
+	if rsp.IsFailure() && rsp.SubCode != "ACQ.TRADE_NOT_EXIST" {
+		return fmt.Errorf("close alipay order error: %s: %s", rsp.SubCode, rsp.SubMsg)
+	}
+
+	p.Status = string(types.StatusCanceled)
+	if err := s.repository.Update(ctx, p); err != nil {
+		return fmt.Errorf("update payment record error: %w", err)
+	}
 	return nil
 }
 
+// QueryPayment 查询支付订单
+// 待支付且未过期的订单会主动查询支付宝并同步状态，用于补偿未送达的通知
 func (s *AlipayService) QueryPayment(ctx context.Context, orderNo string) (*payment.Payment, error) {
-	return s.repository.GetByOrderNo(ctx, orderNo)
+	p, err := s.repository.GetByOrderNo(ctx, orderNo)
+	if err != nil {
+		return nil, fmt.Errorf("get payment record error: %w", err)
+	}
+
+	if !p.IsPending() || p.IsExpired() {
+		return p, nil
+	}
+
+	rsp, err := s.client.TradeQuery(ctx, alipay.TradeQuery{OutTradeNo: orderNo})
+	if err != nil {
+		return nil, fmt.Errorf("query alipay payment error: %w", err)
+	}
+	// 用户尚未扫码时支付宝不会创建交易，视为仍待支付
+	if rsp.IsFailure() {
+		if rsp.SubCode == "ACQ.TRADE_NOT_EXIST" {
+			return p, nil
+		}
+		return nil, fmt.Errorf("query alipay payment failed: %s %s", rsp.SubCode, rsp.SubMsg)
+	}
+
+	if err := s.applyTrade(ctx, p, rsp.TradeStatus, rsp.TradeNo, rsp.SendPayDate); err != nil {
+		return nil, err
+	}
+	return p, nil
 }
 
-func (s *AlipayService) HandleNotify(ctx context.Context, data []byte) error {
-	// 实现支付通知处理逻辑
+// HandleNotify 处理支付结果通知
+// 支付宝以表单形式推送，验签覆盖全部表单字段
+func (s *AlipayService) HandleNotify(ctx context.Context, header http.Header, body []byte) error {
+	values, err := url.ParseQuery(string(body))
+	if err != nil {
+		return fmt.Errorf("parse alipay notify error: %w", err)
+	}
+
+	notification, err := s.client.DecodeNotification(values)
+	if err != nil {
+		return fmt.Errorf("verify alipay notify error: %w", err)
+	}
+
+	p, err := s.repository.GetByOrderNo(ctx, notification.OutTradeNo)
+	if err != nil {
+		return fmt.Errorf("get payment record error: %w", err)
+	}
+
+	return s.applyTrade(ctx, p, notification.TradeStatus, notification.TradeNo, notification.GmtPayment)
+}
+
+// applyTrade 根据支付宝交易状态更新订单，重复或非成功状态不做处理
+func (s *AlipayService) applyTrade(ctx context.Context, p *payment.Payment, status alipay.TradeStatus, tradeNo, paidAt string) error {
+	if p.IsSuccess() || p.IsRefunded() {
+		return nil
+	}
+	if status != alipay.TradeStatusSuccess && status != alipay.TradeStatusFinished {
+		return nil
+	}
+
+	payAt := time.Now()
+	if paidAt != "" {
+		if t, err := time.ParseInLocation(time.DateTime, paidAt, time.Local); err == nil {
+			payAt = t
+		}
+	}
+
+	p.MarkPaid(tradeNo, payAt)
+	if err := s.repository.CompletePayment(ctx, p); err != nil {
+		return fmt.Errorf("complete payment error: %w", err)
+	}
 	return nil
 }
 
+// RefundPayment 申请退款，支持多次部分退款
+// 同一订单的退款持有退款锁串行执行，校验额度与累加已退金额之间不会有其他退款插入
 func (s *AlipayService) RefundPayment(ctx context.Context, orderNo string, amount int64, reason string) error {
-	// 实现退款逻辑
+	return utils.WithRefundLock(orderNo, func() error {
+		return s.refund(ctx, orderNo, amount, reason)
+	})
+}
+
+// refund 校验退款额度、保存退款记录并调用支付宝退款 API，需持有退款锁
+func (s *AlipayService) refund(ctx context.Context, orderNo string, amount int64, reason string) error {
+	// 1. 查询支付记录并校验退款金额，处理中的退款同样占用可退金额
+	p, err := s.repository.GetByOrderNo(ctx, orderNo)
+	if err != nil {
+		return fmt.Errorf("get payment record error: %w", err)
+	}
+	processing, err := s.repository.ProcessingRefundAmount(ctx, p.ID)
+	if err != nil {
+		return fmt.Errorf("get processing refunds error: %w", err)
+	}
+	if err := p.CheckRefund(amount, processing); err != nil {
+		return err
+	}
+
+	// 2. 先保存处理中的退款记录，再调用支付宝退款 API，部分退款必须携带 OutRequestNo
+	refund := &payment.Refund{
+		RefundNo:  "R" + utils.GenerateOrderNo(),
+		PaymentID: p.ID,
+		OrderNo:   orderNo,
+		Provider:  string(types.ProviderAlipay),
+		Amount:    amount,
+		Reason:    reason,
+		Status:    string(payment.RefundStatusProcessing),
+	}
+	if err := s.repository.CreateRefund(ctx, refund); err != nil {
+		return fmt.Errorf("create refund record error: %w", err)
+	}
+
+	rsp, err := s.client.TradeRefund(ctx, alipay.TradeRefund{
+		OutTradeNo:   orderNo,
+		RefundAmount: fmt.Sprintf("%.2f", float64(amount)/100),
+		RefundReason: reason,
+		OutRequestNo: refund.RefundNo,
+	})
+	if err == nil && rsp.IsFailure() {
+		err = fmt.Errorf("%s: %s", rsp.SubCode, rsp.SubMsg)
+	}
+	if err != nil {
+		refund.Status = string(payment.RefundStatusFailed)
+		if updateErr := s.repository.UpdateRefund(ctx, refund); updateErr != nil {
+			return fmt.Errorf("update refund record error: %w", updateErr)
+		}
+		return fmt.Errorf("alipay refund error: %w", err)
+	}
+
+	// 3. 支付宝退款接口同步返回结果，成功即累加已退金额
+	refund.ProviderRefundID = rsp.TradeNo
+	if err := s.repository.FinishRefund(ctx, refund); err != nil {
+		return fmt.Errorf("finish refund error: %w", err)
+	}
 	return nil
-} 
\ No newline at end of file
+}
+
+// SyncRefund 查询处理中的退款（如记账失败遗留的记录），支付宝未返回退款成功时标记为失败
+func (s *AlipayService) SyncRefund(ctx context.Context, refund *payment.Refund) error {
+	return utils.WithRefundLock(refund.OrderNo, func() error {
+		rsp, err := s.client.TradeFastPayRefundQuery(ctx, alipay.TradeFastPayRefundQuery{
+			OutTradeNo:   refund.OrderNo,
+			OutRequestNo: refund.RefundNo,
+		})
+		if err == nil && rsp.IsFailure() {
+			err = fmt.Errorf("%s: %s", rsp.SubCode, rsp.SubMsg)
+		}
+		if err != nil {
+			return fmt.Errorf("query alipay refund error: %w", err)
+		}
+
+		if rsp.RefundStatus == "REFUND_SUCCESS" {
+			refund.ProviderRefundID = rsp.TradeNo
+			if err := s.repository.FinishRefund(ctx, refund); err != nil {
+				return fmt.Errorf("finish refund error: %w", err)
+			}
+			return nil
+		}
+
+		refund.Status = string(payment.RefundStatusFailed)
+		if err := s.repository.UpdateRefund(ctx, refund); err != nil {
+			return fmt.Errorf("update refund record error: %w", err)
+		}
+		return nil
+	})
+}
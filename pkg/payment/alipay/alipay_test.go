@@ -0,0 +1,219 @@
+package alipay
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/smartwalle/alipay/v3"
+
+	"tarot/app/models/payment"
+	"tarot/app/models/reading"
+	"tarot/app/repositories"
+	"tarot/pkg/database"
+	"tarot/pkg/payment/types"
+	"tarot/pkg/testutil"
+)
+
+// stubClient 记录调用并返回预设结果的支付宝接口
+type stubClient struct {
+	mu         sync.Mutex
+	refundCode alipay.Code
+	refunds    []alipay.TradeRefund
+	closed     []string
+	queryRsp   *alipay.TradeQueryRsp
+	pages      int
+}
+
+func (s *stubClient) TradePagePay(param alipay.TradePagePay) (*url.URL, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pages++
+	return url.Parse("https://openapi.alipay.com/gateway.do?out_trade_no=" + param.OutTradeNo)
+}
+
+func (s *stubClient) TradeClose(ctx context.Context, param alipay.TradeClose) (*alipay.TradeCloseRsp, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = append(s.closed, param.OutTradeNo)
+	return &alipay.TradeCloseRsp{Error: alipay.Error{Code: alipay.CodeSuccess}}, nil
+}
+
+func (s *stubClient) TradeQuery(ctx context.Context, param alipay.TradeQuery) (*alipay.TradeQueryRsp, error) {
+	return s.queryRsp, nil
+}
+
+func (s *stubClient) TradeRefund(ctx context.Context, param alipay.TradeRefund) (*alipay.TradeRefundRsp, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.refunds = append(s.refunds, param)
+	code := s.refundCode
+	if code == "" {
+		code = alipay.CodeSuccess
+	}
+	return &alipay.TradeRefundRsp{
+		Error:   alipay.Error{Code: code, SubCode: "ACQ.SYSTEM_ERROR"},
+		TradeNo: "ali-trade",
+	}, nil
+}
+
+func (s *stubClient) TradeFastPayRefundQuery(ctx context.Context, param alipay.TradeFastPayRefundQuery) (*alipay.TradeFastPayRefundQueryRsp, error) {
+	return &alipay.TradeFastPayRefundQueryRsp{
+		Error:        alipay.Error{Code: alipay.CodeSuccess},
+		TradeNo:      "ali-trade",
+		RefundStatus: "REFUND_SUCCESS",
+	}, nil
+}
+
+func (s *stubClient) DecodeNotification(values url.Values) (*alipay.Notification, error) {
+	return nil, errors.New("not used")
+}
+
+func newTestService(t *testing.T, client *stubClient) *AlipayService {
+	t.Helper()
+	testutil.SetupDB(t, &payment.Payment{}, &payment.Refund{}, &payment.PaymentEvent{}, &reading.Reading{})
+	testutil.SetupRedis(t)
+	return &AlipayService{client: client, repository: repositories.NewPaymentRepository()}
+}
+
+func createPayment(t *testing.T, status payment.Status, amount int64) *payment.Payment {
+	t.Helper()
+	now := time.Now()
+	p := &payment.Payment{
+		OrderNo:  "A" + now.Format("150405.000000000"),
+		UserID:   "user-1",
+		Provider: string(payment.ProviderAlipay),
+		Amount:   amount,
+		Status:   string(status),
+	}
+	if status == payment.StatusPaid {
+		p.PayAt = &now
+	}
+	if err := database.DB.Create(p).Error; err != nil {
+		t.Fatalf("create payment: %v", err)
+	}
+	return p
+}
+
+func reload(t *testing.T, orderNo string) *payment.Payment {
+	t.Helper()
+	p, err := repositories.NewPaymentRepository().GetByOrderNo(context.Background(), orderNo)
+	if err != nil {
+		t.Fatalf("reload payment: %v", err)
+	}
+	return p
+}
+
+func TestRefundPaymentFullAndPartial(t *testing.T) {
+	client := &stubClient{}
+	s := newTestService(t, client)
+	p := createPayment(t, payment.StatusPaid, 2000)
+	ctx := context.Background()
+
+	if err := s.RefundPayment(ctx, p.OrderNo, 600, "partial"); err != nil {
+		t.Fatalf("partial refund: %v", err)
+	}
+	got := reload(t, p.OrderNo)
+	if got.Status != string(payment.StatusPaid) || got.RefundedAmount != 600 {
+		t.Fatalf("after partial: status=%s refunded=%d", got.Status, got.RefundedAmount)
+	}
+	if client.refunds[0].RefundAmount != "6.00" || client.refunds[0].OutRequestNo == "" {
+		t.Fatalf("unexpected refund request: %+v", client.refunds[0])
+	}
+
+	if err := s.RefundPayment(ctx, p.OrderNo, 1400, "rest"); err != nil {
+		t.Fatalf("full refund: %v", err)
+	}
+	got = reload(t, p.OrderNo)
+	if got.Status != string(payment.StatusRefunded) || got.RefundedAmount != 2000 {
+		t.Fatalf("after full: status=%s refunded=%d", got.Status, got.RefundedAmount)
+	}
+}
+
+func TestRefundPaymentRejectsOverRefund(t *testing.T) {
+	client := &stubClient{}
+	s := newTestService(t, client)
+	p := createPayment(t, payment.StatusPaid, 2000)
+
+	if err := s.RefundPayment(context.Background(), p.OrderNo, 2001, "over"); !errors.Is(err, payment.ErrInvalidRefundAmount) {
+		t.Fatalf("err = %v, want ErrInvalidRefundAmount", err)
+	}
+	if len(client.refunds) != 0 {
+		t.Fatalf("alipay called %d times for a rejected refund", len(client.refunds))
+	}
+}
+
+func TestRefundPaymentUnpaidNotRefundable(t *testing.T) {
+	s := newTestService(t, &stubClient{})
+	p := createPayment(t, payment.StatusPending, 2000)
+
+	if err := s.RefundPayment(context.Background(), p.OrderNo, 100, "x"); !errors.Is(err, payment.ErrNotRefundable) {
+		t.Fatalf("err = %v, want ErrNotRefundable", err)
+	}
+}
+
+func TestRefundPaymentFailureNotApplied(t *testing.T) {
+	client := &stubClient{refundCode: "40004"}
+	s := newTestService(t, client)
+	p := createPayment(t, payment.StatusPaid, 2000)
+
+	if err := s.RefundPayment(context.Background(), p.OrderNo, 2000, "x"); err == nil {
+		t.Fatal("expected alipay failure")
+	}
+	if got := reload(t, p.OrderNo); got.RefundedAmount != 0 || got.Status != string(payment.StatusPaid) {
+		t.Fatalf("failed refund applied: status=%s refunded=%d", got.Status, got.RefundedAmount)
+	}
+
+	// 失败的退款不占用可退金额
+	client.refundCode = ""
+	if err := s.RefundPayment(context.Background(), p.OrderNo, 2000, "retry"); err != nil {
+		t.Fatalf("retry refund: %v", err)
+	}
+}
+
+func TestCancelPayment(t *testing.T) {
+	client := &stubClient{}
+	s := newTestService(t, client)
+	pending := createPayment(t, payment.StatusPending, 2000)
+	paid := createPayment(t, payment.StatusPaid, 2000)
+
+	if err := s.CancelPayment(context.Background(), paid.OrderNo); !errors.Is(err, payment.ErrNotCancelable) {
+		t.Fatalf("cancel paid err = %v, want ErrNotCancelable", err)
+	}
+	if err := s.CancelPayment(context.Background(), pending.OrderNo); err != nil {
+		t.Fatalf("cancel pending: %v", err)
+	}
+
+	if len(client.closed) != 1 || client.closed[0] != pending.OrderNo {
+		t.Fatalf("closed = %v, want only the pending order", client.closed)
+	}
+	if got := reload(t, paid.OrderNo); got.Status != string(payment.StatusPaid) {
+		t.Fatalf("paid order status = %s", got.Status)
+	}
+	if got := reload(t, pending.OrderNo); got.Status != string(payment.StatusCanceled) {
+		t.Fatalf("pending order status = %s, want canceled", got.Status)
+	}
+}
+
+func TestCreatePaymentRejectsNonPositiveAmount(t *testing.T) {
+	client := &stubClient{}
+	s := newTestService(t, client)
+
+	for _, amount := range []int64{0, -100} {
+		_, err := s.CreatePayment(context.Background(), &types.Request{UserID: "user-1", Amount: amount})
+		if !errors.Is(err, payment.ErrInvalidPayment) {
+			t.Fatalf("amount %d: err = %v, want ErrInvalidPayment", amount, err)
+		}
+	}
+	if client.pages != 0 {
+		t.Fatalf("alipay called %d times for invalid amounts", client.pages)
+	}
+	var count int64
+	database.DB.Model(&payment.Payment{}).Count(&count)
+	if count != 0 {
+		t.Fatalf("%d payments created for invalid amounts", count)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	
 	"tarot/config"
 	"tarot/pkg/payment/alipay"
+	"tarot/pkg/payment/stripe"
 	"tarot/pkg/payment/wechat"
 	"tarot/pkg/payment/types"
 )
@@ -26,6 +27,13 @@ func NewPaymentService(provider types.Provider, repo types.Repository, cfg inter
 		}
 		return alipay.NewAlipayService(acfg, repo)
 		
+	case types.ProviderStripe:
+		scfg, ok := cfg.(config.StripeConfig)
+		if !ok {
+			return nil, fmt.Errorf("invalid stripe config type")
+		}
+		return stripe.NewStripeService(scfg, repo)
+		
 	default:
 		return nil, fmt.Errorf("unsupported payment provider: %s", provider)
 	}
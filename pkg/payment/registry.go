@@ -0,0 +1,27 @@
+package payment
+
+import (
+	"sync"
+
+	"tarot/pkg/payment/types"
+)
+
+var (
+	servicesMu sync.RWMutex
+	services   = make(map[types.Provider]types.Service)
+)
+
+// Register 注册支付服务，启动时由 bootstrap 调用
+func Register(provider types.Provider, service types.Service) {
+	servicesMu.Lock()
+	defer servicesMu.Unlock()
+	services[provider] = service
+}
+
+// GetService 获取指定提供商的支付服务
+func GetService(provider types.Provider) (types.Service, bool) {
+	servicesMu.RLock()
+	defer servicesMu.RUnlock()
+	service, ok := services[provider]
+	return service, ok
+}
@@ -0,0 +1,395 @@
+package stripe
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+
+	"tarot/app/models/payment"
+	"tarot/config"
+	"tarot/pkg/payment/types"
+	"tarot/pkg/payment/utils"
+)
+
+// EventPaymentIntentSucceeded 支付成功的 Webhook 事件类型
+const EventPaymentIntentSucceeded = "payment_intent.succeeded"
+
+var (
+	// ErrCurrencyMismatch 下单币种与 Stripe 配置的币种不一致
+	ErrCurrencyMismatch = errors.New("stripe currency mismatch")
+	// ErrIntentMismatch 支付意图的金额或币种与订单不一致
+	ErrIntentMismatch = errors.New("stripe payment intent does not match order")
+)
+
+// StripeService Stripe 支付服务
+// 基于 PaymentIntents API，前端使用 client_secret 完成银行卡支付
+type StripeService struct {
+	client        *resty.Client
+	webhookSecret string
+	currency      string
+	repository    types.Repository
+}
+
+// PaymentIntent Stripe 支付意图（仅包含用到的字段）
+type PaymentIntent struct {
+	ID           string            `json:"id"`
+	Status       string            `json:"status"`
+	Amount       int64             `json:"amount"`
+	Currency     string            `json:"currency"`
+	ClientSecret string            `json:"client_secret"`
+	Created      int64             `json:"created"`
+	Metadata     map[string]string `json:"metadata"`
+}
+
+// Refund Stripe 退款
+type Refund struct {
+	ID     string `json:"id"`
+	Status string `json:"status"`
+}
+
+// apiError Stripe 错误响应
+type apiError struct {
+	Error struct {
+		Type    string `json:"type"`
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// NewStripeService 创建 Stripe 支付服务
+func NewStripeService(config config.StripeConfig, repo types.Repository) (*StripeService, error) {
+	if config.SecretKey == "" {
+		return nil, fmt.Errorf("stripe secret key is required")
+	}
+	if config.WebhookSecret == "" {
+		return nil, fmt.Errorf("stripe webhook secret is required")
+	}
+
+	apiBase := config.APIBase
+	if apiBase == "" {
+		apiBase = "https://api.stripe.com"
+	}
+	currency := strings.ToLower(config.Currency)
+	if currency == "" {
+		currency = "usd"
+	}
+
+	client := resty.New().
+		SetBaseURL(apiBase).
+		SetBasicAuth(config.SecretKey, "").
+		SetTimeout(30 * time.Second)
+
+	return &StripeService{
+		client:        client,
+		webhookSecret: config.WebhookSecret,
+		currency:      currency,
+		repository:    repo,
+	}, nil
+}
+
+// CreatePayment 创建支付
+// 金额以 Stripe 配置币种的最小单位计价，请求指定其他币种时拒绝下单；client_secret 通过 ExtraData 返回给前端
+func (s *StripeService) CreatePayment(ctx context.Context, req *types.Request) (*types.Result, error) {
+	if req.Currency != "" && !strings.EqualFold(req.Currency, s.currency) {
+		return nil, fmt.Errorf("%w: got %s, want %s", ErrCurrencyMismatch, req.Currency, s.currency)
+	}
+
+	orderNo := utils.GenerateOrderNo()
+	expireAt := time.Now().Add(30 * time.Minute)
+
+	p := &payment.Payment{
+		OrderNo:   orderNo,
+		UserID:    req.UserID,
+		ReadingID: req.ReadingID,
+		Provider:  string(types.ProviderStripe),
+		Amount:    req.Amount,
+		Status:    string(types.StatusPending),
+		ExpireAt:  &expireAt,
+	}
+
+	// 校验失败时不创建 PaymentIntent
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+
+	if err := s.repository.Create(ctx, p); err != nil {
+		return nil, fmt.Errorf("create payment record error: %w", err)
+	}
+
+	// 以订单号作为幂等键，避免重试时重复创建
+	var intent PaymentIntent
+	err := s.post(ctx, "/v1/payment_intents", orderNo, map[string]string{
+		"amount":                             strconv.FormatInt(req.Amount, 10),
+		"currency":                           s.currency,
+		"description":                        req.Description,
+		"metadata[order_no]":                 orderNo,
+		"metadata[reading_id]":               strconv.FormatUint(req.ReadingID, 10),
+		"automatic_payment_methods[enabled]": "true",
+	}, &intent)
+	if err != nil {
+		return nil, fmt.Errorf("create stripe payment error: %w", err)
+	}
+
+	p.ExtraData = payment.JSON{"payment_intent_id": intent.ID, "currency": s.currency}
+	if err := s.repository.Update(ctx, p); err != nil {
+		return nil, fmt.Errorf("update payment record error: %w", err)
+	}
+
+	return &types.Result{
+		OrderNo: orderNo,
+		ExtraData: map[string]interface{}{
+			"payment_intent_id": intent.ID,
+			"client_secret":     intent.ClientSecret,
+		},
+		ExpireAt: expireAt,
+	}, nil
+}
+
+// QueryPayment 查询支付订单
+// 待支付且未过期的订单会主动查询 Stripe 并同步状态，用于补偿未送达的 Webhook
+func (s *StripeService) QueryPayment(ctx context.Context, orderNo string) (*payment.Payment, error) {
+	p, err := s.repository.GetByOrderNo(ctx, orderNo)
+	if err != nil {
+		return nil, fmt.Errorf("get payment record error: %w", err)
+	}
+
+	intentID := paymentIntentID(p)
+	if !p.IsPending() || p.IsExpired() || intentID == "" {
+		return p, nil
+	}
+
+	var intent PaymentIntent
+	if err := s.get(ctx, "/v1/payment_intents/"+intentID, &intent); err != nil {
+		return nil, fmt.Errorf("query stripe payment error: %w", err)
+	}
+
+	if err := s.applyIntent(ctx, p, &intent); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// HandleNotify 处理 Stripe Webhook
+// 先校验 Stripe-Signature，再处理 payment_intent.succeeded 事件，其余事件直接确认
+func (s *StripeService) HandleNotify(ctx context.Context, header http.Header, body []byte) error {
+	event, err := ConstructEvent(body, header.Get("Stripe-Signature"), s.webhookSecret, time.Now())
+	if err != nil {
+		return fmt.Errorf("verify stripe webhook error: %w", err)
+	}
+
+	if event.Type != EventPaymentIntentSucceeded {
+		return nil
+	}
+
+	intent := event.Data.Object
+	orderNo := intent.Metadata["order_no"]
+	if orderNo == "" {
+		return fmt.Errorf("stripe webhook missing order_no metadata")
+	}
+
+	p, err := s.repository.GetByOrderNo(ctx, orderNo)
+	if err != nil {
+		return fmt.Errorf("get payment record error: %w", err)
+	}
+
+	return s.applyIntent(ctx, p, &intent)
+}
+
+// applyIntent 根据支付意图状态更新订单，重复或非成功状态不做处理
+// 金额或币种与订单不一致时不标记支付成功
+func (s *StripeService) applyIntent(ctx context.Context, p *payment.Payment, intent *PaymentIntent) error {
+	if p.IsSuccess() || p.IsRefunded() || intent.Status != "succeeded" {
+		return nil
+	}
+	if currency := s.orderCurrency(p); intent.Amount != p.Amount || !strings.EqualFold(intent.Currency, currency) {
+		return fmt.Errorf("%w: order %s expects %d %s, got %d %s",
+			ErrIntentMismatch, p.OrderNo, p.Amount, currency, intent.Amount, intent.Currency)
+	}
+
+	p.MarkPaid(intent.ID, time.Now())
+	if err := s.repository.CompletePayment(ctx, p); err != nil {
+		return fmt.Errorf("complete payment error: %w", err)
+	}
+	return nil
+}
+
+// CancelPayment 取消未支付订单
+func (s *StripeService) CancelPayment(ctx context.Context, orderNo string) error {
+	p, err := s.repository.GetByOrderNo(ctx, orderNo)
+	if err != nil {
+		return fmt.Errorf("get payment record error: %w", err)
+	}
+	if p.IsCanceled() {
+		return nil
+	}
+	if err := p.CheckCancel(); err != nil {
+		return err
+	}
+
+	if intentID := paymentIntentID(p); intentID != "" {
+		var intent PaymentIntent
+		if err := s.post(ctx, "/v1/payment_intents/"+intentID+"/cancel", "", nil, &intent); err != nil {
+			return fmt.Errorf("cancel stripe payment error: %w", err)
+		}
+	}
+
+	p.Status = string(types.StatusCanceled)
+	if err := s.repository.Update(ctx, p); err != nil {
+		return fmt.Errorf("update payment record error: %w", err)
+	}
+	return nil
+}
+
+// RefundPayment 申请退款，支持多次部分退款
+// 同一订单的退款持有退款锁串行执行；Stripe 返回 pending 时只保存退款记录，成功后才计入已退金额
+func (s *StripeService) RefundPayment(ctx context.Context, orderNo string, amount int64, reason string) error {
+	return utils.WithRefundLock(orderNo, func() error {
+		return s.refund(ctx, orderNo, amount, reason)
+	})
+}
+
+// refund 校验退款额度、保存退款记录并调用 Stripe 退款 API，需持有退款锁
+func (s *StripeService) refund(ctx context.Context, orderNo string, amount int64, reason string) error {
+	// 1. 查询支付记录并校验退款金额，处理中的退款同样占用可退金额
+	p, err := s.repository.GetByOrderNo(ctx, orderNo)
+	if err != nil {
+		return fmt.Errorf("get payment record error: %w", err)
+	}
+	processing, err := s.repository.ProcessingRefundAmount(ctx, p.ID)
+	if err != nil {
+		return fmt.Errorf("get processing refunds error: %w", err)
+	}
+	if err := p.CheckRefund(amount, processing); err != nil {
+		return err
+	}
+
+	// 2. 先保存处理中的退款记录，再调用 Stripe 退款 API，退款单号作为幂等键
+	refund := &payment.Refund{
+		RefundNo:  "R" + utils.GenerateOrderNo(),
+		PaymentID: p.ID,
+		OrderNo:   orderNo,
+		Provider:  string(types.ProviderStripe),
+		Amount:    amount,
+		Reason:    reason,
+		Status:    string(payment.RefundStatusProcessing),
+	}
+	if err := s.repository.CreateRefund(ctx, refund); err != nil {
+		return fmt.Errorf("create refund record error: %w", err)
+	}
+
+	var resp Refund
+	err = s.post(ctx, "/v1/refunds", refund.RefundNo, map[string]string{
+		"payment_intent":      p.TransactionID,
+		"amount":              strconv.FormatInt(amount, 10),
+		"metadata[refund_no]": refund.RefundNo,
+		"metadata[reason]":    reason,
+	}, &resp)
+	if err != nil {
+		refund.Status = string(payment.RefundStatusFailed)
+		if updateErr := s.repository.UpdateRefund(ctx, refund); updateErr != nil {
+			return fmt.Errorf("update refund record error: %w", updateErr)
+		}
+		return fmt.Errorf("stripe refund error: %w", err)
+	}
+
+	// 3. 按退款状态记账
+	return s.applyRefund(ctx, refund, &resp)
+}
+
+// SyncRefund 查询处理中的退款，Stripe 退款成功后计入订单的已退金额，失败或取消时标记为失败
+func (s *StripeService) SyncRefund(ctx context.Context, refund *payment.Refund) error {
+	if refund.ProviderRefundID == "" {
+		return fmt.Errorf("stripe refund %s has no refund id", refund.RefundNo)
+	}
+	return utils.WithRefundLock(refund.OrderNo, func() error {
+		var resp Refund
+		if err := s.get(ctx, "/v1/refunds/"+refund.ProviderRefundID, &resp); err != nil {
+			return fmt.Errorf("query stripe refund error: %w", err)
+		}
+		return s.applyRefund(ctx, refund, &resp)
+	})
+}
+
+// applyRefund 根据 Stripe 退款状态更新退款记录，只有 succeeded 会累加订单的已退金额
+func (s *StripeService) applyRefund(ctx context.Context, refund *payment.Refund, resp *Refund) error {
+	if resp.ID != "" {
+		refund.ProviderRefundID = resp.ID
+	}
+
+	switch resp.Status {
+	case "succeeded":
+		if err := s.repository.FinishRefund(ctx, refund); err != nil {
+			return fmt.Errorf("finish refund error: %w", err)
+		}
+		return nil
+	case "failed", "canceled":
+		refund.Status = string(payment.RefundStatusFailed)
+	}
+
+	if err := s.repository.UpdateRefund(ctx, refund); err != nil {
+		return fmt.Errorf("update refund record error: %w", err)
+	}
+	return nil
+}
+
+// post 发送表单请求，idempotencyKey 非空时携带幂等键
+func (s *StripeService) post(ctx context.Context, path, idempotencyKey string, form map[string]string, out interface{}) error {
+	req := s.client.R().
+		SetContext(ctx).
+		SetFormData(form).
+		SetResult(out).
+		SetError(&apiError{})
+	if idempotencyKey != "" {
+		req.SetHeader("Idempotency-Key", idempotencyKey)
+	}
+
+	resp, err := req.Post(path)
+	return checkResponse(resp, err)
+}
+
+// get 发送查询请求
+func (s *StripeService) get(ctx context.Context, path string, out interface{}) error {
+	resp, err := s.client.R().
+		SetContext(ctx).
+		SetResult(out).
+		SetError(&apiError{}).
+		Get(path)
+	return checkResponse(resp, err)
+}
+
+// checkResponse 将 Stripe 错误响应转换为 error
+func checkResponse(resp *resty.Response, err error) error {
+	if err != nil {
+		return err
+	}
+	if resp.IsError() {
+		if e, ok := resp.Error().(*apiError); ok && e.Error.Message != "" {
+			return fmt.Errorf("stripe api error (%d %s): %s", resp.StatusCode(), e.Error.Code, e.Error.Message)
+		}
+		return fmt.Errorf("stripe api error: status %d", resp.StatusCode())
+	}
+	return nil
+}
+
+// paymentIntentID 读取订单关联的支付意图 ID
+func paymentIntentID(p *payment.Payment) string {
+	if p.TransactionID != "" {
+		return p.TransactionID
+	}
+	id, _ := p.ExtraData["payment_intent_id"].(string)
+	return id
+}
+
+// orderCurrency 读取下单时使用的币种，早期订单未记录时按当前配置的币种处理
+func (s *StripeService) orderCurrency(p *payment.Payment) string {
+	if currency, _ := p.ExtraData["currency"].(string); currency != "" {
+		return currency
+	}
+	return s.currency
+}
@@ -0,0 +1,299 @@
+package stripe
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"tarot/app/models/payment"
+	"tarot/app/models/reading"
+	"tarot/app/repositories"
+	"tarot/config"
+	"tarot/pkg/database"
+	"tarot/pkg/payment/types"
+	"tarot/pkg/testutil"
+)
+
+// fakeStripe 模拟 Stripe API 的 HTTP 服务
+type fakeStripe struct {
+	mu           sync.Mutex
+	refundStatus string
+	refundForms  []map[string]string
+	intentForms  []map[string]string
+	idempotency  []string
+}
+
+// postForm 读取表单参数
+func postForm(r *http.Request) map[string]string {
+	r.ParseForm()
+	form := map[string]string{}
+	for k := range r.PostForm {
+		form[k] = r.PostForm.Get(k)
+	}
+	return form
+}
+
+func (f *fakeStripe) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == "/v1/payment_intents":
+		f.intentForms = append(f.intentForms, postForm(r))
+		json.NewEncoder(w).Encode(PaymentIntent{ID: "pi_new", Status: "requires_payment_method", ClientSecret: "pi_new_secret"})
+	case r.Method == http.MethodPost && r.URL.Path == "/v1/refunds":
+		f.refundForms = append(f.refundForms, postForm(r))
+		f.idempotency = append(f.idempotency, r.Header.Get("Idempotency-Key"))
+		json.NewEncoder(w).Encode(Refund{ID: "re_1", Status: f.refundStatus})
+	case r.Method == http.MethodGet && r.URL.Path == "/v1/refunds/re_1":
+		json.NewEncoder(w).Encode(Refund{ID: "re_1", Status: f.refundStatus})
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"error":{"type":"invalid_request_error","code":"resource_missing","message":"not found"}}`))
+	}
+}
+
+func (f *fakeStripe) setRefundStatus(status string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.refundStatus = status
+}
+
+func newTestService(t *testing.T, fake *fakeStripe) *StripeService {
+	t.Helper()
+	testutil.SetupDB(t, &payment.Payment{}, &payment.Refund{}, &payment.PaymentEvent{}, &reading.Reading{})
+	testutil.SetupRedis(t)
+
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	s, err := NewStripeService(config.StripeConfig{
+		SecretKey:     "sk_test",
+		WebhookSecret: "whsec_test",
+		Currency:      "USD",
+		APIBase:       server.URL,
+	}, repositories.NewPaymentRepository())
+	if err != nil {
+		t.Fatalf("NewStripeService: %v", err)
+	}
+	return s
+}
+
+func createPaid(t *testing.T, amount int64) *payment.Payment {
+	t.Helper()
+	now := time.Now()
+	p := &payment.Payment{
+		OrderNo:       "S" + now.Format("150405.000000000"),
+		UserID:        "user-1",
+		Provider:      string(payment.ProviderStripe),
+		Amount:        amount,
+		Status:        string(payment.StatusPaid),
+		TransactionID: "pi_1",
+		PayAt:         &now,
+	}
+	if err := database.DB.Create(p).Error; err != nil {
+		t.Fatalf("create payment: %v", err)
+	}
+	return p
+}
+
+func reload(t *testing.T, orderNo string) *payment.Payment {
+	t.Helper()
+	p, err := repositories.NewPaymentRepository().GetByOrderNo(context.Background(), orderNo)
+	if err != nil {
+		t.Fatalf("reload payment: %v", err)
+	}
+	return p
+}
+
+func TestRefundPaymentSucceeded(t *testing.T) {
+	fake := &fakeStripe{refundStatus: "succeeded"}
+	s := newTestService(t, fake)
+	p := createPaid(t, 2000)
+
+	if err := s.RefundPayment(context.Background(), p.OrderNo, 2000, "full"); err != nil {
+		t.Fatalf("RefundPayment: %v", err)
+	}
+	if got := reload(t, p.OrderNo); got.Status != string(payment.StatusRefunded) || got.RefundedAmount != 2000 {
+		t.Fatalf("status=%s refunded=%d, want refunded/2000", got.Status, got.RefundedAmount)
+	}
+	form := fake.refundForms[0]
+	if form["payment_intent"] != "pi_1" || form["amount"] != "2000" || fake.idempotency[0] == "" {
+		t.Fatalf("unexpected refund request: %v idempotency=%q", form, fake.idempotency[0])
+	}
+}
+
+func TestRefundPaymentPendingAppliedAfterSync(t *testing.T) {
+	fake := &fakeStripe{refundStatus: "pending"}
+	s := newTestService(t, fake)
+	p := createPaid(t, 2000)
+	ctx := context.Background()
+
+	if err := s.RefundPayment(ctx, p.OrderNo, 800, "partial"); err != nil {
+		t.Fatalf("RefundPayment: %v", err)
+	}
+	if got := reload(t, p.OrderNo); got.RefundedAmount != 0 {
+		t.Fatalf("pending refund applied early: refunded=%d", got.RefundedAmount)
+	}
+	if err := s.RefundPayment(ctx, p.OrderNo, 1300, "over"); !errors.Is(err, payment.ErrInvalidRefundAmount) {
+		t.Fatalf("refund beyond pending err = %v, want ErrInvalidRefundAmount", err)
+	}
+
+	var refund payment.Refund
+	if err := database.DB.Where("order_no = ?", p.OrderNo).First(&refund).Error; err != nil {
+		t.Fatalf("load refund: %v", err)
+	}
+	if refund.ProviderRefundID != "re_1" {
+		t.Fatalf("provider refund id = %q, want re_1", refund.ProviderRefundID)
+	}
+
+	fake.setRefundStatus("succeeded")
+	if err := s.SyncRefund(ctx, &refund); err != nil {
+		t.Fatalf("SyncRefund: %v", err)
+	}
+	if got := reload(t, p.OrderNo); got.Status != string(payment.StatusPaid) || got.RefundedAmount != 800 {
+		t.Fatalf("status=%s refunded=%d, want paid/800", got.Status, got.RefundedAmount)
+	}
+}
+
+func TestRefundPaymentFailedNotApplied(t *testing.T) {
+	fake := &fakeStripe{refundStatus: "failed"}
+	s := newTestService(t, fake)
+	p := createPaid(t, 2000)
+
+	if err := s.RefundPayment(context.Background(), p.OrderNo, 2000, "x"); err != nil {
+		t.Fatalf("RefundPayment: %v", err)
+	}
+	if got := reload(t, p.OrderNo); got.RefundedAmount != 0 || got.Status != string(payment.StatusPaid) {
+		t.Fatalf("failed refund applied: status=%s refunded=%d", got.Status, got.RefundedAmount)
+	}
+	var refund payment.Refund
+	database.DB.Where("order_no = ?", p.OrderNo).First(&refund)
+	if refund.Status != string(payment.RefundStatusFailed) {
+		t.Fatalf("refund status = %s, want failed", refund.Status)
+	}
+}
+
+// createReading 创建待支付的付费解读
+func createReading(t *testing.T) *reading.Reading {
+	t.Helper()
+	r := &reading.Reading{TaskID: "task-stripe", UserID: "user-1", Type: reading.TypePremium, Cards: reading.Cards{1}}
+	if err := database.DB.Create(r).Error; err != nil {
+		t.Fatalf("create reading: %v", err)
+	}
+	return r
+}
+
+// notify 以 Stripe 的签名方式投递 payment_intent.succeeded 事件
+func notify(t *testing.T, s *StripeService, intent PaymentIntent) error {
+	t.Helper()
+	event := map[string]interface{}{
+		"id":   "evt_1",
+		"type": EventPaymentIntentSucceeded,
+		"data": map[string]interface{}{"object": intent},
+	}
+	body, _ := json.Marshal(event)
+	now := time.Now().Unix()
+	header := http.Header{}
+	header.Set("Stripe-Signature", fmt.Sprintf("t=%d,v1=%s", now, hex.EncodeToString(computeSignature(body, now, "whsec_test"))))
+	return s.HandleNotify(context.Background(), header, body)
+}
+
+func TestCreatePaymentUsesConfiguredCurrency(t *testing.T) {
+	fake := &fakeStripe{}
+	s := newTestService(t, fake)
+	r := createReading(t)
+
+	result, err := s.CreatePayment(context.Background(), &types.Request{UserID: "user-1", ReadingID: r.ID, Amount: 299, Currency: "usd"})
+	if err != nil {
+		t.Fatalf("CreatePayment: %v", err)
+	}
+	if form := fake.intentForms[0]; form["amount"] != "299" || form["currency"] != "usd" {
+		t.Fatalf("payment intent request = %v, want 299 usd", form)
+	}
+	if got := reload(t, result.OrderNo); got.Amount != 299 || got.ExtraData["currency"] != "usd" {
+		t.Fatalf("order amount=%d extra=%v, want 299 usd", got.Amount, got.ExtraData)
+	}
+}
+
+func TestCreatePaymentRejectsOtherCurrency(t *testing.T) {
+	fake := &fakeStripe{}
+	s := newTestService(t, fake)
+	r := createReading(t)
+
+	_, err := s.CreatePayment(context.Background(), &types.Request{UserID: "user-1", ReadingID: r.ID, Amount: 2000, Currency: "CNY"})
+	if !errors.Is(err, ErrCurrencyMismatch) {
+		t.Fatalf("err = %v, want ErrCurrencyMismatch", err)
+	}
+	if len(fake.intentForms) != 0 {
+		t.Fatalf("payment intent created for mismatched currency")
+	}
+}
+
+func TestWebhookMarksMatchingIntentPaid(t *testing.T) {
+	s := newTestService(t, &fakeStripe{})
+	r := createReading(t)
+	result, err := s.CreatePayment(context.Background(), &types.Request{UserID: "user-1", ReadingID: r.ID, Amount: 299, Currency: "usd"})
+	if err != nil {
+		t.Fatalf("CreatePayment: %v", err)
+	}
+
+	intent := PaymentIntent{ID: "pi_new", Status: "succeeded", Amount: 299, Currency: "usd", Metadata: map[string]string{"order_no": result.OrderNo}}
+	if err := notify(t, s, intent); err != nil {
+		t.Fatalf("HandleNotify: %v", err)
+	}
+	if got := reload(t, result.OrderNo); got.Status != string(payment.StatusPaid) {
+		t.Fatalf("status = %s, want paid", got.Status)
+	}
+}
+
+func TestWebhookRejectsMismatchedIntent(t *testing.T) {
+	s := newTestService(t, &fakeStripe{})
+	r := createReading(t)
+	result, err := s.CreatePayment(context.Background(), &types.Request{UserID: "user-1", ReadingID: r.ID, Amount: 299, Currency: "usd"})
+	if err != nil {
+		t.Fatalf("CreatePayment: %v", err)
+	}
+
+	for _, intent := range []PaymentIntent{
+		{ID: "pi_new", Status: "succeeded", Amount: 1, Currency: "usd"},
+		{ID: "pi_new", Status: "succeeded", Amount: 299, Currency: "jpy"},
+	} {
+		intent.Metadata = map[string]string{"order_no": result.OrderNo}
+		if err := notify(t, s, intent); !errors.Is(err, ErrIntentMismatch) {
+			t.Fatalf("intent %d %s: err = %v, want ErrIntentMismatch", intent.Amount, intent.Currency, err)
+		}
+	}
+	if got := reload(t, result.OrderNo); got.Status != string(payment.StatusPending) {
+		t.Fatalf("status = %s, want pending", got.Status)
+	}
+}
+
+func TestCreatePaymentRejectsNonPositiveAmount(t *testing.T) {
+	fake := &fakeStripe{}
+	s := newTestService(t, fake)
+	r := createReading(t)
+
+	for _, amount := range []int64{0, -100} {
+		_, err := s.CreatePayment(context.Background(), &types.Request{UserID: "user-1", ReadingID: r.ID, Amount: amount, Currency: "usd"})
+		if !errors.Is(err, payment.ErrInvalidPayment) {
+			t.Fatalf("amount %d: err = %v, want ErrInvalidPayment", amount, err)
+		}
+	}
+	if len(fake.intentForms) != 0 {
+		t.Fatalf("payment intent created %d times for invalid amounts", len(fake.intentForms))
+	}
+	var count int64
+	database.DB.Model(&payment.Payment{}).Count(&count)
+	if count != 0 {
+		t.Fatalf("%d payments created for invalid amounts", count)
+	}
+}
@@ -0,0 +1,108 @@
+package stripe
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SignatureTolerance Webhook 时间戳允许的最大偏差，用于防重放
+const SignatureTolerance = 5 * time.Minute
+
+var (
+	// ErrInvalidSignatureHeader Stripe-Signature 请求头缺失或格式错误
+	ErrInvalidSignatureHeader = errors.New("invalid stripe signature header")
+	// ErrSignatureMismatch 签名校验失败
+	ErrSignatureMismatch = errors.New("stripe signature mismatch")
+	// ErrSignatureExpired 时间戳超出允许范围
+	ErrSignatureExpired = errors.New("stripe signature timestamp outside tolerance")
+)
+
+// Event Stripe Webhook 事件（仅解析支付意图类事件）
+type Event struct {
+	ID   string `json:"id"`
+	Type string `json:"type"`
+	Data struct {
+		Object PaymentIntent `json:"object"`
+	} `json:"data"`
+}
+
+// ConstructEvent 校验签名并解析 Webhook 事件
+// 签名格式：t=<timestamp>,v1=<hex(hmac_sha256(secret, "<timestamp>.<payload>"))>
+func ConstructEvent(payload []byte, sigHeader, secret string, now time.Time) (*Event, error) {
+	timestamp, signatures, err := parseSignatureHeader(sigHeader)
+	if err != nil {
+		return nil, err
+	}
+
+	if diff := now.Sub(time.Unix(timestamp, 0)); diff > SignatureTolerance || diff < -SignatureTolerance {
+		return nil, ErrSignatureExpired
+	}
+
+	expected := computeSignature(payload, timestamp, secret)
+	matched := false
+	for _, sig := range signatures {
+		if hmac.Equal(expected, sig) {
+			matched = true
+			break
+		}
+	}
+	if !matched {
+		return nil, ErrSignatureMismatch
+	}
+
+	var event Event
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, fmt.Errorf("decode stripe event error: %w", err)
+	}
+	return &event, nil
+}
+
+// parseSignatureHeader 解析 Stripe-Signature，可能包含多个 v1 签名（密钥轮换期间）
+func parseSignatureHeader(header string) (int64, [][]byte, error) {
+	var (
+		timestamp  int64
+		signatures [][]byte
+	)
+
+	for _, part := range strings.Split(header, ",") {
+		kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		switch kv[0] {
+		case "t":
+			t, err := strconv.ParseInt(kv[1], 10, 64)
+			if err != nil {
+				return 0, nil, ErrInvalidSignatureHeader
+			}
+			timestamp = t
+		case "v1":
+			sig, err := hex.DecodeString(kv[1])
+			if err != nil {
+				continue
+			}
+			signatures = append(signatures, sig)
+		}
+	}
+
+	if timestamp == 0 || len(signatures) == 0 {
+		return 0, nil, ErrInvalidSignatureHeader
+	}
+	return timestamp, signatures, nil
+}
+
+// computeSignature 计算签名
+func computeSignature(payload []byte, timestamp int64, secret string) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
@@ -0,0 +1,149 @@
+package payment
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	paymentModel "tarot/app/models/payment"
+	"tarot/pkg/logger"
+	"tarot/pkg/payment/types"
+)
+
+// Sweeper 待支付订单对账与过期清理
+// 定期向支付平台补查长时间未收到通知的订单与处理中的退款，并关闭已过期但仍处于待支付状态的订单
+type Sweeper struct {
+	repository     types.Repository
+	interval       time.Duration
+	batchSize      int
+	reconcileAfter time.Duration
+}
+
+// NewSweeper 创建对账与清理任务
+// 创建超过 reconcileAfter 仍待支付的订单、仍在处理中的退款会被补查，reconcileAfter 为 0 时不补查
+func NewSweeper(repo types.Repository, interval time.Duration, batchSize int, reconcileAfter time.Duration) *Sweeper {
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	if batchSize <= 0 {
+		batchSize = 100
+	}
+	return &Sweeper{
+		repository:     repo,
+		interval:       interval,
+		batchSize:      batchSize,
+		reconcileAfter: reconcileAfter,
+	}
+}
+
+// Start 启动清理循环，直到 ctx 被取消
+func (s *Sweeper) Start(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.Reconcile(ctx)
+			s.SyncRefunds(ctx)
+			s.Sweep(ctx)
+		}
+	}
+}
+
+// Reconcile 向支付平台补查长时间待支付的订单，补偿丢失的支付通知，返回状态发生变化的订单数
+func (s *Sweeper) Reconcile(ctx context.Context) int {
+	if s.reconcileAfter <= 0 {
+		return 0
+	}
+
+	now := time.Now()
+	payments, err := s.repository.ListStalePending(ctx, now.Add(-s.reconcileAfter), now, s.batchSize)
+	if err != nil {
+		logger.ErrorString("Payment", "Reconcile", fmt.Sprintf("查询待对账订单失败: %v", err))
+		return 0
+	}
+
+	queryCtx := paymentModel.WithEventSource(ctx, paymentModel.EventSourceQuery, nil)
+	updated := 0
+	for _, p := range payments {
+		service, ok := GetService(types.Provider(p.Provider))
+		if !ok {
+			continue
+		}
+
+		synced, err := service.QueryPayment(queryCtx, p.OrderNo)
+		if err != nil {
+			logger.WarnString("Payment", "Reconcile", fmt.Sprintf("查询订单 %s 失败: %v", p.OrderNo, err))
+			continue
+		}
+		if synced.Status != p.Status {
+			updated++
+		}
+	}
+
+	if updated > 0 {
+		logger.InfoString("Payment", "Reconcile", fmt.Sprintf("已同步 %d 个订单状态", updated))
+	}
+	return updated
+}
+
+// SyncRefunds 向支付平台补查处理中的退款，退款成功后才计入订单的已退金额，返回补查成功的退款数
+func (s *Sweeper) SyncRefunds(ctx context.Context) int {
+	if s.reconcileAfter <= 0 {
+		return 0
+	}
+
+	refunds, err := s.repository.ListProcessingRefunds(ctx, time.Now().Add(-s.reconcileAfter), s.batchSize)
+	if err != nil {
+		logger.ErrorString("Payment", "SyncRefunds", fmt.Sprintf("查询处理中的退款失败: %v", err))
+		return 0
+	}
+
+	queryCtx := paymentModel.WithEventSource(ctx, paymentModel.EventSourceQuery, nil)
+	synced := 0
+	for i := range refunds {
+		refund := &refunds[i]
+		service, ok := GetService(types.Provider(refund.Provider))
+		if !ok {
+			continue
+		}
+		if err := service.SyncRefund(queryCtx, refund); err != nil {
+			logger.WarnString("Payment", "SyncRefunds", fmt.Sprintf("查询退款 %s 失败: %v", refund.RefundNo, err))
+			continue
+		}
+		synced++
+	}
+	return synced
+}
+
+// Sweep 执行一次清理，返回成功取消的订单数
+func (s *Sweeper) Sweep(ctx context.Context) int {
+	payments, err := s.repository.ListExpiredPending(ctx, time.Now(), s.batchSize)
+	if err != nil {
+		logger.ErrorString("Payment", "Sweep", fmt.Sprintf("查询过期订单失败: %v", err))
+		return 0
+	}
+
+	canceled := 0
+	for _, p := range payments {
+		service, ok := GetService(types.Provider(p.Provider))
+		if !ok {
+			logger.WarnString("Payment", "Sweep", fmt.Sprintf("订单 %s 的支付渠道 %s 未启用", p.OrderNo, p.Provider))
+			continue
+		}
+
+		if err := service.CancelPayment(paymentModel.WithEventSource(ctx, paymentModel.EventSourceSweep, nil), p.OrderNo); err != nil {
+			logger.ErrorString("Payment", "Sweep", fmt.Sprintf("取消订单 %s 失败: %v", p.OrderNo, err))
+			continue
+		}
+		canceled++
+	}
+
+	if canceled > 0 {
+		logger.InfoString("Payment", "Sweep", fmt.Sprintf("已取消 %d 个过期订单", canceled))
+	}
+	return canceled
+}
@@ -0,0 +1,242 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	paymentModel "tarot/app/models/payment"
+	"tarot/app/models/reading"
+	"tarot/app/repositories"
+	"tarot/pkg/database"
+	"tarot/pkg/payment/types"
+	"tarot/pkg/testutil"
+)
+
+// stubService 按仓储中的订单状态执行取消，记录被取消的订单号
+// paid 中的订单模拟支付平台已收款但通知丢失，查询时同步为已支付
+type stubService struct {
+	mu         sync.Mutex
+	repository types.Repository
+	canceled   []string
+	queried    []string
+	paid       map[string]bool
+}
+
+func (s *stubService) CreatePayment(ctx context.Context, req *types.Request) (*types.Result, error) {
+	return nil, errors.New("not used")
+}
+
+func (s *stubService) QueryPayment(ctx context.Context, orderNo string) (*paymentModel.Payment, error) {
+	p, err := s.repository.GetByOrderNo(ctx, orderNo)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	s.queried = append(s.queried, orderNo)
+	paid := s.paid[orderNo]
+	s.mu.Unlock()
+	if paid && p.IsPending() {
+		now := time.Now()
+		p.Status = string(types.StatusPaid)
+		p.PayAt = &now
+		if err := s.repository.CompletePayment(ctx, p); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+func (s *stubService) HandleNotify(ctx context.Context, header http.Header, body []byte) error {
+	return errors.New("not used")
+}
+
+func (s *stubService) CancelPayment(ctx context.Context, orderNo string) error {
+	p, err := s.repository.GetByOrderNo(ctx, orderNo)
+	if err != nil {
+		return err
+	}
+	if err := p.CheckCancel(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.canceled = append(s.canceled, orderNo)
+	s.mu.Unlock()
+	p.Status = string(types.StatusCanceled)
+	return s.repository.Update(ctx, p)
+}
+
+func (s *stubService) RefundPayment(ctx context.Context, orderNo string, amount int64, reason string) error {
+	return errors.New("not used")
+}
+
+func (s *stubService) SyncRefund(ctx context.Context, refund *paymentModel.Refund) error {
+	return nil
+}
+
+func setupSweeper(t *testing.T) (*Sweeper, *stubService) {
+	t.Helper()
+	testutil.SetupDB(t, &paymentModel.Payment{}, &paymentModel.Refund{}, &paymentModel.PaymentEvent{}, &reading.Reading{})
+
+	repo := repositories.NewPaymentRepository()
+	service := &stubService{repository: repo}
+	prev, hadPrev := GetService(types.ProviderWechat)
+	Register(types.ProviderWechat, service)
+	t.Cleanup(func() {
+		servicesMu.Lock()
+		defer servicesMu.Unlock()
+		if hadPrev {
+			services[types.ProviderWechat] = prev
+		} else {
+			delete(services, types.ProviderWechat)
+		}
+	})
+	return NewSweeper(repo, time.Minute, 100, 0), service
+}
+
+func createPayment(t *testing.T, orderNo string, status paymentModel.Status, expireAt *time.Time) {
+	t.Helper()
+	p := &paymentModel.Payment{
+		OrderNo:  orderNo,
+		UserID:   "user-1",
+		Provider: string(types.ProviderWechat),
+		Amount:   2000,
+		Status:   string(status),
+		ExpireAt: expireAt,
+	}
+	if err := database.DB.Create(p).Error; err != nil {
+		t.Fatalf("create payment %s: %v", orderNo, err)
+	}
+}
+
+func statusOf(t *testing.T, orderNo string) string {
+	t.Helper()
+	p, err := repositories.NewPaymentRepository().GetByOrderNo(context.Background(), orderNo)
+	if err != nil {
+		t.Fatalf("get payment %s: %v", orderNo, err)
+	}
+	return p.Status
+}
+
+func TestSweepCancelsOnlyExpiredPending(t *testing.T) {
+	sweeper, service := setupSweeper(t)
+	past := time.Now().Add(-time.Minute)
+	future := time.Now().Add(time.Hour)
+
+	createPayment(t, "expired-pending", paymentModel.StatusPending, &past)
+	createPayment(t, "live-pending", paymentModel.StatusPending, &future)
+	createPayment(t, "no-expiry", paymentModel.StatusPending, nil)
+	createPayment(t, "expired-paid", paymentModel.StatusPaid, &past)
+
+	if n := sweeper.Sweep(context.Background()); n != 1 {
+		t.Fatalf("Sweep canceled %d orders, want 1", n)
+	}
+	if len(service.canceled) != 1 || service.canceled[0] != "expired-pending" {
+		t.Fatalf("canceled = %v, want [expired-pending]", service.canceled)
+	}
+
+	want := map[string]paymentModel.Status{
+		"expired-pending": paymentModel.StatusCanceled,
+		"live-pending":    paymentModel.StatusPending,
+		"no-expiry":       paymentModel.StatusPending,
+		"expired-paid":    paymentModel.StatusPaid,
+	}
+	for orderNo, status := range want {
+		if got := statusOf(t, orderNo); got != string(status) {
+			t.Errorf("%s status = %s, want %s", orderNo, got, status)
+		}
+	}
+
+	// 已取消的订单不会被再次选中
+	if n := sweeper.Sweep(context.Background()); n != 0 {
+		t.Fatalf("second Sweep canceled %d orders, want 0", n)
+	}
+}
+
+func TestSweeperStartStopsOnCancel(t *testing.T) {
+	sweeper, _ := setupSweeper(t)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	done := make(chan struct{})
+	go func() {
+		sweeper.Start(ctx)
+		close(done)
+	}()
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Start did not return after ctx was canceled")
+	}
+}
+
+// ageOrder 将订单的创建时间提前 age，使其超过对账阈值
+func ageOrder(t *testing.T, orderNo string, age time.Duration) {
+	t.Helper()
+	if err := database.DB.Model(&paymentModel.Payment{}).Where("order_no = ?", orderNo).
+		UpdateColumn("created_at", time.Now().Add(-age)).Error; err != nil {
+		t.Fatalf("age payment %s: %v", orderNo, err)
+	}
+}
+
+func TestReconcileSyncsProviderPaidOrders(t *testing.T) {
+	_, service := setupSweeper(t)
+	sweeper := NewSweeper(repositories.NewPaymentRepository(), time.Minute, 100, 10*time.Minute)
+	future := time.Now().Add(time.Hour)
+	past := time.Now().Add(-time.Minute)
+
+	createPayment(t, "stale-paid", paymentModel.StatusPending, &future)
+	createPayment(t, "stale-unpaid", paymentModel.StatusPending, &future)
+	createPayment(t, "recent-paid", paymentModel.StatusPending, &future)
+	createPayment(t, "expired", paymentModel.StatusPending, &past)
+	ageOrder(t, "stale-paid", time.Hour)
+	ageOrder(t, "stale-unpaid", time.Hour)
+	ageOrder(t, "expired", time.Hour)
+	service.paid = map[string]bool{"stale-paid": true, "recent-paid": true}
+
+	if n := sweeper.Reconcile(context.Background()); n != 1 {
+		t.Fatalf("Reconcile updated %d orders, want 1", n)
+	}
+	// 未超过阈值的订单与已过期的订单不补查
+	if len(service.queried) != 2 {
+		t.Fatalf("queried = %v, want only the stale live orders", service.queried)
+	}
+	if n := sweeper.Sweep(context.Background()); n != 1 {
+		t.Fatalf("Sweep canceled %d orders, want 1", n)
+	}
+
+	want := map[string]paymentModel.Status{
+		"stale-paid":   paymentModel.StatusPaid,
+		"stale-unpaid": paymentModel.StatusPending,
+		"recent-paid":  paymentModel.StatusPending,
+		"expired":      paymentModel.StatusCanceled,
+	}
+	for orderNo, status := range want {
+		if got := statusOf(t, orderNo); got != string(status) {
+			t.Errorf("%s status = %s, want %s", orderNo, got, status)
+		}
+	}
+
+	// 对账产生的状态变化记录来源为 query
+	p, _ := repositories.NewPaymentRepository().GetByOrderNo(context.Background(), "stale-paid")
+	events, err := repositories.NewPaymentRepository().ListEvents(context.Background(), p.ID)
+	if err != nil || len(events) != 1 || events[0].Source != string(paymentModel.EventSourceQuery) {
+		t.Fatalf("events = %+v, %v, want one query event", events, err)
+	}
+}
+
+func TestReconcileDisabledWithoutThreshold(t *testing.T) {
+	sweeper, service := setupSweeper(t)
+	future := time.Now().Add(time.Hour)
+	createPayment(t, "stale-paid", paymentModel.StatusPending, &future)
+	ageOrder(t, "stale-paid", time.Hour)
+	service.paid = map[string]bool{"stale-paid": true}
+
+	if n := sweeper.Reconcile(context.Background()); n != 0 || len(service.queried) != 0 {
+		t.Fatalf("Reconcile updated %d orders and queried %v, want nothing", n, service.queried)
+	}
+}
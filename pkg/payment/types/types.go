@@ -2,6 +2,7 @@ package types
 
 import (
 	"context"
+	"net/http"
 	"tarot/app/models/payment"
 	"time"
 )
@@ -12,6 +13,7 @@ type Provider string
 const (
 	ProviderWechat Provider = "wechat"
 	ProviderAlipay Provider = "alipay"
+	ProviderStripe Provider = "stripe"
 )
 
 // Status 支付状态
@@ -30,6 +32,7 @@ type Request struct {
 	UserID      string   `json:"user_id"`
 	ReadingID   uint64   `json:"reading_id"`
 	Amount      int64    `json:"amount"`
+	Currency    string   `json:"currency"`
 	Provider    Provider `json:"provider"`
 	ReturnURL   string   `json:"return_url"`
 	NotifyURL   string   `json:"notify_url"`
@@ -49,15 +52,25 @@ type Result struct {
 type Service interface {
 	CreatePayment(ctx context.Context, req *Request) (*Result, error)
 	QueryPayment(ctx context.Context, orderNo string) (*payment.Payment, error)
-	HandleNotify(ctx context.Context, data []byte) error
+	HandleNotify(ctx context.Context, header http.Header, body []byte) error
 	CancelPayment(ctx context.Context, orderNo string) error
 	RefundPayment(ctx context.Context, orderNo string, amount int64, reason string) error
+	// SyncRefund 向支付平台查询处理中的退款并更新状态，退款成功时才计入订单的退款金额
+	SyncRefund(ctx context.Context, refund *payment.Refund) error
 }
 
 // Repository 支付仓储接口
 type Repository interface {
 	Create(ctx context.Context, payment *payment.Payment) error
 	Update(ctx context.Context, payment *payment.Payment) error
+	CompletePayment(ctx context.Context, payment *payment.Payment) error
 	GetByOrderNo(ctx context.Context, orderNo string) (*payment.Payment, error)
 	GetByTransactionID(ctx context.Context, transactionID string) (*payment.Payment, error)
+	CreateRefund(ctx context.Context, refund *payment.Refund) error
+	UpdateRefund(ctx context.Context, refund *payment.Refund) error
+	FinishRefund(ctx context.Context, refund *payment.Refund) error
+	ProcessingRefundAmount(ctx context.Context, paymentID uint64) (int64, error)
+	ListProcessingRefunds(ctx context.Context, createdBefore time.Time, limit int) ([]payment.Refund, error)
+	ListExpiredPending(ctx context.Context, before time.Time, limit int) ([]payment.Payment, error)
+	ListStalePending(ctx context.Context, createdBefore, now time.Time, limit int) ([]payment.Payment, error)
 } 
\ No newline at end of file
@@ -0,0 +1,23 @@
+package utils
+
+import (
+	"errors"
+	"time"
+
+	"tarot/app/models/payment"
+	"tarot/pkg/redis"
+)
+
+// RefundLockTTL 退款锁的有效期，需覆盖一次退款接口调用的耗时
+const RefundLockTTL = time.Minute
+
+// WithRefundLock 持有订单的退款锁执行 fn
+// 同一订单的退款额度校验、渠道调用与记账串行执行，并发退款不会超出可退金额；
+// 锁被占用时不执行 fn，返回 payment.ErrRefundInProgress
+func WithRefundLock(orderNo string, fn func() error) error {
+	err := redis.Cache().WithLock("refund:"+orderNo, RefundLockTTL, fn)
+	if errors.Is(err, redis.ErrLockNotAcquired) {
+		return payment.ErrRefundInProgress
+	}
+	return err
+}
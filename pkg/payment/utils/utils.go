@@ -4,12 +4,21 @@ import (
 	"crypto/rand"
 	"encoding/hex"
 	"fmt"
+	"sync/atomic"
 	"time"
 )
 
-// GenerateOrderNo 生成订单号
+// orderSeq 进程内订单序号，保证同一实例同一秒内不重复
+var orderSeq atomic.Uint32
+
+// GenerateOrderNo 生成订单号，所有支付渠道共用
+// 格式为 14 位时间 + 6 位进程内序号 + 8 位随机十六进制，共 28 位，
+// 满足微信支付 out_trade_no 不超过 32 位的限制；随机后缀避免多实例之间冲突，也使订单号不可预测
 func GenerateOrderNo() string {
-	return fmt.Sprintf("%s%d", time.Now().Format("20060102150405"), time.Now().UnixNano()%1000)
+	b := make([]byte, 4)
+	rand.Read(b)
+	seq := orderSeq.Add(1) % 1000000
+	return fmt.Sprintf("%s%06d%s", time.Now().Format("20060102150405"), seq, hex.EncodeToString(b))
 }
 
 // GenerateNonceStr 生成随机字符串
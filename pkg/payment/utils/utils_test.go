@@ -0,0 +1,48 @@
+package utils
+
+import (
+	"regexp"
+	"sync"
+	"testing"
+)
+
+var orderNoPattern = regexp.MustCompile(`^\d{20}[0-9a-f]{8}$`)
+
+func TestGenerateOrderNoConcurrentUnique(t *testing.T) {
+	const workers, perWorker = 50, 2000
+
+	var mu sync.Mutex
+	seen := make(map[string]struct{}, workers*perWorker)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			batch := make([]string, 0, perWorker)
+			for j := 0; j < perWorker; j++ {
+				batch = append(batch, GenerateOrderNo())
+			}
+			mu.Lock()
+			for _, orderNo := range batch {
+				seen[orderNo] = struct{}{}
+			}
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	if len(seen) != workers*perWorker {
+		t.Fatalf("generated %d unique order numbers, want %d", len(seen), workers*perWorker)
+	}
+}
+
+func TestGenerateOrderNoFormat(t *testing.T) {
+	orderNo := GenerateOrderNo()
+	// 微信支付 out_trade_no 不超过 32 位
+	if len(orderNo) != 28 || !orderNoPattern.MatchString(orderNo) {
+		t.Fatalf("order number %q, want 20 digits followed by 8 hex characters", orderNo)
+	}
+	if orderNo == GenerateOrderNo() {
+		t.Fatal("consecutive order numbers are equal")
+	}
+}
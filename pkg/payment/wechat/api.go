@@ -0,0 +1,61 @@
+package wechat
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/wechatpay-apiv3/wechatpay-go/core"
+	"github.com/wechatpay-apiv3/wechatpay-go/services/payments"
+	"github.com/wechatpay-apiv3/wechatpay-go/services/payments/jsapi"
+	"github.com/wechatpay-apiv3/wechatpay-go/services/refunddomestic"
+)
+
+// wechatAPI 服务用到的微信支付接口，测试时以桩实现替换
+type wechatAPI interface {
+	Prepay(ctx context.Context, req jsapi.PrepayRequest) (*jsapi.PrepayResponse, error)
+	CloseOrder(ctx context.Context, req jsapi.CloseOrderRequest) error
+	QueryOrder(ctx context.Context, req jsapi.QueryOrderByOutTradeNoRequest) (*payments.Transaction, error)
+	CreateRefund(ctx context.Context, req refunddomestic.CreateRequest) (*refunddomestic.Refund, error)
+	QueryRefund(ctx context.Context, req refunddomestic.QueryByOutRefundNoRequest) (*refunddomestic.Refund, error)
+}
+
+// coreAPI 基于官方 SDK 客户端的实现
+type coreAPI struct {
+	client *core.Client
+}
+
+func (a coreAPI) Prepay(ctx context.Context, req jsapi.PrepayRequest) (*jsapi.PrepayResponse, error) {
+	svc := jsapi.JsapiApiService{Client: a.client}
+	resp, result, err := svc.Prepay(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if result != nil && result.Response.StatusCode != 200 {
+		return nil, fmt.Errorf("prepay failed with status code: %d", result.Response.StatusCode)
+	}
+	return resp, nil
+}
+
+func (a coreAPI) CloseOrder(ctx context.Context, req jsapi.CloseOrderRequest) error {
+	svc := jsapi.JsapiApiService{Client: a.client}
+	_, err := svc.CloseOrder(ctx, req)
+	return err
+}
+
+func (a coreAPI) QueryOrder(ctx context.Context, req jsapi.QueryOrderByOutTradeNoRequest) (*payments.Transaction, error) {
+	svc := jsapi.JsapiApiService{Client: a.client}
+	resp, _, err := svc.QueryOrderByOutTradeNo(ctx, req)
+	return resp, err
+}
+
+func (a coreAPI) CreateRefund(ctx context.Context, req refunddomestic.CreateRequest) (*refunddomestic.Refund, error) {
+	svc := refunddomestic.RefundsApiService{Client: a.client}
+	resp, _, err := svc.Create(ctx, req)
+	return resp, err
+}
+
+func (a coreAPI) QueryRefund(ctx context.Context, req refunddomestic.QueryByOutRefundNoRequest) (*refunddomestic.Refund, error) {
+	svc := refunddomestic.RefundsApiService{Client: a.client}
+	resp, _, err := svc.QueryByOutRefundNo(ctx, req)
+	return resp, err
+}
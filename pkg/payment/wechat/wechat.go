@@ -1,27 +1,36 @@
 package wechat
 
 import (
+	"bytes"
 	"context"
 	"fmt"
+	"net/http"
 	"time"
 	
 	"github.com/wechatpay-apiv3/wechatpay-go/core"
+	"github.com/wechatpay-apiv3/wechatpay-go/core/auth/verifiers"
+	"github.com/wechatpay-apiv3/wechatpay-go/core/downloader"
+	"github.com/wechatpay-apiv3/wechatpay-go/core/notify"
 	"github.com/wechatpay-apiv3/wechatpay-go/core/option"
+	"github.com/wechatpay-apiv3/wechatpay-go/services/payments"
 	"github.com/wechatpay-apiv3/wechatpay-go/services/payments/jsapi"
+	"github.com/wechatpay-apiv3/wechatpay-go/services/refunddomestic"
 	"github.com/wechatpay-apiv3/wechatpay-go/utils"
 	
 	"tarot/app/models/payment"
 	"tarot/config"
 	"tarot/pkg/payment/types"
+	paymentUtils "tarot/pkg/payment/utils"
 )
 
 // WechatPayService 微信支付服务
 type WechatPayService struct {
-	client     *core.Client
-	appID      string
-	mchID      string
-	notifyURL  string
-	repository types.Repository
+	api           wechatAPI
+	appID         string
+	mchID         string
+	notifyURL     string
+	notifyHandler *notify.Handler
+	repository    types.Repository
 }
 
 // NewWechatPayService 创建微信支付服务
@@ -48,19 +57,27 @@ func NewWechatPayService(config config.WechatConfig, repo types.Repository) (*We
 		return nil, fmt.Errorf("create wechat pay client error: %w", err)
 	}
 	
+	// 4. 创建回调通知处理器，使用自动更新的平台证书验签
+	certVisitor := downloader.MgrInstance().GetCertificateVisitor(config.MchID)
+	notifyHandler, err := notify.NewRSANotifyHandler(config.APIv3Key, verifiers.NewSHA256WithRSAVerifier(certVisitor))
+	if err != nil {
+		return nil, fmt.Errorf("create wechat notify handler error: %w", err)
+	}
+	
 	return &WechatPayService{
-		client:     client,
-		appID:      config.AppID,
-		mchID:      config.MchID,
+		api:           coreAPI{client: client},
+		appID:         config.AppID,
+		mchID:         config.MchID,
 		
-		notifyURL:  config.NotifyURL,
-		repository: repo,
+		notifyURL:     config.NotifyURL,
+		notifyHandler: notifyHandler,
+		repository:    repo,
 	}, nil
 }
 
 // CreatePayment 创建支付
 func (s *WechatPayService) CreatePayment(ctx context.Context, req *types.Request) (*types.Result, error) {
-	orderNo := GenerateOrderNo()
+	orderNo := paymentUtils.GenerateOrderNo()
 	expireAt := time.Now().Add(30 * time.Minute)
 	
 	p := &payment.Payment{
@@ -74,13 +91,20 @@ func (s *WechatPayService) CreatePayment(ctx context.Context, req *types.Request
 		ExpireAt:  &expireAt,
 	}
 	
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+
 	if err := s.repository.Create(ctx, p); err != nil {
 		return nil, fmt.Errorf("create payment record error: %w", err)
 	}
 	
 	// 2. 调用微信支付API
-	svc := jsapi.JsapiApiService{Client: s.client}
-	prepayResp, result, err := svc.Prepay(ctx, jsapi.PrepayRequest{
+	currency := req.Currency
+	if currency == "" {
+		currency = "CNY"
+	}
+	prepayResp, err := s.api.Prepay(ctx, jsapi.PrepayRequest{
 		Appid:       core.String(s.appID),
 		Mchid:       core.String(s.mchID),
 		Description: core.String(req.Description),
@@ -88,7 +112,7 @@ func (s *WechatPayService) CreatePayment(ctx context.Context, req *types.Request
 		NotifyUrl:   core.String(s.notifyURL),
 		Amount: &jsapi.Amount{
 			Total:    core.Int64(req.Amount),
-			Currency: core.String("CNY"),
+			Currency: core.String(currency),
 		},
 	})
 	
@@ -96,10 +120,6 @@ func (s *WechatPayService) CreatePayment(ctx context.Context, req *types.Request
 		return nil, fmt.Errorf("create wechat payment error: %w", err)
 	}
 	
-	if result != nil && result.Response.StatusCode != 200 {
-		return nil, fmt.Errorf("create wechat payment failed with status code: %d", result.Response.StatusCode)
-	}
-	
 	// 生成支付参数
 	timestamp := time.Now().Unix()
 	nonceStr := GenerateNonceStr()
@@ -123,11 +143,6 @@ func (s *WechatPayService) CreatePayment(ctx context.Context, req *types.Request
 	}, nil
 }
 
-// GenerateOrderNo 生成订单号
-func GenerateOrderNo() string {
-	return fmt.Sprintf("%d%06d", time.Now().Unix(), time.Now().Nanosecond()/1000)
-}
-
 // GenerateNonceStr 生成随机字符串
 func GenerateNonceStr() string {
 	return fmt.Sprintf("%d", time.Now().UnixNano())
@@ -140,21 +155,204 @@ func CalculateWechatPaySign(appID string, timestamp int64, nonceStr, packageStr
 }
 
 // 实现所有接口方法
+// CancelPayment 关闭未支付订单
 func (s *WechatPayService) CancelPayment(ctx context.Context, orderNo string) error {
-	// 实现取消支付逻辑
+	p, err := s.repository.GetByOrderNo(ctx, orderNo)
+	if err != nil {
+		return fmt.Errorf("get payment record error: %w", err)
+	}
+	if p.IsCanceled() {
+		return nil
+	}
+	if err := p.CheckCancel(); err != nil {
+		return err
+	}
+
+	// 调用微信关单 API
+	if err := s.api.CloseOrder(ctx, jsapi.CloseOrderRequest{
+		OutTradeNo: core.String(orderNo),
+		Mchid:      core.String(s.mchID),
+	}); err != nil {
+		return fmt.Errorf("close wechat order error: %w", err)
+	}
+
+	p.Status = string(types.StatusCanceled)
+	if err := s.repository.Update(ctx, p); err != nil {
+		return fmt.Errorf("update payment record error: %w", err)
+	}
 	return nil
 }
 
+// QueryPayment 查询支付订单
+// 待支付且未过期的订单会主动查询微信支付并同步状态，用于补偿未送达的通知
 func (s *WechatPayService) QueryPayment(ctx context.Context, orderNo string) (*payment.Payment, error) {
-	return s.repository.GetByOrderNo(ctx, orderNo)
+	p, err := s.repository.GetByOrderNo(ctx, orderNo)
+	if err != nil {
+		return nil, fmt.Errorf("get payment record error: %w", err)
+	}
+
+	if !p.IsPending() || p.IsExpired() {
+		return p, nil
+	}
+
+	transaction, err := s.api.QueryOrder(ctx, jsapi.QueryOrderByOutTradeNoRequest{
+		OutTradeNo: core.String(orderNo),
+		Mchid:      core.String(s.mchID),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("query wechat payment error: %w", err)
+	}
+
+	if err := s.applyTransaction(ctx, p, transaction); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// HandleNotify 处理支付结果通知
+// 验签依赖 Wechatpay-* 请求头，解密后根据交易状态更新订单
+func (s *WechatPayService) HandleNotify(ctx context.Context, header http.Header, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.notifyURL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("build notify request error: %w", err)
+	}
+	req.Header = header
+
+	var transaction payments.Transaction
+	if _, err := s.notifyHandler.ParseNotifyRequest(ctx, req, &transaction); err != nil {
+		return fmt.Errorf("parse wechat notify error: %w", err)
+	}
+
+	if transaction.OutTradeNo == nil {
+		return fmt.Errorf("wechat notify missing out_trade_no")
+	}
+
+	p, err := s.repository.GetByOrderNo(ctx, *transaction.OutTradeNo)
+	if err != nil {
+		return fmt.Errorf("get payment record error: %w", err)
+	}
+
+	return s.applyTransaction(ctx, p, &transaction)
 }
 
-func (s *WechatPayService) HandleNotify(ctx context.Context, data []byte) error {
-	// 实现支付通知处理逻辑
+// applyTransaction 根据微信交易结果更新订单，重复或非成功状态不做处理
+func (s *WechatPayService) applyTransaction(ctx context.Context, p *payment.Payment, transaction *payments.Transaction) error {
+	if p.IsSuccess() || p.IsRefunded() || transaction.TradeState == nil || *transaction.TradeState != "SUCCESS" {
+		return nil
+	}
+
+	payAt := time.Now()
+	if transaction.SuccessTime != nil {
+		if t, err := time.Parse(time.RFC3339, *transaction.SuccessTime); err == nil {
+			payAt = t
+		}
+	}
+
+	transactionID := ""
+	if transaction.TransactionId != nil {
+		transactionID = *transaction.TransactionId
+	}
+	p.MarkPaid(transactionID, payAt)
+	if err := s.repository.CompletePayment(ctx, p); err != nil {
+		return fmt.Errorf("complete payment error: %w", err)
+	}
 	return nil
 }
 
+// RefundPayment 申请退款，支持多次部分退款
+// 同一订单的退款持有退款锁串行执行；微信返回处理中时只保存退款记录，退款成功后才计入已退金额
 func (s *WechatPayService) RefundPayment(ctx context.Context, orderNo string, amount int64, reason string) error {
-	// 实现退款逻辑
+	return paymentUtils.WithRefundLock(orderNo, func() error {
+		return s.refund(ctx, orderNo, amount, reason)
+	})
+}
+
+// refund 校验退款额度、保存退款记录并调用微信退款 API，需持有退款锁
+func (s *WechatPayService) refund(ctx context.Context, orderNo string, amount int64, reason string) error {
+	// 1. 查询支付记录并校验退款金额，处理中的退款同样占用可退金额
+	p, err := s.repository.GetByOrderNo(ctx, orderNo)
+	if err != nil {
+		return fmt.Errorf("get payment record error: %w", err)
+	}
+	processing, err := s.repository.ProcessingRefundAmount(ctx, p.ID)
+	if err != nil {
+		return fmt.Errorf("get processing refunds error: %w", err)
+	}
+	if err := p.CheckRefund(amount, processing); err != nil {
+		return err
+	}
+
+	// 2. 先保存处理中的退款记录，再调用微信退款 API
+	refund := &payment.Refund{
+		RefundNo:  "R" + paymentUtils.GenerateOrderNo(),
+		PaymentID: p.ID,
+		OrderNo:   orderNo,
+		Provider:  string(types.ProviderWechat),
+		Amount:    amount,
+		Reason:    reason,
+		Status:    string(payment.RefundStatusProcessing),
+	}
+	if err := s.repository.CreateRefund(ctx, refund); err != nil {
+		return fmt.Errorf("create refund record error: %w", err)
+	}
+
+	resp, err := s.api.CreateRefund(ctx, refunddomestic.CreateRequest{
+		OutTradeNo:  core.String(orderNo),
+		OutRefundNo: core.String(refund.RefundNo),
+		Reason:      core.String(reason),
+		Amount: &refunddomestic.AmountReq{
+			Refund:   core.Int64(amount),
+			Total:    core.Int64(p.Amount),
+			Currency: core.String("CNY"),
+		},
+	})
+	if err != nil {
+		refund.Status = string(payment.RefundStatusFailed)
+		if updateErr := s.repository.UpdateRefund(ctx, refund); updateErr != nil {
+			return fmt.Errorf("update refund record error: %w", updateErr)
+		}
+		return fmt.Errorf("wechat refund error: %w", err)
+	}
+
+	// 3. 按退款状态记账
+	return s.applyRefund(ctx, refund, resp)
+}
+
+// SyncRefund 查询处理中的退款，微信退款成功后计入订单的已退金额，关闭或异常时标记为失败
+func (s *WechatPayService) SyncRefund(ctx context.Context, refund *payment.Refund) error {
+	return paymentUtils.WithRefundLock(refund.OrderNo, func() error {
+		resp, err := s.api.QueryRefund(ctx, refunddomestic.QueryByOutRefundNoRequest{
+			OutRefundNo: core.String(refund.RefundNo),
+		})
+		if err != nil {
+			return fmt.Errorf("query wechat refund error: %w", err)
+		}
+		return s.applyRefund(ctx, refund, resp)
+	})
+}
+
+// applyRefund 根据微信退款状态更新退款记录，只有 SUCCESS 会累加订单的已退金额
+func (s *WechatPayService) applyRefund(ctx context.Context, refund *payment.Refund, resp *refunddomestic.Refund) error {
+	if resp.RefundId != nil {
+		refund.ProviderRefundID = *resp.RefundId
+	}
+
+	var status refunddomestic.Status
+	if resp.Status != nil {
+		status = *resp.Status
+	}
+	switch status {
+	case refunddomestic.STATUS_SUCCESS:
+		if err := s.repository.FinishRefund(ctx, refund); err != nil {
+			return fmt.Errorf("finish refund error: %w", err)
+		}
+		return nil
+	case refunddomestic.STATUS_CLOSED, refunddomestic.STATUS_ABNORMAL:
+		refund.Status = string(payment.RefundStatusFailed)
+	}
+
+	if err := s.repository.UpdateRefund(ctx, refund); err != nil {
+		return fmt.Errorf("update refund record error: %w", err)
+	}
 	return nil
-} 
\ No newline at end of file
+}
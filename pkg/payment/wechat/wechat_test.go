@@ -0,0 +1,288 @@
+package wechat
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/wechatpay-apiv3/wechatpay-go/core"
+	"github.com/wechatpay-apiv3/wechatpay-go/services/payments"
+	"github.com/wechatpay-apiv3/wechatpay-go/services/payments/jsapi"
+	"github.com/wechatpay-apiv3/wechatpay-go/services/refunddomestic"
+
+	"tarot/app/models/payment"
+	"tarot/app/models/reading"
+	"tarot/app/repositories"
+	"tarot/pkg/database"
+	"tarot/pkg/payment/types"
+	"tarot/pkg/testutil"
+)
+
+// stubAPI 记录调用并返回预设结果的微信支付接口
+type stubAPI struct {
+	mu           sync.Mutex
+	refundStatus refunddomestic.Status
+	refundErr    error
+	refunds      []refunddomestic.CreateRequest
+	closed       []string
+	transaction  *payments.Transaction
+	prepays      int
+}
+
+func (s *stubAPI) Prepay(ctx context.Context, req jsapi.PrepayRequest) (*jsapi.PrepayResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.prepays++
+	return &jsapi.PrepayResponse{PrepayId: core.String("wx_prepay")}, nil
+}
+
+func (s *stubAPI) CloseOrder(ctx context.Context, req jsapi.CloseOrderRequest) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = append(s.closed, *req.OutTradeNo)
+	return nil
+}
+
+func (s *stubAPI) QueryOrder(ctx context.Context, req jsapi.QueryOrderByOutTradeNoRequest) (*payments.Transaction, error) {
+	return s.transaction, nil
+}
+
+func (s *stubAPI) CreateRefund(ctx context.Context, req refunddomestic.CreateRequest) (*refunddomestic.Refund, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.refunds = append(s.refunds, req)
+	if s.refundErr != nil {
+		return nil, s.refundErr
+	}
+	// 模拟渠道耗时，放大并发退款的竞争窗口
+	time.Sleep(5 * time.Millisecond)
+	return &refunddomestic.Refund{
+		RefundId: core.String("wx_" + *req.OutRefundNo),
+		Status:   s.refundStatus.Ptr(),
+	}, nil
+}
+
+func (s *stubAPI) QueryRefund(ctx context.Context, req refunddomestic.QueryByOutRefundNoRequest) (*refunddomestic.Refund, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return &refunddomestic.Refund{
+		RefundId: core.String("wx_" + *req.OutRefundNo),
+		Status:   s.refundStatus.Ptr(),
+	}, nil
+}
+
+func (s *stubAPI) refundCalls() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.refunds)
+}
+
+func newTestService(t *testing.T, api *stubAPI) *WechatPayService {
+	t.Helper()
+	testutil.SetupDB(t, &payment.Payment{}, &payment.Refund{}, &payment.PaymentEvent{}, &reading.Reading{})
+	testutil.SetupRedis(t)
+	return &WechatPayService{
+		api:        api,
+		appID:      "wx_app",
+		mchID:      "mch",
+		repository: repositories.NewPaymentRepository(),
+	}
+}
+
+func createPaid(t *testing.T, amount int64) *payment.Payment {
+	t.Helper()
+	now := time.Now()
+	p := &payment.Payment{
+		OrderNo:       "W" + now.Format("150405.000000000"),
+		UserID:        "user-1",
+		Provider:      string(payment.ProviderWechat),
+		Amount:        amount,
+		Status:        string(payment.StatusPaid),
+		TransactionID: "tx-1",
+		PayAt:         &now,
+	}
+	if err := database.DB.Create(p).Error; err != nil {
+		t.Fatalf("create payment: %v", err)
+	}
+	return p
+}
+
+func reload(t *testing.T, orderNo string) *payment.Payment {
+	t.Helper()
+	p, err := repositories.NewPaymentRepository().GetByOrderNo(context.Background(), orderNo)
+	if err != nil {
+		t.Fatalf("reload payment: %v", err)
+	}
+	return p
+}
+
+func TestRefundPaymentFull(t *testing.T) {
+	api := &stubAPI{refundStatus: refunddomestic.STATUS_SUCCESS}
+	s := newTestService(t, api)
+	p := createPaid(t, 2000)
+
+	if err := s.RefundPayment(context.Background(), p.OrderNo, 2000, "full"); err != nil {
+		t.Fatalf("RefundPayment: %v", err)
+	}
+
+	got := reload(t, p.OrderNo)
+	if got.Status != string(payment.StatusRefunded) || got.RefundedAmount != 2000 {
+		t.Fatalf("status=%s refunded=%d, want refunded/2000", got.Status, got.RefundedAmount)
+	}
+	if *api.refunds[0].Amount.Total != 2000 || *api.refunds[0].Amount.Refund != 2000 {
+		t.Fatalf("unexpected refund request amount: %+v", api.refunds[0].Amount)
+	}
+}
+
+func TestRefundPaymentPartialAndOverRefund(t *testing.T) {
+	api := &stubAPI{refundStatus: refunddomestic.STATUS_SUCCESS}
+	s := newTestService(t, api)
+	p := createPaid(t, 2000)
+	ctx := context.Background()
+
+	for _, amount := range []int64{500, 700} {
+		if err := s.RefundPayment(ctx, p.OrderNo, amount, "partial"); err != nil {
+			t.Fatalf("RefundPayment(%d): %v", amount, err)
+		}
+	}
+	got := reload(t, p.OrderNo)
+	if got.Status != string(payment.StatusPaid) || got.RefundedAmount != 1200 {
+		t.Fatalf("status=%s refunded=%d, want paid/1200", got.Status, got.RefundedAmount)
+	}
+
+	// 剩余 800，超出部分在调用微信之前被拒绝
+	if err := s.RefundPayment(ctx, p.OrderNo, 801, "over"); !errors.Is(err, payment.ErrInvalidRefundAmount) {
+		t.Fatalf("over refund err = %v, want ErrInvalidRefundAmount", err)
+	}
+	if api.refundCalls() != 2 {
+		t.Fatalf("refund calls = %d, want 2", api.refundCalls())
+	}
+
+	if err := s.RefundPayment(ctx, p.OrderNo, 800, "rest"); err != nil {
+		t.Fatalf("refund rest: %v", err)
+	}
+	if got := reload(t, p.OrderNo); got.Status != string(payment.StatusRefunded) {
+		t.Fatalf("status = %s, want refunded", got.Status)
+	}
+}
+
+func TestRefundPaymentProcessingAppliedAfterSync(t *testing.T) {
+	api := &stubAPI{refundStatus: refunddomestic.STATUS_PROCESSING}
+	s := newTestService(t, api)
+	p := createPaid(t, 2000)
+	ctx := context.Background()
+
+	if err := s.RefundPayment(ctx, p.OrderNo, 1500, "slow"); err != nil {
+		t.Fatalf("RefundPayment: %v", err)
+	}
+	if got := reload(t, p.OrderNo); got.RefundedAmount != 0 || got.Status != string(payment.StatusPaid) {
+		t.Fatalf("processing refund applied early: status=%s refunded=%d", got.Status, got.RefundedAmount)
+	}
+
+	// 处理中的退款占用可退金额
+	if err := s.RefundPayment(ctx, p.OrderNo, 1000, "second"); !errors.Is(err, payment.ErrInvalidRefundAmount) {
+		t.Fatalf("refund beyond processing err = %v, want ErrInvalidRefundAmount", err)
+	}
+
+	var refund payment.Refund
+	if err := database.DB.Where("order_no = ?", p.OrderNo).First(&refund).Error; err != nil {
+		t.Fatalf("load refund: %v", err)
+	}
+	if !refund.IsProcessing() {
+		t.Fatalf("refund status = %s, want processing", refund.Status)
+	}
+
+	api.mu.Lock()
+	api.refundStatus = refunddomestic.STATUS_SUCCESS
+	api.mu.Unlock()
+	for i := 0; i < 2; i++ { // 重复补查不会重复累加
+		if err := s.SyncRefund(ctx, &refund); err != nil {
+			t.Fatalf("SyncRefund: %v", err)
+		}
+	}
+
+	if got := reload(t, p.OrderNo); got.RefundedAmount != 1500 {
+		t.Fatalf("refunded = %d, want 1500", got.RefundedAmount)
+	}
+}
+
+func TestRefundPaymentProviderErrorMarksFailed(t *testing.T) {
+	api := &stubAPI{refundErr: errors.New("boom")}
+	s := newTestService(t, api)
+	p := createPaid(t, 2000)
+
+	if err := s.RefundPayment(context.Background(), p.OrderNo, 2000, "x"); err == nil {
+		t.Fatal("expected provider error")
+	}
+
+	var refund payment.Refund
+	database.DB.Where("order_no = ?", p.OrderNo).First(&refund)
+	if refund.Status != string(payment.RefundStatusFailed) {
+		t.Fatalf("refund status = %s, want failed", refund.Status)
+	}
+	if got := reload(t, p.OrderNo); got.RefundedAmount != 0 {
+		t.Fatalf("refunded = %d, want 0", got.RefundedAmount)
+	}
+}
+
+func TestRefundPaymentConcurrentNeverOverRefunds(t *testing.T) {
+	api := &stubAPI{refundStatus: refunddomestic.STATUS_SUCCESS}
+	s := newTestService(t, api)
+	p := createPaid(t, 2000)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := s.RefundPayment(context.Background(), p.OrderNo, 1500, "race")
+			if err != nil && !errors.Is(err, payment.ErrRefundInProgress) && !errors.Is(err, payment.ErrInvalidRefundAmount) {
+				t.Errorf("unexpected error: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	got := reload(t, p.OrderNo)
+	if got.RefundedAmount != 1500 || api.refundCalls() != 1 {
+		t.Fatalf("refunded = %d with %d provider calls, want exactly one 1500 refund", got.RefundedAmount, api.refundCalls())
+	}
+}
+
+func TestCancelPaymentRejectsPaidOrder(t *testing.T) {
+	api := &stubAPI{}
+	s := newTestService(t, api)
+	p := createPaid(t, 2000)
+
+	if err := s.CancelPayment(context.Background(), p.OrderNo); !errors.Is(err, payment.ErrNotCancelable) {
+		t.Fatalf("CancelPayment err = %v, want ErrNotCancelable", err)
+	}
+	if len(api.closed) != 0 {
+		t.Fatalf("wechat close called for a paid order: %v", api.closed)
+	}
+	if got := reload(t, p.OrderNo); got.Status != string(payment.StatusPaid) {
+		t.Fatalf("status = %s, want paid", got.Status)
+	}
+}
+
+func TestCreatePaymentRejectsNonPositiveAmount(t *testing.T) {
+	api := &stubAPI{}
+	s := newTestService(t, api)
+
+	for _, amount := range []int64{0, -100} {
+		_, err := s.CreatePayment(context.Background(), &types.Request{UserID: "user-1", Amount: amount})
+		if !errors.Is(err, payment.ErrInvalidPayment) {
+			t.Fatalf("amount %d: err = %v, want ErrInvalidPayment", amount, err)
+		}
+	}
+	if api.prepays != 0 {
+		t.Fatalf("wechat prepay called %d times for invalid amounts", api.prepays)
+	}
+	var count int64
+	database.DB.Model(&payment.Payment{}).Count(&count)
+	if count != 0 {
+		t.Fatalf("%d payments created for invalid amounts", count)
+	}
+}
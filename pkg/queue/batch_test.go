@@ -0,0 +1,147 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"tarot/pkg/testutil"
+)
+
+// taskIDs 取出任务 ID
+func taskIDs(tasks []*TarotTask) []string {
+	ids := make([]string, len(tasks))
+	for i, task := range tasks {
+		ids[i] = task.ID
+	}
+	return ids
+}
+
+func TestDequeueTasksDrainsDeepQueueInBatches(t *testing.T) {
+	server := testutil.SetupRedis(t)
+	qs := NewQueueService()
+	ctx := context.Background()
+	ids := pushUserTasks(t, qs, "user-1", 25)
+
+	var got []string
+	for _, want := range []int{10, 10, 5} {
+		tasks, err := qs.DequeueTasks(ctx, "worker-1", 10)
+		if err != nil || len(tasks) != want {
+			t.Fatalf("DequeueTasks = %d tasks, %v, want %d", len(tasks), err, want)
+		}
+		got = append(got, taskIDs(tasks)...)
+	}
+	if !slices.Equal(got, ids) {
+		t.Fatalf("batches dequeued %v, want submission order %v", got, ids)
+	}
+
+	// 取出的任务全部记入处理中列表，确认后移除
+	processing, _ := server.List(qs.processingKey("worker-1"))
+	if len(processing) != 25 {
+		t.Fatalf("processing list has %d tasks, want 25", len(processing))
+	}
+	if n, _ := qs.Length(ctx); n != 0 {
+		t.Fatalf("queue length after draining = %d, want 0", n)
+	}
+}
+
+func TestDequeueTasksBatchesLegacyList(t *testing.T) {
+	server := testutil.SetupRedis(t)
+	qs := NewQueueService()
+	ctx := context.Background()
+
+	// 升级前的任务保存在旧列表中，LPUSH 入队、RPOP 出队
+	for _, id := range []string{"legacy-1", "legacy-2", "legacy-3"} {
+		task := newTask("free")
+		task.ID = id
+		data, err := json.Marshal(task)
+		if err != nil {
+			t.Fatalf("marshal task: %v", err)
+		}
+		server.Lpush(qs.tasksKey(), string(data))
+	}
+
+	tasks, err := qs.DequeueTasks(ctx, "worker-1", 10)
+	if err != nil || !slices.Equal(taskIDs(tasks), []string{"legacy-1", "legacy-2", "legacy-3"}) {
+		t.Fatalf("DequeueTasks = %v, %v, want the legacy tasks in order", taskIDs(tasks), err)
+	}
+	if processing, _ := server.List(qs.processingKey("worker-1")); len(processing) != 3 {
+		t.Fatalf("processing list has %d tasks, want 3", len(processing))
+	}
+}
+
+func TestDequeueTasksBlocksOnEmptyQueue(t *testing.T) {
+	testutil.SetupRedis(t)
+	qs := NewQueueService()
+	ctx := context.Background()
+
+	// 没有任务时等待 dequeueTimeout 后返回 ErrQueueEmpty
+	start := time.Now()
+	if _, err := qs.DequeueTasks(ctx, "worker-1", 10); !errors.Is(err, ErrQueueEmpty) {
+		t.Fatalf("DequeueTasks on an empty queue = %v, want ErrQueueEmpty", err)
+	}
+	if elapsed := time.Since(start); elapsed < dequeueTimeout-200*time.Millisecond || elapsed > dequeueTimeout+time.Second {
+		t.Fatalf("empty dequeue returned after %s, want about %s", elapsed, dequeueTimeout)
+	}
+
+	// 等待期间入队的任务立即唤醒等待的工作器
+	type result struct {
+		tasks []*TarotTask
+		err   error
+	}
+	done := make(chan result, 1)
+	start = time.Now()
+	go func() {
+		tasks, err := qs.DequeueTasks(ctx, "worker-1", 10)
+		done <- result{tasks, err}
+	}()
+	time.Sleep(100 * time.Millisecond)
+	ids := pushUserTasks(t, qs, "user-1", 1)
+	select {
+	case r := <-done:
+		if r.err != nil || !slices.Equal(taskIDs(r.tasks), ids) {
+			t.Fatalf("woken dequeue = %v, %v, want %v", taskIDs(r.tasks), r.err, ids)
+		}
+		if elapsed := time.Since(start); elapsed >= dequeueTimeout {
+			t.Fatalf("woken dequeue took %s, want it before the timeout", elapsed)
+		}
+	case <-time.After(dequeueTimeout + time.Second):
+		t.Fatal("dequeue was not woken by the new task")
+	}
+}
+
+// TestWorkerReleasesRestOfBatchWhenPaused 工作器一次取出整批任务，暂停后未开始的任务按原顺序放回队列
+func TestWorkerReleasesRestOfBatchWhenPaused(t *testing.T) {
+	w, qs, release := newBlockingWorker(t)
+	server := testutil.SetupRedis(t)
+	w.config.BatchSize = 10
+	ids := pushUserTasks(t, qs, "user-1", 5)
+
+	w.Start()
+	t.Cleanup(w.Stop)
+	wid := w.workerID(0)
+	waitUntil(t, 5*time.Second, func() bool {
+		processing, _ := server.List(qs.processingKey(wid))
+		return w.Stats().InFlight == 1 && len(processing) == 5
+	}, "worker did not take the whole batch while the first task ran")
+
+	w.Pause()
+	release()
+	waitUntil(t, 5*time.Second, func() bool {
+		n, _ := qs.Length(context.Background())
+		return n == 4
+	}, "unstarted tasks were not returned to the queue")
+
+	if status, _ := qs.GetTaskStatus(context.Background(), ids[0]); status != TaskCompleted {
+		t.Fatalf("first task status = %s, want completed", status)
+	}
+	if processing, _ := server.List(qs.processingKey(wid)); len(processing) != 0 {
+		t.Fatalf("processing list still has %d tasks", len(processing))
+	}
+	if got := drain(t, qs); !slices.Equal(got, ids[1:]) {
+		t.Fatalf("released tasks dequeued as %v, want %v", got, ids[1:])
+	}
+}
@@ -0,0 +1,70 @@
+package queue
+
+import (
+	"context"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	goredis "github.com/redis/go-redis/v9"
+
+	"tarot/pkg/redis"
+	"tarot/pkg/testutil"
+)
+
+// newClusterQueue 创建使用集群模式客户端的队列服务
+// miniredis 以单节点应答 CLUSTER SLOTS，客户端按集群协议路由，脚本的 key 需位于同一槽位才能执行
+func newClusterQueue(t *testing.T) *QueueService {
+	t.Helper()
+	testutil.SetupRedis(t)
+
+	server := miniredis.RunT(t)
+	client := redis.NewClient(redis.RedisConfig{Mode: redis.ModeCluster, Addresses: []string{server.Addr()}, PoolSize: 2, MinIdleConns: 1})
+	t.Cleanup(func() { client.Client.Close() })
+	if _, ok := client.Client.(*goredis.ClusterClient); !ok {
+		t.Fatalf("client = %T, want *redis.ClusterClient", client.Client)
+	}
+
+	qs := NewQueueService()
+	qs.client = client
+	return qs
+}
+
+func TestQueueOperationsOnClusterClient(t *testing.T) {
+	qs := newClusterQueue(t)
+	ctx := context.Background()
+
+	task := newTask("free")
+	if err := qs.PushTask(ctx, task); err != nil {
+		t.Fatalf("PushTask: %v", err)
+	}
+	if n, err := qs.Length(ctx); err != nil || n != 1 {
+		t.Fatalf("Length = %d, %v, want 1", n, err)
+	}
+	if status, err := qs.GetTaskStatus(ctx, task.ID); err != nil || status != TaskPending {
+		t.Fatalf("status = %s, %v, want pending", status, err)
+	}
+
+	got, err := qs.DequeueTask(ctx, "worker-1")
+	if err != nil || got == nil || got.ID != task.ID {
+		t.Fatalf("DequeueTask = %+v, %v, want %s", got, err, task.ID)
+	}
+
+	if err := qs.SetTaskConversation(ctx, task.ID, "conv-1"); err != nil {
+		t.Fatalf("SetTaskConversation: %v", err)
+	}
+	if err := qs.UpdateTaskStatus(ctx, task.ID, TaskCompleted, "解读结果"); err != nil {
+		t.Fatalf("UpdateTaskStatus: %v", err)
+	}
+
+	progress, err := qs.GetTaskProgress(ctx, task.ID)
+	if err != nil || progress.Status != TaskCompleted || progress.Result != "解读结果" {
+		t.Fatalf("progress = %+v, %v, want completed with result", progress, err)
+	}
+	if conv, err := qs.GetTaskConversation(ctx, task.ID); err != nil || conv != "conv-1" {
+		t.Fatalf("conversation = %q, %v, want conv-1", conv, err)
+	}
+	statuses, err := qs.GetTaskStatuses(ctx, []string{task.ID, "missing"})
+	if err != nil || statuses[task.ID] != TaskCompleted {
+		t.Fatalf("statuses = %v, %v, want %s completed", statuses, err, task.ID)
+	}
+}
@@ -0,0 +1,61 @@
+package queue
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+)
+
+// compressedMarker 压缩值的首字节，任务 JSON 与解读文本都不会以该字节开头，读取时据此自动识别
+const compressedMarker byte = 0x00
+
+// compress 超过阈值的数据使用 gzip 压缩并加上标记字节
+// 未开启压缩、未超过阈值或压缩后没有变小时原样返回
+func (q *QueueService) compress(data []byte) []byte {
+	if q.compressThreshold <= 0 || len(data) < q.compressThreshold {
+		return data
+	}
+
+	var buf bytes.Buffer
+	buf.WriteByte(compressedMarker)
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		return data
+	}
+	if err := zw.Close(); err != nil {
+		return data
+	}
+	if buf.Len() >= len(data) {
+		return data
+	}
+	return buf.Bytes()
+}
+
+// decompress 还原 compress 的结果，没有标记字节的数据原样返回
+func decompress(data []byte) ([]byte, error) {
+	if len(data) == 0 || data[0] != compressedMarker {
+		return data, nil
+	}
+
+	zr, err := gzip.NewReader(bytes.NewReader(data[1:]))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress value: %w", err)
+	}
+	defer zr.Close()
+
+	out, err := io.ReadAll(zr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress value: %w", err)
+	}
+	return out, nil
+}
+
+// decompressString 还原从 Redis 读取的字符串值
+func decompressString(value string) (string, error) {
+	out, err := decompress([]byte(value))
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
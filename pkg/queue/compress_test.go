@@ -0,0 +1,117 @@
+package queue
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"strings"
+	"testing"
+
+	"tarot/pkg/testutil"
+)
+
+// longText 重复的解读文本，便于压缩
+var longText = strings.Repeat("愚者预示着新的开始，保持好奇与勇气。", 200)
+
+func TestCompressRoundTrip(t *testing.T) {
+	q := &QueueService{compressThreshold: 256}
+
+	tests := []struct {
+		name       string
+		data       []byte
+		compressed bool
+	}{
+		{"below threshold", []byte("短文本"), false},
+		{"above threshold", []byte(longText), true},
+		{"empty", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stored := q.compress(tt.data)
+			if got := len(stored) > 0 && stored[0] == compressedMarker; got != tt.compressed {
+				t.Fatalf("compressed = %v, want %v", got, tt.compressed)
+			}
+			out, err := decompress(stored)
+			if err != nil {
+				t.Fatalf("decompress: %v", err)
+			}
+			if !bytes.Equal(out, tt.data) {
+				t.Fatalf("round trip = %q, want %q", out, tt.data)
+			}
+		})
+	}
+}
+
+func TestCompressReducesSize(t *testing.T) {
+	q := &QueueService{compressThreshold: 256}
+
+	stored := q.compress([]byte(longText))
+	if len(stored)*4 > len(longText) {
+		t.Fatalf("compressed %d bytes to %d, want at least a 4x reduction", len(longText), len(stored))
+	}
+}
+
+func TestCompressSkipsWhenDisabledOrNotSmaller(t *testing.T) {
+	disabled := &QueueService{}
+	if stored := disabled.compress([]byte(longText)); string(stored) != longText {
+		t.Fatal("compressed a value with compression disabled")
+	}
+
+	random := make([]byte, 1024)
+	rand.Read(random)
+	q := &QueueService{compressThreshold: 256}
+	if stored := q.compress(random); !bytes.Equal(stored, random) {
+		t.Fatal("stored incompressible data compressed, want it unchanged")
+	}
+}
+
+func TestDecompressRejectsCorruptValue(t *testing.T) {
+	if _, err := decompress([]byte{compressedMarker, 'x', 'y'}); err == nil {
+		t.Fatal("decompress accepted a corrupt value")
+	}
+}
+
+// TestCompressedPayloadsInRedis 超过阈值的任务 JSON 与结果在 Redis 中压缩存储，读取时自动还原
+func TestCompressedPayloadsInRedis(t *testing.T) {
+	server := testutil.SetupRedis(t)
+	testutil.SetConfig(t, "queue.compress_threshold", 256)
+	qs := NewQueueService()
+	ctx := context.Background()
+
+	task := newTask("free")
+	task.Question = longText
+	if err := qs.PushTask(ctx, task); err != nil {
+		t.Fatalf("PushTask: %v", err)
+	}
+	stored, err := server.List(qs.userTasksPrefix() + task.UserID)
+	if err != nil || len(stored) != 1 {
+		t.Fatalf("user list = %d items, %v", len(stored), err)
+	}
+	if stored[0][0] != compressedMarker || len(stored[0]) >= len(longText) {
+		t.Fatalf("stored task is %d bytes and not compressed", len(stored[0]))
+	}
+	got, err := qs.DequeueTask(ctx, "worker-1")
+	if err != nil || got == nil || got.Question != longText {
+		t.Fatalf("DequeueTask returned a different task: %v", err)
+	}
+
+	if err := qs.UpdateTaskStatus(ctx, task.ID, TaskCompleted, longText); err != nil {
+		t.Fatalf("UpdateTaskStatus: %v", err)
+	}
+	raw, _ := server.Get(qs.resultKey(task.ID))
+	if raw == "" || raw[0] != compressedMarker || len(raw) >= len(longText) {
+		t.Fatalf("stored result is %d bytes and not compressed", len(raw))
+	}
+	result, err := qs.GetTaskResult(ctx, task.ID)
+	if err != nil || result == nil || result.Result != longText {
+		t.Fatalf("GetTaskResult returned a different result: %v", err)
+	}
+
+	// 未超过阈值的值原样存储
+	if err := qs.UpdateTaskStatus(ctx, "task-short", TaskCompleted, "短结果"); err != nil {
+		t.Fatalf("UpdateTaskStatus: %v", err)
+	}
+	if raw, _ := server.Get(qs.resultKey("task-short")); raw != "短结果" {
+		t.Fatalf("short result stored as %q", raw)
+	}
+}
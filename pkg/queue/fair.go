@@ -0,0 +1,163 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+)
+
+// 按用户公平调度
+//
+// 每个用户的任务保存在各自的列表中（用户内保持 FIFO），有待处理任务的用户按顺序排在轮转列表里。
+// 出队时从轮转列表取出排在最前的用户，取走其最早的任务，用户仍有任务时重新排到队尾，
+// 因此单个用户大量提交任务时，其他用户的任务最多等待一轮（每个活跃用户一个任务）。
+//
+// 所有键使用相同的哈希标签 {fair}，集群模式下位于同一个槽，
+// 脚本中按前缀拼接出的用户列表 key 也在该槽内，可以原子地操作。
+
+// signalCap 入队信号列表的最大长度，只用于唤醒空闲的工作器，多余的信号没有意义
+const signalCap = 100
+
+// fairPushScript 将任务放入用户列表，用户首次有任务时加入轮转列表，并发出入队信号
+// KEYS: 用户列表, 轮转列表, 活跃用户集合, 信号列表
+// ARGV: 任务 JSON, 用户 ID, 信号列表上限
+var fairPushScript = goredis.NewScript(`
+redis.call("lpush", KEYS[1], ARGV[1])
+if redis.call("sadd", KEYS[3], ARGV[2]) == 1 then
+	redis.call("lpush", KEYS[2], ARGV[2])
+end
+redis.call("lpush", KEYS[4], "1")
+redis.call("ltrim", KEYS[4], 0, tonumber(ARGV[3]) - 1)
+return 1
+`)
+
+// fairPopScript 按轮转顺序取出最多 ARGV[2] 个任务，每次取排在最前的用户的最早任务，
+// 同时放入工作器的处理中列表，没有任务时返回空列表
+// KEYS: 轮转列表, 活跃用户集合, 处理中列表
+// ARGV: 用户列表 key 前缀, 最多取出的任务数
+var fairPopScript = goredis.NewScript(`
+local limit = tonumber(ARGV[2])
+local tasks = {}
+while #tasks < limit do
+	local user = redis.call("rpop", KEYS[1])
+	if not user then
+		break
+	end
+	local key = ARGV[1] .. user
+	local task = redis.call("rpop", key)
+	if task then
+		if redis.call("llen", key) > 0 then
+			redis.call("lpush", KEYS[1], user)
+		else
+			redis.call("srem", KEYS[2], user)
+		end
+		redis.call("lpush", KEYS[3], task)
+		tasks[#tasks + 1] = task
+	else
+		redis.call("srem", KEYS[2], user)
+	end
+end
+return tasks
+`)
+
+// fairReclaimScript 将处理中列表里的任务放回所属用户列表的队首，优先重新处理
+// 只有从处理中列表移除成功时才放回，多个实例同时回收也不会重复入队
+// KEYS: 处理中列表, 用户列表, 轮转列表, 活跃用户集合, 信号列表
+// ARGV: 任务, 用户 ID, 信号列表上限
+var fairReclaimScript = goredis.NewScript(`
+if redis.call("lrem", KEYS[1], 1, ARGV[1]) == 0 then
+	return 0
+end
+redis.call("rpush", KEYS[2], ARGV[1])
+if redis.call("sadd", KEYS[4], ARGV[2]) == 1 then
+	redis.call("lpush", KEYS[3], ARGV[2])
+end
+redis.call("lpush", KEYS[5], "1")
+redis.call("ltrim", KEYS[5], 0, tonumber(ARGV[3]) - 1)
+return 1
+`)
+
+// fairLengthScript 统计所有用户列表中的任务总数
+// KEYS: 轮转列表
+// ARGV: 用户列表 key 前缀
+var fairLengthScript = goredis.NewScript(`
+local total = 0
+for _, user in ipairs(redis.call("lrange", KEYS[1], 0, -1)) do
+	total = total + redis.call("llen", ARGV[1] .. user)
+end
+return total
+`)
+
+// fairKey 公平调度相关的 key，默认队列沿用不带队列名称的 key
+func (q *QueueService) fairKey(name string) string {
+	if q.isDefault() {
+		return fmt.Sprintf("%s:{fair}:%s", q.prefix, name)
+	}
+	return fmt.Sprintf("%s:{fair}:%s:%s", q.prefix, q.name, name)
+}
+
+// userTasksPrefix 用户任务列表 key 的前缀，后接用户 ID
+func (q *QueueService) userTasksPrefix() string {
+	return q.fairKey("user:")
+}
+
+// fairUser 任务所属的调度用户，缺少用户 ID 的任务共用一个列表
+func fairUser(task *TarotTask) string {
+	if task.UserID == "" {
+		return "-"
+	}
+	return task.UserID
+}
+
+// pushFair 将序列化后的任务放入所属用户的列表
+func (q *QueueService) pushFair(ctx context.Context, user string, taskJSON []byte) error {
+	keys := []string{
+		q.userTasksPrefix() + user,
+		q.fairKey("users"),
+		q.fairKey("active"),
+		q.fairKey("signal"),
+	}
+	return fairPushScript.Run(ctx, q.client.Client, keys, taskJSON, user, signalCap).Err()
+}
+
+// popFair 按用户轮转取出最多 limit 个任务并放入 workerID 的处理中列表，没有任务时返回空
+func (q *QueueService) popFair(ctx context.Context, workerID string, limit int) ([][]byte, error) {
+	keys := []string{q.fairKey("users"), q.fairKey("active"), q.processingKey(workerID)}
+	result, err := fairPopScript.Run(ctx, q.client.Client, keys, q.userTasksPrefix(), limit).StringSlice()
+	if err != nil {
+		if err == goredis.Nil {
+			return nil, nil
+		}
+		return nil, err
+	}
+	data := make([][]byte, len(result))
+	for i, s := range result {
+		data[i] = []byte(s)
+	}
+	return data, nil
+}
+
+// reclaimFair 将处理中列表里的任务放回所属用户的队首，任务已被移除时返回 false
+func (q *QueueService) reclaimFair(ctx context.Context, processingKey, user string, data []byte) (bool, error) {
+	keys := []string{
+		processingKey,
+		q.userTasksPrefix() + user,
+		q.fairKey("users"),
+		q.fairKey("active"),
+		q.fairKey("signal"),
+	}
+	n, err := fairReclaimScript.Run(ctx, q.client.Client, keys, data, user, signalCap).Int()
+	return n == 1, err
+}
+
+// waitSignal 阻塞等待入队信号，最长 timeout
+func (q *QueueService) waitSignal(ctx context.Context, timeout time.Duration) error {
+	return q.client.Client.BRPop(ctx, timeout, q.fairKey("signal")).Err()
+}
+
+// lengthFair 所有用户列表中的任务总数
+func (q *QueueService) lengthFair(ctx context.Context) (int64, error) {
+	return fairLengthScript.Run(ctx, q.client.Client, []string{q.fairKey("users")}, q.userTasksPrefix()).Int64()
+}
@@ -0,0 +1,107 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+
+	"tarot/pkg/testutil"
+)
+
+// pushUserTasks 为 userID 依次提交 n 个任务，返回任务 ID
+func pushUserTasks(t *testing.T, qs *QueueService, userID string, n int) []string {
+	t.Helper()
+	ids := make([]string, n)
+	for i := range ids {
+		task := newTask("free")
+		task.ID = fmt.Sprintf("%s-task-%d", userID, i)
+		task.UserID = userID
+		if err := qs.PushTask(context.Background(), task); err != nil {
+			t.Fatalf("PushTask: %v", err)
+		}
+		ids[i] = task.ID
+	}
+	return ids
+}
+
+// drain 逐个取出队列中的所有任务，返回出队顺序
+func drain(t *testing.T, qs *QueueService) []string {
+	t.Helper()
+	var order []string
+	for {
+		task, err := qs.DequeueTask(context.Background(), "worker-1")
+		if err == ErrQueueEmpty {
+			return order
+		}
+		if err != nil {
+			t.Fatalf("DequeueTask: %v", err)
+		}
+		order = append(order, task.ID)
+	}
+}
+
+func TestFloodingUserDoesNotStarveOthers(t *testing.T) {
+	testutil.SetupRedis(t)
+	qs := NewQueueService()
+
+	flood := pushUserTasks(t, qs, "user-flood", 200)
+	single := pushUserTasks(t, qs, "user-single", 1)
+
+	order := drain(t, qs)
+	if len(order) != len(flood)+len(single) {
+		t.Fatalf("dequeued %d tasks, want %d", len(order), len(flood)+len(single))
+	}
+	// 另一个用户的任务最多排在每个活跃用户各一个任务之后
+	if pos := slices.Index(order, single[0]); pos < 0 || pos > 1 {
+		t.Fatalf("single user's task dequeued at position %d behind a flood of %d, want within the first round", pos, len(flood))
+	}
+
+	// 同一用户的任务保持提交顺序
+	var floodOrder []string
+	for _, id := range order {
+		if id != single[0] {
+			floodOrder = append(floodOrder, id)
+		}
+	}
+	if !slices.Equal(floodOrder, flood) {
+		t.Fatal("flooding user's tasks were not dequeued in FIFO order")
+	}
+}
+
+func TestUsersTakeTurns(t *testing.T) {
+	testutil.SetupRedis(t)
+	qs := NewQueueService()
+
+	a := pushUserTasks(t, qs, "user-a", 3)
+	b := pushUserTasks(t, qs, "user-b", 2)
+	c := pushUserTasks(t, qs, "user-c", 1)
+
+	want := []string{a[0], b[0], c[0], a[1], b[1], a[2]}
+	if got := drain(t, qs); !slices.Equal(got, want) {
+		t.Fatalf("dequeue order = %v, want %v", got, want)
+	}
+}
+
+func TestBatchDequeueIsFair(t *testing.T) {
+	testutil.SetupRedis(t)
+	qs := NewQueueService()
+
+	flood := pushUserTasks(t, qs, "user-flood", 50)
+	single := pushUserTasks(t, qs, "user-single", 1)
+
+	tasks, err := qs.DequeueTasks(context.Background(), "worker-1", 10)
+	if err != nil {
+		t.Fatalf("DequeueTasks: %v", err)
+	}
+	var ids []string
+	for _, task := range tasks {
+		ids = append(ids, task.ID)
+	}
+	if !slices.Contains(ids, single[0]) {
+		t.Fatalf("batch %v does not include the single user's task", ids)
+	}
+	if n, err := qs.Length(context.Background()); err != nil || n != int64(len(flood)+len(single)-len(tasks)) {
+		t.Fatalf("Length = %d, %v after taking %d tasks", n, err, len(tasks))
+	}
+}
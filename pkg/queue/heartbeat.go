@@ -0,0 +1,196 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"tarot/pkg/logger"
+)
+
+// 工作器心跳
+//
+// 每个工作器以 <prefix>:workers:<id> 为心跳键，每 heartbeatInterval 刷新一次，过期时间为 heartbeatTTL。
+// 心跳过期说明工作器所在的进程已退出或失联，其处理中列表里的任务由其他存活的工作器组放回队列。
+const (
+	heartbeatInterval = 10 * time.Second
+	heartbeatTTL      = 30 * time.Second
+)
+
+// instanceID 当前进程的标识，用于区分多实例部署中的工作器
+var instanceID = func() string {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "unknown"
+	}
+	return fmt.Sprintf("%s-%d", host, os.Getpid())
+}()
+
+// heartbeatKey 工作器心跳的 key
+func (q *QueueService) heartbeatKey(workerID string) string {
+	return fmt.Sprintf("%s:workers:%s", q.prefix, workerID)
+}
+
+// processingKey 工作器处理中列表的 key，与公平调度的 key 位于同一个槽
+func (q *QueueService) processingKey(workerID string) string {
+	return q.fairKey("processing:" + workerID)
+}
+
+// Heartbeat 刷新工作器的心跳
+func (q *QueueService) Heartbeat(ctx context.Context, workerIDs ...string) error {
+	if len(workerIDs) == 0 {
+		return nil
+	}
+	now := time.Now().Unix()
+	pipe := q.client.Client.Pipeline()
+	for _, id := range workerIDs {
+		pipe.Set(ctx, q.heartbeatKey(id), now, heartbeatTTL)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to refresh worker heartbeat: %w", err)
+	}
+	return nil
+}
+
+// RemoveHeartbeat 工作器正常退出时删除心跳
+func (q *QueueService) RemoveHeartbeat(ctx context.Context, workerID string) error {
+	if err := q.client.Client.Del(ctx, q.heartbeatKey(workerID)).Err(); err != nil {
+		return fmt.Errorf("failed to remove worker heartbeat: %w", err)
+	}
+	return nil
+}
+
+// ActiveWorkers 列出心跳未过期的工作器 ID（包含所有实例与队列）
+func (q *QueueService) ActiveWorkers(ctx context.Context) ([]string, error) {
+	prefix := q.heartbeatKey("")
+	var ids []string
+	err := q.scanKeys(ctx, prefix+"*", 100, func(ctx context.Context, keys []string) error {
+		for _, key := range keys {
+			ids = append(ids, strings.TrimPrefix(key, prefix))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list active workers: %w", err)
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// AckTask 任务处理结束，从工作器的处理中列表移除
+func (q *QueueService) AckTask(ctx context.Context, workerID string, task *TarotTask) error {
+	if len(task.raw) == 0 {
+		return nil
+	}
+	if err := q.client.Client.LRem(ctx, q.processingKey(workerID), 1, task.raw).Err(); err != nil {
+		return fmt.Errorf("failed to ack task: %w", err)
+	}
+	return nil
+}
+
+// RequeueTask 将工作器处理中的任务放回所属用户的队首，并重置为等待状态
+func (q *QueueService) RequeueTask(ctx context.Context, workerID string, task *TarotTask) error {
+	if len(task.raw) == 0 {
+		return nil
+	}
+	ok, err := q.reclaimFair(ctx, q.processingKey(workerID), fairUser(task), task.raw)
+	if err != nil || !ok {
+		return err
+	}
+	return q.UpdateTaskStatus(ctx, task.ID, TaskPending, "")
+}
+
+// ReclaimStaleTasks 将心跳已过期的工作器处理中的任务放回队列，返回放回的任务数
+// 放回的任务排在所属用户的队首，状态重置为 pending
+func (q *QueueService) ReclaimStaleTasks(ctx context.Context) (int, error) {
+	prefix := q.processingKey("")
+	var stale []string
+	err := q.scanKeys(ctx, prefix+"*", 100, func(ctx context.Context, keys []string) error {
+		for _, key := range keys {
+			alive, err := q.client.Client.Exists(ctx, q.heartbeatKey(strings.TrimPrefix(key, prefix))).Result()
+			if err != nil {
+				return err
+			}
+			if alive == 0 {
+				stale = append(stale, key)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to find stale workers: %w", err)
+	}
+
+	reclaimed := 0
+	for _, key := range stale {
+		items, err := q.client.Client.LRange(ctx, key, 0, -1).Result()
+		if err != nil {
+			return reclaimed, fmt.Errorf("failed to read processing list: %w", err)
+		}
+		// 列表左端是最近取出的任务，任务逐个放回用户列表的队首，
+		// 因此从最近取出的任务开始放回，最早取出的任务最后放回、排在最前，保持原有顺序
+		for _, item := range items {
+			data := []byte(item)
+			task, err := unmarshalTask(data)
+			if err != nil {
+				q.client.Client.LRem(ctx, key, 1, data)
+				continue
+			}
+			ok, err := q.reclaimFair(ctx, key, fairUser(task), data)
+			if err != nil {
+				return reclaimed, fmt.Errorf("failed to reclaim task %s: %w", task.ID, err)
+			}
+			if !ok {
+				continue
+			}
+			reclaimed++
+			if err := q.UpdateTaskStatus(ctx, task.ID, TaskPending, ""); err != nil {
+				logger.WarnString("Queue", "Reclaim", fmt.Sprintf("Task %s: %v", task.ID, err))
+			}
+		}
+	}
+	return reclaimed, nil
+}
+
+// workerID 工作器在所有实例中唯一的 ID
+func (w *Worker) workerID(id int) string {
+	return fmt.Sprintf("%s:%s:%d", instanceID, w.queueService.Name(), id)
+}
+
+// heartbeatLoop 定期刷新本组运行中工作器的心跳，并回收失联工作器的任务，直到 ctx 被取消
+// 已被 Resize 停止但仍在完成当前任务的工作器也会继续刷新心跳，避免任务被重复回收
+func (w *Worker) heartbeatLoop(ctx context.Context) {
+	ticker := time.NewTicker(heartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := w.queueService.Heartbeat(ctx, w.runningWorkers()...); err != nil {
+				logger.WarnString("Worker", "Heartbeat", err.Error())
+			}
+			n, err := w.queueService.ReclaimStaleTasks(ctx)
+			if err != nil {
+				logger.WarnString("Worker", "Reclaim", err.Error())
+			}
+			if n > 0 {
+				logger.InfoString("Worker", "Reclaim", fmt.Sprintf("Reclaimed %d tasks from stale workers", n))
+			}
+		}
+	}
+}
+
+// runningWorkers 本组当前运行中的工作器 ID
+func (w *Worker) runningWorkers() []string {
+	var ids []string
+	w.running.Range(func(key, _ interface{}) bool {
+		ids = append(ids, key.(string))
+		return true
+	})
+	return ids
+}
@@ -0,0 +1,106 @@
+package queue
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"tarot/pkg/testutil"
+)
+
+func TestActiveWorkersFollowHeartbeats(t *testing.T) {
+	server := testutil.SetupRedis(t)
+	qs := NewQueueService()
+	ctx := context.Background()
+
+	if err := qs.Heartbeat(ctx, "instance-a:default:1", "instance-b:default:1"); err != nil {
+		t.Fatalf("Heartbeat: %v", err)
+	}
+	ids, err := qs.ActiveWorkers(ctx)
+	if err != nil || !slices.Equal(ids, []string{"instance-a:default:1", "instance-b:default:1"}) {
+		t.Fatalf("ActiveWorkers = %v, %v", ids, err)
+	}
+
+	if err := qs.RemoveHeartbeat(ctx, "instance-a:default:1"); err != nil {
+		t.Fatalf("RemoveHeartbeat: %v", err)
+	}
+	if ids, _ := qs.ActiveWorkers(ctx); !slices.Equal(ids, []string{"instance-b:default:1"}) {
+		t.Fatalf("ActiveWorkers after removal = %v", ids)
+	}
+
+	server.FastForward(heartbeatTTL + time.Second)
+	if ids, _ := qs.ActiveWorkers(ctx); len(ids) != 0 {
+		t.Fatalf("ActiveWorkers after the heartbeat expired = %v, want none", ids)
+	}
+}
+
+func TestExpiredHeartbeatReclaimsTasks(t *testing.T) {
+	server := testutil.SetupRedis(t)
+	qs := NewQueueService()
+	ctx := context.Background()
+
+	ids := pushUserTasks(t, qs, "user-1", 2)
+	if err := qs.Heartbeat(ctx, "worker-lost"); err != nil {
+		t.Fatalf("Heartbeat: %v", err)
+	}
+	tasks, err := qs.DequeueTasks(ctx, "worker-lost", 2)
+	if err != nil || len(tasks) != 2 {
+		t.Fatalf("DequeueTasks = %d tasks, %v", len(tasks), err)
+	}
+	for _, id := range ids {
+		if err := qs.UpdateTaskStatus(ctx, id, TaskRunning, ""); err != nil {
+			t.Fatalf("UpdateTaskStatus: %v", err)
+		}
+	}
+
+	// 心跳未过期时任务仍归该工作器处理
+	if n, err := qs.ReclaimStaleTasks(ctx); err != nil || n != 0 {
+		t.Fatalf("ReclaimStaleTasks with a live heartbeat = %d, %v, want 0", n, err)
+	}
+
+	server.FastForward(heartbeatTTL + time.Second)
+	if n, err := qs.ReclaimStaleTasks(ctx); err != nil || n != 2 {
+		t.Fatalf("ReclaimStaleTasks = %d, %v, want 2", n, err)
+	}
+	if n, err := qs.ReclaimStaleTasks(ctx); err != nil || n != 0 {
+		t.Fatalf("second ReclaimStaleTasks = %d, %v, want the tasks reclaimed only once", n, err)
+	}
+	if server.Exists(qs.processingKey("worker-lost")) {
+		t.Fatal("processing list of the stale worker was not emptied")
+	}
+
+	for _, id := range ids {
+		if status, _ := qs.GetTaskStatus(ctx, id); status != TaskPending {
+			t.Fatalf("%s status = %s, want pending after reclamation", id, status)
+		}
+	}
+	if got := drain(t, qs); !slices.Equal(got, ids) {
+		t.Fatalf("reclaimed tasks dequeued as %v, want the original order %v", got, ids)
+	}
+}
+
+func TestReclaimedTasksGoAheadOfNewTasks(t *testing.T) {
+	server := testutil.SetupRedis(t)
+	qs := NewQueueService()
+	ctx := context.Background()
+
+	first := pushUserTasks(t, qs, "user-1", 1)
+	if _, err := qs.DequeueTask(ctx, "worker-lost"); err != nil {
+		t.Fatalf("DequeueTask: %v", err)
+	}
+	task := newTask("free")
+	task.ID = "user-1-task-new"
+	task.UserID = "user-1"
+	if err := qs.PushTask(ctx, task); err != nil {
+		t.Fatalf("PushTask: %v", err)
+	}
+
+	server.FastForward(heartbeatTTL + time.Second)
+	if n, err := qs.ReclaimStaleTasks(ctx); err != nil || n != 1 {
+		t.Fatalf("ReclaimStaleTasks = %d, %v, want 1", n, err)
+	}
+	if got := drain(t, qs); !slices.Equal(got, []string{first[0], task.ID}) {
+		t.Fatalf("dequeue order = %v, want the reclaimed task first", got)
+	}
+}
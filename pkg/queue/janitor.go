@@ -0,0 +1,176 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+	"golang.org/x/time/rate"
+
+	"tarot/pkg/logger"
+)
+
+// Janitor 定期清理队列库中的残留键
+// 任务结果与对话 ID 在状态键过期或丢失后不再可读，视为孤立键删除；
+// 缺少过期时间的任务键补设过期时间，避免永久占用内存。
+// 只使用 SCAN 游标遍历（从不使用 KEYS），并按 keysPerSecond 限速，避免阻塞 Redis
+type Janitor struct {
+	queue         *QueueService
+	interval      time.Duration
+	scanCount     int64
+	keysPerSecond int
+}
+
+// JanitorStats 一次清理的统计
+type JanitorStats struct {
+	Scanned int // 检查的键数量
+	Deleted int // 删除的孤立键数量
+	Expired int // 补设过期时间的键数量
+}
+
+// NewJanitor 创建清理任务
+// scanCount 为每次 SCAN 的 COUNT 提示，keysPerSecond 为每秒最多检查的键数量
+func NewJanitor(qs *QueueService, interval time.Duration, scanCount, keysPerSecond int) *Janitor {
+	if interval <= 0 {
+		interval = 10 * time.Minute
+	}
+	if scanCount <= 0 {
+		scanCount = 100
+	}
+	if keysPerSecond <= 0 {
+		keysPerSecond = 1000
+	}
+	return &Janitor{
+		queue:         qs,
+		interval:      interval,
+		scanCount:     int64(scanCount),
+		keysPerSecond: keysPerSecond,
+	}
+}
+
+// Start 启动清理循环，直到 ctx 被取消
+func (j *Janitor) Start(ctx context.Context) {
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			stats, err := j.Run(ctx)
+			if err != nil {
+				logger.ErrorString("Queue", "Janitor", fmt.Sprintf("清理残留键失败: %v", err))
+			}
+			if stats.Deleted > 0 || stats.Expired > 0 {
+				logger.InfoString("Queue", "Janitor", fmt.Sprintf(
+					"检查 %d 个键，删除孤立键 %d 个，补设过期时间 %d 个",
+					stats.Scanned, stats.Deleted, stats.Expired))
+			}
+		}
+	}
+}
+
+// Run 执行一次清理，出错时返回已完成部分的统计
+func (j *Janitor) Run(ctx context.Context) (JanitorStats, error) {
+	var stats JanitorStats
+	limiter := rate.NewLimiter(rate.Limit(j.keysPerSecond), j.keysPerSecond)
+
+	// 状态键是判断任务是否存在的依据，只补设过期时间，不会被删除
+	patterns := []struct {
+		match      string
+		orphanable bool
+	}{
+		{j.queue.prefix + ":status:{*}", false},
+		{j.queue.prefix + ":result:{*}", true},
+		{j.queue.prefix + ":conversation:{*}", true},
+	}
+	for _, p := range patterns {
+		err := j.queue.scanKeys(ctx, p.match, j.scanCount, func(ctx context.Context, keys []string) error {
+			if err := waitN(ctx, limiter, len(keys)); err != nil {
+				return err
+			}
+			stats.Scanned += len(keys)
+			return j.clean(ctx, keys, p.orphanable, &stats)
+		})
+		if err != nil {
+			return stats, err
+		}
+	}
+	return stats, nil
+}
+
+// clean 处理一批键：状态键已不存在的结果类键直接删除，没有过期时间的键补设过期时间
+func (j *Janitor) clean(ctx context.Context, keys []string, orphanable bool, stats *JanitorStats) error {
+	client := j.queue.client.Client
+
+	ttls := make([]*goredis.DurationCmd, len(keys))
+	exists := make([]*goredis.IntCmd, len(keys))
+	_, err := client.Pipelined(ctx, func(pipe goredis.Pipeliner) error {
+		for i, key := range keys {
+			ttls[i] = pipe.TTL(ctx, key)
+			if orphanable {
+				exists[i] = pipe.Exists(ctx, j.queue.statusKey(taskIDFromKey(key)))
+			}
+		}
+		return nil
+	})
+	if err != nil && err != goredis.Nil {
+		return fmt.Errorf("inspect keys: %w", err)
+	}
+
+	var orphans, persistent []string
+	for i, key := range keys {
+		if orphanable && exists[i].Val() == 0 {
+			orphans = append(orphans, key)
+			continue
+		}
+		// -1 表示键存在但没有过期时间，-2 表示键已被删除；未配置队列超时时不补设
+		if ttls[i].Val() == -1 && j.queue.timeout > 0 {
+			persistent = append(persistent, key)
+		}
+	}
+	if len(orphans) == 0 && len(persistent) == 0 {
+		return nil
+	}
+
+	_, err = client.Pipelined(ctx, func(pipe goredis.Pipeliner) error {
+		for _, key := range orphans {
+			pipe.Del(ctx, key)
+		}
+		for _, key := range persistent {
+			pipe.Expire(ctx, key, j.queue.timeout)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("clean keys: %w", err)
+	}
+	stats.Deleted += len(orphans)
+	stats.Expired += len(persistent)
+	return nil
+}
+
+// taskIDFromKey 从 prefix:kind:{taskID} 格式的键中取出任务 ID
+func taskIDFromKey(key string) string {
+	start := strings.LastIndex(key, "{")
+	if start < 0 || !strings.HasSuffix(key, "}") {
+		return key
+	}
+	return key[start+1 : len(key)-1]
+}
+
+// waitN 等待限速器放行 n 个键，n 超过令牌桶容量时分批等待
+func waitN(ctx context.Context, limiter *rate.Limiter, n int) error {
+	burst := limiter.Burst()
+	for n > 0 {
+		step := min(n, burst)
+		if err := limiter.WaitN(ctx, step); err != nil {
+			return err
+		}
+		n -= step
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"tarot/pkg/testutil"
+)
+
+func TestJanitorRemovesOrphansAndKeepsLiveKeys(t *testing.T) {
+	server := testutil.SetupRedis(t)
+	qs := NewQueueService()
+	ctx := context.Background()
+
+	// 仍在处理的任务：状态、结果与对话 ID 都应保留
+	live := newTask("free")
+	if err := qs.PushTask(ctx, live); err != nil {
+		t.Fatalf("PushTask: %v", err)
+	}
+	if err := qs.SetTaskConversation(ctx, live.ID, "conv-live"); err != nil {
+		t.Fatalf("SetTaskConversation: %v", err)
+	}
+	server.Set(qs.resultKey(live.ID), "live result")
+	server.SetTTL(qs.resultKey(live.ID), qs.timeout)
+
+	// 状态键已过期的任务残留下的结果与对话 ID
+	var orphans []string
+	for i := 0; i < 5; i++ {
+		id := fmt.Sprintf("task-orphan-%d", i)
+		orphans = append(orphans, qs.resultKey(id), qs.conversationKey(id))
+	}
+	for _, key := range orphans {
+		server.Set(key, "stale")
+	}
+
+	// 没有过期时间的状态键补设过期时间而不是删除
+	server.Set(qs.statusKey("task-persistent"), string(TaskCompleted))
+
+	// 队列前缀之外的键不在清理范围内
+	server.Set("session:{task-other}", "other data")
+
+	stats, err := NewJanitor(qs, time.Minute, 100, 10000).Run(ctx)
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if stats.Deleted != len(orphans) || stats.Expired != 1 {
+		t.Fatalf("stats = %+v, want %d deleted and 1 expired", stats, len(orphans))
+	}
+
+	for _, key := range orphans {
+		if server.Exists(key) {
+			t.Fatalf("orphaned key %s survived", key)
+		}
+	}
+	for _, key := range []string{qs.statusKey(live.ID), qs.resultKey(live.ID), qs.conversationKey(live.ID), "session:{task-other}"} {
+		if !server.Exists(key) {
+			t.Fatalf("live key %s was deleted", key)
+		}
+	}
+	if ttl := server.TTL(qs.statusKey("task-persistent")); ttl <= 0 || ttl > qs.timeout {
+		t.Fatalf("persistent status ttl = %s, want the queue timeout %s", ttl, qs.timeout)
+	}
+}
+
+// TestJanitorFollowsScanCursor 键数量超过单次 SCAN 的 COUNT 时沿游标遍历完所有批次
+func TestJanitorFollowsScanCursor(t *testing.T) {
+	server := testutil.SetupRedis(t)
+	qs := NewQueueService()
+	for i := 0; i < 25; i++ {
+		server.Set(qs.statusKey(fmt.Sprintf("task-%d", i)), string(TaskCompleted))
+	}
+
+	stats, err := NewJanitor(qs, time.Minute, 5, 10000).Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if stats.Scanned != 25 || stats.Expired != 25 {
+		t.Fatalf("stats = %+v, want all 25 keys scanned and expired", stats)
+	}
+}
+
+func TestJanitorRunRespectsCancellation(t *testing.T) {
+	server := testutil.SetupRedis(t)
+	qs := NewQueueService()
+	for i := 0; i < 10; i++ {
+		server.Set(qs.resultKey(fmt.Sprintf("task-orphan-%d", i)), "stale")
+	}
+
+	// 每秒只允许检查 1 个键，剩余的键需要等待限速器放行
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := NewJanitor(qs, time.Minute, 100, 1).Run(ctx)
+	if err == nil {
+		t.Fatal("Run returned nil, want the rate limiter to stop on cancellation")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("Run took %s after cancellation", elapsed)
+	}
+}
+
+func TestJanitorStartRunsOnSchedule(t *testing.T) {
+	server := testutil.SetupRedis(t)
+	qs := NewQueueService()
+	key := qs.resultKey("task-orphan")
+	server.Set(key, "stale")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		NewJanitor(qs, 20*time.Millisecond, 100, 1000).Start(ctx)
+		close(done)
+	}()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for server.Exists(key) {
+		if time.Now().After(deadline) {
+			t.Fatal("orphaned key not cleaned by the scheduled run")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Start did not return after cancellation")
+	}
+}
@@ -4,8 +4,13 @@ import (
 	"sync"
 	"sync/atomic"
 	"time"
+
+	"tarot/pkg/config"
 )
 
+// defaultMetricsSize 处理时间样本的默认保留数量
+const defaultMetricsSize = 1000
+
 // TaskID 任务ID的类型别名
 type TaskID string
 
@@ -31,7 +36,7 @@ type QueueMetrics struct {
 	totalTasks      atomic.Int64
 	successfulTasks atomic.Int64
 	failedTasks     atomic.Int64
-	processingTimes *sync.Map // 处理时间统计
+	processingTimes *processingWindow // 最近处理时间样本（固定容量环形缓冲）
 	errorRates      *sync.Map // 错误率统计
 
 	// 延迟统计
@@ -49,12 +54,14 @@ type QueueMetrics struct {
 }
 
 // NewQueueMetrics 创建新的指标收集器
+// 处理时间样本数量受 queue.metrics_size 限制，避免长时间运行后内存无限增长
 func NewQueueMetrics() *QueueMetrics {
 	return &QueueMetrics{
-		processingTimes: &sync.Map{},
+		processingTimes: newProcessingWindow(config.GetInt("queue.metrics_size", defaultMetricsSize)),
 		errorRates:      &sync.Map{},
 		waitTimeStart:   &sync.Map{},
 		pushLatency:    &LatencyStats{},
+		popLatency:     &LatencyStats{},
 		processLatency: &LatencyStats{},
 	}
 }
@@ -93,7 +100,7 @@ func (m *QueueMetrics) EndWaitTime(taskID TaskID) {
 
 // RecordProcessingTime 记录任务处理时间
 func (m *QueueMetrics) RecordProcessingTime(duration time.Duration) {
-	m.processingTimes.Store(time.Now().Unix(), duration.Milliseconds())
+	m.processingTimes.add(duration.Milliseconds())
 
 	// 更新队列长度
 	currentLength := m.queueLength.Load()
@@ -102,6 +109,47 @@ func (m *QueueMetrics) RecordProcessingTime(duration time.Duration) {
 	}
 }
 
+// SetQueueLength 更新当前队列长度，并同步峰值
+func (m *QueueMetrics) SetQueueLength(n int64) {
+	m.queueLength.Store(n)
+	for {
+		peak := m.peakQueueLength.Load()
+		if n <= peak || m.peakQueueLength.CompareAndSwap(peak, n) {
+			return
+		}
+	}
+}
+
+// MetricsSummary 指标摘要，用于定期输出到日志
+type MetricsSummary struct {
+	Processed       int64
+	Failed          int64
+	AvgLatency      time.Duration
+	QueueLength     int64
+	PeakQueueLength int64
+}
+
+// Summary 当前指标摘要
+func (m *QueueMetrics) Summary() MetricsSummary {
+	return MetricsSummary{
+		Processed:       m.successfulTasks.Load(),
+		Failed:          m.failedTasks.Load(),
+		AvgLatency:      m.AvgProcessingTime(),
+		QueueLength:     m.queueLength.Load(),
+		PeakQueueLength: m.peakQueueLength.Load(),
+	}
+}
+
+// AvgProcessingTime 最近样本的平均处理时间
+func (m *QueueMetrics) AvgProcessingTime() time.Duration {
+	return time.Duration(m.processingTimes.average()) * time.Millisecond
+}
+
+// ProcessingSamples 当前保留的处理时间样本数量
+func (m *QueueMetrics) ProcessingSamples() int {
+	return m.processingTimes.len()
+}
+
 // RecordPushLatency 记录推送延迟
 func (m *QueueMetrics) RecordPushLatency(d time.Duration) {
 	if m.pushLatency == nil {
@@ -141,3 +189,61 @@ func (s *LatencyStats) record(d time.Duration) {
 		s.max = d
 	}
 }
+
+// processingWindow 固定容量的处理时间环形缓冲，写满后覆盖最旧的样本
+type processingWindow struct {
+	mu      sync.Mutex
+	samples []int64 // 毫秒
+	next    int
+	full    bool
+}
+
+func newProcessingWindow(size int) *processingWindow {
+	if size <= 0 {
+		size = defaultMetricsSize
+	}
+	return &processingWindow{samples: make([]int64, size)}
+}
+
+// add 写入一个样本
+func (w *processingWindow) add(ms int64) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.samples[w.next] = ms
+	w.next = (w.next + 1) % len(w.samples)
+	if w.next == 0 {
+		w.full = true
+	}
+}
+
+// len 当前样本数量
+func (w *processingWindow) len() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.full {
+		return len(w.samples)
+	}
+	return w.next
+}
+
+// average 样本平均值，无样本时返回 0
+func (w *processingWindow) average() int64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	n := w.next
+	if w.full {
+		n = len(w.samples)
+	}
+	if n == 0 {
+		return 0
+	}
+
+	var total int64
+	for _, ms := range w.samples[:n] {
+		total += ms
+	}
+	return total / int64(n)
+}
@@ -0,0 +1,54 @@
+package queue
+
+import (
+	"testing"
+	"time"
+
+	"tarot/pkg/testutil"
+)
+
+func TestProcessingSamplesStayBounded(t *testing.T) {
+	testutil.SetConfig(t, "queue.metrics_size", 100)
+	m := NewQueueMetrics()
+
+	for i := 0; i < 10000; i++ {
+		m.RecordProcessingTime(time.Duration(i%10) * time.Millisecond)
+	}
+	if n := m.ProcessingSamples(); n != 100 {
+		t.Fatalf("samples = %d, want 100", n)
+	}
+}
+
+func TestAvgProcessingTimeUsesRecentSamples(t *testing.T) {
+	testutil.SetConfig(t, "queue.metrics_size", 4)
+	m := NewQueueMetrics()
+
+	if avg := m.AvgProcessingTime(); avg != 0 {
+		t.Fatalf("empty average = %v, want 0", avg)
+	}
+	m.RecordProcessingTime(10 * time.Millisecond)
+	m.RecordProcessingTime(30 * time.Millisecond)
+	if avg := m.AvgProcessingTime(); avg != 20*time.Millisecond || m.ProcessingSamples() != 2 {
+		t.Fatalf("average = %v over %d samples, want 20ms over 2", avg, m.ProcessingSamples())
+	}
+
+	// 写满后最旧的样本被覆盖
+	for i := 0; i < 4; i++ {
+		m.RecordProcessingTime(100 * time.Millisecond)
+	}
+	if avg := m.AvgProcessingTime(); avg != 100*time.Millisecond {
+		t.Fatalf("average = %v, want 100ms after old samples evicted", avg)
+	}
+}
+
+func TestProcessingWindowDefaultsInvalidSize(t *testing.T) {
+	testutil.SetConfig(t, "queue.metrics_size", 0)
+	m := NewQueueMetrics()
+
+	for i := 0; i < defaultMetricsSize+10; i++ {
+		m.RecordProcessingTime(time.Millisecond)
+	}
+	if n := m.ProcessingSamples(); n != defaultMetricsSize {
+		t.Fatalf("samples = %d, want %d", n, defaultMetricsSize)
+	}
+}
@@ -4,15 +4,22 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"regexp"
 	"time"
 	
 	goredis "github.com/redis/go-redis/v9"
 	"golang.org/x/time/rate"
 	
+	"tarot/app/models/reading"
 	"tarot/pkg/config"
+	"tarot/pkg/dify"
 	"tarot/pkg/redis"
+	"tarot/pkg/tracing"
 )
 
+// dequeueTimeout 工作器单次阻塞等待任务的最长时间
+const dequeueTimeout = 2 * time.Second
+
 // TaskStatus 任务状态
 type TaskStatus string
 
@@ -21,6 +28,7 @@ const (
 	TaskRunning   TaskStatus = "running"
 	TaskCompleted TaskStatus = "completed"
 	TaskFailed    TaskStatus = "failed"
+	TaskFallback  TaskStatus = "fallback" // Dify 不可用，结果为兜底文案
 )
 
 // TarotTask 塔罗牌解读任务
@@ -28,35 +36,148 @@ type TarotTask struct {
 	ID        string     `json:"id"`
 	UserID    string     `json:"user_id"`
 	Question  string     `json:"question"`
+	Type      string     `json:"type"`
+	SpreadID  uint64     `json:"spread_id,omitempty"`
+	Spread    string     `json:"spread,omitempty"` // 牌阵说明，传给 Dify 工作流
 	Cards     []int      `json:"cards"`
 	Status    TaskStatus `json:"status"`
 	Result    string     `json:"result"`
 	CreatedAt time.Time  `json:"created_at"`
 	UpdatedAt time.Time  `json:"updated_at"`
+
+	// ResponseMode Dify 响应模式，为空时使用服务默认配置
+	ResponseMode string `json:"response_mode,omitempty"`
+	// RequestID 创建任务的 HTTP 请求 ID，用于关联 Worker 与 Dify 日志
+	RequestID string `json:"request_id,omitempty"`
+	// TraceParent 创建任务时的 W3C traceparent，Worker 据此延续链路
+	TraceParent string `json:"traceparent,omitempty"`
+	// CallbackURL 任务结束后回调的地址，为空时不回调
+	CallbackURL string `json:"callback_url,omitempty"`
+	// ConversationID 追问时延续的 Dify 对话，为空时开始新对话
+	ConversationID string `json:"conversation_id,omitempty"`
+
+	// raw 出队时的原始数据，用于从处理中列表移除
+	raw []byte
+}
+
+// readingInput 转换为 Dify 解读输入
+func (t *TarotTask) readingInput() dify.ReadingInput {
+	return dify.ReadingInput{
+		Question:       t.Question,
+		Cards:          t.Cards,
+		Spread:         t.Spread,
+		ConversationID: t.ConversationID,
+	}
+}
+
+// Validate 在调用 Dify 前检查任务内容，与创建解读时的校验一致
+// 旧版本客户端或其他途径入队的任务可能未经校验，这类任务重试也不会成功
+func (t *TarotTask) Validate() error {
+	if err := reading.ValidateQuestion(t.Question); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidTask, err)
+	}
+	if err := reading.ValidateCardIDs(t.Cards); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidTask, err)
+	}
+	if len(t.Cards) > reading.MaxCards {
+		return fmt.Errorf("%w: 卡牌数量不能超过 %d 张", ErrInvalidTask, reading.MaxCards)
+	}
+	return nil
+}
+
+// DefaultQueue 默认队列名称，交互式解读使用该队列
+const DefaultQueue = "default"
+
+// queueNamePattern 队列名称格式，名称会出现在 Redis key 中
+var queueNamePattern = regexp.MustCompile(`^[a-z0-9_-]{1,32}$`)
+
+// ValidQueueName 检查队列名称是否合法
+func ValidQueueName(name string) bool {
+	return queueNamePattern.MatchString(name)
 }
 
 // QueueService Redis 队列服务
 // 支持高并发操作和可靠的任务处理
+// 每个 QueueService 绑定一个命名队列，任务状态与结果按任务 ID 存储，不区分队列
 type QueueService struct {
 	client      *redis.RedisClient
 	prefix      string
+	name        string
 	timeout     time.Duration
 	rateLimiter *rate.Limiter
 	metrics     *QueueMetrics
+
+	// compressThreshold 任务 JSON 与结果超过该字节数时压缩存储，<= 0 时不压缩
+	compressThreshold int
+	// statusTTLs 各终态的状态与结果保留时间，未配置的状态使用 timeout
+	statusTTLs map[TaskStatus]time.Duration
 }
 
-// NewQueueService 创建新的队列服务实例
+// NewQueueService 创建默认队列的服务实例
 func NewQueueService() *QueueService {
+	return NewNamedQueueService(DefaultQueue)
+}
+
+// NewNamedQueueService 创建指定队列的服务实例，名称需满足 ValidQueueName
+func NewNamedQueueService(name string) *QueueService {
 	rateLimit := config.GetInt("queue.rate_limit", 1000)
 	burst := config.GetInt("queue.rate_burst", rateLimit)
 	
 	return &QueueService{
 		client:      redis.GetRedis(redis.QueueDB),
 		prefix:      config.GetString("redis.queue_prefix", "tarot"),
+		name:        name,
 		timeout:     time.Duration(config.GetInt("redis.queue_timeout", 3600)) * time.Second,
 		rateLimiter: rate.NewLimiter(rate.Limit(rateLimit), burst),
 		metrics:     NewQueueMetrics(),
+
+		compressThreshold: config.GetInt("queue.compress_threshold", 0),
+		statusTTLs: map[TaskStatus]time.Duration{
+			TaskCompleted: time.Duration(config.GetInt("queue.ttl_completed", 0)) * time.Second,
+			TaskFailed:    time.Duration(config.GetInt("queue.ttl_failed", 0)) * time.Second,
+			TaskFallback:  time.Duration(config.GetInt("queue.ttl_fallback", 0)) * time.Second,
+		},
+	}
+}
+
+// ttlFor 任务处于该状态时状态与结果的保留时间
+// 终态可单独配置，例如完成的结果保留更久、失败的尽快释放；等待与执行中的状态使用 timeout，被遗弃的任务会自然过期
+func (q *QueueService) ttlFor(status TaskStatus) time.Duration {
+	if ttl := q.statusTTLs[status]; ttl > 0 {
+		return ttl
 	}
+	return q.timeout
+}
+
+// Name 队列名称
+func (q *QueueService) Name() string {
+	return q.name
+}
+
+// isDefault 是否为默认队列，只有默认队列需要处理升级前遗留的任务
+func (q *QueueService) isDefault() bool {
+	return q.name == DefaultQueue
+}
+
+// tasksKey 按用户公平调度之前使用的单一任务列表，仅用于取出默认队列升级前遗留的任务
+func (q *QueueService) tasksKey() string {
+	return q.prefix + ":tasks"
+}
+
+// statusKey 任务状态的 key
+// 任务 ID 作为哈希标签，集群模式下同一任务的状态与结果位于同一个槽
+func (q *QueueService) statusKey(taskID string) string {
+	return fmt.Sprintf("%s:status:{%s}", q.prefix, taskID)
+}
+
+// resultKey 任务结果的 key，与 statusKey 使用相同的哈希标签
+func (q *QueueService) resultKey(taskID string) string {
+	return fmt.Sprintf("%s:result:{%s}", q.prefix, taskID)
+}
+
+// conversationKey 任务对应的 Dify 对话 ID 的 key，与 statusKey 使用相同的哈希标签
+func (q *QueueService) conversationKey(taskID string) string {
+	return fmt.Sprintf("%s:conversation:{%s}", q.prefix, taskID)
 }
 
 // PushTask 将任务推送到队列
@@ -64,7 +185,7 @@ func NewQueueService() *QueueService {
 func (q *QueueService) PushTask(ctx context.Context, task *TarotTask) error {
 	// 应用限流
 	if err := q.rateLimiter.Wait(ctx); err != nil {
-		return fmt.Errorf("rate limit exceeded: %w", err)
+		return fmt.Errorf("%w: %v", ErrQueueFull, err)
 	}
 
 	// 开始计时
@@ -75,6 +196,14 @@ func (q *QueueService) PushTask(ctx context.Context, task *TarotTask) error {
 		}
 	}()
 
+	// 记录入队 span，Worker 以此为父 span 延续链路
+	ctx, span := tracing.Start(ctx, "redis.queue.push", tracing.KindProducer)
+	span.SetAttribute("task.id", task.ID)
+	defer span.End()
+	if traceparent := tracing.Inject(ctx); traceparent != "" {
+		task.TraceParent = traceparent
+	}
+
 	// 序列化任务
 	taskJSON, err := json.Marshal(task)
 	if err != nil {
@@ -82,17 +211,17 @@ func (q *QueueService) PushTask(ctx context.Context, task *TarotTask) error {
 		return fmt.Errorf("failed to marshal task: %w", err)
 	}
 
-	// 使用事务确保原子性
-	key := fmt.Sprintf("%s:tasks", q.prefix)
-	statusKey := fmt.Sprintf("%s:status:%s", q.prefix, task.ID)
+	// 先写入状态再入队，避免工作器已开始处理后状态又被覆盖为 pending
+	if err := q.client.Client.Set(ctx, q.statusKey(task.ID), string(TaskPending), q.timeout).Err(); err != nil {
+		q.metrics.RecordError(OpPush)
+		span.RecordError(err)
+		return fmt.Errorf("failed to push task: %w", err)
+	}
 
-	pipe := q.client.Client.Pipeline()
-	pipe.LPush(ctx, key, taskJSON)
-	pipe.Set(ctx, statusKey, string(TaskPending), q.timeout)
-	
-	_, err = pipe.Exec(ctx)
-	if err != nil {
+	// 放入所属用户的任务列表，按用户轮转调度
+	if err := q.pushFair(ctx, fairUser(task), q.compress(taskJSON)); err != nil {
 		q.metrics.RecordError(OpPush)
+		span.RecordError(err)
 		return fmt.Errorf("failed to push task: %w", err)
 	}
 
@@ -100,43 +229,53 @@ func (q *QueueService) PushTask(ctx context.Context, task *TarotTask) error {
 	return nil
 }
 
-// PopTask 从队列中获取任务
-func (q *QueueService) PopTask(ctx context.Context) (*TarotTask, error) {
-	key := fmt.Sprintf("%s:tasks", q.prefix)
-	result, err := q.client.Client.BRPop(ctx, 0, key).Result()
+// UpdateTaskStatus 更新任务状态，保留时间按状态由 ttlFor 决定
+// 状态与结果在同一个 MULTI/EXEC 事务中写入（两个 key 的哈希标签相同，集群模式下位于同一个槽），
+// 读取方不会看到已完成但没有结果的中间状态
+func (q *QueueService) UpdateTaskStatus(ctx context.Context, taskID string, status TaskStatus, result string) error {
+	ttl := q.ttlFor(status)
+	_, err := q.client.Client.TxPipelined(ctx, func(pipe goredis.Pipeliner) error {
+		if result != "" {
+			pipe.Set(ctx, q.resultKey(taskID), q.compress([]byte(result)), ttl)
+		}
+		pipe.Set(ctx, q.statusKey(taskID), string(status), ttl)
+		return nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to pop task from queue: %w", err)
+		return fmt.Errorf("failed to update task status: %w", err)
 	}
 
-	var task TarotTask
-	if err := json.Unmarshal([]byte(result[1]), &task); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal task: %w", err)
+	// 通知正在订阅的 SSE 连接：终态推送 done，其余推送状态变化
+	if status.IsTerminal() {
+		return q.publishDone(ctx, taskID, status)
 	}
-
-	return &task, nil
+	return q.publishStatus(ctx, taskID, status)
 }
 
-// UpdateTaskStatus 更新任务状态
-func (q *QueueService) UpdateTaskStatus(ctx context.Context, taskID string, status TaskStatus, result string) error {
-	statusKey := fmt.Sprintf("%s:status:%s", q.prefix, taskID)
-	if err := q.client.Client.Set(ctx, statusKey, string(status), q.timeout).Err(); err != nil {
-		return fmt.Errorf("failed to update task status: %w", err)
+// SetTaskConversation 记录任务所属的 Dify 对话 ID，供后续追问延续上下文，与完成的结果保留相同时间
+func (q *QueueService) SetTaskConversation(ctx context.Context, taskID, conversationID string) error {
+	if err := q.client.Client.Set(ctx, q.conversationKey(taskID), conversationID, q.ttlFor(TaskCompleted)).Err(); err != nil {
+		return fmt.Errorf("failed to save task conversation: %w", err)
 	}
+	return nil
+}
 
-	if result != "" {
-		resultKey := fmt.Sprintf("%s:result:%s", q.prefix, taskID)
-		if err := q.client.Client.Set(ctx, resultKey, result, q.timeout).Err(); err != nil {
-			return fmt.Errorf("failed to save task result: %w", err)
+// GetTaskConversation 获取任务所属的 Dify 对话 ID，不存在时返回空字符串
+func (q *QueueService) GetTaskConversation(ctx context.Context, taskID string) (string, error) {
+	id, err := q.client.Client.Get(ctx, q.conversationKey(taskID)).Result()
+	if err != nil {
+		if err == goredis.Nil {
+			return "", nil
 		}
+		return "", fmt.Errorf("failed to get task conversation: %w", err)
 	}
-
-	return nil
+	return id, nil
 }
 
 // GetTaskResult 获取任务结果
 func (q *QueueService) GetTaskResult(ctx context.Context, taskID string) (*TarotTask, error) {
 	// 1. 获取任务状态
-	statusKey := fmt.Sprintf("%s:status:%s", q.prefix, taskID)
+	statusKey := q.statusKey(taskID)
 	status, err := q.client.Client.Get(ctx, statusKey).Result()
 	if err != nil {
 		if err == goredis.Nil {
@@ -146,11 +285,14 @@ func (q *QueueService) GetTaskResult(ctx context.Context, taskID string) (*Tarot
 	}
 
 	// 2. 获取任务结果
-	resultKey := fmt.Sprintf("%s:result:%s", q.prefix, taskID)
+	resultKey := q.resultKey(taskID)
 	result, err := q.client.Client.Get(ctx, resultKey).Result()
 	if err != nil && err != goredis.Nil {
 		return nil, fmt.Errorf("failed to get task result: %w", err)
 	}
+	if result, err = decompressString(result); err != nil {
+		return nil, fmt.Errorf("failed to get task result: %w", err)
+	}
 
 	// 3. 构建任务对象
 	task := &TarotTask{
@@ -169,7 +311,7 @@ func (q *QueueService) GetTaskResult(ctx context.Context, taskID string) (*Tarot
 
 // GetTaskStatus 获取任务状态
 func (q *QueueService) GetTaskStatus(ctx context.Context, taskID string) (TaskStatus, error) {
-	statusKey := fmt.Sprintf("%s:status:%s", q.prefix, taskID)
+	statusKey := q.statusKey(taskID)
 	status, err := q.client.Client.Get(ctx, statusKey).Result()
 	if err != nil {
 		if err == goredis.Nil {
@@ -181,6 +323,28 @@ func (q *QueueService) GetTaskStatus(ctx context.Context, taskID string) (TaskSt
 	return TaskStatus(status), nil
 }
 
+// GetTaskStatuses 批量获取任务状态，一次 MGET 完成
+// 返回的 map 只包含存在的任务
+func (q *QueueService) GetTaskStatuses(ctx context.Context, taskIDs []string) (map[string]TaskStatus, error) {
+	keys := make([]string, len(taskIDs))
+	for i, id := range taskIDs {
+		keys[i] = q.statusKey(id)
+	}
+
+	values, err := q.client.MGetContext(ctx, keys...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get task statuses: %w", err)
+	}
+
+	statuses := make(map[string]TaskStatus, len(values))
+	for i, id := range taskIDs {
+		if status, ok := values[keys[i]]; ok {
+			statuses[id] = TaskStatus(status)
+		}
+	}
+	return statuses, nil
+}
+
 // GetTaskProgress 获取任务进度信息
 func (q *QueueService) GetTaskProgress(ctx context.Context, taskID string) (*TaskProgress, error) {
 	// 1. 获取任务状态
@@ -195,19 +359,51 @@ func (q *QueueService) GetTaskProgress(ctx context.Context, taskID string) (*Tas
 		Status: status,
 	}
 
-	// 3. 如果任务已完成，获取结果
-	if status == TaskCompleted {
-		resultKey := fmt.Sprintf("%s:result:%s", q.prefix, taskID)
+	// 3. 如果任务已完成（含兜底结果），获取结果
+	if status == TaskCompleted || status == TaskFallback {
+		resultKey := q.resultKey(taskID)
 		result, err := q.client.Client.Get(ctx, resultKey).Result()
 		if err != nil && err != goredis.Nil {
 			return nil, fmt.Errorf("failed to get task result: %w", err)
 		}
+		if result, err = decompressString(result); err != nil {
+			return nil, fmt.Errorf("failed to get task result: %w", err)
+		}
 		progress.Result = result
 	}
 
 	return progress, nil
 }
 
+// WaitTaskProgress 轮询任务进度，直到任务进入终态、任务不存在或 ctx 结束
+// ctx 到期时返回最后一次查询到的进度，不视为错误
+func (q *QueueService) WaitTaskProgress(ctx context.Context, taskID string, interval time.Duration) (*TaskProgress, error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var last *TaskProgress
+	for {
+		progress, err := q.GetTaskProgress(ctx, taskID)
+		if err != nil {
+			// 查询途中 ctx 到期，返回上一次的进度
+			if ctx.Err() != nil && last != nil {
+				return last, nil
+			}
+			return nil, err
+		}
+		if progress.Status == "" || progress.Status.IsTerminal() {
+			return progress, nil
+		}
+		last = progress
+
+		select {
+		case <-ctx.Done():
+			return last, nil
+		case <-ticker.C:
+		}
+	}
+}
+
 // TaskProgress 任务进度信息
 type TaskProgress struct {
 	TaskID string     `json:"task_id"`
@@ -220,31 +416,160 @@ func (q *QueueService) Ping(ctx context.Context) error {
 	return q.client.Ping()
 }
 
-// DequeueTask 从队列中获取任务
-func (q *QueueService) DequeueTask(ctx context.Context) (*TarotTask, error) {
-	key := fmt.Sprintf("%s:tasks", q.prefix)
-	
-	// 使用 Client.BRPop 而不是直接使用 BRPop
-	result, err := q.client.Client.BRPop(ctx, 0, key).Result()
+// Length 当前等待处理的任务数量，包括升级前遗留在旧列表中的任务
+func (q *QueueService) Length(ctx context.Context) (int64, error) {
+	n, err := q.lengthFair(ctx)
 	if err != nil {
-		if err == goredis.Nil {
-			return nil, nil
+		return 0, fmt.Errorf("failed to get queue length: %w", err)
+	}
+	if !q.isDefault() {
+		return n, nil
+	}
+	legacy, err := q.client.Client.LLen(ctx, q.tasksKey()).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get queue length: %w", err)
+	}
+	return n + legacy, nil
+}
+
+// DequeueTask 为 workerID 获取一个任务，见 DequeueTasks
+func (q *QueueService) DequeueTask(ctx context.Context, workerID string) (*TarotTask, error) {
+	tasks, err := q.DequeueTasks(ctx, workerID, 1)
+	if err != nil {
+		return nil, err
+	}
+	return tasks[0], nil
+}
+
+// DequeueTasks 为 workerID 一次获取最多 limit 个任务，各用户按轮转顺序获得处理机会
+// 取出的任务同时放入该工作器的处理中列表，每个任务处理结束后需调用 AckTask；工作器失联时由 ReclaimStaleTasks 放回队列。
+// 队列积压时一轮即可取满 limit 个，减少与 Redis 的往返；队列为空时最多等待 dequeueTimeout 的入队信号，
+// 仍无任务返回 ErrQueueEmpty，使工作器能及时响应暂停与停止
+func (q *QueueService) DequeueTasks(ctx context.Context, workerID string, limit int) ([]*TarotTask, error) {
+	if limit <= 0 {
+		limit = 1
+	}
+
+	data, err := q.nextTasks(ctx, workerID, limit)
+	if err != nil || len(data) > 0 {
+		return q.decodeTasks(ctx, workerID, data, err)
+	}
+
+	if err := q.waitSignal(ctx, dequeueTimeout); err != nil {
+		if err == goredis.Nil || err == context.DeadlineExceeded {
+			return nil, ErrQueueEmpty
+		}
+		return nil, fmt.Errorf("failed to pop task from queue: %v", err)
+	}
+
+	data, err = q.nextTasks(ctx, workerID, limit)
+	if err == nil && len(data) == 0 {
+		return nil, ErrQueueEmpty
+	}
+	return q.decodeTasks(ctx, workerID, data, err)
+}
+
+// nextTasks 取出最多 limit 个任务，用户列表为空时取升级前遗留在旧列表中的任务
+func (q *QueueService) nextTasks(ctx context.Context, workerID string, limit int) ([][]byte, error) {
+	data, err := q.popFair(ctx, workerID, limit)
+	if err != nil || len(data) > 0 || !q.isDefault() {
+		return data, err
+	}
+
+	// 旧列表与处理中列表不在同一个槽，无法原子转移，取出后再单独记录
+	cmds, err := q.client.Client.Pipelined(ctx, func(pipe goredis.Pipeliner) error {
+		for i := 0; i < limit; i++ {
+			pipe.RPop(ctx, q.tasksKey())
 		}
-		if err == context.DeadlineExceeded {
-			return nil, nil
+		return nil
+	})
+	if err != nil && err != goredis.Nil {
+		return nil, err
+	}
+	for _, cmd := range cmds {
+		if result, err := cmd.(*goredis.StringCmd).Bytes(); err == nil {
+			data = append(data, result)
 		}
+	}
+	if len(data) == 0 {
+		return nil, nil
+	}
+
+	values := make([]interface{}, len(data))
+	for i, d := range data {
+		values[i] = d
+	}
+	if err := q.client.Client.LPush(ctx, q.processingKey(workerID), values...).Err(); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// decodeTasks 解析取出的任务
+// 无法解析的任务重试也不会成功，直接从处理中列表移除，避免被反复回收；全部无法解析时返回最后一个错误
+func (q *QueueService) decodeTasks(ctx context.Context, workerID string, data [][]byte, err error) ([]*TarotTask, error) {
+	if err != nil {
 		return nil, fmt.Errorf("failed to pop task from queue: %v", err)
 	}
-	
-	if len(result) != 2 {
-		return nil, fmt.Errorf("invalid result from queue")
+
+	tasks := make([]*TarotTask, 0, len(data))
+	var lastErr error
+	for _, d := range data {
+		task, err := unmarshalTask(d)
+		if err != nil {
+			q.client.Client.LRem(ctx, q.processingKey(workerID), 1, d)
+			lastErr = err
+			continue
+		}
+		tasks = append(tasks, task)
 	}
-	
+	if len(tasks) == 0 {
+		return nil, lastErr
+	}
+	return tasks, nil
+}
+
+// unmarshalTask 解析队列中保存的任务，兼容压缩与未压缩的数据
+func unmarshalTask(raw []byte) (*TarotTask, error) {
+	data, err := decompress(raw)
+	if err != nil {
+		return nil, err
+	}
+
 	var task TarotTask
-	if err := json.Unmarshal([]byte(result[1]), &task); err != nil {
+	if err := json.Unmarshal(data, &task); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal task: %v", err)
 	}
-	
+	task.raw = raw
 	return &task, nil
 }
- 
\ No newline at end of file
+
+// scanKeys 以 SCAN 游标分批遍历匹配的键，从不使用 KEYS
+// 集群模式下 SCAN 只作用于单个节点，需要逐个主节点遍历
+func (q *QueueService) scanKeys(ctx context.Context, match string, count int64, fn func(ctx context.Context, keys []string) error) error {
+	scanNode := func(ctx context.Context, client goredis.Cmdable) error {
+		var cursor uint64
+		for {
+			keys, next, err := client.Scan(ctx, cursor, match, count).Result()
+			if err != nil {
+				return fmt.Errorf("scan %s: %w", match, err)
+			}
+			if len(keys) > 0 {
+				if err := fn(ctx, keys); err != nil {
+					return err
+				}
+			}
+			if next == 0 {
+				return nil
+			}
+			cursor = next
+		}
+	}
+
+	if cluster, ok := q.client.Client.(*goredis.ClusterClient); ok {
+		return cluster.ForEachMaster(ctx, func(ctx context.Context, node *goredis.Client) error {
+			return scanNode(ctx, node)
+		})
+	}
+	return scanNode(ctx, q.client.Client)
+}
@@ -0,0 +1,202 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"tarot/pkg/dify"
+	"tarot/pkg/testutil"
+)
+
+// TestObserversNeverSeeCompletedWithoutResult 状态与结果在同一事务中写入，
+// 并发读取的一方看到 completed 时结果一定已经存在
+func TestObserversNeverSeeCompletedWithoutResult(t *testing.T) {
+	testutil.SetupRedis(t)
+	qs := NewQueueService()
+	ctx := context.Background()
+
+	const tasks = 200
+	ids := make([]string, tasks)
+	for i := range ids {
+		ids[i] = fmt.Sprintf("task-%d", i)
+		if err := qs.UpdateTaskStatus(ctx, ids[i], TaskRunning, ""); err != nil {
+			t.Fatalf("UpdateTaskStatus: %v", err)
+		}
+	}
+
+	var done atomic.Bool
+	var partial, observed atomic.Int64
+	var wg sync.WaitGroup
+	for r := 0; r < 4; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for !done.Load() {
+				for _, id := range ids {
+					task, err := qs.GetTaskResult(ctx, id)
+					if err != nil || task == nil || task.Status != TaskCompleted {
+						continue
+					}
+					observed.Add(1)
+					if task.Result == "" {
+						partial.Add(1)
+					}
+				}
+			}
+		}()
+	}
+
+	for _, id := range ids {
+		if err := qs.UpdateTaskStatus(ctx, id, TaskCompleted, "解读结果 "+id); err != nil {
+			t.Fatalf("UpdateTaskStatus: %v", err)
+		}
+	}
+	done.Store(true)
+	wg.Wait()
+
+	if n := partial.Load(); n > 0 {
+		t.Fatalf("observers saw %d completed tasks without a result", n)
+	}
+	if observed.Load() == 0 {
+		t.Fatal("observers never saw a completed task")
+	}
+}
+
+func TestUpdateTaskStatusWritesResultWithStatus(t *testing.T) {
+	server := testutil.SetupRedis(t)
+	qs := NewQueueService()
+	ctx := context.Background()
+
+	if err := qs.UpdateTaskStatus(ctx, "task-1", TaskCompleted, "解读结果"); err != nil {
+		t.Fatalf("UpdateTaskStatus: %v", err)
+	}
+	task, err := qs.GetTaskResult(ctx, "task-1")
+	if err != nil || task == nil || task.Status != TaskCompleted || task.Result != "解读结果" {
+		t.Fatalf("GetTaskResult = %+v, %v", task, err)
+	}
+	if statusTTL, resultTTL := server.TTL(qs.statusKey("task-1")), server.TTL(qs.resultKey("task-1")); statusTTL != resultTTL {
+		t.Fatalf("status ttl = %s, result ttl = %s, want them to expire together", statusTTL, resultTTL)
+	}
+
+	// 不带结果的状态更新保留已有结果
+	if err := qs.UpdateTaskStatus(ctx, "task-1", TaskRunning, ""); err != nil {
+		t.Fatalf("UpdateTaskStatus: %v", err)
+	}
+	if !server.Exists(qs.resultKey("task-1")) {
+		t.Fatal("status-only update removed the stored result")
+	}
+}
+
+func TestNamedQueuesAreIsolated(t *testing.T) {
+	testutil.SetupRedis(t)
+	a := NewNamedQueueService("horoscope")
+	b := NewNamedQueueService("interactive")
+	ctx := context.Background()
+
+	task := newTask("free")
+	if err := a.PushTask(ctx, task); err != nil {
+		t.Fatalf("PushTask: %v", err)
+	}
+
+	if got, err := b.DequeueTask(ctx, "worker-b"); err != ErrQueueEmpty {
+		t.Fatalf("queue B dequeued %+v, %v, want ErrQueueEmpty", got, err)
+	}
+	if got, err := NewQueueService().DequeueTask(ctx, "worker-default"); err != ErrQueueEmpty {
+		t.Fatalf("default queue dequeued %+v, %v, want ErrQueueEmpty", got, err)
+	}
+	if n, _ := b.Length(ctx); n != 0 {
+		t.Fatalf("queue B length = %d, want 0", n)
+	}
+	if n, _ := a.Length(ctx); n != 1 {
+		t.Fatalf("queue A length = %d, want 1", n)
+	}
+
+	got, err := a.DequeueTask(ctx, "worker-a")
+	if err != nil || got == nil || got.ID != task.ID {
+		t.Fatalf("queue A DequeueTask = %+v, %v, want %s", got, err, task.ID)
+	}
+}
+
+// TestWorkerOnlyProcessesItsQueue 绑定队列 B 的工作器不会处理推入队列 A 的任务
+func TestWorkerOnlyProcessesItsQueue(t *testing.T) {
+	w, _, _ := newModeWorker(t, dify.ResponseModeBlocking)
+	ctx := context.Background()
+	a := NewNamedQueueService("horoscope")
+	b := NewNamedQueueService("interactive")
+
+	workerB := NewWorker(b, w.difyService, WorkerConfig{WorkerCount: 2, TaskTimeout: 5 * time.Second})
+	workerB.Start()
+	t.Cleanup(workerB.Stop)
+
+	task := newTask("free")
+	if err := a.PushTask(ctx, task); err != nil {
+		t.Fatalf("PushTask: %v", err)
+	}
+	time.Sleep(1500 * time.Millisecond)
+	if status, _ := a.GetTaskStatus(ctx, task.ID); status != TaskPending {
+		t.Fatalf("status = %s, want the task left pending for queue A", status)
+	}
+	if n, _ := a.Length(ctx); n != 1 {
+		t.Fatalf("queue A length = %d, want 1", n)
+	}
+
+	workerA := NewWorker(a, w.difyService, WorkerConfig{WorkerCount: 1, TaskTimeout: 5 * time.Second})
+	workerA.Start()
+	t.Cleanup(workerA.Stop)
+	waitUntil(t, 5*time.Second, func() bool {
+		status, _ := a.GetTaskStatus(ctx, task.ID)
+		return status == TaskCompleted
+	}, "queue A's worker did not process the task")
+}
+
+func TestUpdateTaskStatusAppliesPerStatusTTL(t *testing.T) {
+	server := testutil.SetupRedis(t)
+	testutil.SetConfig(t, "redis.queue_timeout", 3600)
+	testutil.SetConfig(t, "queue.ttl_completed", 7*24*3600)
+	testutil.SetConfig(t, "queue.ttl_failed", 600)
+	testutil.SetConfig(t, "queue.ttl_fallback", 0)
+	qs := NewQueueService()
+	ctx := context.Background()
+
+	tests := []struct {
+		status TaskStatus
+		want   time.Duration
+	}{
+		{TaskCompleted, 7 * 24 * time.Hour},
+		{TaskFailed, 10 * time.Minute},
+		{TaskFallback, time.Hour}, // 未配置时沿用队列超时
+		{TaskRunning, time.Hour},
+	}
+	for _, tt := range tests {
+		id := "task-" + string(tt.status)
+		if err := qs.UpdateTaskStatus(ctx, id, tt.status, "结果"); err != nil {
+			t.Fatalf("UpdateTaskStatus(%s): %v", tt.status, err)
+		}
+		if got := server.TTL(qs.statusKey(id)); got != tt.want {
+			t.Errorf("%s status ttl = %s, want %s", tt.status, got, tt.want)
+		}
+		if got := server.TTL(qs.resultKey(id)); got != tt.want {
+			t.Errorf("%s result ttl = %s, want %s", tt.status, got, tt.want)
+		}
+	}
+
+	// 失败后重试成功，结果保留完成状态的时长
+	if err := qs.UpdateTaskStatus(ctx, "task-failed", TaskCompleted, "重试结果"); err != nil {
+		t.Fatalf("UpdateTaskStatus: %v", err)
+	}
+	if got := server.TTL(qs.resultKey("task-failed")); got != 7*24*time.Hour {
+		t.Fatalf("result ttl after completing = %s, want the completed ttl", got)
+	}
+
+	// 对话 ID 与完成的结果保留相同时间
+	if err := qs.SetTaskConversation(ctx, "task-completed", "conv-1"); err != nil {
+		t.Fatalf("SetTaskConversation: %v", err)
+	}
+	if got := server.TTL(qs.conversationKey("task-completed")); got != 7*24*time.Hour {
+		t.Fatalf("conversation ttl = %s, want the completed ttl", got)
+	}
+}
@@ -0,0 +1,79 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	goredis "github.com/redis/go-redis/v9"
+)
+
+// 流式推送事件类型
+const (
+	StreamEventStatus = "status" // 任务状态变化（pending → running）
+	StreamEventChunk  = "chunk"  // 解读文本片段
+	StreamEventDone   = "done"   // 任务结束（完成、失败或兜底）
+)
+
+// StreamMessage 流式推送消息，通过 Redis 频道从 Worker 转发给 SSE 连接
+type StreamMessage struct {
+	Event  string     `json:"event"`
+	Text   string     `json:"text,omitempty"`
+	Status TaskStatus `json:"status,omitempty"`
+}
+
+// IsTerminal 判断任务状态是否为终态
+func (s TaskStatus) IsTerminal() bool {
+	return s == TaskCompleted || s == TaskFailed || s == TaskFallback
+}
+
+// streamChannel 任务的流式推送频道
+func (q *QueueService) streamChannel(taskID string) string {
+	return fmt.Sprintf("%s:stream:%s", q.prefix, taskID)
+}
+
+// PublishChunk 推送一段解读文本
+func (q *QueueService) PublishChunk(ctx context.Context, taskID, text string) error {
+	return q.publish(ctx, taskID, StreamMessage{Event: StreamEventChunk, Text: text})
+}
+
+// publishStatus 推送任务状态变化事件
+func (q *QueueService) publishStatus(ctx context.Context, taskID string, status TaskStatus) error {
+	return q.publish(ctx, taskID, StreamMessage{Event: StreamEventStatus, Status: status})
+}
+
+// publishDone 推送任务结束事件
+func (q *QueueService) publishDone(ctx context.Context, taskID string, status TaskStatus) error {
+	return q.publish(ctx, taskID, StreamMessage{Event: StreamEventDone, Status: status})
+}
+
+func (q *QueueService) publish(ctx context.Context, taskID string, msg StreamMessage) error {
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to marshal stream message: %w", err)
+	}
+	if err := q.client.Client.Publish(ctx, q.streamChannel(taskID), payload).Err(); err != nil {
+		return fmt.Errorf("failed to publish stream message: %w", err)
+	}
+	return nil
+}
+
+// SubscribeStream 订阅任务的流式推送，调用方负责关闭返回的订阅
+func (q *QueueService) SubscribeStream(ctx context.Context, taskID string) (*goredis.PubSub, error) {
+	sub := q.client.Client.Subscribe(ctx, q.streamChannel(taskID))
+	// 等待订阅确认，确保之后发布的消息不会丢失
+	if _, err := sub.Receive(ctx); err != nil {
+		sub.Close()
+		return nil, fmt.Errorf("failed to subscribe stream: %w", err)
+	}
+	return sub, nil
+}
+
+// ParseStreamMessage 解析频道消息
+func ParseStreamMessage(msg *goredis.Message) (StreamMessage, error) {
+	var m StreamMessage
+	if err := json.Unmarshal([]byte(msg.Payload), &m); err != nil {
+		return m, fmt.Errorf("failed to unmarshal stream message: %w", err)
+	}
+	return m, nil
+}
@@ -7,13 +7,22 @@ import (
 	"sync"
 	"time"
 
+	"tarot/app/models/reading"
 	"tarot/pkg/dify"
 	"tarot/pkg/logger"
+	"tarot/pkg/tracing"
+	"tarot/pkg/webhook"
+
+	"go.uber.org/zap"
 )
 
 // 错误常量定义
 var (
 	ErrQueueEmpty = errors.New("queue is empty")
+	// ErrQueueFull 入队超过限流速率，等待额度超出请求期限
+	ErrQueueFull = errors.New("queue is full")
+	// ErrInvalidTask 任务内容不合法，不调用 Dify，直接标记为失败
+	ErrInvalidTask = errors.New("invalid task")
 )
 
 // contextKey 自定义上下文键类型
@@ -24,29 +33,82 @@ const (
 	taskIDKey contextKey = "task_id"
 )
 
+// webhookDeadline 单个任务回调（含全部重试）的最长时间
+const webhookDeadline = 10 * time.Minute
+
 // Worker 队列工作器
 type Worker struct {
-	queueService *QueueService
-	difyService  *dify.DifyService
-	stopChan     chan struct{}
-	workerCount  int
-	metrics      *QueueMetrics
-	wg           sync.WaitGroup
-	config       WorkerConfig
-	cancel       context.CancelFunc
-	ctx          context.Context
-	timeout      time.Duration
-	retryConfig  RetryConfig
+	queueService  *QueueService
+	difyService   *dify.DifyService
+	stopChan      chan struct{}
+	metrics       *QueueMetrics
+	wg            sync.WaitGroup
+	config        WorkerConfig
+	cancel        context.CancelFunc
+	ctx           context.Context // 取消后不再获取新任务
+	taskCancel    context.CancelFunc
+	taskCtx       context.Context // 执行任务使用，仅在关闭超时时取消
+	heartbeatDone chan struct{}
+	timeout       time.Duration
+	streamTimeout time.Duration
+	retryConfig   RetryConfig
+	counters      workerCounters
+
+	running      sync.Map // 运行中（含正在退出）的工作器 ID，用于刷新心跳
+	poolMu       sync.Mutex
+	pool         []*poolWorker // 运行中的工作器，Resize 时从末尾停止
+	nextWorkerID int
+
+	pauseMu  sync.Mutex
+	resumeCh chan struct{} // 暂停期间为未关闭的通道，Resume 时关闭；未暂停时为 nil
 }
 
+// Workers 默认队列的工作器组，由 bootstrap.SetupQueue 初始化，供管理端暂停与恢复
+var Workers *Worker
+
+// WorkerGroups 按队列名称索引的全部工作器组，包含默认队列
+var WorkerGroups = map[string]*Worker{}
+
 // WorkerConfig 工作器配置
 type WorkerConfig struct {
 	WorkerCount     int           // 并发工作器数量
 	MaxRetries      int           // 最大重试次数
 	RetryInterval   time.Duration // 重试间隔
 	ShutdownTimeout time.Duration // 关闭超时时间
-	BatchSize       int           // 批处理大小
+	TaskTimeout     time.Duration // 单个任务执行超时时间
+	StreamTimeout   time.Duration // 流式任务执行超时时间，需覆盖完整的流时长
+	BatchSize       int           // 每个工作器单次最多取出的任务数
 	MaxQueueSize    int           // 最大队列长度
+	MetricsInterval time.Duration // 指标摘要输出间隔，<= 0 时不输出
+	Fallback        FallbackConfig
+	Notifier        *webhook.Notifier // 任务结束回调，为 nil 时不回调
+	Results         ResultRecorder    // 任务结束后写入解读记录，为 nil 时结果只保存在 Redis 中
+}
+
+// ResultRecorder 将任务结果写入对应的解读记录
+type ResultRecorder interface {
+	UpdateResultByTaskID(ctx context.Context, taskID string, status reading.Status, interpretation string) (bool, error)
+}
+
+// FallbackConfig 兜底解读配置
+// 启用后，指定类型的任务在 Dify 不可用时返回兜底文案而不是失败
+type FallbackConfig struct {
+	Enabled bool
+	Types   []string // 适用的解读类型
+	Message string
+}
+
+// appliesTo 判断兜底是否适用于该解读类型
+func (f FallbackConfig) appliesTo(taskType string) bool {
+	if !f.Enabled || f.Message == "" {
+		return false
+	}
+	for _, t := range f.Types {
+		if t == taskType {
+			return true
+		}
+	}
+	return false
 }
 
 // RetryConfig 重试配置
@@ -67,19 +129,29 @@ func NewWorker(qs *QueueService, ds *dify.DifyService, config WorkerConfig) *Wor
 	if config.MaxQueueSize <= 0 {
 		config.MaxQueueSize = 10000 // 默认最大队列长度
 	}
+	if config.TaskTimeout <= 0 {
+		config.TaskTimeout = 30 * time.Second // 默认任务超时时间
+	}
+	if config.StreamTimeout < config.TaskTimeout {
+		config.StreamTimeout = config.TaskTimeout
+	}
 
 	ctx, cancel := context.WithCancel(context.Background())
+	taskCtx, taskCancel := context.WithCancel(context.Background())
 
 	return &Worker{
-		queueService: qs,
-		difyService:  ds,
-		stopChan:     make(chan struct{}),
-		workerCount:  config.WorkerCount,
-		metrics:      NewQueueMetrics(),
-		config:       config,
-		ctx:          ctx,
-		cancel:       cancel,
-		timeout:      30 * time.Second,
+		queueService:  qs,
+		difyService:   ds,
+		stopChan:      make(chan struct{}),
+		metrics:       NewQueueMetrics(),
+		config:        config,
+		ctx:           ctx,
+		cancel:        cancel,
+		taskCtx:       taskCtx,
+		taskCancel:    taskCancel,
+		heartbeatDone: make(chan struct{}),
+		timeout:       config.TaskTimeout,
+		streamTimeout: config.StreamTimeout,
 		retryConfig: RetryConfig{
 			MaxRetries:    3,
 			RetryInterval: 5 * time.Second,
@@ -90,32 +162,148 @@ func NewWorker(qs *QueueService, ds *dify.DifyService, config WorkerConfig) *Wor
 
 // Start 启动工作器组
 func (w *Worker) Start() {
-	logger.InfoString("Worker", "Start", fmt.Sprintf("Starting %d workers", w.workerCount))
+	logger.InfoString("Worker", "Start", fmt.Sprintf("Starting %d workers for queue %s",
+		w.config.WorkerCount, w.queueService.Name()))
 
-	w.wg.Add(w.workerCount)
-	for i := 0; i < w.workerCount; i++ {
-		go func(id int) {
+	if w.config.MetricsInterval > 0 {
+		w.wg.Add(1)
+		go func() {
 			defer w.wg.Done()
-			if err := w.startWorker(id); err != nil {
-				logger.ErrorString("Worker", "Error",
-					fmt.Sprintf("Worker %d error: %v", id, err))
-			}
-		}(i)
+			w.reportMetrics(w.ctx, w.config.MetricsInterval)
+		}()
+	}
+
+	// 心跳在关闭期间继续刷新，直到进行中的任务全部结束
+	go func() {
+		defer close(w.heartbeatDone)
+		w.heartbeatLoop(w.taskCtx)
+	}()
+
+	w.poolMu.Lock()
+	w.spawn(w.config.WorkerCount)
+	w.poolMu.Unlock()
+}
+
+// reportMetrics 定期将队列指标摘要写入日志，ctx 取消时退出
+func (w *Worker) reportMetrics(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			w.logMetrics(ctx)
+		}
+	}
+}
+
+// logMetrics 输出一次指标摘要，队列长度读取失败时沿用上次的值
+func (w *Worker) logMetrics(ctx context.Context) {
+	if n, err := w.queueService.Length(ctx); err != nil {
+		logger.WarnString("Worker", "Metrics", err.Error())
+	} else {
+		w.metrics.SetQueueLength(n)
+	}
+
+	summary := w.metrics.Summary()
+	logger.Info("Queue Metrics",
+		zap.Int64("processed", summary.Processed),
+		zap.Int64("failed", summary.Failed),
+		zap.Duration("avg_latency", summary.AvgLatency),
+		zap.Int64("queue_length", summary.QueueLength),
+		zap.Int64("peak_queue_length", summary.PeakQueueLength),
+	)
+}
+
+// Pause 暂停获取新任务，已开始执行的任务继续完成，任务保留在队列中
+func (w *Worker) Pause() {
+	w.pauseMu.Lock()
+	defer w.pauseMu.Unlock()
+
+	if w.resumeCh == nil {
+		w.resumeCh = make(chan struct{})
+		logger.InfoString("Worker", "Pause", "Workers paused")
 	}
 }
 
-// startWorker 启动单个工作器
-func (w *Worker) startWorker(id int) error {
+// Resume 恢复获取任务
+func (w *Worker) Resume() {
+	w.pauseMu.Lock()
+	defer w.pauseMu.Unlock()
+
+	if w.resumeCh != nil {
+		close(w.resumeCh)
+		w.resumeCh = nil
+		logger.InfoString("Worker", "Resume", "Workers resumed")
+	}
+}
+
+// Queue 工作器组绑定的队列
+func (w *Worker) Queue() *QueueService {
+	return w.queueService
+}
+
+// Paused 是否处于暂停状态
+func (w *Worker) Paused() bool {
+	w.pauseMu.Lock()
+	defer w.pauseMu.Unlock()
+	return w.resumeCh != nil
+}
+
+// waitIfPaused 暂停时阻塞直到恢复，ctx 取消时返回 false
+func (w *Worker) waitIfPaused(ctx context.Context) bool {
+	w.pauseMu.Lock()
+	ch := w.resumeCh
+	w.pauseMu.Unlock()
+
+	if ch == nil {
+		return true
+	}
+	select {
+	case <-ch:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// startWorker 启动单个工作器，ctx 取消后不再获取新任务
+// 取任务使用工作器组的 w.ctx，执行任务使用 w.taskCtx，停止工作器时当前任务会先完成
+func (w *Worker) startWorker(ctx context.Context, id int) error {
 	logger.InfoString("Worker", "Start", fmt.Sprintf("Worker %d started", id))
+	w.counters.active.Add(1)
+	defer w.counters.active.Add(-1)
+
+	// 取任务前先登记心跳，避免处理中的任务被当作失联工作器的任务回收
+	// 退出时工作器组可能已关闭，心跳与确认使用不随之取消的 ctx
+	bg := context.WithoutCancel(w.taskCtx)
+	wid := w.workerID(id)
+	if err := w.queueService.Heartbeat(bg, wid); err != nil {
+		logger.WarnString("Worker", "Heartbeat", err.Error())
+	}
+	w.running.Store(wid, struct{}{})
+	defer func() {
+		w.running.Delete(wid)
+		if err := w.queueService.RemoveHeartbeat(bg, wid); err != nil {
+			logger.WarnString("Worker", "Heartbeat", err.Error())
+		}
+	}()
 
 	for {
 		select {
-		case <-w.ctx.Done():
+		case <-ctx.Done():
 			logger.InfoString("Worker", "Stop", fmt.Sprintf("Worker %d stopping", id))
 			return nil
 		default:
-			// 尝试获取任务
-			task, err := w.queueService.DequeueTask(w.ctx)
+			// 暂停期间不获取新任务，进行中的任务不受影响
+			if !w.waitIfPaused(ctx) {
+				continue
+			}
+
+			// 尝试获取任务，队列积压时一次取出最多 BatchSize 个
+			tasks, err := w.queueService.DequeueTasks(w.ctx, wid, w.config.BatchSize)
 			if err != nil {
 				if err == ErrQueueEmpty {
 					// 队列为空，等待一段时间后重试
@@ -128,22 +316,71 @@ func (w *Worker) startWorker(id int) error {
 				continue
 			}
 
-			// 执行任务
-			if err := w.executeTask(w.ctx, task, id); err != nil {
-				logger.ErrorString("Worker", "Error",
-					fmt.Sprintf("Worker %d execution error: %v", id, err))
+			// 依次执行本批任务，停止或暂停后剩余的任务放回队列，交给其他工作器或恢复后处理
+			for i, task := range tasks {
+				if ctx.Err() != nil || w.Paused() {
+					w.release(bg, wid, tasks[i:])
+					break
+				}
+				w.runTask(bg, wid, task, id)
 			}
 		}
 	}
 }
 
+// runTask 执行取出的任务并确认
+func (w *Worker) runTask(bg context.Context, wid string, task *TarotTask, id int) {
+	w.counters.inFlight.Add(1)
+	err := w.executeTask(w.taskCtx, task, id)
+	w.counters.inFlight.Add(-1)
+	if ackErr := w.queueService.AckTask(bg, wid, task); ackErr != nil {
+		logger.WarnString("Worker", "Ack", ackErr.Error())
+	}
+	w.counters.processed.Add(1)
+	w.counters.window.record(time.Now())
+	if err != nil {
+		logger.ErrorString("Worker", "Error",
+			fmt.Sprintf("Worker %d execution error: %v", id, err))
+	}
+}
+
+// release 将已取出但尚未开始的任务放回队列
+// 放回的任务排在所属用户的队首，倒序放回以保持原来的先后顺序
+func (w *Worker) release(bg context.Context, wid string, tasks []*TarotTask) {
+	for i := len(tasks) - 1; i >= 0; i-- {
+		if err := w.queueService.RequeueTask(bg, wid, tasks[i]); err != nil {
+			logger.WarnString("Worker", "Release",
+				fmt.Sprintf("Worker %s release task %s: %v", wid, tasks[i].ID, err))
+		}
+	}
+}
+
 // executeTask 执行单个任务
 func (w *Worker) executeTask(ctx context.Context, task *TarotTask, workerID int) error {
+	// 沿用创建任务时的请求 ID，便于关联 HTTP、Worker 与 Dify 日志
+	ctx = logger.WithRequestID(ctx, task.RequestID)
+
+	// 延续创建任务时的链路
+	ctx = tracing.ContextFromTraceparent(ctx, task.TraceParent)
+	ctx, span := tracing.Start(ctx, "queue.task.process", tracing.KindConsumer)
+	span.SetAttribute("task.id", task.ID)
+	span.SetAttribute("task.type", task.Type)
+	span.SetAttribute("worker.id", workerID)
+	defer span.End()
+
 	start := time.Now()
 	defer func() {
 		w.metrics.RecordProcessingTime(time.Since(start))
 	}()
 
+	// 明显无效的任务不调用 Dify，也不重试或兜底，直接标记为失败并记录原因
+	if err := task.Validate(); err != nil {
+		span.RecordError(err)
+		logger.WarnContext(ctx, "Worker", "InvalidTask",
+			logger.WorkerID(workerID), logger.TaskID(task.ID), zap.Error(err))
+		return w.fail(ctx, task, err)
+	}
+
 	// 更新状态���中
 	if err := w.queueService.UpdateTaskStatus(ctx, task.ID, TaskRunning, ""); err != nil {
 		return fmt.Errorf("update task status error: %w", err)
@@ -151,20 +388,102 @@ func (w *Worker) executeTask(ctx context.Context, task *TarotTask, workerID int)
 
 	// 处理任务
 	err := w.processTask(ctx, task)
-	if err != nil {
+	span.RecordError(err)
+
+	// 关闭超时被强制中断的任务放回队列，由其他实例或重启后继续处理，不算作失败
+	if err != nil && w.taskCtx.Err() != nil {
+		return w.requeue(task, workerID)
+	}
+	if err != nil && w.shouldFallback(ctx, task, err) {
+		logger.WarnContext(ctx, "Worker", "Fallback",
+			logger.WorkerID(workerID), logger.TaskID(task.ID), logger.Latency(time.Since(start)), zap.Error(err))
 		w.metrics.RecordError(OpProcess)
-		if updateErr := w.queueService.UpdateTaskStatus(ctx, task.ID, TaskFailed, err.Error()); updateErr != nil {
-			logger.ErrorString("Worker", "UpdateStatus", updateErr.Error())
+		if updateErr := w.queueService.UpdateTaskStatus(ctx, task.ID, TaskFallback, w.config.Fallback.Message); updateErr != nil {
+			return fmt.Errorf("update task status error: %w", updateErr)
 		}
-		return fmt.Errorf("process task error: %w", err)
+		w.recordResult(ctx, task, reading.StatusCompleted, w.config.Fallback.Message)
+		w.notify(ctx, task, webhook.Payload{Event: webhook.EventCompleted, Status: string(TaskFallback), Result: w.config.Fallback.Message})
+		return nil
+	}
+	if err != nil {
+		return w.fail(ctx, task, err)
 	}
 
 	w.metrics.RecordSuccess(OpProcess)
-	logger.InfoString("Worker", "Success",
-		fmt.Sprintf("Worker %d completed task %s", workerID, task.ID))
+	w.notifyCompleted(ctx, task)
+	logger.InfoContext(ctx, "Worker", "Success",
+		logger.WorkerID(workerID), logger.TaskID(task.ID), logger.Latency(time.Since(start)))
 	return nil
 }
 
+// fail 将任务标记为失败，失败原因写入任务结果并回调
+func (w *Worker) fail(ctx context.Context, task *TarotTask, err error) error {
+	w.metrics.RecordError(OpProcess)
+	if updateErr := w.queueService.UpdateTaskStatus(ctx, task.ID, TaskFailed, err.Error()); updateErr != nil {
+		logger.ErrorStringContext(ctx, "Worker", "UpdateStatus", updateErr.Error())
+	}
+	w.recordResult(ctx, task, reading.StatusFailed, "")
+	w.notify(ctx, task, webhook.Payload{Event: webhook.EventFailed, Status: string(TaskFailed), Error: err.Error()})
+	return fmt.Errorf("process task error: %w", err)
+}
+
+// notifyCompleted 读取任务结果，写入解读记录并回调
+func (w *Worker) notifyCompleted(ctx context.Context, task *TarotTask) {
+	if w.config.Results == nil && (task.CallbackURL == "" || w.config.Notifier == nil) {
+		return
+	}
+	result, err := w.queueService.GetTaskResult(ctx, task.ID)
+	if err != nil || result == nil {
+		logger.WarnStringContext(ctx, "Worker", "Result",
+			fmt.Sprintf("Task %s: read result failed: %v", task.ID, err))
+		return
+	}
+	w.recordResult(ctx, task, reading.StatusCompleted, result.Result)
+	w.notify(ctx, task, webhook.Payload{Event: webhook.EventCompleted, Status: string(TaskCompleted), Result: result.Result})
+}
+
+// recordResult 将任务结果写入解读记录，失败只记录日志，Redis 中的结果仍可查询
+func (w *Worker) recordResult(ctx context.Context, task *TarotTask, status reading.Status, interpretation string) {
+	if w.config.Results == nil {
+		return
+	}
+	updated, err := w.config.Results.UpdateResultByTaskID(ctx, task.ID, status, interpretation)
+	if err != nil {
+		logger.ErrorContext(ctx, "Worker", "RecordResult", logger.TaskID(task.ID), zap.Error(err))
+		return
+	}
+	if !updated {
+		logger.WarnContext(ctx, "Worker", "RecordResult", logger.TaskID(task.ID),
+			zap.String("reason", "reading not found"))
+	}
+}
+
+// notify 异步投递任务结束回调，重试等待不占用工作器
+// 回调不随工作器关闭而取消，最长持续 webhookDeadline
+func (w *Worker) notify(ctx context.Context, task *TarotTask, payload webhook.Payload) {
+	if task.CallbackURL == "" || w.config.Notifier == nil {
+		return
+	}
+	payload.TaskID = task.ID
+
+	go func() {
+		deliverCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), webhookDeadline)
+		defer cancel()
+
+		if err := w.config.Notifier.Deliver(deliverCtx, task.CallbackURL, payload); err != nil {
+			logger.WarnStringContext(deliverCtx, "Worker", "Webhook",
+				fmt.Sprintf("Task %s: %v", task.ID, err))
+		}
+	}()
+}
+
+// shouldFallback 判断失败的任务是否返回兜底解读
+// 只有 Dify 不可用（网络错误、5xx、超时、没有可用实例）才走兜底；
+// 工作器关闭、请求被 Dify 拒绝、响应无法解析等失败照常标记为失败
+func (w *Worker) shouldFallback(ctx context.Context, task *TarotTask, err error) bool {
+	return ctx.Err() == nil && dify.IsUnavailable(err) && w.config.Fallback.appliesTo(task.Type)
+}
+
 // processTask 处理任务的核心逻辑
 func (w *Worker) processTask(ctx context.Context, task *TarotTask) error {
 	var lastErr error
@@ -173,9 +492,8 @@ func (w *Worker) processTask(ctx context.Context, task *TarotTask) error {
 	for attempt := 0; attempt <= w.retryConfig.MaxRetries; attempt++ {
 		// 如果不是第一次尝试，记录重试信息
 		if attempt > 0 {
-			logger.InfoString("Worker", "Retry",
-				fmt.Sprintf("Retrying task %s, attempt %d of %d",
-					task.ID, attempt, w.retryConfig.MaxRetries))
+			logger.InfoContext(ctx, "Worker", "Retry",
+				logger.TaskID(task.ID), logger.Attempt(attempt+1), zap.Int("max_retries", w.retryConfig.MaxRetries))
 
 			// 添加重试延迟
 			select {
@@ -192,8 +510,8 @@ func (w *Worker) processTask(ctx context.Context, task *TarotTask) error {
 		}
 
 		lastErr = err
-		logger.WarnString("Worker", "TaskError",
-			fmt.Sprintf("Task %s failed attempt %d: %v", task.ID, attempt, err))
+		logger.WarnContext(ctx, "Worker", "TaskError",
+			logger.TaskID(task.ID), logger.Attempt(attempt+1), zap.Error(err))
 
 		// 检查是否是致命错误（不需要重试）
 		if isFatalError(err) {
@@ -209,80 +527,159 @@ func (w *Worker) processTask(ctx context.Context, task *TarotTask) error {
 	return errors.New("task failed with unknown error")
 }
 
+// responseMode 任务的响应模式，未指定时使用 Dify 服务的默认配置
+func (w *Worker) responseMode(task *TarotTask) string {
+	if dify.IsValidResponseMode(task.ResponseMode) {
+		return task.ResponseMode
+	}
+	return w.difyService.ResponseMode()
+}
+
 // executeTaskWithTimeout 在超时限制内执行任务
 func (w *Worker) executeTaskWithTimeout(ctx context.Context, task *TarotTask) error {
+	// 流式模式交由 Dify 服务处理，由其控制流的总时长与无数据超时
+	if w.responseMode(task) == dify.ResponseModeStreaming {
+		taskCtx, cancel := context.WithTimeout(ctx, w.streamTimeout)
+		defer cancel()
+		return w.executeStreamTask(taskCtx, task)
+	}
+
 	taskCtx, cancel := context.WithTimeout(ctx, w.timeout)
 	defer cancel()
 
-	// 获取可用的 Dify 实例
-	instance, err := w.difyService.GetHealthyInstance()
-	if err != nil {
-		return fmt.Errorf("failed to get healthy instance: %w", err)
+	// 对话应用的接口与工作流不同，交由 Dify 服务处理
+	if w.difyService.SupportsConversation() {
+		return w.executeChatTask(taskCtx, task)
 	}
 
-	// 将卡牌数组转换为字符串
-	cardsStr := fmt.Sprintf("%v", task.Cards)
+	// 同时处理的相同解读只调用一次 Dify，共享同一结果
+	// 实例选择、冷却、错误阈值与重试均由 Dify 服务处理
+	in := task.readingInput()
+	result, shared, err := w.difyService.Coalesce(taskCtx, dify.ReadingKey(in), func() (string, error) {
+		return w.difyService.ProcessTarotReading(taskCtx, in)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to process task: %w", err)
+	}
+	if shared {
+		logger.InfoContext(ctx, "Worker", "Coalesce", logger.TaskID(task.ID))
+	}
 
-	// 构建请求体
-	requestBody := map[string]interface{}{
-		"inputs": map[string]interface{}{
-			"question": task.Question,
-			"cards":    cardsStr, // 转换为字符串
-		},
-		"response_mode": "blocking",
-		"user":          task.ID,
+	// 更新任务状态和结果
+	if err := w.queueService.UpdateTaskStatus(taskCtx, task.ID, TaskCompleted, result); err != nil {
+		return fmt.Errorf("failed to update task result: %w", err)
 	}
+	return nil
+}
 
-	// 使用选定的实例执行任务
-	result, err := instance.Client.R().
-		SetContext(taskCtx).
-		SetHeader("Authorization", "Bearer "+instance.APIKey).
-		SetHeader("Content-Type", "application/json").
-		SetBody(requestBody).
-		Post(instance.URL + "/workflows/run")
+// executeChatTask 以阻塞模式调用 Dify 对话应用执行任务
+func (w *Worker) executeChatTask(ctx context.Context, task *TarotTask) error {
+	in := task.readingInput()
+	in.OnConversation = w.saveConversation(ctx, task)
 
+	result, err := w.difyService.ProcessTarotReading(ctx, in)
 	if err != nil {
-		w.difyService.MarkInstanceUnhealthy(instance, err)
 		return fmt.Errorf("failed to process task: %w", err)
 	}
 
-	// 更新任务状态和结果
-	if err := w.queueService.UpdateTaskStatus(taskCtx, task.ID, TaskCompleted, result.String()); err != nil {
+	if err := w.queueService.UpdateTaskStatus(ctx, task.ID, TaskCompleted, result); err != nil {
 		return fmt.Errorf("failed to update task result: %w", err)
 	}
+	return nil
+}
 
-	// 记录实例成功使用
-	instance.LastUsed = time.Now()
-	instance.RequestCount.AddRequest()
+// saveConversation 返回记录 Dify 对话 ID 的回调
+// 记录失败只影响后续追问，不影响本次解读
+func (w *Worker) saveConversation(ctx context.Context, task *TarotTask) func(string) {
+	return func(conversationID string) {
+		if err := w.queueService.SetTaskConversation(ctx, task.ID, conversationID); err != nil {
+			logger.WarnStringContext(ctx, "Worker", "Conversation",
+				fmt.Sprintf("Task %s: %v", task.ID, err))
+		}
+	}
+}
+
+// executeStreamTask 以流式模式执行任务
+// 收到的文本片段实时推送给订阅了该任务的 SSE 连接
+func (w *Worker) executeStreamTask(ctx context.Context, task *TarotTask) error {
+	in := task.readingInput()
+	in.OnConversation = w.saveConversation(ctx, task)
+	in.OnChunk = func(text string) {
+		if err := w.queueService.PublishChunk(ctx, task.ID, text); err != nil {
+			logger.WarnStringContext(ctx, "Worker", "PublishChunk",
+				fmt.Sprintf("Task %s: %v", task.ID, err))
+		}
+	}
 
+	result, err := w.difyService.ProcessTarotReadingStream(ctx, in)
+	if err != nil {
+		return fmt.Errorf("failed to process task: %w", err)
+	}
+
+	if err := w.queueService.UpdateTaskStatus(ctx, task.ID, TaskCompleted, result); err != nil {
+		return fmt.Errorf("failed to update task result: %w", err)
+	}
 	return nil
 }
 
-// isFatalError 判断是否是致命错误
+// isFatalError 判断是否是致命错误，致命错误不再重试
+// Dify 拒绝请求（4xx）或卡牌数量不合法时重试也不会成功
 func isFatalError(err error) bool {
 	return errors.Is(err, context.Canceled) ||
-		errors.Is(err, context.DeadlineExceeded)
+		errors.Is(err, context.DeadlineExceeded) ||
+		dify.IsStreamTimeout(err) ||
+		errors.Is(err, dify.ErrDifyClient) ||
+		errors.Is(err, dify.ErrInvalidCards) ||
+		errors.Is(err, ErrInvalidTask)
+}
+
+// requeue 将被中断的任务放回所属用户的队首并重置为等待状态
+func (w *Worker) requeue(task *TarotTask, workerID int) error {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(w.taskCtx), 5*time.Second)
+	defer cancel()
+
+	if err := w.queueService.RequeueTask(ctx, w.workerID(workerID), task); err != nil {
+		return fmt.Errorf("requeue interrupted task %s: %w", task.ID, err)
+	}
+	logger.WarnString("Worker", "Requeue",
+		fmt.Sprintf("Worker %d task %s interrupted by shutdown, requeued", workerID, task.ID))
+	return nil
 }
 
 // Stop 优雅关闭工作器组
+// 立即停止获取新任务，进行中的任务在 ShutdownTimeout 内继续执行；
+// 超时后强制取消，被中断的任务放回队列
 func (w *Worker) Stop() {
 	logger.InfoString("Worker", "Stop", "Stopping all workers...")
 
-	// 发送停止信号
+	// 停止获取新任务
 	w.cancel()
 
-	// 等待所有工作器完成
 	done := make(chan struct{})
 	go func() {
 		w.wg.Wait()
 		close(done)
 	}()
 
-	// 设置超时
+	timeout := w.config.ShutdownTimeout
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+
 	select {
 	case <-done:
 		logger.InfoString("Worker", "Stop", "All workers stopped gracefully")
-	case <-time.After(30 * time.Second):
-		logger.WarnString("Worker", "Stop", "Worker shutdown timed out")
+	case <-time.After(timeout):
+		logger.WarnString("Worker", "Stop", "Worker shutdown timed out, cancelling in-flight tasks")
+		w.taskCancel()
+		// 等待被中断的任务放回队列
+		select {
+		case <-done:
+		case <-time.After(10 * time.Second):
+			logger.WarnString("Worker", "Stop", "Workers did not exit after cancellation")
+		}
 	}
+
+	w.taskCancel()
+	<-w.heartbeatDone
 }
@@ -0,0 +1,84 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"tarot/pkg/logger"
+)
+
+// MaxWorkerCount 允许调整到的最大工作器数量
+const MaxWorkerCount = 200
+
+var (
+	// ErrInvalidWorkerCount 调整的目标工作器数量不在 1..MaxWorkerCount 之间
+	ErrInvalidWorkerCount = fmt.Errorf("worker count must be between 1 and %d", MaxWorkerCount)
+	// ErrWorkerStopped 工作器组已关闭，无法再调整
+	ErrWorkerStopped = errors.New("worker pool stopped")
+)
+
+// poolWorker 工作器组中的单个工作器
+type poolWorker struct {
+	id     int
+	cancel context.CancelFunc
+}
+
+// spawn 启动 n 个工作器，调用方需持有 poolMu
+func (w *Worker) spawn(n int) {
+	for i := 0; i < n; i++ {
+		ctx, cancel := context.WithCancel(w.ctx)
+		pw := &poolWorker{id: w.nextWorkerID, cancel: cancel}
+		w.nextWorkerID++
+		w.pool = append(w.pool, pw)
+
+		w.wg.Add(1)
+		go func() {
+			defer w.wg.Done()
+			defer cancel()
+			if err := w.startWorker(ctx, pw.id); err != nil {
+				logger.ErrorString("Worker", "Error",
+					fmt.Sprintf("Worker %d error: %v", pw.id, err))
+			}
+		}()
+	}
+}
+
+// Resize 调整运行中的工作器数量
+// 增加时立即启动新的工作器；减少时从最近启动的工作器开始停止，
+// 被停止的工作器不再获取新任务，正在处理的任务完成后才退出，因此 Stats 中的 ActiveWorkers 可能短暂高于目标值
+func (w *Worker) Resize(n int) error {
+	if n < 1 || n > MaxWorkerCount {
+		return ErrInvalidWorkerCount
+	}
+
+	w.poolMu.Lock()
+	defer w.poolMu.Unlock()
+
+	if w.ctx.Err() != nil {
+		return ErrWorkerStopped
+	}
+
+	current := len(w.pool)
+	switch {
+	case n > current:
+		w.spawn(n - current)
+	case n < current:
+		for _, pw := range w.pool[n:] {
+			pw.cancel()
+		}
+		w.pool = w.pool[:n]
+	default:
+		return nil
+	}
+
+	logger.InfoString("Worker", "Resize", fmt.Sprintf("Workers resized from %d to %d", current, n))
+	return nil
+}
+
+// WorkerCount 当前的目标工作器数量
+func (w *Worker) WorkerCount() int {
+	w.poolMu.Lock()
+	defer w.poolMu.Unlock()
+	return len(w.pool)
+}
@@ -0,0 +1,116 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"tarot/pkg/testutil"
+)
+
+// workerGoroutines 统计正在执行 Worker 方法的 goroutine 数量
+// 不直接比较 runtime.NumGoroutine，Redis 连接池与 miniredis 的连接 goroutine 会随连接数变化
+func workerGoroutines() int {
+	buf := make([]byte, 1<<20)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+
+	count := 0
+	for _, g := range strings.Split(string(buf), "\n\n") {
+		if strings.Contains(g, "tarot/pkg/queue.(*Worker)") {
+			count++
+		}
+	}
+	return count
+}
+
+func TestResizeScalesWorkersWithoutLeaks(t *testing.T) {
+	testutil.SetupRedis(t)
+	if n := workerGoroutines(); n != 0 {
+		t.Fatalf("%d worker goroutines before Start", n)
+	}
+
+	w := NewWorker(NewQueueService(), nil, WorkerConfig{WorkerCount: 2})
+	w.Start()
+	waitUntil(t, 2*time.Second, func() bool { return w.Stats().ActiveWorkers == 2 },
+		"active workers = %d, want 2", w.Stats().ActiveWorkers)
+
+	for _, n := range []int{5, 1} {
+		if err := w.Resize(n); err != nil {
+			t.Fatalf("Resize(%d): %v", n, err)
+		}
+		if got := w.WorkerCount(); got != n {
+			t.Fatalf("WorkerCount after Resize(%d) = %d", n, got)
+		}
+		waitUntil(t, 5*time.Second, func() bool { return w.Stats().ActiveWorkers == int64(n) },
+			"active workers = %d, want %d", w.Stats().ActiveWorkers, n)
+	}
+
+	w.Stop()
+	if err := w.Resize(3); !errors.Is(err, ErrWorkerStopped) {
+		t.Fatalf("Resize after Stop = %v, want ErrWorkerStopped", err)
+	}
+	waitUntil(t, 5*time.Second, func() bool { return workerGoroutines() == 0 },
+		"%d worker goroutines left after Stop", workerGoroutines())
+}
+
+func TestResizeRejectsInvalidCount(t *testing.T) {
+	testutil.SetupRedis(t)
+	w := NewWorker(NewQueueService(), nil, WorkerConfig{WorkerCount: 1})
+
+	for _, n := range []int{0, -1, MaxWorkerCount + 1} {
+		if err := w.Resize(n); !errors.Is(err, ErrInvalidWorkerCount) {
+			t.Fatalf("Resize(%d) = %v, want ErrInvalidWorkerCount", n, err)
+		}
+	}
+}
+
+// TestResizeDrainsStoppedWorkers 缩容时被停止的工作器先完成正在处理的任务再退出
+func TestResizeDrainsStoppedWorkers(t *testing.T) {
+	w, qs, release := newBlockingWorker(t)
+	ctx := context.Background()
+	w.Start()
+	t.Cleanup(w.Stop)
+	if err := w.Resize(2); err != nil {
+		t.Fatalf("Resize(2): %v", err)
+	}
+
+	ids := []string{"task-1", "task-2"}
+	for _, id := range ids {
+		task := newTask("free")
+		task.ID = id
+		task.UserID = "user-" + id
+		if err := qs.PushTask(ctx, task); err != nil {
+			t.Fatalf("PushTask: %v", err)
+		}
+	}
+	waitUntil(t, 5*time.Second, func() bool { return w.Stats().InFlight == 2 },
+		"in flight = %d, want both workers busy", w.Stats().InFlight)
+
+	if err := w.Resize(1); err != nil {
+		t.Fatalf("Resize(1): %v", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if active := w.Stats().ActiveWorkers; active != 2 {
+		t.Fatalf("active workers = %d while the stopped worker is busy, want 2", active)
+	}
+
+	release()
+	for _, id := range ids {
+		waitUntil(t, 5*time.Second, func() bool {
+			status, _ := qs.GetTaskStatus(ctx, id)
+			return status == TaskCompleted
+		}, "%s was not finished by its worker", id)
+	}
+	waitUntil(t, 5*time.Second, func() bool { return w.Stats().ActiveWorkers == 1 },
+		"active workers = %d after draining, want 1", w.Stats().ActiveWorkers)
+}
@@ -0,0 +1,71 @@
+package queue
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// throughputWindow 计算每秒处理任务数的统计窗口（秒）
+const throughputWindow = 60
+
+// WorkerStats 工作器组的实时运行情况，用于评估 WorkerCount 是否合适
+type WorkerStats struct {
+	WorkerCount    int     `json:"worker_count"`     // 配置的工作器数量
+	ActiveWorkers  int64   `json:"active_workers"`   // 正在运行的工作器数量
+	InFlight       int64   `json:"in_flight"`        // 正在处理的任务数量
+	Processed      int64   `json:"processed"`        // 启动以来处理完成的任务总数（含失败）
+	TasksPerSecond float64 `json:"tasks_per_second"` // 最近 throughputWindow 秒的平均处理速率
+	AvgProcessMs   int64   `json:"avg_process_ms"`   // 最近处理的任务平均耗时（毫秒）
+}
+
+// workerCounters 工作器计数，均为原子操作，不占用工作器的锁
+type workerCounters struct {
+	active    atomic.Int64
+	inFlight  atomic.Int64
+	processed atomic.Int64
+	window    throughputCounter
+}
+
+// throughputCounter 按秒分桶的环形计数器
+type throughputCounter struct {
+	buckets [throughputWindow]struct {
+		second atomic.Int64
+		count  atomic.Int64
+	}
+}
+
+// record 在 now 所在的秒桶中计数一次，桶属于更早的秒时先清零
+func (t *throughputCounter) record(now time.Time) {
+	sec := now.Unix()
+	b := &t.buckets[sec%throughputWindow]
+	if old := b.second.Load(); old != sec && b.second.CompareAndSwap(old, sec) {
+		b.count.Store(0)
+	}
+	b.count.Add(1)
+}
+
+// rate 最近 throughputWindow 秒（不含当前未结束的一秒）的平均每秒计数
+func (t *throughputCounter) rate(now time.Time) float64 {
+	current := now.Unix()
+	var total int64
+	for i := range t.buckets {
+		b := &t.buckets[i]
+		sec := b.second.Load()
+		if sec < current && sec >= current-throughputWindow {
+			total += b.count.Load()
+		}
+	}
+	return float64(total) / throughputWindow
+}
+
+// Stats 返回工作器组的实时运行情况
+func (w *Worker) Stats() WorkerStats {
+	return WorkerStats{
+		WorkerCount:    w.WorkerCount(),
+		ActiveWorkers:  w.counters.active.Load(),
+		InFlight:       w.counters.inFlight.Load(),
+		Processed:      w.counters.processed.Load(),
+		TasksPerSecond: w.counters.window.rate(time.Now()),
+		AvgProcessMs:   w.metrics.AvgProcessingTime().Milliseconds(),
+	}
+}
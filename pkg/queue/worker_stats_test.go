@@ -0,0 +1,118 @@
+package queue
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"tarot/pkg/dify"
+	"tarot/pkg/testutil"
+)
+
+// newBlockingWorker 创建连接到假 Dify 服务的工作器，Dify 在调用 release 前不响应
+// 每个工作器一次只取一个任务，便于观察各工作器上的任务
+func newBlockingWorker(t *testing.T) (*Worker, *QueueService, func()) {
+	t.Helper()
+	testutil.SetupRedis(t)
+
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data":{"status":"succeeded","outputs":{"text":"解读结果"}}}`))
+	}))
+	var once sync.Once
+	unblock := func() { once.Do(func() { close(release) }) }
+	t.Cleanup(server.Close)
+	t.Cleanup(unblock)
+
+	ds := dify.NewDifyService(&dify.Config{
+		URLs:         []string{server.URL},
+		APIKeys:      []string{"app-test"},
+		Timeout:      5 * time.Second,
+		ResponseMode: dify.ResponseModeBlocking,
+		AppMode:      dify.AppModeWorkflow,
+	})
+	if ds == nil {
+		t.Fatal("NewDifyService returned nil")
+	}
+
+	qs := NewQueueService()
+	w := NewWorker(qs, ds, WorkerConfig{WorkerCount: 1, TaskTimeout: 5 * time.Second, BatchSize: 1})
+	w.retryConfig.MaxRetries = 0
+	return w, qs, unblock
+}
+
+func TestStatsTrackInFlightTasks(t *testing.T) {
+	w, qs, release := newBlockingWorker(t)
+	ctx := context.Background()
+
+	w.Start()
+	stopped := false
+	t.Cleanup(func() {
+		if !stopped {
+			w.Stop()
+		}
+	})
+	waitUntil(t, 2*time.Second, func() bool { return w.Stats().ActiveWorkers == 1 },
+		"active workers = %d, want 1", w.Stats().ActiveWorkers)
+	if stats := w.Stats(); stats.InFlight != 0 || stats.WorkerCount != 1 {
+		t.Fatalf("stats before any task = %+v, want nothing in flight", stats)
+	}
+
+	task := newTask("free")
+	if err := qs.PushTask(ctx, task); err != nil {
+		t.Fatalf("PushTask: %v", err)
+	}
+	waitUntil(t, 5*time.Second, func() bool { return w.Stats().InFlight == 1 },
+		"in-flight count did not rise while the task was blocked")
+	if processed := w.Stats().Processed; processed != 0 {
+		t.Fatalf("processed = %d while the task is blocked, want 0", processed)
+	}
+
+	release()
+	waitUntil(t, 5*time.Second, func() bool { return w.Stats().InFlight == 0 },
+		"in-flight count did not return to zero after the task finished")
+	if status, _ := qs.GetTaskStatus(ctx, task.ID); status != TaskCompleted {
+		t.Fatalf("status = %s, want completed", status)
+	}
+	if processed := w.Stats().Processed; processed != 1 {
+		t.Fatalf("processed = %d, want 1", processed)
+	}
+
+	w.Stop()
+	stopped = true
+	if active := w.Stats().ActiveWorkers; active != 0 {
+		t.Fatalf("active workers after Stop = %d, want 0", active)
+	}
+}
+
+func TestThroughputCounterRate(t *testing.T) {
+	var c throughputCounter
+	now := time.Unix(1_700_000_000, 0)
+
+	// 当前未结束的一秒不计入速率
+	for i := 0; i < 30; i++ {
+		c.record(now)
+	}
+	if got := c.rate(now); got != 0 {
+		t.Fatalf("rate within the current second = %v, want 0", got)
+	}
+	if got := c.rate(now.Add(time.Second)); got != 0.5 {
+		t.Fatalf("rate = %v, want 30 tasks over the %d second window", got, throughputWindow)
+	}
+
+	// 超出统计窗口的计数不再计入，复用的桶先清零
+	later := now.Add(throughputWindow * time.Second)
+	c.record(later)
+	if got := c.rate(later.Add(time.Second)); got != 1.0/throughputWindow {
+		t.Fatalf("rate after the window = %v, want only the latest task", got)
+	}
+}
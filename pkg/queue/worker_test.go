@@ -0,0 +1,1036 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"tarot/app/models/reading"
+	"tarot/pkg/dify"
+	"tarot/pkg/logger"
+	"tarot/pkg/testutil"
+	"tarot/pkg/webhook"
+)
+
+const fallbackMessage = "神谕正在休息，请稍后再试"
+
+// newFallbackWorker 创建连接到假 Dify 服务的工作器，Dify 对所有请求返回 status，status 为 0 时不响应直到任务超时
+func newFallbackWorker(t *testing.T, status int, fallback FallbackConfig) (*Worker, *QueueService) {
+	t.Helper()
+	testutil.SetupRedis(t)
+
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if status == 0 {
+			select {
+			case <-r.Context().Done():
+			case <-release:
+			}
+			return
+		}
+		w.WriteHeader(status)
+		w.Write([]byte(`{"code":"error","message":"dify error"}`))
+	}))
+	t.Cleanup(server.Close)
+	t.Cleanup(func() { close(release) })
+
+	ds := dify.NewDifyService(&dify.Config{
+		URLs:         []string{server.URL},
+		APIKeys:      []string{"app-test"},
+		Timeout:      5 * time.Second,
+		ResponseMode: dify.ResponseModeBlocking,
+		AppMode:      dify.AppModeWorkflow,
+	})
+	if ds == nil {
+		t.Fatal("NewDifyService returned nil")
+	}
+
+	qs := NewQueueService()
+	w := NewWorker(qs, ds, WorkerConfig{WorkerCount: 1, TaskTimeout: 200 * time.Millisecond, Fallback: fallback})
+	w.retryConfig.MaxRetries = 0
+	return w, qs
+}
+
+// newTask 创建指定类型的待处理任务
+func newTask(taskType string) *TarotTask {
+	return &TarotTask{
+		ID:        "task-" + taskType,
+		UserID:    "user-1",
+		Question:  "我最近的事业运势如何？",
+		Type:      taskType,
+		Cards:     []int{1, 2, 3},
+		Status:    TaskPending,
+		CreatedAt: time.Now(),
+	}
+}
+
+// runTask 推送并执行一个任务，返回最终状态与结果
+func runTask(t *testing.T, w *Worker, qs *QueueService, taskType string) (TaskStatus, string) {
+	t.Helper()
+	return executeTask(t, w, qs, newTask(taskType))
+}
+
+// executeTask 推送并执行指定任务，返回最终状态与结果
+func executeTask(t *testing.T, w *Worker, qs *QueueService, task *TarotTask) (TaskStatus, string) {
+	t.Helper()
+	ctx := context.Background()
+	if err := qs.PushTask(ctx, task); err != nil {
+		t.Fatalf("PushTask: %v", err)
+	}
+	w.executeTask(ctx, task, 0)
+
+	progress, err := qs.GetTaskProgress(ctx, task.ID)
+	if err != nil {
+		t.Fatalf("GetTaskProgress: %v", err)
+	}
+	return progress.Status, progress.Result
+}
+
+var enabledFallback = FallbackConfig{Enabled: true, Types: []string{"free"}, Message: fallbackMessage}
+
+func TestFallbackUsedWhenDifyDown(t *testing.T) {
+	w, qs := newFallbackWorker(t, http.StatusServiceUnavailable, enabledFallback)
+
+	status, result := runTask(t, w, qs, "free")
+	if status != TaskFallback || result != fallbackMessage {
+		t.Fatalf("status=%s result=%q, want fallback message", status, result)
+	}
+}
+
+func TestFallbackUsedWhenDifyTimesOut(t *testing.T) {
+	w, qs := newFallbackWorker(t, 0, enabledFallback)
+
+	if status, result := runTask(t, w, qs, "free"); status != TaskFallback || result != fallbackMessage {
+		t.Fatalf("status=%s result=%q, want fallback message", status, result)
+	}
+}
+
+func TestFallbackNotUsedWhenDisabled(t *testing.T) {
+	w, qs := newFallbackWorker(t, http.StatusServiceUnavailable, FallbackConfig{Types: []string{"free"}, Message: fallbackMessage})
+
+	if status, _ := runTask(t, w, qs, "free"); status != TaskFailed {
+		t.Fatalf("status = %s, want failed", status)
+	}
+}
+
+func TestFallbackNotUsedForOtherTypes(t *testing.T) {
+	w, qs := newFallbackWorker(t, http.StatusServiceUnavailable, enabledFallback)
+
+	if status, _ := runTask(t, w, qs, "premium"); status != TaskFailed {
+		t.Fatalf("status = %s, want failed", status)
+	}
+}
+
+func TestFallbackNotUsedWhenDifyRejectsRequest(t *testing.T) {
+	w, qs := newFallbackWorker(t, http.StatusBadRequest, enabledFallback)
+
+	status, result := runTask(t, w, qs, "free")
+	if status != TaskFailed || result == fallbackMessage {
+		t.Fatalf("status=%s result=%q, want failed without fallback", status, result)
+	}
+}
+
+// newModeWorker 创建连接到假 Dify 服务的工作器，服务记录每次请求的 response_mode 并按该模式应答
+func newModeWorker(t *testing.T, defaultMode string) (*Worker, *QueueService, *[]string) {
+	t.Helper()
+	testutil.SetupRedis(t)
+
+	var mu sync.Mutex
+	modes := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			ResponseMode string `json:"response_mode"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		mu.Lock()
+		modes = append(modes, body.ResponseMode)
+		mu.Unlock()
+
+		if body.ResponseMode == dify.ResponseModeStreaming {
+			w.Header().Set("Content-Type", "text/event-stream")
+			fmt.Fprint(w, "data: {\"event\":\"text_chunk\",\"data\":{\"text\":\"流式结果\"}}\n\n")
+			fmt.Fprint(w, "data: {\"event\":\"workflow_finished\",\"data\":{\"status\":\"succeeded\"}}\n\n")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data":{"status":"succeeded","outputs":{"text":"阻塞结果"}}}`))
+	}))
+	t.Cleanup(server.Close)
+
+	ds := dify.NewDifyService(&dify.Config{
+		URLs:              []string{server.URL},
+		APIKeys:           []string{"app-test"},
+		Timeout:           5 * time.Second,
+		StreamTimeout:     5 * time.Second,
+		StreamIdleTimeout: 5 * time.Second,
+		ResponseMode:      defaultMode,
+		AppMode:           dify.AppModeWorkflow,
+	})
+	if ds == nil {
+		t.Fatal("NewDifyService returned nil")
+	}
+
+	qs := NewQueueService()
+	w := NewWorker(qs, ds, WorkerConfig{WorkerCount: 1, TaskTimeout: 5 * time.Second})
+	w.retryConfig.MaxRetries = 0
+	return w, qs, &modes
+}
+
+func TestTaskResponseModeDrivesDifyRequest(t *testing.T) {
+	w, qs, modes := newModeWorker(t, dify.ResponseModeBlocking)
+
+	streaming := newTask("free")
+	streaming.ID = "task-streaming"
+	streaming.ResponseMode = dify.ResponseModeStreaming
+	if status, result := executeTask(t, w, qs, streaming); status != TaskCompleted || result != "流式结果" {
+		t.Fatalf("streaming task: status=%s result=%q", status, result)
+	}
+
+	blocking := newTask("free")
+	blocking.ID = "task-blocking"
+	blocking.ResponseMode = dify.ResponseModeBlocking
+	if status, result := executeTask(t, w, qs, blocking); status != TaskCompleted || result != "阻塞结果" {
+		t.Fatalf("blocking task: status=%s result=%q", status, result)
+	}
+
+	if want := []string{dify.ResponseModeStreaming, dify.ResponseModeBlocking}; !slices.Equal(*modes, want) {
+		t.Fatalf("dify response modes = %v, want %v", *modes, want)
+	}
+}
+
+func TestTaskWithoutResponseModeUsesServiceDefault(t *testing.T) {
+	w, qs, modes := newModeWorker(t, dify.ResponseModeStreaming)
+
+	if status, _ := runTask(t, w, qs, "free"); status != TaskCompleted {
+		t.Fatalf("status = %s, want completed", status)
+	}
+	if len(*modes) != 1 || (*modes)[0] != dify.ResponseModeStreaming {
+		t.Fatalf("dify response modes = %v, want the configured streaming mode", *modes)
+	}
+}
+
+func TestCompletedTaskSendsSignedWebhook(t *testing.T) {
+	w, qs, _ := newModeWorker(t, dify.ResponseModeStreaming)
+
+	requests := make(chan *http.Request, 1)
+	bodies := make(chan []byte, 1)
+	receiver := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests <- r
+		bodies <- body
+	}))
+	t.Cleanup(receiver.Close)
+	w.config.Notifier = webhook.NewNotifier(webhook.Config{
+		Secret:       "whsec_test",
+		MaxAttempts:  1,
+		Timeout:      time.Second,
+		AllowPrivate: true,
+	}, nil)
+
+	task := newTask("free")
+	task.CallbackURL = receiver.URL
+	if status, _ := executeTask(t, w, qs, task); status != TaskCompleted {
+		t.Fatalf("status = %s, want completed", status)
+	}
+
+	var r *http.Request
+	select {
+	case r = <-requests:
+	case <-time.After(2 * time.Second):
+		t.Fatal("webhook not delivered")
+	}
+	body := <-bodies
+	ts, _ := strconv.ParseInt(r.Header.Get(webhook.TimestampHeader), 10, 64)
+	if !webhook.Verify("whsec_test", ts, body, r.Header.Get(webhook.SignatureHeader)) {
+		t.Fatalf("webhook signature %q does not verify", r.Header.Get(webhook.SignatureHeader))
+	}
+
+	var payload webhook.Payload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		t.Fatalf("decode payload: %v", err)
+	}
+	if payload.Event != webhook.EventCompleted || payload.TaskID != task.ID || payload.Result != "流式结果" {
+		t.Fatalf("payload = %+v", payload)
+	}
+}
+
+// chatRequest 假 Dify 对话应用收到的请求
+type chatRequest struct {
+	Query          string `json:"query"`
+	ConversationID string `json:"conversation_id"`
+}
+
+// newChatWorker 创建连接到假 Dify 对话应用的工作器，未携带对话 ID 的请求开始新对话 conv-new
+func newChatWorker(t *testing.T) (*Worker, *QueueService, *[]chatRequest) {
+	t.Helper()
+	testutil.SetupRedis(t)
+
+	var mu sync.Mutex
+	received := []chatRequest{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req chatRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		mu.Lock()
+		received = append(received, req)
+		mu.Unlock()
+
+		conversationID := req.ConversationID
+		if conversationID == "" {
+			conversationID = "conv-new"
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"event":"message","answer":"对话结果","conversation_id":%q}`, conversationID)
+	}))
+	t.Cleanup(server.Close)
+
+	ds := dify.NewDifyService(&dify.Config{
+		URLs:         []string{server.URL},
+		APIKeys:      []string{"app-test"},
+		Timeout:      5 * time.Second,
+		ResponseMode: dify.ResponseModeBlocking,
+		AppMode:      dify.AppModeChat,
+	})
+	if ds == nil {
+		t.Fatal("NewDifyService returned nil")
+	}
+
+	qs := NewQueueService()
+	w := NewWorker(qs, ds, WorkerConfig{WorkerCount: 1, TaskTimeout: 5 * time.Second})
+	w.retryConfig.MaxRetries = 0
+	return w, qs, &received
+}
+
+func TestChatTaskThreadsConversationID(t *testing.T) {
+	w, qs, received := newChatWorker(t)
+	ctx := context.Background()
+
+	first := newTask("free")
+	first.ID = "task-first"
+	if status, result := executeTask(t, w, qs, first); status != TaskCompleted || result != "对话结果" {
+		t.Fatalf("first task: status=%s result=%q", status, result)
+	}
+	if id, err := qs.GetTaskConversation(ctx, first.ID); err != nil || id != "conv-new" {
+		t.Fatalf("first task conversation = %q, %v, want conv-new", id, err)
+	}
+
+	followUp := newTask("free")
+	followUp.ID = "task-follow-up"
+	followUp.ConversationID = "conv-new"
+	if status, _ := executeTask(t, w, qs, followUp); status != TaskCompleted {
+		t.Fatalf("follow-up status = %s, want completed", status)
+	}
+
+	want := []chatRequest{
+		{Query: first.Question, ConversationID: ""},
+		{Query: followUp.Question, ConversationID: "conv-new"},
+	}
+	if !slices.Equal(*received, want) {
+		t.Fatalf("dify requests = %+v, want %+v", *received, want)
+	}
+}
+
+func TestIsFatalErrorFollowsDifyClassification(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		err   error
+		fatal bool
+	}{
+		{"client error", fmt.Errorf("failed to process task: %w", dify.ErrDifyClient), true},
+		{"invalid cards", fmt.Errorf("failed to process task: %w", dify.ErrInvalidCards), true},
+		{"stream timeout", dify.ErrStreamIdleTimeout, true},
+		{"canceled", context.Canceled, true},
+		{"server error", fmt.Errorf("failed to process task: %w", dify.ErrDifyServer), false},
+		{"request timeout", dify.ErrDifyTimeout, false},
+		{"malformed", dify.ErrDifyMalformed, false},
+	} {
+		if got := isFatalError(tc.err); got != tc.fatal {
+			t.Errorf("%s: isFatalError = %v, want %v", tc.name, got, tc.fatal)
+		}
+	}
+}
+
+func TestReportMetricsEmitsSummary(t *testing.T) {
+	logs := testutil.ObserveLogs(t)
+	w, qs := newFallbackWorker(t, http.StatusServiceUnavailable, enabledFallback)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	for _, id := range []string{"task-1", "task-2"} {
+		task := newTask("free")
+		task.ID = id
+		if err := qs.PushTask(ctx, task); err != nil {
+			t.Fatalf("PushTask: %v", err)
+		}
+	}
+	w.metrics.RecordProcessingTime(40 * time.Millisecond)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		w.reportMetrics(ctx, 10*time.Millisecond)
+	}()
+
+	deadline := time.Now().Add(time.Second)
+	for logs.FilterMessage("Queue Metrics").Len() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("no metrics summary logged within 1s")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("reportMetrics did not stop after cancel")
+	}
+
+	fields := logs.FilterMessage("Queue Metrics").All()[0].ContextMap()
+	if fields["queue_length"] != int64(2) || fields["peak_queue_length"] != int64(2) || fields["avg_latency"] != 40*time.Millisecond {
+		t.Fatalf("summary fields = %v, want queue length 2 and avg latency 40ms", fields)
+	}
+	for _, key := range []string{"processed", "failed"} {
+		if _, ok := fields[key]; !ok {
+			t.Fatalf("summary fields = %v, missing %s", fields, key)
+		}
+	}
+}
+
+// waitUntil 等待 cond 成立，超时则失败
+func waitUntil(t *testing.T, timeout time.Duration, cond func() bool, format string, args ...interface{}) {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf(format, args...)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestPausedWorkerLeavesTasksQueued(t *testing.T) {
+	w, qs, _ := newModeWorker(t, dify.ResponseModeBlocking)
+	ctx := context.Background()
+
+	w.Pause()
+	w.Start()
+	t.Cleanup(w.Stop)
+	if !w.Paused() {
+		t.Fatal("worker not paused")
+	}
+
+	ids := []string{"task-1", "task-2"}
+	for _, id := range ids {
+		task := newTask("free")
+		task.ID = id
+		if err := qs.PushTask(ctx, task); err != nil {
+			t.Fatalf("PushTask: %v", err)
+		}
+	}
+
+	// 暂停期间任务保留在队列中
+	time.Sleep(200 * time.Millisecond)
+	if n, err := qs.Length(ctx); err != nil || n != 2 {
+		t.Fatalf("queue length while paused = %d, %v, want 2", n, err)
+	}
+	for _, id := range ids {
+		if status, _ := qs.GetTaskStatus(ctx, id); status != TaskPending {
+			t.Fatalf("%s status while paused = %s, want pending", id, status)
+		}
+	}
+
+	w.Resume()
+	if w.Paused() {
+		t.Fatal("worker still paused after Resume")
+	}
+	for _, id := range ids {
+		waitUntil(t, 5*time.Second, func() bool {
+			status, _ := qs.GetTaskStatus(ctx, id)
+			return status == TaskCompleted
+		}, "%s was not processed after resume", id)
+	}
+	if n, err := qs.Length(ctx); err != nil || n != 0 {
+		t.Fatalf("queue length after resume = %d, %v, want 0", n, err)
+	}
+}
+
+// TestStopLetsInFlightTaskFinish 关闭时不再获取新任务，进行中的任务在关闭超时内完成
+func TestStopLetsInFlightTaskFinish(t *testing.T) {
+	w, qs, release := newBlockingWorker(t)
+	w.config.ShutdownTimeout = 5 * time.Second
+	ctx := context.Background()
+
+	w.Start()
+	task := newTask("free")
+	if err := qs.PushTask(ctx, task); err != nil {
+		t.Fatalf("PushTask: %v", err)
+	}
+	waitUntil(t, 5*time.Second, func() bool { return w.Stats().InFlight == 1 },
+		"task did not start")
+
+	stopped := make(chan struct{})
+	go func() {
+		w.Stop()
+		close(stopped)
+	}()
+
+	// 关闭开始后提交的任务留在队列中
+	waitUntil(t, time.Second, func() bool { return w.ctx.Err() != nil }, "Stop did not stop dequeuing")
+	late := newTask("free")
+	late.ID = "task-late"
+	late.UserID = "user-2"
+	if err := qs.PushTask(ctx, late); err != nil {
+		t.Fatalf("PushTask: %v", err)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	select {
+	case <-stopped:
+		t.Fatal("Stop returned before the in-flight task finished")
+	default:
+	}
+	release()
+
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Stop did not return after the task finished")
+	}
+	result, err := qs.GetTaskResult(ctx, task.ID)
+	if err != nil || result == nil || !strings.Contains(result.Result, "解读结果") {
+		t.Fatalf("in-flight task result = %+v, %v, want completed", result, err)
+	}
+	if status, _ := qs.GetTaskStatus(ctx, late.ID); status != TaskPending {
+		t.Fatalf("late task status = %s, want pending", status)
+	}
+	if n, _ := qs.Length(ctx); n != 1 {
+		t.Fatalf("queue length = %d, want the late task still queued", n)
+	}
+}
+
+// TestStopRequeuesTaskAfterShutdownTimeout 关闭超时后强制中断的任务放回队列，不算作失败
+func TestStopRequeuesTaskAfterShutdownTimeout(t *testing.T) {
+	w, qs, _ := newBlockingWorker(t)
+	w.config.ShutdownTimeout = 200 * time.Millisecond
+	ctx := context.Background()
+
+	w.Start()
+	task := newTask("free")
+	if err := qs.PushTask(ctx, task); err != nil {
+		t.Fatalf("PushTask: %v", err)
+	}
+	waitUntil(t, 5*time.Second, func() bool { return w.Stats().InFlight == 1 },
+		"task did not start")
+
+	start := time.Now()
+	w.Stop()
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Fatalf("Stop took %s with a 200ms shutdown timeout", elapsed)
+	}
+
+	if status, _ := qs.GetTaskStatus(ctx, task.ID); status != TaskPending {
+		t.Fatalf("status = %s, want the interrupted task reset to pending", status)
+	}
+	if n, _ := qs.Length(ctx); n != 1 {
+		t.Fatalf("queue length = %d, want the interrupted task requeued", n)
+	}
+	ids, _ := qs.ActiveWorkers(ctx)
+	if len(ids) != 0 {
+		t.Fatalf("ActiveWorkers after Stop = %v, want heartbeats removed", ids)
+	}
+	got, err := qs.DequeueTask(ctx, "worker-next")
+	if err != nil || got == nil || got.ID != task.ID {
+		t.Fatalf("DequeueTask = %+v, %v, want the requeued task", got, err)
+	}
+}
+
+// TestWorkersShareOutboundDifyRate 多个工作器同时消化积压任务时，发往 Dify 的请求不超过配置的速率
+func TestWorkersShareOutboundDifyRate(t *testing.T) {
+	testutil.SetupRedis(t)
+	ctx := context.Background()
+
+	var mu sync.Mutex
+	var calls []time.Time
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		calls = append(calls, time.Now())
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data":{"status":"succeeded","outputs":{"text":"解读结果"}}}`))
+	}))
+	t.Cleanup(server.Close)
+
+	const rateLimit = 10
+	ds := dify.NewDifyService(&dify.Config{
+		URLs:         []string{server.URL},
+		APIKeys:      []string{"app-test"},
+		Timeout:      5 * time.Second,
+		ResponseMode: dify.ResponseModeBlocking,
+		AppMode:      dify.AppModeWorkflow,
+		RateLimit:    rateLimit,
+		RateBurst:    1,
+	})
+	if ds == nil {
+		t.Fatal("NewDifyService returned nil")
+	}
+
+	qs := NewQueueService()
+	// 问题各不相同，避免相同的解读被合并成一次请求
+	var ids []string
+	for i := 0; i < 15; i++ {
+		task := newTask("free")
+		task.ID = fmt.Sprintf("task-%d", i)
+		task.UserID = fmt.Sprintf("user-%d", i)
+		task.Question = fmt.Sprintf("第 %d 个问题：我最近的事业运势如何？", i)
+		if err := qs.PushTask(ctx, task); err != nil {
+			t.Fatalf("PushTask: %v", err)
+		}
+		ids = append(ids, task.ID)
+	}
+
+	w := NewWorker(qs, ds, WorkerConfig{WorkerCount: 5, TaskTimeout: 5 * time.Second, BatchSize: 1})
+	w.retryConfig.MaxRetries = 0
+	w.Start()
+	t.Cleanup(w.Stop)
+	for _, id := range ids {
+		waitUntil(t, 10*time.Second, func() bool {
+			status, _ := qs.GetTaskStatus(ctx, id)
+			return status == TaskCompleted
+		}, "%s was not processed", id)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(calls) != len(ids) {
+		t.Fatalf("dify received %d requests, want %d", len(calls), len(ids))
+	}
+	slices.SortFunc(calls, func(a, b time.Time) int { return a.Compare(b) })
+	// 任意连续 rateLimit+1 次请求至少间隔接近 1 秒
+	for i := rateLimit; i < len(calls); i++ {
+		if span := calls[i].Sub(calls[i-rateLimit]); span < 900*time.Millisecond {
+			t.Fatalf("%d requests within %s, want at most %d per second", rateLimit+1, span, rateLimit)
+		}
+	}
+}
+
+// TestConcurrentIdenticalReadingsShareOneDifyCall 同时处理的相同解读只调用一次 Dify，所有任务得到同一结果
+func TestConcurrentIdenticalReadingsShareOneDifyCall(t *testing.T) {
+	testutil.SetupRedis(t)
+	ctx := context.Background()
+	const n = 5
+
+	var calls atomic.Int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		select {
+		case <-release:
+		case <-r.Context().Done():
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data":{"status":"succeeded","outputs":{"text":"共享结果"}}}`))
+	}))
+	t.Cleanup(server.Close)
+
+	ds := dify.NewDifyService(&dify.Config{
+		URLs:         []string{server.URL},
+		APIKeys:      []string{"app-test"},
+		Timeout:      5 * time.Second,
+		ResponseMode: dify.ResponseModeBlocking,
+		AppMode:      dify.AppModeWorkflow,
+	})
+	if ds == nil {
+		t.Fatal("NewDifyService returned nil")
+	}
+
+	// 不同用户提交了问题与卡牌都相同的解读
+	qs := NewQueueService()
+	var ids []string
+	for i := 0; i < n; i++ {
+		task := newTask("free")
+		task.ID = fmt.Sprintf("task-%d", i)
+		task.UserID = fmt.Sprintf("user-%d", i)
+		if err := qs.PushTask(ctx, task); err != nil {
+			t.Fatalf("PushTask: %v", err)
+		}
+		ids = append(ids, task.ID)
+	}
+
+	w := NewWorker(qs, ds, WorkerConfig{WorkerCount: n, TaskTimeout: 5 * time.Second, BatchSize: 1})
+	w.retryConfig.MaxRetries = 0
+	w.Start()
+	t.Cleanup(w.Stop)
+
+	waitUntil(t, 5*time.Second, func() bool { return w.Stats().InFlight == n },
+		"in flight = %d, want all %d tasks waiting on Dify", w.Stats().InFlight, n)
+	close(release)
+
+	for _, id := range ids {
+		waitUntil(t, 5*time.Second, func() bool {
+			status, _ := qs.GetTaskStatus(ctx, id)
+			return status == TaskCompleted
+		}, "%s was not completed", id)
+		result, err := qs.GetTaskResult(ctx, id)
+		if err != nil || result == nil || result.Result != "共享结果" {
+			t.Fatalf("%s result = %+v, %v, want the shared result", id, result, err)
+		}
+	}
+	if got := calls.Load(); got != 1 {
+		t.Fatalf("dify called %d times for %d identical readings, want 1", got, n)
+	}
+}
+
+// workflowAnswer 返回以 text 作为工作流输出的假 Dify 实例
+func workflowAnswer(text string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"data":{"status":"succeeded","outputs":{"text":%q}}}`, text)
+	}
+}
+
+// newInstancesWorker 创建连接到多个假 Dify 工作流实例（阻塞模式）的工作器，handlers 依次对应各实例
+// 返回每个实例收到的请求数
+func newInstancesWorker(t *testing.T, cfg dify.Config, handlers ...http.HandlerFunc) (*Worker, *QueueService, []*atomic.Int32) {
+	t.Helper()
+	testutil.SetupRedis(t)
+
+	calls := make([]*atomic.Int32, len(handlers))
+	for i, handler := range handlers {
+		var count atomic.Int32
+		calls[i] = &count
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			count.Add(1)
+			handler(w, r)
+		}))
+		t.Cleanup(server.Close)
+		cfg.URLs = append(cfg.URLs, server.URL)
+		cfg.APIKeys = append(cfg.APIKeys, fmt.Sprintf("app-%d", i+1))
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = 5 * time.Second
+	}
+	cfg.ResponseMode = dify.ResponseModeBlocking
+	cfg.AppMode = dify.AppModeWorkflow
+
+	ds := dify.NewDifyService(&cfg)
+	if ds == nil {
+		t.Fatal("NewDifyService returned nil")
+	}
+	qs := NewQueueService()
+	w := NewWorker(qs, ds, WorkerConfig{WorkerCount: 1, TaskTimeout: 5 * time.Second})
+	w.retryConfig.MaxRetries = 0
+	return w, qs, calls
+}
+
+// TestWorkflowTasksFollowInstanceWeights 工作流阻塞任务按实例权重分配请求
+func TestWorkflowTasksFollowInstanceWeights(t *testing.T) {
+	w, qs, calls := newInstancesWorker(t, dify.Config{Weights: []string{"3", "1"}},
+		workflowAnswer("解读"), workflowAnswer("解读"))
+
+	for i := 0; i < 8; i++ {
+		task := newTask("free")
+		task.ID = fmt.Sprintf("task-%d", i)
+		if status, result := executeTask(t, w, qs, task); status != TaskCompleted || result != "解读" {
+			t.Fatalf("%s status=%s result=%q, want completed", task.ID, status, result)
+		}
+	}
+	if heavy, light := calls[0].Load(), calls[1].Load(); heavy != 6 || light != 2 {
+		t.Fatalf("requests per instance = %d:%d, want 6:2 for weights 3:1", heavy, light)
+	}
+}
+
+// TestWorkflowFailureBelowThresholdKeepsInstanceHealthy 工作流任务失败未达到错误阈值时实例保持健康
+func TestWorkflowFailureBelowThresholdKeepsInstanceHealthy(t *testing.T) {
+	var fail atomic.Bool
+	fail.Store(true)
+	w, qs, _ := newInstancesWorker(t, dify.Config{MaxRetries: 1, ErrorThreshold: 3}, func(rw http.ResponseWriter, r *http.Request) {
+		if fail.Load() {
+			rw.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		workflowAnswer("解读")(rw, r)
+	})
+
+	if status, _ := runTask(t, w, qs, "free"); status != TaskFailed {
+		t.Fatalf("status = %s, want failed", status)
+	}
+	instance := w.difyService.GetInstances()[0]
+	if !instance.Health || instance.ErrorCount != 1 {
+		t.Fatalf("health=%v errors=%d, want healthy with 1 error below threshold 3", instance.Health, instance.ErrorCount)
+	}
+
+	// 实例仍可用，后续任务正常完成并清零错误计数
+	fail.Store(false)
+	task := newTask("free")
+	task.ID = "task-recovered"
+	if status, result := executeTask(t, w, qs, task); status != TaskCompleted || result != "解读" {
+		t.Fatalf("status=%s result=%q, want completed", status, result)
+	}
+	if instance.ErrorCount != 0 {
+		t.Fatalf("errors = %d after success, want 0", instance.ErrorCount)
+	}
+}
+
+// TestWorkflowRetriesOnAnotherInstanceDuringCooldown 工作流任务失败的实例进入冷却，同一任务改用其他实例重试
+func TestWorkflowRetriesOnAnotherInstanceDuringCooldown(t *testing.T) {
+	failing := func(rw http.ResponseWriter, r *http.Request) { rw.WriteHeader(http.StatusServiceUnavailable) }
+	w, qs, calls := newInstancesWorker(t, dify.Config{MaxRetries: 2, ErrorThreshold: 100, Cooldown: time.Minute},
+		failing, workflowAnswer("备用解读"))
+	// 让失败的实例先被选中
+	w.difyService.GetInstances()[1].RequestCount.AddRequest()
+
+	if status, result := runTask(t, w, qs, "free"); status != TaskCompleted || result != "备用解读" {
+		t.Fatalf("status=%s result=%q, want completed by the second instance", status, result)
+	}
+	if calls[0].Load() != 1 || calls[1].Load() != 1 {
+		t.Fatalf("requests = %d, %d, want one attempt on each instance", calls[0].Load(), calls[1].Load())
+	}
+	failed := w.difyService.GetInstances()[0]
+	if !failed.Health || !time.Now().Before(failed.CooldownTill) {
+		t.Fatalf("health=%v cooldown till %v, want a healthy instance in cooldown", failed.Health, failed.CooldownTill)
+	}
+
+	// 冷却期间后续任务不再选择失败的实例
+	task := newTask("free")
+	task.ID = "task-cooling"
+	if status, _ := executeTask(t, w, qs, task); status != TaskCompleted {
+		t.Fatalf("status = %s, want completed", status)
+	}
+	if calls[0].Load() != 1 {
+		t.Fatalf("cooling instance received %d requests, want 1", calls[0].Load())
+	}
+}
+
+// TestWorkflowTaskSendsFormattedCards 工作流任务按 Dify 服务的格式发送卡牌，结果为解析后的输出文本
+func TestWorkflowTaskSendsFormattedCards(t *testing.T) {
+	var inputs map[string]interface{}
+	w, qs, calls := newInstancesWorker(t, dify.Config{}, func(rw http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Inputs map[string]interface{} `json:"inputs"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		inputs = body.Inputs
+		workflowAnswer("三张牌解读")(rw, r)
+	})
+
+	if status, result := runTask(t, w, qs, "free"); status != TaskCompleted || result != "三张牌解读" {
+		t.Fatalf("status=%s result=%q, want the workflow output text", status, result)
+	}
+	if inputs["cards"] != "1,2,3" {
+		t.Fatalf("dify cards = %v, want \"1,2,3\"", inputs["cards"])
+	}
+
+	// 没有卡牌时不调用 Dify，按不可重试的错误处理
+	task := newTask("free")
+	task.Cards = nil
+	if err := w.processTask(context.Background(), task); !errors.Is(err, dify.ErrInvalidCards) {
+		t.Fatalf("processTask error = %v, want ErrInvalidCards", err)
+	}
+	if calls[0].Load() != 1 {
+		t.Fatalf("dify called %d times, want no request for zero cards", calls[0].Load())
+	}
+}
+
+// TestWorkflowTaskBoundedByDifyTimeout 工作流阻塞请求受 dify.timeout 约束，不会一直等到任务超时
+func TestWorkflowTaskBoundedByDifyTimeout(t *testing.T) {
+	release := make(chan struct{})
+	w, qs, _ := newInstancesWorker(t, dify.Config{Timeout: 200 * time.Millisecond, MaxRetries: 1}, func(rw http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	})
+	t.Cleanup(func() { close(release) })
+
+	start := time.Now()
+	if status, _ := runTask(t, w, qs, "free"); status != TaskFailed {
+		t.Fatalf("status = %s, want failed", status)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("task took %s, want the 200ms dify timeout to cut the request before the 5s task timeout", elapsed)
+	}
+}
+
+// TestWorkerLogsStructuredFields 工作器与 Dify 的日志以独立字段记录任务、实例与耗时，可按请求 ID 关联
+func TestWorkerLogsStructuredFields(t *testing.T) {
+	logs := testutil.ObserveLogs(t)
+	w, qs, _ := newChatWorker(t)
+
+	task := newTask("free")
+	task.RequestID = "req-structured"
+	if status, _ := executeTask(t, w, qs, task); status != TaskCompleted {
+		t.Fatalf("status = %s, want completed", status)
+	}
+
+	find := func(module, event string) map[string]interface{} {
+		t.Helper()
+		for _, entry := range logs.FilterMessage(module).All() {
+			if fields := entry.ContextMap(); fields[logger.FieldEvent] == event {
+				return fields
+			}
+		}
+		t.Fatalf("no %s %s log entry", module, event)
+		return nil
+	}
+
+	worker := find("Worker", "Success")
+	if worker[logger.FieldTaskID] != task.ID || worker["request_id"] != task.RequestID {
+		t.Fatalf("worker fields = %v, want task and request id", worker)
+	}
+	for _, key := range []string{logger.FieldWorkerID, logger.FieldLatencyMs} {
+		if _, ok := worker[key]; !ok {
+			t.Fatalf("worker fields = %v, missing %s", worker, key)
+		}
+	}
+
+	call := find("Dify", "Success")
+	if call["request_id"] != task.RequestID || call[logger.FieldInstance] == "" {
+		t.Fatalf("dify fields = %v, want request id and instance", call)
+	}
+	if _, ok := call[logger.FieldLatencyMs].(float64); !ok {
+		t.Fatalf("dify latency = %v, want a number", call[logger.FieldLatencyMs])
+	}
+}
+
+func TestTaskValidate(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		question string
+		cards    []int
+		valid    bool
+	}{
+		{"valid", "我最近的事业运势如何？", []int{1, 22, 78}, true},
+		{"empty question", "", []int{1}, false},
+		{"blank question", "          ", []int{1}, false},
+		{"no cards", "我最近的事业运势如何？", nil, false},
+		{"card zero", "我最近的事业运势如何？", []int{0, 1}, false},
+		{"card out of range", "我最近的事业运势如何？", []int{1, 79}, false},
+		{"too many cards", "我最近的事业运势如何？", []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11}, false},
+	} {
+		task := &TarotTask{Question: tc.question, Cards: tc.cards}
+		err := task.Validate()
+		if (err == nil) != tc.valid {
+			t.Errorf("%s: Validate = %v, want valid=%v", tc.name, err, tc.valid)
+		}
+		if err != nil && !errors.Is(err, ErrInvalidTask) {
+			t.Errorf("%s: error = %v, want ErrInvalidTask", tc.name, err)
+		}
+	}
+}
+
+// TestInvalidTaskFailsWithoutDifyCall 无效任务直接标记为失败并记录原因，不调用 Dify，也不使用兜底结果
+func TestInvalidTaskFailsWithoutDifyCall(t *testing.T) {
+	w, qs, received := newChatWorker(t)
+	server := testutil.SetupRedis(t)
+	w.config.Fallback = enabledFallback
+	w.retryConfig.MaxRetries = 3
+
+	for _, tc := range []struct {
+		name   string
+		cards  []int
+		reason string
+	}{
+		{"card out of range", []int{1, 99}, "无效的卡牌编号: 99"},
+		{"no cards", []int{}, "至少需要选择一张卡牌"},
+	} {
+		task := newTask("free")
+		task.ID = "task-" + strings.ReplaceAll(tc.name, " ", "-")
+		task.Cards = tc.cards
+		if status, _ := executeTask(t, w, qs, task); status != TaskFailed {
+			t.Fatalf("%s: status = %s, want failed", tc.name, status)
+		}
+		// 失败原因写入任务结果
+		if reason, _ := server.Get(qs.resultKey(task.ID)); !strings.Contains(reason, tc.reason) {
+			t.Fatalf("%s: recorded reason = %q, want %q", tc.name, reason, tc.reason)
+		}
+	}
+	if n := len(*received); n != 0 {
+		t.Fatalf("dify called %d times for invalid tasks, want 0", n)
+	}
+
+	// 有效任务照常调用 Dify
+	if status, _ := executeTask(t, w, qs, newTask("free")); status != TaskCompleted {
+		t.Fatalf("valid task status = %s, want completed", status)
+	}
+	if n := len(*received); n != 1 {
+		t.Fatalf("dify called %d times, want 1 for the valid task", n)
+	}
+}
+
+// recordedResult 工作器写入解读记录的结果
+type recordedResult struct {
+	TaskID         string
+	Status         reading.Status
+	Interpretation string
+}
+
+// fakeResults 记录工作器写入的结果，known 之外的任务视为没有对应的解读记录
+type fakeResults struct {
+	mu      sync.Mutex
+	known   map[string]bool
+	results []recordedResult
+}
+
+func (f *fakeResults) UpdateResultByTaskID(ctx context.Context, taskID string, status reading.Status, interpretation string) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.results = append(f.results, recordedResult{taskID, status, interpretation})
+	return f.known[taskID], nil
+}
+
+func TestWorkerRecordsResultByTaskID(t *testing.T) {
+	logs := testutil.ObserveLogs(t)
+	results := &fakeResults{known: map[string]bool{"task-ok": true, "task-fallback": true, "task-failed": true}}
+
+	w, qs, _ := newChatWorker(t)
+	w.config.Results = results
+	ok := newTask("free")
+	ok.ID = "task-ok"
+	if status, _ := executeTask(t, w, qs, ok); status != TaskCompleted {
+		t.Fatalf("status = %s, want completed", status)
+	}
+
+	down, downQS := newFallbackWorker(t, http.StatusServiceUnavailable, enabledFallback)
+	down.config.Results = results
+	fallback := newTask("free")
+	fallback.ID = "task-fallback"
+	executeTask(t, down, downQS, fallback)
+
+	down.config.Fallback = FallbackConfig{}
+	failed := newTask("free")
+	failed.ID = "task-failed"
+	executeTask(t, down, downQS, failed)
+
+	// 没有对应记录的任务照常完成，只记录警告
+	orphan := newTask("free")
+	orphan.ID = "task-orphan"
+	if status, _ := executeTask(t, w, qs, orphan); status != TaskCompleted {
+		t.Fatalf("orphan status = %s, want completed", status)
+	}
+
+	want := []recordedResult{
+		{"task-ok", reading.StatusCompleted, "对话结果"},
+		{"task-fallback", reading.StatusCompleted, fallbackMessage},
+		{"task-failed", reading.StatusFailed, ""},
+		{"task-orphan", reading.StatusCompleted, "对话结果"},
+	}
+	if !slices.Equal(results.results, want) {
+		t.Fatalf("recorded results = %+v, want %+v", results.results, want)
+	}
+	if logs.FilterMessage("Worker").FilterField(logger.TaskID("task-orphan")).FilterField(zap.String("reason", "reading not found")).Len() != 1 {
+		t.Fatal("missing reading was not logged")
+	}
+}
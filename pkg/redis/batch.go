@@ -0,0 +1,95 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	redis "github.com/redis/go-redis/v9"
+)
+
+/* 📦 批量操作相关方法 */
+
+// MGet 批量获取键值，返回的 map 只包含存在的 key
+func (rds *RedisClient) MGet(keys ...string) (map[string]string, error) {
+	return rds.MGetContext(rds.Context, keys...)
+}
+
+// MGetContext 与 MGet 相同，请求取消时一并中断，整体仍受 DefaultTimeout 限制
+// 集群模式下 key 可能分布在不同的槽，改为按节点拆分的管道 GET
+func (rds *RedisClient) MGetContext(ctx context.Context, keys ...string) (map[string]string, error) {
+	result := make(map[string]string, len(keys))
+	if len(keys) == 0 {
+		return result, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, DefaultTimeout)
+	defer cancel()
+
+	rds.mutex.RLock()
+	defer rds.mutex.RUnlock()
+
+	if _, ok := rds.Client.(*redis.ClusterClient); ok {
+		cmds := make([]*redis.StringCmd, len(keys))
+		_, err := rds.Client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+			for i, key := range keys {
+				cmds[i] = pipe.Get(ctx, key)
+			}
+			return nil
+		})
+		if err != nil && err != redis.Nil {
+			return nil, fmt.Errorf("redis mget error: %w", err)
+		}
+		for i, cmd := range cmds {
+			if value, err := cmd.Result(); err == nil {
+				result[keys[i]] = value
+			}
+		}
+		return result, nil
+	}
+
+	values, err := rds.Client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, fmt.Errorf("redis mget error: %w", err)
+	}
+	for i, value := range values {
+		if s, ok := value.(string); ok {
+			result[keys[i]] = s
+		}
+	}
+	return result, nil
+}
+
+// MSet 批量存储键值对，expiration 为 0 表示不过期
+// 使用管道逐个 SET，以支持过期时间并兼容集群模式
+func (rds *RedisClient) MSet(pairs map[string]interface{}, expiration time.Duration) error {
+	if len(pairs) == 0 {
+		return nil
+	}
+
+	_, err := rds.Pipeline(func(ctx context.Context, pipe redis.Pipeliner) error {
+		for key, value := range pairs {
+			pipe.Set(ctx, key, value, expiration)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("redis mset error: %w", err)
+	}
+	return nil
+}
+
+// Pipeline 在一次往返中执行 fn 内追加的所有命令
+// fn 中的命令应使用传入的 ctx，整体受 DefaultTimeout 限制；
+// 返回各命令的结果，任一命令失败时返回第一个错误
+func (rds *RedisClient) Pipeline(fn func(ctx context.Context, pipe redis.Pipeliner) error) ([]redis.Cmder, error) {
+	ctx, cancel := context.WithTimeout(rds.Context, DefaultTimeout)
+	defer cancel()
+
+	rds.mutex.Lock()
+	defer rds.mutex.Unlock()
+
+	return rds.Client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		return fn(ctx, pipe)
+	})
+}
@@ -0,0 +1,92 @@
+package redis
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	redis "github.com/redis/go-redis/v9"
+)
+
+func TestMGetMixedHitAndMiss(t *testing.T) {
+	rds, server := newTestClient(t)
+	server.Set("status:{a}", "completed")
+	server.Set("status:{c}", "pending")
+
+	got, err := rds.MGet("status:{a}", "status:{b}", "status:{c}")
+	if err != nil {
+		t.Fatalf("MGet: %v", err)
+	}
+	if len(got) != 2 || got["status:{a}"] != "completed" || got["status:{c}"] != "pending" {
+		t.Fatalf("MGet = %v, want only the two existing keys", got)
+	}
+	if _, ok := got["status:{b}"]; ok {
+		t.Fatal("MGet included a missing key")
+	}
+
+	if got, err := rds.MGet(); err != nil || len(got) != 0 {
+		t.Fatalf("MGet() = %v, %v, want empty", got, err)
+	}
+}
+
+func TestMGetMixedHitAndMissOnCluster(t *testing.T) {
+	server := miniredis.RunT(t)
+	rds := NewClient(RedisConfig{Mode: ModeCluster, Addresses: []string{server.Addr()}, PoolSize: 2})
+	t.Cleanup(func() { rds.Client.Close() })
+	server.Set("status:{a}", "completed")
+
+	got, err := rds.MGet("status:{a}", "status:{b}")
+	if err != nil {
+		t.Fatalf("MGet: %v", err)
+	}
+	if len(got) != 1 || got["status:{a}"] != "completed" {
+		t.Fatalf("MGet = %v, want only status:{a}", got)
+	}
+}
+
+func TestMSetWithExpiration(t *testing.T) {
+	rds, server := newTestClient(t)
+
+	if err := rds.MSet(map[string]interface{}{"k1": "v1", "k2": 2}, time.Minute); err != nil {
+		t.Fatalf("MSet: %v", err)
+	}
+	got, err := rds.MGet("k1", "k2")
+	if err != nil || got["k1"] != "v1" || got["k2"] != "2" {
+		t.Fatalf("MGet after MSet = %v, %v", got, err)
+	}
+	if ttl := server.TTL("k1"); ttl != time.Minute {
+		t.Fatalf("TTL = %v, want 1m", ttl)
+	}
+}
+
+func TestPipelineMultiWrite(t *testing.T) {
+	rds, server := newTestClient(t)
+
+	cmds, err := rds.Pipeline(func(ctx context.Context, pipe redis.Pipeliner) error {
+		pipe.Set(ctx, "task:status", "running", 0)
+		pipe.Incr(ctx, "task:attempts")
+		pipe.Incr(ctx, "task:attempts")
+		pipe.RPush(ctx, "task:log", "started", "retried")
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Pipeline: %v", err)
+	}
+	if len(cmds) != 4 {
+		t.Fatalf("Pipeline returned %d commands, want 4", len(cmds))
+	}
+	if n, _ := cmds[2].(*redis.IntCmd).Result(); n != 2 {
+		t.Fatalf("second INCR = %d, want 2", n)
+	}
+
+	if v, _ := server.Get("task:status"); v != "running" {
+		t.Fatalf("task:status = %q, want running", v)
+	}
+	if v, _ := server.Get("task:attempts"); v != "2" {
+		t.Fatalf("task:attempts = %q, want 2", v)
+	}
+	if list, _ := server.List("task:log"); len(list) != 2 {
+		t.Fatalf("task:log = %v, want 2 entries", list)
+	}
+}
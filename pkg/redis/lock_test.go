@@ -0,0 +1,102 @@
+package redis
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+// newTestClient 创建连接到独立 miniredis 的客户端
+func newTestClient(t *testing.T) (*RedisClient, *miniredis.Miniredis) {
+	t.Helper()
+	server := miniredis.RunT(t)
+	rds := NewClient(RedisConfig{Address: server.Addr(), PoolSize: 10, MinIdleConns: 1})
+	t.Cleanup(func() { rds.Client.Close() })
+	return rds, server
+}
+
+func TestLockMutualExclusion(t *testing.T) {
+	rds, _ := newTestClient(t)
+
+	token, err := rds.Lock("credits:user-1", time.Minute)
+	if err != nil {
+		t.Fatalf("Lock: %v", err)
+	}
+	if _, err := rds.Lock("credits:user-1", time.Minute); !errors.Is(err, ErrLockNotAcquired) {
+		t.Fatalf("second Lock error = %v, want ErrLockNotAcquired", err)
+	}
+	if err := rds.Unlock("credits:user-1", "other-token"); !errors.Is(err, ErrLockNotHeld) {
+		t.Fatalf("Unlock with wrong token error = %v, want ErrLockNotHeld", err)
+	}
+
+	ran := false
+	if err := rds.WithLock("credits:user-1", time.Minute, func() error { ran = true; return nil }); !errors.Is(err, ErrLockNotAcquired) || ran {
+		t.Fatalf("WithLock while held: err = %v, ran = %v", err, ran)
+	}
+
+	if err := rds.Unlock("credits:user-1", token); err != nil {
+		t.Fatalf("Unlock: %v", err)
+	}
+	if err := rds.WithLock("credits:user-1", time.Minute, func() error { ran = true; return nil }); err != nil || !ran {
+		t.Fatalf("WithLock after unlock: err = %v, ran = %v", err, ran)
+	}
+	if rds.Has(lockKey("credits:user-1")) {
+		t.Fatal("WithLock did not release the lock")
+	}
+}
+
+func TestLockConcurrentHolders(t *testing.T) {
+	rds, _ := newTestClient(t)
+
+	var holders, acquired atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := rds.WithLock("notify:order-1", time.Minute, func() error {
+				if holders.Add(1) > 1 {
+					t.Error("more than one holder inside the lock")
+				}
+				acquired.Add(1)
+				time.Sleep(5 * time.Millisecond)
+				holders.Add(-1)
+				return nil
+			})
+			if err != nil && !errors.Is(err, ErrLockNotAcquired) {
+				t.Errorf("WithLock: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if acquired.Load() == 0 {
+		t.Fatal("no goroutine acquired the lock")
+	}
+}
+
+func TestLockExpires(t *testing.T) {
+	rds, server := newTestClient(t)
+
+	token, err := rds.Lock("promote", time.Second)
+	if err != nil {
+		t.Fatalf("Lock: %v", err)
+	}
+	server.FastForward(2 * time.Second)
+
+	next, err := rds.Lock("promote", time.Second)
+	if err != nil {
+		t.Fatalf("Lock after expiry: %v", err)
+	}
+	// 过期的持有者不能释放新持有者的锁
+	if err := rds.Unlock("promote", token); !errors.Is(err, ErrLockNotHeld) {
+		t.Fatalf("stale Unlock error = %v, want ErrLockNotHeld", err)
+	}
+	if err := rds.Unlock("promote", next); err != nil {
+		t.Fatalf("Unlock: %v", err)
+	}
+}
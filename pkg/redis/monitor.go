@@ -0,0 +1,127 @@
+package redis
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"tarot/pkg/logger"
+)
+
+// DefaultHealthInterval 默认健康检查间隔
+const DefaultHealthInterval = 10 * time.Second
+
+// Status Redis 实例健康状态
+type Status struct {
+	Healthy   bool      `json:"healthy"`
+	Since     time.Time `json:"since"`                // 进入当前状态的时间
+	LastCheck time.Time `json:"last_check"`           // 最近一次检查时间
+	LastError string    `json:"last_error,omitempty"` // 最近一次检查失败的原因
+}
+
+// healthState 并发安全的健康状态
+type healthState struct {
+	mu     sync.RWMutex
+	status Status
+}
+
+// update 记录一次检查结果，与上一次检查相比状态发生变化时返回 true（首次检查不算变化）
+func (h *healthState) update(err error, now time.Time) (changed bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	healthy := err == nil
+	first := h.status.LastCheck.IsZero()
+	changed = !first && healthy != h.status.Healthy
+	if first || changed {
+		h.status.Since = now
+	}
+	h.status.Healthy = healthy
+	h.status.LastCheck = now
+	h.status.LastError = ""
+	if err != nil {
+		h.status.LastError = err.Error()
+	}
+	return changed
+}
+
+func (h *healthState) get() Status {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.status
+}
+
+/* 🩺 健康监控相关方法 */
+
+// Status 返回最近一次健康检查的结果
+// 未启动监控时会立即执行一次检查
+func (rds *RedisClient) Status() Status {
+	if status := rds.health.get(); !status.LastCheck.IsZero() {
+		return status
+	}
+	rds.CheckHealth()
+	return rds.health.get()
+}
+
+// CheckHealth 执行一次健康检查并记录结果，状态变化时输出日志
+func (rds *RedisClient) CheckHealth() bool {
+	err := rds.Ping()
+	previous := rds.health.get()
+	changed := rds.health.update(err, time.Now())
+
+	switch {
+	case err != nil && (changed || previous.LastCheck.IsZero()):
+		logger.ErrorString("Redis", "Health", fmt.Sprintf("Redis 不可用: %v", err))
+	case err == nil && changed:
+		logger.InfoString("Redis", "Health", fmt.Sprintf(
+			"Redis 已恢复，不可用时长: %v", time.Since(previous.Since).Round(time.Second)))
+	}
+	return err == nil
+}
+
+// StartMonitor 启动后台健康检查，按 interval 定期 Ping
+// go-redis 会在下一次请求时自动重建连接，监控只负责记录状态与输出状态变化日志；
+// 多次调用只会启动一个监控
+func (rds *RedisClient) StartMonitor(interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultHealthInterval
+	}
+
+	rds.monitorOnce.Do(func() {
+		rds.CheckHealth()
+		go func() {
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-rds.Context.Done():
+					return
+				case <-ticker.C:
+					rds.CheckHealth()
+				}
+			}
+		}()
+	})
+}
+
+// StartMonitors 为所有实例启动健康检查
+func (m *RedisManager) StartMonitors(interval time.Duration) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	for _, client := range m.instances {
+		client.StartMonitor(interval)
+	}
+}
+
+// Statuses 返回所有实例的健康状态
+func (m *RedisManager) Statuses() map[RedisInstance]Status {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	statuses := make(map[RedisInstance]Status, len(m.instances))
+	for name, client := range m.instances {
+		statuses[name] = client.Status()
+	}
+	return statuses
+}
@@ -0,0 +1,99 @@
+package redis
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	"tarot/pkg/logger"
+)
+
+func init() {
+	// 后台监控会在测试结束后继续输出日志，使用不替换的空 Logger
+	if logger.Logger == nil {
+		logger.Logger = zap.NewNop()
+	}
+}
+
+// observeLogs 在测试期间记录 logger.Logger 的输出
+func observeLogs(t *testing.T) *observer.ObservedLogs {
+	t.Helper()
+	core, logs := observer.New(zap.DebugLevel)
+	prev := logger.Logger
+	logger.Logger = zap.New(core)
+	t.Cleanup(func() { logger.Logger = prev })
+	return logs
+}
+
+func TestCheckHealthTransitions(t *testing.T) {
+	logs := observeLogs(t)
+	rds, server := newTestClient(t)
+
+	if !rds.CheckHealth() || !rds.Status().Healthy {
+		t.Fatalf("initial status = %+v, want healthy", rds.Status())
+	}
+	since := rds.Status().Since
+
+	server.SetError("LOADING Redis is loading the dataset in memory")
+	if rds.CheckHealth() {
+		t.Fatal("CheckHealth = true while redis is down")
+	}
+	down := rds.Status()
+	if down.Healthy || down.LastError == "" || !down.Since.After(since) {
+		t.Fatalf("status while down = %+v, want unhealthy with error and new since", down)
+	}
+	// 持续不可用时不重复记录日志，也不重置进入状态的时间
+	rds.CheckHealth()
+	if rds.Status().Since != down.Since {
+		t.Fatalf("since changed while still down: %v -> %v", down.Since, rds.Status().Since)
+	}
+
+	server.SetError("")
+	if !rds.CheckHealth() {
+		t.Fatal("CheckHealth = false after redis recovered")
+	}
+	if up := rds.Status(); !up.Healthy || up.LastError != "" {
+		t.Fatalf("status after recovery = %+v, want healthy", up)
+	}
+
+	if n := logs.FilterLevelExact(zapcore.ErrorLevel).Len(); n != 1 {
+		t.Fatalf("logged %d errors, want 1 for the down transition", n)
+	}
+	if n := logs.FilterLevelExact(zapcore.InfoLevel).Len(); n != 1 {
+		t.Fatalf("logged %d info entries, want 1 for the recovery", n)
+	}
+}
+
+func TestStartMonitorTracksStatus(t *testing.T) {
+	rds, server := newTestClient(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	rds.Context = ctx
+	t.Cleanup(cancel)
+
+	rds.StartMonitor(10 * time.Millisecond)
+	rds.StartMonitor(10 * time.Millisecond) // 重复调用不会启动第二个监控
+	if !rds.Status().Healthy {
+		t.Fatalf("status = %+v, want healthy", rds.Status())
+	}
+
+	server.SetError("ERR down")
+	waitStatus(t, rds, false)
+	server.SetError("")
+	waitStatus(t, rds, true)
+}
+
+// waitStatus 等待后台监控记录到期望的健康状态
+func waitStatus(t *testing.T, rds *RedisClient, healthy bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for rds.Status().Healthy != healthy {
+		if time.Now().After(deadline) {
+			t.Fatalf("status = %+v, want healthy=%v", rds.Status(), healthy)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
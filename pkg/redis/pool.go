@@ -0,0 +1,36 @@
+package redis
+
+// PoolStats 连接池统计，命中与超时次数为启动以来的累计值
+type PoolStats struct {
+	Hits       uint32 `json:"hits"`        // 从池中取到空闲连接的次数
+	Misses     uint32 `json:"misses"`      // 池中没有空闲连接、需新建连接的次数
+	Timeouts   uint32 `json:"timeouts"`    // 等待连接超时的次数，持续增长说明连接池不足
+	TotalConns uint32 `json:"total_conns"` // 当前连接总数
+	IdleConns  uint32 `json:"idle_conns"`  // 当前空闲连接数
+	StaleConns uint32 `json:"stale_conns"` // 已关闭的过期连接数
+}
+
+// PoolStats 返回客户端的连接池统计，集群模式下为所有节点之和
+func (rds *RedisClient) PoolStats() PoolStats {
+	stats := rds.Client.PoolStats()
+	return PoolStats{
+		Hits:       stats.Hits,
+		Misses:     stats.Misses,
+		Timeouts:   stats.Timeouts,
+		TotalConns: stats.TotalConns,
+		IdleConns:  stats.IdleConns,
+		StaleConns: stats.StaleConns,
+	}
+}
+
+// PoolStats 返回所有实例的连接池统计
+func (m *RedisManager) PoolStats() map[RedisInstance]PoolStats {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	stats := make(map[RedisInstance]PoolStats, len(m.instances))
+	for name, client := range m.instances {
+		stats[name] = client.PoolStats()
+	}
+	return stats
+}
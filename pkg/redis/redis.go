@@ -34,6 +34,14 @@ const (
 	DefaultMaxRetries = 3
 	// DefaultIdleTimeout 空闲超时
 	DefaultIdleTimeout = 5 * time.Minute
+	// DefaultConnMaxLifetime 连接最长存活时间
+	DefaultConnMaxLifetime = 24 * time.Hour
+	// DefaultDialTimeout 建立连接超时
+	DefaultDialTimeout = 5 * time.Second
+	// DefaultReadTimeout 读超时
+	DefaultReadTimeout = 3 * time.Second
+	// DefaultWriteTimeout 写超时
+	DefaultWriteTimeout = 3 * time.Second
 )
 
 // RedisInstance Redis 实例类型
@@ -42,24 +50,72 @@ type RedisInstance string
 const (
 	MainDB   RedisInstance = "main"   // 主数据库实例（用于限流等）
 	QueueDB  RedisInstance = "queue"  // 队列数据库实例
+	CacheDB  RedisInstance = "cache"  // 缓存、幂等与分布式锁使用的实例，与限流、队列的数据互不干扰
+)
+
+// 部署模式
+const (
+	ModeStandalone = "standalone" // 单机（含主从）
+	ModeCluster    = "cluster"    // Redis Cluster
 )
 
 // RedisClient Redis 客户端封装
+// Client 使用 UniversalClient，单机与集群模式下调用方式一致
 type RedisClient struct {
-	Client  *redis.Client
+	Client  redis.UniversalClient
 	Context context.Context
 	mutex   sync.RWMutex // 用于并发安全的操作
+
+	health      healthState // 后台健康检查结果
+	monitorOnce sync.Once
 }
 
 // RedisConfig Redis 配置结构
 type RedisConfig struct {
+	Mode         string   // 部署模式：standalone / cluster
+	Addresses    []string // 集群节点地址，仅 cluster 模式使用
 	Address      string
 	Username     string
 	Password     string
 	DB           int
 	PoolSize     int
 	MinIdleConns int
-	Timeout      time.Duration
+	Timeout      time.Duration // 从连接池获取连接的超时时间
+
+	DialTimeout     time.Duration
+	ReadTimeout     time.Duration
+	WriteTimeout    time.Duration
+	ConnMaxIdleTime time.Duration
+	ConnMaxLifetime time.Duration
+}
+
+// withDefaults 未配置（<= 0）的项使用默认值
+func (c RedisConfig) withDefaults() RedisConfig {
+	if c.PoolSize <= 0 {
+		c.PoolSize = DefaultPoolSize
+	}
+	if c.MinIdleConns <= 0 {
+		c.MinIdleConns = DefaultMinIdleConns
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = DefaultTimeout
+	}
+	if c.DialTimeout <= 0 {
+		c.DialTimeout = DefaultDialTimeout
+	}
+	if c.ReadTimeout <= 0 {
+		c.ReadTimeout = DefaultReadTimeout
+	}
+	if c.WriteTimeout <= 0 {
+		c.WriteTimeout = DefaultWriteTimeout
+	}
+	if c.ConnMaxIdleTime <= 0 {
+		c.ConnMaxIdleTime = DefaultIdleTimeout
+	}
+	if c.ConnMaxLifetime <= 0 {
+		c.ConnMaxLifetime = DefaultConnMaxLifetime
+	}
+	return c
 }
 
 type RedisManager struct {
@@ -97,8 +153,25 @@ func NewClient(config RedisConfig) *RedisClient {
 		Context: context.Background(),
 	}
 
+	config = config.withDefaults()
+	if config.Mode == ModeCluster {
+		rds.Client = newClusterClient(config)
+	} else {
+		rds.Client = newStandaloneClient(config)
+	}
+
+	// 测试连接
+	if err := rds.Ping(); err != nil {
+		panic(fmt.Sprintf("Redis 连接失败: %v", err))
+	}
+
+	return rds
+}
+
+// newStandaloneClient 创建单机模式客户端
+func newStandaloneClient(config RedisConfig) *redis.Client {
 	// 优化的 Redis 客户端配置
-	rds.Client = redis.NewClient(&redis.Options{
+	return redis.NewClient(&redis.Options{
 		Addr:         config.Address,
 		Username:     config.Username,
 		Password:     config.Password,
@@ -108,25 +181,48 @@ func NewClient(config RedisConfig) *RedisClient {
 		
 		// 连接池配置
 		PoolTimeout:     config.Timeout,
-		ConnMaxIdleTime: DefaultIdleTimeout,
-		ConnMaxLifetime: 24 * time.Hour,
+		ConnMaxIdleTime: config.ConnMaxIdleTime,
+		ConnMaxLifetime: config.ConnMaxLifetime,
 		
 		// 读写超时
-		ReadTimeout:  3 * time.Second,
-		WriteTimeout: 3 * time.Second,
+		DialTimeout:  config.DialTimeout,
+		ReadTimeout:  config.ReadTimeout,
+		WriteTimeout: config.WriteTimeout,
 		
 		// 重试策略
 		MaxRetries:      DefaultMaxRetries,
 		MinRetryBackoff: 8 * time.Millisecond,
 		MaxRetryBackoff: 512 * time.Millisecond,
 	})
+}
 
-	// 测试连接
-	if err := rds.Ping(); err != nil {
-		panic(fmt.Sprintf("Redis 连接失败: %v", err))
+// newClusterClient 创建集群模式客户端
+// 集群不支持选择数据库，DB 配置被忽略，各业务通过 key 前缀区分
+func newClusterClient(config RedisConfig) *redis.ClusterClient {
+	addrs := config.Addresses
+	if len(addrs) == 0 {
+		addrs = []string{config.Address}
 	}
 
-	return rds
+	return redis.NewClusterClient(&redis.ClusterOptions{
+		Addrs:        addrs,
+		Username:     config.Username,
+		Password:     config.Password,
+		PoolSize:     config.PoolSize,
+		MinIdleConns: config.MinIdleConns,
+
+		PoolTimeout:     config.Timeout,
+		ConnMaxIdleTime: config.ConnMaxIdleTime,
+		ConnMaxLifetime: config.ConnMaxLifetime,
+
+		DialTimeout:  config.DialTimeout,
+		ReadTimeout:  config.ReadTimeout,
+		WriteTimeout: config.WriteTimeout,
+
+		MaxRetries:      DefaultMaxRetries,
+		MinRetryBackoff: 8 * time.Millisecond,
+		MaxRetryBackoff: 512 * time.Millisecond,
+	})
 }
 
 /* 🔍 健康检查方法 */
@@ -238,41 +334,36 @@ func (rds *RedisClient) Increment(parameters ...interface{}) bool {
 }
 
 // InitRedis 初始化 Redis 管理器
-func InitRedis(address, username, password string, mainDB, queueDB int) {
+// mainConfig、queueConfig 分别为主实例与队列实例的连接配置；
+// 集群模式下没有多库的概念，主实例与队列实例共用同一个集群客户端
+func InitRedis(mainConfig, queueConfig, cacheConfig RedisConfig) {
 	once.Do(func() {
 		Manager = &RedisManager{
 			instances: make(map[RedisInstance]*RedisClient),
 		}
 
 		// 初始化主数据库实例
-		mainConfig := RedisConfig{
-			Address:      address,
-			Username:     username,
-			Password:     password,
-			DB:          mainDB,
-			PoolSize:    DefaultPoolSize,
-			MinIdleConns: DefaultMinIdleConns,
-			Timeout:     DefaultTimeout,
-		}
 		Manager.instances[MainDB] = NewClient(mainConfig)
 
-		// 初始化队列数据库实例
-		queueConfig := RedisConfig{
-			Address:      address,
-			Username:     username,
-			Password:     password,
-			DB:          queueDB,
-			PoolSize:    DefaultPoolSize,
-			MinIdleConns: DefaultMinIdleConns,
-			Timeout:     DefaultTimeout,
+		// 初始化队列与缓存实例，集群模式不支持选择数据库，共用主实例
+		if mainConfig.Mode == ModeCluster {
+			Manager.instances[QueueDB] = Manager.instances[MainDB]
+			Manager.instances[CacheDB] = Manager.instances[MainDB]
+		} else {
+			Manager.instances[QueueDB] = NewClient(queueConfig)
+			Manager.instances[CacheDB] = NewClient(cacheConfig)
 		}
-		Manager.instances[QueueDB] = NewClient(queueConfig)
 
 		// 保持向后兼容
 		Redis = Manager.instances[MainDB]
 	})
 }
 
+// Cache 缓存、幂等与分布式锁使用的 Redis 实例
+func Cache() *RedisClient {
+	return GetRedis(CacheDB)
+}
+
 // GetRedis 获取指定的 Redis 实例
 func GetRedis(instance RedisInstance) *RedisClient {
 	Manager.mutex.RLock()
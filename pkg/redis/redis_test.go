@@ -0,0 +1,156 @@
+package redis
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	redis "github.com/redis/go-redis/v9"
+)
+
+// customConfig 每一项都不同于默认值，便于确认配置没有被默认值覆盖
+func customConfig(addr string) RedisConfig {
+	return RedisConfig{
+		Address:         addr,
+		DB:              2,
+		PoolSize:        7,
+		MinIdleConns:    3,
+		Timeout:         11 * time.Second,
+		DialTimeout:     2 * time.Second,
+		ReadTimeout:     4 * time.Second,
+		WriteTimeout:    6 * time.Second,
+		ConnMaxIdleTime: 90 * time.Second,
+		ConnMaxLifetime: time.Hour,
+	}
+}
+
+func TestNewClientAppliesConfig(t *testing.T) {
+	server := miniredis.RunT(t)
+	cfg := customConfig(server.Addr())
+
+	rds := NewClient(cfg)
+	t.Cleanup(func() { rds.Client.Close() })
+
+	client, ok := rds.Client.(*redis.Client)
+	if !ok {
+		t.Fatalf("client = %T, want *redis.Client", rds.Client)
+	}
+	opts := client.Options()
+	if opts.Addr != cfg.Address || opts.DB != cfg.DB || opts.PoolSize != cfg.PoolSize || opts.MinIdleConns != cfg.MinIdleConns {
+		t.Fatalf("options addr=%s db=%d pool=%d min_idle=%d, want %+v", opts.Addr, opts.DB, opts.PoolSize, opts.MinIdleConns, cfg)
+	}
+	if opts.PoolTimeout != cfg.Timeout || opts.DialTimeout != cfg.DialTimeout ||
+		opts.ReadTimeout != cfg.ReadTimeout || opts.WriteTimeout != cfg.WriteTimeout {
+		t.Fatalf("timeouts pool=%s dial=%s read=%s write=%s, want %+v", opts.PoolTimeout, opts.DialTimeout, opts.ReadTimeout, opts.WriteTimeout, cfg)
+	}
+	if opts.ConnMaxIdleTime != cfg.ConnMaxIdleTime || opts.ConnMaxLifetime != cfg.ConnMaxLifetime {
+		t.Fatalf("lifetimes idle=%s max=%s, want %+v", opts.ConnMaxIdleTime, opts.ConnMaxLifetime, cfg)
+	}
+}
+
+func TestNewClusterClientAppliesConfig(t *testing.T) {
+	cfg := customConfig("127.0.0.1:7000")
+	cfg.Mode = ModeCluster
+	cfg.Addresses = []string{"127.0.0.1:7000", "127.0.0.1:7001"}
+
+	client := newClusterClient(cfg)
+	t.Cleanup(func() { client.Close() })
+
+	opts := client.Options()
+	if len(opts.Addrs) != 2 || opts.PoolSize != cfg.PoolSize || opts.MinIdleConns != cfg.MinIdleConns {
+		t.Fatalf("options addrs=%v pool=%d min_idle=%d, want %+v", opts.Addrs, opts.PoolSize, opts.MinIdleConns, cfg)
+	}
+	if opts.PoolTimeout != cfg.Timeout || opts.DialTimeout != cfg.DialTimeout ||
+		opts.ReadTimeout != cfg.ReadTimeout || opts.WriteTimeout != cfg.WriteTimeout ||
+		opts.ConnMaxIdleTime != cfg.ConnMaxIdleTime || opts.ConnMaxLifetime != cfg.ConnMaxLifetime {
+		t.Fatalf("cluster options = %+v, want %+v", opts, cfg)
+	}
+}
+
+func TestConfigDefaultsWhenUnset(t *testing.T) {
+	cfg := RedisConfig{PoolSize: -1}.withDefaults()
+
+	want := RedisConfig{
+		PoolSize:        DefaultPoolSize,
+		MinIdleConns:    DefaultMinIdleConns,
+		Timeout:         DefaultTimeout,
+		DialTimeout:     DefaultDialTimeout,
+		ReadTimeout:     DefaultReadTimeout,
+		WriteTimeout:    DefaultWriteTimeout,
+		ConnMaxIdleTime: DefaultIdleTimeout,
+		ConnMaxLifetime: DefaultConnMaxLifetime,
+	}
+	if cfg.PoolSize != want.PoolSize || cfg.MinIdleConns != want.MinIdleConns || cfg.Timeout != want.Timeout ||
+		cfg.DialTimeout != want.DialTimeout || cfg.ReadTimeout != want.ReadTimeout || cfg.WriteTimeout != want.WriteTimeout ||
+		cfg.ConnMaxIdleTime != want.ConnMaxIdleTime || cfg.ConnMaxLifetime != want.ConnMaxLifetime {
+		t.Fatalf("withDefaults = %+v, want %+v", cfg, want)
+	}
+}
+
+// resetManager 清空 InitRedis 的初始化状态，测试结束后恢复原有的管理器
+func resetManager(t *testing.T) {
+	t.Helper()
+	prevManager, prevRedis := Manager, Redis
+	t.Cleanup(func() {
+		once = sync.Once{}
+		once.Do(func() {})
+		Manager, Redis = prevManager, prevRedis
+	})
+	once = sync.Once{}
+}
+
+func TestInitRedisReturnsDistinctClientPerInstance(t *testing.T) {
+	server := miniredis.RunT(t)
+	resetManager(t)
+
+	configs := map[RedisInstance]RedisConfig{
+		MainDB:  {Address: server.Addr(), DB: 1},
+		QueueDB: {Address: server.Addr(), DB: 2},
+		CacheDB: {Address: server.Addr(), DB: 3},
+	}
+	InitRedis(configs[MainDB], configs[QueueDB], configs[CacheDB])
+	t.Cleanup(func() {
+		for instance := range configs {
+			GetRedis(instance).Client.Close()
+		}
+	})
+
+	seen := map[*RedisClient]RedisInstance{}
+	for instance, cfg := range configs {
+		rds := GetRedis(instance)
+		if other, ok := seen[rds]; ok {
+			t.Fatalf("%s and %s share a client", instance, other)
+		}
+		seen[rds] = instance
+		if db := rds.Client.(*redis.Client).Options().DB; db != cfg.DB {
+			t.Fatalf("%s uses db %d, want %d", instance, db, cfg.DB)
+		}
+	}
+	if Cache() != GetRedis(CacheDB) || Redis != GetRedis(MainDB) {
+		t.Fatal("Cache or Redis does not point at its instance")
+	}
+
+	// 同名的 key 分别保存在各自的库中
+	for instance := range configs {
+		GetRedis(instance).Set("shared-key", string(instance), time.Minute)
+	}
+	for instance := range configs {
+		if got := GetRedis(instance).Get("shared-key"); got != string(instance) {
+			t.Fatalf("%s read %q, want its own value", instance, got)
+		}
+	}
+}
+
+func TestInitRedisClusterSharesOneClient(t *testing.T) {
+	server := miniredis.RunT(t)
+	resetManager(t)
+	cfg := RedisConfig{Mode: ModeCluster, Addresses: []string{server.Addr()}}
+	InitRedis(cfg, cfg, cfg)
+	t.Cleanup(func() { Redis.Client.Close() })
+
+	// 集群不支持选择数据库，队列与缓存共用主实例
+	if GetRedis(QueueDB) != Redis || Cache() != Redis {
+		t.Fatal("cluster mode created separate clients for queue or cache")
+	}
+}
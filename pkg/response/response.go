@@ -15,10 +15,29 @@ const (
 	Error   = "error"   // 错误状态
 )
 
+// 错误码，供客户端按错误类型处理，不随提示文案变化
+const (
+	CodeBadRequest          = "bad_request"
+	CodeUnauthorized        = "unauthorized"
+	CodeForbidden           = "forbidden"
+	CodeNotFound            = "not_found"
+	CodeValidationFailed    = "validation_failed"
+	CodeRateLimited         = "rate_limited"
+	CodeInternalError       = "internal_error"
+	CodeServiceUnavailable  = "service_unavailable"
+	CodeQueueFull           = "queue_full"
+	CodeInsufficientCredits = "insufficient_credits"
+	CodeGuestLimitReached   = "guest_limit_reached"
+	CodeContentBlocked      = "content_blocked"
+	CodePaymentRequired     = "payment_required"
+	CodeConflict            = "conflict"
+)
+
 /* 标准响应结构
 {
     "status": "success",
-    "data": {},     // 成功时返回的数据
+    "code": "",     // 错误时返回的错误码，例如 not_found
+    "data": {},     // 成功时返回的数据，部分错误附带详情
     "error": "",    // 错误时返回的信息
     "message": "",  // 提示信息
 }
@@ -27,6 +46,7 @@ const (
 // Response 统一响应结构体
 type Response struct {
 	Status  string      `json:"status"`
+	Code    string      `json:"code,omitempty"`
 	Data    interface{} `json:"data,omitempty"`
 	Error   string      `json:"error,omitempty"`
 	Message string      `json:"message,omitempty"`
@@ -42,49 +62,81 @@ func Data(c *gin.Context, data interface{}) {
 	})
 }
 
-// JSON 直接返回 JSON 数据
-func JSON(c *gin.Context, data interface{}) {
-	c.JSON(http.StatusOK, data)
-}
-
 // Created 成功创建的响应
 func Created(c *gin.Context, data interface{}, msg ...string) {
-	message := "创建成功"
-	if len(msg) > 0 {
-		message = msg[0]
+	c.JSON(http.StatusCreated, Response{
+		Status:  Success,
+		Data:    data,
+		Message: getMsg("创建成功", msg...),
+	})
+}
+
+// Accepted 已接受、将异步处理的响应，Location 指向查询处理结果的地址
+func Accepted(c *gin.Context, location string, data interface{}, msg ...string) {
+	if location != "" {
+		c.Header("Location", location)
 	}
-	
-	c.JSON(http.StatusCreated, gin.H{
-		"status":  "success",
-		"message": message,
-		"data":    data,
+	c.JSON(http.StatusAccepted, Response{
+		Status:  Success,
+		Data:    data,
+		Message: getMsg("已接受", msg...),
 	})
 }
 
 //  ------------------ 错误响应系列 ------------------
 
-// Abort400 响应 400 错误
-func Abort400(c *gin.Context, msg ...string) {
-	c.AbortWithStatusJSON(http.StatusBadRequest, Response{
+// Fail 以指定 HTTP 状态码和错误码中止请求
+func Fail(c *gin.Context, status int, code, msg string) {
+	c.AbortWithStatusJSON(status, Response{
 		Status:  Error,
-		Message: getMsg("请求参数错误", msg...),
+		Code:    code,
+		Message: msg,
 	})
 }
 
-// Abort404 响应 404 错误
-func Abort404(c *gin.Context, msg ...string) {
-	c.AbortWithStatusJSON(http.StatusNotFound, Response{
+// FailWithData 同 Fail，并附带错误详情
+func FailWithData(c *gin.Context, status int, code, msg string, data interface{}) {
+	c.AbortWithStatusJSON(status, Response{
 		Status:  Error,
-		Message: getMsg("资源不存在", msg...),
+		Code:    code,
+		Message: msg,
+		Data:    data,
 	})
 }
 
+// Abort400 响应 400 错误
+func Abort400(c *gin.Context, msg ...string) {
+	Fail(c, http.StatusBadRequest, CodeBadRequest, getMsg("请求参数错误", msg...))
+}
+
+// Abort401 响应 401 错误
+func Abort401(c *gin.Context, msg ...string) {
+	Fail(c, http.StatusUnauthorized, CodeUnauthorized, getMsg("请先登录", msg...))
+}
+
+// Abort403 响应 403 错误
+func Abort403(c *gin.Context, msg ...string) {
+	Fail(c, http.StatusForbidden, CodeForbidden, getMsg("无权访问该资源", msg...))
+}
+
+// Abort404 响应 404 错误
+func Abort404(c *gin.Context, msg ...string) {
+	Fail(c, http.StatusNotFound, CodeNotFound, getMsg("资源不存在", msg...))
+}
+
+// Abort429 响应 429 错误
+func Abort429(c *gin.Context, msg ...string) {
+	Fail(c, http.StatusTooManyRequests, CodeRateLimited, getMsg("请求太频繁，请稍后再试", msg...))
+}
+
 // Abort500 响应 500 错误
 func Abort500(c *gin.Context, msg ...string) {
-	c.AbortWithStatusJSON(http.StatusInternalServerError, Response{
-		Status:  Error,
-		Message: getMsg("服务器内部错误", msg...),
-	})
+	Fail(c, http.StatusInternalServerError, CodeInternalError, getMsg("服务器内部错误", msg...))
+}
+
+// Abort503 响应 503 错误
+func Abort503(c *gin.Context, msg ...string) {
+	Fail(c, http.StatusServiceUnavailable, CodeServiceUnavailable, getMsg("服务暂不可用", msg...))
 }
 
 // BadRequest 响应 400 错误（带错误信息）
@@ -92,6 +144,7 @@ func BadRequest(c *gin.Context, err error, msg ...string) {
 	logger.LogIf(err)
 	c.AbortWithStatusJSON(http.StatusBadRequest, Response{
 		Status:  Error,
+		Code:    CodeBadRequest,
 		Message: getMsg("请求格式错误", msg...),
 		Error:   err.Error(),
 	})
@@ -102,6 +155,7 @@ func ServerError(c *gin.Context, err error, msg ...string) {
 	logger.LogIf(err)
 	c.AbortWithStatusJSON(http.StatusInternalServerError, Response{
 		Status:  Error,
+		Code:    CodeInternalError,
 		Message: getMsg("服务器内部错误", msg...),
 		Error:   err.Error(),
 	})
@@ -109,11 +163,7 @@ func ServerError(c *gin.Context, err error, msg ...string) {
 
 // ValidationError 响应 422 表单验证错误
 func ValidationError(c *gin.Context, errors map[string][]string) {
-	c.AbortWithStatusJSON(http.StatusUnprocessableEntity, Response{
-		Status:  Error,
-		Message: "表单验证失败",
-		Data:    errors,
-	})
+	FailWithData(c, http.StatusUnprocessableEntity, CodeValidationFailed, "表单验证失败", errors)
 }
 
 // getMsg 获取消息内容
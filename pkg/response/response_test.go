@@ -0,0 +1,118 @@
+package response
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	_ "tarot/pkg/testutil" // 初始化空日志
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// respond 执行 handler 并返回状态码与解析后的响应体
+func respond(t *testing.T, handler func(c *gin.Context)) (*httptest.ResponseRecorder, map[string]interface{}) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+	handler(c)
+
+	var body map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode %q: %v", w.Body.String(), err)
+	}
+	return w, body
+}
+
+func TestSuccessEnvelope(t *testing.T) {
+	payload := map[string]interface{}{"task_id": "task-1"}
+
+	for _, tc := range []struct {
+		name    string
+		handler func(c *gin.Context)
+		status  int
+		message interface{}
+	}{
+		{"data", func(c *gin.Context) { Data(c, payload) }, http.StatusOK, nil},
+		{"created", func(c *gin.Context) { Created(c, payload) }, http.StatusCreated, "创建成功"},
+		{"accepted", func(c *gin.Context) { Accepted(c, "/v1/tarot/readings/task-1", payload) }, http.StatusAccepted, "已接受"},
+	} {
+		w, body := respond(t, tc.handler)
+		if w.Code != tc.status || body["status"] != Success || body["message"] != tc.message {
+			t.Fatalf("%s: status=%d body=%v", tc.name, w.Code, body)
+		}
+		if _, ok := body["code"]; ok {
+			t.Fatalf("%s: success response has code: %v", tc.name, body)
+		}
+		if data, _ := body["data"].(map[string]interface{}); data["task_id"] != "task-1" {
+			t.Fatalf("%s: data = %v", tc.name, body["data"])
+		}
+	}
+
+	w, _ := respond(t, func(c *gin.Context) { Accepted(c, "/v1/tarot/readings/task-1", payload) })
+	if w.Header().Get("Location") != "/v1/tarot/readings/task-1" {
+		t.Fatalf("Location = %q", w.Header().Get("Location"))
+	}
+}
+
+func TestErrorEnvelope(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		handler func(c *gin.Context)
+		status  int
+		code    string
+		message string
+	}{
+		{"400", func(c *gin.Context) { Abort400(c) }, http.StatusBadRequest, CodeBadRequest, "请求参数错误"},
+		{"401", func(c *gin.Context) { Abort401(c) }, http.StatusUnauthorized, CodeUnauthorized, "请先登录"},
+		{"403", func(c *gin.Context) { Abort403(c) }, http.StatusForbidden, CodeForbidden, "无权访问该资源"},
+		{"404", func(c *gin.Context) { Abort404(c, "任务不存在") }, http.StatusNotFound, CodeNotFound, "任务不存在"},
+		{"429", func(c *gin.Context) { Abort429(c) }, http.StatusTooManyRequests, CodeRateLimited, "请求太频繁，请稍后再试"},
+		{"500", func(c *gin.Context) { Abort500(c) }, http.StatusInternalServerError, CodeInternalError, "服务器内部错误"},
+		{"503", func(c *gin.Context) { Abort503(c) }, http.StatusServiceUnavailable, CodeServiceUnavailable, "服务暂不可用"},
+		{"queue full", func(c *gin.Context) { Fail(c, http.StatusServiceUnavailable, CodeQueueFull, "队列已满") }, http.StatusServiceUnavailable, CodeQueueFull, "队列已满"},
+		{"insufficient credits", func(c *gin.Context) { Fail(c, http.StatusPaymentRequired, CodeInsufficientCredits, "余额不足") }, http.StatusPaymentRequired, CodeInsufficientCredits, "余额不足"},
+		{"bad request error", func(c *gin.Context) { BadRequest(c, errors.New("invalid json")) }, http.StatusBadRequest, CodeBadRequest, "请求格式错误"},
+		{"server error", func(c *gin.Context) { ServerError(c, errors.New("db down")) }, http.StatusInternalServerError, CodeInternalError, "服务器内部错误"},
+	} {
+		w, body := respond(t, tc.handler)
+		if w.Code != tc.status || body["status"] != Error || body["code"] != tc.code || body["message"] != tc.message {
+			t.Fatalf("%s: status=%d body=%v, want %d %s %q", tc.name, w.Code, body, tc.status, tc.code, tc.message)
+		}
+		if _, ok := body["data"]; ok {
+			t.Fatalf("%s: error response has data: %v", tc.name, body)
+		}
+	}
+}
+
+func TestValidationErrorEnvelope(t *testing.T) {
+	w, body := respond(t, func(c *gin.Context) {
+		ValidationError(c, map[string][]string{"question": {"问题长度需要在 10 到 500 个字符之间"}})
+	})
+	if w.Code != http.StatusUnprocessableEntity || body["status"] != Error || body["code"] != CodeValidationFailed || body["message"] != "表单验证失败" {
+		t.Fatalf("status=%d body=%v", w.Code, body)
+	}
+	data, _ := body["data"].(map[string]interface{})
+	if fields, _ := data["question"].([]interface{}); len(fields) != 1 {
+		t.Fatalf("data = %v, want field errors", body["data"])
+	}
+}
+
+func TestErrorAbortsChain(t *testing.T) {
+	router := gin.New()
+	reached := false
+	router.GET("/", func(c *gin.Context) { Abort403(c) }, func(c *gin.Context) { reached = true })
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Code != http.StatusForbidden || reached {
+		t.Fatalf("status=%d reached=%t, want aborted 403", w.Code, reached)
+	}
+}
@@ -0,0 +1,59 @@
+// Package share 生成与校验解读分享链接的令牌
+// 令牌无状态：内容为解读记录 ID 与过期时间，使用 HMAC-SHA256 签名，不包含用户 ID
+package share
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidToken = errors.New("invalid share token")
+	ErrTokenExpired = errors.New("share token expired")
+)
+
+// Sign 为解读记录生成分享令牌，格式为 <记录ID>.<过期时间戳>.<签名>
+func Sign(secret string, readingID uint64, expiresAt time.Time) string {
+	payload := fmt.Sprintf("%d.%d", readingID, expiresAt.Unix())
+	return payload + "." + signature(secret, payload)
+}
+
+// Parse 校验令牌并返回解读记录 ID
+// 签名不匹配或格式错误时返回 ErrInvalidToken，已过期时返回 ErrTokenExpired
+func Parse(secret, token string, now time.Time) (uint64, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return 0, ErrInvalidToken
+	}
+
+	payload := parts[0] + "." + parts[1]
+	if !hmac.Equal([]byte(signature(secret, payload)), []byte(parts[2])) {
+		return 0, ErrInvalidToken
+	}
+
+	readingID, err := strconv.ParseUint(parts[0], 10, 64)
+	if err != nil {
+		return 0, ErrInvalidToken
+	}
+	expiresAt, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return 0, ErrInvalidToken
+	}
+	if now.Unix() >= expiresAt {
+		return 0, ErrTokenExpired
+	}
+
+	return readingID, nil
+}
+
+func signature(secret, payload string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
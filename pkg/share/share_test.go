@@ -0,0 +1,54 @@
+package share
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testSecret = "share-secret"
+
+func TestParseValidToken(t *testing.T) {
+	now := time.Now()
+	token := Sign(testSecret, 42, now.Add(time.Hour))
+
+	id, err := Parse(testSecret, token, now)
+	if err != nil || id != 42 {
+		t.Fatalf("Parse = %d, %v, want 42", id, err)
+	}
+}
+
+func TestParseExpiredToken(t *testing.T) {
+	now := time.Now()
+	token := Sign(testSecret, 42, now.Add(-time.Second))
+
+	if _, err := Parse(testSecret, token, now); !errors.Is(err, ErrTokenExpired) {
+		t.Fatalf("Parse error = %v, want ErrTokenExpired", err)
+	}
+	// 到期时刻即视为过期
+	expiresAt := now.Add(time.Hour)
+	if _, err := Parse(testSecret, Sign(testSecret, 42, expiresAt), expiresAt); !errors.Is(err, ErrTokenExpired) {
+		t.Fatalf("Parse at expiry error = %v, want ErrTokenExpired", err)
+	}
+}
+
+func TestParseTamperedToken(t *testing.T) {
+	now := time.Now()
+	expiresAt := now.Add(time.Hour)
+	token := Sign(testSecret, 42, expiresAt)
+	parts := strings.Split(token, ".")
+
+	for name, tampered := range map[string]string{
+		"reading id":   "43." + parts[1] + "." + parts[2],
+		"expiry":       parts[0] + "." + "99999999999." + parts[2],
+		"signature":    parts[0] + "." + parts[1] + "." + strings.Repeat("A", len(parts[2])),
+		"wrong secret": Sign("other-secret", 42, expiresAt),
+		"malformed":    parts[0] + "." + parts[2],
+		"empty":        "",
+	} {
+		if _, err := Parse(testSecret, tampered, now); !errors.Is(err, ErrInvalidToken) {
+			t.Fatalf("%s: Parse error = %v, want ErrInvalidToken", name, err)
+		}
+	}
+}
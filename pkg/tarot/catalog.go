@@ -0,0 +1,148 @@
+// Package tarot 塔罗牌目录数据
+package tarot
+
+import (
+	"fmt"
+	"strings"
+
+	"tarot/pkg/config"
+)
+
+// Arcana 牌组类型
+type Arcana string
+
+const (
+	ArcanaMajor Arcana = "major" // 大阿尔卡那
+	ArcanaMinor Arcana = "minor" // 小阿尔卡那
+)
+
+// TotalCards 一副塔罗牌的张数
+const TotalCards = 78
+
+// Card 卡牌目录项
+type Card struct {
+	ID       int    `json:"id"`
+	Name     string `json:"name"`
+	NameCN   string `json:"name_cn"`
+	Arcana   Arcana `json:"arcana"`
+	Suit     string `json:"suit,omitempty"`
+	ImageKey string `json:"image_key"`
+	ImageURL string `json:"image_url"`
+}
+
+var majorArcana = []struct{ name, nameCN string }{
+	{"The Fool", "愚者"}, {"The Magician", "魔术师"}, {"The High Priestess", "女祭司"},
+	{"The Empress", "皇后"}, {"The Emperor", "皇帝"}, {"The Hierophant", "教皇"},
+	{"The Lovers", "恋人"}, {"The Chariot", "战车"}, {"Strength", "力量"},
+	{"The Hermit", "隐士"}, {"Wheel of Fortune", "命运之轮"}, {"Justice", "正义"},
+	{"The Hanged Man", "倒吊人"}, {"Death", "死神"}, {"Temperance", "节制"},
+	{"The Devil", "恶魔"}, {"The Tower", "高塔"}, {"The Star", "星星"},
+	{"The Moon", "月亮"}, {"The Sun", "太阳"}, {"Judgement", "审判"},
+	{"The World", "世界"},
+}
+
+var suits = []struct{ key, name, nameCN string }{
+	{"wands", "Wands", "权杖"},
+	{"cups", "Cups", "圣杯"},
+	{"swords", "Swords", "宝剑"},
+	{"pentacles", "Pentacles", "星币"},
+}
+
+var ranks = []struct{ name, nameCN string }{
+	{"Ace", "王牌"}, {"Two", "二"}, {"Three", "三"}, {"Four", "四"}, {"Five", "五"},
+	{"Six", "六"}, {"Seven", "七"}, {"Eight", "八"}, {"Nine", "九"}, {"Ten", "十"},
+	{"Page", "侍从"}, {"Knight", "骑士"}, {"Queen", "皇后"}, {"King", "国王"},
+}
+
+// catalog 按卡牌编号（1-78）排列，编号与请求中的 cards 一致
+var catalog = buildCatalog()
+
+func buildCatalog() []Card {
+	cards := make([]Card, 0, TotalCards)
+
+	for i, m := range majorArcana {
+		cards = append(cards, Card{
+			ID:       len(cards) + 1,
+			Name:     m.name,
+			NameCN:   m.nameCN,
+			Arcana:   ArcanaMajor,
+			ImageKey: fmt.Sprintf("major/%02d.jpg", i),
+		})
+	}
+
+	for _, s := range suits {
+		for i, r := range ranks {
+			cards = append(cards, Card{
+				ID:       len(cards) + 1,
+				Name:     fmt.Sprintf("%s of %s", r.name, s.name),
+				NameCN:   s.nameCN + r.nameCN,
+				Arcana:   ArcanaMinor,
+				Suit:     s.key,
+				ImageKey: fmt.Sprintf("minor/%s/%02d.jpg", s.key, i+1),
+			})
+		}
+	}
+
+	if err := validateCatalog(cards); err != nil {
+		panic(err)
+	}
+	return cards
+}
+
+// validateCatalog 校验目录完整且每张牌都有唯一的图片 key
+func validateCatalog(cards []Card) error {
+	if len(cards) != TotalCards {
+		return fmt.Errorf("tarot catalog has %d cards, want %d", len(cards), TotalCards)
+	}
+	seen := make(map[string]int, len(cards))
+	for _, card := range cards {
+		if card.ImageKey == "" {
+			return fmt.Errorf("tarot card %d missing image key", card.ID)
+		}
+		if id, ok := seen[card.ImageKey]; ok {
+			return fmt.Errorf("tarot cards %d and %d share image key %s", id, card.ID, card.ImageKey)
+		}
+		seen[card.ImageKey] = card.ID
+	}
+	return nil
+}
+
+// ImageURL 拼接图片访问地址，未配置前缀时返回 key 本身
+func ImageURL(key string) string {
+	base := strings.TrimRight(config.GetString("tarot.card_image_base_url"), "/")
+	if base == "" {
+		return key
+	}
+	return base + "/" + key
+}
+
+// All 返回完整卡牌目录
+func All() []Card {
+	cards := make([]Card, len(catalog))
+	for i, card := range catalog {
+		card.ImageURL = ImageURL(card.ImageKey)
+		cards[i] = card
+	}
+	return cards
+}
+
+// Get 根据编号获取卡牌
+func Get(id int) (Card, bool) {
+	if id < 1 || id > len(catalog) {
+		return Card{}, false
+	}
+	card := catalog[id-1]
+	card.ImageURL = ImageURL(card.ImageKey)
+	return card, true
+}
+
+// Lookup 按顺序返回编号对应的卡牌，忽略无效编号
+func Lookup(ids []int) []Card {
+	cards := make([]Card, 0, len(ids))
+	for _, id := range ids {
+		if card, ok := Get(id); ok {
+			cards = append(cards, card)
+		}
+	}
+	return cards
+}
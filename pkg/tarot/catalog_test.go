@@ -0,0 +1,69 @@
+package tarot
+
+import (
+	"strings"
+	"testing"
+
+	"tarot/pkg/testutil"
+)
+
+func TestCatalogHasImageForEveryCard(t *testing.T) {
+	cards := All()
+	if len(cards) != TotalCards {
+		t.Fatalf("catalog has %d cards, want %d", len(cards), TotalCards)
+	}
+	for i, card := range cards {
+		if card.ID != i+1 {
+			t.Fatalf("card at %d has id %d", i, card.ID)
+		}
+		if card.ImageKey == "" || !strings.HasSuffix(card.ImageKey, ".jpg") {
+			t.Fatalf("card %d image key = %q", card.ID, card.ImageKey)
+		}
+	}
+	if err := validateCatalog(cards); err != nil {
+		t.Fatalf("validateCatalog: %v", err)
+	}
+}
+
+func TestImageURLUsesConfiguredPrefix(t *testing.T) {
+	testutil.SetConfig(t, "tarot.card_image_base_url", "https://cdn.example.com/cards/")
+
+	for _, card := range All() {
+		if want := "https://cdn.example.com/cards/" + card.ImageKey; card.ImageURL != want {
+			t.Fatalf("card %d image url = %q, want %q", card.ID, card.ImageURL, want)
+		}
+	}
+	fool, ok := Get(1)
+	if !ok || fool.ImageURL != "https://cdn.example.com/cards/major/00.jpg" {
+		t.Fatalf("Get(1) = %+v, %v", fool, ok)
+	}
+	if cards := Lookup([]int{78, 0, 79}); len(cards) != 1 || cards[0].ImageURL != "https://cdn.example.com/cards/minor/pentacles/14.jpg" {
+		t.Fatalf("Lookup = %+v, want only the King of Pentacles", cards)
+	}
+}
+
+func TestImageURLWithoutPrefix(t *testing.T) {
+	testutil.SetConfig(t, "tarot.card_image_base_url", "")
+
+	if card, _ := Get(23); card.ImageURL != card.ImageKey || card.ImageKey != "minor/wands/01.jpg" {
+		t.Fatalf("Get(23) = %+v, want unprefixed key", card)
+	}
+}
+
+func TestValidateCatalogRejectsMissingAndDuplicateKeys(t *testing.T) {
+	cards := All()
+	cards[5].ImageKey = ""
+	if err := validateCatalog(cards); err == nil {
+		t.Fatalf("missing image key accepted")
+	}
+
+	cards = All()
+	cards[5].ImageKey = cards[4].ImageKey
+	if err := validateCatalog(cards); err == nil {
+		t.Fatalf("duplicate image key accepted")
+	}
+
+	if err := validateCatalog(All()[:77]); err == nil {
+		t.Fatalf("incomplete catalog accepted")
+	}
+}
@@ -0,0 +1,82 @@
+package tarot
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+
+	"tarot/pkg/config"
+	"tarot/pkg/helpers"
+	"tarot/pkg/redis"
+)
+
+var (
+	// ErrDrawNotFound 抽牌记录不存在、已过期或已被使用
+	ErrDrawNotFound = errors.New("draw not found")
+	// ErrDrawMismatch 提交的卡牌与服务端抽出的卡牌不一致
+	ErrDrawMismatch = errors.New("cards do not match draw")
+)
+
+// Draw 服务端抽牌结果，保存在缓存 Redis 中，创建解读时校验并消费
+type Draw struct {
+	ID        string    `json:"draw_id"`
+	UserID    string    `json:"user_id"`
+	Cards     []int     `json:"cards"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// DrawCards 从整副牌中不重复地抽取 n 张，按抽出的顺序返回卡牌编号
+func DrawCards(random helpers.RandomSource, n int) []int {
+	deck := make([]int, TotalCards)
+	for i := range deck {
+		deck[i] = i + 1
+	}
+	// 只需打乱前 n 个位置
+	for i := 0; i < n; i++ {
+		j := i + random.Intn(TotalCards-i)
+		deck[i], deck[j] = deck[j], deck[i]
+	}
+	return deck[:n]
+}
+
+// Matches 提交的卡牌（含顺序）是否与抽牌结果一致
+func (d *Draw) Matches(cards []int) bool {
+	return slices.Equal(d.Cards, cards)
+}
+
+// SaveDraw 保存抽牌结果，ttl 后自动过期
+func SaveDraw(ctx context.Context, d *Draw, ttl time.Duration) error {
+	data, err := json.Marshal(d)
+	if err != nil {
+		return err
+	}
+	return redis.Cache().Client.Set(ctx, drawKey(d.UserID, d.ID), data, ttl).Err()
+}
+
+// TakeDraw 取出并删除用户的抽牌结果，同一次抽牌只能用于一次解读
+// 不存在或已过期时返回 ErrDrawNotFound
+func TakeDraw(ctx context.Context, userID, drawID string) (*Draw, error) {
+	data, err := redis.Cache().Client.GetDel(ctx, drawKey(userID, drawID)).Bytes()
+	if errors.Is(err, goredis.Nil) {
+		return nil, ErrDrawNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var d Draw
+	if err := json.Unmarshal(data, &d); err != nil {
+		return nil, fmt.Errorf("解析抽牌记录失败: %w", err)
+	}
+	return &d, nil
+}
+
+// drawKey 抽牌记录的 key，包含用户 ID，其他用户无法引用
+func drawKey(userID, drawID string) string {
+	return config.GetString("app.name") + ":draw:" + userID + ":" + drawID
+}
@@ -0,0 +1,80 @@
+package tarot
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"slices"
+	"testing"
+	"time"
+
+	"tarot/pkg/testutil"
+)
+
+func TestDrawCardsAreDistinctAndDeterministic(t *testing.T) {
+	cards := DrawCards(rand.New(rand.NewSource(1)), TotalCards)
+	sorted := slices.Clone(cards)
+	slices.Sort(sorted)
+	for i, id := range sorted {
+		if id != i+1 {
+			t.Fatalf("drawing the whole deck gave %v, want every card once", cards)
+		}
+	}
+
+	first := DrawCards(rand.New(rand.NewSource(42)), 3)
+	if second := DrawCards(rand.New(rand.NewSource(42)), 3); !slices.Equal(first, second) {
+		t.Fatalf("draws with the same seed = %v and %v", first, second)
+	}
+}
+
+func TestDrawMatchesCardsInOrder(t *testing.T) {
+	d := &Draw{Cards: []int{5, 12, 30}}
+	for _, tc := range []struct {
+		cards []int
+		want  bool
+	}{
+		{[]int{5, 12, 30}, true},
+		{[]int{30, 12, 5}, false},
+		{[]int{5, 12}, false},
+		{[]int{5, 12, 31}, false},
+	} {
+		if got := d.Matches(tc.cards); got != tc.want {
+			t.Errorf("Matches(%v) = %v, want %v", tc.cards, got, tc.want)
+		}
+	}
+}
+
+func TestTakeDrawConsumesOnce(t *testing.T) {
+	testutil.SetupRedis(t)
+	ctx := context.Background()
+	d := &Draw{ID: "draw-1", UserID: "user-1", Cards: []int{1, 2, 3}}
+	if err := SaveDraw(ctx, d, time.Minute); err != nil {
+		t.Fatalf("SaveDraw: %v", err)
+	}
+
+	// 其他用户不能引用该次抽牌
+	if _, err := TakeDraw(ctx, "user-2", d.ID); !errors.Is(err, ErrDrawNotFound) {
+		t.Fatalf("TakeDraw by another user = %v, want ErrDrawNotFound", err)
+	}
+	got, err := TakeDraw(ctx, "user-1", d.ID)
+	if err != nil || !got.Matches(d.Cards) {
+		t.Fatalf("TakeDraw = %+v, %v, want the saved draw", got, err)
+	}
+	if _, err := TakeDraw(ctx, "user-1", d.ID); !errors.Is(err, ErrDrawNotFound) {
+		t.Fatalf("second TakeDraw = %v, want ErrDrawNotFound", err)
+	}
+}
+
+func TestTakeDrawAfterExpiry(t *testing.T) {
+	server := testutil.SetupRedis(t)
+	ctx := context.Background()
+	d := &Draw{ID: "draw-1", UserID: "user-1", Cards: []int{1, 2, 3}}
+	if err := SaveDraw(ctx, d, time.Minute); err != nil {
+		t.Fatalf("SaveDraw: %v", err)
+	}
+
+	server.FastForward(time.Minute + time.Second)
+	if _, err := TakeDraw(ctx, "user-1", d.ID); !errors.Is(err, ErrDrawNotFound) {
+		t.Fatalf("TakeDraw after expiry = %v, want ErrDrawNotFound", err)
+	}
+}
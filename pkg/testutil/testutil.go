@@ -0,0 +1,107 @@
+// Package testutil 测试辅助：内存 SQLite 数据库、miniredis 与配置覆盖，仅供 _test.go 使用
+package testutil
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+
+	"tarot/pkg/config"
+	"tarot/pkg/database"
+	"tarot/pkg/logger"
+	"tarot/pkg/redis"
+)
+
+var (
+	dbSeq atomic.Int64
+
+	redisOnce   sync.Once
+	redisServer *miniredis.Miniredis
+)
+
+func init() {
+	// 测试中不输出业务日志，也避免未初始化的 Logger 导致空指针
+	if logger.Logger == nil {
+		logger.Logger = zap.NewNop()
+	}
+}
+
+// SetupDB 使用独立的内存 SQLite 替换 database.DB，并迁移 models
+// 只保留一个连接，并发访问在连接上排队，避免 SQLite 的表锁错误
+func SetupDB(t testing.TB, models ...interface{}) *gorm.DB {
+	t.Helper()
+
+	dsn := fmt.Sprintf("file:testdb%d?mode=memory&cache=shared", dbSeq.Add(1))
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: gormlogger.Discard})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("sqlite handle: %v", err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+
+	if len(models) > 0 {
+		if err := db.AutoMigrate(models...); err != nil {
+			t.Fatalf("migrate: %v", err)
+		}
+	}
+
+	prevDB, prevSQL := database.DB, database.SQLDB
+	database.DB, database.SQLDB = db, sqlDB
+	t.Cleanup(func() {
+		database.DB, database.SQLDB = prevDB, prevSQL
+		sqlDB.Close()
+	})
+	return db
+}
+
+// SetupRedis 初始化指向 miniredis 的 Redis 管理器，并清空上一个测试留下的数据
+// 管理器只能初始化一次，同一测试进程内的测试共用一个 miniredis
+func SetupRedis(t testing.TB) *miniredis.Miniredis {
+	t.Helper()
+
+	redisOnce.Do(func() {
+		redisServer = miniredis.NewMiniRedis()
+		if err := redisServer.Start(); err != nil {
+			panic(fmt.Sprintf("start miniredis: %v", err))
+		}
+		cfg := redis.RedisConfig{Address: redisServer.Addr(), PoolSize: 10, MinIdleConns: 1}
+		redis.InitRedis(cfg, cfg, cfg)
+	})
+	redisServer.FlushAll()
+	return redisServer
+}
+
+// SetConfig 在测试期间覆盖配置项，测试结束后恢复原值
+func SetConfig(t testing.TB, path string, value interface{}) {
+	t.Helper()
+
+	prev := config.Get(path)
+	config.Set(path, value)
+	t.Cleanup(func() {
+		config.Set(path, prev)
+	})
+}
+
+// ObserveLogs 在测试期间将 logger.Logger 替换为记录日志的观察者，返回记录的日志
+func ObserveLogs(t testing.TB) *observer.ObservedLogs {
+	t.Helper()
+
+	core, logs := observer.New(zap.DebugLevel)
+	prev := logger.Logger
+	logger.Logger = zap.New(core)
+	t.Cleanup(func() {
+		logger.Logger = prev
+	})
+	return logs
+}
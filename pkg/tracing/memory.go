@@ -0,0 +1,51 @@
+package tracing
+
+import (
+	"context"
+	"sync"
+)
+
+// MemoryExporter 将 span 保存在内存中，用于测试与本地调试
+type MemoryExporter struct {
+	mu    sync.Mutex
+	spans []*Span
+}
+
+// Export 保存一批 span
+func (e *MemoryExporter) Export(ctx context.Context, spans []*Span) error {
+	e.mu.Lock()
+	e.spans = append(e.spans, spans...)
+	e.mu.Unlock()
+	return nil
+}
+
+// Spans 返回已导出的 span
+func (e *MemoryExporter) Spans() []*Span {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]*Span(nil), e.spans...)
+}
+
+// Name 返回 span 名称
+func (s *Span) Name() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.name
+}
+
+// Attribute 返回属性值，不存在时为 nil
+func (s *Span) Attribute(key string) interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.attributes[key]
+}
+
+// SpanContext 返回 span 的标识
+func (s *Span) SpanContext() SpanContext {
+	return s.sc
+}
+
+// Parent 返回父 span ID，根 span 为全零
+func (s *Span) Parent() SpanID {
+	return s.parent
+}
@@ -0,0 +1,115 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// OTLPExporter 以 OTLP/HTTP JSON 格式导出 span
+type OTLPExporter struct {
+	client      *resty.Client
+	url         string
+	serviceName string
+}
+
+// NewOTLPExporter 创建 OTLP 导出器，endpoint 为 Collector 地址（不含 /v1/traces）
+func NewOTLPExporter(endpoint, serviceName string) *OTLPExporter {
+	return &OTLPExporter{
+		client:      resty.New().SetTimeout(10 * time.Second),
+		url:         strings.TrimRight(endpoint, "/") + "/v1/traces",
+		serviceName: serviceName,
+	}
+}
+
+// Export 导出一批 span
+func (e *OTLPExporter) Export(ctx context.Context, spans []*Span) error {
+	resp, err := e.client.R().
+		SetContext(ctx).
+		SetHeader("Content-Type", "application/json").
+		SetBody(e.payload(spans)).
+		Post(e.url)
+	if err != nil {
+		return fmt.Errorf("export spans error: %w", err)
+	}
+	if resp.IsError() {
+		return fmt.Errorf("export spans error: status %d, body: %s", resp.StatusCode(), resp.String())
+	}
+	return nil
+}
+
+// payload 构建 ExportTraceServiceRequest
+func (e *OTLPExporter) payload(spans []*Span) map[string]interface{} {
+	otlpSpans := make([]map[string]interface{}, 0, len(spans))
+	for _, s := range spans {
+		otlpSpans = append(otlpSpans, otlpSpan(s))
+	}
+
+	return map[string]interface{}{
+		"resourceSpans": []interface{}{
+			map[string]interface{}{
+				"resource": map[string]interface{}{
+					"attributes": []interface{}{attribute("service.name", e.serviceName)},
+				},
+				"scopeSpans": []interface{}{
+					map[string]interface{}{
+						"scope": map[string]interface{}{"name": "tarot/pkg/tracing"},
+						"spans": otlpSpans,
+					},
+				},
+			},
+		},
+	}
+}
+
+func otlpSpan(s *Span) map[string]interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	attrs := make([]interface{}, 0, len(s.attributes))
+	for k, v := range s.attributes {
+		attrs = append(attrs, attribute(k, v))
+	}
+
+	status := map[string]interface{}{"code": 1}
+	if s.err != nil {
+		status = map[string]interface{}{"code": 2, "message": s.err.Error()}
+	}
+
+	span := map[string]interface{}{
+		"traceId":           s.sc.TraceID.String(),
+		"spanId":            s.sc.SpanID.String(),
+		"name":              s.name,
+		"kind":              int(s.kind),
+		"startTimeUnixNano": strconv.FormatInt(s.start.UnixNano(), 10),
+		"endTimeUnixNano":   strconv.FormatInt(s.end.UnixNano(), 10),
+		"attributes":        attrs,
+		"status":            status,
+	}
+	if s.parent.IsValid() {
+		span["parentSpanId"] = s.parent.String()
+	}
+	return span
+}
+
+// attribute 转换为 OTLP KeyValue
+func attribute(key string, value interface{}) map[string]interface{} {
+	var v map[string]interface{}
+	switch val := value.(type) {
+	case bool:
+		v = map[string]interface{}{"boolValue": val}
+	case int:
+		v = map[string]interface{}{"intValue": strconv.Itoa(val)}
+	case int64:
+		v = map[string]interface{}{"intValue": strconv.FormatInt(val, 10)}
+	case float64:
+		v = map[string]interface{}{"doubleValue": val}
+	default:
+		v = map[string]interface{}{"stringValue": fmt.Sprint(val)}
+	}
+	return map[string]interface{}{"key": key, "value": v}
+}
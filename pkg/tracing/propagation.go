@@ -0,0 +1,53 @@
+package tracing
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// TraceparentHeader W3C Trace Context 请求头
+const TraceparentHeader = "traceparent"
+
+// Inject 将 ctx 中的 span 编码为 traceparent，无 span 时返回空字符串
+// 格式：00-<trace-id>-<span-id>-<flags>
+func Inject(ctx context.Context) string {
+	sc := SpanContextFrom(ctx)
+	if !sc.IsValid() {
+		return ""
+	}
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return fmt.Sprintf("00-%s-%s-%s", sc.TraceID, sc.SpanID, flags)
+}
+
+// Extract 解析 traceparent，格式不合法时返回无效的 SpanContext
+func Extract(traceparent string) SpanContext {
+	parts := strings.Split(strings.TrimSpace(traceparent), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" ||
+		len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return SpanContext{}
+	}
+
+	var sc SpanContext
+	if _, err := hex.Decode(sc.TraceID[:], []byte(parts[1])); err != nil {
+		return SpanContext{}
+	}
+	if _, err := hex.Decode(sc.SpanID[:], []byte(parts[2])); err != nil {
+		return SpanContext{}
+	}
+	flags, err := hex.DecodeString(parts[3])
+	if err != nil {
+		return SpanContext{}
+	}
+	sc.Sampled = flags[0]&0x01 == 0x01
+	return sc
+}
+
+// ContextFromTraceparent 解析 traceparent 并作为远程父 span 写入 ctx
+func ContextFromTraceparent(ctx context.Context, traceparent string) context.Context {
+	return ContextWithRemote(ctx, Extract(traceparent))
+}
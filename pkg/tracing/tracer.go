@@ -0,0 +1,160 @@
+package tracing
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"tarot/pkg/logger"
+)
+
+// Config 追踪配置
+type Config struct {
+	Enabled       bool
+	Endpoint      string  // OTLP/HTTP 地址，例如 http://otel-collector:4318
+	ServiceName   string  // 上报的 service.name
+	SampleRatio   float64 // 新链路的采样率（0~1），延续的链路沿用上游的采样决定
+	BatchSize     int     // 单次导出的最大 span 数
+	QueueSize     int     // 待导出队列长度，队列满时丢弃新的 span
+	FlushInterval time.Duration
+}
+
+// Exporter span 导出器
+type Exporter interface {
+	Export(ctx context.Context, spans []*Span) error
+}
+
+// tracer 批量导出 span
+type tracer struct {
+	cfg      Config
+	exporter Exporter
+	queue    chan *Span
+	stop     chan struct{}
+	wg       sync.WaitGroup
+}
+
+var global atomic.Pointer[tracer]
+
+// current 返回当前启用的 tracer，未启用时为 nil
+func current() *tracer {
+	return global.Load()
+}
+
+// Enabled 是否已启用追踪
+func Enabled() bool {
+	return current() != nil
+}
+
+// Setup 启用追踪，exporter 为空时使用 OTLP/HTTP 导出器
+// cfg.Enabled 为 false 时不做任何事，所有追踪操作保持空操作
+func Setup(cfg Config, exporter Exporter) {
+	if !cfg.Enabled {
+		return
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 512
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = 2048
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = 5 * time.Second
+	}
+	if exporter == nil {
+		exporter = NewOTLPExporter(cfg.Endpoint, cfg.ServiceName)
+	}
+
+	t := &tracer{
+		cfg:      cfg,
+		exporter: exporter,
+		queue:    make(chan *Span, cfg.QueueSize),
+		stop:     make(chan struct{}),
+	}
+	t.wg.Add(1)
+	go t.run()
+
+	if old := global.Swap(t); old != nil {
+		old.shutdown(context.Background())
+	}
+}
+
+// Shutdown 停止追踪并导出剩余的 span
+func Shutdown(ctx context.Context) {
+	if t := global.Swap(nil); t != nil {
+		t.shutdown(ctx)
+	}
+}
+
+// sample 按采样率决定新链路是否采样
+func (t *tracer) sample() bool {
+	if t.cfg.SampleRatio >= 1 {
+		return true
+	}
+	return rand.Float64() < t.cfg.SampleRatio
+}
+
+// enqueue 提交已结束的 span，队列满时丢弃，不阻塞业务
+func (t *tracer) enqueue(s *Span) {
+	select {
+	case t.queue <- s:
+	default:
+	}
+}
+
+// run 按批次或间隔导出 span
+func (t *tracer) run() {
+	defer t.wg.Done()
+
+	ticker := time.NewTicker(t.cfg.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]*Span, 0, t.cfg.BatchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		if err := t.exporter.Export(ctx, batch); err != nil {
+			logger.WarnString("Tracing", "Export", err.Error())
+		}
+		cancel()
+		batch = make([]*Span, 0, t.cfg.BatchSize)
+	}
+
+	for {
+		select {
+		case s := <-t.queue:
+			batch = append(batch, s)
+			if len(batch) >= t.cfg.BatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-t.stop:
+			for {
+				select {
+				case s := <-t.queue:
+					batch = append(batch, s)
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+func (t *tracer) shutdown(ctx context.Context) {
+	close(t.stop)
+	done := make(chan struct{})
+	go func() {
+		t.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+}
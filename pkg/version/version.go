@@ -0,0 +1,41 @@
+// Package version 构建版本信息，通过 -ldflags 在编译时注入
+//
+//	go build -ldflags "-X tarot/pkg/version.Version=v1.2.0 \
+//	  -X tarot/pkg/version.Commit=$(git rev-parse --short HEAD) \
+//	  -X tarot/pkg/version.BuildTime=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+package version
+
+import (
+	"runtime"
+	"time"
+)
+
+// 编译时注入的变量，未注入时为 dev
+var (
+	Version   = "dev"
+	Commit    = "dev"
+	BuildTime = "dev"
+)
+
+// startTime 进程启动时间，用于计算运行时长
+var startTime = time.Now()
+
+// Info 版本信息
+type Info struct {
+	Version   string  `json:"version"`
+	Commit    string  `json:"commit"`
+	BuildTime string  `json:"build_time"`
+	GoVersion string  `json:"go_version"`
+	Uptime    float64 `json:"uptime_seconds"`
+}
+
+// Get 返回当前构建的版本信息
+func Get() Info {
+	return Info{
+		Version:   Version,
+		Commit:    Commit,
+		BuildTime: BuildTime,
+		GoVersion: runtime.Version(),
+		Uptime:    time.Since(startTime).Seconds(),
+	}
+}
@@ -0,0 +1,236 @@
+// Package webhook 解读完成回调：签名、带退避的重试与投递记录
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+
+	"tarot/pkg/logger"
+)
+
+// 回调请求头
+const (
+	SignatureHeader = "X-Tarot-Signature" // sha256=<hex(HMAC-SHA256(secret, timestamp + "." + body))>
+	TimestampHeader = "X-Tarot-Timestamp" // unix 秒
+)
+
+// 回调事件
+const (
+	EventCompleted = "reading.completed"
+	EventFailed    = "reading.failed"
+)
+
+var (
+	// ErrInvalidURL 回调地址不合法
+	ErrInvalidURL = errors.New("invalid callback url")
+	// ErrPrivateAddress 回调地址指向内网
+	ErrPrivateAddress = errors.New("callback url resolves to a private address")
+)
+
+// Payload 回调内容
+type Payload struct {
+	Event     string `json:"event"`
+	TaskID    string `json:"task_id"`
+	Status    string `json:"status"`
+	Result    string `json:"result,omitempty"`
+	Error     string `json:"error,omitempty"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+// Attempt 单次投递记录
+type Attempt struct {
+	TaskID     string
+	URL        string
+	Event      string
+	Attempt    int
+	StatusCode int
+	Error      string
+	Success    bool
+	Duration   time.Duration
+}
+
+// Recorder 持久化投递记录
+type Recorder interface {
+	RecordAttempt(ctx context.Context, attempt *Attempt) error
+}
+
+// Config 回调配置
+type Config struct {
+	Secret         string
+	MaxAttempts    int
+	InitialBackoff time.Duration // 首次重试间隔，之后每次翻倍
+	MaxBackoff     time.Duration
+	Timeout        time.Duration // 单次请求超时
+	AllowPrivate   bool          // 是否允许回调内网地址，仅用于本地开发
+}
+
+// Notifier 回调投递器
+type Notifier struct {
+	cfg      Config
+	client   *resty.Client
+	recorder Recorder
+}
+
+// NewNotifier 创建回调投递器，recorder 可为 nil
+func NewNotifier(cfg Config, recorder Recorder) *Notifier {
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 5
+	}
+	if cfg.InitialBackoff <= 0 {
+		cfg.InitialBackoff = time.Second
+	}
+	if cfg.MaxBackoff < cfg.InitialBackoff {
+		cfg.MaxBackoff = time.Minute
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+
+	dialer := &net.Dialer{Timeout: cfg.Timeout}
+	if !cfg.AllowPrivate {
+		// 在建立连接时检查实际解析到的 IP，避免 DNS 指向内网绕过校验
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip != nil && isPrivateIP(ip) {
+				return ErrPrivateAddress
+			}
+			return nil
+		}
+	}
+
+	client := resty.New().
+		SetTimeout(cfg.Timeout).
+		SetTransport(&http.Transport{DialContext: dialer.DialContext}).
+		SetRedirectPolicy(resty.NoRedirectPolicy())
+
+	return &Notifier{cfg: cfg, client: client, recorder: recorder}
+}
+
+// ValidateURL 校验回调地址：必须为 http(s) 的绝对地址
+func ValidateURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return ErrInvalidURL
+	}
+	return nil
+}
+
+// Sign 计算回调签名
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify 校验回调签名，供接收方使用
+func Verify(secret string, timestamp int64, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, timestamp, body)), []byte(signature))
+}
+
+// Deliver 投递回调，失败时按指数退避重试，直到成功、达到最大次数或 ctx 取消
+// 2xx 视为成功；4xx（408、429 除外）说明接收方拒绝，不再重试
+func (n *Notifier) Deliver(ctx context.Context, callbackURL string, payload Payload) error {
+	if err := ValidateURL(callbackURL); err != nil {
+		return err
+	}
+	payload.Timestamp = time.Now().Unix()
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("marshal webhook payload error: %w", err)
+	}
+
+	backoff := n.cfg.InitialBackoff
+	var lastErr error
+	for attempt := 1; attempt <= n.cfg.MaxAttempts; attempt++ {
+		if attempt > 1 {
+			select {
+			case <-ctx.Done():
+				return fmt.Errorf("webhook delivery cancelled: %w", ctx.Err())
+			case <-time.After(backoff):
+			}
+			if backoff *= 2; backoff > n.cfg.MaxBackoff {
+				backoff = n.cfg.MaxBackoff
+			}
+		}
+
+		statusCode, err := n.send(ctx, callbackURL, body, payload.Timestamp, payload, attempt)
+		if err == nil {
+			return nil
+		}
+		lastErr = err
+		if !retryable(statusCode) {
+			break
+		}
+	}
+	return fmt.Errorf("webhook delivery failed: %w", lastErr)
+}
+
+// send 发送一次回调并记录结果
+func (n *Notifier) send(ctx context.Context, callbackURL string, body []byte, timestamp int64, payload Payload, attempt int) (int, error) {
+	start := time.Now()
+	resp, err := n.client.R().
+		SetContext(ctx).
+		SetHeader("Content-Type", "application/json").
+		SetHeader(TimestampHeader, strconv.FormatInt(timestamp, 10)).
+		SetHeader(SignatureHeader, Sign(n.cfg.Secret, timestamp, body)).
+		SetBody(body).
+		Post(callbackURL)
+
+	record := &Attempt{
+		TaskID:   payload.TaskID,
+		URL:      callbackURL,
+		Event:    payload.Event,
+		Attempt:  attempt,
+		Duration: time.Since(start),
+	}
+	if err == nil {
+		record.StatusCode = resp.StatusCode()
+		if !resp.IsSuccess() {
+			err = fmt.Errorf("callback returned status %d", resp.StatusCode())
+		}
+	}
+	if err != nil {
+		record.Error = err.Error()
+	} else {
+		record.Success = true
+	}
+
+	if n.recorder != nil {
+		if recErr := n.recorder.RecordAttempt(ctx, record); recErr != nil {
+			logger.WarnString("Webhook", "Record", recErr.Error())
+		}
+	}
+	return record.StatusCode, err
+}
+
+// retryable 网络错误与 5xx、408、429 可重试
+func retryable(statusCode int) bool {
+	if statusCode == 0 || statusCode >= 500 {
+		return true
+	}
+	return statusCode == http.StatusRequestTimeout || statusCode == http.StatusTooManyRequests
+}
+
+// isPrivateIP 回环、内网、链路本地与未指定地址
+func isPrivateIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsUnspecified()
+}
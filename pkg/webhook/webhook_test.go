@@ -0,0 +1,163 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	_ "tarot/pkg/testutil" // 初始化空日志
+)
+
+const testSecret = "whsec_test"
+
+// memoryRecorder 在内存中保存投递记录
+type memoryRecorder struct {
+	mu       sync.Mutex
+	attempts []Attempt
+}
+
+func (r *memoryRecorder) RecordAttempt(ctx context.Context, attempt *Attempt) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.attempts = append(r.attempts, *attempt)
+	return nil
+}
+
+// received 接收方收到的一次回调
+type received struct {
+	header http.Header
+	body   []byte
+}
+
+// newReceiver 创建回调接收方，按 statuses 依次应答，用完后返回 200
+func newReceiver(t *testing.T, statuses ...int) (*httptest.Server, *[]received) {
+	t.Helper()
+	var mu sync.Mutex
+	calls := []received{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		calls = append(calls, received{header: r.Header.Clone(), body: body})
+		n := len(calls)
+		mu.Unlock()
+
+		if n <= len(statuses) {
+			w.WriteHeader(statuses[n-1])
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(server.Close)
+	return server, &calls
+}
+
+// newTestNotifier 测试接收方监听在回环地址，需要允许内网地址
+func newTestNotifier(recorder Recorder) *Notifier {
+	return NewNotifier(Config{
+		Secret:         testSecret,
+		MaxAttempts:    3,
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     5 * time.Millisecond,
+		Timeout:        time.Second,
+		AllowPrivate:   true,
+	}, recorder)
+}
+
+func TestDeliverSignedPayload(t *testing.T) {
+	server, calls := newReceiver(t)
+	recorder := &memoryRecorder{}
+
+	err := newTestNotifier(recorder).Deliver(context.Background(), server.URL+"/hook", Payload{
+		Event: EventCompleted, TaskID: "task-1", Status: "completed", Result: "解读结果",
+	})
+	if err != nil {
+		t.Fatalf("Deliver: %v", err)
+	}
+	if len(*calls) != 1 {
+		t.Fatalf("receiver got %d calls, want 1", len(*calls))
+	}
+
+	call := (*calls)[0]
+	timestamp, err := strconv.ParseInt(call.header.Get(TimestampHeader), 10, 64)
+	if err != nil {
+		t.Fatalf("timestamp header %q: %v", call.header.Get(TimestampHeader), err)
+	}
+	if !Verify(testSecret, timestamp, call.body, call.header.Get(SignatureHeader)) {
+		t.Fatalf("signature %q does not verify", call.header.Get(SignatureHeader))
+	}
+
+	var payload Payload
+	if err := json.Unmarshal(call.body, &payload); err != nil {
+		t.Fatalf("decode payload: %v", err)
+	}
+	if payload.TaskID != "task-1" || payload.Result != "解读结果" || payload.Timestamp != timestamp {
+		t.Fatalf("payload = %+v", payload)
+	}
+
+	if len(recorder.attempts) != 1 || !recorder.attempts[0].Success || recorder.attempts[0].StatusCode != http.StatusOK {
+		t.Fatalf("recorded attempts = %+v, want one successful attempt", recorder.attempts)
+	}
+}
+
+func TestVerifyRejectsTampering(t *testing.T) {
+	body := []byte(`{"task_id":"task-1","status":"completed"}`)
+	signature := Sign(testSecret, 1700000000, body)
+
+	if !Verify(testSecret, 1700000000, body, signature) {
+		t.Fatal("valid signature rejected")
+	}
+	for name, ok := range map[string]bool{
+		"wrong secret": Verify("other", 1700000000, body, signature),
+		"timestamp":    Verify(testSecret, 1700000001, body, signature),
+		"body":         Verify(testSecret, 1700000000, []byte(`{"task_id":"task-2","status":"completed"}`), signature),
+	} {
+		if ok {
+			t.Fatalf("%s: tampered signature accepted", name)
+		}
+	}
+}
+
+func TestDeliverRetriesServerErrors(t *testing.T) {
+	server, calls := newReceiver(t, http.StatusServiceUnavailable, http.StatusInternalServerError)
+	recorder := &memoryRecorder{}
+
+	if err := newTestNotifier(recorder).Deliver(context.Background(), server.URL, Payload{Event: EventFailed, TaskID: "task-1"}); err != nil {
+		t.Fatalf("Deliver: %v", err)
+	}
+	if len(*calls) != 3 || len(recorder.attempts) != 3 || !recorder.attempts[2].Success || recorder.attempts[0].StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("calls = %d, attempts = %+v, want success on the third attempt", len(*calls), recorder.attempts)
+	}
+}
+
+func TestDeliverDoesNotRetryClientErrors(t *testing.T) {
+	server, calls := newReceiver(t, http.StatusBadRequest)
+
+	if err := newTestNotifier(nil).Deliver(context.Background(), server.URL, Payload{TaskID: "task-1"}); err == nil {
+		t.Fatal("Deliver succeeded on 400")
+	}
+	if len(*calls) != 1 {
+		t.Fatalf("calls = %d, want 1", len(*calls))
+	}
+}
+
+func TestDeliverRejectsPrivateAddress(t *testing.T) {
+	server, calls := newReceiver(t)
+	n := NewNotifier(Config{Secret: testSecret, MaxAttempts: 1, Timeout: time.Second}, nil)
+
+	if err := n.Deliver(context.Background(), server.URL, Payload{TaskID: "task-1"}); !errors.Is(err, ErrPrivateAddress) {
+		t.Fatalf("Deliver error = %v, want ErrPrivateAddress", err)
+	}
+	if len(*calls) != 0 {
+		t.Fatalf("private receiver got %d calls", len(*calls))
+	}
+	if err := n.Deliver(context.Background(), "ftp://example.com/hook", Payload{}); !errors.Is(err, ErrInvalidURL) {
+		t.Fatalf("Deliver error = %v, want ErrInvalidURL", err)
+	}
+}
@@ -1,7 +1,11 @@
 package routes
 
 import (
+	"tarot/app/http/controllers/api/v1/admin"
+	"tarot/app/http/controllers/api/v1/health"
+	"tarot/app/http/controllers/api/v1/payment"
 	"tarot/app/http/controllers/api/v1/tarot"
+	"tarot/app/http/controllers/api/v1/user"
 	"tarot/app/http/middlewares"
 
 	"github.com/gin-gonic/gin"
@@ -13,12 +17,25 @@ const (
 	GlobalLimit = "30000-h"
 	// 🎴 创建塔罗牌解读限流：每小时每IP 100 请求
 	ReadingLimit = "100-h"
+	// 👤 创建塔罗牌解读限流：每小时每用户 60 请求
+	ReadingUserLimit = "60-h"
+	// 🃏 服务端抽牌限流：每小时每IP 300 请求，额度与创建解读分开计算
+	DrawLimit = "300-h"
+	// 🃏 服务端抽牌限流：每小时每用户 180 请求
+	DrawUserLimit = "180-h"
 	// 🔍 查询结果限流：每分钟每IP 300 请求
 	QueryLimit = "300-m"
+	// 🙋 创建游客限流：每小时每IP 20 请求
+	GuestLimit = "20-h"
 )
 
 // RegisterAPIRoutes 注册所有 API 路由
 func RegisterAPIRoutes(r *gin.Engine) {
+	// 🩺 存活与就绪探针，不经过限流与鉴权
+	hc := health.NewHealthController()
+	r.GET("/healthz", hc.Liveness)
+	r.GET("/readyz", hc.Readiness)
+
 	v1 := r.Group("/v1")
 
 	v1.Use(
@@ -26,9 +43,13 @@ func RegisterAPIRoutes(r *gin.Engine) {
 		middlewares.SecurityHeaders(),
 		// TODO: 限流功能后续实现
 		// middlewares.LimitIP(GlobalLimit),
-		middlewares.Cors(),
+		middlewares.CurrentUser(),
 	)
 
+	// 🏷 构建版本信息
+	// GET /v1/version
+	v1.GET("/version", health.NewVersionController().Show)
+
 	// 🎴 塔罗牌相关路由
 	tarotRoutes := v1.Group("/tarot")
 	{
@@ -36,11 +57,11 @@ func RegisterAPIRoutes(r *gin.Engine) {
 
 		// 📝 创建塔罗牌解读任务
 		// POST /v1/tarot/readings
-		// 请求频率：每小时每IP最多100次
-		tarotRoutes.POST("/readings", rc.Store)
+		// 请求频率：每小时每IP最多100次，且每用户（游客按IP）最多60次
+		tarotRoutes.POST("/readings", middlewares.LimitIPAndUser(ReadingLimit, ReadingUserLimit), rc.Store)
 
 		// 📊 获取解读结果
-		// GET /v1/tarot/readings/:id
+		// GET /v1/tarot/readings/:id[?wait=25]，游客需携带 ?user_id=<guest_id>
 		// 请求频率：每分钟每IP最多300次
 		tarotRoutes.GET("/readings/:id", rc.GetResult)
 
@@ -49,11 +70,132 @@ func RegisterAPIRoutes(r *gin.Engine) {
 		// 请求频率：每分钟每IP最多300次
 		tarotRoutes.GET("/readings/:id/status", rc.GetStatus)
 
+		// 📡 批量获取任务状态
+		// POST /v1/tarot/readings/status
+		// 单次最多 50 个任务
+		tarotRoutes.POST("/readings/status", middlewares.LimitPerRoute(QueryLimit), rc.BatchStatus)
+
+		// 🌊 以 SSE 推送解读结果（response_mode=streaming）
+		// GET /v1/tarot/readings/:id/stream，游客需携带 ?user_id=<guest_id>
+		tarotRoutes.GET("/readings/:id/stream", rc.Stream)
+
 		// 添加新的路由
-		v1.GET("/users/:user_id/readings", rc.GetHistory)                // 获取历史记录
+		v1.GET("/users/:user_id/readings", rc.GetHistory)                // 获取历史记录，支持 ?q= 按问题搜索
 		v1.GET("/users/:user_id/readings/:task_id", rc.GetReadingDetail) // 获取单结果
+		v1.GET("/users/:user_id/readings/:task_id/export", rc.Export)    // 导出解读（Markdown）
+
+		// 💬 追问：延续原解读的 Dify 对话，沿用原卡牌与牌阵
+		// POST /v1/users/:user_id/readings/:task_id/follow-up
+		v1.POST("/users/:user_id/readings/:task_id/follow-up", middlewares.LimitIPAndUser(ReadingLimit, ReadingUserLimit), rc.FollowUp)
+
+		// 🔁 重新解读：失败的解读沿用原问题、卡牌与牌阵重新提交
+		// POST /v1/users/:user_id/readings/:task_id/retry
+		v1.POST("/users/:user_id/readings/:task_id/retry", middlewares.LimitIPAndUser(ReadingLimit, ReadingUserLimit), rc.Retry)
+
+		// 🃏 卡牌目录（含图片地址）
+		// GET /v1/tarot/cards
+		cc := tarot.NewCardController()
+		tarotRoutes.GET("/cards", cc.Index)
+		tarotRoutes.GET("/cards/:id", cc.Show)
+
+		// 🎴 服务端抽牌，返回的 draw_id 在创建解读时引用
+		// POST /v1/tarot/draws
+		tarotRoutes.POST("/draws", middlewares.LimitScopedIPAndUser("draw", DrawLimit, DrawUserLimit), tarot.NewDrawController().Store)
+
+		// 🔮 牌阵模板列表
+		// GET /v1/tarot/spreads
+		tarotRoutes.GET("/spreads", tarot.NewSpreadController().Index)
 
 		// 添加健康检查路由
 		tarotRoutes.GET("/health/redis", rc.CheckRedisHealth)
 	}
+
+	// 🙋 创建游客会话，返回的 guest_id 作为未登录时的 user_id 使用
+	// POST /v1/guests
+	v1.POST("/guests", middlewares.LimitPerRoute(GuestLimit), user.NewGuestController().Store)
+
+	// 👤 用户设置
+	uc := user.NewUserController()
+	// PUT /v1/users/:user_id/retention
+	v1.PUT("/users/:user_id/retention", uc.UpdateRetention)
+	// POST /v1/users/:user_id/migrate
+	v1.POST("/users/:user_id/migrate", uc.Migrate)
+
+	// 🔗 分享解读：生成有效期内的只读链接，链接中不包含用户 ID
+	sc := tarot.NewShareController()
+	// POST /v1/users/:user_id/readings/:task_id/share
+	v1.POST("/users/:user_id/readings/:task_id/share", sc.Store)
+	// GET /v1/shared/:token
+	v1.GET("/shared/:token", middlewares.LimitPerRoute(QueryLimit), sc.Show)
+
+	// ⭐ 解读评价，每个用户对每条解读只保留一条
+	// POST /v1/users/:user_id/readings/:task_id/feedback
+	v1.POST("/users/:user_id/readings/:task_id/feedback", user.NewFeedbackController().Store)
+
+	// 💳 支付相关路由
+	pc := payment.NewPaymentController()
+
+	// 📋 用户支付记录
+	// GET /v1/users/:user_id/payments
+	v1.GET("/users/:user_id/payments", pc.Index)
+
+	paymentRoutes := v1.Group("/payments")
+	{
+		// 📝 创建支付订单
+		// POST /v1/payments
+		paymentRoutes.POST("", pc.CreatePayment)
+
+		// 🔍 查询支付状态（待支付订单会向支付平台同步）
+		// GET /v1/payments/:order_no
+		paymentRoutes.GET("/:order_no", pc.Show)
+
+		// 🔔 支付结果回调（由支付平台调用，签名校验在服务内完成）
+		// POST /v1/payments/notify/wechat
+		paymentRoutes.POST("/notify/wechat", pc.NotifyWechat)
+		// POST /v1/payments/notify/alipay
+		paymentRoutes.POST("/notify/alipay", pc.NotifyAlipay)
+		// POST /v1/payments/notify/stripe
+		paymentRoutes.POST("/notify/stripe", pc.NotifyStripe)
+	}
+
+	// 🛠 管理端路由，需携带 X-Admin-Token
+	adminRoutes := v1.Group("/admin", middlewares.AdminAuth())
+	{
+		sc := admin.NewSpreadController()
+
+		// 🃏 牌阵模板管理
+		adminRoutes.GET("/spreads", sc.Index)
+		adminRoutes.POST("/spreads", sc.Store)
+		adminRoutes.POST("/spreads/reload", sc.Reload)
+		adminRoutes.GET("/spreads/:id", sc.Show)
+		adminRoutes.PUT("/spreads/:id", sc.Update)
+		adminRoutes.DELETE("/spreads/:id", sc.Destroy)
+
+		// ⏸ 队列暂停与恢复，?queue=name 指定命名队列（默认 default）
+		qc := admin.NewQueueController()
+		adminRoutes.GET("/queue", qc.Show)
+		adminRoutes.POST("/queue/pause", qc.Pause)
+		adminRoutes.POST("/queue/resume", qc.Resume)
+		// 🔧 运行时调整工作器数量
+		adminRoutes.PUT("/queue/workers", qc.Resize)
+
+		// 🤖 Dify 实例健康状态与负载
+		adminRoutes.GET("/dify/instances", admin.NewDifyController().Instances)
+
+		// 📊 系统概览：最近 24 小时的解读与支付、队列积压与 Dify 健康实例数
+		adminRoutes.GET("/stats", admin.NewStatsController().Show)
+
+		// 🔌 数据库与 Redis 连接池使用情况
+		adminRoutes.GET("/pools", admin.NewPoolController().Show)
+
+		// ⭐ 解读评价汇总
+		adminRoutes.GET("/feedback/summary", admin.NewFeedbackController().Summary)
+
+		// 💳 订单状态变化记录
+		pac := admin.NewPaymentController()
+		adminRoutes.GET("/payments/:order_no/events", pac.Events)
+		// 🗑 软删除与恢复已结束的订单（测试订单等）
+		adminRoutes.DELETE("/payments/:order_no", pac.Destroy)
+		adminRoutes.POST("/payments/:order_no/restore", pac.Restore)
+	}
 }